@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiError is an error response from the API
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// client calls the AdVantage REST API
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// newClient creates a client for the configured server
+func newClient(cfg *cliConfig) *client {
+	return &client{
+		server: strings.TrimRight(cfg.Server, "/"),
+		token:  cfg.Token,
+		// Requests carry no overall timeout since uploads and exports can be large; the
+		// transport's dial and header timeouts catch unreachable servers
+		http: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 5 * time.Minute,
+		}},
+	}
+}
+
+// url builds the URL of an API path with query parameters
+func (c *client) url(path string, params url.Values) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasPrefix(path, "/api/") {
+		path = "/api/v1" + path
+	}
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return c.server + path
+}
+
+// do sends a request and returns the response, turning error statuses into an apiError
+func (c *client) do(method, path string, params url.Values, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url(path, params), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("User-Agent", "advctl")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return resp, decodeError(resp)
+	}
+
+	return resp, nil
+}
+
+// doJSON sends a JSON request and decodes the JSON response into out, when given
+func (c *client) doJSON(method, path string, params url.Values, in, out interface{}, headers map[string]string) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers["Content-Type"] = "application/json"
+	}
+
+	resp, err := c.do(method, path, params, body, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// decodeError reads the {"error": ...} body of an error response
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	if body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+
+	return &apiError{Status: resp.StatusCode, Message: body.Error}
+}
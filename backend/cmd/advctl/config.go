@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultServer is the API used until login points advctl elsewhere
+const defaultServer = "http://localhost:8080"
+
+// cliConfig is advctl's saved state: the server and token from the last login, saved queries,
+// and the resumable uploads in progress
+type cliConfig struct {
+	Server  string                   `json:"server"`
+	Token   string                   `json:"token,omitempty"`
+	Queries map[string]savedQuery    `json:"queries,omitempty"`
+	Uploads map[string]pendingUpload `json:"uploads,omitempty"`
+}
+
+// savedQuery is a named API request with its query parameters
+type savedQuery struct {
+	Path   string            `json:"path"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// pendingUpload remembers a started resumable upload, keyed by the local file's path,
+// so an interrupted upload resumes instead of starting over
+type pendingUpload struct {
+	ID      string `json:"id"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// configPath returns where the config is stored, honoring ADVCTL_CONFIG
+func configPath() (string, error) {
+	if path := os.Getenv("ADVCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(dir, "advctl", "config.json"), nil
+}
+
+// loadConfig reads the config, applying the ADVCTL_SERVER and ADVCTL_TOKEN overrides
+func loadConfig() (*cliConfig, error) {
+	cfg := &cliConfig{Server: defaultServer}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to decode config %s: %w", path, err)
+		}
+	}
+
+	if server := os.Getenv("ADVCTL_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv("ADVCTL_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Queries == nil {
+		cfg.Queries = make(map[string]savedQuery)
+	}
+	if cfg.Uploads == nil {
+		cfg.Uploads = make(map[string]pendingUpload)
+	}
+
+	return cfg, nil
+}
+
+// save writes the config, readable only by the user since it holds a token
+func (c *cliConfig) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
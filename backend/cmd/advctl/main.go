@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const usage = `advctl scripts the AdVantage API.
+
+Usage:
+  advctl login [-server URL] [-email EMAIL] [-password-stdin]
+  advctl logout
+  advctl upload [-chunk-size MB] [-type TYPE] [-priority PRIORITY] [-watch] FILE
+  advctl watch [-interval 2s] FILE_ID
+  advctl get PATH [key=value ...]
+  advctl query save NAME PATH [key=value ...]
+  advctl query list
+  advctl query run NAME [key=value ...]
+  advctl query delete NAME
+  advctl export [-o FILE] PATH [key=value ...]
+
+PATH is an API path such as /analytics/domains/FILE_ID; /api/v1 is added when missing.
+ADVCTL_SERVER, ADVCTL_TOKEN and ADVCTL_CONFIG override the saved server, token and config file.
+`
+
+// advctl is a command-line client for the AdVantage API, so data engineers can script
+// uploads and queries instead of hand-writing curl
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "advctl: %v\n", err)
+		os.Exit(1)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "login":
+		err = runLogin(cfg, args)
+	case "logout":
+		cfg.Token = ""
+		err = cfg.save()
+	case "upload":
+		err = runUpload(cfg, args)
+	case "watch":
+		err = runWatch(cfg, args)
+	case "get":
+		err = runGet(cfg, args)
+	case "query":
+		err = runQuery(cfg, args)
+	case "export":
+		err = runExport(cfg, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "advctl: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "advctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runLogin signs in and saves the token for later commands
+func runLogin(cfg *cliConfig, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	server := flags.String("server", cfg.Server, "API server URL")
+	email := flags.String("email", "", "account email")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from standard input")
+	flags.Parse(args)
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *email == "" {
+		fmt.Fprint(os.Stderr, "Email: ")
+		*email = readLine(stdin)
+	}
+	password := os.Getenv("ADVCTL_PASSWORD")
+	if password == "" {
+		if !*passwordStdin {
+			fmt.Fprint(os.Stderr, "Password: ")
+		}
+		password = readLine(stdin)
+	}
+
+	cfg.Server = *server
+	cfg.Token = ""
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := newClient(cfg).doJSON(http.MethodPost, "/auth/login", nil, map[string]string{
+		"email":    *email,
+		"password": password,
+	}, &resp, nil)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	cfg.Token = resp.Token
+	if err := cfg.save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s\n", cfg.Server, *email)
+	return nil
+}
+
+// runGet prints the JSON response of a GET request
+func runGet(cfg *cliConfig, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: advctl get PATH [key=value ...]")
+	}
+	params, err := parseParams(args[1:])
+	if err != nil {
+		return err
+	}
+
+	return printResponse(newClient(cfg), args[0], params)
+}
+
+// runQuery manages and runs saved queries
+func runQuery(cfg *cliConfig, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: advctl query save|list|run|delete ...")
+	}
+
+	switch args[0] {
+	case "save":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: advctl query save NAME PATH [key=value ...]")
+		}
+		params, err := parseParams(args[3:])
+		if err != nil {
+			return err
+		}
+		query := savedQuery{Path: args[2], Params: make(map[string]string)}
+		for key := range params {
+			query.Params[key] = params.Get(key)
+		}
+		cfg.Queries[args[1]] = query
+		return cfg.save()
+
+	case "list":
+		names := make([]string, 0, len(cfg.Queries))
+		for name := range cfg.Queries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			query := cfg.Queries[name]
+			params := make(url.Values)
+			for key, value := range query.Params {
+				params.Set(key, value)
+			}
+			line := query.Path
+			if len(params) > 0 {
+				line += "?" + params.Encode()
+			}
+			fmt.Printf("%-24s %s\n", name, line)
+		}
+		return nil
+
+	case "run":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: advctl query run NAME [key=value ...]")
+		}
+		query, ok := cfg.Queries[args[1]]
+		if !ok {
+			return fmt.Errorf("no saved query named %q", args[1])
+		}
+		// Parameters given on the command line override the saved ones
+		overrides, err := parseParams(args[2:])
+		if err != nil {
+			return err
+		}
+		params := make(url.Values)
+		for key, value := range query.Params {
+			params.Set(key, value)
+		}
+		for key := range overrides {
+			params.Set(key, overrides.Get(key))
+		}
+		return printResponse(newClient(cfg), query.Path, params)
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: advctl query delete NAME")
+		}
+		if _, ok := cfg.Queries[args[1]]; !ok {
+			return fmt.Errorf("no saved query named %q", args[1])
+		}
+		delete(cfg.Queries, args[1])
+		return cfg.save()
+
+	default:
+		return fmt.Errorf("unknown query command %q", args[0])
+	}
+}
+
+// runExport downloads a response, such as a CSV export, to a file
+func runExport(cfg *cliConfig, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default the server's file name, - for standard output)")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		return fmt.Errorf("usage: advctl export [-o FILE] PATH [key=value ...]")
+	}
+	params, err := parseParams(flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	resp, err := newClient(cfg).do(http.MethodGet, flags.Arg(0), params, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	name := *output
+	if name == "" {
+		name = downloadName(resp, flags.Arg(0))
+	}
+
+	var dst io.Writer = os.Stdout
+	if name != "-" {
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dst = file
+	}
+
+	written, err := io.Copy(dst, resp.Body)
+	if err != nil {
+		return fmt.Errorf("download interrupted: %w", err)
+	}
+	if name != "-" {
+		fmt.Fprintf(os.Stderr, "Saved %s to %s\n", formatBytes(written), name)
+	}
+
+	return nil
+}
+
+// printResponse prints the indented JSON response of a GET request
+func printResponse(api *client, apiPath string, params url.Values) error {
+	var body json.RawMessage
+	if err := api.doJSON(http.MethodGet, apiPath, params, nil, &body, nil); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(body)
+}
+
+// parseParams parses key=value arguments into query parameters
+func parseParams(args []string) (url.Values, error) {
+	params := make(url.Values)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected key=value", arg)
+		}
+		params.Set(key, value)
+	}
+	return params, nil
+}
+
+// downloadName picks a local file name from the Content-Disposition header or the path
+func downloadName(resp *http.Response, apiPath string) string {
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		if _, name, ok := strings.Cut(disposition, "filename="); ok {
+			if name = path.Base(strings.Trim(name, `"`)); name != "." && name != "/" {
+				return name
+			}
+		}
+	}
+	return path.Base(apiPath)
+}
+
+// readLine reads a line from standard input without its line ending
+func readLine(reader *bufio.Reader) string {
+	line, _ := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uploadStatus is a resumable upload as reported by the API
+type uploadStatus struct {
+	ID       string `json:"id"`
+	Size     int64  `json:"size"`
+	Received int64  `json:"received"`
+}
+
+// fileStatus is an uploaded file as listed by the API
+type fileStatus struct {
+	ID       string `json:"id"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	Status   string `json:"status"`
+	JobID    string `json:"jobId,omitempty"`
+}
+
+// chunkRetries is how many times a failed chunk is retried before giving up
+const chunkRetries = 5
+
+// runUpload uploads a log file in resumable chunks, optionally waiting for it to be processed
+func runUpload(cfg *cliConfig, args []string) error {
+	flags := flag.NewFlagSet("upload", flag.ExitOnError)
+	chunkMB := flags.Int("chunk-size", 8, "chunk size in MB")
+	fileType := flags.String("type", "", "content type (default from the file extension)")
+	priority := flags.String("priority", "", "processing priority: low, normal or high (default the org's)")
+	watch := flags.Bool("watch", false, "wait until the file has been processed")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: advctl upload [-chunk-size MB] [-type TYPE] [-priority PRIORITY] [-watch] FILE")
+	}
+	if *chunkMB < 1 || *chunkMB > 64 {
+		return fmt.Errorf("-chunk-size must be between 1 and 64 MB")
+	}
+
+	path, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if *fileType == "" {
+		*fileType = contentTypeOf(path)
+	}
+
+	api := newClient(cfg)
+	upload, err := resumeOrStartUpload(cfg, api, path, info, *fileType)
+	if err != nil {
+		return err
+	}
+
+	// Send the remaining chunks, resuming from wherever the server says it got to
+	chunkSize := int64(*chunkMB) << 20
+	for upload.Received < upload.Size {
+		n := min(chunkSize, upload.Size-upload.Received)
+		received, err := sendChunk(api, upload.ID, file, upload.Received, n)
+		if err != nil {
+			return fmt.Errorf("upload interrupted at %d of %d bytes; run the same command again to resume: %w", upload.Received, upload.Size, err)
+		}
+		upload.Received = received
+		fmt.Fprintf(os.Stderr, "\rUploaded %s of %s (%.0f%%)", formatBytes(upload.Received), formatBytes(upload.Size), float64(upload.Received)/float64(upload.Size)*100)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	// The upload ID doubles as the idempotency key, so a retried completion returns the same file
+	var params url.Values
+	if *priority != "" {
+		params = url.Values{"priority": {*priority}}
+	}
+	var uploaded fileStatus
+	err = api.doJSON(http.MethodPost, "/files/uploads/"+upload.ID+"/complete", params, nil, &uploaded, map[string]string{"Idempotency-Key": upload.ID})
+	if err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	delete(cfg.Uploads, path)
+	if err := cfg.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Uploaded %s as file %s\n", uploaded.FileName, uploaded.ID)
+
+	if *watch {
+		return watchFile(api, uploaded.ID, 2*time.Second)
+	}
+	return nil
+}
+
+// resumeOrStartUpload continues the upload previously started for an unchanged file, or starts a new one
+func resumeOrStartUpload(cfg *cliConfig, api *client, path string, info os.FileInfo, fileType string) (*uploadStatus, error) {
+	if pending, ok := cfg.Uploads[path]; ok && pending.Size == info.Size() && pending.ModTime == info.ModTime().UnixNano() {
+		var upload uploadStatus
+		err := api.doJSON(http.MethodGet, "/files/uploads/"+pending.ID, nil, nil, &upload, nil)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Resuming upload %s from %s\n", upload.ID, formatBytes(upload.Received))
+			return &upload, nil
+		}
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+			return nil, fmt.Errorf("failed to check upload: %w", err)
+		}
+		// The server no longer has it; start over
+	}
+
+	var upload uploadStatus
+	err := api.doJSON(http.MethodPost, "/files/uploads", nil, map[string]interface{}{
+		"fileName": filepath.Base(path),
+		"fileType": fileType,
+		"size":     info.Size(),
+	}, &upload, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload: %w", err)
+	}
+
+	cfg.Uploads[path] = pendingUpload{ID: upload.ID, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if err := cfg.save(); err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+// sendChunk sends n bytes of the file from offset, retrying with backoff, and returns the
+// number of bytes the server has received. When the server has a different offset, such as
+// after a chunk whose response was lost, the server's offset wins.
+func sendChunk(api *client, uploadID string, file *os.File, offset, n int64) (int64, error) {
+	var lastErr error
+	for attempt := 0; attempt < chunkRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<attempt) * time.Second)
+		}
+
+		resp, err := api.do(http.MethodPatch, "/files/uploads/"+uploadID, nil, io.NewSectionReader(file, offset, n), map[string]string{
+			"Content-Type":  "application/octet-stream",
+			"Upload-Offset": strconv.FormatInt(offset, 10),
+		})
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			if received, parseErr := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64); parseErr == nil {
+				return received, nil
+			}
+		}
+		if err != nil {
+			lastErr = err
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.Status < 500 {
+				return 0, err
+			}
+			continue
+		}
+		resp.Body.Close()
+
+		received, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("server did not report the upload offset")
+		}
+		return received, nil
+	}
+
+	return 0, lastErr
+}
+
+// runWatch waits until a file has been processed
+func runWatch(cfg *cliConfig, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", 2*time.Second, "how often to check the status")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: advctl watch [-interval 2s] FILE_ID")
+	}
+
+	return watchFile(newClient(cfg), flags.Arg(0), *interval)
+}
+
+// watchFile polls a file's status until processing finishes, failing when processing failed
+func watchFile(api *client, fileID string, interval time.Duration) error {
+	last := ""
+	for {
+		var files []fileStatus
+		if err := api.doJSON(http.MethodGet, "/files/list", nil, nil, &files, nil); err != nil {
+			return fmt.Errorf("failed to get file status: %w", err)
+		}
+
+		status := ""
+		for _, file := range files {
+			if file.ID == fileID {
+				status = file.Status
+			}
+		}
+		if status == "" {
+			return fmt.Errorf("file %s not found", fileID)
+		}
+		if status != last {
+			fmt.Fprintf(os.Stderr, "%s  %s\n", time.Now().Format("15:04:05"), status)
+			last = status
+		}
+
+		switch status {
+		case "processed":
+			return nil
+		case "failed":
+			return fmt.Errorf("processing of file %s failed; see advctl get /files/analysis/%s", fileID, fileID)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// contentTypeOf guesses a log file's content type from its extension
+func contentTypeOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "application/x-ndjson"
+	case ".json":
+		return "application/json"
+	case ".txt", ".log":
+		return "text/plain"
+	case ".xls":
+		return "application/vnd.ms-excel"
+	case ".xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv"
+	}
+}
+
+// formatBytes formats a byte count in binary units
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/backup"
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+const usage = `backup exports AdVantage metadata and analyses to a portable archive and restores it.
+
+Usage:
+  backup export [-dir uploads] [-o FILE]
+  backup restore [-dir uploads] FILE
+
+-dir is the server's upload directory, whose reports are archived with the database.
+Restore needs a migrated database without users, such as a fresh environment. Archives are
+refused once they decompress past INGESTION_MAX_DECOMPRESSION_RATIO times their size or
+INGESTION_MAX_DECOMPRESSED_MB, as uploads are; raise them to restore a larger backup.
+`
+
+// backup exports files, analyses, campaign metadata and rollups to an archive and restores them
+// into a fresh database, for disaster recovery and cloning environments
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Setup logger
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	slog.SetDefault(logger)
+
+	command, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	dir := flags.String("dir", "uploads", "upload directory the server saves analyses under")
+	var out *string
+	switch command {
+	case "export":
+		out = flags.String("o", fmt.Sprintf("advantage-backup-%s.zip", time.Now().UTC().Format("20060102-150405")), "archive to write")
+	case "restore":
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "backup: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	flags.Parse(args)
+	if command == "restore" && flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Load credentials from the secrets backend when configured
+	if _, err := secrets.LoadInto(context.Background(), cfg); err != nil {
+		slog.Error("Failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Connect to database
+	database, err := db.NewPostgresDB(cfg.Database)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	// Large backups take a while, so there is no timeout; an interrupt cancels instead. Backups
+	// cover every org, so they run as system work.
+	ctx, cancel := signal.NotifyContext(db.AsSystem(context.Background()), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	reportsDir := filepath.Join(*dir, "reports")
+	var manifest *backup.Manifest
+	if command == "export" {
+		manifest, err = runExport(ctx, database, reportsDir, *out)
+	} else {
+		limits := storage.DecompressionLimits{
+			MaxRatio: int64(cfg.Ingestion.MaxDecompressionRatio),
+			MaxBytes: int64(cfg.Ingestion.MaxDecompressedMB) << 20,
+		}
+		manifest, err = runRestore(ctx, database, reportsDir, flags.Arg(0), limits)
+	}
+	if err != nil {
+		slog.Error("Backup "+command+" failed", "error", err)
+		os.Exit(1)
+	}
+
+	tables, analyses := manifest.Counts()
+	slog.Info("Backup "+command+" completed successfully", "tables", tables, "analyses", analyses)
+}
+
+// runExport writes a backup archive, first to a temporary file so an interrupted export never
+// leaves a partial archive under the requested name
+func runExport(ctx context.Context, database *db.PostgresDB, reportsDir, name string) (*backup.Manifest, error) {
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	manifest, err := backup.Export(ctx, database.Pool, reportsDir, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), name); err != nil {
+		return nil, fmt.Errorf("failed to save archive: %w", err)
+	}
+
+	slog.Info("Wrote backup archive", "file", name)
+	return manifest, nil
+}
+
+// runRestore restores a backup archive within decompression limits
+func runRestore(ctx context.Context, database *db.PostgresDB, reportsDir, name string, limits storage.DecompressionLimits) (*backup.Manifest, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	return backup.Restore(ctx, database.Pool, reportsDir, file, info.Size(), limits)
+}
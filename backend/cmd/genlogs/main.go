@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+)
+
+// genlogs writes synthetic Beeswax, Trade Desk or DV360 logs of a chosen size and cardinality,
+// optionally with injected anomalies, for load testing ingestion and demoing with fake data
+func main() {
+	defaults := loggen.DefaultConfig()
+
+	format := flag.String("format", defaults.Format, "log format: "+strings.Join(loggen.FormatNames(), ", "))
+	rows := flag.Int("rows", defaults.Rows, "rows to write")
+	size := flag.String("size", "", "approximate file size to write instead of -rows, e.g. 500MB or 2GB")
+	out := flag.String("out", "", "file to write (default stdout)")
+	seed := flag.Int64("seed", defaults.Seed, "random seed; the same seed and flags write the same log")
+	start := flag.String("start", defaults.Start.Format("2006-01-02"), "first day of traffic, YYYY-MM-DD")
+	days := flag.Int("days", defaults.Days, "days of traffic")
+	accounts := flag.Int("accounts", defaults.Accounts, "advertiser accounts")
+	campaigns := flag.Int("campaigns", defaults.Campaigns, "campaigns, spread across the accounts")
+	creatives := flag.Int("creatives", defaults.Creatives, "creatives per campaign")
+	domains := flag.Int("domains", defaults.Domains, "distinct domains")
+	users := flag.Int("users", defaults.Users, "distinct users")
+	anomalies := flag.String("anomalies", "", "comma-separated anomalies to inject ("+strings.Join(loggen.Anomalies, ", ")+") or all")
+	flag.Parse()
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -start: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg := loggen.Config{
+		Format:    *format,
+		Rows:      *rows,
+		Seed:      *seed,
+		Start:     startDate,
+		Days:      *days,
+		Accounts:  *accounts,
+		Campaigns: *campaigns,
+		Creatives: *creatives,
+		Domains:   *domains,
+		Users:     *users,
+		Anomalies: parseAnomalies(*anomalies),
+	}
+
+	if *size != "" {
+		bytes, err := parseSize(*size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -size: %v\n", err)
+			os.Exit(2)
+		}
+		if cfg.Rows, err = loggen.RowsForSize(cfg, bytes); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid options: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	generator, err := loggen.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid options: %v\n", err)
+		os.Exit(2)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	began := time.Now()
+	written, err := generator.Write(w)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write log: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The summary goes to stderr so it doesn't end up in a log written to stdout
+	fmt.Fprintf(os.Stderr, "Wrote %d %s rows, %.1f MB, in %s\n", cfg.Rows, cfg.Format,
+		float64(written)/(1<<20), time.Since(began).Round(time.Millisecond))
+	for _, injection := range generator.Injections() {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", injection.Anomaly, injection.Detail)
+	}
+}
+
+// parseAnomalies splits the -anomalies flag, expanding "all"
+func parseAnomalies(value string) []string {
+	if value == "" {
+		return nil
+	}
+	if value == "all" {
+		return loggen.Anomalies
+	}
+	var anomalies []string
+	for _, anomaly := range strings.Split(value, ",") {
+		if anomaly = strings.TrimSpace(anomaly); anomaly != "" {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies
+}
+
+// sizeUnits are the -size suffixes, largest first so "MB" isn't read as "B"
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a byte size such as 500MB or 1.5GB
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSuffix(value, unit.suffix), unit.bytes
+			break
+		}
+	}
+
+	amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("expected a positive size such as 500MB, got %q", value)
+	}
+	return int64(amount * float64(multiplier)), nil
+}
@@ -2,15 +2,24 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
 	"github.com/bolognesandwiches/AdVantage/internal/config"
 	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
+	"github.com/jackc/pgx/v5"
 )
 
 func main() {
+	contract := flag.Bool("contract", false, "also run contract steps, which drop what the previous release still used; only run once it is fully rolled out")
+	lockTimeout := flag.Duration("lock-timeout", 5*time.Second, "longest a schema change on a busy table waits for its lock before retrying")
+	timeout := flag.Duration("timeout", time.Hour, "time limit for the whole migration, including concurrent index builds")
+	flag.Parse()
+
 	// Setup logger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -24,6 +33,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Load credentials from the secrets backend when configured
+	if _, err := secrets.LoadInto(context.Background(), cfg); err != nil {
+		slog.Error("Failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+
 	// Connect to database
 	database, err := db.NewPostgresDB(cfg.Database)
 	if err != nil {
@@ -32,20 +47,79 @@ func main() {
 	}
 	defer database.Close()
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Create context with timeout; migrations backfill every org's rows
+	ctx, cancel := context.WithTimeout(db.AsSystem(context.Background()), *timeout)
 	defer cancel()
 
 	// Run migrations
-	if err := runMigrations(ctx, database); err != nil {
+	online := database.Online(*lockTimeout)
+	if err := runMigrations(ctx, database, online); err != nil {
 		slog.Error("Failed to run migrations", "error", err)
 		os.Exit(1)
 	}
 
+	if *contract {
+		if err := runContractMigrations(ctx, online); err != nil {
+			slog.Error("Failed to run contract migrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	slog.Info("Migrations completed successfully")
 }
 
-func runMigrations(ctx context.Context, database *db.PostgresDB) error {
+// tenantTables are the tables holding org data, isolated with row-level security. Most belong to
+// orgs through their users; orgColumn marks those recording the org itself. Tables hanging off
+// another tenant table name it as their parent, with the column holding its ID, and see the rows
+// whose parent row the org can see.
+var tenantTables = []struct {
+	name      string
+	orgColumn bool
+	parent    string
+	parentKey string
+}{
+	{name: "api_keys"},
+	{name: "brand_safety_lists"},
+	{name: "campaign_annotations"},
+	{name: "campaign_goals"},
+	{name: "campaign_performance"},
+	{name: "category_overrides"},
+	{name: "custom_metrics", orgColumn: true},
+	{name: "data_shares", orgColumn: true},
+	{name: "dataset_files", parent: "datasets", parentKey: "dataset_id"},
+	{name: "datasets"},
+	{name: "dead_letter_jobs"},
+	{name: "delivery_report_rows"},
+	{name: "digest_deliveries"},
+	{name: "embeds"},
+	{name: "experiments"},
+	{name: "export_destinations"},
+	{name: "export_table_syncs", parent: "export_destinations", parentKey: "destination_id"},
+	{name: "file_schemas"},
+	{name: "files"},
+	{name: "idempotency_keys"},
+	{name: "integrations"},
+	{name: "invoice_rows"},
+	{name: "log_records"},
+	{name: "log_streams"},
+	{name: "mapping_profiles"},
+	{name: "metric_rollups"},
+	{name: "org_usage", orgColumn: true},
+	{name: "parser_runs", parent: "files", parentKey: "file_id"},
+	{name: "processing_jobs"},
+	{name: "realtime_metrics"},
+	{name: "report_templates", orgColumn: true},
+	{name: "rollup_files"},
+	{name: "sessions"},
+	{name: "site_outcomes"},
+	{name: "upload_batch_files", parent: "upload_batches", parentKey: "batch_id"},
+	{name: "upload_batches"},
+	{name: "user_preferences"},
+}
+
+// runMigrations runs the expand steps, which only add to the schema, so the previous release keeps
+// working against it while instances are switched over
+func runMigrations(ctx context.Context, database *db.PostgresDB, online *db.OnlineMigrator) error {
 	// Create users table
 	_, err := database.Pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS users (
@@ -62,13 +136,1097 @@ func runMigrations(ctx context.Context, database *db.PostgresDB) error {
 		return err
 	}
 
-	// Create index on email
+	// Create organizations table; every user belongs to one, starting with a personal org
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS organizations (
+			id VARCHAR(255) PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id VARCHAR(255) REFERENCES organizations (id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// An org's priority applies to its uploads that don't ask for one
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS job_priority VARCHAR(16) NOT NULL DEFAULT 'normal'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Campaign spend is reported in the org's currency, converted from the currencies campaigns run in
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS reporting_currency VARCHAR(3) NOT NULL DEFAULT 'USD'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// An org's upload defaults apply to its uploads that are parsed without options of their own
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS upload_defaults JSONB NOT NULL DEFAULT '{}'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Give users from before organizations a personal org with their own ID
+	_, err = database.Pool.Exec(ctx, `
+		INSERT INTO organizations (id, name, created_at)
+		SELECT id, first_name || ' ' || last_name, created_at
+		FROM users
+		WHERE org_id IS NULL
+		ON CONFLICT (id) DO NOTHING
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		UPDATE users SET org_id = id WHERE org_id IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE users ALTER COLUMN org_id SET NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Orgs from before plans keep everything they had on the enterprise plan; new orgs start on a trial
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE organizations
+			ADD COLUMN IF NOT EXISTS plan VARCHAR(32) NOT NULL DEFAULT 'enterprise',
+			ADD COLUMN IF NOT EXISTS trial_ends_at TIMESTAMP WITH TIME ZONE
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE organizations ALTER COLUMN plan SET DEFAULT 'trial'
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_users_org_id ON users (org_id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add profile fields and the ID of the user's stored avatar
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE users
+			ADD COLUMN IF NOT EXISTS company VARCHAR(255) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS role VARCHAR(255) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS avatar_id VARCHAR(255)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create sessions table for signed-in devices; access tokens carry the session ID
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			device VARCHAR(255) NOT NULL,
+			user_agent TEXT NOT NULL,
+			ip_address VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id, last_seen_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add refresh tokens to sessions, keeping the hash of the token each replaced so a replaced
+	// token being used again revokes the session. Sessions from before refresh tokens have none
+	// and can't be refreshed.
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE sessions
+			ADD COLUMN IF NOT EXISTS refresh_token_hash VARCHAR(64) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS previous_refresh_token_hash VARCHAR(64)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions (refresh_token_hash)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_sessions_previous_refresh_token_hash ON sessions (previous_refresh_token_hash)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create user preferences table for report and dashboard defaults
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS user_preferences (
+			user_id VARCHAR(255) PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+			currency CHAR(3) NOT NULL,
+			timezone VARCHAR(64) NOT NULL,
+			date_format VARCHAR(16) NOT NULL,
+			default_dashboard VARCHAR(64) NOT NULL,
+			email_digest BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create digest deliveries table so each weekly digest is sent once, whichever instance sends it
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS digest_deliveries (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+			sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, period_end)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create files table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS files (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			file_name VARCHAR(1024) NOT NULL,
+			file_size BIGINT NOT NULL,
+			file_type VARCHAR(255) NOT NULL,
+			file_path VARCHAR(2048) NOT NULL,
+			status VARCHAR(32) NOT NULL,
+			uploaded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create index on file owner
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_files_user_id ON files (user_id, uploaded_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Files can be tagged and filed in folders to keep months of uploads in order
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE files
+			ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+			ADD COLUMN IF NOT EXISTS folder VARCHAR(1024) NOT NULL DEFAULT ''
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create processing jobs table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS processing_jobs (
+			id VARCHAR(255) PRIMARY KEY,
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			status VARCHAR(32) NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			started_at TIMESTAMP WITH TIME ZONE,
+			completed_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Jobs run in priority order, so interactive uploads go ahead of bulk backfills
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS priority VARCHAR(16) NOT NULL DEFAULT 'normal'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Reprocessing jobs parse a file again, optionally overriding its detected parser and log format
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE processing_jobs
+			ADD COLUMN IF NOT EXISTS reprocess BOOLEAN NOT NULL DEFAULT false,
+			ADD COLUMN IF NOT EXISTS parser VARCHAR(32) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS log_format VARCHAR(64) NOT NULL DEFAULT ''
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Failed jobs are retried, and dead-lettered once they run out of attempts
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create index on job status for queue polling
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_processing_jobs_status ON processing_jobs (status, created_at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create dead letter table for jobs that failed on every attempt, kept until requeued
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS dead_letter_jobs (
+			job_id VARCHAR(255) PRIMARY KEY REFERENCES processing_jobs (id) ON DELETE CASCADE,
+			file_id VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			priority VARCHAR(16) NOT NULL,
+			reprocess BOOLEAN NOT NULL,
+			parser VARCHAR(32) NOT NULL,
+			log_format VARCHAR(64) NOT NULL,
+			attempts INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			stack TEXT NOT NULL DEFAULT '',
+			failed_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create idempotency keys table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			key VARCHAR(255) NOT NULL,
+			file_id VARCHAR(255) NOT NULL,
+			job_id VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, key)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create upload batches table for files uploaded together in one request
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS upload_batches (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			idempotency_key VARCHAR(255),
+			rejected JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (user_id, idempotency_key)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create upload batch files table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS upload_batch_files (
+			batch_id VARCHAR(255) NOT NULL REFERENCES upload_batches (id) ON DELETE CASCADE,
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			job_id VARCHAR(255) NOT NULL,
+			position INT NOT NULL,
+			PRIMARY KEY (batch_id, file_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create datasets table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS datasets (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			campaign_id VARCHAR(255) NOT NULL DEFAULT '',
+			source VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_datasets_user_id ON datasets (user_id, updated_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create dataset files table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS dataset_files (
+			dataset_id VARCHAR(255) NOT NULL REFERENCES datasets (id) ON DELETE CASCADE,
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			added_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (dataset_id, file_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create integrations table
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS integrations (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			provider VARCHAR(50) NOT NULL,
+			account_id VARCHAR(255) NOT NULL,
+			refresh_token TEXT NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			last_synced_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (user_id, provider, account_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Push integrations, like Google Sheets reports, keep their tab and query alongside the connection
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE integrations ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create campaign performance table for daily reports pulled from integrations
 	_, err = database.Pool.Exec(ctx, `
-		CREATE INDEX IF NOT EXISTS idx_users_email ON users (email)
+		CREATE TABLE IF NOT EXISTS campaign_performance (
+			integration_id VARCHAR(255) NOT NULL REFERENCES integrations (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			campaign_id VARCHAR(255) NOT NULL,
+			campaign_name VARCHAR(1024) NOT NULL,
+			date DATE NOT NULL,
+			impressions BIGINT NOT NULL,
+			clicks BIGINT NOT NULL,
+			conversions DOUBLE PRECISION NOT NULL,
+			cost_micros BIGINT NOT NULL,
+			PRIMARY KEY (integration_id, campaign_id, date)
+		)
 	`)
 	if err != nil {
 		return err
 	}
 
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_campaign_performance_user_date ON campaign_performance (user_id, date)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create site outcomes table for daily analytics pulled from integrations
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS site_outcomes (
+			integration_id VARCHAR(255) NOT NULL REFERENCES integrations (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			date DATE NOT NULL,
+			source VARCHAR(255) NOT NULL,
+			medium VARCHAR(255) NOT NULL,
+			campaign VARCHAR(1024) NOT NULL,
+			sessions BIGINT NOT NULL,
+			conversions DOUBLE PRECISION NOT NULL,
+			revenue DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (integration_id, date, source, medium, campaign)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_site_outcomes_user_date ON site_outcomes (user_id, date)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create delivery report rows table for ad server delivery reports imported from uploaded files
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS delivery_report_rows (
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			imported_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			date DATE NOT NULL,
+			order_id VARCHAR(255) NOT NULL,
+			order_name VARCHAR(1024) NOT NULL,
+			line_item_id VARCHAR(255) NOT NULL,
+			line_item_name VARCHAR(1024) NOT NULL,
+			ad_unit VARCHAR(1024) NOT NULL,
+			impressions BIGINT NOT NULL,
+			clicks BIGINT NOT NULL,
+			revenue_micros BIGINT NOT NULL,
+			PRIMARY KEY (file_id, date, order_id, order_name, line_item_id, line_item_name, ad_unit)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_delivery_report_rows_user_date ON delivery_report_rows (user_id, date)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create invoice rows table for DSP invoices imported from uploaded files
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS invoice_rows (
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			imported_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			date DATE NOT NULL,
+			campaign_id VARCHAR(255) NOT NULL,
+			campaign_name VARCHAR(1024) NOT NULL,
+			impressions BIGINT NOT NULL,
+			spend_micros BIGINT NOT NULL,
+			PRIMARY KEY (file_id, date, campaign_id, campaign_name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_invoice_rows_user_date ON invoice_rows (user_id, date)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create category overrides table for the content categories users assign to domains
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS category_overrides (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			domain VARCHAR(1024) NOT NULL,
+			category VARCHAR(20) NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, domain)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create mapping profiles table for the header names users map onto canonical columns, per log source
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS mapping_profiles (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			source VARCHAR(64) NOT NULL,
+			columns JSONB NOT NULL DEFAULT '{}',
+			auto_extend BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, source)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create file schemas table recording each uploaded log's columns and how they drifted from the previous upload
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS file_schemas (
+			file_id VARCHAR(255) PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			source VARCHAR(64) NOT NULL,
+			columns TEXT[] NOT NULL,
+			mapped JSONB NOT NULL,
+			drift JSONB,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_file_schemas_user_source ON file_schemas (user_id, source, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create brand safety lists table for the block lists, allow lists and keyword sets files are screened against
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS brand_safety_lists (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			entries TEXT[] NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (user_id, name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create metric rollups table for the hourly and daily aggregates kept as files are processed
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS metric_rollups (
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			grain VARCHAR(10) NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			value VARCHAR(1024) NOT NULL,
+			bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+			bids BIGINT NOT NULL,
+			impressions BIGINT NOT NULL,
+			clicks BIGINT NOT NULL,
+			conversions BIGINT NOT NULL,
+			spend DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (file_id, grain, dimension, value, bucket)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_metric_rollups_user ON metric_rollups (user_id, dimension, grain, value, bucket)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Rollups carry conversion revenue for ROAS
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE metric_rollups ADD COLUMN IF NOT EXISTS revenue DOUBLE PRECISION NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Rollups carry viewability counts, so custom metrics such as viewable CPM can be computed from them
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE metric_rollups
+			ADD COLUMN IF NOT EXISTS measurable_impressions BIGINT NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS viewable_impressions BIGINT NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create custom metrics table for the derived metrics organizations define
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS custom_metrics (
+			org_id VARCHAR(255) NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+			name VARCHAR(64) NOT NULL,
+			formula TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (org_id, name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create report templates table for the layouts organizations generate reports with
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS report_templates (
+			id VARCHAR(255) PRIMARY KEY,
+			org_id VARCHAR(255) NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			sections JSONB NOT NULL,
+			created_by VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (org_id, name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create embeds table for tokenized charts shown outside the app; only token hashes are stored
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS embeds (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			widget VARCHAR(32) NOT NULL,
+			file_id VARCHAR(255) NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+			metric VARCHAR(255) NOT NULL,
+			item_limit INTEGER NOT NULL DEFAULT 0,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_embeds_user_id ON embeds (user_id, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create API keys table for BI tools reading the metrics feed; only key hashes are stored
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			prefix VARCHAR(32) NOT NULL,
+			key_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create data shares table for tokens exposing some campaigns to third parties; only token
+	// hashes are stored
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS data_shares (
+			id VARCHAR(255) PRIMARY KEY,
+			org_id VARCHAR(255) NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			campaign_ids TEXT[] NOT NULL,
+			date_from DATE,
+			date_to DATE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_data_shares_org_id ON data_shares (org_id, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create org usage table metering each org's API calls and ingested rows by calendar month
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS org_usage (
+			org_id VARCHAR(255) NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+			period DATE NOT NULL,
+			api_calls BIGINT NOT NULL DEFAULT 0,
+			rows_ingested BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (org_id, period)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create parser runs table recording each file's parse, so parser regressions show up
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS parser_runs (
+			file_id VARCHAR(255) NOT NULL,
+			parser VARCHAR(32) NOT NULL,
+			source VARCHAR(64) NOT NULL,
+			failed BOOLEAN NOT NULL,
+			row_count BIGINT NOT NULL,
+			row_errors BIGINT NOT NULL,
+			bytes BIGINT NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			finished_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_parser_runs_finished_at ON parser_runs (finished_at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create incidents table for the notices admins post to the public status page
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS incidents (
+			id VARCHAR(255) PRIMARY KEY,
+			title VARCHAR(255) NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			severity VARCHAR(16) NOT NULL,
+			started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			resolved_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create campaign goals table for the targets users measure their campaigns against
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS campaign_goals (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			campaign_id VARCHAR(255) NOT NULL,
+			target_cpa DOUBLE PRECISION,
+			target_ctr DOUBLE PRECISION,
+			target_roas DOUBLE PRECISION,
+			daily_spend DOUBLE PRECISION,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, campaign_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Campaign goals are set in the campaign's own currency, with an optional flight budget
+	_, err = database.Pool.Exec(ctx, `
+		ALTER TABLE campaign_goals
+			ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+			ADD COLUMN IF NOT EXISTS budget DOUBLE PRECISION
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create campaign annotations table for the events users record against their campaigns
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS campaign_annotations (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			campaign_id VARCHAR(255) NOT NULL,
+			type VARCHAR(50) NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_campaign_annotations_user_occurred ON campaign_annotations (user_id, occurred_at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create experiments table for users' A/B tests and the result of their latest analysis
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS experiments (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			control JSONB NOT NULL,
+			variant JSONB NOT NULL,
+			from_date DATE NOT NULL,
+			to_date DATE NOT NULL,
+			confidence DOUBLE PRECISION NOT NULL,
+			result JSONB,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_experiments_user_id ON experiments (user_id, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create exchange rates table for the daily snapshots spend is converted between currencies with
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+			rate_date DATE NOT NULL,
+			currency VARCHAR(3) NOT NULL,
+			per_usd DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (rate_date, currency)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create log streams table for customer Kafka topics consumed into rollups
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS log_streams (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			proxy_url TEXT NOT NULL,
+			username VARCHAR(255) NOT NULL DEFAULT '',
+			password TEXT NOT NULL DEFAULT '',
+			topic VARCHAR(255) NOT NULL,
+			group_id VARCHAR(255) NOT NULL,
+			source VARCHAR(50) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			records_consumed BIGINT NOT NULL DEFAULT 0,
+			records_rejected BIGINT NOT NULL DEFAULT 0,
+			last_consumed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (user_id, topic, group_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create export destinations table for warehouses users' data is exported to
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS export_destinations (
+			id VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			settings JSONB NOT NULL DEFAULT '{}',
+			credentials TEXT NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			last_synced_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_export_destinations_user ON export_destinations (user_id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create export table syncs table tracking each table's export to a destination
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS export_table_syncs (
+			destination_id VARCHAR(255) NOT NULL REFERENCES export_destinations (id) ON DELETE CASCADE,
+			table_name VARCHAR(255) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			rows_loaded BIGINT NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			sync_cursor TIMESTAMP WITH TIME ZONE,
+			last_synced_at TIMESTAMP WITH TIME ZONE,
+			last_succeeded_at TIMESTAMP WITH TIME ZONE,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (destination_id, table_name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create realtime metrics table of streamed logs' per-minute delivery, pruned by the server after an hour
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS realtime_metrics (
+			user_id VARCHAR(255) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+			campaign_id VARCHAR(255) NOT NULL,
+			minute TIMESTAMP WITH TIME ZONE NOT NULL,
+			bids BIGINT NOT NULL,
+			impressions BIGINT NOT NULL,
+			clicks BIGINT NOT NULL,
+			conversions BIGINT NOT NULL,
+			spend DOUBLE PRECISION NOT NULL,
+			revenue DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (user_id, campaign_id, minute)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_realtime_metrics_minute ON realtime_metrics (minute)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create rollup files table recording which processed files have rollups
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS rollup_files (
+			file_id VARCHAR(255) PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL,
+			rolled_up_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create log records table, partitioned by month of bid time; partitions are managed by the server
+	_, err = database.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS log_records (
+			file_id VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			auction_id VARCHAR(255) NOT NULL,
+			account_id VARCHAR(255) NOT NULL,
+			campaign_id VARCHAR(255) NOT NULL,
+			creative_id VARCHAR(255) NOT NULL,
+			bid_time TIMESTAMP WITH TIME ZONE NOT NULL,
+			impression_time TIMESTAMP WITH TIME ZONE,
+			bid_price_micros BIGINT NOT NULL,
+			clearing_price_micros BIGINT NOT NULL,
+			win_cost_micros BIGINT NOT NULL,
+			clicks INTEGER NOT NULL,
+			conversions INTEGER NOT NULL,
+			domain VARCHAR(1024) NOT NULL,
+			geo_country VARCHAR(255) NOT NULL,
+			geo_region VARCHAR(255) NOT NULL,
+			geo_city VARCHAR(255) NOT NULL,
+			device_type VARCHAR(255) NOT NULL,
+			browser VARCHAR(255) NOT NULL,
+			os VARCHAR(255) NOT NULL,
+			ad_position VARCHAR(255) NOT NULL,
+			viewer_id VARCHAR(255) NOT NULL,
+			exchange VARCHAR(255) NOT NULL,
+			seller_id VARCHAR(255) NOT NULL
+		) PARTITION BY RANGE (bid_time)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Log records is the platform's largest and busiest table, so its schema changes go through the
+	// online migrator: DDL gives up on its lock rather than stalling ingestion behind it, and indexes
+	// are built partition by partition without blocking writes
+
+	// Records carry conversion revenue, added to every partition; a constant default doesn't rewrite the table
+	err = online.Exec(ctx, `
+		ALTER TABLE log_records ADD COLUMN IF NOT EXISTS revenue_micros BIGINT NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create indexes on log records; they are built on every partition
+	if err := online.CreateIndex(ctx, "idx_log_records_file", "log_records", "file_id"); err != nil {
+		return err
+	}
+
+	if err := online.CreateIndex(ctx, "idx_log_records_campaign", "log_records", "user_id, campaign_id, bid_time"); err != nil {
+		return err
+	}
+
+	// Isolate tenant data with row-level security as a backstop to the queries' own user filters.
+	// Requests set app.org_id and only see rows of their org or its users. System work such as
+	// processing jobs sets app.system instead and sees every row; connections with neither see
+	// none, so a query that loses its org fails closed. FORCE applies the policies to the table
+	// owner too, though superusers always bypass them, so the server must not connect as one.
+	for _, table := range tenantTables {
+		identifier := pgx.Identifier{table.name}.Sanitize()
+		orgFilter := "user_id IN (SELECT id FROM users WHERE org_id = current_setting('app.org_id', true))"
+		switch {
+		case table.orgColumn:
+			orgFilter = "org_id = current_setting('app.org_id', true)"
+		case table.parent != "":
+			// The parent's own policy decides which of its rows the subquery sees
+			orgFilter = fmt.Sprintf("EXISTS (SELECT 1 FROM %s p WHERE p.id = %s)", pgx.Identifier{table.parent}.Sanitize(), pgx.Identifier{table.parentKey}.Sanitize())
+		}
+		statements := []string{
+			fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", identifier),
+			fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", identifier),
+			fmt.Sprintf("DROP POLICY IF EXISTS org_isolation ON %s", identifier),
+			fmt.Sprintf(`
+				CREATE POLICY org_isolation ON %s
+				USING (
+					current_setting('app.system', true) = 'on'
+					OR (COALESCE(current_setting('app.org_id', true), '') <> '' AND %s)
+				)
+			`, identifier, orgFilter),
+		}
+		for _, statement := range statements {
+			if _, err := database.Pool.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("failed to secure %s: %w", table.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runContractMigrations runs the contract steps, which drop columns and indexes the current release
+// no longer uses. The previous release may still rely on them, so they only run on request once
+// every instance runs the current release.
+func runContractMigrations(ctx context.Context, online *db.OnlineMigrator) error {
+	// The unique constraint on users.email already indexes it
+	if err := online.DropIndex(ctx, "idx_users_email"); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sharedTables are the tables that aren't isolated by org, and why
+var sharedTables = map[string]string{
+	"users":          "read to sign in before the org is known",
+	"organizations":  "read to sign in before the org is known",
+	"exchange_rates": "shared by every org",
+	"incidents":      "status page for every org",
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\t\t\)`)
+	addColumnPattern   = regexp.MustCompile(`ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+)`)
+	referencesPattern  = regexp.MustCompile(`REFERENCES (\w+)`)
+)
+
+// migratedTable is a table as the migrations create it
+type migratedTable struct {
+	columns    map[string]bool
+	references map[string]string // column → table it references
+}
+
+// migratedTables reads the tables the migrations create from their source
+func migratedTables(t *testing.T) map[string]*migratedTable {
+	t.Helper()
+
+	source, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+
+	tables := make(map[string]*migratedTable)
+	for _, match := range createTablePattern.FindAllStringSubmatch(string(source), -1) {
+		table := &migratedTable{columns: make(map[string]bool), references: make(map[string]string)}
+		for _, line := range strings.Split(match[2], "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0] == "PRIMARY" || fields[0] == "UNIQUE" {
+				continue
+			}
+			column := fields[0]
+			table.columns[column] = true
+			if reference := referencesPattern.FindStringSubmatch(line); reference != nil {
+				table.references[column] = reference[1]
+			}
+		}
+		tables[match[1]] = table
+	}
+	for _, match := range addColumnPattern.FindAllStringSubmatch(string(source), -1) {
+		if table, ok := tables[match[1]]; ok {
+			table.columns[match[2]] = true
+		}
+	}
+	return tables
+}
+
+func TestEveryTableHoldingOrgDataHasAPolicy(t *testing.T) {
+	tables := migratedTables(t)
+	if len(tables) == 0 {
+		t.Fatal("found no tables in the migrations")
+	}
+
+	secured := make(map[string]bool, len(tenantTables))
+	for _, tenant := range tenantTables {
+		secured[tenant.name] = true
+	}
+
+	for name, table := range tables {
+		// A row hanging off a tenant table belongs to that org too
+		for column, parent := range table.references {
+			if secured[parent] && !secured[name] {
+				t.Errorf("%s.%s references %s, which holds org data, but %s has no policy", name, column, parent, name)
+			}
+		}
+
+		if reason, ok := sharedTables[name]; ok {
+			if secured[name] {
+				t.Errorf("%s is secured but listed as shared (%s)", name, reason)
+			}
+			continue
+		}
+		if !secured[name] {
+			t.Errorf("%s has no row-level security policy; add it to tenantTables, or to sharedTables if every org may see it", name)
+		}
+	}
+
+	for _, tenant := range tenantTables {
+		table, ok := tables[tenant.name]
+		if !ok {
+			t.Errorf("tenantTables lists %s, which the migrations don't create", tenant.name)
+			continue
+		}
+		switch {
+		case tenant.orgColumn:
+			if !table.columns["org_id"] {
+				t.Errorf("%s is filtered on org_id, which it doesn't have", tenant.name)
+			}
+		case tenant.parent != "":
+			if !secured[tenant.parent] {
+				t.Errorf("%s is filtered through %s, which has no policy of its own", tenant.name, tenant.parent)
+			}
+			if !table.columns[tenant.parentKey] {
+				t.Errorf("%s is filtered through %s, which it doesn't have", tenant.name, tenant.parentKey)
+			}
+			if reference, ok := table.references[tenant.parentKey]; ok && reference != tenant.parent {
+				t.Errorf("%s.%s references %s, not %s", tenant.name, tenant.parentKey, reference, tenant.parent)
+			}
+		default:
+			if !table.columns["user_id"] {
+				t.Errorf("%s is filtered on user_id, which it doesn't have", tenant.name)
+			}
+		}
+	}
+}
@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/bolognesandwiches/AdVantage/internal/config"
 	"github.com/bolognesandwiches/AdVantage/internal/db"
 	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
 )
 
 func main() {
+	manifestFlag := flag.String("manifest", "", "YAML or JSON seed manifest (default "+manifestDir+"/<ENV>.yaml, .yml or .json)")
+	sandboxFlag := flag.Bool("sandbox", false, "also provision the sandbox org with generated logs, goals, embeds and a report template")
+	sandboxRows := flag.Int("sandbox-rows", 50000, "rows in each of the sandbox's generated logs")
+	flag.Parse()
+
 	// Setup logger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -25,6 +36,23 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Load credentials from the secrets backend when configured
+	if _, err := secrets.LoadInto(context.Background(), cfg); err != nil {
+		slog.Error("Failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Read the environment's manifest before connecting, so a bad manifest changes nothing
+	path := *manifestFlag
+	if path == "" {
+		path = manifestPath(cfg.Environment)
+	}
+	seed, err := loadManifest(path)
+	if err != nil {
+		slog.Error("Failed to load seed manifest", "error", err)
+		os.Exit(1)
+	}
+
 	// Connect to database
 	database, err := db.NewPostgresDB(cfg.Database)
 	if err != nil {
@@ -33,93 +61,131 @@ func main() {
 	}
 	defer database.Close()
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Create context with timeout; processing sample files takes longer than seeding users. Seeding
+	// writes several orgs' rows, so it runs as system work.
+	ctx, cancel := context.WithTimeout(db.AsSystem(context.Background()), 10*time.Minute)
 	defer cancel()
 
+	files, err := newProcessor(database)
+	if err != nil {
+		slog.Error("Failed to initialize file processing", "error", err)
+		os.Exit(1)
+	}
+
 	// Run seed
-	if err := seedUsers(ctx, database); err != nil {
-		slog.Error("Failed to seed users", "error", err)
+	if err := applyManifest(ctx, seed, files); err != nil {
+		slog.Error("Failed to apply seed manifest", "manifest", path, "error", err)
 		os.Exit(1)
 	}
 
-	slog.Info("Seed completed successfully")
+	if *sandboxFlag {
+		if err := newSandbox(files, *sandboxRows).provision(ctx); err != nil {
+			slog.Error("Failed to provision sandbox", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	slog.Info("Seed completed successfully", "manifest", path)
 }
 
-func seedUsers(ctx context.Context, database *db.PostgresDB) error {
-	// Check if users table is empty
-	var count int
-	err := database.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	// If users already exist, skip seeding
-	if count > 0 {
-		slog.Info("Users already exist, skipping seed")
-		return nil
-	}
-
-	// Create admin user
-	admin := &models.User{
-		ID:        "user_admin",
-		Email:     "admin@advantage.com",
-		FirstName: "Admin",
-		LastName:  "User",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	if err := admin.SetPassword("password123"); err != nil {
-		return err
-	}
-
-	// Create demo user
-	demo := &models.User{
-		ID:        "user_demo",
-		Email:     "demo@advantage.com",
-		FirstName: "Demo",
-		LastName:  "User",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	if err := demo.SetPassword("password123"); err != nil {
-		return err
-	}
-
-	// Insert users
-	query := `
-		INSERT INTO users (id, email, password, first_name, last_name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	// Insert admin
-	_, err = database.Pool.Exec(ctx, query,
-		admin.ID,
-		admin.Email,
-		admin.Password,
-		admin.FirstName,
-		admin.LastName,
-		admin.CreatedAt,
-		admin.UpdatedAt,
-	)
+// applyManifest upserts the manifest's orgs and users, then uploads and processes the files
+// their users don't have yet
+func applyManifest(ctx context.Context, m *manifest, p *processor) error {
+	repos := p.repos
+
+	for _, seed := range m.Orgs {
+		if err := repos.Orgs.Upsert(ctx, &models.Organization{
+			ID:                seed.ID,
+			Name:              seed.Name,
+			JobPriority:       seed.JobPriority,
+			ReportingCurrency: seed.ReportingCurrency,
+			CreatedAt:         time.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to upsert org %s: %w", seed.ID, err)
+		}
+	}
+
+	for _, seed := range m.Users {
+		if err := upsertUser(ctx, repos.Users, seed); err != nil {
+			return err
+		}
+	}
+
+	for _, seed := range m.Files {
+		if err := seedFile(ctx, m, p, seed); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Applied seed manifest", "orgs", len(m.Orgs), "users", len(m.Users), "files", len(m.Files))
+	return nil
+}
+
+// upsertUser creates a user or updates an existing one's profile, and its password when the
+// manifest sets one
+func upsertUser(ctx context.Context, users repository.UserRepository, seed manifestUser) error {
+	user, err := users.FindByID(ctx, seed.ID)
+	created := errors.Is(err, repository.ErrNotFound)
+	switch {
+	case created:
+		if seed.Password == "" {
+			return fmt.Errorf("user %s: password is required for a new user", seed.ID)
+		}
+		user = &models.User{ID: seed.ID, OrgID: seed.Org, CreatedAt: time.Now()}
+	case err != nil:
+		return fmt.Errorf("failed to find user %s: %w", seed.ID, err)
+	}
+
+	user.Email = seed.Email
+	user.FirstName = seed.FirstName
+	user.LastName = seed.LastName
+	user.Company = seed.Company
+	user.Role = seed.Role
+	user.Timezone = seed.Timezone
+	user.Locale = seed.Locale
+	user.UpdatedAt = time.Now()
+	if seed.Password != "" {
+		if err := user.SetPassword(seed.Password); err != nil {
+			return err
+		}
+	}
+
+	if created {
+		err = users.Create(ctx, user)
+	} else {
+		err = users.Update(ctx, user)
+	}
 	if err != nil {
-		return err
-	}
-
-	// Insert demo
-	_, err = database.Pool.Exec(ctx, query,
-		demo.ID,
-		demo.Email,
-		demo.Password,
-		demo.FirstName,
-		demo.LastName,
-		demo.CreatedAt,
-		demo.UpdatedAt,
-	)
+		return fmt.Errorf("failed to upsert user %s: %w", seed.ID, err)
+	}
+	return nil
+}
+
+// seedFile uploads and processes a file unless its user already has one by that name. Files
+// aren't updated in place; delete one to have it seeded again.
+func seedFile(ctx context.Context, m *manifest, p *processor, seed manifestFile) error {
+	existing, err := p.repos.Files.ListByUser(ctx, seed.User)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to list files of %s: %w", seed.User, err)
+	}
+	for _, file := range existing {
+		if file.FileName == seed.Name {
+			return nil
+		}
 	}
 
-	slog.Info("Created seed users", "count", 2)
+	if seed.Generate != nil {
+		_, err = p.processGeneratedLog(ctx, seed.Generate.generatorConfig(), seed.Name, seed.User)
+	} else {
+		var log *os.File
+		if log, err = os.Open(filepath.Join(m.dir, seed.Path)); err != nil {
+			return fmt.Errorf("failed to open file %s: %w", seed.Name, err)
+		}
+		defer log.Close()
+		_, err = p.processLog(ctx, log, seed.Name, seed.User)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to seed file %s: %w", seed.Name, err)
+	}
 	return nil
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestDir holds a seed manifest per environment, named after it, e.g. staging.yaml or
+// staging.json
+const manifestDir = "configs/seed"
+
+// manifestExtensions are the extensions an environment's manifest is looked for with, in order
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// manifest lists the orgs, users and sample files an environment is seeded with. Seeding
+// upserts orgs and users by ID and adds files a user doesn't have by name, so a manifest can be
+// applied again after it changes.
+type manifest struct {
+	Orgs  []manifestOrg  `yaml:"orgs"`
+	Users []manifestUser `yaml:"users"`
+	Files []manifestFile `yaml:"files"`
+
+	// dir is the manifest's directory, which file paths are relative to
+	dir string
+}
+
+// manifestOrg is an organization to upsert
+type manifestOrg struct {
+	ID                string `yaml:"id"`
+	Name              string `yaml:"name"`
+	JobPriority       string `yaml:"jobPriority"`       // Defaults to normal
+	ReportingCurrency string `yaml:"reportingCurrency"` // Defaults to USD
+}
+
+// manifestUser is a user to upsert. A user's org is only set when the user is created; without
+// one, the user gets a personal org with the user's ID.
+type manifestUser struct {
+	ID        string `yaml:"id"`
+	Org       string `yaml:"org"`
+	Email     string `yaml:"email"`
+	Password  string `yaml:"password"` // Required for new users; existing users keep theirs when empty
+	FirstName string `yaml:"firstName"`
+	LastName  string `yaml:"lastName"`
+	Company   string `yaml:"company"`
+	Role      string `yaml:"role"`
+	Timezone  string `yaml:"timezone"`
+	Locale    string `yaml:"locale"`
+}
+
+// manifestFile is a log to upload and process for a user, read from a CSV file or generated
+type manifestFile struct {
+	User     string           `yaml:"user"`
+	Name     string           `yaml:"name"`
+	Path     string           `yaml:"path"` // Relative to the manifest
+	Generate *manifestLogSpec `yaml:"generate"`
+}
+
+// manifestLogSpec configures a generated log; unset fields take the generator's defaults
+type manifestLogSpec struct {
+	Format    string   `yaml:"format"`
+	Rows      int      `yaml:"rows"`
+	Seed      int64    `yaml:"seed"`
+	Days      int      `yaml:"days"`
+	Campaigns int      `yaml:"campaigns"`
+	Domains   int      `yaml:"domains"`
+	Anomalies []string `yaml:"anomalies"`
+}
+
+// manifestPath returns the manifest for an environment, the first of its YAML and JSON
+// manifests that exists, or its YAML manifest when there's none so the error names it
+func manifestPath(environment string) string {
+	for _, extension := range manifestExtensions {
+		path := filepath.Join(manifestDir, environment+extension)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(manifestDir, environment+manifestExtensions[0])
+}
+
+// loadManifest reads a YAML or JSON manifest, expanding ${VAR} references to environment
+// variables first so secrets such as staging passwords stay out of the file
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed manifest: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder reads both
+	m := &manifest{dir: filepath.Dir(path)}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), m); err != nil {
+		return nil, fmt.Errorf("failed to parse seed manifest %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid seed manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// validate fills in defaults and checks the manifest's references
+func (m *manifest) validate() error {
+	for i := range m.Orgs {
+		org := &m.Orgs[i]
+		if org.ID == "" || org.Name == "" {
+			return fmt.Errorf("org %d: id and name are required", i+1)
+		}
+		if org.JobPriority == "" {
+			org.JobPriority = models.JobPriorityNormal
+		}
+		if !models.ValidJobPriority(org.JobPriority) {
+			return fmt.Errorf("org %s: jobPriority must be low, normal or high", org.ID)
+		}
+		if org.ReportingCurrency == "" {
+			org.ReportingCurrency = "USD"
+		}
+	}
+
+	users := make(map[string]bool, len(m.Users))
+	for i := range m.Users {
+		user := &m.Users[i]
+		if user.ID == "" || user.Email == "" {
+			return fmt.Errorf("user %d: id and email are required", i+1)
+		}
+		if user.Org == "" {
+			user.Org = user.ID
+		}
+		users[user.ID] = true
+	}
+
+	for i, file := range m.Files {
+		if !users[file.User] {
+			return fmt.Errorf("file %d: user %q isn't in the manifest", i+1, file.User)
+		}
+		if file.Name == "" {
+			return fmt.Errorf("file %d: name is required", i+1)
+		}
+		if (file.Path == "") == (file.Generate == nil) {
+			return fmt.Errorf("file %s: set one of path or generate", file.Name)
+		}
+		if file.Generate != nil {
+			// Check the generator options before anything is written
+			if _, err := loggen.New(file.Generate.generatorConfig()); err != nil {
+				return fmt.Errorf("file %s: %w", file.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// generatorConfig returns the generator config for a generated file
+func (s *manifestLogSpec) generatorConfig() loggen.Config {
+	cfg := loggen.DefaultConfig()
+	if s.Format != "" {
+		cfg.Format = s.Format
+	}
+	if s.Rows > 0 {
+		cfg.Rows = s.Rows
+	}
+	if s.Seed != 0 {
+		cfg.Seed = s.Seed
+	}
+	if s.Days > 0 {
+		cfg.Days = s.Days
+		cfg.Start = time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -s.Days)
+	}
+	if s.Campaigns > 0 {
+		cfg.Campaigns = s.Campaigns
+	}
+	if s.Domains > 0 {
+		cfg.Domains = s.Domains
+	}
+	cfg.Anomalies = s.Anomalies
+	return cfg
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+// processor stores and processes seeded logs
+type processor struct {
+	repos        repository.Repositories
+	fileStorage  *storage.FileStorage
+	logProcessor *ingestion.LogProcessorService
+	rollups      *services.RollupService
+}
+
+// newProcessor wires file processing the way the server does, so seeded files' analyses and
+// rollups are what an upload would produce
+func newProcessor(database *db.PostgresDB) (*processor, error) {
+	fileStorage, err := storage.NewFileStorage("uploads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize file storage: %w", err)
+	}
+
+	repos := repository.NewPostgresRepositories(database.Pool)
+	unitOfWork := repository.NewPostgresUnitOfWork(database.Pool)
+
+	logProcessor := ingestion.NewLogProcessorService("uploads")
+	logProcessor.SetCategoryOverrides(repos.Categories)
+	logProcessor.SetSchemaTracking(repos.Mappings, repos.Schemas)
+	logProcessor.SetParserRunSink(repos.ParserRuns)
+	rollupService := services.NewRollupService(repos, unitOfWork)
+	logProcessor.SetRollupSink(rollupService)
+
+	return &processor{
+		repos:        repos,
+		fileStorage:  fileStorage,
+		logProcessor: logProcessor,
+		rollups:      rollupService,
+	}, nil
+}
+
+// processLog stores a log for a user, records the file and processes it, returning the file's ID
+func (p *processor) processLog(ctx context.Context, log io.Reader, fileName, userID string) (string, error) {
+	fileInfo, err := p.fileStorage.StoreFile(log, fileName, "text/csv", userID, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to store log: %w", err)
+	}
+
+	now := time.Now()
+	if err := p.repos.Files.Create(ctx, &models.File{
+		ID:         fileInfo.ID,
+		UserID:     userID,
+		FileName:   fileInfo.FileName,
+		FileSize:   fileInfo.FileSize,
+		FileType:   fileInfo.FileType,
+		FilePath:   fileInfo.FilePath,
+		Status:     models.FileStatusProcessing,
+		UploadedAt: fileInfo.UploadedAt,
+		UpdatedAt:  now,
+	}); err != nil {
+		_ = p.fileStorage.DeleteFile(fileInfo.ID, userID)
+		return "", fmt.Errorf("failed to save file metadata: %w", err)
+	}
+
+	if _, err := p.logProcessor.ProcessLogFile(ctx, fileInfo.FilePath, fileInfo.ID, fileInfo.FileName, userID, ingestion.ParseOptions{}); err != nil {
+		_ = p.repos.Files.UpdateStatus(ctx, fileInfo.ID, userID, models.FileStatusFailed)
+		return "", fmt.Errorf("failed to process log: %w", err)
+	}
+	if err := p.repos.Files.UpdateStatus(ctx, fileInfo.ID, userID, models.FileStatusProcessed); err != nil {
+		return "", fmt.Errorf("failed to update file status: %w", err)
+	}
+
+	slog.Info("Processed seed log", "file", fileName, "user", userID)
+	return fileInfo.ID, nil
+}
+
+// processGeneratedLog generates a log straight into file storage and processes it, returning
+// the file's ID
+func (p *processor) processGeneratedLog(ctx context.Context, cfg loggen.Config, fileName, userID string) (string, error) {
+	generator, err := loggen.New(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		_, err := generator.Write(writer)
+		writer.CloseWithError(err)
+	}()
+
+	return p.processLog(ctx, reader, fileName, userID)
+}
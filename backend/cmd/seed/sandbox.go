@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+)
+
+// sandboxUserID is the user, in an org of its own, that sales demos and new users explore
+// the product as
+const sandboxUserID = "user_sandbox"
+
+// sandboxDays is how many days of traffic the sandbox's logs cover, up to yesterday
+const sandboxDays = 14
+
+// sandboxLog is one of the generated logs the sandbox is provisioned with
+type sandboxLog struct {
+	fileName  string
+	format    string
+	seed      int64
+	campaigns int
+	anomalies []string
+}
+
+// sandboxLogs give the sandbox a log from each supported DSP, with the anomalies in the largest
+// so alerts and incidents have something to show
+var sandboxLogs = []sandboxLog{
+	{fileName: "beeswax_prospecting.csv", format: "beeswax", seed: 101, campaigns: 12, anomalies: loggen.Anomalies},
+	{fileName: "ttd_retargeting.csv", format: "ttd", seed: 102, campaigns: 6},
+	{fileName: "dv360_awareness.csv", format: "dv360", seed: 103, campaigns: 4},
+}
+
+// sandbox provisions the sandbox org: a user, processed logs, campaign goals, embedded charts
+// and a report template
+type sandbox struct {
+	*processor
+	embeds    *services.EmbedService
+	templates *services.ReportTemplateService
+	rows      int
+}
+
+// newSandbox creates a sandbox provisioner that processes logs with p
+func newSandbox(p *processor, rows int) *sandbox {
+	return &sandbox{
+		processor: p,
+		embeds:    services.NewEmbedService(p.repos, p.logProcessor),
+		templates: services.NewReportTemplateService(p.repos, p.rollups, services.NewCustomMetricService(p.repos), p.logProcessor),
+		rows:      rows,
+	}
+}
+
+// provision creates the sandbox, skipping it when the sandbox user already has files
+func (s *sandbox) provision(ctx context.Context) error {
+	_, err := s.repos.Users.FindByID(ctx, sandboxUserID)
+	switch {
+	case err == nil:
+		files, err := s.repos.Files.ListByUser(ctx, sandboxUserID)
+		if err != nil {
+			return fmt.Errorf("failed to list sandbox files: %w", err)
+		}
+		if len(files) > 0 {
+			slog.Info("Sandbox already provisioned, skipping", "files", len(files))
+			return nil
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		if err := s.createUser(ctx); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("failed to find sandbox user: %w", err)
+	}
+
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -sandboxDays)
+	var fileIDs []string
+	for _, spec := range sandboxLogs {
+		fileID, err := s.generateLog(ctx, spec, start)
+		if err != nil {
+			return fmt.Errorf("failed to provision %s: %w", spec.fileName, err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	if err := s.createGoals(ctx); err != nil {
+		return err
+	}
+	if err := s.createEmbeds(ctx, fileIDs[0]); err != nil {
+		return err
+	}
+	if err := s.createTemplate(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("Provisioned sandbox", "user", sandboxUserID, "files", len(fileIDs))
+	return nil
+}
+
+// createUser creates the sandbox user in an org of its own
+func (s *sandbox) createUser(ctx context.Context) error {
+	user := &models.User{
+		ID:        sandboxUserID,
+		OrgID:     sandboxUserID,
+		Email:     "sandbox@advantage.com",
+		FirstName: "Sandbox",
+		LastName:  "Demo",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.SetPassword("password123"); err != nil {
+		return err
+	}
+	if err := s.repos.Users.Create(ctx, user); err != nil {
+		return fmt.Errorf("failed to create sandbox user: %w", err)
+	}
+	return nil
+}
+
+// generateLog generates one of the sandbox's logs and processes it, returning the file's ID
+func (s *sandbox) generateLog(ctx context.Context, spec sandboxLog, start time.Time) (string, error) {
+	cfg := loggen.DefaultConfig()
+	cfg.Format = spec.format
+	cfg.Rows = s.rows
+	cfg.Seed = spec.seed
+	cfg.Start = start
+	cfg.Days = sandboxDays
+	cfg.Campaigns = spec.campaigns
+	cfg.Anomalies = spec.anomalies
+	return s.processGeneratedLog(ctx, cfg, spec.fileName, sandboxUserID)
+}
+
+// createGoals sets goals on the prospecting log's busiest campaigns, one of them out of reach
+// so the goal views show both on- and off-target campaigns
+func (s *sandbox) createGoals(ctx context.Context) error {
+	goals := []ingestion.CampaignGoal{
+		{CampaignID: "20000", TargetCPA: ptr(40.0), TargetCTR: ptr(0.3), DailySpend: ptr(1.0)},
+		{CampaignID: "20001", TargetROAS: ptr(2.0), Budget: ptr(10.0)},
+		{CampaignID: "20002", TargetCPA: ptr(5.0), TargetCTR: ptr(1.5)},
+	}
+	for i := range goals {
+		goals[i].Currency = "USD"
+		goals[i].UpdatedAt = time.Now()
+		if err := s.repos.Goals.SetGoal(ctx, sandboxUserID, &goals[i]); err != nil {
+			return fmt.Errorf("failed to set sandbox goal: %w", err)
+		}
+	}
+	return nil
+}
+
+// createEmbeds adds a chart of each widget for a file, for the dashboard
+func (s *sandbox) createEmbeds(ctx context.Context, fileID string) error {
+	embeds := []models.Embed{
+		{Name: "Daily spend", Widget: models.EmbedWidgetDaily, Metric: ingestion.MetricSpend},
+		{Name: "Top campaigns", Widget: models.EmbedWidgetCampaigns, Metric: ingestion.MetricSpend},
+		{Name: "Top domains", Widget: models.EmbedWidgetDomains, Metric: ingestion.MetricImpressions},
+		{Name: "Devices", Widget: models.EmbedWidgetDevices, Metric: ingestion.MetricClicks},
+		{Name: "Countries", Widget: models.EmbedWidgetGeo, Metric: ingestion.MetricImpressions},
+	}
+	for i := range embeds {
+		embeds[i].UserID = sandboxUserID
+		embeds[i].FileID = fileID
+		if err := s.embeds.CreateEmbed(ctx, &embeds[i]); err != nil {
+			return fmt.Errorf("failed to create sandbox embed: %w", err)
+		}
+	}
+	return nil
+}
+
+// createTemplate adds a weekly performance report template to the sandbox org
+func (s *sandbox) createTemplate(ctx context.Context) error {
+	template := &models.ReportTemplate{
+		OrgID:       sandboxUserID,
+		Name:        "Weekly performance",
+		Description: "Delivery, trend and top campaigns and domains",
+		CreatedBy:   sandboxUserID,
+		Sections: []models.ReportSection{
+			{Type: models.ReportSectionSummary, Title: "Overview"},
+			{Type: models.ReportSectionTrend, Title: "Daily trend", Metrics: []string{ingestion.MetricImpressions, ingestion.MetricSpend, "ctr"}},
+			{Type: models.ReportSectionBreakdown, Title: "Campaigns", Dimension: ingestion.RollupByCampaign},
+			{Type: models.ReportSectionBreakdown, Title: "Domains", Dimension: ingestion.RollupByDomain, Limit: 15},
+		},
+	}
+	if err := s.templates.CreateTemplate(ctx, template); err != nil {
+		return fmt.Errorf("failed to create sandbox report template: %w", err)
+	}
+	return nil
+}
+
+// ptr returns a pointer to a copy of v
+func ptr[T any](v T) *T {
+	return &v
+}
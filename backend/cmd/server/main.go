@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,12 +13,17 @@ import (
 	"github.com/bolognesandwiches/AdVantage/internal/api"
 	"github.com/bolognesandwiches/AdVantage/internal/config"
 	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/diagnostics"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
+	"github.com/bolognesandwiches/AdVantage/internal/settings"
 )
 
 func main() {
-	// Setup logger
+	// Setup logger; the level can be changed at runtime
+	logLevel := new(slog.LevelVar)
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: logLevel,
 	}))
 	slog.SetDefault(logger)
 
@@ -27,8 +34,45 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Connect to database
-	database, err := db.NewPostgresDB(cfg.Database)
+	// Report errors to the error tracking service when configured
+	reporter, err := errreport.New(cfg.ErrorReporting, cfg.Environment)
+	if err != nil {
+		slog.Error("Failed to initialize error reporting", "error", err)
+		os.Exit(1)
+	}
+	errreport.SetDefault(reporter)
+
+	// Load runtime settings and keep the log level in sync with them
+	runtimeSettings, err := settings.Load(cfg.Admin.SettingsFile)
+	if err != nil {
+		slog.Error("Failed to load runtime settings", "error", err)
+		os.Exit(1)
+	}
+	settingsStore := settings.NewStore(runtimeSettings, cfg.Admin.SettingsFile)
+	settingsStore.OnChange(func(current settings.Settings) {
+		if err := logLevel.UnmarshalText([]byte(current.LogLevel)); err != nil {
+			slog.Error("Invalid log level", "level", current.LogLevel, "error", err)
+		}
+	})
+
+	// Load credentials from the secrets backend when configured
+	secretStore, err := secrets.LoadInto(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Refresh secrets in the background so rotated credentials are picked up
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	if secretStore != nil {
+		go secretStore.Run(refreshCtx, time.Duration(cfg.Secrets.RefreshSeconds)*time.Second)
+	}
+
+	// Connect to database, using the latest credentials for new connections
+	database, err := db.NewPostgresDB(cfg.Database, db.WithCredentials(func() (string, string) {
+		return secretStore.Get(secrets.KeyDBUser, cfg.Database.User), secretStore.Get(secrets.KeyDBPassword, cfg.Database.Password)
+	}))
 	if err != nil {
 		slog.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
@@ -36,11 +80,11 @@ func main() {
 	defer database.Close()
 
 	// Initialize server
-	server := api.NewServer(cfg, database)
+	server := api.NewServer(cfg, database, secretStore, settingsStore)
 
 	// Start server in a goroutine
 	go func() {
-		if err := server.Start(); err != nil {
+		if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("Failed to start server", "error", err)
 			os.Exit(1)
 		}
@@ -48,6 +92,31 @@ func main() {
 
 	slog.Info("Server started successfully", "port", cfg.Port)
 
+	// Serve pprof and expvar on the internal diagnostics listener when configured
+	diagnosticsServer := diagnostics.NewServer(cfg.Diagnostics)
+	if diagnosticsServer != nil {
+		go func() {
+			if err := diagnosticsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Diagnostics server failed", "error", err)
+			}
+		}()
+		slog.Info("Diagnostics server started", "addr", diagnosticsServer.Addr)
+	}
+
+	// Reload runtime settings on SIGHUP without interrupting running jobs
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloaded, err := settingsStore.Reload()
+			if err != nil {
+				slog.Error("Failed to reload runtime settings", "error", err)
+				continue
+			}
+			slog.Info("Reloaded runtime settings", "settings", reloaded)
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -55,13 +124,19 @@ func main() {
 
 	slog.Info("Shutting down server...")
 
-	// Create a deadline to wait for current operations to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Create a deadline to wait for current operations to complete; flushing usage writes every org's rows
+	ctx, cancel := context.WithTimeout(db.AsSystem(context.Background()), time.Duration(cfg.ShutdownTimeout)*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	}
+	if diagnosticsServer != nil {
+		_ = diagnosticsServer.Close()
+	}
+	if err := errreport.Flush(ctx); err != nil {
+		slog.Error("Failed to flush error reports", "error", err)
+	}
 
 	slog.Info("Server exited properly")
 }
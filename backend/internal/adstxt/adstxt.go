@@ -0,0 +1,139 @@
+package adstxt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Relationships an ads.txt entry declares
+const (
+	RelationshipDirect   = "DIRECT"
+	RelationshipReseller = "RESELLER"
+)
+
+// sellers.json seller types
+const (
+	SellerTypePublisher    = "PUBLISHER"
+	SellerTypeIntermediary = "INTERMEDIARY"
+	SellerTypeBoth         = "BOTH"
+)
+
+// Entry is a data record from an ads.txt file: an advertising system authorized to sell the
+// domain's inventory through a seller account
+type Entry struct {
+	AdSystem     string `json:"adSystem"`
+	SellerID     string `json:"sellerId"`
+	Relationship string `json:"relationship"`
+	CertID       string `json:"certId,omitempty"`
+}
+
+// Seller is a seller account listed in an advertising system's sellers.json
+type Seller struct {
+	SellerID       string `json:"seller_id"`
+	Name           string `json:"name,omitempty"`
+	Domain         string `json:"domain,omitempty"`
+	SellerType     string `json:"seller_type"`
+	IsConfidential int    `json:"is_confidential,omitempty"`
+}
+
+// ParseAdsTxt parses the data records of an ads.txt file. Comments, variables such as
+// contact= and OWNERDOMAIN=, and malformed lines are skipped, as the IAB spec asks crawlers to.
+func ParseAdsTxt(reader io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		entry := Entry{
+			AdSystem:     strings.ToLower(fields[0]),
+			SellerID:     fields[1],
+			Relationship: strings.ToUpper(fields[2]),
+		}
+		if entry.AdSystem == "" || entry.SellerID == "" {
+			continue
+		}
+		if entry.Relationship != RelationshipDirect && entry.Relationship != RelationshipReseller {
+			continue
+		}
+		if len(fields) > 3 {
+			entry.CertID = fields[3]
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ads.txt: %w", err)
+	}
+
+	return entries, nil
+}
+
+// FindSellers streams a sellers.json file, returning the listed sellers among the wanted IDs.
+// Large exchanges list hundreds of thousands of sellers, so only the wanted ones are kept.
+func FindSellers(reader io.Reader, wanted map[string]bool) (map[string]Seller, error) {
+	decoder := json.NewDecoder(reader)
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("invalid sellers.json: expected an object")
+	}
+
+	found := make(map[string]Seller)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid sellers.json: %w", err)
+		}
+
+		if key, _ := token.(string); key != "sellers" {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, fmt.Errorf("invalid sellers.json: %w", err)
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, fmt.Errorf("invalid sellers.json: sellers is not an array")
+		}
+		for decoder.More() {
+			var seller struct {
+				Seller
+				// Some exchanges publish numeric seller IDs
+				RawID json.RawMessage `json:"seller_id"`
+			}
+			if err := decoder.Decode(&seller); err != nil {
+				return nil, fmt.Errorf("invalid sellers.json seller: %w", err)
+			}
+			id := strings.Trim(string(seller.RawID), `"`)
+			if wanted[id] {
+				seller.Seller.SellerID = id
+				seller.Seller.SellerType = strings.ToUpper(seller.Seller.SellerType)
+				found[id] = seller.Seller
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid sellers.json: %w", err)
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,427 @@
+package adstxt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/cache"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+// Authorization statuses of a supply path
+const (
+	StatusAuthorized   = "authorized"
+	StatusUnauthorized = "unauthorized"
+	StatusNoAdsTxt     = "no_ads_txt"
+	StatusUnverified   = "unverified"
+)
+
+// Crawl states cached for a domain's ads.txt or an ad system's sellers.json
+const (
+	crawlFound       = "found"
+	crawlMissing     = "missing"
+	crawlUnreachable = "unreachable"
+)
+
+const (
+	// adsTxtTimeout bounds fetching one ads.txt; sellers.json files are far larger and get sellersTimeout
+	adsTxtTimeout  = 10 * time.Second
+	sellersTimeout = 2 * time.Minute
+	maxAdsTxtSize  = 4 << 20
+	maxSellersSize = 512 << 20
+	// unreachableTTL is how long a failed crawl is cached before it's retried
+	unreachableTTL = time.Hour
+	// crawlConcurrency is how many domains are crawled at once
+	crawlConcurrency = 8
+)
+
+// adSystemDomains maps the exchange names DSP logs use, lowercased with spaces, dashes and
+// underscores removed, to the ad system domain ads.txt files list the exchange under
+var adSystemDomains = map[string]string{
+	"google":          "google.com",
+	"adx":             "google.com",
+	"googleadx":       "google.com",
+	"googleadmanager": "google.com",
+	"doubleclick":     "google.com",
+	"appnexus":        "appnexus.com",
+	"xandr":           "appnexus.com",
+	"microsoftcurate": "appnexus.com",
+	"rubicon":         "rubiconproject.com",
+	"rubiconproject":  "rubiconproject.com",
+	"magnite":         "rubiconproject.com",
+	"spotx":           "spotxchange.com",
+	"spotxchange":     "spotxchange.com",
+	"openx":           "openx.com",
+	"pubmatic":        "pubmatic.com",
+	"index":           "indexexchange.com",
+	"indexexchange":   "indexexchange.com",
+	"ix":              "indexexchange.com",
+	"triplelift":      "triplelift.com",
+	"sovrn":           "sovrn.com",
+	"sharethrough":    "sharethrough.com",
+	"yieldmo":         "yieldmo.com",
+	"smaato":          "smaato.com",
+	"inmobi":          "inmobi.com",
+	"medianet":        "media.net",
+	"freewheel":       "freewheel.tv",
+	"gumgum":          "gumgum.com",
+	"33across":        "33across.com",
+	"yahoo":           "yahoo.com",
+	"verizonmedia":    "yahoo.com",
+	"unruly":          "video.unruly.co",
+}
+
+// sellersJSONURLs are sellers.json files that aren't served from the ad system domain's root
+var sellersJSONURLs = map[string]string{
+	"google.com": "https://storage.googleapis.com/adx-rtb-dictionaries/sellers.json",
+}
+
+// hostnamePattern matches crawlable hostnames; app bundle and store IDs don't match
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]([a-z0-9-]*[a-z0-9])?$`)
+
+// errPrivateAddress is returned when a domain resolves to an address that isn't on the public internet
+var errPrivateAddress = errors.New("refusing to crawl a non-public address")
+
+// Check is the verdict on one supply path of a domain
+type Check struct {
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	AdSystem   string `json:"adSystem,omitempty"`
+	SellerName string `json:"sellerName,omitempty"`
+	SellerType string `json:"sellerType,omitempty"`
+}
+
+// adsTxtFile is the cached crawl of a domain's ads.txt
+type adsTxtFile struct {
+	State   string  `json:"state"`
+	Entries []Entry `json:"entries,omitempty"`
+}
+
+// sellerLookup is the cached result of looking a seller ID up in an ad system's sellers.json
+type sellerLookup struct {
+	State  string `json:"state"`
+	Seller Seller `json:"seller"`
+}
+
+// Validator crawls ads.txt and sellers.json files and checks supply paths against them.
+// Crawls are cached, misses and failures included, so a report doesn't refetch every file.
+type Validator struct {
+	client *http.Client
+	cache  cache.Cache
+	ttl    time.Duration
+	group  singleflight.Group
+}
+
+// NewValidator creates a validator caching crawls for ttl. Without a shared cache, crawls are
+// cached in process memory.
+func NewValidator(c cache.Cache, ttl time.Duration) *Validator {
+	if c == nil {
+		c = cache.NewMemoryCache()
+	}
+
+	// Domains come from uploaded logs, so they're only crawled when they resolve to public addresses
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Validator{
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+		cache: c,
+		ttl:   ttl,
+	}
+}
+
+// AdSystemDomain returns the ad system domain ads.txt files list an exchange under, or an
+// empty string when the exchange isn't known. Exchanges logged as domains are used as is.
+func AdSystemDomain(exchange string) string {
+	name := strings.ToLower(strings.TrimSpace(exchange))
+	if strings.Contains(name, ".") && hostnamePattern.MatchString(name) {
+		return name
+	}
+	name = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name)
+	return adSystemDomains[name]
+}
+
+// Validate checks each domain's supply paths against the domain's ads.txt and the exchanges'
+// sellers.json files, returning a verdict for every path in the order given
+func (v *Validator) Validate(ctx context.Context, domains map[string][]ingestion.SellerPath) map[string][]Check {
+	// Crawl each domain's ads.txt
+	files := make(map[string]adsTxtFile, len(domains))
+	var mu sync.Mutex
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(crawlConcurrency)
+	for domain := range domains {
+		group.Go(func() error {
+			file := v.adsTxt(groupCtx, domain)
+			mu.Lock()
+			files[domain] = file
+			mu.Unlock()
+			return nil
+		})
+	}
+	group.Wait()
+
+	// Look up every seller in its exchange's sellers.json
+	wanted := make(map[string]map[string]bool)
+	for _, paths := range domains {
+		for _, path := range paths {
+			adSystem := AdSystemDomain(path.Exchange)
+			if adSystem == "" {
+				continue
+			}
+			if wanted[adSystem] == nil {
+				wanted[adSystem] = make(map[string]bool)
+			}
+			wanted[adSystem][path.SellerID] = true
+		}
+	}
+	sellers := make(map[string]map[string]sellerLookup, len(wanted))
+	for adSystem, ids := range wanted {
+		sellers[adSystem] = v.sellers(ctx, adSystem, ids)
+	}
+
+	checks := make(map[string][]Check, len(domains))
+	for domain, paths := range domains {
+		file := files[domain]
+		for _, path := range paths {
+			adSystem := AdSystemDomain(path.Exchange)
+			checks[domain] = append(checks[domain], check(file, sellers[adSystem], adSystem, path))
+		}
+	}
+
+	return checks
+}
+
+// check decides whether a path is authorized by the domain's ads.txt and the exchange's sellers.json
+func check(file adsTxtFile, sellers map[string]sellerLookup, adSystem string, path ingestion.SellerPath) Check {
+	result := Check{Status: StatusAuthorized, AdSystem: adSystem}
+
+	switch {
+	case adSystem == "":
+		return Check{Status: StatusUnverified, Reason: "the exchange's ad system domain isn't known"}
+	case file.State == crawlMissing:
+		result = Check{Status: StatusNoAdsTxt, Reason: "the domain has no ads.txt", AdSystem: adSystem}
+	case file.State != crawlFound:
+		return Check{Status: StatusUnverified, Reason: "ads.txt couldn't be fetched", AdSystem: adSystem}
+	default:
+		listed, direct := false, false
+		for _, entry := range file.Entries {
+			if entry.AdSystem == adSystem && strings.EqualFold(entry.SellerID, path.SellerID) {
+				listed = true
+				direct = direct || entry.Relationship == RelationshipDirect
+			}
+		}
+		switch {
+		case !listed:
+			result.Status, result.Reason = StatusUnauthorized, "the seller account isn't listed in ads.txt"
+		case path.Relationship == ingestion.SellerRelationshipDirect && !direct:
+			result.Status, result.Reason = StatusUnauthorized, "sold as direct, but ads.txt lists the seller as a reseller"
+		}
+	}
+
+	lookup, ok := sellers[path.SellerID]
+	if !ok || lookup.State == crawlUnreachable {
+		return result
+	}
+	if lookup.State == crawlMissing {
+		if result.Status == StatusAuthorized {
+			result.Status, result.Reason = StatusUnauthorized, "the seller account isn't in the exchange's sellers.json"
+		}
+		return result
+	}
+
+	result.SellerName, result.SellerType = lookup.Seller.Name, lookup.Seller.SellerType
+	if result.Status == StatusAuthorized && path.Relationship == ingestion.SellerRelationshipDirect && lookup.Seller.SellerType == SellerTypeIntermediary {
+		result.Status, result.Reason = StatusUnauthorized, "sold as direct, but sellers.json lists the seller as an intermediary"
+	}
+	return result
+}
+
+// adsTxt returns a domain's ads.txt, crawling it when it isn't cached
+func (v *Validator) adsTxt(ctx context.Context, domain string) adsTxtFile {
+	host := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+	if !hostnamePattern.MatchString(host) {
+		return adsTxtFile{State: crawlUnreachable}
+	}
+
+	key := "adstxt:domain:" + host
+	var file adsTxtFile
+	if found, err := v.cache.Get(ctx, key, &file); err != nil {
+		slog.Error("Failed to read ads.txt cache", "domain", host, "error", err)
+	} else if found {
+		return file
+	}
+
+	result, _, _ := v.group.Do(key, func() (interface{}, error) {
+		file := v.crawlAdsTxt(ctx, host)
+		ttl := v.ttl
+		if file.State == crawlUnreachable {
+			ttl = min(ttl, unreachableTTL)
+		}
+		if err := v.cache.Set(ctx, key, file, ttl); err != nil {
+			slog.Error("Failed to write ads.txt cache", "domain", host, "error", err)
+		}
+		return file, nil
+	})
+	return result.(adsTxtFile)
+}
+
+// crawlAdsTxt fetches a domain's ads.txt over HTTPS, falling back to HTTP
+func (v *Validator) crawlAdsTxt(ctx context.Context, host string) adsTxtFile {
+	ctx, cancel := context.WithTimeout(ctx, adsTxtTimeout)
+	defer cancel()
+
+	var lastErr error
+	for _, scheme := range []string{"https", "http"} {
+		file, err := v.fetchAdsTxt(ctx, scheme+"://"+host+"/ads.txt")
+		if err == nil {
+			return file
+		}
+		lastErr = err
+	}
+
+	slog.Warn("Failed to crawl ads.txt", "domain", host, "error", lastErr)
+	return adsTxtFile{State: crawlUnreachable}
+}
+
+// fetchAdsTxt fetches and parses one ads.txt URL
+func (v *Validator) fetchAdsTxt(ctx context.Context, url string) (adsTxtFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return adsTxtFile{}, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return adsTxtFile{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return adsTxtFile{State: crawlMissing}, nil
+	case resp.StatusCode != http.StatusOK:
+		return adsTxtFile{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Sites without ads.txt often answer with their HTML 404 page and a 200
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return adsTxtFile{State: crawlMissing}, nil
+	}
+
+	entries, err := ParseAdsTxt(io.LimitReader(resp.Body, maxAdsTxtSize))
+	if err != nil {
+		return adsTxtFile{}, err
+	}
+	return adsTxtFile{State: crawlFound, Entries: entries}, nil
+}
+
+// sellers looks seller IDs up in an ad system's sellers.json, fetching the file when any of
+// them isn't cached
+func (v *Validator) sellers(ctx context.Context, adSystem string, ids map[string]bool) map[string]sellerLookup {
+	lookups := make(map[string]sellerLookup, len(ids))
+	missing := make(map[string]bool)
+	for id := range ids {
+		var lookup sellerLookup
+		found, err := v.cache.Get(ctx, sellerKey(adSystem, id), &lookup)
+		if err != nil {
+			slog.Error("Failed to read sellers.json cache", "adSystem", adSystem, "error", err)
+		}
+		if found {
+			lookups[id] = lookup
+		} else {
+			missing[id] = true
+		}
+	}
+	if len(missing) == 0 {
+		return lookups
+	}
+
+	listed, err := v.fetchSellers(ctx, adSystem, missing)
+	if err != nil {
+		slog.Warn("Failed to crawl sellers.json", "adSystem", adSystem, "error", err)
+	}
+	for id := range missing {
+		lookup, ttl := sellerLookup{State: crawlMissing}, v.ttl
+		if seller, ok := listed[id]; ok {
+			lookup = sellerLookup{State: crawlFound, Seller: seller}
+		} else if err != nil {
+			lookup, ttl = sellerLookup{State: crawlUnreachable}, min(ttl, unreachableTTL)
+		}
+		lookups[id] = lookup
+		if err := v.cache.Set(ctx, sellerKey(adSystem, id), lookup, ttl); err != nil {
+			slog.Error("Failed to write sellers.json cache", "adSystem", adSystem, "error", err)
+		}
+	}
+
+	return lookups
+}
+
+// fetchSellers streams an ad system's sellers.json, keeping the wanted sellers
+func (v *Validator) fetchSellers(ctx context.Context, adSystem string, wanted map[string]bool) (map[string]Seller, error) {
+	ctx, cancel := context.WithTimeout(ctx, sellersTimeout)
+	defer cancel()
+
+	url, ok := sellersJSONURLs[adSystem]
+	if !ok {
+		url = "https://" + adSystem + "/sellers.json"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return FindSellers(io.LimitReader(resp.Body, maxSellersSize), wanted)
+}
+
+// sellerKey is the cache key of a seller ID's sellers.json lookup
+func sellerKey(adSystem, sellerID string) string {
+	return "adstxt:seller:" + adSystem + ":" + sellerID
+}
+
+// isPublicIP reports whether an address is routable on the public internet
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateSettingsRequest changes some runtime settings; omitted fields keep their current values
+type UpdateSettingsRequest struct {
+	LogLevel           *string `json:"logLevel"`
+	RateLimitPerMinute *int    `json:"rateLimitPerMinute"`
+	WorkerConcurrency  *int    `json:"workerConcurrency"`
+	MaxUploadSizeMB    *int    `json:"maxUploadSizeMB"`
+}
+
+// SetOrgPriorityRequest sets the priority of an org's uploads that don't ask for one
+type SetOrgPriorityRequest struct {
+	Priority string `json:"priority" binding:"required"`
+}
+
+// SetOrgCurrencyRequest sets the currency an org's campaign spend is reported in
+type SetOrgCurrencyRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// SetOrgPlanRequest moves an org to a plan, optionally setting when its trial ends
+type SetOrgPlanRequest struct {
+	Plan        string     `json:"plan" binding:"required"`
+	TrialEndsAt *time.Time `json:"trialEndsAt"`
+}
+
+// AdminMiddleware checks the X-Admin-Token header against the configured admin token.
+// Admin routes are unavailable when no token is configured.
+func (s *Server) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := s.config.Admin.Token
+		if expected == "" {
+			respondErrorf(c, http.StatusNotFound, "Not found")
+			return
+		}
+
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			respondErrorf(c, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+
+		// Admins work across orgs, so their queries aren't restricted to one
+		c.Request = c.Request.WithContext(db.AsSystem(c.Request.Context()))
+
+		c.Next()
+	}
+}
+
+// HandleGetSettings returns the current runtime settings
+func (s *Server) HandleGetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, s.settings.Get())
+}
+
+// HandleUpdateSettings applies a partial update to the runtime settings
+func (s *Server) HandleUpdateSettings(c *gin.Context) {
+	var req UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Merge the request into the current settings
+	updated := s.settings.Get()
+	if req.LogLevel != nil {
+		updated.LogLevel = *req.LogLevel
+	}
+	if req.RateLimitPerMinute != nil {
+		updated.RateLimitPerMinute = *req.RateLimitPerMinute
+	}
+	if req.WorkerConcurrency != nil {
+		updated.WorkerConcurrency = *req.WorkerConcurrency
+	}
+	if req.MaxUploadSizeMB != nil {
+		updated.MaxUploadSizeMB = *req.MaxUploadSizeMB
+	}
+
+	// Apply the settings
+	if err := s.settings.Update(updated); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.settings.Get())
+}
+
+// HandleReloadSettings re-reads the settings file, as on SIGHUP
+func (s *Server) HandleReloadSettings(c *gin.Context) {
+	reloaded, err := s.settings.Reload()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to reload settings: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reloaded)
+}
+
+// HandleSetOrgPriority sets the default processing priority of an org's uploads
+func (s *Server) HandleSetOrgPriority(c *gin.Context) {
+	var req SetOrgPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	org, err := s.orgService.SetJobPriority(c, c.Param("id"), req.Priority)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPriority):
+			respondErrorf(c, http.StatusBadRequest, "priority must be low, normal or high")
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to set priority: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// HandleSetOrgCurrency sets the currency an org's campaign spend is reported in
+func (s *Server) HandleSetOrgCurrency(c *gin.Context) {
+	var req SetOrgCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	org, err := s.orgService.SetReportingCurrency(c, c.Param("id"), req.Currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCurrency):
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to set reporting currency: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// HandleSetOrgPlan moves an org to a plan, which decides what it's entitled to
+func (s *Server) HandleSetOrgPlan(c *gin.Context) {
+	var req SetOrgPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	org, err := s.entitlementService.SetPlan(c, c.Param("id"), req.Plan, req.TrialEndsAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPlan):
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to set plan: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetFunnel handles retrieving the bid → impression → click → conversion funnel for a file
+func (s *Server) HandleGetFunnel(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Validate the optional segment dimension
+	segmentBy := c.Query("segmentBy")
+	if segmentBy != "" && !ingestion.IsFunnelSegment(segmentBy) {
+		respondErrorf(c, http.StatusBadRequest, "segmentBy must be one of device, geo, creative")
+		return
+	}
+
+	// Build the funnel using the analytics service
+	report, err := s.analyticsService.GetFunnel(c, fileID, userID.(string), segmentBy)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get funnel: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetSupplyPath handles retrieving the supply path report for a file
+func (s *Server) HandleGetSupplyPath(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetSupplyPath(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get supply path report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetBidEfficiency handles retrieving the bid efficiency report for an OpenRTB bid log
+func (s *Server) HandleGetBidEfficiency(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetBidEfficiency(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get bid efficiency report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetPriceFloors handles retrieving the inferred price floors of a file's domains and
+// exchanges with suggested bid adjustments
+func (s *Server) HandleGetPriceFloors(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetPriceFloors(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get price floor report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetPrices handles retrieving the bid price, clearing price and CPM percentiles for a file
+func (s *Server) HandleGetPrices(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetPrices(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get price report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetCreatives handles retrieving performance, ROAS and CPA by creative for a file
+func (s *Server) HandleGetCreatives(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetCreatives(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get creative report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetPrebid handles retrieving the bidder adapter report for a Prebid Server analytics log
+func (s *Server) HandleGetPrebid(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetPrebid(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get Prebid report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetDomains handles retrieving the domain report, with authorized supply, for a file
+func (s *Server) HandleGetDomains(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Parse keyset pagination parameters
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Build the page using the analytics service
+	report, next, err := s.analyticsService.GetDomains(c, fileID, userID.(string), c.Query("cursor"), limit)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get domain report: %v", err)
+		return
+	}
+
+	// Stream the domains, which can run to hundreds of thousands for large files
+	domains := report.Domains
+	report.Domains = nil
+	page, err := newPageWriter(c, report, "domains")
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to write domain report: %v", err)
+		return
+	}
+	for _, entry := range domains {
+		if err := page.Write(entry); err != nil {
+			return
+		}
+	}
+	_ = page.Close(next)
+}
+
+// HandleGetBreakdown handles paging through a file's full breakdown by a dimension, beyond the
+// top values its summary keeps
+func (s *Server) HandleGetBreakdown(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Parse keyset pagination parameters
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	custom, err := s.metricService.Compile(c, c.MustGet("orgID").(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to load custom metrics: %v", err)
+		return
+	}
+
+	// Stream the page as the breakdown is read
+	var page *pageWriter
+	next, err := s.rollupService.StreamFileBreakdown(c, c.Param("id"), userID, c.Param("dimension"), c.Query("cursor"), limit,
+		func(breakdown *services.FileBreakdown) (err error) {
+			page, err = newPageWriter(c, breakdown, "entries")
+			return err
+		},
+		func(entry services.BreakdownEntry) error {
+			custom.Apply(&entry.CampaignMetrics)
+			return page.Write(entry)
+		},
+	)
+	switch {
+	case page != nil && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to stream breakdown", err)
+		return
+	case errors.Is(err, services.ErrInvalidBreakdown), errors.Is(err, services.ErrInvalidCursor):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrBreakdownUnavailable):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get breakdown: %v", err)
+		return
+	}
+
+	_ = page.Close(next)
+}
+
+// HandleGetContentCategories handles retrieving performance by IAB content category for a file
+func (s *Server) HandleGetContentCategories(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetContentCategories(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get content category report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetDayparting handles retrieving the dayparting heatmap data for a file
+func (s *Server) HandleGetDayparting(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetDayparting(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get dayparting report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetGeographic handles the country → region → city drill-down for a file
+func (s *Server) HandleGetGeographic(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Get the optional drill-down path
+	country := c.Query("country")
+	region := c.Query("region")
+	if region != "" && country == "" {
+		respondErrorf(c, http.StatusBadRequest, "region requires a country")
+		return
+	}
+
+	// Build the report using the analytics service
+	report, err := s.analyticsService.GetGeo(c, fileID, userID.(string), country, region)
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if errors.Is(err, ingestion.ErrLocationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get geographic report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleGetBenchmarks handles comparing a file's campaigns against the user's historical norms
+func (s *Server) HandleGetBenchmarks(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Build the benchmarks using the analytics service
+	benchmarks, err := s.analyticsService.GetBenchmarks(c, fileID, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get benchmarks: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"fileId": fileID, "benchmarks": benchmarks})
+}
+
+// HandleGetRealtime handles retrieving live delivery over the last 1, 5 and 60 minutes of the
+// user's streamed logs, in total and for the top campaigns by spend
+func (s *Server) HandleGetRealtime(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	snapshot, err := s.realtimeService.Snapshot(c, userID.(string), limit)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get realtime metrics: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, snapshot)
+}
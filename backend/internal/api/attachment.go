@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setAttachment sets the Content-Disposition header that downloads a response as a file. Names
+// that aren't plain ASCII, such as "rapport résumé (1).csv", are sent twice: in the filename*
+// parameter, UTF-8 encoded as RFC 5987 has it, and as a fallback with the characters older
+// clients can't take replaced.
+func setAttachment(c *gin.Context, filename string) {
+	fallback := asciiFilename(filename)
+	disposition := `attachment; filename="` + fallback + `"`
+	if fallback != filename {
+		disposition += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	c.Header("Content-Disposition", disposition)
+}
+
+// asciiFilename replaces what can't go in a quoted filename parameter as is with underscores:
+// characters outside printable ASCII, quotes and backslashes
+func asciiFilename(filename string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, strings.ToValidUTF8(filename, "_"))
+}
+
+// encodeRFC5987 percent-encodes a value's UTF-8 bytes, leaving only RFC 5987's attr-chars as is
+func encodeRFC5987(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(strings.ToValidUTF8(value, string(unicode.ReplacementChar))) {
+		if b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", b)
+	}
+	return encoded.String()
+}
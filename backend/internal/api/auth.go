@@ -6,24 +6,40 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// tokenClaims are the claims of an access token
+type tokenClaims struct {
+	// OrgID is the org the user acts for; tokens issued before orgs existed don't have it
+	OrgID string `json:"org,omitempty"`
+	jwt.RegisteredClaims
+}
+
 // AuthMiddleware is a middleware for checking JWT tokens
 func (s *Server) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the Authorization header
+		// Get the Authorization header. Browsers can't set headers on websocket handshakes, so
+		// those may pass the token as the access_token query parameter instead.
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") && c.Query("access_token") != "" {
+			authHeader = "Bearer " + c.Query("access_token")
+		}
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			respondErrorf(c, http.StatusUnauthorized, "Authorization header is required")
 			return
 		}
 
 		// Check if the header format is correct
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+			respondErrorf(c, http.StatusUnauthorized, "Authorization header format must be Bearer {token}")
 			return
 		}
 
@@ -31,7 +47,7 @@ func (s *Server) AuthMiddleware() gin.HandlerFunc {
 		tokenString := headerParts[1]
 
 		// Parse the token
-		claims := &jwt.RegisteredClaims{}
+		claims := &tokenClaims{}
 		token, err := jwt.ParseWithClaims(
 			tokenString,
 			claims,
@@ -40,40 +56,176 @@ func (s *Server) AuthMiddleware() gin.HandlerFunc {
 				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, errors.New("unexpected signing method")
 				}
-				return []byte(s.config.JWT.Secret), nil
+				return s.jwtSecret(), nil
 			},
 		)
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token"))
 			return
 		}
 
 		// Check token expiration
 		if claims.ExpiresAt.Time.Before(time.Now()) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+			writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeTokenExpired, "Token expired"))
+			return
+		}
+
+		// Look up the org of tokens issued before orgs existed
+		orgID := claims.OrgID
+		if orgID == "" {
+			user, err := s.userService.FindByID(c, claims.Subject)
+			if err != nil {
+				writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token"))
+				return
+			}
+			orgID = user.OrgID
+		}
+
+		// Scope the request's queries to the org's rows, including the session check below
+		c.Request = c.Request.WithContext(db.WithOrg(c.Request.Context(), orgID))
+
+		// Reject tokens whose session was revoked. Tokens without a session can't be revoked, so
+		// they aren't accepted at all.
+		if claims.ID == "" {
+			writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token"))
+			return
+		}
+		if err := s.sessionService.ValidateSession(c, claims.ID, claims.Subject); err != nil {
+			if errors.Is(err, services.ErrSessionRevoked) {
+				writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeSessionRevoked, "Session has been revoked"))
+				return
+			}
+			respondErrorf(c, http.StatusInternalServerError, "Failed to check session")
 			return
 		}
 
 		// Set the user ID in the context
 		c.Set("userID", claims.Subject)
+		c.Set("orgID", orgID)
+		c.Set("sessionID", claims.ID)
 
 		c.Next()
 	}
 }
 
-// generateToken generates a new JWT token for a user
-func (s *Server) generateToken(userID string) (string, error) {
+// APIKeyMiddleware authenticates requests from BI tools by API key, sent as the X-API-Key
+// header or as a bearer token
+func (s *Server) APIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := c.GetHeader("X-API-Key")
+		if presented == "" {
+			presented = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if presented == "" {
+			respondErrorf(c, http.StatusUnauthorized, "An API key is required")
+			return
+		}
+
+		// The key's org isn't known until it is found, so it is looked up across orgs
+		key, err := s.apiKeyService.Authenticate(db.AsSystem(c.Request.Context()), presented)
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			respondError(c, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			respondErrorf(c, http.StatusInternalServerError, "Failed to check API key")
+			return
+		}
+
+		user, err := s.userService.FindByID(c, key.UserID)
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, services.ErrInvalidAPIKey)
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("orgID", user.OrgID)
+
+		// Scope the request's queries to the org's rows
+		c.Request = c.Request.WithContext(db.WithOrg(c.Request.Context(), user.OrgID))
+
+		c.Next()
+	}
+}
+
+// ShareTokenMiddleware authenticates third parties by data share token, sent as the
+// X-Share-Token header or as a bearer token. Handlers read the share from the context rather
+// than a user, so only routes written for shares can be reached with one.
+func (s *Server) ShareTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Share-Token")
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			respondErrorf(c, http.StatusUnauthorized, "A share token is required")
+			return
+		}
+
+		// The share's org isn't known until it is found, so it is looked up across orgs
+		share, err := s.shareService.Authenticate(db.AsSystem(c.Request.Context()), token)
+		if errors.Is(err, services.ErrInvalidShareToken) {
+			respondError(c, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			respondErrorf(c, http.StatusInternalServerError, "Failed to check share token")
+			return
+		}
+
+		c.Set("share", share)
+
+		// Scope the request's queries to the sharing org's rows
+		c.Request = c.Request.WithContext(db.WithOrg(c.Request.Context(), share.OrgID))
+
+		c.Next()
+	}
+}
+
+// startSession starts a session for the device signing in, returning its refresh token and an
+// access token for it
+func (s *Server) startSession(c *gin.Context, user *models.User) (*models.Session, string, error) {
+	expiresAt := time.Now().AddDate(0, 0, s.config.JWT.RefreshExpiration)
+	session, err := s.sessionService.CreateSession(db.WithOrg(c.Request.Context(), user.OrgID), user.ID, c.Request.UserAgent(), c.ClientIP(), expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.generateToken(user, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// generateToken generates a JWT access token for a session, expiring no later than the session
+func (s *Server) generateToken(user *models.User, session *models.Session) (string, error) {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(s.config.JWT.Expiration) * time.Hour)
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
 	// Create the claims
-	claims := jwt.RegisteredClaims{
-		Subject:   userID,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(s.config.JWT.Expiration) * time.Hour)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	claims := tokenClaims{
+		OrgID: user.OrgID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.ID,
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
 	}
 
 	// Create the token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign the token
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	return token.SignedString(s.jwtSecret())
+}
+
+// jwtSecret returns the current signing secret, which may be rotated by the secrets backend
+func (s *Server) jwtSecret() []byte {
+	return []byte(s.secrets.Get(secrets.KeyJWTSecret, s.config.JWT.Secret))
 }
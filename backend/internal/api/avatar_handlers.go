@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleUploadAvatar handles replacing the current user's avatar with an uploaded image
+func (s *Server) HandleUploadAvatar(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAvatarUploadSize+1<<20)
+
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		if isTooLarge(err) {
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "Avatar exceeds the maximum size of %d MB", services.MaxAvatarUploadSize>>20)
+			return
+		}
+		respondErrorf(c, http.StatusBadRequest, "No avatar image uploaded")
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to read avatar: %v", err)
+		return
+	}
+	defer src.Close()
+
+	user, err := s.userService.SetAvatar(c, userID, src)
+	if err != nil {
+		switch {
+		case isTooLarge(err):
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "Avatar exceeds the maximum size of %d MB", services.MaxAvatarUploadSize>>20)
+		case errors.Is(err, storage.ErrInvalidImage), errors.Is(err, storage.ErrImageTooLarge):
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to store avatar: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": userResponse(user)})
+}
+
+// HandleDeleteAvatar handles removing the current user's avatar
+func (s *Server) HandleDeleteAvatar(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	if err := s.userService.RemoveAvatar(c, userID); err != nil {
+		if errors.Is(err, services.ErrNoAvatar) {
+			respondErrorf(c, http.StatusNotFound, "No avatar to remove")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to remove avatar: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetAvatar handles serving an avatar image. It needs no authentication, so it serves
+// nothing but the re-encoded PNGs the avatar store wrote.
+func (s *Server) HandleGetAvatar(c *gin.Context) {
+	file, err := s.userService.OpenAvatar(strings.TrimSuffix(c.Param("id"), ".png"))
+	if err != nil {
+		if errors.Is(err, storage.ErrAvatarNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Avatar not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to open avatar")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to open avatar")
+		return
+	}
+
+	// Avatar IDs change whenever the image does, so the image can be cached indefinitely
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.DataFromReader(http.StatusOK, info.Size(), "image/png", file, nil)
+}
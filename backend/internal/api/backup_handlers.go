@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/backup"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleExportBackup handles downloading a backup archive of the metadata tables and analyses
+func (s *Server) HandleExportBackup(c *gin.Context) {
+	// The archive is streamed, so failures after the first byte can only be reported
+	started := false
+	_, err := backup.Export(c, s.db.Pool, s.reportsDir, writerFunc(func(p []byte) (int, error) {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/zip")
+			setAttachment(c, fmt.Sprintf("advantage-backup-%s.zip", time.Now().UTC().Format("20060102-150405")))
+		}
+		return c.Writer.Write(p)
+	}))
+	switch {
+	case started && err != nil:
+		errreport.Report(requestContext(c), "Failed to export backup", err)
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to export backup: %v", err)
+	}
+}
+
+// HandleRestoreBackup handles restoring an uploaded backup archive into a fresh database
+func (s *Server) HandleRestoreBackup(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to get file: %v", err)
+		return
+	}
+	archive, err := header.Open()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to open file: %v", err)
+		return
+	}
+	defer archive.Close()
+
+	manifest, err := backup.Restore(c, s.db.Pool, s.reportsDir, archive, header.Size, s.fileService.DecompressionLimits())
+	switch {
+	case errors.Is(err, storage.ErrDecompressionLimit):
+		respondError(c, http.StatusRequestEntityTooLarge, storage.ErrDecompressionLimit)
+		return
+	case errors.Is(err, backup.ErrInvalidArchive):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, backup.ErrDatabaseNotEmpty):
+		respondError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to restore backup: %v", err)
+		return
+	}
+
+	tables, analyses := manifest.Counts()
+	c.JSON(http.StatusOK, gin.H{"createdAt": manifest.CreatedAt, "tables": tables, "analyses": analyses})
+}
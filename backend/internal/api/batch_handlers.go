@@ -0,0 +1,294 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidBatch is returned for batch forms the server can't read, such as a malformed manifest
+var errInvalidBatch = errors.New("invalid batch")
+
+// batchManifest lists the files to take from a batch's archive
+type batchManifest struct {
+	Files []string `json:"files"`
+}
+
+// archiveContentTypes maps the extensions of archived files to the content types uploads are checked against
+var archiveContentTypes = map[string]string{
+	".csv":    "text/csv",
+	".tsv":    "text/plain",
+	".txt":    "text/plain",
+	".log":    "text/plain",
+	".json":   "application/json",
+	".ndjson": "application/x-ndjson",
+	".jsonl":  "application/x-ndjson",
+	".xls":    "application/vnd.ms-excel",
+	".xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// HandleBatchUpload handles uploading several files in one request, queuing a processing job
+// per file. The form carries any number of "files" parts, or an "archive" zip part optionally
+// preceded by a "manifest" part listing the archived files to take. Files that can't be
+// accepted are listed as rejected without failing the rest of the batch.
+func (s *Server) HandleBatchUpload(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Repeated requests with the same Idempotency-Key return the original batch
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > 255 {
+		respondErrorf(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+
+	priority, ok := uploadPriority(c)
+	if !ok {
+		return
+	}
+
+	// Batches with more files than the plan has uploads left for today are rejected
+	remaining := c.MustGet("uploadsRemaining").(int)
+
+	// A day of hourly files outlasts the server's default timeouts
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetReadDeadline(time.Now().Add(uploadTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(uploadTimeout))
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to parse form: %v", err)
+		return
+	}
+
+	batch, err := s.fileService.UploadBatch(c, userID, idempotencyKey, priority, func(add func(io.Reader, string, string) error, reject func(string, string)) error {
+		return s.readBatchForm(reader, remaining, add, reject)
+	})
+
+	// Queue whatever was stored, even if the rest of the form failed; the files are already saved
+	if batch != nil && !batch.Replayed {
+		for _, file := range batch.Files {
+			if err := s.fileService.SubmitProcessingJob(file.JobID, file.FileID, userID, file.Priority); err != nil {
+				// If the server is shutting down the job stays queued for the next start
+				errreport.Report(errreport.WithTags(requestContext(c), "jobID", file.JobID, "fileID", file.FileID), "Failed to submit processing job", err)
+			}
+		}
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidBatch):
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrDailyUploadLimit):
+			respondError(c, http.StatusTooManyRequests, err)
+		case isTooLarge(err):
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "Archive exceeds the maximum allowed size of %dMB", s.fileService.MaxUploadSize()>>20)
+		case errors.Is(err, storage.ErrDecompressionLimit):
+			// The error is wrapped in how storing the file failed, which the client doesn't need
+			respondError(c, http.StatusRequestEntityTooLarge, storage.ErrDecompressionLimit)
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to upload batch: %v", err)
+		}
+		return
+	}
+
+	if batch.Replayed {
+		// The earlier request already queued processing
+		c.Header("Idempotent-Replayed", "true")
+		c.JSON(http.StatusOK, batch)
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+// readBatchForm streams a batch upload form, passing each file it carries to add. remaining is
+// how many files the org may still upload today, or -1 for no limit; manifests and archives
+// listing more are refused before any of their files are stored, and streamed files end the
+// form once they pass it.
+func (s *Server) readBatchForm(reader *multipart.Reader, remaining int, add func(io.Reader, string, string) error, reject func(string, string)) error {
+	var manifest *batchManifest
+	sawArchive, sawFile := false, false
+	files := 0
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch part.FormName() {
+		case "manifest":
+			if sawArchive {
+				err = fmt.Errorf("%w: manifest must come before the archive", errInvalidBatch)
+				break
+			}
+			manifest = &batchManifest{}
+			if decodeErr := json.NewDecoder(io.LimitReader(part, 1<<20)).Decode(manifest); decodeErr != nil {
+				err = fmt.Errorf("%w: malformed manifest: %v", errInvalidBatch, decodeErr)
+				break
+			}
+			err = checkUploadAllowance(len(manifest.Files), remaining)
+		case "archive":
+			sawArchive = true
+			archiveRemaining := remaining
+			if remaining >= 0 {
+				archiveRemaining = remaining - files
+			}
+			err = s.addArchiveFiles(part, manifest, archiveRemaining, add, reject)
+		case "files", "file":
+			if part.FileName() != "" {
+				sawFile = true
+				files++
+				if err = checkUploadAllowance(files, remaining); err == nil {
+					err = add(part, part.FileName(), part.Header.Get("Content-Type"))
+				}
+			}
+		}
+		part.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !sawArchive && !sawFile {
+		return fmt.Errorf("%w: no files or archive in form", errInvalidBatch)
+	}
+	return nil
+}
+
+// checkUploadAllowance returns ErrDailyUploadLimit when a batch of count files is more than the
+// remaining uploads for today allow; remaining is -1 when the plan doesn't limit uploads
+func checkUploadAllowance(count, remaining int) error {
+	if remaining < 0 || count <= remaining {
+		return nil
+	}
+	return fmt.Errorf("%w: the batch has %d files, but only %d more may be uploaded today", services.ErrDailyUploadLimit, count, max(remaining, 0))
+}
+
+// addArchiveFiles passes the files in a zip archive to add, limited to the manifest's files when
+// there is one. Manifest entries missing from the archive are rejected, and archives with more
+// files than remaining uploads are refused before any is decompressed.
+func (s *Server) addArchiveFiles(part io.Reader, manifest *batchManifest, remaining int, add func(io.Reader, string, string) error, reject func(string, string)) error {
+	// Zip directories sit at the end of the archive, so it has to be on disk before it can be read
+	file, size, err := s.fileService.SpoolArchive(part)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("%w: archive is not a valid zip file", errInvalidBatch)
+	}
+
+	var wanted map[string]bool
+	if manifest != nil {
+		wanted = make(map[string]bool, len(manifest.Files))
+		for _, name := range manifest.Files {
+			wanted[name] = true
+		}
+	}
+
+	// Sort out which entries to take before decompressing any, so an archive declaring it expands
+	// past the limits is refused up front
+	type archiveEntry struct {
+		file        *zip.File
+		contentType string
+	}
+	var entries []archiveEntry
+	var declared uint64
+	for _, entry := range archive.File {
+		// Skip folders and the metadata macOS adds to archives it creates
+		name := entry.Name
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if wanted != nil {
+			if !wanted[name] {
+				continue
+			}
+			delete(wanted, name)
+		}
+
+		contentType, ok := archiveContentTypes[strings.ToLower(path.Ext(name))]
+		if !ok {
+			reject(name, fmt.Sprintf("%v: %s", services.ErrFileTypeNotAllowed, path.Ext(name)))
+			continue
+		}
+		entries = append(entries, archiveEntry{file: entry, contentType: contentType})
+		declared += entry.UncompressedSize64
+	}
+
+	if err := checkUploadAllowance(len(entries), remaining); err != nil {
+		return err
+	}
+
+	// Entries can understate their size, so what they decompress into is counted as well
+	budget := s.fileService.DecompressionLimits().Budget(size)
+	if err := budget.Check(declared); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		src, err := entry.file.Open()
+		if err != nil {
+			reject(entry.file.Name, fmt.Sprintf("failed to read from archive: %v", err))
+			continue
+		}
+		err = add(budget.Reader(src), path.Base(entry.file.Name), entry.contentType)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	missing := make([]string, 0, len(wanted))
+	for name := range wanted {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		reject(name, "not found in archive")
+	}
+
+	return nil
+}
+
+// HandleGetBatch handles retrieving a batch with the processing status of its files
+func (s *Server) HandleGetBatch(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	batch, err := s.fileService.GetBatch(c, c.Param("id"), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrBatchNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Batch not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get batch: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBrandSafetyUploadSize caps the size of an uploaded brand safety list
+const maxBrandSafetyUploadSize = 10 << 20
+
+// CreateBrandSafetyListRequest represents a request to create a brand safety list
+type CreateBrandSafetyListRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Type    string   `json:"type" binding:"required"`
+	Entries []string `json:"entries" binding:"required"`
+}
+
+// HandleCreateBrandSafetyList handles creating a brand safety list, either from JSON or from
+// a multipart upload whose file has one entry per line
+func (s *Server) HandleCreateBrandSafetyList(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req CreateBrandSafetyListRequest
+	if c.ContentType() == "multipart/form-data" {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBrandSafetyUploadSize)
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			respondErrorf(c, http.StatusBadRequest, "Failed to get file: %v", err)
+			return
+		}
+		reader, err := file.Open()
+		if err != nil {
+			respondErrorf(c, http.StatusBadRequest, "Failed to open file: %v", err)
+			return
+		}
+		defer reader.Close()
+
+		req.Name = c.PostForm("name")
+		req.Type = c.PostForm("type")
+		if req.Entries, err = readListEntries(reader); err != nil {
+			respondErrorf(c, http.StatusBadRequest, "Failed to read file: %v", err)
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	list, err := s.brandSafetyService.CreateList(c, userID.(string), req.Name, req.Type, req.Entries)
+	switch {
+	case errors.Is(err, services.ErrInvalidBrandSafetyList), errors.Is(err, services.ErrTooManyBrandSafetyEntries):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrBrandSafetyListExists):
+		respondError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create brand safety list: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// readListEntries reads one entry per line, taking the first column of CSV exports
+func readListEntries(reader io.Reader) ([]string, error) {
+	var entries []string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if column, _, found := strings.Cut(line, ","); found {
+			line = column
+		}
+		entries = append(entries, strings.Trim(line, "\" \t"))
+	}
+
+	return entries, scanner.Err()
+}
+
+// HandleListBrandSafetyLists handles listing the current user's brand safety lists
+func (s *Server) HandleListBrandSafetyLists(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	lists, err := s.brandSafetyService.ListLists(c, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list brand safety lists: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lists": lists})
+}
+
+// HandleDeleteBrandSafetyList handles deleting a brand safety list
+func (s *Server) HandleDeleteBrandSafetyList(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := s.brandSafetyService.DeleteList(c, c.Param("id"), userID.(string))
+	switch {
+	case errors.Is(err, services.ErrBrandSafetyListNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete brand safety list: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetBrandSafety handles retrieving the brand safety report for a file
+func (s *Server) HandleGetBrandSafety(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	// Screen the file using the brand safety service
+	report, err := s.brandSafetyService.GetBrandSafety(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get brand safety report: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// HandleExportBrandSafetyViolations handles downloading a file's brand safety violations as CSV
+func (s *Server) HandleExportBrandSafetyViolations(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	report, err := s.brandSafetyService.GetBrandSafety(c, fileID, userID.(string))
+	if errors.Is(err, services.ErrReportUnavailable) {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get brand safety report: %v", err)
+		return
+	}
+
+	// Label spend with the user's currency
+	defaults, err := s.preferencesService.ReportDefaults(c, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get report defaults: %v", err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	setAttachment(c, fmt.Sprintf("brand_safety_violations_%s.csv", fileID))
+
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"campaign_id", "domain", "rule", "list", "match", "impressions", "spend", "currency"})
+	for _, violation := range report.Violations {
+		_ = writer.Write([]string{
+			violation.CampaignID,
+			violation.Domain,
+			violation.Rule,
+			violation.List,
+			violation.Match,
+			strconv.Itoa(violation.Impressions),
+			strconv.FormatFloat(violation.Spend, 'f', 2, 64),
+			defaults.Currency,
+		})
+	}
+	writer.Flush()
+}
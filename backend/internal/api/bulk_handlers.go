@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BulkFilesRequest applies an action to many files at once
+type BulkFilesRequest struct {
+	Action  string   `json:"action" binding:"required,oneof=delete tag move-to-folder reprocess"`
+	FileIDs []string `json:"fileIds" binding:"required,min=1,max=1000,dive,required"` // At most services.MaxBulkFiles
+	Tags    []string `json:"tags" binding:"required_if=Action tag"`                   // Added by the tag action
+	Folder  string   `json:"folder"`                                                  // Where move-to-folder files them; empty for the top level
+}
+
+// bulkFileResult is the outcome of a bulk action for one file
+type bulkFileResult struct {
+	FileID string                `json:"fileId"`
+	Status string                `json:"status"` // succeeded or failed
+	Job    *models.ProcessingJob `json:"job,omitempty"`
+	Error  *apierror.Error       `json:"error,omitempty"`
+}
+
+// HandleBulkFiles handles deleting, tagging, moving or reprocessing many files in one request.
+// Each file succeeds or fails on its own, and the response reports which did and why, so a
+// file that's gone or already queued doesn't fail the rest. ?priority sets the priority of
+// reprocessing jobs.
+func (s *Server) HandleBulkFiles(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req BulkFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	priority, ok := uploadPriority(c)
+	if !ok {
+		return
+	}
+
+	results, err := s.fileService.ApplyBulkAction(c, userID, services.BulkFileAction{
+		Action:   req.Action,
+		FileIDs:  req.FileIDs,
+		Tags:     req.Tags,
+		Folder:   req.Folder,
+		Priority: priority,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidBulkAction), errors.Is(err, services.ErrInvalidTags),
+			errors.Is(err, services.ErrInvalidFolder), errors.Is(err, services.ErrInvalidPriority):
+			respondError(c, http.StatusBadRequest, err)
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to apply bulk action: %v", err)
+		}
+		return
+	}
+
+	response := make([]bulkFileResult, len(results))
+	failed := 0
+	for i, result := range results {
+		response[i] = bulkFileResult{FileID: result.FileID, Status: "succeeded", Job: result.Job}
+		if result.Err != nil {
+			response[i].Status = "failed"
+			response[i].Error = localizeError(c, bulkFileError(c, result))
+			failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"action":    req.Action,
+		"results":   response,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	})
+}
+
+// bulkFileError explains why a bulk action failed for a file, reporting unexpected failures
+func bulkFileError(c *gin.Context, result services.BulkFileResult) *apierror.Error {
+	switch {
+	case errors.Is(result.Err, services.ErrFileNotFound):
+		return apierror.New(http.StatusNotFound, "", "File not found")
+	case errors.Is(result.Err, services.ErrJobActive):
+		return apierror.New(http.StatusConflict, apierror.CodeAlreadyQueued, "File is already queued or being processed")
+	default:
+		errreport.Report(errreport.WithTags(requestContext(c), "fileID", result.FileID), "Failed to apply bulk action", result.Err)
+		return apierror.New(http.StatusInternalServerError, "", "Failed to apply bulk action")
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleExportBundle handles downloading a processed file's analysis as a portable ZIP bundle
+func (s *Server) HandleExportBundle(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+	fileID := c.Param("id")
+
+	// The bundle is assembled in memory before the response starts, so failures still get a status
+	started := false
+	err := s.bundleService.ExportBundle(c, fileID, userID, writerFunc(func(p []byte) (int, error) {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/zip")
+			setAttachment(c, fmt.Sprintf("analysis_%s.zip", fileID))
+		}
+		return c.Writer.Write(p)
+	}))
+	switch {
+	case started && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to export analysis bundle", err)
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, ingestion.ErrAnalysisNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to export analysis bundle: %v", err)
+	}
+}
+
+// HandleImportBundle handles importing an analysis bundle as a new processed file
+func (s *Server) HandleImportBundle(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Reject bodies over the upload limit; the multipart framing adds a little overhead
+	maxSize := s.fileService.MaxUploadSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		if isTooLarge(err) {
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "File size exceeds the maximum allowed size of %dMB", maxSize>>20)
+			return
+		}
+		respondErrorf(c, http.StatusBadRequest, "Failed to get file: %v", err)
+		return
+	}
+	bundle, err := header.Open()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to open file: %v", err)
+		return
+	}
+	defer bundle.Close()
+
+	fileInfo, err := s.bundleService.ImportBundle(c, bundle, header.Size, userID)
+	switch {
+	case isTooLarge(err):
+		respondErrorf(c, http.StatusRequestEntityTooLarge, "File size exceeds the maximum allowed size of %dMB", maxSize>>20)
+		return
+	case errors.Is(err, storage.ErrDecompressionLimit):
+		respondError(c, http.StatusRequestEntityTooLarge, storage.ErrDecompressionLimit)
+		return
+	case errors.Is(err, services.ErrInvalidBundle):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to import analysis bundle: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, fileInfo)
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
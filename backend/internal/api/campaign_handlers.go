@@ -0,0 +1,544 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetCampaignRollup handles retrieving a campaign merged across all of the org's uploads
+func (s *Server) HandleGetCampaignRollup(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	orgID := c.MustGet("orgID").(string)
+
+	// Get campaign ID from route params
+	campaignID := c.Param("id")
+	if campaignID == "" {
+		respondErrorf(c, http.StatusBadRequest, "Campaign ID is required")
+		return
+	}
+
+	// Parse optional flight dates
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Build the rollup using the campaign service
+	rollup, err := s.campaignService.GetCampaignRollup(c, orgID, campaignID, from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get campaign rollup: %v", err)
+		return
+	}
+
+	// Compare the rollup with the campaign's goal, in the campaign's currency
+	err = s.goalService.ApplyGoal(c, userID.(string), rollup)
+	switch {
+	case errors.Is(err, fxrates.ErrNoRate):
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get campaign goal: %v", err)
+		return
+	}
+
+	// Mark the events recorded against the campaign
+	if err := s.annotationService.ApplyAnnotations(c, userID.(string), rollup); err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get annotations: %v", err)
+		return
+	}
+
+	// Report spend in the organization's currency
+	currency, err := s.currencyService.ReportingCurrency(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get reporting currency: %v", err)
+		return
+	}
+	rollup, err = s.currencyService.ConvertRollup(c, rollup, currency)
+	switch {
+	case errors.Is(err, fxrates.ErrNoRate):
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to convert campaign rollup: %v", err)
+		return
+	}
+
+	// Evaluate the organization's custom metrics for the totals and each day
+	custom, err := s.metricService.Compile(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to load custom metrics: %v", err)
+		return
+	}
+	custom.Apply(&rollup.Totals)
+	for i := range rollup.Daily {
+		custom.Apply(&rollup.Daily[i].CampaignMetrics)
+	}
+
+	c.JSON(http.StatusOK, rollup)
+}
+
+// SetCampaignGoalRequest represents a request to set a campaign's goal; targets left out are
+// unset, and money targets are in the campaign's currency, USD unless given
+type SetCampaignGoalRequest struct {
+	Currency   string   `json:"currency"`
+	TargetCPA  *float64 `json:"targetCpa"`
+	TargetCTR  *float64 `json:"targetCtr"`
+	TargetROAS *float64 `json:"targetRoas"`
+	DailySpend *float64 `json:"dailySpend"`
+	Budget     *float64 `json:"budget"`
+}
+
+// HandleListCampaignGoals handles listing the current user's campaign goals
+func (s *Server) HandleListCampaignGoals(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	goals, err := s.goalService.ListGoals(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list campaign goals: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+// HandleGetCampaignGoal handles retrieving a campaign's goal
+func (s *Server) HandleGetCampaignGoal(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	goal, err := s.goalService.GetGoal(c, userID, c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrCampaignGoalNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get campaign goal: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+// HandleSetCampaignGoal handles setting a campaign's goal
+func (s *Server) HandleSetCampaignGoal(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req SetCampaignGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	goal := &ingestion.CampaignGoal{
+		CampaignID: c.Param("id"),
+		Currency:   req.Currency,
+		TargetCPA:  req.TargetCPA,
+		TargetCTR:  req.TargetCTR,
+		TargetROAS: req.TargetROAS,
+		DailySpend: req.DailySpend,
+		Budget:     req.Budget,
+	}
+	err := s.goalService.SetGoal(c, userID, goal)
+	switch {
+	case errors.Is(err, services.ErrInvalidCampaignGoal):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to set campaign goal: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+// HandleDeleteCampaignGoal handles removing a campaign's goal
+func (s *Server) HandleDeleteCampaignGoal(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.goalService.DeleteGoal(c, userID, c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrCampaignGoalNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete campaign goal: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateCampaignAnnotationRequest represents a request to record an event against a campaign.
+// occurredAt is an RFC 3339 time, or a YYYY-MM-DD date for an event without one, taken as
+// midnight UTC.
+type CreateCampaignAnnotationRequest struct {
+	Type       string `json:"type" binding:"required"`
+	Note       string `json:"note"`
+	OccurredAt string `json:"occurredAt" binding:"required"`
+}
+
+// HandleListCampaignAnnotations handles listing the events recorded against a campaign
+func (s *Server) HandleListCampaignAnnotations(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	annotations, err := s.annotationService.ListAnnotations(c, userID, c.Param("id"), from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list annotations: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"annotations": annotations})
+}
+
+// HandleCreateCampaignAnnotation handles recording an event against a campaign
+func (s *Server) HandleCreateCampaignAnnotation(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateCampaignAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	occurredAt, err := time.Parse(time.RFC3339, req.OccurredAt)
+	if err != nil {
+		date, dateErr := parseOptionalDate("occurredAt", req.OccurredAt)
+		if dateErr != nil {
+			respondErrorf(c, http.StatusBadRequest, "invalid 'occurredAt', expected an RFC 3339 time or a YYYY-MM-DD date")
+			return
+		}
+		occurredAt = *date
+	}
+
+	annotation := &ingestion.CampaignAnnotation{
+		CampaignID: c.Param("id"),
+		Type:       req.Type,
+		Note:       req.Note,
+		OccurredAt: occurredAt,
+	}
+	err = s.annotationService.AddAnnotation(c, userID, annotation)
+	switch {
+	case errors.Is(err, services.ErrInvalidAnnotation):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create annotation: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, annotation)
+}
+
+// HandleDeleteCampaignAnnotation handles removing an event recorded against a campaign
+func (s *Server) HandleDeleteCampaignAnnotation(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.annotationService.DeleteAnnotation(c, userID, c.Param("id"), c.Param("annotationId"))
+	switch {
+	case errors.Is(err, services.ErrAnnotationNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete annotation: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetCampaignReach handles retrieving a campaign's unique reach and frequency
+func (s *Server) HandleGetCampaignReach(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get campaign ID from route params
+	campaignID := c.Param("id")
+	if campaignID == "" {
+		respondErrorf(c, http.StatusBadRequest, "Campaign ID is required")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Compute reach and frequency using the campaign service
+	report, err := s.campaignService.GetReachFrequency(c, userID.(string), campaignID, from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get reach and frequency: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleGetCampaignFrequencyCap handles retrieving a campaign's conversions by impression
+// frequency with a recommended frequency cap
+func (s *Server) HandleGetCampaignFrequencyCap(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	campaignID := c.Param("id")
+	if campaignID == "" {
+		respondErrorf(c, http.StatusBadRequest, "Campaign ID is required")
+		return
+	}
+
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := s.campaignService.GetFrequencyCap(c, userID, campaignID, from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get frequency cap: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// parseDateRangeQuery parses the optional 'from' and 'to' YYYY-MM-DD query parameters
+func parseDateRangeQuery(c *gin.Context) (*time.Time, *time.Time, error) {
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		return nil, nil, err
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, fmt.Errorf("'to' must not be before 'from'")
+	}
+
+	return from, to, nil
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter
+func parseDateQuery(c *gin.Context, name string) (*time.Time, error) {
+	return parseOptionalDate(name, c.Query(name))
+}
+
+// parseOptionalDate parses an optional YYYY-MM-DD value named name
+func parseOptionalDate(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' date, expected YYYY-MM-DD", name)
+	}
+
+	return &date, nil
+}
+
+// HandleGetRollups handles retrieving precomputed hourly or daily rollups of a dimension across
+// all of the user's uploads
+func (s *Server) HandleGetRollups(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Parse keyset pagination parameters
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	custom, err := s.metricService.Compile(c, c.MustGet("orgID").(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to load custom metrics: %v", err)
+		return
+	}
+
+	// Stream the page as rollups are read; hourly domain rollups can run to hundreds of thousands of rows
+	var page *pageWriter
+	next, err := s.rollupService.StreamRollups(c, userID.(string), c.Query("dimension"), c.DefaultQuery("grain", ingestion.RollupDaily), c.Query("value"), from, to, c.Query("cursor"), limit,
+		func(series *services.RollupSeries) (err error) {
+			// A campaign's series carries its annotations, for charts to mark
+			if series.Dimension == ingestion.RollupByCampaign && series.Value != "" {
+				series.Annotations, err = s.annotationService.ListAnnotations(c, userID.(string), series.Value, from, to)
+				if err != nil {
+					return err
+				}
+			}
+			page, err = newPageWriter(c, series, "buckets")
+			return err
+		},
+		func(rollup ingestion.Rollup) error {
+			custom.Apply(&rollup.CampaignMetrics)
+			return page.Write(rollup)
+		},
+	)
+	switch {
+	case page != nil && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to stream rollups", err)
+		return
+	case errors.Is(err, services.ErrInvalidRollupQuery), errors.Is(err, services.ErrInvalidCursor):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get rollups: %v", err)
+		return
+	}
+
+	_ = page.Close(next)
+}
+
+// HandleExportRollups handles downloading every rollup of a dimension across the user's uploads
+// as CSV, with a column for each of the organization's custom metrics, and for campaigns, one
+// for the events recorded against them
+func (s *Server) HandleExportRollups(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+	orgID := c.MustGet("orgID").(string)
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	custom, err := s.metricService.Compile(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to load custom metrics: %v", err)
+		return
+	}
+	names := custom.Names()
+
+	dimension, grain := c.Query("dimension"), c.DefaultQuery("grain", ingestion.RollupDaily)
+	var annotations map[string][]ingestion.CampaignAnnotation
+	if dimension == ingestion.RollupByCampaign {
+		annotations, err = s.annotationService.AnnotationsByCampaign(c, userID, from, to)
+		if err != nil {
+			respondErrorf(c, http.StatusInternalServerError, "Failed to get annotations: %v", err)
+			return
+		}
+	}
+
+	// Read the rollups a page at a time, writing the header once the first page starts
+	var writer *csv.Writer
+	cursor := ""
+	for {
+		cursor, err = s.rollupService.StreamRollups(c, userID, dimension, grain, c.Query("value"), from, to, cursor, services.MaxPageSize,
+			func(*services.RollupSeries) error {
+				if writer != nil {
+					return nil
+				}
+				c.Header("Content-Type", "text/csv")
+				setAttachment(c, fmt.Sprintf("rollups_%s_%s.csv", dimension, grain))
+				writer = csv.NewWriter(c.Writer)
+				header := []string{"bucket", "value", "bids", "impressions", "clicks", "conversions", "spend", "revenue", "ctr", "roas", "cpa", "measurable_impressions", "viewable_impressions"}
+				header = append(header, names...)
+				if annotations != nil {
+					header = append(header, "annotations")
+				}
+				return writer.Write(header)
+			},
+			func(rollup ingestion.Rollup) error {
+				custom.Apply(&rollup.CampaignMetrics)
+				record := rollupRecord(rollup, names)
+				if annotations != nil {
+					record = append(record, ingestion.AnnotationLabels(ingestion.AnnotationsIn(annotations[rollup.Value], grain, rollup.Bucket)))
+				}
+				return writer.Write(record)
+			},
+		)
+		if err != nil || cursor == "" {
+			break
+		}
+	}
+	switch {
+	case writer != nil && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to export rollups", err)
+		return
+	case errors.Is(err, services.ErrInvalidRollupQuery):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to export rollups: %v", err)
+		return
+	}
+
+	writer.Flush()
+}
+
+// rollupRecord formats a rollup as a CSV record, leaving custom metrics that are undefined for
+// it empty
+func rollupRecord(rollup ingestion.Rollup, names []string) []string {
+	record := []string{
+		rollup.Bucket.Format(time.RFC3339),
+		rollup.Value,
+		strconv.Itoa(rollup.Bids),
+		strconv.Itoa(rollup.Impressions),
+		strconv.Itoa(rollup.Clicks),
+		strconv.Itoa(rollup.Conversions),
+		strconv.FormatFloat(rollup.Spend, 'f', 2, 64),
+		strconv.FormatFloat(rollup.Revenue, 'f', 2, 64),
+		strconv.FormatFloat(rollup.CTR, 'f', -1, 64),
+		strconv.FormatFloat(rollup.ROAS, 'f', -1, 64),
+		strconv.FormatFloat(rollup.CPA, 'f', -1, 64),
+		strconv.Itoa(rollup.MeasurableImpressions),
+		strconv.Itoa(rollup.ViewableImpressions),
+	}
+	for _, name := range names {
+		value := ""
+		if v, ok := rollup.Custom[name]; ok {
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		record = append(record, value)
+	}
+	return record
+}
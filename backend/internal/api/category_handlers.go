@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCategoryOverrideRequest represents a request to assign a content category to a domain
+type SetCategoryOverrideRequest struct {
+	Category string `json:"category" binding:"required"`
+}
+
+// HandleListCategories handles listing the IAB content categories
+func (s *Server) HandleListCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"categories": s.categoryService.ListCategories()})
+}
+
+// HandleListCategoryOverrides handles listing the current user's category overrides
+func (s *Server) HandleListCategoryOverrides(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	overrides, err := s.categoryService.ListOverrides(c, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list category overrides: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides})
+}
+
+// HandleSetCategoryOverride handles assigning a content category to a domain
+func (s *Server) HandleSetCategoryOverride(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req SetCategoryOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	override, err := s.categoryService.SetOverride(c, userID.(string), c.Param("domain"), req.Category)
+	switch {
+	case errors.Is(err, services.ErrInvalidDomain), errors.Is(err, services.ErrInvalidCategory):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to set category override: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+// HandleDeleteCategoryOverride handles removing a domain's category override
+func (s *Server) HandleDeleteCategoryOverride(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := s.categoryService.DeleteOverride(c, userID.(string), c.Param("domain"))
+	switch {
+	case errors.Is(err, services.ErrCategoryOverrideNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete category override: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
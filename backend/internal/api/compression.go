@@ -0,0 +1,153 @@
+package api
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minCompressSize is the smallest response worth compressing, when its length is known upfront
+const minCompressSize = 1024
+
+// compressibleTypes are the content types compressed; files such as PDFs, workbooks and images
+// are compressed already
+var compressibleTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+	"application/xml":      true,
+	"image/svg+xml":        true,
+	"text/csv":             true,
+	"text/html":            true,
+	"text/plain":           true,
+}
+
+// gzipWriters are reused between responses, since each holds sizable compression state
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// CompressionMiddleware gzips responses for clients that accept it, as each is written, so
+// streamed responses stay streamed. Websocket upgrades are left alone. It runs outside
+// RecoveryMiddleware so the error sent for a panic is compressed and finished like any other.
+func CompressionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Upgrade") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		writer := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		writer.close()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// gzip;q=0 explicitly refuses it
+		_, q, ok := strings.Cut(strings.ReplaceAll(params, " ", ""), "q=")
+		if ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter compresses a response once its headers show it's worth compressing
+type gzipWriter struct {
+	gin.ResponseWriter
+	gz      *gzip.Writer // nil when the response isn't compressed
+	decided bool
+}
+
+// Write writes the response, compressing it when it's compressible
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes the response, compressing it when it's compressible
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what's been compressed so far, for streamed responses
+func (w *gzipWriter) Flush() {
+	w.decide()
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide starts compressing the response on its first write, unless its status, type or length
+// make it not worth it
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	if w.ResponseWriter.Written() {
+		// The headers have gone out without saying the response is compressed
+		return
+	}
+	header := w.Header()
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return
+	}
+	// Byte ranges count bytes of the response as stored, so a response that takes them is sent as
+	// is, with its Content-Length, for interrupted downloads to resume from
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || header.Get("Accept-Ranges") == "bytes" {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if !compressibleTypes[mediaType] {
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < minCompressSize {
+		return
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+// Unwrap returns the writer being compressed into, so http.ResponseController can reach the
+// connection to set deadlines
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the compressed response
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	gzipWriters.Put(w.gz)
+	w.gz = nil
+}
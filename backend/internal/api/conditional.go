@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxConditionalBody bounds the responses held back to be tagged; larger ones are sent as
+// they're written, without an ETag
+const maxConditionalBody = 64 << 20
+
+// ConditionalGetMiddleware tags successful JSON responses to GET requests with an ETag, and
+// answers 304 Not Modified instead of sending the body again when the client already has it.
+// Handlers that know when their data last changed set Last-Modified, which If-Modified-Since
+// is checked against when the client sends no If-None-Match. The response still has to be
+// built to be tagged, so this saves sending it, not building it.
+func ConditionalGetMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		writer := &conditionalWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		if writer.passthrough || writer.body.Len() == 0 {
+			return
+		}
+
+		// The tag is weak since compression changes the bytes sent, not what they mean
+		sum := sha256.Sum256(writer.body.Bytes())
+		etag := `W/"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+		header := writer.Header()
+		header.Set("ETag", etag)
+		// Browsers may keep the response, but must check it's still current before using it
+		header.Set("Cache-Control", "private, no-cache")
+
+		if notModified(c.Request, etag, header.Get("Last-Modified")) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			writer.body.Reset()
+			writer.passthrough = true
+			writer.WriteHeader(http.StatusNotModified)
+			writer.WriteHeaderNow()
+			return
+		}
+		writer.release()
+	}
+}
+
+// notModified reports whether a request's preconditions show the client has the current
+// response: If-None-Match naming its ETag, or failing that If-Modified-Since at or after its
+// Last-Modified
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// conditionalWriter holds back a successful JSON response so it can be tagged once the
+// handler is done. Anything else, and responses that are flushed or too large to hold, are
+// written straight through.
+type conditionalWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	passthrough bool
+}
+
+// Write writes the response, holding it back while it can still be tagged
+func (w *conditionalWriter) Write(data []byte) (int, error) {
+	if w.holding(len(data)) {
+		return w.body.Write(data)
+	}
+	w.release()
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes the response, holding it back while it can still be tagged
+func (w *conditionalWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends the response so far, giving up on tagging it
+func (w *conditionalWriter) Flush() {
+	w.release()
+	w.ResponseWriter.Flush()
+}
+
+// Written reports whether a response has been written, held back or not
+func (w *conditionalWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Unwrap returns the writer held back responses are released to, so http.ResponseController
+// can reach the connection to set deadlines
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// holding reports whether n more bytes of the response should be held back
+func (w *conditionalWriter) holding(n int) bool {
+	if w.passthrough || w.Status() != http.StatusOK || w.body.Len()+n > maxConditionalBody {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// release writes the held back response and stops holding any more back
+func (w *conditionalWriter) release() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCustomMetricRequest represents a request to define an organization's custom metric
+type SetCustomMetricRequest struct {
+	Formula     string `json:"formula" binding:"required"`
+	Description string `json:"description"`
+}
+
+// HandleListCustomMetrics handles listing the organization's custom metrics
+func (s *Server) HandleListCustomMetrics(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	metrics, err := s.metricService.ListMetrics(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list custom metrics: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metrics": metrics, "variables": ingestion.MetricVariables})
+}
+
+// HandleSetCustomMetric handles defining or replacing one of the organization's custom metrics
+func (s *Server) HandleSetCustomMetric(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	var req SetCustomMetricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	metric := &models.CustomMetric{OrgID: orgID, Name: c.Param("name"), Formula: req.Formula, Description: req.Description}
+	err := s.metricService.SetMetric(c, metric)
+	switch {
+	case errors.Is(err, services.ErrInvalidCustomMetric):
+		writeError(c, codedError(http.StatusBadRequest, err).WithDetail("variables", ingestion.MetricVariables))
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to set custom metric: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, metric)
+}
+
+// HandleDeleteCustomMetric handles removing one of the organization's custom metrics
+func (s *Server) HandleDeleteCustomMetric(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	err := s.metricService.DeleteMetric(c, orgID, c.Param("name"))
+	switch {
+	case errors.Is(err, services.ErrCustomMetricNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete custom metric: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
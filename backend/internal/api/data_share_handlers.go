@@ -0,0 +1,186 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateDataShareRequest represents a request to share some campaigns' delivery with a third party
+type CreateDataShareRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	CampaignIDs []string   `json:"campaignIds" binding:"required"`
+	From        string     `json:"from"` // YYYY-MM-DD
+	To          string     `json:"to"`   // YYYY-MM-DD
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// HandleListDataShares handles listing the org's data shares
+func (s *Server) HandleListDataShares(c *gin.Context) {
+	// Get org ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	shares, err := s.shareService.ListShares(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list data shares: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// HandleCreateDataShare handles minting a data share; the token is only included in this response
+func (s *Server) HandleCreateDataShare(c *gin.Context) {
+	// Get user and org IDs from context
+	userID := c.MustGet("userID").(string)
+	orgID := c.MustGet("orgID").(string)
+
+	var req CreateDataShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	from, err := parseOptionalDate("from", req.From)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	to, err := parseOptionalDate("to", req.To)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	share := &models.DataShare{
+		OrgID:       orgID,
+		UserID:      userID,
+		Name:        req.Name,
+		CampaignIDs: req.CampaignIDs,
+		From:        from,
+		To:          to,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	err = s.shareService.CreateShare(c, share)
+	switch {
+	case errors.Is(err, services.ErrInvalidDataShare):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create data share: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// HandleRevokeDataShare handles revoking one of the org's data shares
+func (s *Server) HandleRevokeDataShare(c *gin.Context) {
+	// Get org ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	err := s.shareService.RevokeShare(c, c.Param("id"), orgID)
+	switch {
+	case errors.Is(err, services.ErrDataShareNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to revoke data share: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetSharedScope handles describing what a share token can read
+func (s *Server) HandleGetSharedScope(c *gin.Context) {
+	share := c.MustGet("share").(*models.DataShare)
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":        share.Name,
+		"campaignIds": share.CampaignIDs,
+		"from":        share.From,
+		"to":          share.To,
+		"expiresAt":   share.ExpiresAt,
+	})
+}
+
+// HandleGetSharedCampaignRollup handles rolling up a shared campaign's delivery
+func (s *Server) HandleGetSharedCampaignRollup(c *gin.Context) {
+	share := c.MustGet("share").(*models.DataShare)
+
+	// Parse optional flight dates
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rollup, err := s.shareService.CampaignRollup(c, share, c.Param("id"), from, to)
+	switch {
+	case errors.Is(err, services.ErrCampaignNotShared):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrOutsideShareRange):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get campaign rollup: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rollup)
+}
+
+// HandleGetSharedCampaignRollups handles reading a page of a shared campaign's hourly or daily rollups
+func (s *Server) HandleGetSharedCampaignRollups(c *gin.Context) {
+	share := c.MustGet("share").(*models.DataShare)
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Parse keyset pagination parameters
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var page *pageWriter
+	next, err := s.shareService.StreamCampaignRollups(c, share, c.Param("id"), c.DefaultQuery("grain", ingestion.RollupDaily), from, to, c.Query("cursor"), limit,
+		func(series *services.RollupSeries) (err error) {
+			page, err = newPageWriter(c, series, "buckets")
+			return err
+		},
+		func(rollup ingestion.Rollup) error {
+			return page.Write(rollup)
+		},
+	)
+	switch {
+	case page != nil && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to stream shared rollups", err)
+		return
+	case errors.Is(err, services.ErrCampaignNotShared):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrOutsideShareRange), errors.Is(err, services.ErrInvalidRollupQuery), errors.Is(err, services.ErrInvalidCursor):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get rollups: %v", err)
+		return
+	}
+
+	_ = page.Close(next)
+}
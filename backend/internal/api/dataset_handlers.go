@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateDatasetRequest represents a request to create a dataset
+type CreateDatasetRequest struct {
+	Name       string `json:"name" binding:"required"`
+	CampaignID string `json:"campaignId"`
+	Source     string `json:"source"`
+}
+
+// AppendDatasetFileRequest represents a request to append a file to a dataset
+type AppendDatasetFileRequest struct {
+	FileID string `json:"fileId" binding:"required"`
+}
+
+// HandleCreateDataset handles creating a dataset
+func (s *Server) HandleCreateDataset(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req CreateDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Create the dataset using the dataset service
+	dataset, err := s.datasetService.CreateDataset(c, userID.(string), req.Name, req.CampaignID, req.Source)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create dataset: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dataset)
+}
+
+// HandleListDatasets handles listing the user's datasets
+func (s *Server) HandleListDatasets(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	datasets, err := s.datasetService.ListDatasets(c, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list datasets: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"datasets": datasets})
+}
+
+// HandleGetDataset handles retrieving a dataset with its merged summary
+func (s *Server) HandleGetDataset(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	dataset, err := s.datasetService.GetDataset(c, c.Param("id"), userID.(string))
+	if errors.Is(err, services.ErrDatasetNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get dataset: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dataset)
+}
+
+// HandleAppendDatasetFile handles appending a file to a dataset; the summary is updated in the background
+func (s *Server) HandleAppendDatasetFile(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req AppendDatasetFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := s.datasetService.AppendFile(c, c.Param("id"), req.FileID, userID.(string))
+	switch {
+	case errors.Is(err, services.ErrDatasetNotFound), errors.Is(err, services.ErrFileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrFileAlreadyAdded):
+		respondError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to append file: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"datasetId": c.Param("id"), "fileId": req.FileID, "status": "appending"})
+}
+
+// HandleRecomputeDataset handles re-parsing a dataset's files and rebuilding its summary in the background
+func (s *Server) HandleRecomputeDataset(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := s.datasetService.RecomputeDataset(c, c.Param("id"), userID.(string))
+	if errors.Is(err, services.ErrDatasetNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to recompute dataset: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"datasetId": c.Param("id"), "status": "recomputing"})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequeueDeadLettersRequest lists the dead-lettered jobs to requeue; all of them when All is set
+type RequeueDeadLettersRequest struct {
+	JobIDs []string `json:"jobIds"`
+	All    bool     `json:"all"`
+}
+
+// HandleListDeadLetters handles listing processing jobs that failed on every attempt
+func (s *Server) HandleListDeadLetters(c *gin.Context) {
+	jobs, err := s.deadLetterService.ListDeadLetters(c)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list dead-lettered jobs: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// HandleGetDeadLetter handles inspecting a dead-lettered job, including its error and stack
+func (s *Server) HandleGetDeadLetter(c *gin.Context) {
+	job, err := s.deadLetterService.GetDeadLetter(c, c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get dead-lettered job: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// HandleUpdateDeadLetter handles changing the priority and parse options a dead-lettered job is
+// requeued with
+func (s *Server) HandleUpdateDeadLetter(c *gin.Context) {
+	var req services.DeadLetterParameters
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	job, err := s.deadLetterService.UpdateDeadLetter(c, c.Param("id"), req)
+	switch {
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrInvalidPriority):
+		respondErrorf(c, http.StatusBadRequest, "priority must be low, normal or high")
+		return
+	case errors.Is(err, services.ErrInvalidParseOptions):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update dead-lettered job: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// HandleRequeueDeadLetters handles requeuing dead-lettered jobs in bulk
+func (s *Server) HandleRequeueDeadLetters(c *gin.Context) {
+	var req RequeueDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(req.JobIDs) == 0 && !req.All {
+		respondErrorf(c, http.StatusBadRequest, "jobIds is required unless all is set")
+		return
+	}
+	if req.All {
+		req.JobIDs = nil
+	}
+
+	result, err := s.deadLetterService.RequeueDeadLetters(c, req.JobIDs)
+	if err != nil {
+		writeError(c, apierror.Newf(http.StatusInternalServerError, "Failed to requeue dead-lettered jobs: %v", err).WithDetail("result", result))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
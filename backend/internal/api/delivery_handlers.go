@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportDeliveryReportRequest represents a request to import an uploaded file as a delivery report
+type ImportDeliveryReportRequest struct {
+	FileID string `json:"fileId" binding:"required"`
+}
+
+// HandleImportDeliveryReport handles importing an uploaded ad server delivery report
+func (s *Server) HandleImportDeliveryReport(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req ImportDeliveryReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	imported, err := s.deliveryService.ImportDeliveryReport(c, req.FileID, userID.(string))
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, ingestion.ErrInvalidDeliveryReport):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to import delivery report: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, imported)
+}
+
+// HandleGetDeliveryReconciliation handles retrieving DSP wins reconciled against ad server delivery
+func (s *Server) HandleGetDeliveryReconciliation(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Parse optional discrepancy threshold, in percent
+	threshold := services.DefaultDiscrepancyThreshold
+	if value := c.Query("threshold"); value != "" {
+		threshold, err = strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			respondErrorf(c, http.StatusBadRequest, "Invalid 'threshold', expected a non-negative percentage")
+			return
+		}
+	}
+
+	report, err := s.deliveryService.GetDeliveryReconciliation(c, userID.(string), from, to, threshold)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to reconcile delivery: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
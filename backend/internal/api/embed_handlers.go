@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/reportgen"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEmbedRequest represents a request to share a chart of a processed file
+type CreateEmbedRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Widget    string     `json:"widget" binding:"required"`
+	FileID    string     `json:"fileId" binding:"required"`
+	Metric    string     `json:"metric"`
+	Limit     int        `json:"limit"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// HandleListEmbeds handles listing the user's embeds
+func (s *Server) HandleListEmbeds(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	embeds, err := s.embedService.ListEmbeds(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list embeds: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"embeds": embeds})
+}
+
+// HandleCreateEmbed handles creating an embed, returning its token this once
+func (s *Server) HandleCreateEmbed(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateEmbedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	embed := &models.Embed{
+		UserID:    userID,
+		Name:      req.Name,
+		Widget:    req.Widget,
+		FileID:    req.FileID,
+		Metric:    req.Metric,
+		Limit:     req.Limit,
+		ExpiresAt: req.ExpiresAt,
+	}
+	err := s.embedService.CreateEmbed(c, embed)
+	switch {
+	case errors.Is(err, services.ErrInvalidEmbed):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrFileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create embed: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, embed)
+}
+
+// HandleRevokeEmbed handles revoking one of the user's embeds
+func (s *Server) HandleRevokeEmbed(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.embedService.RevokeEmbed(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrEmbedNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to revoke embed: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetEmbedChart handles fetching an embed's chart by its token, as chart-ready JSON or,
+// with format=svg, a rendered image
+func (s *Server) HandleGetEmbedChart(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "svg" {
+		respondErrorf(c, http.StatusBadRequest, "format must be json or svg")
+		return
+	}
+
+	chart, err := s.embedService.GetEmbedChart(c, c.Param("token"))
+	switch {
+	case errors.Is(err, services.ErrEmbedNotFound), errors.Is(err, ingestion.ErrAnalysisNotFound):
+		respondError(c, http.StatusNotFound, services.ErrEmbedNotFound)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get embed chart: %v", err)
+		return
+	}
+
+	// Charts are cached briefly, so a revoked embed stops showing within minutes
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if format == "json" {
+		c.JSON(http.StatusOK, chart)
+		return
+	}
+
+	c.Header("Content-Type", "image/svg+xml")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.Status(http.StatusOK)
+	if err := reportgen.WriteSVG(c.Writer, chart); err != nil {
+		errreport.Report(requestContext(c), "Failed to write embed chart", err)
+	}
+}
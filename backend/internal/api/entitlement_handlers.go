@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireFeature rejects requests with 403 when the org's plan doesn't include a feature, so
+// gated routes don't each check the plan
+func (s *Server) RequireFeature(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.MustGet("orgID").(string)
+
+		err := s.entitlementService.RequireFeature(c, orgID, feature)
+		switch {
+		case errors.Is(err, services.ErrFeatureNotInPlan):
+			writeError(c, codedError(http.StatusForbidden, err).WithDetail("feature", feature))
+			return
+		case err != nil:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to check plan: %v", err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UploadAllowanceMiddleware rejects uploads with 429 once the org has made its plan's uploads
+// for the day. Handlers taking several files read how many are left from "uploadsRemaining",
+// -1 when the plan doesn't limit uploads.
+func (s *Server) UploadAllowanceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.MustGet("orgID").(string)
+
+		remaining, err := s.entitlementService.UploadsRemaining(c, orgID)
+		switch {
+		case errors.Is(err, services.ErrDailyUploadLimit):
+			respondError(c, http.StatusTooManyRequests, err)
+			return
+		case err != nil:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to check plan: %v", err)
+			return
+		}
+
+		c.Set("uploadsRemaining", remaining)
+		c.Next()
+	}
+}
+
+// HandleGetEntitlements handles getting what the org's plan allows, so the frontend can show
+// which features are gated and how many uploads are left today
+func (s *Server) HandleGetEntitlements(c *gin.Context) {
+	// Get org ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	entitlements, err := s.entitlementService.Entitlements(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get entitlements: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entitlements)
+}
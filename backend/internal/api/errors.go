@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// errorCodes are the codes of service errors clients may want to tell apart from others with
+// the same status; the first match wins, so more specific errors come first
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{services.ErrAPICallLimit, apierror.CodeAPICallLimit},
+	{services.ErrRowLimit, apierror.CodeRowLimit},
+	{services.ErrStorageLimit, apierror.CodeStorageLimit},
+	{storage.ErrDecompressionLimit, apierror.CodeDecompressionLimit},
+	{services.ErrDailyUploadLimit, apierror.CodeDailyUploadLimit},
+	{services.ErrFeatureNotInPlan, apierror.CodeFeatureNotInPlan},
+	{services.ErrJobActive, apierror.CodeAlreadyQueued},
+	{ingestion.ErrUnknownLogFormat, apierror.CodeUnknownLogFormat},
+	{services.ErrInvalidParseOptions, apierror.CodeInvalidParseOptions},
+	{services.ErrInvalidShareToken, apierror.CodeInvalidToken},
+	{services.ErrInvalidAPIKey, apierror.CodeInvalidToken},
+	{services.ErrInvalidDownloadToken, apierror.CodeInvalidToken},
+	{services.ErrSessionRevoked, apierror.CodeSessionRevoked},
+}
+
+// requestIDPattern is what a client's own X-Request-ID must look like to be kept
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware gives each request an ID, echoed in the X-Request-ID header and in error
+// responses, and tagged on errors reported while handling it, so a client's failed request can
+// be found in the logs. A client may pick the ID by sending the header itself.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set("requestID", requestID)
+		c.Header("X-Request-ID", requestID)
+		c.Request = c.Request.WithContext(errreport.WithTags(c.Request.Context(), "requestID", requestID))
+		c.Next()
+	}
+}
+
+// useJSONFieldNames makes binding errors name fields as they're sent, by their JSON names
+func useJSONFieldNames() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// writeError responds with an error, its messages translated into the request's language, and
+// aborts the request so no later handlers run
+func writeError(c *gin.Context, apiErr *apierror.Error) {
+	response := localizeError(c, apiErr)
+	response.RequestID = c.GetString("requestID")
+	c.AbortWithStatusJSON(response.Status, gin.H{"error": response})
+}
+
+// localizeError copies an error response with its messages translated into the request's language
+func localizeError(c *gin.Context, apiErr *apierror.Error) *apierror.Error {
+	l := localizer(c)
+	response := *apiErr
+	response.Message = l.T(apiErr.Message)
+	response.Fields = make([]apierror.FieldError, len(apiErr.Fields))
+	for i, field := range apiErr.Fields {
+		response.Fields[i] = apierror.FieldError{Field: field.Field, Message: l.T(field.Message)}
+	}
+	return &response
+}
+
+// respondError responds with an error, coded by the service error it is when clients may want to
+// tell it apart and otherwise by the status
+func respondError(c *gin.Context, status int, err error) {
+	writeError(c, codedError(status, err))
+}
+
+// codedError makes an error response of an error, coded as respondError does
+func codedError(status int, err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return apierror.New(status, known.code, err.Error())
+		}
+	}
+	return apierror.New(status, "", err.Error())
+}
+
+// respondErrorf responds with an error coded by the status, with a formatted message
+func respondErrorf(c *gin.Context, status int, format string, args ...any) {
+	writeError(c, apierror.Newf(status, format, args...))
+}
+
+// respondBindError responds with why a request body couldn't be bound, naming each invalid field
+func respondBindError(c *gin.Context, err error) {
+	apiErr := apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		apiErr.Message = "Invalid request"
+		for _, fieldErr := range validationErrs {
+			apiErr.WithField(fieldPath(fieldErr.Namespace()), fieldMessage(fieldErr))
+		}
+	case errors.As(err, &typeErr):
+		apiErr.Message = "Invalid request"
+		apiErr.WithField(typeErr.Field, fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value))
+	}
+
+	writeError(c, apiErr)
+}
+
+// fieldPath drops the request type from a validation error's namespace, such as
+// CreateRequest.sections[0].type, leaving the path of the field in the body
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// fieldMessage explains a validation error
+func fieldMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "email":
+		return "must be an email address"
+	case "min", "max":
+		bound := "at least"
+		if fieldErr.Tag() == "max" {
+			bound = "at most"
+		}
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be %s %s characters", bound, fieldErr.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, fieldErr.Param())
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	default:
+		return fmt.Sprintf("failed the %s check", fieldErr.Tag())
+	}
+}
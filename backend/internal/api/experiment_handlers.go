@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateExperimentRequest represents a request to define an experiment; from and to are
+// YYYY-MM-DD dates, inclusive, and confidence defaults to 0.95
+type CreateExperimentRequest struct {
+	Name       string                 `json:"name" binding:"required"`
+	Dimension  string                 `json:"dimension" binding:"required"`
+	Control    models.ExperimentGroup `json:"control"`
+	Variant    models.ExperimentGroup `json:"variant"`
+	From       string                 `json:"from" binding:"required"`
+	To         string                 `json:"to" binding:"required"`
+	Confidence float64                `json:"confidence"`
+}
+
+// HandleListExperiments handles listing the current user's experiments
+func (s *Server) HandleListExperiments(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	experiments, err := s.experimentService.ListExperiments(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list experiments: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments})
+}
+
+// HandleCreateExperiment handles defining an experiment, which is analyzed straight away
+func (s *Server) HandleCreateExperiment(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	from, err := parseOptionalDate("from", req.From)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	to, err := parseOptionalDate("to", req.To)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	experiment := &models.Experiment{
+		UserID:     userID,
+		Name:       req.Name,
+		Dimension:  req.Dimension,
+		Control:    req.Control,
+		Variant:    req.Variant,
+		From:       *from,
+		To:         *to,
+		Confidence: req.Confidence,
+	}
+	err = s.experimentService.CreateExperiment(c, experiment)
+	switch {
+	case errors.Is(err, services.ErrInvalidExperiment):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create experiment: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, experiment)
+}
+
+// HandleGetExperiment handles retrieving an experiment with the result of its latest analysis
+func (s *Server) HandleGetExperiment(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	experiment, err := s.experimentService.GetExperiment(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrExperimentNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get experiment: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// HandleAnalyzeExperiment handles analyzing an experiment again with the delivery ingested since
+func (s *Server) HandleAnalyzeExperiment(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	experiment, err := s.experimentService.AnalyzeExperiment(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrExperimentNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to analyze experiment: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// HandleDeleteExperiment handles removing an experiment
+func (s *Server) HandleDeleteExperiment(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.experimentService.DeleteExperiment(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrExperimentNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete experiment: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
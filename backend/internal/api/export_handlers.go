@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateExportDestinationRequest represents a request to export the user's data to a warehouse.
+// Settings and credentials depend on the provider; credentials are encrypted and never returned.
+type CreateExportDestinationRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Provider    string            `json:"provider" binding:"required"`
+	Settings    map[string]string `json:"settings"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+// HandleListExportDestinations handles listing the user's export destinations
+func (s *Server) HandleListExportDestinations(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	destinations, err := s.exportService.ListDestinations(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list export destinations: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"destinations": destinations})
+}
+
+// HandleCreateExportDestination handles adding an export destination, which starts its first sync
+func (s *Server) HandleCreateExportDestination(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateExportDestinationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	destination := &models.ExportDestination{
+		UserID:   userID,
+		Name:     req.Name,
+		Provider: req.Provider,
+		Settings: req.Settings,
+	}
+	err := s.exportService.CreateDestination(c, destination, req.Credentials)
+	switch {
+	case errors.Is(err, services.ErrInvalidExportDestination):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrExportUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create export destination: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, destination)
+}
+
+// HandleSyncExportDestination handles exporting to a destination now rather than on schedule
+func (s *Server) HandleSyncExportDestination(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.exportService.SyncDestination(c, c.Param("id"), userID)
+	if errors.Is(err, services.ErrExportDestinationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to sync export destination: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"destinationId": c.Param("id"), "status": models.ExportTableSyncing})
+}
+
+// HandleListExportTables handles reporting the sync status of each table exported to a destination
+func (s *Server) HandleListExportTables(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	tables, err := s.exportService.ListTableSyncs(c, c.Param("id"), userID)
+	if errors.Is(err, services.ErrExportDestinationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list export tables: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"destinationId": c.Param("id"), "tables": tables})
+}
+
+// HandleGetExportTable handles reporting the sync status of one table exported to a destination
+func (s *Server) HandleGetExportTable(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	table, err := s.exportService.GetTableSync(c, c.Param("id"), userID, c.Param("table"))
+	if errors.Is(err, services.ErrExportDestinationNotFound) || errors.Is(err, services.ErrExportTableNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get export table: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, table)
+}
+
+// HandleDeleteExportDestination handles removing an export destination; exported data stays in the warehouse
+func (s *Server) HandleDeleteExportDestination(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.exportService.DeleteDestination(c, c.Param("id"), userID)
+	if errors.Is(err, services.ErrExportDestinationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete export destination: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
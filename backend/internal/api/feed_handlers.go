@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKeyRequest represents a request to create an API key for a BI tool
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// HandleListAPIKeys handles listing the user's API keys
+func (s *Server) HandleListAPIKeys(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	keys, err := s.apiKeyService.ListKeys(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list API keys: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apiKeys": keys})
+}
+
+// HandleCreateAPIKey handles creating an API key; the key is only included in this response
+func (s *Server) HandleCreateAPIKey(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	key := &models.APIKey{
+		UserID: userID,
+		Name:   req.Name,
+	}
+	err := s.apiKeyService.CreateKey(c, key)
+	switch {
+	case errors.Is(err, services.ErrInvalidAPIKeyRequest):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create API key: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// HandleRevokeAPIKey handles revoking one of the user's API keys
+func (s *Server) HandleRevokeAPIKey(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.apiKeyService.RevokeKey(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to revoke API key: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleListFeedReports handles listing the metrics feed's reports with their schemas
+func (s *Server) HandleListFeedReports(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"reports": s.feedService.Reports()})
+}
+
+// HandleGetFeedReport handles reading a page of a metrics feed report. The response carries the
+// report's schema ahead of its rows, so connectors can check it before reading on.
+func (s *Server) HandleGetFeedReport(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Parse keyset pagination parameters
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var page *pageWriter
+	next, err := s.feedService.StreamReport(c, userID, c.Param("report"), from, to, c.Query("cursor"), limit,
+		func(report *services.FeedReport) (err error) {
+			page, err = newPageWriter(c, report, "rows")
+			return err
+		},
+		func(row services.FeedRow) error {
+			return page.Write(row)
+		},
+	)
+	switch {
+	case page != nil && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to stream feed report", err)
+		return
+	case errors.Is(err, services.ErrFeedReportNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrInvalidCursor):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get feed report: %v", err)
+		return
+	}
+
+	_ = page.Close(next)
+}
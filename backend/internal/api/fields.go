@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// errInvalidFields is returned when a request's ?fields= isn't a list of field paths
+var errInvalidFields = errors.New("invalid fields")
+
+// fieldSet is the fields of a response to keep, by name. A field mapped to nil is kept whole;
+// otherwise only the listed fields within it are.
+type fieldSet map[string]fieldSet
+
+// parseFields parses a comma-separated list of dot-separated field paths, such as
+// "fileId,summary.campaignPerformance", into the set of fields to keep
+func parseFields(fields string) (fieldSet, error) {
+	set := fieldSet{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		names := strings.Split(path, ".")
+		if slices.Contains(names, "") {
+			return nil, fmt.Errorf("%w: %s", errInvalidFields, path)
+		}
+		level := set
+		for i, name := range names {
+			sub, listed := level[name]
+			if listed && sub == nil {
+				// The field is already kept whole, along with whatever this path picks from it
+				break
+			}
+			if i == len(names)-1 {
+				level[name] = nil
+				break
+			}
+			if !listed {
+				sub = fieldSet{}
+				level[name] = sub
+			}
+			level = sub
+		}
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("%w: no fields listed", errInvalidFields)
+	}
+	return set, nil
+}
+
+// selectFields narrows a response to the fields in set, applying the set to each element of an
+// array. Fields the response doesn't have are skipped, since optional fields are left out of
+// responses that have nothing to put in them.
+func selectFields(body any, set fieldSet) (any, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	// Keep numbers as they were written rather than rounding large ones through float64
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return set.apply(value), nil
+}
+
+// apply keeps the fields of value in the set
+func (set fieldSet) apply(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		selected := make(map[string]any, len(set))
+		for name, sub := range set {
+			field, ok := value[name]
+			if !ok {
+				continue
+			}
+			if sub != nil {
+				field = sub.apply(field)
+			}
+			selected[name] = field
+		}
+		return selected
+	case []any:
+		for i, element := range value {
+			value[i] = set.apply(element)
+		}
+		return value
+	default:
+		return value
+	}
+}
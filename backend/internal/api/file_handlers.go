@@ -1,12 +1,30 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
-
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/i18n"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
 )
 
+// uploadTimeout bounds reading an upload body and writing the response; large files take a while
+const uploadTimeout = 30 * time.Minute
+
 // FileUploadResponse represents the response from a file upload
 type FileUploadResponse struct {
 	ID       string `json:"id"`
@@ -14,6 +32,8 @@ type FileUploadResponse struct {
 	FileSize int64  `json:"fileSize"`
 	FileType string `json:"fileType"`
 	Status   string `json:"status"`
+	JobID    string `json:"jobId,omitempty"`
+	Priority string `json:"priority,omitempty"`
 }
 
 // HandleFileUpload handles the upload of a file
@@ -21,38 +41,67 @@ func (s *Server) HandleFileUpload(c *gin.Context) {
 	// Get user ID from context (set by AuthMiddleware)
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	// Parse multipart form with 50MB max memory
-	if err := c.Request.ParseMultipartForm(50 << 20); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to parse form: %v", err)})
+	// Repeated requests with the same Idempotency-Key return the original upload
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > 255 {
+		respondErrorf(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
 		return
 	}
 
-	// Get the file from the request
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to get file: %v", err)})
+	// Interactive uploads can jump ahead of bulk backfills with ?priority=high
+	priority, ok := uploadPriority(c)
+	if !ok {
 		return
 	}
-	defer file.Close()
 
-	// Upload the file using the file service
-	fileInfo, err := s.fileService.UploadFile(c, file, header, userID.(string))
+	// Large uploads outlast the server's default timeouts
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetReadDeadline(time.Now().Add(uploadTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(uploadTimeout))
+
+	// Reject bodies over the upload limit while streaming; the multipart framing adds a little overhead
+	maxSize := s.fileService.MaxUploadSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	// Find the file part without buffering the body
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file: %v", err)})
+		respondErrorf(c, http.StatusBadRequest, "Failed to parse form: %v", err)
 		return
 	}
+	part, err := nextFilePart(reader, "file")
+	if err != nil {
+		if isTooLarge(err) {
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "File size exceeds the maximum allowed size of %dMB", maxSize>>20)
+			return
+		}
+		respondErrorf(c, http.StatusBadRequest, "Failed to get file: %v", err)
+		return
+	}
+	defer part.Close()
 
-	// Process the log file asynchronously
-	go func() {
-		// Create a new context for processing since the request context will be canceled
-		if err := s.fileService.ProcessLogFile(c.Request.Context(), fileInfo.ID, userID.(string)); err != nil {
-			fmt.Printf("Error processing log file: %v\n", err)
+	// Stream the file into storage using the file service
+	fileInfo, err := s.fileService.UploadFile(c, part, part.FileName(), part.Header.Get("Content-Type"), userID.(string), idempotencyKey, priority)
+	if err != nil {
+		if isTooLarge(err) {
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "File size exceeds the maximum allowed size of %dMB", maxSize>>20)
+			return
 		}
-	}()
+		respondErrorf(c, http.StatusInternalServerError, "Failed to upload file: %v", err)
+		return
+	}
+
+	if fileInfo.Replayed {
+		// The earlier request already queued processing
+		c.Header("Idempotent-Replayed", "true")
+	} else if err := s.fileService.SubmitProcessingJob(fileInfo.JobID, fileInfo.ID, userID.(string), fileInfo.Priority); err != nil {
+		// Process the log file asynchronously; if the server is shutting down the job stays queued for the next start
+		errreport.Report(errreport.WithTags(requestContext(c), "jobID", fileInfo.JobID, "fileID", fileInfo.ID), "Failed to submit processing job", err)
+	}
 
 	// Return the file information
 	c.JSON(http.StatusOK, FileUploadResponse{
@@ -61,6 +110,8 @@ func (s *Server) HandleFileUpload(c *gin.Context) {
 		FileSize: fileInfo.FileSize,
 		FileType: fileInfo.FileType,
 		Status:   fileInfo.Status,
+		JobID:    fileInfo.JobID,
+		Priority: fileInfo.Priority,
 	})
 }
 
@@ -69,31 +120,106 @@ func (s *Server) HandleGetFile(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get file ID from route params
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Get the file using the file service
 	file, fileInfo, err := s.fileService.GetFile(c, fileID, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Failed to get file: %v", err)})
+		respondErrorf(c, http.StatusNotFound, "Failed to get file: %v", err)
+		return
+	}
+	defer file.Close()
+
+	serveFile(c, file, fileInfo)
+}
+
+// HandleCreateDownloadURL handles signing a short-lived URL that downloads a file without a
+// session, for handing large downloads to the browser
+func (s *Server) HandleCreateDownloadURL(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	token, expiresAt, err := s.fileService.SignDownload(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to sign download URL: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"url":       "/api/" + c.GetString("apiVersion") + "/downloads/" + token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// HandleSignedDownload handles downloading a file by a signed download URL's token
+func (s *Server) HandleSignedDownload(c *gin.Context) {
+	file, fileInfo, err := s.fileService.OpenSignedDownload(c, c.Param("token"))
+	switch {
+	case errors.Is(err, services.ErrDownloadTokenExpired):
+		writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeTokenExpired, "Download URL expired"))
+		return
+	case errors.Is(err, services.ErrInvalidDownloadToken):
+		respondError(c, http.StatusUnauthorized, err)
+		return
+	case errors.Is(err, storage.ErrFileNotFound):
+		respondErrorf(c, http.StatusNotFound, "File not found")
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get file: %v", err)
 		return
 	}
 	defer file.Close()
 
+	// The URL is the credential, so the file mustn't outlive it in shared caches
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	serveFile(c, file, fileInfo)
+}
+
+// serveFile streams a stored file to the response as an attachment. Range requests are
+// answered with just the bytes asked for, so an interrupted download can resume where it
+// stopped; HEAD requests get the headers alone, for download managers to size up the file.
+func serveFile(c *gin.Context, file *os.File, fileInfo *services.FileUploadInfo) {
 	// Set content type and attachment headers
 	c.Header("Content-Type", fileInfo.FileType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.FileName))
+	setAttachment(c, fileInfo.FileName)
+	// Uploads never change, so the file ID is a strong validator; If-Range can name it to
+	// resume only if the file is the one partly downloaded
+	c.Header("ETag", `"`+fileInfo.ID+`"`)
+
+	// Stream the file to the response. A multi-GB download outlasts the server's write
+	// timeout, so it's only cut off once the client stops reading.
+	reader := &idleDeadlineReader{ReadSeeker: file, controller: http.NewResponseController(c.Writer)}
+	http.ServeContent(c.Writer, c.Request, fileInfo.FileName, fileInfo.UploadedAt, reader)
+}
 
-	// Stream the file to the response
-	http.ServeContent(c.Writer, c.Request, fileInfo.FileName, fileInfo.UploadedAt, file)
+// downloadIdleTimeout is how long sending part of a download may take before it's cut off
+const downloadIdleTimeout = time.Minute
+
+// idleDeadlineReader pushes back a response's write deadline each time more of the file being
+// sent is read, so the deadline bounds how long the client stalls rather than the download
+type idleDeadlineReader struct {
+	io.ReadSeeker
+	controller *http.ResponseController
+}
+
+// Read reads the next part of the file, giving the client another downloadIdleTimeout to take it
+func (r *idleDeadlineReader) Read(p []byte) (int, error) {
+	_ = r.controller.SetWriteDeadline(time.Now().Add(downloadIdleTimeout))
+	return r.ReadSeeker.Read(p)
 }
 
 // HandleDeleteFile handles deleting a file by ID
@@ -101,39 +227,141 @@ func (s *Server) HandleDeleteFile(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get file ID from route params
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Delete the file using the file service
 	if err := s.fileService.DeleteFile(c, fileID, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete file: %v", err)})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete file: %v", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
 }
 
+// HandleCancelProcessing handles canceling a file's queued or running processing job
+func (s *Server) HandleCancelProcessing(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	if err := s.fileService.CancelProcessing(c, c.Param("id"), userID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileNotFound):
+			respondErrorf(c, http.StatusNotFound, "File not found")
+		case errors.Is(err, services.ErrNoActiveJob):
+			respondErrorf(c, http.StatusConflict, "File has no queued or running processing job")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to cancel processing: %v", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetJob handles retrieving a processing job's status and priority
+func (s *Server) HandleGetJob(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	job, err := s.fileService.GetJob(c, c.Param("id"), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Job not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get job: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, localizeJob(localizer(c), job))
+}
+
+// localizeAnalysis returns a copy of analysis results for a client, without the reach sketches
+// and with the reason parsing failed translated
+func localizeAnalysis(l *i18n.Localizer, result *ingestion.LogAnalysisResult) *ingestion.LogAnalysisResult {
+	localized := result.WithoutSketches()
+	localized.ErrorMessage = l.T(result.ErrorMessage)
+	return localized
+}
+
+// localizeJob returns a copy of a job with its error translated
+func localizeJob(l *i18n.Localizer, job *models.ProcessingJob) *models.ProcessingJob {
+	localized := *job
+	localized.Error = l.T(job.Error)
+	return &localized
+}
+
+// jobEventWriteTimeout bounds sending one job event to a websocket client
+const jobEventWriteTimeout = 10 * time.Second
+
+// HandleJobEvents handles streaming a processing job's status and progress over a websocket.
+// The job is sent as JSON whenever it changes, and the connection closes once it finishes.
+func (s *Server) HandleJobEvents(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+	jobID := c.Param("id")
+
+	// Check the job before upgrading, so an unknown job gets a plain 404
+	if _, err := s.fileService.GetJob(c, jobID, userID); err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Job not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get job: %v", err)
+		return
+	}
+
+	// Any origin may connect: the token authenticates the request, not cookies a page could ride on
+	ctx := requestContext(c)
+	l := localizer(c)
+	server := websocket.Server{Handshake: func(*websocket.Config, *http.Request) error { return nil }}
+	server.Handler = func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		// The connection outlives the server's request timeouts; stop when the client goes away
+		_ = conn.SetDeadline(time.Time{})
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer cancel()
+			var discard string
+			for websocket.Message.Receive(conn, &discard) == nil {
+			}
+		}()
+
+		err := s.fileService.WatchJob(ctx, jobID, userID, func(job *models.ProcessingJob) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(jobEventWriteTimeout))
+			return websocket.JSON.Send(conn, localizeJob(l, job))
+		})
+		if err != nil && ctx.Err() == nil {
+			errreport.Report(errreport.WithTags(ctx, "jobID", jobID), "Failed to stream job events", err)
+		}
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
 // HandleListFiles handles listing all files for a user
 func (s *Server) HandleListFiles(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	// List files using the file service
 	files, err := s.fileService.ListUserFiles(c, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list files: %v", err)})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list files: %v", err)
 		return
 	}
 
@@ -157,20 +385,20 @@ func (s *Server) HandleProcessFile(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get file ID from route params
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Process the file using the file service
-	if err := s.fileService.ProcessLogFile(c, fileID, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
+	if _, err := s.fileService.ProcessLogFile(c, fileID, userID.(string)); err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to process file: %v", err)
 		return
 	}
 
@@ -182,20 +410,20 @@ func (s *Server) HandleAnalyzeFile(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get file ID from route params
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Analyze the file using the file service
 	if err := s.fileService.AnalyzeLogFile(c, fileID, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to analyze file: %v", err)})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to analyze file: %v", err)
 		return
 	}
 
@@ -207,26 +435,26 @@ func (s *Server) ProcessFile(c *gin.Context) {
 	// Get the file ID from the URL parameter
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Get the user ID from the JWT token
-	userID, exists := c.Get("userId")
+	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		respondErrorf(c, http.StatusUnauthorized, "User ID not found in token")
 		return
 	}
 
 	// Process the file
 	result, err := s.fileService.ProcessLogFile(c.Request.Context(), fileID, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to process file: %v", err)})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to process file: %v", err)
 		return
 	}
 
 	// Return the result
-	c.JSON(http.StatusOK, result)
+	respond(c, http.StatusOK, localizeAnalysis(localizer(c), result))
 }
 
 // GetFileAnalysis handles the request to retrieve analysis results for a file
@@ -234,24 +462,191 @@ func (s *Server) GetFileAnalysis(c *gin.Context) {
 	// Get the file ID from the URL parameter
 	fileID := c.Param("id")
 	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
 		return
 	}
 
 	// Get the user ID from the JWT token
-	userID, exists := c.Get("userId")
+	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		respondErrorf(c, http.StatusUnauthorized, "User ID not found in token")
 		return
 	}
 
-	// Get the analysis results
-	result, err := s.fileService.GetLogAnalysisResult(c.Request.Context(), fileID, userID.(string))
+	// Get the analysis results, or an earlier version of them when one is asked for
+	var result *ingestion.LogAnalysisResult
+	var err error
+	if v := c.Query("version"); v != "" {
+		version, convErr := strconv.Atoi(v)
+		if convErr != nil || version < 1 {
+			respondErrorf(c, http.StatusBadRequest, "version must be a positive integer")
+			return
+		}
+		result, err = s.fileService.GetAnalysisVersion(c.Request.Context(), fileID, userID.(string), version)
+	} else {
+		result, err = s.fileService.GetLogAnalysisResult(c.Request.Context(), fileID, userID.(string))
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get analysis results: %v", err)})
+		if errors.Is(err, ingestion.ErrAnalysisNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Analysis not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get analysis results: %v", err)
 		return
 	}
 
-	// Return the result
-	c.JSON(http.StatusOK, result)
+	// Return the result, dated so clients can ask whether it changed since they fetched it
+	c.Header("Last-Modified", result.ProcessedAt.UTC().Format(http.TimeFormat))
+	respond(c, http.StatusOK, localizeAnalysis(localizer(c), result))
+}
+
+// HandleListAnalysisVersions handles listing the versions of a file's analysis
+func (s *Server) HandleListAnalysisVersions(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	versions, err := s.fileService.ListAnalysisVersions(c, c.Param("id"), userID)
+	if err != nil {
+		if errors.Is(err, ingestion.ErrAnalysisNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Analysis not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list analysis versions: %v", err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"versions": versions})
+}
+
+// ReprocessRequest optionally overrides how a file is parsed when it is reprocessed
+type ReprocessRequest struct {
+	Parser string `json:"parser"` // csv, openrtb or prebid
+	Format string `json:"format"` // A registered log format, such as beeswax
+}
+
+// HandleReprocessFile handles queuing a file to be parsed again into a new analysis version
+func (s *Server) HandleReprocessFile(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// The body is optional; without it the file is parsed with the detected parser and format
+	var req ReprocessRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	priority, ok := uploadPriority(c)
+	if !ok {
+		return
+	}
+
+	job, err := s.fileService.QueueReprocess(c, c.Param("id"), userID, priority, ingestion.ParseOptions{Parser: req.Parser, Format: req.Format})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidParseOptions):
+			writeError(c, codedError(http.StatusBadRequest, err).WithDetail("formats", ingestion.LogSources()))
+		case errors.Is(err, services.ErrFileNotFound):
+			respondErrorf(c, http.StatusNotFound, "File not found")
+		case errors.Is(err, services.ErrJobActive):
+			writeError(c, apierror.New(http.StatusConflict, apierror.CodeAlreadyQueued, "File is already queued or being processed"))
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to queue reprocessing: %v", err)
+		}
+		return
+	}
+
+	if err := s.fileService.SubmitProcessingJob(job.ID, job.FileID, userID, job.Priority); err != nil {
+		// If the server is shutting down the job stays queued for the next start
+		errreport.Report(errreport.WithTags(requestContext(c), "jobID", job.ID, "fileID", job.FileID), "Failed to submit processing job", err)
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// HandleValidateFile handles a dry run of parsing the first rows of a CSV upload without storing
+// it. ?rows sets how many rows are checked, and ?parser and ?format override detection like a reprocess.
+// Only the head of the file is read, so clients can send just its first megabytes.
+func (s *Server) HandleValidateFile(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	rows := ingestion.DefaultSampleRows
+	if value := c.Query("rows"); value != "" {
+		var err error
+		rows, err = strconv.Atoi(value)
+		if err != nil || rows < 1 || rows > ingestion.MaxSampleRows {
+			respondErrorf(c, http.StatusBadRequest, "rows must be between 1 and %d", ingestion.MaxSampleRows)
+			return
+		}
+	}
+
+	// A sample is never larger than an upload could be
+	maxSize := s.fileService.MaxUploadSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to parse form: %v", err)
+		return
+	}
+	part, err := nextFilePart(reader, "file")
+	if err != nil {
+		respondErrorf(c, http.StatusBadRequest, "Failed to get file: %v", err)
+		return
+	}
+	defer part.Close()
+
+	opts := ingestion.ParseOptions{Parser: c.Query("parser"), Format: c.Query("format")}
+	validation, err := s.fileService.ValidateSample(c, part, part.FileName(), userID, opts, rows)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidParseOptions):
+			writeError(c, codedError(http.StatusBadRequest, err).WithDetail("formats", ingestion.LogSources()))
+		case isTooLarge(err):
+			respondErrorf(c, http.StatusRequestEntityTooLarge, "File size exceeds the maximum allowed size of %dMB", maxSize>>20)
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to validate file: %v", err)
+		}
+		return
+	}
+
+	validation.Error = localizer(c).T(validation.Error)
+	c.JSON(http.StatusOK, validation)
+}
+
+// nextFilePart skips form parts until the file part with the given field name
+func nextFilePart(reader *multipart.Reader, field string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no %s field in form", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// uploadPriority reads an upload's optional priority query parameter, responding with an error
+// when it isn't a job priority
+func uploadPriority(c *gin.Context) (string, bool) {
+	priority := c.Query("priority")
+	if priority != "" && !models.ValidJobPriority(priority) {
+		respondErrorf(c, http.StatusBadRequest, "priority must be low, normal or high")
+		return "", false
+	}
+	return priority, true
+}
+
+// isTooLarge reports whether an upload failed because it exceeded the size limit
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr) || errors.Is(err, storage.ErrFileTooLarge)
 }
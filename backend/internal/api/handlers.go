@@ -1,17 +1,32 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/bolognesandwiches/AdVantage/internal/health"
 	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
-// HandleHealthCheck handles the health check endpoint
+// HandleHealthCheck handles the liveness endpoint
 func (s *Server) HandleHealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// HandleReadinessCheck handles the readiness endpoint, reporting the status of each dependency
+func (s *Server) HandleReadinessCheck(c *gin.Context) {
+	report := s.health.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
+
 // RegisterRequest represents the request body for registration
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
@@ -24,18 +39,18 @@ type RegisterRequest struct {
 func (s *Server) HandleRegister(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Check if user already exists
 	exists, err := s.userService.ExistsByEmail(c, req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user existence"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to check user existence")
 		return
 	}
 	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		respondErrorf(c, http.StatusConflict, "User with this email already exists")
 		return
 	}
 
@@ -46,30 +61,26 @@ func (s *Server) HandleRegister(c *gin.Context) {
 		LastName:  req.LastName,
 	}
 	if err := user.SetPassword(req.Password); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
 	if err := s.userService.Create(c, user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	// Generate token
-	token, err := s.generateToken(user.ID)
+	// Generate tokens
+	session, token, err := s.startSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"user": map[string]interface{}{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-		},
-		"token": token,
+		"user":         userResponse(user),
+		"token":        token,
+		"refreshToken": session.RefreshToken,
 	})
 }
 
@@ -83,38 +94,34 @@ type LoginRequest struct {
 func (s *Server) HandleLogin(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	// Find user by email
 	user, err := s.userService.FindByEmail(c, req.Email)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		respondErrorf(c, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
 	// Verify password
 	if !user.CheckPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		respondErrorf(c, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
-	// Generate token
-	token, err := s.generateToken(user.ID)
+	// Generate tokens
+	session, token, err := s.startSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": map[string]interface{}{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-		},
-		"token": token,
+		"user":         userResponse(user),
+		"token":        token,
+		"refreshToken": session.RefreshToken,
 	})
 }
 
@@ -126,31 +133,31 @@ func (s *Server) HandleGetCurrentUser(c *gin.Context) {
 	// Find user by ID
 	user, err := s.userService.FindByID(c, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to find user")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": map[string]interface{}{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-		},
+		"user": userResponse(user),
 	})
 }
 
-// UpdateUserRequest represents the request body for updating a user
+// UpdateUserRequest represents the request body for updating a user. Profile fields that are
+// omitted are left unchanged; an empty string clears them.
 type UpdateUserRequest struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+	Company   *string `json:"company"`
+	Role      *string `json:"role"`
+	Timezone  *string `json:"timezone"`
+	Locale    *string `json:"locale"`
 }
 
 // HandleUpdateCurrentUser handles updating the current user
 func (s *Server) HandleUpdateCurrentUser(c *gin.Context) {
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -160,7 +167,7 @@ func (s *Server) HandleUpdateCurrentUser(c *gin.Context) {
 	// Find user by ID
 	user, err := s.userService.FindByID(c, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user"})
+		respondErrorf(c, http.StatusInternalServerError, "Failed to find user")
 		return
 	}
 
@@ -171,19 +178,49 @@ func (s *Server) HandleUpdateCurrentUser(c *gin.Context) {
 	if req.LastName != "" {
 		user.LastName = req.LastName
 	}
+	if req.Company != nil {
+		user.Company = *req.Company
+	}
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	if req.Timezone != nil {
+		user.Timezone = *req.Timezone
+	}
+	if req.Locale != nil {
+		user.Locale = *req.Locale
+	}
 
 	// Save user
 	if err := s.userService.Update(c, user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		if errors.Is(err, services.ErrInvalidProfile) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": map[string]interface{}{
-			"id":        user.ID,
-			"email":     user.Email,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-		},
+		"user": userResponse(user),
 	})
 }
+
+// userResponse is the representation of a user returned by the API
+func userResponse(user *models.User) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":        user.ID,
+		"orgId":     user.OrgID,
+		"email":     user.Email,
+		"firstName": user.FirstName,
+		"lastName":  user.LastName,
+		"company":   user.Company,
+		"role":      user.Role,
+		"timezone":  user.Timezone,
+		"locale":    user.Locale,
+	}
+	if user.AvatarID != "" {
+		response["avatarUrl"] = "/api/v1/avatars/" + user.AvatarID
+	}
+	return response
+}
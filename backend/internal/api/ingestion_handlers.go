@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListLogSources handles listing the supported log sources with the columns their
+// exports are read from, so users know what to export from their DSP
+func (s *Server) HandleListLogSources(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sources": ingestion.LogSourceDictionaries()})
+}
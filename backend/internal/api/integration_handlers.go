@@ -0,0 +1,283 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bolognesandwiches/AdVantage/internal/integrations"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ConnectGoogleAdsRequest represents a request to connect a Google Ads account
+type ConnectGoogleAdsRequest struct {
+	CustomerID string `json:"customerId" binding:"required"`
+}
+
+// HandleConnectGoogleAds handles starting the OAuth flow for a Google Ads account; the client
+// sends the user to the returned consent screen URL
+func (s *Server) HandleConnectGoogleAds(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req ConnectGoogleAdsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	authURL, err := s.integrationService.GoogleAdsAuthURL(userID.(string), req.CustomerID)
+	switch {
+	case errors.Is(err, services.ErrIntegrationUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	case errors.Is(err, integrations.ErrInvalidCustomerID):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to start connection: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authUrl": authURL})
+}
+
+// ConnectGA4Request represents a request to connect a Google Analytics 4 property
+type ConnectGA4Request struct {
+	PropertyID string `json:"propertyId" binding:"required"`
+}
+
+// HandleConnectGA4 handles starting the OAuth flow for a Google Analytics 4 property; the
+// client sends the user to the returned consent screen URL
+func (s *Server) HandleConnectGA4(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req ConnectGA4Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	authURL, err := s.integrationService.GA4AuthURL(userID.(string), req.PropertyID)
+	switch {
+	case errors.Is(err, services.ErrIntegrationUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	case errors.Is(err, integrations.ErrInvalidPropertyID):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to start connection: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authUrl": authURL})
+}
+
+// HandleConnectGoogleSheets handles starting the OAuth flow for a spreadsheet tab a report is
+// written to on every sync; the client sends the user to the returned consent screen URL
+func (s *Server) HandleConnectGoogleSheets(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req services.SheetReport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	authURL, err := s.integrationService.GoogleSheetsAuthURL(userID.(string), req)
+	switch {
+	case errors.Is(err, services.ErrIntegrationUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	case errors.Is(err, integrations.ErrInvalidSpreadsheet), errors.Is(err, services.ErrInvalidRollupQuery):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to start connection: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authUrl": authURL})
+}
+
+// HandleGoogleCallback handles the redirect back from Google's consent screen. It isn't
+// authenticated; the sealed state identifies the user who started the connection.
+func (s *Server) HandleGoogleCallback(c *gin.Context) {
+	if denied := c.Query("error"); denied != "" {
+		s.finishConnection(c, nil, fmt.Errorf("authorization denied: %s", denied))
+		return
+	}
+
+	integration, err := s.integrationService.CompleteGoogleConnection(c, c.Query("code"), c.Query("state"))
+	s.finishConnection(c, integration, err)
+}
+
+// finishConnection sends the browser back to the app after an OAuth flow, or responds with
+// JSON when no return URL is configured
+func (s *Server) finishConnection(c *gin.Context, integration *models.Integration, err error) {
+	status := http.StatusOK
+	switch {
+	case err == nil:
+	case errors.Is(err, integrations.ErrInvalidState):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrIntegrationUnavailable):
+		status = http.StatusServiceUnavailable
+	default:
+		status = http.StatusBadGateway
+	}
+
+	if s.config.Integrations.ReturnURL == "" {
+		if err != nil {
+			respondError(c, status, err)
+			return
+		}
+		c.JSON(http.StatusCreated, integration)
+		return
+	}
+
+	returnURL, parseErr := url.Parse(s.config.Integrations.ReturnURL)
+	if parseErr != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Invalid integrations return URL")
+		return
+	}
+	query := returnURL.Query()
+	if err != nil {
+		query.Set("error", err.Error())
+	} else {
+		query.Set("integrationId", integration.ID)
+	}
+	returnURL.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, returnURL.String())
+}
+
+// HandleListIntegrations handles listing the user's integrations
+func (s *Server) HandleListIntegrations(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	list, err := s.integrationService.ListIntegrations(c, userID.(string))
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list integrations: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"integrations": list})
+}
+
+// HandleSyncIntegration handles pulling an integration's reports now rather than on schedule
+func (s *Server) HandleSyncIntegration(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := s.integrationService.SyncIntegration(c, c.Param("id"), userID.(string))
+	if errors.Is(err, services.ErrIntegrationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to sync integration: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"integrationId": c.Param("id"), "status": "syncing"})
+}
+
+// HandleDeleteIntegration handles disconnecting an integration
+func (s *Server) HandleDeleteIntegration(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := s.integrationService.DeleteIntegration(c, c.Param("id"), userID.(string))
+	if errors.Is(err, services.ErrIntegrationNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete integration: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetChannelSpend handles retrieving daily spend per source across DSP logs and ad platforms
+func (s *Server) HandleGetChannelSpend(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := s.integrationService.GetChannelSpend(c, userID.(string), from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get channel spend: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleGetBlendedCPA handles retrieving cost per on-site conversion by campaign, joining DSP
+// and ad platform spend with GA4 outcomes
+func (s *Server) HandleGetBlendedCPA(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := s.integrationService.GetBlendedCPA(c, userID.(string), from, to)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get blended CPA: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
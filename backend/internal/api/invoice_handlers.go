@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportInvoiceRequest represents a request to import an uploaded file as a DSP invoice
+type ImportInvoiceRequest struct {
+	FileID string `json:"fileId" binding:"required"`
+}
+
+// HandleImportInvoice handles importing an uploaded DSP invoice or billing report
+func (s *Server) HandleImportInvoice(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req ImportInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	imported, err := s.invoiceService.ImportInvoice(c, req.FileID, userID.(string))
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, ingestion.ErrInvalidInvoice):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to import invoice: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, imported)
+}
+
+// HandleGetSpendReconciliation handles retrieving invoiced spend reconciled against logged spend
+func (s *Server) HandleGetSpendReconciliation(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Parse optional discrepancy threshold, in percent
+	threshold := services.DefaultSpendDiscrepancyThreshold
+	if value := c.Query("threshold"); value != "" {
+		threshold, err = strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			respondErrorf(c, http.StatusBadRequest, "Invalid 'threshold', expected a non-negative percentage")
+			return
+		}
+	}
+
+	report, err := s.invoiceService.GetSpendReconciliation(c, userID.(string), c.Query("source"), from, to, threshold)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to reconcile spend: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
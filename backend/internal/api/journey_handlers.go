@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleExportJourneys handles downloading a campaign's anonymized user journeys for a file,
+// as newline-delimited JSON with one journey per line or, with format=csv, one event per row
+func (s *Server) HandleExportJourneys(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		respondErrorf(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Get file ID from route params
+	fileID := c.Param("id")
+	if fileID == "" {
+		respondErrorf(c, http.StatusBadRequest, "File ID is required")
+		return
+	}
+
+	campaignID := c.Query("campaignId")
+	if campaignID == "" {
+		respondErrorf(c, http.StatusBadRequest, "campaignId is required")
+		return
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		respondErrorf(c, http.StatusBadRequest, "format must be ndjson or csv")
+		return
+	}
+
+	// CSV timestamps are shown in the user's time zone and date format; NDJSON keeps RFC 3339
+	var defaults *services.ReportDefaults
+	if format == "csv" {
+		var err error
+		defaults, err = s.preferencesService.ReportDefaults(c, userID.(string))
+		if err != nil {
+			respondErrorf(c, http.StatusInternalServerError, "Failed to get report defaults: %v", err)
+			return
+		}
+	}
+
+	// Headers are written with the first journey, so errors found up front still get a status
+	var (
+		encoder *json.Encoder
+		writer  *csv.Writer
+	)
+	start := func() {
+		filename := fmt.Sprintf("journeys_%s_%s.%s", fileID, campaignID, format)
+		setAttachment(c, filename)
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv")
+			writer = csv.NewWriter(c.Writer)
+			_ = writer.Write([]string{"user_id", "sequence", "event", "timestamp"})
+		} else {
+			c.Header("Content-Type", "application/x-ndjson")
+			encoder = json.NewEncoder(c.Writer)
+		}
+		c.Status(http.StatusOK)
+	}
+
+	started := false
+	err := s.journeyService.ExportJourneys(c, fileID, userID.(string), campaignID, func(journey *ingestion.Journey) error {
+		if !started {
+			start()
+			started = true
+		}
+		if encoder != nil {
+			return encoder.Encode(journey)
+		}
+		for i, event := range journey.Events {
+			if err := writer.Write([]string{journey.UserID, strconv.Itoa(i + 1), event.Type, defaults.FormatTimestamp(event.Timestamp)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case started && err != nil:
+		// The response is under way, so the error can only be reported
+		errreport.Report(errreport.WithTags(requestContext(c), "fileID", fileID), "Failed to export journeys", err)
+	case errors.Is(err, services.ErrReportUnavailable), errors.Is(err, services.ErrJourneysUnavailable):
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	case errors.Is(err, services.ErrCampaignNotDelivered):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to export journeys: %v", err)
+		return
+	case !started:
+		start()
+	}
+
+	if writer != nil {
+		writer.Flush()
+	}
+}
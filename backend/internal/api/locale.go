@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/bolognesandwiches/AdVantage/internal/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// LocaleMiddleware picks the language a request prefers from its Accept-Language header, which
+// error messages are translated into and handlers localize with
+func LocaleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		localizer := i18n.New(i18n.Match(c.GetHeader("Accept-Language")))
+		c.Set("localizer", localizer)
+		c.Header("Content-Language", localizer.Language())
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// localizer returns the request's localizer, set by LocaleMiddleware
+func localizer(c *gin.Context) *i18n.Localizer {
+	if value, ok := c.Get("localizer"); ok {
+		return value.(*i18n.Localizer)
+	}
+	return i18n.New(i18n.English)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateLogStreamRequest represents a request to stream a Kafka topic into the user's rollups
+type CreateLogStreamRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ProxyURL string `json:"proxyUrl" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Topic    string `json:"topic" binding:"required"`
+	GroupID  string `json:"groupId"`
+	Source   string `json:"source" binding:"required"`
+}
+
+// HandleListLogStreams handles listing the user's log streams
+func (s *Server) HandleListLogStreams(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	streams, err := s.logStreamService.ListStreams(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list log streams: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"streams": streams})
+}
+
+// HandleCreateLogStream handles creating a log stream, which starts consuming within a minute
+func (s *Server) HandleCreateLogStream(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req CreateLogStreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	stream := &models.LogStream{
+		UserID:   userID,
+		Name:     req.Name,
+		ProxyURL: req.ProxyURL,
+		Username: req.Username,
+		Topic:    req.Topic,
+		GroupID:  req.GroupID,
+		Source:   req.Source,
+	}
+	err := s.logStreamService.CreateStream(c, stream, req.Password)
+	switch {
+	case errors.Is(err, services.ErrInvalidLogStream):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrLogStreamExists):
+		respondError(c, http.StatusConflict, err)
+		return
+	case errors.Is(err, services.ErrLogStreamCredentials):
+		respondError(c, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create log stream: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, stream)
+}
+
+// HandlePauseLogStream handles stopping a log stream's consumer; committed offsets are kept
+func (s *Server) HandlePauseLogStream(c *gin.Context) {
+	s.setLogStreamPaused(c, true)
+}
+
+// HandleResumeLogStream handles restarting a paused log stream's consumer from its committed offsets
+func (s *Server) HandleResumeLogStream(c *gin.Context) {
+	s.setLogStreamPaused(c, false)
+}
+
+// setLogStreamPaused pauses or resumes the log stream named in the path
+func (s *Server) setLogStreamPaused(c *gin.Context, paused bool) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	stream, err := s.logStreamService.SetPaused(c, c.Param("id"), userID, paused)
+	if errors.Is(err, services.ErrLogStreamNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update log stream: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stream)
+}
+
+// HandleDeleteLogStream handles deleting a log stream; rollups it already streamed are kept
+func (s *Server) HandleDeleteLogStream(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.logStreamService.DeleteStream(c, c.Param("id"), userID)
+	if errors.Is(err, services.ErrLogStreamNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete log stream: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetMappingProfileRequest represents a request to set the column mapping for a log source
+type SetMappingProfileRequest struct {
+	// Columns maps header names to the canonical columns they hold
+	Columns    map[string]string `json:"columns"`
+	AutoExtend bool              `json:"autoExtend"`
+}
+
+// HandleListMappingProfiles handles listing the current user's column mapping profiles
+func (s *Server) HandleListMappingProfiles(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	profiles, err := s.mappingService.ListProfiles(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list mapping profiles: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// HandleSetMappingProfile handles setting the column mapping for a log source
+func (s *Server) HandleSetMappingProfile(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	var req SetMappingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	profile := &ingestion.MappingProfile{Source: c.Param("source"), Columns: req.Columns, AutoExtend: req.AutoExtend}
+	err := s.mappingService.SetProfile(c, userID, profile)
+	switch {
+	case errors.Is(err, services.ErrInvalidMappingProfile):
+		writeError(c, codedError(http.StatusBadRequest, err).WithDetail("formats", ingestion.LogSources()))
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to set mapping profile: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// HandleDeleteMappingProfile handles removing the column mapping for a log source
+func (s *Server) HandleDeleteMappingProfile(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	err := s.mappingService.DeleteProfile(c, userID, c.Param("source"))
+	switch {
+	case errors.Is(err, services.ErrMappingProfileNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete mapping profile: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGetFileSchema handles retrieving the columns of a processed log and how they drifted
+// from the previous upload from its source
+func (s *Server) HandleGetFileSchema(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	schema, err := s.mappingService.GetFileSchema(c, c.Param("id"), userID)
+	switch {
+	case errors.Is(err, services.ErrFileSchemaNotFound):
+		respondErrorf(c, http.StatusNotFound, "No schema recorded for this file")
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get file schema: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schema)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetOrganization handles retrieving the user's organization and its settings
+func (s *Server) HandleGetOrganization(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	org, err := s.orgService.GetOrganization(c, orgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to get organization: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// HandleSetUploadDefaults handles setting how the organization's uploads are parsed when they
+// don't say, replacing the defaults set before
+func (s *Server) HandleSetUploadDefaults(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	var req models.UploadDefaults
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	org, err := s.orgService.SetUploadDefaults(c, orgID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUploadDefaults):
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to set upload defaults: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// HandleSetReportingCurrency handles setting the currency the organization's campaign spend is
+// reported in
+func (s *Server) HandleSetReportingCurrency(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	var req SetOrgCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	org, err := s.orgService.SetReportingCurrency(c, orgID, req.Currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCurrency):
+			respondError(c, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrOrganizationNotFound):
+			respondErrorf(c, http.StatusNotFound, "Organization not found")
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to set reporting currency: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// pageFlushInterval is how many items are written between flushes of a streamed page
+const pageFlushInterval = 500
+
+// parsePageLimit parses the optional 'limit' query parameter, which must be between 1 and the maximum page size
+func parsePageLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return services.DefaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > services.MaxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", services.MaxPageSize)
+	}
+	return limit, nil
+}
+
+// pageWriter streams a JSON object holding a page of items, encoding items as they're
+// written instead of buffering the whole response
+type pageWriter struct {
+	c       *gin.Context
+	written int
+}
+
+// newPageWriter starts a 200 response with head's fields followed by the items array under key.
+// head must encode as a JSON object and must not have a field named key or nextCursor.
+func newPageWriter(c *gin.Context, head interface{}, key string) (*pageWriter, error) {
+	fields, err := json.Marshal(head)
+	if err != nil {
+		return nil, err
+	}
+	fields = bytes.TrimSuffix(bytes.TrimSpace(fields), []byte("}"))
+	if len(fields) > 1 {
+		fields = append(fields, ',')
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if _, err := fmt.Fprintf(c.Writer, "%s%q:[", fields, key); err != nil {
+		return nil, err
+	}
+
+	return &pageWriter{c: c}, nil
+}
+
+// Write appends an item to the page
+func (w *pageWriter) Write(item interface{}) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if w.written > 0 {
+		data = append([]byte{','}, data...)
+	}
+	if _, err := w.c.Writer.Write(data); err != nil {
+		return err
+	}
+
+	w.written++
+	if w.written%pageFlushInterval == 0 {
+		w.c.Writer.Flush()
+	}
+	return nil
+}
+
+// Close ends the items array and the object with the cursor of the next page, empty on the last page
+func (w *pageWriter) Close(nextCursor string) error {
+	var next interface{}
+	if nextCursor != "" {
+		next = nextCursor
+	}
+	cursor, _ := json.Marshal(next)
+
+	_, err := fmt.Fprintf(w.c.Writer, `],"nextCursor":%s}`, cursor)
+	return err
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultParserBaselineDays is how many days before the last one parser runs are compared with
+const defaultParserBaselineDays = 7
+
+// HandleGetParserHealth handles reporting each parser's recent failure rate, row errors and
+// throughput against its baseline, flagging regressions
+func (s *Server) HandleGetParserHealth(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("baselineDays", strconv.Itoa(defaultParserBaselineDays)))
+	if err != nil || days < 1 || days > 90 {
+		respondErrorf(c, http.StatusBadRequest, "baselineDays must be between 1 and 90")
+		return
+	}
+
+	parsers, err := s.parserHealth.GetParserHealth(c, days)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get parser health: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"parsers": parsers})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdatePreferencesRequest represents a request to update preferences; omitted fields are left unchanged
+type UpdatePreferencesRequest struct {
+	Currency         *string `json:"currency"`
+	Timezone         *string `json:"timezone"`
+	DateFormat       *string `json:"dateFormat"`
+	DefaultDashboard *string `json:"defaultDashboard"`
+	EmailDigest      *bool   `json:"emailDigest"`
+}
+
+// HandleGetPreferences handles retrieving the current user's preferences
+func (s *Server) HandleGetPreferences(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	preferences, err := s.preferencesService.GetPreferences(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get preferences: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// HandleUpdatePreferences handles updating the current user's preferences
+func (s *Server) HandleUpdatePreferences(c *gin.Context) {
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	preferences, err := s.preferencesService.GetPreferences(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get preferences: %v", err)
+		return
+	}
+
+	if req.Currency != nil {
+		preferences.Currency = *req.Currency
+	}
+	if req.Timezone != nil {
+		preferences.Timezone = *req.Timezone
+	}
+	if req.DateFormat != nil {
+		preferences.DateFormat = *req.DateFormat
+	}
+	if req.DefaultDashboard != nil {
+		preferences.DefaultDashboard = *req.DefaultDashboard
+	}
+	if req.EmailDigest != nil {
+		preferences.EmailDigest = *req.EmailDigest
+	}
+
+	if err := s.preferencesService.UpdatePreferences(c, preferences); err != nil {
+		if errors.Is(err, services.ErrInvalidPreferences) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update preferences: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
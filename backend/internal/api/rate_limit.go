@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// idleClientTTL is how long an idle client's limiter is kept
+const idleClientTTL = 10 * time.Minute
+
+// rateLimiter limits requests per client with token buckets whose rate can change at runtime
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	clients   map[string]*rateClient
+	lastSweep time.Time
+}
+
+// rateClient is a client's token bucket and when it was last used
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter creates a limiter allowing perMinute requests per client; 0 disables limiting
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		clients:   make(map[string]*rateClient),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetLimit changes the per-client limit, including for clients already being tracked
+func (l *rateLimiter) SetLimit(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.perMinute = perMinute
+	for _, client := range l.clients {
+		client.limiter.SetLimit(limitFor(perMinute))
+		client.limiter.SetBurst(burstFor(perMinute))
+	}
+}
+
+// allow reports whether a client may make a request now
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perMinute == 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, client := range l.clients {
+			if now.Sub(client.lastSeen) > idleClientTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[key]
+	if !ok {
+		client = &rateClient{limiter: rate.NewLimiter(limitFor(l.perMinute), burstFor(l.perMinute))}
+		l.clients[key] = client
+	}
+	client.lastSeen = now
+
+	return client.limiter.AllowN(now, 1)
+}
+
+// limitFor converts a per-minute limit to a token rate
+func limitFor(perMinute int) rate.Limit {
+	return rate.Limit(float64(perMinute) / 60)
+}
+
+// burstFor allows short bursts of a quarter of the per-minute limit
+func burstFor(perMinute int) int {
+	return max(perMinute/4, 1)
+}
+
+// RateLimitMiddleware limits requests per authenticated user, or per client IP before authentication
+func (s *Server) RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if userID, exists := c.Get("userID"); exists {
+			key = "user:" + userID.(string)
+		}
+
+		if !s.rateLimiter.allow(key) {
+			c.Header("Retry-After", strconv.Itoa(1))
+			respondErrorf(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorContextMiddleware tags the request context so errors reported while handling it
+// carry the request method and route
+func ErrorContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := errreport.WithTags(c.Request.Context(), "method", c.Request.Method, "path", c.Request.URL.Path, "route", c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RecoveryMiddleware recovers from panics in handlers, reporting them with the request
+// and user they happened for before responding with a 500
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Deliberate aborts are not errors
+				panic(recovered)
+			}
+
+			errreport.ReportEvent(requestContext(c), errreport.Event{
+				Message: "Recovered from panic",
+				Err:     fmt.Errorf("panic: %v", recovered),
+				Stack:   string(debug.Stack()),
+			})
+
+			respondErrorf(c, http.StatusInternalServerError, "Internal server error")
+		}()
+
+		c.Next()
+	}
+}
+
+// requestContext returns the request context tagged with the authenticated user, if any
+func requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if userID, exists := c.Get("userID"); exists {
+		ctx = errreport.WithTags(ctx, "userID", userID.(string))
+	}
+	return ctx
+}
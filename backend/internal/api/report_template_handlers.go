@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/reportgen"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ReportTemplateRequest represents a request to create or replace a report template
+type ReportTemplateRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Sections    []models.ReportSection `json:"sections" binding:"required"`
+}
+
+// HandleListReportTemplates handles listing the organization's report templates
+func (s *Server) HandleListReportTemplates(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	templates, err := s.templateService.ListTemplates(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list report templates: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// HandleGetReportTemplate handles retrieving one of the organization's report templates
+func (s *Server) HandleGetReportTemplate(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	template, err := s.templateService.GetTemplate(c, c.Param("id"), orgID)
+	switch {
+	case errors.Is(err, services.ErrReportTemplateNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get report template: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// HandleCreateReportTemplate handles creating a report template for the organization
+func (s *Server) HandleCreateReportTemplate(c *gin.Context) {
+	// Get user and organization ID from context
+	userID := c.MustGet("userID").(string)
+	orgID := c.MustGet("orgID").(string)
+
+	var req ReportTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	template := &models.ReportTemplate{OrgID: orgID, Name: req.Name, Description: req.Description, Sections: req.Sections, CreatedBy: userID}
+	err := s.templateService.CreateTemplate(c, template)
+	switch {
+	case errors.Is(err, services.ErrInvalidReportTemplate):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrReportTemplateExists):
+		respondError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create report template: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// HandleUpdateReportTemplate handles replacing one of the organization's report templates
+func (s *Server) HandleUpdateReportTemplate(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	var req ReportTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	template := &models.ReportTemplate{ID: c.Param("id"), OrgID: orgID, Name: req.Name, Description: req.Description, Sections: req.Sections}
+	err := s.templateService.UpdateTemplate(c, template)
+	switch {
+	case errors.Is(err, services.ErrInvalidReportTemplate):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrReportTemplateNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, services.ErrReportTemplateExists):
+		respondError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update report template: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// HandleDeleteReportTemplate handles deleting one of the organization's report templates
+func (s *Server) HandleDeleteReportTemplate(c *gin.Context) {
+	// Get organization ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	err := s.templateService.DeleteTemplate(c, c.Param("id"), orgID)
+	switch {
+	case errors.Is(err, services.ErrReportTemplateNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to delete report template: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleGenerateReport handles generating a report from one of the organization's templates,
+// as a PDF, an Excel workbook or JSON
+func (s *Server) HandleGenerateReport(c *gin.Context) {
+	// Get user and organization ID from context
+	userID := c.MustGet("userID").(string)
+	orgID := c.MustGet("orgID").(string)
+
+	// Parse optional date range
+	from, to, err := parseDateRangeQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "pdf"))
+	if format != "pdf" && format != "xlsx" && format != "json" {
+		respondErrorf(c, http.StatusBadRequest, "Invalid 'format', expected pdf, xlsx or json")
+		return
+	}
+
+	report, err := s.templateService.GenerateReport(c, userID, orgID, c.Param("id"), from, to)
+	switch {
+	case errors.Is(err, services.ErrReportTemplateNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to generate report: %v", err)
+		return
+	}
+
+	report.Localize(localizer(c).T)
+
+	filename := fmt.Sprintf("report_%s.%s", c.Param("id"), format)
+	switch format {
+	case "json":
+		c.JSON(http.StatusOK, report)
+		return
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		setAttachment(c, filename)
+		err = reportgen.WriteXLSX(c.Writer, report)
+	default:
+		c.Header("Content-Type", "application/pdf")
+		setAttachment(c, filename)
+		err = reportgen.WritePDF(c.Writer, report)
+	}
+	if err != nil {
+		// The response is under way, so the error can only be reported
+		errreport.Report(requestContext(c), "Failed to render report", err)
+	}
+}
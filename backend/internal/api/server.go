@@ -4,43 +4,122 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bolognesandwiches/AdVantage/internal/adstxt"
+	"github.com/bolognesandwiches/AdVantage/internal/cache"
 	"github.com/bolognesandwiches/AdVantage/internal/config"
 	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/diagnostics"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/events"
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/health"
 	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/integrations"
+	"github.com/bolognesandwiches/AdVantage/internal/mail"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/narrative"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/secrets"
 	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/settings"
 	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
 	"github.com/gin-gonic/gin"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	router      *gin.Engine
-	config      *config.Config
-	db          *db.PostgresDB
-	http        *http.Server
-	userService *services.UserService
-	fileService *services.FileService
+	router             *gin.Engine
+	config             *config.Config
+	db                 *db.PostgresDB
+	http               *http.Server
+	userService        *services.UserService
+	sessionService     *services.SessionService
+	preferencesService *services.PreferencesService
+	orgService         *services.OrganizationService
+	entitlementService *services.EntitlementService
+	fileService        *services.FileService
+	bundleService      *services.BundleService
+	deadLetterService  *services.DeadLetterService
+	campaignService    *services.CampaignService
+	rollupService      *services.RollupService
+	analyticsService   *services.AnalyticsService
+	datasetService     *services.DatasetService
+	integrationService *services.IntegrationService
+	logStreamService   *services.LogStreamService
+	exportService      *services.ExportService
+	realtimeService    *services.RealtimeService
+	usageService       *services.UsageService
+	deliveryService    *services.DeliveryService
+	invoiceService     *services.InvoiceService
+	categoryService    *services.CategoryService
+	mappingService     *services.MappingService
+	metricService      *services.CustomMetricService
+	templateService    *services.ReportTemplateService
+	embedService       *services.EmbedService
+	apiKeyService      *services.APIKeyService
+	feedService        *services.MetricsFeedService
+	shareService       *services.DataShareService
+	statusService      *services.StatusService
+	goalService        *services.GoalService
+	annotationService  *services.AnnotationService
+	experimentService  *services.ExperimentService
+	currencyService    *services.CurrencyService
+	brandSafetyService *services.BrandSafetyService
+	journeyService     *services.JourneyService
+	health             *health.Checker
+	parserHealth       *services.ParserHealthService
+	storageGC          *services.StorageGC
+	reportsDir         string
+	workers            *worker.Manager
+	events             events.Publisher
+	stopStreams        context.CancelFunc
+	streamsDone        chan struct{}
+	secrets            *secrets.Store
+	settings           *settings.Store
+	rateLimiter        *rateLimiter
 }
 
-// NewServer creates a new HTTP server
-func NewServer(cfg *config.Config, database *db.PostgresDB) *Server {
+// NewServer creates a new HTTP server. The secret store is optional and supplies rotated credentials.
+// Changes to the runtime settings are applied to the server's workers, limits and upload size as they happen.
+func NewServer(cfg *config.Config, database *db.PostgresDB, secretStore *secrets.Store, settingsStore *settings.Store) *Server {
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Background services and processing jobs work across orgs
+	systemCtx := db.AsSystem(context.Background())
+
 	// Create Gin router
 	router := gin.New()
 
+	// Let handlers pass the gin context to services while keeping values set on the request
+	// context, such as the org row-level security is scoped to
+	router.ContextWithFallback = true
+
 	// Add middleware
 	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	router.Use(RequestIDMiddleware())
+	router.Use(ErrorContextMiddleware())
+	router.Use(CompressionMiddleware())
+	router.Use(RecoveryMiddleware())
+	router.Use(LocaleMiddleware())
 
 	// Add CORS middleware
-	router.Use(CORSMiddleware())
+	router.Use(CORSMiddleware(cfg.CORS))
+
+	// Unknown routes and invalid bodies get the same error responses as everything else
+	router.NoRoute(func(c *gin.Context) {
+		respondErrorf(c, http.StatusNotFound, "Not found")
+	})
+	useJSONFieldNames()
 
 	// Create file storage
 	fileStorage, err := storage.NewFileStorage("uploads")
@@ -50,18 +129,300 @@ func NewServer(cfg *config.Config, database *db.PostgresDB) *Server {
 
 	// Initialize the log processor service
 	logProcessor := ingestion.NewLogProcessorService("uploads")
+	logProcessor.SetParseWorkers(cfg.Ingestion.ParseWorkers)
+	logProcessor.SetBreakdownLimit(cfg.Ingestion.BreakdownLimit)
+
+	// Enable analysis narratives when a provider is configured
+	narrativeGenerator, err := narrative.NewGenerator(cfg.Narrative)
+	if err != nil {
+		log.Fatalf("Failed to initialize narrative generator: %v", err)
+	}
+	if narrativeGenerator != nil {
+		logProcessor.SetNarrativeGenerator(narrativeGenerator)
+	}
+
+	// Cache analytics results in Redis when configured
+	resultStore, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	resultCache := services.NewResultCache(resultStore, time.Duration(cfg.Cache.TTLSeconds)*time.Second)
+
+	// Create repositories
+	repos := repository.NewPostgresRepositories(database.Pool)
+	readRepos := repository.NewPostgresRepositories(database.Reader())
+	unitOfWork := repository.NewPostgresUnitOfWork(database.Pool)
+
+	// Changing a file also drops its org's cached cross-file results
+	resultCache.SetUsers(repos.Users)
+
+	// Categorize domains with users' overrides on top of the bundled mapping
+	logProcessor.SetCategoryOverrides(repos.Categories)
+
+	// Map CSV logs with users' mapping profiles and catch column changes between uploads
+	logProcessor.SetSchemaTracking(repos.Mappings, repos.Schemas)
+
+	// Record each file's parse so parser regressions show up in the admin API and metrics
+	logProcessor.SetParserRunSink(repos.ParserRuns)
+
+	// Meter each org's API calls, ingested rows and storage against its plan's limits
+	usageService := services.NewUsageService(repos)
+	logProcessor.SetUsageSink(usageService)
+	go usageService.Run(systemCtx)
+
+	// Persist individual log records into monthly partitions when enabled
+	if cfg.LogRecords.Persist {
+		logProcessor.SetRecordSink(repos.LogRecords)
+		maintenance := services.NewPartitionMaintenance(repos.LogRecords, cfg.LogRecords.RetentionMonths)
+		go maintenance.Run(systemCtx, 24*time.Hour)
+	}
+
+	// Register readiness checks for the dependencies a request needs
+	healthChecker := health.NewChecker()
+	healthChecker.Register("database", database.Ping)
+	healthChecker.Register("storage", func(ctx context.Context) error {
+		return fileStorage.CheckWritable()
+	})
+	healthChecker.Register("jobQueue", func(ctx context.Context) error {
+		_, err := repos.Jobs.CountByStatus(db.AsSystem(ctx), models.JobStatusQueued)
+		return err
+	})
+	healthChecker.Register("migrations", func(ctx context.Context) error {
+		missing, err := database.MissingTables(ctx, "users", "organizations", "sessions", "user_preferences", "digest_deliveries", "files", "processing_jobs", "dead_letter_jobs", "idempotency_keys", "upload_batches", "upload_batch_files", "datasets", "dataset_files", "integrations", "campaign_performance", "site_outcomes", "delivery_report_rows", "invoice_rows", "category_overrides", "mapping_profiles", "file_schemas", "brand_safety_lists", "metric_rollups", "rollup_files", "log_records", "custom_metrics", "report_templates", "embeds", "api_keys", "data_shares", "org_usage", "incidents", "parser_runs", "campaign_goals", "campaign_annotations", "experiments", "exchange_rates", "log_streams", "export_destinations", "export_table_syncs", "realtime_metrics")
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+
+	// The public status page reports the same checks, cached briefly
+	statusService := services.NewStatusService(repos, healthChecker)
+	parserHealth := services.NewParserHealthService(repos)
+
+	// Background processing jobs are drained on shutdown
+	workers := worker.NewManager(settingsStore.Get().WorkerConcurrency)
+	diagnostics.Publish("processingJobs", func() any {
+		return workers.Running()
+	})
+	diagnostics.PublishMetrics(logProcessor.ParserMetrics().WritePrometheus)
+	diagnostics.PublishMetrics(database.QueryMetrics().WritePrometheus)
+	diagnostics.PublishMetrics(database.WritePoolMetrics)
 
 	// Create services
-	userService := services.NewUserService(database)
-	fileService := services.NewFileService(fileStorage, logProcessor)
+	userService := services.NewUserService(repos.Users, fileStorage)
+	sessionService := services.NewSessionService(repos.Sessions)
+	preferencesService := services.NewPreferencesService(repos.Preferences, repos.Users)
+	orgService := services.NewOrganizationService(repos.Orgs)
+	entitlementService := services.NewEntitlementService(repos)
+	fileService := services.NewFileService(fileStorage, logProcessor, resultCache, repos, readRepos, unitOfWork, workers)
+	fileService.SetMaxJobAttempts(cfg.Ingestion.JobAttempts)
+	// Download URLs are signed with DOWNLOAD_SIGNING_KEY, or when unset a key derived from the JWT
+	// secret, so neither kind of token can pass for the other
+	downloadKey := []byte(cfg.Downloads.SigningKey)
+	if len(downloadKey) == 0 {
+		downloadKey, err = services.DeriveDownloadKey(cfg.JWT.Secret)
+		if err != nil {
+			log.Fatalf("Failed to derive download signing key: %v", err)
+		}
+	}
+	fileService.SetDownloadSigning(downloadKey, time.Duration(cfg.Downloads.ExpiryMinutes)*time.Minute)
+	fileService.SetDecompressionLimits(storage.DecompressionLimits{
+		MaxRatio: int64(cfg.Ingestion.MaxDecompressionRatio),
+		MaxBytes: int64(cfg.Ingestion.MaxDecompressedMB) << 20,
+	})
+
+	// Publish upload, processing and alert events for customers to subscribe to when a broker is configured
+	eventPublisher, err := events.New(cfg.Events, cfg.Environment)
+	if err != nil {
+		log.Fatalf("Failed to initialize event publisher: %v", err)
+	}
+	fileService.SetEventPublisher(eventPublisher)
+	deadLetterService := services.NewDeadLetterService(repos, unitOfWork, fileService)
+
+	// Sweep storage for uploads without file records and records without uploads
+	storageGC := services.NewStorageGC(fileStorage, repos.Files, resultCache,
+		time.Duration(cfg.StorageGC.GraceMinutes)*time.Minute, time.Duration(cfg.StorageGC.TrashRetentionDays)*24*time.Hour, cfg.StorageGC.Reconcile)
+	if cfg.StorageGC.IntervalMinutes > 0 {
+		go storageGC.Run(systemCtx, time.Duration(cfg.StorageGC.IntervalMinutes)*time.Minute)
+	}
+	bundleService := services.NewBundleService(fileStorage, fileService, logProcessor, resultCache, repos, unitOfWork)
+	campaignService := services.NewCampaignService(repos, logProcessor, resultCache)
+
+	// Roll processed files up by hour and day so cross-file reports read small aggregates
+	rollupService := services.NewRollupService(readRepos, unitOfWork)
+	logProcessor.SetRollupSink(rollupService)
+	campaignService.SetRollups(rollupService)
+	analyticsService := services.NewAnalyticsService(logProcessor, resultCache)
+	if cfg.SupplyAuth.Enabled {
+		// Crawls share the result cache's store, or stay in memory when Redis isn't configured
+		var crawlStore cache.Cache
+		if cfg.Cache.RedisURL != "" {
+			crawlStore = resultStore
+		}
+		validator := adstxt.NewValidator(crawlStore, time.Duration(cfg.SupplyAuth.CacheHours)*time.Hour)
+		analyticsService.SetSupplyValidator(validator, cfg.SupplyAuth.MaxDomains)
+	}
+	datasetService := services.NewDatasetService(repos, fileService, logProcessor, workers)
+	deliveryService := services.NewDeliveryService(repos, unitOfWork, fileService, logProcessor)
+	invoiceService := services.NewInvoiceService(repos, unitOfWork, fileService, logProcessor)
+	categoryService := services.NewCategoryService(repos)
+	mappingService := services.NewMappingService(repos)
+	metricService := services.NewCustomMetricService(repos)
+	templateService := services.NewReportTemplateService(repos, rollupService, metricService, logProcessor)
+	embedService := services.NewEmbedService(repos, logProcessor)
+	apiKeyService := services.NewAPIKeyService(repos)
+	feedService := services.NewMetricsFeedService(rollupService)
+	shareService := services.NewDataShareService(repos, campaignService, rollupService)
+	// Snapshot exchange rates daily, so spend converts between currencies at historical rates
+	ratesClient := fxrates.NewClient(cfg.ExchangeRates.URL)
+	currencyService := services.NewCurrencyService(repos, ratesClient)
+	if ratesClient != nil {
+		go currencyService.Run(systemCtx)
+	}
+	goalService := services.NewGoalService(repos, currencyService)
+	annotationService := services.NewAnnotationService(repos)
+	experimentService := services.NewExperimentService(repos, rollupService)
+	brandSafetyService := services.NewBrandSafetyService(repos, analyticsService)
+
+	// Journeys are read from persisted records; user IDs are hashed with JOURNEY_HASH_KEY, or a
+	// key derived from the JWT secret when unset
+	var journeyRecords repository.LogRecordRepository
+	if cfg.LogRecords.Persist {
+		journeyRecords = readRepos.LogRecords
+	}
+	journeyHashKey := []byte(cfg.LogRecords.JourneyHashKey)
+	if len(journeyHashKey) == 0 {
+		journeyHashKey, err = services.DeriveJourneyKey(cfg.JWT.Secret)
+		if err != nil {
+			log.Fatalf("Failed to derive journey hash key: %v", err)
+		}
+	}
+	journeyService := services.NewJourneyService(analyticsService, journeyRecords, journeyHashKey)
+
+	// Pull reports from connected ad platforms when integrations are configured
+	googleOAuth, err := integrations.NewGoogleOAuth(cfg.Google)
+	if err != nil {
+		log.Fatalf("Failed to initialize Google integrations: %v", err)
+	}
+	var tokenCipher *integrations.TokenCipher
+	if cfg.Integrations.EncryptionKey != "" {
+		tokenCipher, err = integrations.NewTokenCipher(cfg.Integrations.EncryptionKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize integrations: %v", err)
+		}
+	} else if googleOAuth != nil {
+		log.Fatalf("Google integrations require INTEGRATIONS_ENCRYPTION_KEY")
+	}
+	integrationService := services.NewIntegrationService(repos, logProcessor, rollupService, workers, entitlementService, services.IntegrationClients{
+		Cipher:      tokenCipher,
+		GoogleOAuth: googleOAuth,
+		GoogleAds:   integrations.NewGoogleAds(cfg.Google, googleOAuth),
+		GA4:         integrations.NewGA4(googleOAuth),
+		Sheets:      integrations.NewGoogleSheets(googleOAuth),
+	}, cfg.Integrations.LookbackDays)
+	if tokenCipher != nil {
+		go integrationService.Run(systemCtx, time.Duration(cfg.Integrations.SyncIntervalMinutes)*time.Minute)
+	}
+
+	// Export users' files and rollups to the warehouses they connect; credentials need the integrations key
+	exportService := services.NewExportService(repos, workers, entitlementService, tokenCipher)
+	if tokenCipher != nil {
+		go exportService.Run(systemCtx, time.Duration(cfg.Exports.SyncIntervalMinutes)*time.Minute)
+	}
+
+	// Consume users' Kafka topics into rollups as events arrive; consumers flush on shutdown
+	realtimeService := services.NewRealtimeService(repos)
+	logStreamService := services.NewLogStreamService(repos, fileStorage, rollupService, resultCache, realtimeService, usageService, entitlementService, tokenCipher)
+	streamsCtx, stopStreams := context.WithCancel(systemCtx)
+	streamsDone := make(chan struct{})
+	go func() {
+		defer close(streamsDone)
+		logStreamService.Run(streamsCtx)
+	}()
+
+	// Email opted-in users a weekly digest when an SMTP relay is configured
+	mailSender, err := mail.NewSMTPSender(cfg.Email)
+	if err != nil {
+		log.Fatalf("Failed to initialize email: %v", err)
+	}
+	if mailSender != nil {
+		digestService := services.NewDigestService(logProcessor, repos.Digests, preferencesService, mailSender, time.Weekday(cfg.Email.DigestWeekday), cfg.Email.DigestHour)
+		digestService.SetGoals(goalService)
+		go digestService.Run(systemCtx)
+	}
 
 	// Create server
 	server := &Server{
-		router:      router,
-		config:      cfg,
-		db:          database,
-		userService: userService,
-		fileService: fileService,
+		router:             router,
+		config:             cfg,
+		db:                 database,
+		userService:        userService,
+		sessionService:     sessionService,
+		preferencesService: preferencesService,
+		orgService:         orgService,
+		entitlementService: entitlementService,
+		fileService:        fileService,
+		bundleService:      bundleService,
+		deadLetterService:  deadLetterService,
+		campaignService:    campaignService,
+		rollupService:      rollupService,
+		analyticsService:   analyticsService,
+		datasetService:     datasetService,
+		integrationService: integrationService,
+		logStreamService:   logStreamService,
+		exportService:      exportService,
+		realtimeService:    realtimeService,
+		usageService:       usageService,
+		deliveryService:    deliveryService,
+		invoiceService:     invoiceService,
+		categoryService:    categoryService,
+		mappingService:     mappingService,
+		metricService:      metricService,
+		templateService:    templateService,
+		embedService:       embedService,
+		apiKeyService:      apiKeyService,
+		feedService:        feedService,
+		shareService:       shareService,
+		statusService:      statusService,
+		goalService:        goalService,
+		annotationService:  annotationService,
+		experimentService:  experimentService,
+		currencyService:    currencyService,
+		brandSafetyService: brandSafetyService,
+		journeyService:     journeyService,
+		health:             healthChecker,
+		parserHealth:       parserHealth,
+		storageGC:          storageGC,
+		reportsDir:         logProcessor.ReportsDir(),
+		workers:            workers,
+		events:             eventPublisher,
+		stopStreams:        stopStreams,
+		streamsDone:        streamsDone,
+		secrets:            secretStore,
+		settings:           settingsStore,
+		rateLimiter:        newRateLimiter(settingsStore.Get().RateLimitPerMinute),
+	}
+
+	// Apply runtime settings now and whenever they change
+	settingsStore.OnChange(func(current settings.Settings) {
+		workers.SetConcurrency(current.WorkerConcurrency)
+		fileService.SetMaxUploadSize(current.MaxUploadSizeBytes())
+		server.rateLimiter.SetLimit(current.RateLimitPerMinute)
+	})
+
+	// Requeue jobs orphaned by a crash, then resume everything queued
+	if requeued, err := fileService.ReconcileJobs(systemCtx); err != nil {
+		errreport.Report(systemCtx, "Failed to reconcile processing jobs", err)
+	} else if requeued > 0 {
+		slog.Warn("Requeued processing jobs interrupted by a crash", "count", requeued)
+	}
+	if resumed, err := fileService.ResumeQueuedJobs(systemCtx); err != nil {
+		errreport.Report(systemCtx, "Failed to resume queued jobs", err)
+	} else if resumed > 0 {
+		slog.Info("Resumed queued processing jobs", "count", resumed)
 	}
 
 	// Setup routes
@@ -70,15 +431,60 @@ func NewServer(cfg *config.Config, database *db.PostgresDB) *Server {
 	return server
 }
 
-// CORSMiddleware handles CORS preflight requests and sets appropriate headers
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware handles CORS preflight requests and sets headers for allowed origins.
+// The request origin is echoed back rather than "*" so credentialed requests are accepted by browsers.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			// Not a cross-origin request
+			c.Next()
+			return
+		}
+
+		// Responses differ by origin, so shared caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		if !allowed[origin] && !allowAny {
+			if c.Request.Method == "OPTIONS" {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAny && !allowed[origin] {
+			// Wildcard origins can't be combined with credentials
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if exposed != "" {
+			c.Writer.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
 
 		if c.Request.Method == "OPTIONS" {
+			c.Writer.Header().Set("Access-Control-Allow-Methods", methods)
+			c.Writer.Header().Set("Access-Control-Allow-Headers", headers)
+			c.Writer.Header().Set("Access-Control-Max-Age", maxAge)
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -100,49 +506,373 @@ func (s *Server) Start() error {
 	return s.http.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the HTTP server
+// Shutdown gracefully shuts down the HTTP server, then drains background processing jobs.
+// Jobs still running when ctx expires are canceled and requeued.
 func (s *Server) Shutdown(ctx context.Context) error {
+	var httpErr error
 	if s.http != nil {
-		return s.http.Shutdown(ctx)
+		httpErr = s.http.Shutdown(ctx)
+	}
+
+	if err := s.workers.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to drain processing jobs: %w", err)
+	}
+
+	// Stream consumers store what they consumed and commit its offsets before stopping
+	s.stopStreams()
+	select {
+	case <-s.streamsDone:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop log stream consumers: %w", ctx.Err())
+	}
+
+	// Drained jobs and stopped consumers may have just metered usage
+	if err := s.usageService.Flush(ctx); err != nil {
+		return fmt.Errorf("failed to flush usage: %w", err)
+	}
+
+	// Drained jobs may have just published their events
+	if s.events != nil {
+		if err := s.events.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush events: %w", err)
+		}
 	}
-	return nil
+
+	return httpErr
 }
 
 // setupRoutes sets up all the routes for the server
 func (s *Server) setupRoutes() {
-	// API v1 group
-	v1 := s.router.Group("/api/v1")
+	// Every API version serves the same routes; see versioning.go for how they differ
+	for _, version := range apiVersions(s.config.API) {
+		s.setupAPIRoutes(s.router.Group("/api/"+version.Name, VersionMiddleware(version)))
+	}
+	s.router.GET("/api/versions", s.HandleListAPIVersions)
+
+	// Health checks: liveness only reports that the process is serving, readiness checks dependencies
+	s.router.GET("/health", s.HandleHealthCheck)
+	s.router.GET("/healthz", s.HandleHealthCheck)
+	s.router.GET("/readyz", s.HandleReadinessCheck)
+
+	// The status page is public, so customers can check for slow processing before filing tickets
+	s.router.GET("/status", s.RateLimitMiddleware(), s.HandleGetStatus)
+}
+
+// setupAPIRoutes sets up the API's routes on a version's group
+func (s *Server) setupAPIRoutes(version *gin.RouterGroup) {
+	// Auth routes
+	auth := version.Group("/auth")
+	auth.Use(s.RateLimitMiddleware())
 	{
-		// Auth routes
-		auth := v1.Group("/auth")
+		auth.POST("/register", s.HandleRegister)
+		auth.POST("/login", s.HandleLogin)
+		auth.POST("/refresh", s.HandleRefreshSession)
+	}
+
+	// Avatars are public so they can be embedded in pages without a token; their IDs are
+	// random and say nothing about the user
+	version.GET("/avatars/:id", s.HandleGetAvatar)
+
+	// Embedded charts are shown in dashboards and client portals, authorized by the token
+	// in their URL rather than a session
+	version.GET("/embed/:token", s.RateLimitMiddleware(), s.HandleGetEmbedChart)
+
+	// Signed download URLs hand a browser a file without a session, authorized by the short-lived
+	// token in their URL
+	version.GET("/downloads/:token", s.RateLimitMiddleware(), s.HandleSignedDownload)
+	version.HEAD("/downloads/:token", s.RateLimitMiddleware(), s.HandleSignedDownload)
+
+	// The metrics feed is read by BI tools, authorized by an API key rather than a session
+	feed := version.Group("/feed")
+	feed.Use(s.APIKeyMiddleware(), s.RateLimitMiddleware(), s.UsageMiddleware())
+	{
+		feed.GET("/reports", s.HandleListFeedReports)
+		feed.GET("/reports/:report", s.HandleGetFeedReport)
+	}
+
+	// Data shares give third parties read access to some campaigns, authorized by the share's
+	// token; the rate limit applies per client address since there's no user
+	shared := version.Group("/shared")
+	shared.Use(s.ShareTokenMiddleware(), s.RateLimitMiddleware(), s.UsageMiddleware())
+	{
+		shared.GET("", s.HandleGetSharedScope)
+		shared.GET("/campaigns/:id/rollup", s.HandleGetSharedCampaignRollup)
+		shared.GET("/campaigns/:id/rollups", s.HandleGetSharedCampaignRollups)
+	}
+
+	// Protected routes
+	protected := version.Group("/")
+	protected.Use(s.AuthMiddleware(), s.RateLimitMiddleware(), s.UsageMiddleware())
+	{
+		// User routes
+		user := protected.Group("/user")
 		{
-			auth.POST("/register", s.HandleRegister)
-			auth.POST("/login", s.HandleLogin)
+			user.GET("/me", s.HandleGetCurrentUser)
+			user.PUT("/me", s.HandleUpdateCurrentUser)
+			user.PUT("/me/avatar", s.HandleUploadAvatar)
+			user.DELETE("/me/avatar", s.HandleDeleteAvatar)
+			user.GET("/me/preferences", s.HandleGetPreferences)
+			user.PUT("/me/preferences", s.HandleUpdatePreferences)
+			user.GET("/me/sessions", s.HandleListSessions)
+			user.DELETE("/me/sessions/:id", s.HandleRevokeSession)
 		}
 
-		// Protected routes
-		protected := v1.Group("/")
-		protected.Use(s.AuthMiddleware())
+		// File upload routes
+		files := protected.Group("/files")
 		{
-			// User routes
-			user := protected.Group("/user")
-			{
-				user.GET("/me", s.HandleGetCurrentUser)
-				user.PUT("/me", s.HandleUpdateCurrentUser)
-			}
+			files.POST("/upload", s.UploadAllowanceMiddleware(), s.IngestionLimitMiddleware(), s.HandleFileUpload)
+			files.POST("/upload-batch", s.UploadAllowanceMiddleware(), s.IngestionLimitMiddleware(), s.HandleBatchUpload)
+			files.POST("/validate", s.HandleValidateFile)
+			files.GET("/batches/:id", s.HandleGetBatch)
+			files.GET("/jobs/:id", s.HandleGetJob)
+			files.GET("/jobs/:id/events", s.HandleJobEvents)
+			files.POST("/uploads", s.UploadAllowanceMiddleware(), s.IngestionLimitMiddleware(), s.HandleStartUpload)
+			files.GET("/uploads/:id", s.HandleGetUpload)
+			files.PATCH("/uploads/:id", s.HandleUploadChunk)
+			files.POST("/uploads/:id/complete", s.HandleCompleteUpload)
+			files.DELETE("/uploads/:id", s.HandleAbortUpload)
+			files.GET("/:id", s.HandleGetFile)
+			files.HEAD("/:id", s.HandleGetFile)
+			files.POST("/:id/download-url", s.HandleCreateDownloadURL)
+			files.DELETE("/:id/processing", s.HandleCancelProcessing)
+			files.POST("/:id/reprocess", s.HandleReprocessFile)
+			files.GET("/:id/schema", s.HandleGetFileSchema)
+			files.GET("/:id/bundle", s.HandleExportBundle)
+			files.POST("/import-bundle", s.UploadAllowanceMiddleware(), s.IngestionLimitMiddleware(), s.HandleImportBundle)
+			files.GET("/list", s.HandleListFiles)
+			files.POST("/bulk", s.HandleBulkFiles)
+			files.POST("/process/:id", s.ProcessFile)
+			files.GET("/analysis/:id", ConditionalGetMiddleware(), s.GetFileAnalysis)
+			files.GET("/analysis/:id/versions", ConditionalGetMiddleware(), s.HandleListAnalysisVersions)
+		}
 
-			// File upload routes
-			files := protected.Group("/files")
-			{
-				files.POST("/upload", s.HandleFileUpload)
-				files.GET("/:id", s.HandleGetFile)
-				files.GET("/list", s.HandleListFiles)
-				files.POST("/process/:id", s.ProcessFile)
-				files.GET("/analysis/:id", s.GetFileAnalysis)
-			}
+		// Campaign routes
+		campaigns := protected.Group("/campaigns")
+		{
+			campaigns.GET("/:id/rollup", s.HandleGetCampaignRollup)
+			campaigns.GET("/:id/reach", s.HandleGetCampaignReach)
+			campaigns.GET("/:id/frequency-cap", s.HandleGetCampaignFrequencyCap)
+			campaigns.GET("/:id/goal", s.HandleGetCampaignGoal)
+			campaigns.PUT("/:id/goal", s.HandleSetCampaignGoal)
+			campaigns.DELETE("/:id/goal", s.HandleDeleteCampaignGoal)
+			campaigns.GET("/:id/annotations", s.HandleListCampaignAnnotations)
+			campaigns.POST("/:id/annotations", s.HandleCreateCampaignAnnotation)
+			campaigns.DELETE("/:id/annotations/:annotationId", s.HandleDeleteCampaignAnnotation)
+		}
+		protected.GET("/campaign-goals", s.HandleListCampaignGoals)
+
+		// Experiment routes; analyzing experiments is an advanced analytics feature
+		experiments := protected.Group("/experiments")
+		{
+			experiments.GET("", s.HandleListExperiments)
+			experiments.POST("", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleCreateExperiment)
+			experiments.GET("/:id", s.HandleGetExperiment)
+			experiments.POST("/:id/analyze", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleAnalyzeExperiment)
+			experiments.DELETE("/:id", s.HandleDeleteExperiment)
+		}
+
+		// Rollup routes
+		protected.GET("/rollups", s.HandleGetRollups)
+		protected.GET("/rollups/export", s.HandleExportRollups)
+
+		// Dataset routes
+		datasets := protected.Group("/datasets")
+		{
+			datasets.POST("", s.HandleCreateDataset)
+			datasets.GET("", s.HandleListDatasets)
+			datasets.GET("/:id", s.HandleGetDataset)
+			datasets.POST("/:id/files", s.HandleAppendDatasetFile)
+			datasets.POST("/:id/recompute", s.HandleRecomputeDataset)
+		}
+
+		// Integration routes
+		integrationRoutes := protected.Group("/integrations")
+		{
+			integrationRoutes.GET("", s.HandleListIntegrations)
+			integrationRoutes.GET("/spend", s.HandleGetChannelSpend)
+			integrationRoutes.GET("/blended-cpa", s.HandleGetBlendedCPA)
+			integrationRoutes.POST("/google-ads/connect", s.RequireFeature(models.FeatureConnectors), s.HandleConnectGoogleAds)
+			integrationRoutes.POST("/ga4/connect", s.RequireFeature(models.FeatureConnectors), s.HandleConnectGA4)
+			integrationRoutes.POST("/google-sheets/connect", s.RequireFeature(models.FeatureConnectors), s.HandleConnectGoogleSheets)
+			integrationRoutes.POST("/:id/sync", s.RequireFeature(models.FeatureConnectors), s.HandleSyncIntegration)
+			integrationRoutes.DELETE("/:id", s.HandleDeleteIntegration)
+		}
+
+		// Log stream routes
+		streams := protected.Group("/streams")
+		{
+			streams.GET("", s.HandleListLogStreams)
+			streams.POST("", s.RequireFeature(models.FeatureConnectors), s.HandleCreateLogStream)
+			streams.POST("/:id/pause", s.HandlePauseLogStream)
+			streams.POST("/:id/resume", s.RequireFeature(models.FeatureConnectors), s.HandleResumeLogStream)
+			streams.DELETE("/:id", s.HandleDeleteLogStream)
+		}
+
+		// Warehouse export routes
+		exports := protected.Group("/exports/destinations")
+		{
+			exports.GET("", s.HandleListExportDestinations)
+			exports.POST("", s.RequireFeature(models.FeatureConnectors), s.HandleCreateExportDestination)
+			exports.POST("/:id/sync", s.RequireFeature(models.FeatureConnectors), s.HandleSyncExportDestination)
+			exports.GET("/:id/tables", s.HandleListExportTables)
+			exports.GET("/:id/tables/:table", s.HandleGetExportTable)
+			exports.DELETE("/:id", s.HandleDeleteExportDestination)
+		}
+
+		// Delivery report routes
+		delivery := protected.Group("/delivery-reports")
+		{
+			delivery.POST("", s.HandleImportDeliveryReport)
+			delivery.GET("/reconciliation", s.HandleGetDeliveryReconciliation)
+		}
+
+		// DSP invoice routes
+		invoices := protected.Group("/invoices")
+		{
+			invoices.POST("", s.HandleImportInvoice)
+			invoices.GET("/reconciliation", s.HandleGetSpendReconciliation)
+		}
+
+		// Content category routes
+		categories := protected.Group("/categories")
+		{
+			categories.GET("", s.HandleListCategories)
+			categories.GET("/overrides", s.HandleListCategoryOverrides)
+			categories.PUT("/overrides/:domain", s.HandleSetCategoryOverride)
+			categories.DELETE("/overrides/:domain", s.HandleDeleteCategoryOverride)
+		}
+
+		// Ingestion data dictionary routes
+		ingestionRoutes := protected.Group("/ingestion")
+		{
+			ingestionRoutes.GET("/sources", s.HandleListLogSources)
+		}
+
+		// Column mapping profile routes
+		mappings := protected.Group("/mapping-profiles")
+		{
+			mappings.GET("", s.HandleListMappingProfiles)
+			mappings.PUT("/:source", s.HandleSetMappingProfile)
+			mappings.DELETE("/:source", s.HandleDeleteMappingProfile)
+		}
+
+		// Organization routes; upload defaults apply to every member's uploads
+		org := protected.Group("/org")
+		{
+			org.GET("", s.HandleGetOrganization)
+			org.PUT("/upload-defaults", s.HandleSetUploadDefaults)
+			org.PUT("/currency", s.HandleSetReportingCurrency)
+		}
+
+		// Custom metric routes
+		customMetrics := protected.Group("/custom-metrics")
+		{
+			customMetrics.GET("", s.HandleListCustomMetrics)
+			customMetrics.PUT("/:name", s.HandleSetCustomMetric)
+			customMetrics.DELETE("/:name", s.HandleDeleteCustomMetric)
+		}
+
+		// Report template routes
+		reportTemplates := protected.Group("/report-templates")
+		{
+			reportTemplates.GET("", s.HandleListReportTemplates)
+			reportTemplates.POST("", s.HandleCreateReportTemplate)
+			reportTemplates.GET("/:id", s.HandleGetReportTemplate)
+			reportTemplates.PUT("/:id", s.HandleUpdateReportTemplate)
+			reportTemplates.DELETE("/:id", s.HandleDeleteReportTemplate)
+			reportTemplates.GET("/:id/report", s.HandleGenerateReport)
+		}
+
+		// Embed routes
+		embeds := protected.Group("/embeds")
+		{
+			embeds.POST("", s.HandleCreateEmbed)
+			embeds.GET("", s.HandleListEmbeds)
+			embeds.DELETE("/:id", s.HandleRevokeEmbed)
+		}
+
+		// API key routes
+		apiKeys := protected.Group("/api-keys")
+		{
+			apiKeys.GET("", s.HandleListAPIKeys)
+			apiKeys.POST("", s.HandleCreateAPIKey)
+			apiKeys.DELETE("/:id", s.HandleRevokeAPIKey)
+		}
+
+		// Data share routes
+		shares := protected.Group("/shares")
+		{
+			shares.GET("", s.HandleListDataShares)
+			shares.POST("", s.HandleCreateDataShare)
+			shares.DELETE("/:id", s.HandleRevokeDataShare)
+		}
+
+		// Usage routes
+		usage := protected.Group("/usage")
+		{
+			usage.GET("", s.HandleGetUsage)
+			usage.GET("/history", s.HandleGetUsageHistory)
+		}
+
+		// What the org's plan allows, for the frontend to gate features with
+		protected.GET("/entitlements", s.HandleGetEntitlements)
+
+		// Brand safety list routes
+		brandSafety := protected.Group("/brand-safety/lists")
+		{
+			brandSafety.POST("", s.HandleCreateBrandSafetyList)
+			brandSafety.GET("", s.HandleListBrandSafetyLists)
+			brandSafety.DELETE("/:id", s.HandleDeleteBrandSafetyList)
+		}
+
+		// Analytics routes
+		analytics := protected.Group("/analytics")
+		analytics.Use(ConditionalGetMiddleware())
+		{
+			analytics.GET("/funnel/:id", s.HandleGetFunnel)
+			analytics.GET("/supply-path/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetSupplyPath)
+			analytics.GET("/bid-efficiency/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetBidEfficiency)
+			analytics.GET("/prebid/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetPrebid)
+			analytics.GET("/prices/:id", s.HandleGetPrices)
+			analytics.GET("/price-floors/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetPriceFloors)
+			analytics.GET("/creatives/:id", s.HandleGetCreatives)
+			analytics.GET("/domains/:id", s.HandleGetDomains)
+			analytics.GET("/breakdowns/:id/:dimension", s.HandleGetBreakdown)
+			analytics.GET("/content-categories/:id", s.HandleGetContentCategories)
+			analytics.GET("/brand-safety/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetBrandSafety)
+			analytics.GET("/brand-safety/:id/violations.csv", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleExportBrandSafetyViolations)
+			analytics.GET("/journeys/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleExportJourneys)
+			analytics.GET("/dayparting/:id", s.HandleGetDayparting)
+			analytics.GET("/geographic/:id", s.HandleGetGeographic)
+			analytics.GET("/benchmarks/:id", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetBenchmarks)
+			analytics.GET("/realtime", s.RequireFeature(models.FeatureAdvancedAnalytics), s.HandleGetRealtime)
 		}
 	}
 
-	// Health check
-	s.router.GET("/health", s.HandleHealthCheck)
+	// OAuth callbacks arrive from the provider's consent screen without a bearer token
+	version.GET("/integrations/google/callback", s.RateLimitMiddleware(), s.HandleGoogleCallback)
+
+	// Admin routes for runtime settings
+	admin := version.Group("/admin")
+	admin.Use(s.AdminMiddleware())
+	{
+		admin.GET("/settings", s.HandleGetSettings)
+		admin.PATCH("/settings", s.HandleUpdateSettings)
+		admin.POST("/settings/reload", s.HandleReloadSettings)
+		admin.PUT("/orgs/:id/priority", s.HandleSetOrgPriority)
+		admin.PUT("/orgs/:id/currency", s.HandleSetOrgCurrency)
+		admin.PUT("/orgs/:id/plan", s.HandleSetOrgPlan)
+		admin.GET("/incidents", s.HandleListIncidents)
+		admin.POST("/incidents", s.HandleCreateIncident)
+		admin.PATCH("/incidents/:id", s.HandleUpdateIncident)
+		admin.GET("/dead-letters", s.HandleListDeadLetters)
+		admin.GET("/dead-letters/:id", s.HandleGetDeadLetter)
+		admin.PATCH("/dead-letters/:id", s.HandleUpdateDeadLetter)
+		admin.POST("/dead-letters/requeue", s.HandleRequeueDeadLetters)
+		admin.GET("/parser-health", s.HandleGetParserHealth)
+		admin.GET("/storage/gc", s.HandleGetStorageGC)
+		admin.POST("/storage/gc", s.HandleSweepStorage)
+		admin.GET("/backup", s.HandleExportBackup)
+		admin.POST("/restore", s.HandleRestoreBackup)
+	}
 }
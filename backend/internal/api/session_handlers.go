@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionResponse is a session as listed to its user
+type sessionResponse struct {
+	*models.Session
+	// Current marks the session the request was made with
+	Current bool `json:"current"`
+}
+
+// RefreshRequest represents the request body for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// HandleRefreshSession handles exchanging a session's refresh token for a new access token and
+// refresh token
+func (s *Server) HandleRefreshSession(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// The session's org isn't known until it is found, so it is looked up across orgs
+	ctx := db.AsSystem(c.Request.Context())
+	session, err := s.sessionService.RefreshSession(ctx, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid refresh token"))
+		case errors.Is(err, services.ErrSessionRevoked), errors.Is(err, services.ErrRefreshTokenReplaced):
+			writeError(c, apierror.New(http.StatusUnauthorized, apierror.CodeSessionRevoked, err.Error()))
+		default:
+			respondErrorf(c, http.StatusInternalServerError, "Failed to refresh session")
+		}
+		return
+	}
+
+	user, err := s.userService.FindByID(ctx, session.UserID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to find user")
+		return
+	}
+	token, err := s.generateToken(user, session)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": session.RefreshToken,
+	})
+}
+
+// HandleListSessions handles listing the devices the current user is signed in on
+func (s *Server) HandleListSessions(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+	sessionID := c.GetString("sessionID")
+
+	sessions, err := s.sessionService.ListSessions(c, userID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list sessions: %v", err)
+		return
+	}
+
+	response := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = sessionResponse{Session: session, Current: session.ID == sessionID}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// HandleRevokeSession handles signing one of the current user's devices out
+func (s *Server) HandleRevokeSession(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	if err := s.sessionService.RevokeSession(c, c.Param("id"), userID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			respondErrorf(c, http.StatusNotFound, "Session not found")
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to revoke session: %v", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateIncidentRequest represents a request to post an incident to the status page
+type CreateIncidentRequest struct {
+	Title    string `json:"title" binding:"required"`
+	Message  string `json:"message"`
+	Severity string `json:"severity" binding:"required"`
+}
+
+// UpdateIncidentRequest changes an incident; omitted fields keep their current values
+type UpdateIncidentRequest struct {
+	Title    *string `json:"title"`
+	Message  *string `json:"message"`
+	Severity *string `json:"severity"`
+	Resolved *bool   `json:"resolved"`
+}
+
+// HandleGetStatus handles the public status page, summarizing system health, the processing
+// backlog and recent incidents
+func (s *Server) HandleGetStatus(c *gin.Context) {
+	status := s.statusService.GetStatus(c.Request.Context())
+
+	c.Header("Cache-Control", "public, max-age=15")
+	c.JSON(http.StatusOK, status)
+}
+
+// HandleListIncidents handles listing open and recently resolved incidents
+func (s *Server) HandleListIncidents(c *gin.Context) {
+	incidents, err := s.statusService.ListIncidents(c)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list incidents: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"incidents": incidents})
+}
+
+// HandleCreateIncident handles posting an incident to the status page
+func (s *Server) HandleCreateIncident(c *gin.Context) {
+	var req CreateIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	incident := &models.Incident{Title: req.Title, Message: req.Message, Severity: req.Severity}
+	err := s.statusService.CreateIncident(c, incident)
+	switch {
+	case errors.Is(err, services.ErrInvalidIncident):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to create incident: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, incident)
+}
+
+// HandleUpdateIncident handles updating or resolving an incident
+func (s *Server) HandleUpdateIncident(c *gin.Context) {
+	var req UpdateIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	incident, err := s.statusService.GetIncident(c, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrIncidentNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get incident: %v", err)
+		return
+	}
+
+	// Merge the request into the incident
+	if req.Title != nil {
+		incident.Title = *req.Title
+	}
+	if req.Message != nil {
+		incident.Message = *req.Message
+	}
+	if req.Severity != nil {
+		incident.Severity = *req.Severity
+	}
+	if req.Resolved != nil {
+		switch {
+		case *req.Resolved && incident.ResolvedAt == nil:
+			now := time.Now()
+			incident.ResolvedAt = &now
+		case !*req.Resolved:
+			incident.ResolvedAt = nil
+		}
+	}
+
+	err = s.statusService.UpdateIncident(c, incident)
+	switch {
+	case errors.Is(err, services.ErrInvalidIncident):
+		respondError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, services.ErrIncidentNotFound):
+		respondError(c, http.StatusNotFound, err)
+		return
+	case err != nil:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to update incident: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
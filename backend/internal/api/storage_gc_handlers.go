@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SweepStorageRequest runs a storage sweep, fixing what it finds when Reconcile is set
+type SweepStorageRequest struct {
+	Reconcile bool `json:"reconcile"`
+}
+
+// HandleGetStorageGC handles reporting what the latest storage sweep found: uploads without a
+// file record and records without an upload
+func (s *Server) HandleGetStorageGC(c *gin.Context) {
+	report := s.storageGC.LastReport()
+	if report == nil {
+		respondErrorf(c, http.StatusNotFound, "Storage hasn't been swept yet")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleSweepStorage handles sweeping storage now. Without a body it only reports what's out of
+// step; with {"reconcile": true} it trashes orphaned uploads and deletes records without one.
+func (s *Server) HandleSweepStorage(c *gin.Context) {
+	var req SweepStorageRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	report, err := s.storageGC.Sweep(c, req.Reconcile)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to sweep storage: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
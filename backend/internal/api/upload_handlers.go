@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadChunkSize caps the size of one chunk of a resumable upload
+const maxUploadChunkSize = 64 << 20
+
+// StartUploadRequest represents a request to start a resumable upload
+type StartUploadRequest struct {
+	FileName string `json:"fileName" binding:"required"`
+	FileType string `json:"fileType" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+}
+
+// HandleStartUpload handles starting a resumable upload
+func (s *Server) HandleStartUpload(c *gin.Context) {
+	var req StartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	upload, err := s.fileService.StartUpload(c, req.FileName, req.FileType, userID, req.Size)
+	if err != nil {
+		s.respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// HandleGetUpload handles retrieving how much of a resumable upload has been received
+func (s *Server) HandleGetUpload(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	upload, err := s.fileService.GetUpload(c, c.Param("id"), userID)
+	if err != nil {
+		s.respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	c.JSON(http.StatusOK, upload)
+}
+
+// HandleUploadChunk handles appending a chunk to a resumable upload. The Upload-Offset header
+// gives the byte offset the chunk starts at, which must be the number of bytes already received.
+func (s *Server) HandleUploadChunk(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		respondErrorf(c, http.StatusBadRequest, "Upload-Offset header must be a non-negative byte offset")
+		return
+	}
+
+	// Chunks of large uploads outlast the server's default timeouts on slow connections
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetReadDeadline(time.Now().Add(uploadTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(uploadTimeout))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadChunkSize)
+
+	upload, err := s.fileService.UploadChunk(c, c.Param("id"), userID, offset, c.Request.Body)
+	if errors.Is(err, storage.ErrOffsetMismatch) {
+		// Tell the client where to resume from
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+		writeError(c, codedError(http.StatusConflict, err).WithDetail("received", upload.Received))
+		return
+	}
+	if err != nil {
+		s.respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	c.JSON(http.StatusOK, upload)
+}
+
+// HandleCompleteUpload handles turning a fully received resumable upload into a file and queuing its processing
+func (s *Server) HandleCompleteUpload(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	// Repeated requests with the same Idempotency-Key return the original file
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > 255 {
+		respondErrorf(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+
+	priority, ok := uploadPriority(c)
+	if !ok {
+		return
+	}
+
+	fileInfo, err := s.fileService.CompleteUpload(c, c.Param("id"), userID, idempotencyKey, priority)
+	if err != nil {
+		s.respondUploadError(c, err)
+		return
+	}
+
+	if fileInfo.Replayed {
+		// The earlier request already queued processing
+		c.Header("Idempotent-Replayed", "true")
+	} else if err := s.fileService.SubmitProcessingJob(fileInfo.JobID, fileInfo.ID, userID, fileInfo.Priority); err != nil {
+		// If the server is shutting down the job stays queued for the next start
+		errreport.Report(errreport.WithTags(requestContext(c), "jobID", fileInfo.JobID, "fileID", fileInfo.ID), "Failed to submit processing job", err)
+	}
+
+	c.JSON(http.StatusOK, FileUploadResponse{
+		ID:       fileInfo.ID,
+		FileName: fileInfo.FileName,
+		FileSize: fileInfo.FileSize,
+		FileType: fileInfo.FileType,
+		Status:   fileInfo.Status,
+		JobID:    fileInfo.JobID,
+		Priority: fileInfo.Priority,
+	})
+}
+
+// HandleAbortUpload handles discarding a resumable upload
+func (s *Server) HandleAbortUpload(c *gin.Context) {
+	// Get user ID from context
+	userID := c.MustGet("userID").(string)
+
+	if err := s.fileService.AbortUpload(c, c.Param("id"), userID); err != nil {
+		s.respondUploadError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondUploadError maps resumable upload errors to responses
+func (s *Server) respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUploadNotFound):
+		respondErrorf(c, http.StatusNotFound, "Upload not found")
+	case isTooLarge(err):
+		respondErrorf(c, http.StatusRequestEntityTooLarge, "Upload exceeds its declared size or the maximum allowed size of %dMB", s.fileService.MaxUploadSize()>>20)
+	case errors.Is(err, services.ErrFileTypeNotAllowed), errors.Is(err, services.ErrInvalidUploadSize):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrUploadIncomplete):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondErrorf(c, http.StatusInternalServerError, "Failed to upload file: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// UsageMiddleware meters an API call against the caller's org, rejecting it with 429 once the
+// org has used its plan's monthly API calls. It runs after authentication, reading the org set
+// by a session, API key or data share.
+func (s *Server) UsageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.GetString("orgID")
+		if share, ok := c.Get("share"); ok {
+			orgID = share.(*models.DataShare).OrgID
+		}
+		if orgID == "" {
+			c.Next()
+			return
+		}
+
+		if err := s.usageService.MeterAPICall(c, orgID); err != nil {
+			var limitErr *services.UsageLimitError
+			if !errors.As(err, &limitErr) {
+				// Metering never takes the API down with it
+				errreport.Report(requestContext(c), "Failed to meter API call", err)
+				c.Next()
+				return
+			}
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(*limitErr.ResetsAt).Seconds())+1))
+			respondError(c, http.StatusTooManyRequests, limitErr)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IngestionLimitMiddleware rejects uploads with 402 once the org has ingested its plan's monthly
+// rows or the upload would take it past its storage
+func (s *Server) IngestionLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.MustGet("orgID").(string)
+
+		if err := s.usageService.CheckIngestion(c, orgID, c.Request.ContentLength); err != nil {
+			var limitErr *services.UsageLimitError
+			if !errors.As(err, &limitErr) {
+				errreport.Report(requestContext(c), "Failed to check usage limits", err)
+				c.Next()
+				return
+			}
+			respondError(c, http.StatusPaymentRequired, limitErr)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HandleGetUsage handles getting the org's usage this month against its plan's limits
+func (s *Server) HandleGetUsage(c *gin.Context) {
+	// Get org ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	usage, err := s.usageService.Usage(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get usage: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// HandleGetUsageHistory handles listing the org's usage over recent months
+func (s *Server) HandleGetUsageHistory(c *gin.Context) {
+	// Get org ID from context
+	orgID := c.MustGet("orgID").(string)
+
+	months := 12
+	if value := c.Query("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondErrorf(c, http.StatusBadRequest, "months must be a positive number")
+			return
+		}
+		months = parsed
+	}
+
+	history, err := s.usageService.History(c, orgID, months)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to list usage: %v", err)
+		return
+	}
+
+	limits, err := s.usageService.Limits(c, orgID)
+	if err != nil {
+		respondErrorf(c, http.StatusInternalServerError, "Failed to get usage limits: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"months": history, "limits": limits})
+}
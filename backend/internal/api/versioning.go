@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/apierror"
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// API versions
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+)
+
+// API version statuses
+const (
+	versionCurrent    = "current"
+	versionDeprecated = "deprecated"
+	versionSunset     = "sunset"
+)
+
+// apiVersion is a version of the API. Every version serves the same routes and handlers; they
+// differ only in how responses with a converter for the version are marshaled.
+type apiVersion struct {
+	Name         string     `json:"name"`
+	Status       string     `json:"status"`
+	DeprecatedAt *time.Time `json:"deprecatedAt,omitempty"`
+	SunsetAt     *time.Time `json:"sunsetAt,omitempty"` // When the version stops working, once announced
+	Link         string     `json:"link,omitempty"`     // How to move off a deprecated version
+}
+
+// status returns whether the version is current, deprecated or sunset at now
+func (v apiVersion) status(now time.Time) string {
+	switch {
+	case v.SunsetAt != nil && !now.Before(*v.SunsetAt):
+		return versionSunset
+	case v.DeprecatedAt != nil:
+		return versionDeprecated
+	default:
+		return versionCurrent
+	}
+}
+
+// apiVersions returns the API's versions, oldest first
+func apiVersions(cfg config.APIConfig) []apiVersion {
+	v1 := apiVersion{Name: apiV1, DeprecatedAt: cfg.V1DeprecatedAt, SunsetAt: cfg.V1Sunset}
+	if v1.DeprecatedAt != nil {
+		v1.Link = cfg.MigrationGuideURL
+	}
+	return []apiVersion{v1, {Name: apiV2}}
+}
+
+// responseConverter converts a v1 response into a later version's shape, reporting whether it
+// converts responses of the body's type
+type responseConverter func(body any) (any, bool)
+
+// convertResponse makes a converter of responses of type T
+func convertResponse[T any](convert func(T) any) responseConverter {
+	return func(body any) (any, bool) {
+		typed, ok := body.(T)
+		if !ok {
+			return nil, false
+		}
+		return convert(typed), true
+	}
+}
+
+// responseConverters are the converters of each version after v1, by version. Responses without
+// a converter are sent as v1 sends them, so v2 only lists the responses whose schema changed.
+var responseConverters = map[string][]responseConverter{
+	apiV2: {},
+}
+
+// respond sends a JSON response marshaled for the request's API version, narrowed to the fields
+// the request's ?fields= lists if it lists any. Handlers whose responses differ between versions,
+// or that are large enough for clients to want only parts of, respond with it instead of c.JSON.
+func respond(c *gin.Context, status int, body any) {
+	for _, convert := range responseConverters[c.GetString("apiVersion")] {
+		if converted, ok := convert(body); ok {
+			body = converted
+			break
+		}
+	}
+
+	if fields, ok := c.GetQuery("fields"); ok {
+		set, err := parseFields(fields)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if body, err = selectFields(body, set); err != nil {
+			respondErrorf(c, http.StatusInternalServerError, "Failed to select fields: %v", err)
+			return
+		}
+	}
+
+	c.JSON(status, body)
+}
+
+// VersionMiddleware marks requests with the API version they were made to. Responses of a
+// deprecated version carry Deprecation and Sunset headers (RFC 9745, RFC 8594) linking to the
+// migration guide, and once it's sunset requests to it are answered with 410 Gone.
+func VersionMiddleware(version apiVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("apiVersion", version.Name)
+
+		if version.DeprecatedAt != nil {
+			c.Header("Deprecation", fmt.Sprintf("@%d", version.DeprecatedAt.Unix()))
+			if version.Link != "" {
+				c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, version.Link))
+			}
+		}
+		if version.SunsetAt != nil {
+			c.Header("Sunset", version.SunsetAt.UTC().Format(http.TimeFormat))
+			if version.Link != "" {
+				c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="sunset"; type="text/html"`, version.Link))
+			}
+			if version.status(time.Now()) == versionSunset {
+				writeError(c, apierror.New(http.StatusGone, apierror.CodeVersionSunset,
+					fmt.Sprintf("API %s was sunset on %s; use %s", version.Name, version.SunsetAt.UTC().Format("2006-01-02"), apiV2)))
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// HandleListAPIVersions handles listing the API's versions and when deprecated ones stop working
+func (s *Server) HandleListAPIVersions(c *gin.Context) {
+	now := time.Now()
+	versions := apiVersions(s.config.API)
+	for i := range versions {
+		versions[i].Status = versions[i].status(now)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions, "latest": versions[len(versions)-1].Name})
+}
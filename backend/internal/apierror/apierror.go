@@ -0,0 +1,121 @@
+// Package apierror defines the error responses the API sends: a machine-readable code clients
+// can branch on, a message for people, and the fields of the request that were invalid.
+//
+// Every error response has the same envelope:
+//
+//	{"error": {"code": "not_found", "message": "Job not found", "requestId": "..."}}
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Codes for each kind of error. Errors a client may want to tell apart from others with the
+// same status, such as which plan limit was reached, have codes of their own.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthenticated  = "unauthenticated"
+	CodePlanLimit        = "plan_limit_reached"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeUnprocessable    = "unprocessable"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "unavailable"
+
+	CodeAPICallLimit        = "api_call_limit_reached"
+	CodeRowLimit            = "row_limit_reached"
+	CodeStorageLimit        = "storage_limit_reached"
+	CodeDailyUploadLimit    = "daily_upload_limit_reached"
+	CodeFeatureNotInPlan    = "feature_not_in_plan"
+	CodeAlreadyQueued       = "already_queued"
+	CodeUnknownLogFormat    = "unknown_log_format"
+	CodeInvalidParseOptions = "invalid_parse_options"
+	CodeInvalidToken        = "invalid_token"
+	CodeTokenExpired        = "token_expired"
+	CodeSessionRevoked      = "session_revoked"
+	CodeVersionSunset       = "version_sunset"
+	CodeDecompressionLimit  = "decompression_limit_exceeded"
+)
+
+// statusCodes are the codes of errors that have no code of their own, by status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusPaymentRequired:       CodePlanLimit,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// CodeForStatus returns the code of an error response with a status, for errors without a code
+// of their own
+func CodeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// FieldError is why one field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error response
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	// Details are extra facts about the error, such as the values a field may take
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"requestId,omitempty"`
+}
+
+// New creates an error response. An empty code is filled in from the status.
+func New(status int, code, message string) *Error {
+	if code == "" {
+		code = CodeForStatus(status)
+	}
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Newf creates an error response coded by its status, with a formatted message
+func Newf(status int, format string, args ...any) *Error {
+	return New(status, "", fmt.Sprintf(format, args...))
+}
+
+// Error returns the message
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithField adds why a field is invalid
+func (e *Error) WithField(field, message string) *Error {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+	return e
+}
+
+// WithDetail adds a fact about the error
+func (e *Error) WithDetail(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
@@ -0,0 +1,492 @@
+package backup
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Backup archive format
+const (
+	Format        = "advantage-backup"
+	FormatVersion = 1
+)
+
+// Backup archive entries
+const (
+	manifestEntry = "manifest.json"
+	tablesDir     = "tables/"
+	analysesDir   = "analyses/"
+)
+
+// restoreBatchSize is how many rows are inserted per statement on restore
+const restoreBatchSize = 500
+
+// Tables are the tables a backup holds, in an order that restores parents before the rows
+// referencing them. Sessions, jobs and other transient state are left out, as are log records,
+// which reprocessing rebuilds, and integrations, whose refresh tokens are encrypted with the
+// environment's key and whose reports are pulled again once reconnected.
+var Tables = []string{
+	"organizations",
+	"users",
+	"user_preferences",
+	"digest_deliveries",
+	"files",
+	"file_schemas",
+	"datasets",
+	"dataset_files",
+	"delivery_report_rows",
+	"invoice_rows",
+	"category_overrides",
+	"mapping_profiles",
+	"brand_safety_lists",
+	"custom_metrics",
+	"report_templates",
+	"campaign_goals",
+	"campaign_annotations",
+	"experiments",
+	"exchange_rates",
+	"metric_rollups",
+	"rollup_files",
+}
+
+// Backup errors
+var (
+	ErrInvalidArchive   = errors.New("invalid backup archive")
+	ErrDatabaseNotEmpty = errors.New("database is not empty")
+)
+
+// Manifest describes a backup archive and checksums its entries
+type Manifest struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"formatVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Entries       []Entry   `json:"entries"`
+}
+
+// Entry is one file of a backup archive: a table's rows as JSON lines, or an analysis
+type Entry struct {
+	Name   string `json:"name"`
+	Table  string `json:"table,omitempty"`
+	Rows   int64  `json:"rows,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Counts returns the rows of each table in the archive and its number of analysis files
+func (m *Manifest) Counts() (map[string]int64, int) {
+	tables := make(map[string]int64)
+	analyses := 0
+	for _, entry := range m.Entries {
+		if entry.Table != "" {
+			tables[entry.Table] = entry.Rows
+		} else {
+			analyses++
+		}
+	}
+	return tables, analyses
+}
+
+// Export writes a backup archive of the metadata tables and the analyses under reportsDir, the
+// directory the log processor saves results in. The archive is streamed, so the manifest comes
+// last; readers find it through the ZIP directory.
+func Export(ctx context.Context, pool *pgxpool.Pool, reportsDir string, w io.Writer) (*Manifest, error) {
+	// A repeatable read transaction gives every table the same snapshot
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Whole-table reads of large tables outlast the pool's statement timeout
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return nil, fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+
+	manifest := &Manifest{Format: Format, FormatVersion: FormatVersion, CreatedAt: time.Now().UTC()}
+	archive := zip.NewWriter(w)
+
+	for _, table := range Tables {
+		entry, err := exportTable(ctx, tx, archive, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		manifest.Entries = append(manifest.Entries, *entry)
+	}
+
+	err = filepath.WalkDir(reportsDir, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && name == reportsDir {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		// Checkpoints only matter to processing that was under way
+		if d.IsDir() && d.Name() == "checkpoints" {
+			return fs.SkipDir
+		}
+		if !d.Type().IsRegular() || filepath.Ext(name) != ".json" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(reportsDir, name)
+		if err != nil {
+			return err
+		}
+		entry, err := exportFile(archive, name, analysesDir+filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", rel, err)
+		}
+		manifest.Entries = append(manifest.Entries, *entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	part, err := archive.Create(manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// exportTable writes a table's rows to the archive as JSON lines
+func exportTable(ctx context.Context, tx pgx.Tx, archive *zip.Writer, table string) (*Entry, error) {
+	entry := &Entry{Name: tablesDir + table + ".jsonl", Table: table}
+	part, err := archive.Create(entry.Name)
+	if err != nil {
+		return nil, err
+	}
+	counter := newHashCounter(part)
+
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT row_to_json(t)::text FROM %s t", pgx.Identifier{table}.Sanitize()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(counter, line+"\n"); err != nil {
+			return nil, err
+		}
+		entry.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entry.Size, entry.SHA256 = counter.size, counter.sum()
+	return entry, nil
+}
+
+// exportFile copies a file into the archive
+func exportFile(archive *zip.Writer, name, entryName string) (*Entry, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	part, err := archive.Create(entryName)
+	if err != nil {
+		return nil, err
+	}
+	counter := newHashCounter(part)
+	if _, err := io.Copy(counter, file); err != nil {
+		return nil, err
+	}
+
+	return &Entry{Name: entryName, Size: counter.size, SHA256: counter.sum()}, nil
+}
+
+// Restore restores a backup archive into a database that has been migrated but holds no users
+// yet, and its analyses into reportsDir. Tables are restored in one transaction, which is only
+// committed once every entry has matched its checksum. Rows already present, such as exchange
+// rates snapshotted since the database was created, are kept.
+func Restore(ctx context.Context, pool *pgxpool.Pool, reportsDir string, archive io.ReaderAt, size int64, limits storage.DecompressionLimits) (*Manifest, error) {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	budget := limits.Budget(size)
+	manifest, err := readManifest(files, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are read no further than the manifest says they go, so a manifest declaring more
+	// than the budget is refused before reading them
+	var declared uint64
+	entries := make(map[string]Entry, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		if _, ok := files[entry.Name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, entry.Name)
+		}
+		if entry.Size < 0 {
+			return nil, fmt.Errorf("%w: %s has a negative size", ErrInvalidArchive, entry.Name)
+		}
+		declared += uint64(entry.Size)
+		if entry.Table != "" {
+			entries[entry.Table] = entry
+		}
+	}
+	if err := budget.Check(declared); err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return nil, fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+
+	var populated bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users)").Scan(&populated); err != nil {
+		return nil, fmt.Errorf("failed to check for users: %w", err)
+	}
+	if populated {
+		return nil, fmt.Errorf("%w: restore into a freshly migrated database", ErrDatabaseNotEmpty)
+	}
+
+	for _, table := range Tables {
+		entry, ok := entries[table]
+		if !ok {
+			continue
+		}
+		if err := restoreTable(ctx, tx, files[entry.Name], entry, budget); err != nil {
+			return nil, err
+		}
+	}
+
+	// Analyses are written before the commit, so a damaged one leaves the database untouched
+	for _, entry := range manifest.Entries {
+		if entry.Table != "" {
+			continue
+		}
+		if err := restoreFile(files[entry.Name], entry, reportsDir, budget); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// readManifest reads and checks an archive's manifest, counting it against the archive's
+// decompression budget
+func readManifest(files map[string]*zip.File, budget *storage.DecompressionBudget) (*Manifest, error) {
+	file, ok := files[manifestEntry]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, manifestEntry)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, manifestEntry, err)
+	}
+	defer reader.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(budget.Reader(io.LimitReader(reader, 16<<20))).Decode(&manifest); err != nil {
+		if errors.Is(err, storage.ErrDecompressionLimit) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: malformed manifest: %v", ErrInvalidArchive, err)
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("%w: not a backup archive", ErrInvalidArchive)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidArchive, manifest.FormatVersion)
+	}
+
+	known := make(map[string]bool, len(Tables))
+	for _, table := range Tables {
+		known[table] = true
+	}
+	for _, entry := range manifest.Entries {
+		switch {
+		case entry.Table != "" && !known[entry.Table]:
+			return nil, fmt.Errorf("%w: unknown table %s", ErrInvalidArchive, entry.Table)
+		case entry.Table != "" && entry.Name != tablesDir+entry.Table+".jsonl":
+			return nil, fmt.Errorf("%w: unexpected entry %s for table %s", ErrInvalidArchive, entry.Name, entry.Table)
+		case entry.Table == "" && !isAnalysisEntry(entry.Name):
+			return nil, fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, entry.Name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// isAnalysisEntry reports whether an entry name is an analysis that stays inside the reports
+// directory once restored
+func isAnalysisEntry(name string) bool {
+	rel, ok := strings.CutPrefix(name, analysesDir)
+	return ok && path.Clean(name) == name && filepath.IsLocal(filepath.FromSlash(rel))
+}
+
+// restoreTable inserts a table's rows from its archive entry in batches
+func restoreTable(ctx context.Context, tx pgx.Tx, file *zip.File, entry Entry, budget *storage.DecompressionBudget) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, entry.Name, err)
+	}
+	defer reader.Close()
+
+	identifier := pgx.Identifier{entry.Table}.Sanitize()
+	insert := fmt.Sprintf("INSERT INTO %s SELECT * FROM json_populate_recordset(NULL::%s, $1::json) ON CONFLICT DO NOTHING", identifier, identifier)
+
+	var batch bytes.Buffer
+	pending := 0
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		batch.WriteByte(']')
+		if _, err := tx.Exec(ctx, insert, batch.String()); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", entry.Table, err)
+		}
+		batch.Reset()
+		pending = 0
+		return nil
+	}
+
+	// Entries are read at most one byte past their declared size, so an archive can't
+	// decompress into more than it claims, and within the archive's budget
+	counter := newHashCounter(io.Discard)
+	lines := bufio.NewReader(io.TeeReader(budget.Reader(io.LimitReader(reader, entry.Size+1)), counter))
+	var rows int64
+	for {
+		line, err := lines.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if pending == 0 {
+				batch.WriteByte('[')
+			} else {
+				batch.WriteByte(',')
+			}
+			batch.Write(line)
+			pending++
+			rows++
+			if pending == restoreBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, storage.ErrDecompressionLimit) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, entry.Name, err)
+		}
+	}
+	if counter.size != entry.Size || counter.sum() != entry.SHA256 || rows != entry.Rows {
+		return fmt.Errorf("%w: %s doesn't match its checksum", ErrInvalidArchive, entry.Name)
+	}
+
+	return flush()
+}
+
+// restoreFile writes an analysis from its archive entry under reportsDir
+func restoreFile(file *zip.File, entry Entry, reportsDir string, budget *storage.DecompressionBudget) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, entry.Name, err)
+	}
+	defer reader.Close()
+
+	name := filepath.Join(reportsDir, filepath.FromSlash(strings.TrimPrefix(entry.Name, analysesDir)))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", entry.Name, err)
+	}
+
+	counter := newHashCounter(out)
+	_, err = io.Copy(counter, budget.Reader(io.LimitReader(reader, entry.Size+1)))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && (counter.size != entry.Size || counter.sum() != entry.SHA256) {
+		err = fmt.Errorf("%w: %s doesn't match its checksum", ErrInvalidArchive, entry.Name)
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+
+	return nil
+}
+
+// hashCounter passes writes through while hashing and counting them
+type hashCounter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newHashCounter(w io.Writer) *hashCounter {
+	return &hashCounter{w: w, hash: sha256.New()}
+}
+
+func (h *hashCounter) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.hash.Write(p[:n])
+	h.size += int64(n)
+	return n, err
+}
+
+// sum returns the hex SHA-256 of everything written
+func (h *hashCounter) sum() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
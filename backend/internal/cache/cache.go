@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores serialized values by key with explicit invalidation
+type Cache interface {
+	// Get loads the value stored at key into dest, reporting whether it was found
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	// Set stores a value at key for the given duration
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// DeletePrefix removes every key that starts with the prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// New creates a cache from configuration, falling back to a no-op cache when Redis is not configured
+func New(cfg config.CacheConfig) (Cache, error) {
+	if cfg.RedisURL == "" {
+		return NoopCache{}, nil
+	}
+
+	return NewRedisCache(cfg.RedisURL, cfg.KeyPrefix)
+}
+
+// NoopCache never stores anything, so every lookup falls through to the source
+type NoopCache struct{}
+
+// Get always reports a miss
+func (NoopCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	return false, nil
+}
+
+// Set discards the value
+func (NoopCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return nil
+}
+
+// DeletePrefix does nothing
+func (NoopCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return nil
+}
+
+// MemoryCache stores JSON-encoded values in process memory, for caches that must work without
+// Redis. Expired entries are dropped when read or when a later Set finds them.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an empty in-process cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get loads and decodes the value stored at key
+func (c *MemoryCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(entry.data, dest); err != nil {
+		return false, fmt.Errorf("failed to decode cached value: %w", err)
+	}
+
+	return true, nil
+}
+
+// Set encodes and stores a value at key
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = memoryEntry{data: data, expires: now.Add(ttl)}
+
+	return nil
+}
+
+// DeletePrefix removes every key that starts with the prefix
+func (c *MemoryCache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
+
+// RedisCache stores JSON-encoded values in Redis
+type RedisCache struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisCache connects to Redis using a redis:// URL
+func NewRedisCache(url, keyPrefix string) (*RedisCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("unable to ping redis: %w", err)
+	}
+
+	return &RedisCache{client: client, keyPrefix: keyPrefix}, nil
+}
+
+// Get loads and decodes the value stored at key
+func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	data, err := c.client.Get(ctx, c.keyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, fmt.Errorf("failed to decode cached value: %w", err)
+	}
+
+	return true, nil
+}
+
+// Set encodes and stores a value at key
+func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+
+	if err := c.client.Set(ctx, c.keyPrefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every key starting with the prefix, scanning in batches so Redis isn't blocked
+func (c *RedisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := c.client.Scan(ctx, 0, c.keyPrefix+prefix+"*", 500).Iterator()
+
+	batch := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := c.client.Del(ctx, batch...).Err(); err != nil {
+				return fmt.Errorf("failed to invalidate cache: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan cache: %w", err)
+	}
+
+	if len(batch) > 0 {
+		if err := c.client.Del(ctx, batch...).Err(); err != nil {
+			return fmt.Errorf("failed to invalidate cache: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the Redis connection
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
@@ -4,32 +4,219 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Environment string
-	Port        int
-	JWT         JWTConfig
-	Database    DatabaseConfig
+	Environment     string
+	Port            int
+	ShutdownTimeout int // in seconds, covers draining processing jobs
+	JWT             JWTConfig
+	Database        DatabaseConfig
+	Narrative       NarrativeConfig
+	Cache           CacheConfig
+	LogRecords      LogRecordsConfig
+	CORS            CORSConfig
+	Secrets         SecretsConfig
+	Admin           AdminConfig
+	Diagnostics     DiagnosticsConfig
+	ErrorReporting  ErrorReportingConfig
+	Ingestion       IngestionConfig
+	Integrations    IntegrationsConfig
+	Google          GoogleConfig
+	SupplyAuth      SupplyAuthConfig
+	Email           EmailConfig
+	ExchangeRates   ExchangeRatesConfig
+	Events          EventsConfig
+	Exports         ExportsConfig
+	API             APIConfig
+	StorageGC       StorageGCConfig
+	Downloads       DownloadsConfig
 }
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret     string
-	Expiration int // in hours
+	Secret            string
+	Expiration        int // access tokens' lifetime, in hours
+	RefreshExpiration int // sessions' lifetime, which refreshing access tokens doesn't extend, in days
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host        string
+	Port        int
+	User        string
+	Password    string
+	DBName      string
+	SSLMode     string
+	ReplicaDSNs []string // read replicas for read-only queries
+
+	MaxConns                int // per pool, so each replica gets as many again
+	MinConns                int
+	ConnectTimeoutSeconds   int
+	StatementTimeoutSeconds int // server-side limit on every statement; 0 disables
+	QueryTimeoutSeconds     int // limit on analytics reads routed to the replicas; 0 disables
+
+	QueryExecMode          string // pgx query exec mode; "describe_exec" or "exec" behind PgBouncer in transaction mode
+	StatementCacheCapacity int    // prepared statements cached per connection
+	SlowQueryMilliseconds  int    // queries at least this slow are logged; 0 disables
+}
+
+// NarrativeConfig holds configuration for LLM-generated analysis narratives
+type NarrativeConfig struct {
+	Provider       string // "openai", "anthropic", or empty to disable
+	APIKey         string
+	Model          string
+	BaseURL        string
+	TimeoutSeconds int
+}
+
+// CacheConfig holds configuration for the analytics result cache
+type CacheConfig struct {
+	RedisURL   string // empty disables caching
+	KeyPrefix  string
+	TTLSeconds int
+}
+
+// LogRecordsConfig holds configuration for persisting individual log records
+type LogRecordsConfig struct {
+	Persist         bool
+	RetentionMonths int    // 0 keeps records forever
+	JourneyHashKey  string // keys the hashes that anonymize user IDs in journey exports; empty derives one from the JWT secret
+}
+
+// CORSConfig holds cross-origin request configuration
+type CORSConfig struct {
+	AllowedOrigins   []string // "*" allows any origin, but never with credentials
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // in seconds
+}
+
+// SecretsConfig holds configuration for loading credentials from a secrets backend.
+// The secret is a JSON object whose keys match the environment variables they override.
+type SecretsConfig struct {
+	Provider       string // "aws", "gcp", "vault", or empty to use environment variables only
+	Name           string
+	RefreshSeconds int
+	AWSRegion      string
+	GCPProject     string
+	VaultAddr      string
+	VaultToken     string
+	VaultMount     string
+}
+
+// AdminConfig holds configuration for runtime administration
+type AdminConfig struct {
+	Token        string // empty disables the admin endpoints
+	SettingsFile string // re-read on SIGHUP or reload requests
+}
+
+// DiagnosticsConfig holds configuration for the internal pprof and expvar listener
+type DiagnosticsConfig struct {
+	Host string // keep on a loopback or private interface, never the public one
+	Port int    // 0 disables the listener
+}
+
+// ErrorReportingConfig holds configuration for sending errors to an error tracking service
+type ErrorReportingConfig struct {
+	SentryDSN string // empty only logs errors
+}
+
+// IngestionConfig holds configuration for parsing uploaded logs
+type IngestionConfig struct {
+	ParseWorkers   int // 0 uses one per CPU
+	BreakdownLimit int // top N domains and hours kept in summaries; 0 keeps all
+	JobAttempts    int // tries a failing processing job gets before it is dead-lettered
+	// Archives are refused once they decompress into more than MaxDecompressionRatio times their
+	// compressed size, or more than MaxDecompressedMB in all; 0 is no limit
+	MaxDecompressionRatio int
+	MaxDecompressedMB     int
+}
+
+// IntegrationsConfig holds configuration for pulling data from connected ad platforms
+type IntegrationsConfig struct {
+	EncryptionKey       string // base64 AES-256 key for stored OAuth tokens; required to enable integrations
+	ReturnURL           string // where the browser is sent after connecting; empty responds with JSON
+	SyncIntervalMinutes int
+	LookbackDays        int // days re-pulled on every sync, since platforms restate recent conversions
+}
+
+// APIConfig holds configuration for retiring API versions. A deprecated version keeps working,
+// but its responses say so and when it will stop; after its sunset it answers 410 Gone.
+type APIConfig struct {
+	V1DeprecatedAt    *time.Time // nil while v1 isn't deprecated
+	V1Sunset          *time.Time // nil until v1's end is announced
+	MigrationGuideURL string     // linked from deprecated versions' responses
+}
+
+// StorageGCConfig holds configuration for sweeping stored uploads that have no file record, and
+// file records whose upload is gone
+type StorageGCConfig struct {
+	IntervalMinutes    int  // 0 disables scheduled sweeps; admins can still sweep on request
+	GraceMinutes       int  // uploads and records younger than this are left alone
+	Reconcile          bool // scheduled sweeps fix what they find rather than only reporting it
+	TrashRetentionDays int  // how long orphaned uploads stay in the trash before they're removed
+}
+
+// DownloadsConfig holds configuration for signed download URLs, which download a file without
+// signing in until they expire
+type DownloadsConfig struct {
+	SigningKey    string // empty uses a key derived from the JWT secret
+	ExpiryMinutes int
+}
+
+// ExportsConfig holds configuration for exporting data to users' warehouses. Credentials are
+// encrypted with the integrations key, so exports are only enabled along with integrations.
+type ExportsConfig struct {
+	SyncIntervalMinutes int
+}
+
+// GoogleConfig holds the OAuth client and API credentials for Google integrations
+type GoogleConfig struct {
+	ClientID           string // empty disables Google integrations
+	ClientSecret       string
+	RedirectURL        string // must point at /api/v1/integrations/google/callback
+	AdsDeveloperToken  string
+	AdsLoginCustomerID string // manager account to access client accounts through, if any
+}
+
+// SupplyAuthConfig holds configuration for checking supply paths against ads.txt and sellers.json
+type SupplyAuthConfig struct {
+	Enabled    bool
+	CacheHours int // how long crawled files are trusted before they're fetched again
+	MaxDomains int // domains checked per report, by bid volume; the rest are left unchecked
+}
+
+// EmailConfig holds configuration for sending email and the weekly digest
+type EmailConfig struct {
+	SMTPHost      string // empty disables email
+	SMTPPort      int
+	SMTPUsername  string // empty sends without authentication
+	SMTPPassword  string
+	From          string
+	DigestWeekday int // day the weekly digest is sent, 0 = Sunday, in UTC
+	DigestHour    int // hour of the day the weekly digest is sent, in UTC
+}
+
+// ExchangeRatesConfig holds configuration for the daily exchange rate snapshots spend is
+// converted between currencies with
+type ExchangeRatesConfig struct {
+	URL string // latest USD rates as {"base", "date", "rates"}; empty disables snapshots
+}
+
+// EventsConfig holds configuration for publishing upload, processing and alert events to a
+// message broker
+type EventsConfig struct {
+	Provider string // "kafka", "nats", or empty to disable
+	URL      string // Kafka REST Proxy URL, or nats:// or tls:// server URL; credentials go in the URL
+	Topic    string // Kafka topic, or NATS subject prefix the event type is appended to
 }
 
 // Load loads configuration from environment variables
@@ -46,11 +233,20 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid PORT: %w", err)
 	}
 
+	shutdownTimeout, err := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT_SECONDS: %w", err)
+	}
+
 	// JWT
 	jwtExpiration, err := strconv.Atoi(getEnv("JWT_EXPIRATION", "24"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid JWT_EXPIRATION: %w", err)
 	}
+	jwtRefreshExpiration, err := strconv.Atoi(getEnv("JWT_REFRESH_EXPIRATION", "30"))
+	if err != nil || jwtRefreshExpiration < 1 {
+		return nil, fmt.Errorf("invalid JWT_REFRESH_EXPIRATION: must be at least 1 day")
+	}
 
 	// Database
 	dbPort, err := strconv.Atoi(getEnv("DB_PORT", "5432"))
@@ -58,20 +254,320 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
 	}
 
+	dbMaxConns, err := strconv.Atoi(getEnv("DB_MAX_CONNS", "10"))
+	if err != nil || dbMaxConns < 1 {
+		return nil, fmt.Errorf("invalid DB_MAX_CONNS: must be at least 1")
+	}
+	dbMinConns, err := strconv.Atoi(getEnv("DB_MIN_CONNS", "2"))
+	if err != nil || dbMinConns < 0 || dbMinConns > dbMaxConns {
+		return nil, fmt.Errorf("invalid DB_MIN_CONNS: must be 0 to DB_MAX_CONNS")
+	}
+	dbConnectTimeout, err := strconv.Atoi(getEnv("DB_CONNECT_TIMEOUT_SECONDS", "10"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONNECT_TIMEOUT_SECONDS: %w", err)
+	}
+	dbStatementTimeout, err := strconv.Atoi(getEnv("DB_STATEMENT_TIMEOUT_SECONDS", "120"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_STATEMENT_TIMEOUT_SECONDS: %w", err)
+	}
+	dbQueryTimeout, err := strconv.Atoi(getEnv("DB_QUERY_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_QUERY_TIMEOUT_SECONDS: %w", err)
+	}
+
+	dbStatementCache, err := strconv.Atoi(getEnv("DB_STATEMENT_CACHE_CAPACITY", "512"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_STATEMENT_CACHE_CAPACITY: %w", err)
+	}
+	dbSlowQuery, err := strconv.Atoi(getEnv("DB_SLOW_QUERY_MS", "500"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_SLOW_QUERY_MS: %w", err)
+	}
+
+	// Narrative
+	narrativeTimeout, err := strconv.Atoi(getEnv("NARRATIVE_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NARRATIVE_TIMEOUT_SECONDS: %w", err)
+	}
+
+	// Cache
+	cacheTTL, err := strconv.Atoi(getEnv("CACHE_TTL_SECONDS", "300"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_TTL_SECONDS: %w", err)
+	}
+
+	// Log records
+	persistRecords, err := strconv.ParseBool(getEnv("LOG_RECORDS_PERSIST", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_RECORDS_PERSIST: %w", err)
+	}
+	recordRetention, err := strconv.Atoi(getEnv("LOG_RECORDS_RETENTION_MONTHS", "13"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_RECORDS_RETENTION_MONTHS: %w", err)
+	}
+
+	// CORS; development allows the local frontend by default, other environments must list origins
+	defaultOrigins := ""
+	if env == "development" {
+		defaultOrigins = "http://localhost:3000"
+	}
+	corsCredentials, err := strconv.ParseBool(getEnv("CORS_ALLOW_CREDENTIALS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS: %w", err)
+	}
+	corsMaxAge, err := strconv.Atoi(getEnv("CORS_MAX_AGE", "600"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS_MAX_AGE: %w", err)
+	}
+
+	// Diagnostics
+	diagnosticsPort, err := strconv.Atoi(getEnv("DIAGNOSTICS_PORT", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DIAGNOSTICS_PORT: %w", err)
+	}
+
+	// Ingestion
+	parseWorkers, err := strconv.Atoi(getEnv("PARSE_WORKERS", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PARSE_WORKERS: %w", err)
+	}
+	breakdownLimit, err := strconv.Atoi(getEnv("BREAKDOWN_TOP_N", "1000"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BREAKDOWN_TOP_N: %w", err)
+	}
+	jobAttempts, err := strconv.Atoi(getEnv("JOB_MAX_ATTEMPTS", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_MAX_ATTEMPTS: %w", err)
+	}
+	maxDecompressionRatio, err := strconv.Atoi(getEnv("INGESTION_MAX_DECOMPRESSION_RATIO", "200"))
+	if err != nil || maxDecompressionRatio < 0 {
+		return nil, fmt.Errorf("invalid INGESTION_MAX_DECOMPRESSION_RATIO: must be 0 (unlimited) or more")
+	}
+	maxDecompressedMB, err := strconv.Atoi(getEnv("INGESTION_MAX_DECOMPRESSED_MB", "4096"))
+	if err != nil || maxDecompressedMB < 0 {
+		return nil, fmt.Errorf("invalid INGESTION_MAX_DECOMPRESSED_MB: must be 0 (unlimited) or more")
+	}
+
+	// Integrations
+	syncInterval, err := strconv.Atoi(getEnv("INTEGRATIONS_SYNC_INTERVAL_MINUTES", "360"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INTEGRATIONS_SYNC_INTERVAL_MINUTES: %w", err)
+	}
+	syncLookback, err := strconv.Atoi(getEnv("INTEGRATIONS_LOOKBACK_DAYS", "7"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INTEGRATIONS_LOOKBACK_DAYS: %w", err)
+	}
+
+	// Signed downloads
+	downloadExpiry, err := strconv.Atoi(getEnv("DOWNLOAD_URL_EXPIRY_MINUTES", "15"))
+	if err != nil || downloadExpiry < 1 {
+		return nil, fmt.Errorf("invalid DOWNLOAD_URL_EXPIRY_MINUTES: must be 1 or more")
+	}
+
+	// Warehouse exports
+	exportInterval, err := strconv.Atoi(getEnv("EXPORTS_SYNC_INTERVAL_MINUTES", "60"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EXPORTS_SYNC_INTERVAL_MINUTES: %w", err)
+	}
+
+	// Storage garbage collection
+	gcInterval, err := strconv.Atoi(getEnv("STORAGE_GC_INTERVAL_MINUTES", "360"))
+	if err != nil || gcInterval < 0 {
+		return nil, fmt.Errorf("invalid STORAGE_GC_INTERVAL_MINUTES: must be 0 (disabled) or more")
+	}
+	gcGrace, err := strconv.Atoi(getEnv("STORAGE_GC_GRACE_MINUTES", "60"))
+	if err != nil || gcGrace < 1 {
+		return nil, fmt.Errorf("invalid STORAGE_GC_GRACE_MINUTES: must be 1 or more")
+	}
+	gcReconcile, err := strconv.ParseBool(getEnv("STORAGE_GC_RECONCILE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_GC_RECONCILE: %w", err)
+	}
+	gcTrashRetention, err := strconv.Atoi(getEnv("STORAGE_GC_TRASH_RETENTION_DAYS", "7"))
+	if err != nil || gcTrashRetention < 0 {
+		return nil, fmt.Errorf("invalid STORAGE_GC_TRASH_RETENTION_DAYS: must be 0 or more")
+	}
+
+	// Supply authorization
+	supplyAuthEnabled, err := strconv.ParseBool(getEnv("ADS_TXT_VALIDATION_ENABLED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADS_TXT_VALIDATION_ENABLED: %w", err)
+	}
+	supplyAuthCacheHours, err := strconv.Atoi(getEnv("ADS_TXT_CACHE_HOURS", "24"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADS_TXT_CACHE_HOURS: %w", err)
+	}
+	supplyAuthMaxDomains, err := strconv.Atoi(getEnv("ADS_TXT_MAX_DOMAINS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADS_TXT_MAX_DOMAINS: %w", err)
+	}
+
+	// Email
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+	}
+	digestWeekday, err := strconv.Atoi(getEnv("DIGEST_WEEKDAY", "1"))
+	if err != nil || digestWeekday < 0 || digestWeekday > 6 {
+		return nil, fmt.Errorf("invalid DIGEST_WEEKDAY: must be 0 (Sunday) to 6 (Saturday)")
+	}
+	digestHour, err := strconv.Atoi(getEnv("DIGEST_HOUR", "8"))
+	if err != nil || digestHour < 0 || digestHour > 23 {
+		return nil, fmt.Errorf("invalid DIGEST_HOUR: must be 0 to 23")
+	}
+
+	// API versions
+	v1DeprecatedAt, err := parseOptionalTime(getEnv("API_V1_DEPRECATED_AT", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_V1_DEPRECATED_AT: %w", err)
+	}
+	v1Sunset, err := parseOptionalTime(getEnv("API_V1_SUNSET", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_V1_SUNSET: %w", err)
+	}
+	if v1Sunset != nil && (v1DeprecatedAt == nil || v1Sunset.Before(*v1DeprecatedAt)) {
+		return nil, fmt.Errorf("invalid API_V1_SUNSET: v1 must be deprecated before it's sunset")
+	}
+
+	// Secrets
+	secretsRefresh, err := strconv.Atoi(getEnv("SECRETS_REFRESH_SECONDS", "300"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SECRETS_REFRESH_SECONDS: %w", err)
+	}
+
 	return &Config{
-		Environment: env,
-		Port:        port,
+		Environment:     env,
+		Port:            port,
+		ShutdownTimeout: shutdownTimeout,
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "your-secret-key"),
-			Expiration: jwtExpiration,
+			Secret:            getEnv("JWT_SECRET", "your-secret-key"),
+			Expiration:        jwtExpiration,
+			RefreshExpiration: jwtRefreshExpiration,
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     dbPort,
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "advantage"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        dbPort,
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", "postgres"),
+			DBName:      getEnv("DB_NAME", "advantage"),
+			SSLMode:     getEnv("DB_SSLMODE", "disable"),
+			ReplicaDSNs: splitList(getEnv("DB_REPLICA_DSNS", "")),
+
+			MaxConns:                dbMaxConns,
+			MinConns:                dbMinConns,
+			ConnectTimeoutSeconds:   dbConnectTimeout,
+			StatementTimeoutSeconds: dbStatementTimeout,
+			QueryTimeoutSeconds:     dbQueryTimeout,
+
+			QueryExecMode:          getEnv("DB_QUERY_EXEC_MODE", "cache_statement"),
+			StatementCacheCapacity: dbStatementCache,
+			SlowQueryMilliseconds:  dbSlowQuery,
+		},
+		Narrative: NarrativeConfig{
+			Provider:       getEnv("NARRATIVE_PROVIDER", ""),
+			APIKey:         getEnv("NARRATIVE_API_KEY", ""),
+			Model:          getEnv("NARRATIVE_MODEL", ""),
+			BaseURL:        getEnv("NARRATIVE_BASE_URL", ""),
+			TimeoutSeconds: narrativeTimeout,
+		},
+		Cache: CacheConfig{
+			RedisURL:   getEnv("REDIS_URL", ""),
+			KeyPrefix:  getEnv("CACHE_KEY_PREFIX", "advantage:"),
+			TTLSeconds: cacheTTL,
+		},
+		LogRecords: LogRecordsConfig{
+			Persist:         persistRecords,
+			RetentionMonths: recordRetention,
+			JourneyHashKey:  getEnv("JOURNEY_HASH_KEY", ""),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", defaultOrigins)),
+			AllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET, POST, PUT, PATCH, DELETE, OPTIONS")),
+			AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, Idempotency-Key, Upload-Offset, X-Request-ID")),
+			ExposedHeaders:   splitList(getEnv("CORS_EXPOSED_HEADERS", "Idempotent-Replayed, Upload-Offset, X-Request-ID")),
+			AllowCredentials: corsCredentials,
+			MaxAge:           corsMaxAge,
+		},
+		Secrets: SecretsConfig{
+			Provider:       getEnv("SECRETS_PROVIDER", ""),
+			Name:           getEnv("SECRETS_NAME", ""),
+			RefreshSeconds: secretsRefresh,
+			AWSRegion:      getEnv("AWS_REGION", ""),
+			GCPProject:     getEnv("GCP_PROJECT", ""),
+			VaultAddr:      getEnv("VAULT_ADDR", ""),
+			VaultToken:     getEnv("VAULT_TOKEN", ""),
+			VaultMount:     getEnv("VAULT_MOUNT", "secret"),
+		},
+		Admin: AdminConfig{
+			Token:        getEnv("ADMIN_TOKEN", ""),
+			SettingsFile: getEnv("SETTINGS_FILE", ".env"),
+		},
+		Diagnostics: DiagnosticsConfig{
+			Host: getEnv("DIAGNOSTICS_HOST", "127.0.0.1"),
+			Port: diagnosticsPort,
+		},
+		ErrorReporting: ErrorReportingConfig{
+			SentryDSN: getEnv("SENTRY_DSN", ""),
+		},
+		Ingestion: IngestionConfig{
+			ParseWorkers:          parseWorkers,
+			BreakdownLimit:        breakdownLimit,
+			JobAttempts:           jobAttempts,
+			MaxDecompressionRatio: maxDecompressionRatio,
+			MaxDecompressedMB:     maxDecompressedMB,
+		},
+		Integrations: IntegrationsConfig{
+			EncryptionKey:       getEnv("INTEGRATIONS_ENCRYPTION_KEY", ""),
+			ReturnURL:           getEnv("INTEGRATIONS_RETURN_URL", ""),
+			SyncIntervalMinutes: syncInterval,
+			LookbackDays:        syncLookback,
+		},
+		Google: GoogleConfig{
+			ClientID:           getEnv("GOOGLE_OAUTH_CLIENT_ID", ""),
+			ClientSecret:       getEnv("GOOGLE_OAUTH_CLIENT_SECRET", ""),
+			RedirectURL:        getEnv("GOOGLE_OAUTH_REDIRECT_URL", ""),
+			AdsDeveloperToken:  getEnv("GOOGLE_ADS_DEVELOPER_TOKEN", ""),
+			AdsLoginCustomerID: getEnv("GOOGLE_ADS_LOGIN_CUSTOMER_ID", ""),
+		},
+		SupplyAuth: SupplyAuthConfig{
+			Enabled:    supplyAuthEnabled,
+			CacheHours: supplyAuthCacheHours,
+			MaxDomains: supplyAuthMaxDomains,
+		},
+		Email: EmailConfig{
+			SMTPHost:      getEnv("SMTP_HOST", ""),
+			SMTPPort:      smtpPort,
+			SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+			From:          getEnv("EMAIL_FROM", ""),
+			DigestWeekday: digestWeekday,
+			DigestHour:    digestHour,
+		},
+		ExchangeRates: ExchangeRatesConfig{
+			URL: getEnv("EXCHANGE_RATES_URL", "https://api.frankfurter.app/latest?from=USD"),
+		},
+		Events: EventsConfig{
+			Provider: getEnv("EVENTS_PROVIDER", ""),
+			URL:      getEnv("EVENTS_URL", ""),
+			Topic:    getEnv("EVENTS_TOPIC", "advantage.events"),
+		},
+		Exports: ExportsConfig{
+			SyncIntervalMinutes: exportInterval,
+		},
+		API: APIConfig{
+			V1DeprecatedAt:    v1DeprecatedAt,
+			V1Sunset:          v1Sunset,
+			MigrationGuideURL: getEnv("API_MIGRATION_GUIDE_URL", ""),
+		},
+		StorageGC: StorageGCConfig{
+			IntervalMinutes:    gcInterval,
+			GraceMinutes:       gcGrace,
+			Reconcile:          gcReconcile,
+			TrashRetentionDays: gcTrashRetention,
+		},
+		Downloads: DownloadsConfig{
+			SigningKey:    getEnv("DOWNLOAD_SIGNING_KEY", ""),
+			ExpiryMinutes: downloadExpiry,
 		},
 	}, nil
 }
@@ -85,6 +581,32 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseOptionalTime parses an optional YYYY-MM-DD date, as midnight UTC, or RFC 3339 time
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("expected YYYY-MM-DD or RFC 3339 time")
+	}
+	return &t, nil
+}
+
 // GetDSN returns the PostgreSQL connection string
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -0,0 +1,363 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockNotAvailable is the SQLSTATE Postgres reports when a statement gives up waiting for a lock
+const lockNotAvailable = "55P03"
+
+// maxIdentifierLength is the longest name Postgres keeps for an identifier
+const maxIdentifierLength = 63
+
+// defaultBackfillBatchSize is how many rows a backfill updates per statement when none is given
+const defaultBackfillBatchSize = 5000
+
+// OnlineMigrator applies schema changes to large, busy tables such as log_records while the
+// platform keeps serving. DDL waits at most the lock timeout for its lock and is retried instead of
+// queueing every other query on the table behind it, indexes are built concurrently, and data is
+// backfilled in small batches.
+//
+// Changes that old instances can't run against are split expand/contract: the expand step (new
+// nullable columns, new indexes, backfills) ships before the code that uses it, and the contract
+// step (drops) only after no running instance depends on what it removes.
+type OnlineMigrator struct {
+	pool        *pgxpool.Pool
+	lockTimeout time.Duration
+	retries     int
+}
+
+// Online returns a migrator that waits at most lockTimeout for each lock and retries DDL that times out
+func (db *PostgresDB) Online(lockTimeout time.Duration) *OnlineMigrator {
+	return &OnlineMigrator{
+		pool:        db.Pool,
+		lockTimeout: lockTimeout,
+		retries:     5,
+	}
+}
+
+// Exec runs a DDL statement under the lock timeout, retrying with backoff while the table is busy
+func (m *OnlineMigrator) Exec(ctx context.Context, statement string) error {
+	return m.retry(ctx, statement, nil)
+}
+
+// retry runs a statement until it gets its locks, calling cleanup before each retry to undo
+// whatever the timed out attempt left behind
+func (m *OnlineMigrator) retry(ctx context.Context, statement string, cleanup func(context.Context) error) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := m.exec(ctx, statement)
+		if err == nil || !isLockTimeout(err) || attempt >= m.retries {
+			return err
+		}
+
+		slog.Warn("Migration statement timed out waiting for a lock, retrying", "attempt", attempt+1, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if cleanup != nil {
+			if err := cleanup(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exec runs a statement on a dedicated connection with the lock timeout set for the session
+func (m *OnlineMigrator) exec(ctx context.Context, statement string) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	// Index builds on big tables legitimately outlast the pool's statement timeout
+	if _, err := conn.Exec(ctx, fmt.Sprintf("SET lock_timeout = %d; SET statement_timeout = 0", m.lockTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+	defer conn.Exec(context.Background(), "RESET lock_timeout; RESET statement_timeout")
+
+	_, err = conn.Exec(ctx, statement)
+	return err
+}
+
+// CreateIndex creates an index without blocking writes to the table. Plain tables get
+// CREATE INDEX CONCURRENTLY. Partitioned tables don't support it, so the index is created invalid
+// on the parent alone, built concurrently on each partition, and attached partition by partition,
+// which makes the parent index valid once every partition has it. An index left invalid by an
+// interrupted build is dropped and rebuilt, so the migration can simply be run again.
+func (m *OnlineMigrator) CreateIndex(ctx context.Context, name, table, columns string) error {
+	valid, exists, err := m.indexState(ctx, name)
+	if err != nil {
+		return err
+	}
+	if valid {
+		return nil
+	}
+
+	partitions, partitioned, err := m.partitions(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	if !partitioned {
+		if exists {
+			if err := m.DropIndex(ctx, name); err != nil {
+				return err
+			}
+		}
+		return m.createConcurrently(ctx, name, table, columns)
+	}
+
+	if err := m.Exec(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON ONLY %s (%s)",
+		pgx.Identifier{name}.Sanitize(), pgx.Identifier{table}.Sanitize(), columns)); err != nil {
+		return fmt.Errorf("failed to create index %s on %s: %w", name, table, err)
+	}
+
+	for i, partition := range partitions {
+		// Partitions created since the parent index have it cloned and attached already
+		attached, err := m.hasAttachedIndex(ctx, name, partition)
+		if err != nil {
+			return err
+		}
+		if attached {
+			continue
+		}
+
+		child := partitionIndexName(partition, name)
+		childValid, childExists, err := m.indexState(ctx, child)
+		if err != nil {
+			return err
+		}
+		if childExists && !childValid {
+			if err := m.DropIndex(ctx, child); err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+		if err := m.createConcurrently(ctx, child, partition, columns); err != nil {
+			return fmt.Errorf("failed to create index %s on %s: %w", child, partition, err)
+		}
+		if err := m.Exec(ctx, fmt.Sprintf("ALTER INDEX %s ATTACH PARTITION %s",
+			pgx.Identifier{name}.Sanitize(), pgx.Identifier{child}.Sanitize())); err != nil {
+			return fmt.Errorf("failed to attach index %s to %s: %w", child, name, err)
+		}
+
+		slog.Info("Indexed partition", "index", name, "partition", partition,
+			"done", i+1, "total", len(partitions), "duration", time.Since(start))
+	}
+
+	return nil
+}
+
+// createConcurrently builds an index on a plain table or partition without blocking writes.
+// A build that times out waiting for a lock leaves an invalid index behind, which is dropped
+// before the build is retried.
+func (m *OnlineMigrator) createConcurrently(ctx context.Context, name, table, columns string) error {
+	statement := fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)",
+		pgx.Identifier{name}.Sanitize(), pgx.Identifier{table}.Sanitize(), columns)
+	return m.retry(ctx, statement, func(ctx context.Context) error {
+		return m.exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+pgx.Identifier{name}.Sanitize())
+	})
+}
+
+// DropIndex drops an index without blocking queries on its table. Dropping an index is a
+// contract step: only run it once no deployed code relies on the index.
+func (m *OnlineMigrator) DropIndex(ctx context.Context, name string) error {
+	return m.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+pgx.Identifier{name}.Sanitize())
+}
+
+// Backfill describes a batched update of existing rows, such as filling a newly added column
+type Backfill struct {
+	// Table is the table to update; partitioned tables are backfilled one partition at a time
+	Table string
+	// Set is the SET clause applied to each row, e.g. "region_code = upper(geo_region)"
+	Set string
+	// Where selects the rows still to be backfilled and must stop matching a row once Set has been
+	// applied to it, e.g. "region_code IS NULL", or the backfill never finishes
+	Where string
+	// BatchSize is how many rows each statement updates
+	BatchSize int
+	// Pause is how long to wait between batches so replication and autovacuum keep up
+	Pause time.Duration
+	// Progress, when set, is called after every batch
+	Progress func(BackfillProgress)
+}
+
+// BackfillProgress reports how far a backfill has got
+type BackfillProgress struct {
+	Table     string        `json:"table"`
+	Partition string        `json:"partition,omitempty"`
+	Batches   int           `json:"batches"`
+	Updated   int64         `json:"updated"`
+	Estimated int64         `json:"estimated"`
+	Elapsed   time.Duration `json:"elapsed"`
+}
+
+// Backfill updates the rows matching the backfill's condition in batches, each its own short
+// transaction, so no statement holds row locks on more than a batch of rows at a time.
+// It returns the number of rows updated.
+func (m *OnlineMigrator) Backfill(ctx context.Context, b Backfill) (int64, error) {
+	if b.Set == "" || b.Where == "" {
+		return 0, errors.New("backfill needs both a SET clause and a WHERE condition")
+	}
+	if b.BatchSize <= 0 {
+		b.BatchSize = defaultBackfillBatchSize
+	}
+
+	partitions, partitioned, err := m.partitions(ctx, b.Table)
+	if err != nil {
+		return 0, err
+	}
+	if !partitioned {
+		partitions = []string{b.Table}
+	}
+
+	// The planner's row estimate is good enough for progress and doesn't scan the table
+	progress := BackfillProgress{Table: b.Table}
+	for _, table := range partitions {
+		var estimate float64
+		if err := m.pool.QueryRow(ctx, "SELECT GREATEST(reltuples, 0)::float8 FROM pg_class WHERE oid = $1::regclass",
+			pgx.Identifier{table}.Sanitize()).Scan(&estimate); err != nil {
+			return 0, fmt.Errorf("failed to estimate rows of %s: %w", table, err)
+		}
+		progress.Estimated += int64(estimate)
+	}
+
+	start := time.Now()
+	for _, table := range partitions {
+		if partitioned {
+			progress.Partition = table
+		}
+
+		identifier := pgx.Identifier{table}.Sanitize()
+		statement := fmt.Sprintf(
+			"UPDATE %s SET %s WHERE ctid = ANY(ARRAY(SELECT ctid FROM %s WHERE %s LIMIT %d))",
+			identifier, b.Set, identifier, b.Where, b.BatchSize,
+		)
+
+		for {
+			tag, err := m.pool.Exec(ctx, statement)
+			if err != nil {
+				return progress.Updated, fmt.Errorf("failed to backfill %s: %w", table, err)
+			}
+
+			progress.Batches++
+			progress.Updated += tag.RowsAffected()
+			progress.Elapsed = time.Since(start)
+			if b.Progress != nil {
+				b.Progress(progress)
+			}
+
+			if tag.RowsAffected() < int64(b.BatchSize) {
+				slog.Info("Backfilled table", "table", table, "updated", progress.Updated,
+					"estimated", progress.Estimated, "elapsed", progress.Elapsed)
+				break
+			}
+
+			if b.Pause > 0 {
+				select {
+				case <-ctx.Done():
+					return progress.Updated, ctx.Err()
+				case <-time.After(b.Pause):
+				}
+			}
+		}
+	}
+
+	return progress.Updated, nil
+}
+
+// indexState reports whether an index exists and whether it is valid for queries
+func (m *OnlineMigrator) indexState(ctx context.Context, name string) (valid, exists bool, err error) {
+	err = m.pool.QueryRow(ctx, `
+		SELECT pg_index.indisvalid
+		FROM pg_index
+		JOIN pg_class ON pg_class.oid = pg_index.indexrelid
+		WHERE pg_class.relname = $1 AND pg_class.relnamespace = 'public'::regnamespace
+	`, name).Scan(&valid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to check index %s: %w", name, err)
+	}
+
+	return valid, true, nil
+}
+
+// hasAttachedIndex reports whether a partition has an index attached to the partitioned index
+func (m *OnlineMigrator) hasAttachedIndex(ctx context.Context, index, partition string) (bool, error) {
+	var attached bool
+	err := m.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM pg_inherits
+			JOIN pg_index ON pg_index.indexrelid = pg_inherits.inhrelid
+			WHERE pg_inherits.inhparent = $1::regclass AND pg_index.indrelid = $2::regclass
+		)
+	`, pgx.Identifier{index}.Sanitize(), pgx.Identifier{partition}.Sanitize()).Scan(&attached)
+	if err != nil {
+		return false, fmt.Errorf("failed to check index %s on %s: %w", index, partition, err)
+	}
+
+	return attached, nil
+}
+
+// partitions returns a table's partitions in name order and whether the table is partitioned at all
+func (m *OnlineMigrator) partitions(ctx context.Context, table string) ([]string, bool, error) {
+	var kind string
+	if err := m.pool.QueryRow(ctx, "SELECT relkind::text FROM pg_class WHERE oid = $1::regclass",
+		pgx.Identifier{table}.Sanitize()).Scan(&kind); err != nil {
+		return nil, false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	if kind != "p" {
+		return nil, false, nil
+	}
+
+	rows, err := m.pool.Query(ctx, `
+		SELECT child.relname
+		FROM pg_inherits
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE pg_inherits.inhparent = $1::regclass
+		ORDER BY child.relname
+	`, pgx.Identifier{table}.Sanitize())
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+	}
+
+	partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+	}
+
+	return partitions, true, nil
+}
+
+// partitionIndexName names a partition's share of a partitioned index, within Postgres' identifier limit
+func partitionIndexName(partition, index string) string {
+	name := partition + "_" + index
+	if len(name) > maxIdentifierLength {
+		name = name[:maxIdentifierLength]
+	}
+	return name
+}
+
+// isLockTimeout reports whether a statement failed because it waited too long for a lock
+func isLockTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == lockNotAvailable
+}
@@ -3,37 +3,121 @@ package db
 import (
 	"context"
 	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// PostgresDB represents a PostgreSQL database connection
+// replicaHealthInterval is how often read replicas are pinged
+const replicaHealthInterval = 15 * time.Second
+
+// PostgresDB represents a PostgreSQL database connection with optional read replicas
 type PostgresDB struct {
-	Pool *pgxpool.Pool
+	Pool         *pgxpool.Pool
+	replicas     []*replica
+	next         atomic.Uint64
+	queryTimeout time.Duration
+	metrics      *QueryMetrics
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// replica is a read replica connection pool and its last known health
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// CredentialsFunc returns the current database user and password
+type CredentialsFunc func() (user, password string)
+
+// Option configures a PostgresDB
+type Option func(*options)
+
+// options holds the optional settings of a PostgresDB
+type options struct {
+	credentials CredentialsFunc
+}
+
+// WithCredentials looks up the primary's credentials for every new connection,
+// so rotated passwords take effect without a restart
+func WithCredentials(credentials CredentialsFunc) Option {
+	return func(o *options) {
+		o.credentials = credentials
+	}
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
-func NewPostgresDB(cfg config.DatabaseConfig) (*PostgresDB, error) {
-	dsn := cfg.GetDSN()
+func NewPostgresDB(cfg config.DatabaseConfig, opts ...Option) (*PostgresDB, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	// Configure the connection pool
-	poolConfig, err := pgxpool.ParseConfig(dsn)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout(cfg))
+	defer cancel()
+
+	metrics := NewQueryMetrics(time.Duration(cfg.SlowQueryMilliseconds) * time.Millisecond)
+	pool, err := newPool(ctx, cfg, metrics, o.credentials)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse pool config: %w", err)
+		return nil, err
 	}
 
-	// Set pool configuration
-	poolConfig.MaxConns = 10
-	poolConfig.MinConns = 2
-	poolConfig.MaxConnLifetime = time.Hour
-	poolConfig.MaxConnIdleTime = 30 * time.Minute
+	db := &PostgresDB{
+		Pool:         pool,
+		queryTimeout: time.Duration(cfg.QueryTimeoutSeconds) * time.Second,
+		metrics:      metrics,
+		stop:         make(chan struct{}),
+	}
 
-	// Create the connection pool
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Connect to read replicas; an unreachable replica is marked unhealthy rather than failing startup
+	for i, dsn := range cfg.ReplicaDSNs {
+		poolConfig, err := parsePoolConfig(dsn, cfg, metrics)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid replica %d: %w", i, err)
+		}
 
+		replicaPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to create replica %d connection pool: %w", i, err)
+		}
+
+		r := &replica{pool: replicaPool}
+		r.healthy.Store(replicaPool.Ping(ctx) == nil)
+		db.replicas = append(db.replicas, r)
+	}
+
+	if len(db.replicas) > 0 {
+		db.wg.Add(1)
+		go db.checkReplicas()
+	}
+
+	return db, nil
+}
+
+// newPool creates a connection pool and verifies it can reach the database
+func newPool(ctx context.Context, cfg config.DatabaseConfig, metrics *QueryMetrics, credentials CredentialsFunc) (*pgxpool.Pool, error) {
+	poolConfig, err := parsePoolConfig(cfg.GetDSN(), cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	if credentials != nil {
+		poolConfig.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			connConfig.User, connConfig.Password = credentials()
+			return nil
+		}
+	}
+
+	// Create the connection pool
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create connection pool: %w", err)
@@ -45,16 +129,181 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*PostgresDB, error) {
 		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
 
-	return &PostgresDB{Pool: pool}, nil
+	return pool, nil
+}
+
+// parsePoolConfig parses a DSN and applies the pool settings, tracing queries into metrics
+func parsePoolConfig(dsn string, cfg config.DatabaseConfig, metrics *QueryMetrics) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse pool config: %w", err)
+	}
+
+	// Set pool configuration
+	poolConfig.MaxConns = 10
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxConns)
+	}
+	poolConfig.MinConns = min(int32(cfg.MinConns), poolConfig.MaxConns)
+	poolConfig.MaxConnLifetime = time.Hour
+	poolConfig.MaxConnIdleTime = 30 * time.Minute
+	poolConfig.ConnConfig.ConnectTimeout = connectTimeout(cfg)
+
+	// Have the server cancel runaway statements so they can't hold connections indefinitely
+	if cfg.StatementTimeoutSeconds > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(cfg.StatementTimeoutSeconds * 1000)
+	}
+
+	// Prepared statements are cached per connection, so hot repository queries are parsed and
+	// planned once; modes without the cache suit poolers that don't keep connections per client
+	execMode, err := parseQueryExecMode(cfg.QueryExecMode)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.DefaultQueryExecMode = execMode
+	if cfg.StatementCacheCapacity > 0 {
+		poolConfig.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+		poolConfig.ConnConfig.DescriptionCacheCapacity = cfg.StatementCacheCapacity
+	}
+	poolConfig.ConnConfig.Tracer = metrics
+
+	// Scope row-level security to the org of the query's context
+	poolConfig.BeforeAcquire = setOrg
+
+	return poolConfig, nil
+}
+
+// parseQueryExecMode parses the name of a pgx query exec mode; empty keeps the default
+func parseQueryExecMode(mode string) (pgx.QueryExecMode, error) {
+	switch mode {
+	case "", "cache_statement":
+		return pgx.QueryExecModeCacheStatement, nil
+	case "cache_describe":
+		return pgx.QueryExecModeCacheDescribe, nil
+	case "describe_exec":
+		return pgx.QueryExecModeDescribeExec, nil
+	case "exec":
+		return pgx.QueryExecModeExec, nil
+	case "simple_protocol":
+		return pgx.QueryExecModeSimpleProtocol, nil
+	default:
+		return 0, fmt.Errorf("unknown query exec mode %q", mode)
+	}
+}
+
+// connectTimeout returns how long establishing a connection may take
+func connectTimeout(cfg config.DatabaseConfig) time.Duration {
+	if cfg.ConnectTimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(cfg.ConnectTimeoutSeconds) * time.Second
+}
+
+// checkReplicas periodically pings the replicas so unhealthy ones are skipped and recovered ones rejoin
+func (db *PostgresDB) checkReplicas() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(replicaHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			for _, r := range db.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				r.healthy.Store(r.pool.Ping(ctx) == nil)
+				cancel()
+			}
+		}
+	}
+}
+
+// readPool returns the next healthy replica in round-robin order, or the primary when none is healthy
+func (db *PostgresDB) readPool() (*pgxpool.Pool, *replica) {
+	n := len(db.replicas)
+	if n == 0 {
+		return db.Pool, nil
+	}
+
+	start := db.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := db.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.pool, r
+		}
+	}
+
+	return db.Pool, nil
+}
+
+// QueryMetrics returns the metrics of the queries run on the primary and the replicas
+func (db *PostgresDB) QueryMetrics() *QueryMetrics {
+	return db.metrics
+}
+
+// WritePoolMetrics writes the primary pool's connection usage in the Prometheus text exposition format
+func (db *PostgresDB) WritePoolMetrics(w io.Writer) {
+	stat := db.Pool.Stat()
+	gauges := []struct {
+		name, help string
+		value      any
+	}{
+		{"advantage_db_pool_max_connections", "Connections the pool may open.", stat.MaxConns()},
+		{"advantage_db_pool_connections", "Connections open in the pool.", stat.TotalConns()},
+		{"advantage_db_pool_acquired_connections", "Connections in use.", stat.AcquiredConns()},
+		{"advantage_db_pool_idle_connections", "Connections open but idle.", stat.IdleConns()},
+	}
+	for _, gauge := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", gauge.name, gauge.help, gauge.name, gauge.name, gauge.value)
+	}
+
+	fmt.Fprintf(w, "# HELP advantage_db_pool_empty_acquires_total Acquires that waited for a connection.\n# TYPE advantage_db_pool_empty_acquires_total counter\nadvantage_db_pool_empty_acquires_total %d\n", stat.EmptyAcquireCount())
+	fmt.Fprintf(w, "# HELP advantage_db_pool_acquire_seconds_total Time spent acquiring connections.\n# TYPE advantage_db_pool_acquire_seconds_total counter\nadvantage_db_pool_acquire_seconds_total %g\n", stat.AcquireDuration().Seconds())
+}
+
+// Reader returns a connection that routes read-only queries to the replicas
+func (db *PostgresDB) Reader() *ReadRouter {
+	return &ReadRouter{db: db}
 }
 
-// Close closes the database connection
+// Close closes the database connections
 func (db *PostgresDB) Close() {
+	if db.stop != nil {
+		select {
+		case <-db.stop:
+		default:
+			close(db.stop)
+		}
+		db.wg.Wait()
+	}
+
+	for _, r := range db.replicas {
+		r.pool.Close()
+	}
 	if db.Pool != nil {
 		db.Pool.Close()
 	}
 }
 
+// MissingTables returns the tables among the given names that don't exist in the public schema,
+// which indicates that migrations have not been applied
+func (db *PostgresDB) MissingTables(ctx context.Context, tables ...string) ([]string, error) {
+	var missing []string
+	for _, table := range tables {
+		var exists bool
+		if err := db.Pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", "public."+table).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+
+	return missing, nil
+}
+
 // Ping checks if the database connection is alive
 func (db *PostgresDB) Ping(ctx context.Context) error {
 	return db.Pool.Ping(ctx)
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// queryDurationBuckets are the upper bounds, in seconds, of the query duration histogram
+var queryDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// maxLoggedSQLLength caps how much of a slow query's SQL is logged
+const maxLoggedSQLLength = 500
+
+// queryNamePrefix names a query explicitly when it starts its SQL, e.g. "-- name: rollup_series"
+const queryNamePrefix = "-- name:"
+
+// queryStartKey is the context key of a traced query's start
+type queryStartKey struct{}
+
+// queryStart is what a traced query's end needs from its start
+type queryStart struct {
+	sql  string
+	name string
+	at   time.Time
+}
+
+// queryStats accumulate a query's executions since the process started
+type queryStats struct {
+	count   int64
+	errors  int64
+	rows    int64
+	seconds float64
+	buckets []int64
+}
+
+// QueryMetrics traces every query on the database's pools, keeping a duration histogram and
+// row and error counts per query name for Prometheus, and logging queries slower than the
+// threshold so the ones behind slow dashboards can be found.
+//
+// Queries are named after their first comment line when it is "-- name: <name>", and otherwise
+// after their statement type and main table, such as "select metric_rollups".
+type QueryMetrics struct {
+	slowThreshold time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*queryStats
+}
+
+// NewQueryMetrics creates query metrics that log queries taking at least slowThreshold; zero disables the log
+func NewQueryMetrics(slowThreshold time.Duration) *QueryMetrics {
+	return &QueryMetrics{
+		slowThreshold: slowThreshold,
+		stats:         make(map[string]*queryStats),
+	}
+}
+
+// TraceQueryStart notes when a query starts
+func (m *QueryMetrics) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, &queryStart{
+		sql:  data.SQL,
+		name: queryName(data.SQL),
+		at:   time.Now(),
+	})
+}
+
+// TraceQueryEnd records a finished query. For queries returning rows it runs once the rows are
+// read, so the duration includes fetching them.
+func (m *QueryMetrics) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(*queryStart)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(start.at)
+	rows := data.CommandTag.RowsAffected()
+	m.record(start.name, duration, rows, data.Err != nil)
+
+	if m.slowThreshold > 0 && duration >= m.slowThreshold {
+		sql := strings.Join(strings.Fields(start.sql), " ")
+		if len(sql) > maxLoggedSQLLength {
+			sql = sql[:maxLoggedSQLLength] + "..."
+		}
+		slog.Warn("Slow query", "query", start.name, "duration", duration, "rows", rows, "error", data.Err, "sql", sql)
+	}
+}
+
+// record adds an execution to a query's stats
+func (m *QueryMetrics) record(name string, duration time.Duration, rows int64, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[name]
+	if !ok {
+		stats = &queryStats{buckets: make([]int64, len(queryDurationBuckets))}
+		m.stats[name] = stats
+	}
+
+	seconds := duration.Seconds()
+	stats.count++
+	stats.seconds += seconds
+	stats.rows += rows
+	if failed {
+		stats.errors++
+	}
+	for i, bound := range queryDurationBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+// WritePrometheus writes the query stats in the Prometheus text exposition format
+func (m *QueryMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.stats))
+	for name := range m.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprint(w, "# HELP advantage_db_query_duration_seconds Time taken by database queries, including reading their rows.\n")
+	fmt.Fprint(w, "# TYPE advantage_db_query_duration_seconds histogram\n")
+	for _, name := range names {
+		stats := m.stats[name]
+		for i, bound := range queryDurationBuckets {
+			fmt.Fprintf(w, "advantage_db_query_duration_seconds_bucket{query=%q,le=\"%g\"} %d\n", name, bound, stats.buckets[i])
+		}
+		fmt.Fprintf(w, "advantage_db_query_duration_seconds_bucket{query=%q,le=\"+Inf\"} %d\n", name, stats.count)
+		fmt.Fprintf(w, "advantage_db_query_duration_seconds_sum{query=%q} %g\n", name, stats.seconds)
+		fmt.Fprintf(w, "advantage_db_query_duration_seconds_count{query=%q} %d\n", name, stats.count)
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(*queryStats) int64
+	}{
+		{"advantage_db_query_rows_total", "Rows returned or affected by database queries.", func(s *queryStats) int64 { return s.rows }},
+		{"advantage_db_query_errors_total", "Database queries that failed.", func(s *queryStats) int64 { return s.errors }},
+	}
+	for _, counter := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{query=%q} %d\n", counter.name, name, counter.value(m.stats[name]))
+		}
+	}
+}
+
+// queryName names a query for metrics, from its name comment or its statement type and main table
+func queryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if rest, ok := strings.CutPrefix(sql, queryNamePrefix); ok {
+		name, _, _ := strings.Cut(rest, "\n")
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+
+	verb := strings.ToLower(fields[0])
+	var marker string
+	switch verb {
+	case "select", "with", "delete":
+		marker = "from"
+	case "insert":
+		marker = "into"
+	case "update":
+		if len(fields) > 1 {
+			return verb + " " + tableName(fields[1])
+		}
+		return verb
+	default:
+		return verb
+	}
+
+	for i := 1; i < len(fields)-1; i++ {
+		if strings.EqualFold(fields[i], marker) {
+			return verb + " " + tableName(fields[i+1])
+		}
+	}
+	return verb
+}
+
+// tableName cleans up the table a query names, leaving subqueries unnamed
+func tableName(token string) string {
+	if strings.HasPrefix(token, "(") {
+		return "subquery"
+	}
+	return strings.ToLower(strings.Trim(token, `"(),;`))
+}
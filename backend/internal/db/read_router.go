@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ReadRouter sends queries to a healthy read replica, falling back to the primary when
+// no replica is available or the chosen replica cannot be reached. Writes always go to the primary.
+// Reads may lag the primary slightly, so only use it for queries that tolerate replication delay.
+type ReadRouter struct {
+	db *PostgresDB
+}
+
+// Exec runs a statement on the primary
+func (r *ReadRouter) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.db.Pool.Exec(ctx, sql, args...)
+}
+
+// CopyFrom bulk-loads rows on the primary
+func (r *ReadRouter) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return r.db.Pool.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+// Query runs a query on a replica, retrying on the primary if the replica is unreachable.
+// The query is canceled once it runs past the query timeout, so one runaway report can't
+// hold a connection the rest of the pool needs.
+func (r *ReadRouter) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, cancel := r.queryContext(ctx)
+	pool, rep := r.db.readPool()
+
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil && rep != nil && ctx.Err() == nil && isConnectionError(err) {
+		rep.healthy.Store(false)
+		rows, err = r.db.Pool.Query(ctx, sql, args...)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+// QueryRow runs a single-row query on a replica, retrying on the primary if the replica is unreachable
+func (r *ReadRouter) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, cancel := r.queryContext(ctx)
+	pool, rep := r.db.readPool()
+	if rep == nil {
+		return &timeoutRow{row: pool.QueryRow(ctx, sql, args...), cancel: cancel}
+	}
+
+	return &timeoutRow{
+		row: &fallbackRow{
+			ctx: ctx,
+			row: pool.QueryRow(ctx, sql, args...),
+			retry: func() pgx.Row {
+				rep.healthy.Store(false)
+				return r.db.Pool.QueryRow(ctx, sql, args...)
+			},
+		},
+		cancel: cancel,
+	}
+}
+
+// queryContext bounds a read by the query timeout, if there is one
+func (r *ReadRouter) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.db.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.db.queryTimeout)
+}
+
+// timeoutRows releases the query's timeout once its rows are read or closed
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+// Next advances to the next row, releasing the timeout after the last one
+func (t *timeoutRows) Next() bool {
+	if t.Rows.Next() {
+		return true
+	}
+	t.cancel()
+	return false
+}
+
+// Close closes the rows and releases the timeout
+func (t *timeoutRows) Close() {
+	t.Rows.Close()
+	t.cancel()
+}
+
+// timeoutRow releases the query's timeout once its row is scanned
+type timeoutRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+// Scan scans the row and releases the timeout
+func (t *timeoutRow) Scan(dest ...interface{}) error {
+	defer t.cancel()
+	return t.row.Scan(dest...)
+}
+
+// fallbackRow defers the replica fallback to Scan, where pgx reports single-row errors
+type fallbackRow struct {
+	ctx   context.Context
+	row   pgx.Row
+	retry func() pgx.Row
+}
+
+// Scan scans the replica row, retrying on the primary after a connection error
+func (f *fallbackRow) Scan(dest ...interface{}) error {
+	err := f.row.Scan(dest...)
+	if err != nil && f.ctx.Err() == nil && isConnectionError(err) {
+		return f.retry().Scan(dest...)
+	}
+	return err
+}
+
+// isConnectionError reports whether an error means the server could not be reached,
+// as opposed to an error in the query itself
+func isConnectionError(err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err)
+}
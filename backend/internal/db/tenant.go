@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Session settings row-level security policies check: the org rows must belong to, and whether
+// the connection does system work that may see every org's rows
+const (
+	orgSetting    = "app.org_id"
+	systemSetting = "app.system"
+)
+
+// orgKey is the context key of the org a request acts for
+type orgKey struct{}
+
+// systemKey is the context key marking system work
+type systemKey struct{}
+
+// WithOrg returns a context whose queries can only see the org's rows in tables with
+// row-level security
+func WithOrg(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// OrgFromContext returns the org set by WithOrg, or "" when no org is set
+func OrgFromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(orgKey{}).(string)
+	return orgID
+}
+
+// AsSystem returns a context whose queries see every org's rows, for work not done for one org:
+// processing jobs, background services, migrations and the lookups that authenticate a request
+// before its org is known. Queries with neither an org nor this mark see no rows in tables with
+// row-level security.
+func AsSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// IsSystem reports whether a context was marked by AsSystem
+func IsSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
+
+// setOrg sets a connection's org and system mark from the context it is acquired with, so a
+// connection reused across requests never carries another request's settings
+func setOrg(ctx context.Context, conn *pgx.Conn) bool {
+	system := "off"
+	if IsSystem(ctx) {
+		system = "on"
+	}
+	_, err := conn.Exec(ctx, "-- name: set_org\nSELECT set_config($1, $2, false), set_config($3, $4, false)",
+		orgSetting, OrgFromContext(ctx), systemSetting, system)
+	return err == nil
+}
@@ -0,0 +1,82 @@
+package diagnostics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+)
+
+// metricsWriters write the metrics served at /metrics
+var (
+	metricsMu      sync.Mutex
+	metricsWriters []func(w io.Writer)
+)
+
+// NewServer creates an HTTP server exposing pprof profiles under /debug/pprof/, expvar
+// variables under /debug/vars and Prometheus metrics under /metrics. It returns nil when no
+// diagnostics port is configured.
+func NewServer(cfg config.DiagnosticsConfig) *http.Server {
+	if cfg.Port == 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+
+	// Profiles
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	// Runtime variables, including memstats
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// Metrics for Prometheus to scrape
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	// No write timeout: CPU profiles and traces stream for as long as requested
+	return &http.Server{
+		Addr:        fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:     mux,
+		ReadTimeout: 15 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	}
+}
+
+// Publish exposes a value computed on every read of /debug/vars
+func Publish(name string, value func() any) {
+	expvar.Publish(name, expvar.Func(value))
+}
+
+// PublishMetrics adds metrics, written in the Prometheus text exposition format, to /metrics
+func PublishMetrics(write func(w io.Writer)) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metricsWriters = append(metricsWriters, write)
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metricsMu.Lock()
+	writers := slices.Clone(metricsWriters)
+	metricsMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, write := range writers {
+		write(w)
+	}
+}
+
+func init() {
+	Publish("goroutines", func() any {
+		return runtime.NumGoroutine()
+	})
+}
@@ -0,0 +1,108 @@
+package errreport
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+)
+
+// Event is an error with the context it happened in
+type Event struct {
+	Message string
+	Err     error
+	Tags    map[string]string // request, user, job and file identifiers
+	Stack   string            // set for recovered panics
+}
+
+// Reporter sends errors to an error tracking service
+type Reporter interface {
+	Report(event Event)
+	Flush(ctx context.Context) error
+}
+
+var defaultReporter atomic.Pointer[Reporter]
+
+// SetDefault sets the reporter used by Report; nil only logs errors
+func SetDefault(reporter Reporter) {
+	if reporter == nil {
+		defaultReporter.Store(nil)
+		return
+	}
+	defaultReporter.Store(&reporter)
+}
+
+// Flush waits until reported errors have been sent or ctx is done
+func Flush(ctx context.Context) error {
+	if reporter := defaultReporter.Load(); reporter != nil {
+		return (*reporter).Flush(ctx)
+	}
+	return nil
+}
+
+type tagsKey struct{}
+
+// WithTags returns a context carrying tags attached to errors reported with it.
+// Tags are given as key/value pairs and are added to any already on ctx.
+func WithTags(ctx context.Context, keyValues ...string) context.Context {
+	tags := make(map[string]string)
+	for key, value := range Tags(ctx) {
+		tags[key] = value
+	}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		tags[keyValues[i]] = keyValues[i+1]
+	}
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// Tags returns the tags carried by ctx
+func Tags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// Report logs an error with the tags on ctx and sends it to the default reporter
+func Report(ctx context.Context, message string, err error) {
+	ReportEvent(ctx, Event{Message: message, Err: err})
+}
+
+// ReportEvent logs an event and sends it to the default reporter, adding the tags on ctx
+func ReportEvent(ctx context.Context, event Event) {
+	if event.Err == nil {
+		return
+	}
+
+	tags := make(map[string]string)
+	for key, value := range Tags(ctx) {
+		tags[key] = value
+	}
+	for key, value := range event.Tags {
+		tags[key] = value
+	}
+	event.Tags = tags
+
+	attrs := []any{"error", event.Err}
+	for key, value := range tags {
+		attrs = append(attrs, key, value)
+	}
+	slog.Error(event.Message, attrs...)
+
+	if reporter := defaultReporter.Load(); reporter != nil {
+		(*reporter).Report(event)
+	}
+}
+
+// New creates a reporter from configuration. It returns nil when no DSN is configured,
+// in which case errors are only logged.
+func New(cfg config.ErrorReportingConfig, environment string) (Reporter, error) {
+	if cfg.SentryDSN == "" {
+		return nil, nil
+	}
+
+	reporter, err := NewSentryReporter(cfg.SentryDSN, environment)
+	if err != nil {
+		return nil, err
+	}
+	return reporter, nil
+}
@@ -0,0 +1,164 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sentryQueueSize is how many events may wait to be sent before new ones are dropped
+const sentryQueueSize = 100
+
+// SentryReporter sends errors to Sentry's store endpoint in the background
+type SentryReporter struct {
+	client      *http.Client
+	endpoint    string
+	auth        string
+	environment string
+	queue       chan Event
+	pending     sync.WaitGroup
+}
+
+// NewSentryReporter creates a reporter for a Sentry DSN such as https://key@o1.ingest.sentry.io/42
+func NewSentryReporter(dsn, environment string) (*SentryReporter, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing public key")
+	}
+
+	// The project ID is the last path segment; anything before it is a path prefix
+	path := strings.Trim(parsed.Path, "/")
+	projectID := path
+	prefix := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix = "/" + path[:i]
+		projectID = path[i+1:]
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+
+	r := &SentryReporter{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", parsed.Scheme, parsed.Host, prefix, projectID),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=advantage/1.0, sentry_key=%s", parsed.User.Username()),
+		environment: environment,
+		queue:       make(chan Event, sentryQueueSize),
+	}
+	go r.run()
+
+	return r, nil
+}
+
+// Report queues an event to be sent, dropping it if the queue is full
+func (r *SentryReporter) Report(event Event) {
+	r.pending.Add(1)
+	select {
+	case r.queue <- event:
+	default:
+		r.pending.Done()
+		slog.Warn("Dropped error report, queue is full")
+	}
+}
+
+// Flush waits until queued events have been sent or ctx is done
+func (r *SentryReporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends queued events
+func (r *SentryReporter) run() {
+	for event := range r.queue {
+		if err := r.send(event); err != nil {
+			slog.Warn("Failed to send error report", "error", err)
+		}
+		r.pending.Done()
+	}
+}
+
+// send posts an event to Sentry
+func (r *SentryReporter) send(event Event) error {
+	payload, err := json.Marshal(r.buildPayload(event))
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// buildPayload converts an event to Sentry's event format
+func (r *SentryReporter) buildPayload(event Event) map[string]interface{} {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	payload := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"level":       "error",
+		"platform":    "go",
+		"environment": r.environment,
+		"message":     event.Message,
+		"exception": map[string]interface{}{
+			"values": []map[string]string{{
+				"type":  fmt.Sprintf("%T", event.Err),
+				"value": event.Err.Error(),
+			}},
+		},
+		"tags": event.Tags,
+	}
+
+	if userID := event.Tags["userID"]; userID != "" {
+		payload["user"] = map[string]string{"id": userID}
+	}
+	if event.Tags["method"] != "" {
+		payload["request"] = map[string]string{
+			"method": event.Tags["method"],
+			"url":    event.Tags["path"],
+		}
+	}
+	if event.Stack != "" {
+		payload["extra"] = map[string]string{"stack": event.Stack}
+	}
+
+	return payload
+}
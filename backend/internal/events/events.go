@@ -0,0 +1,195 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+)
+
+// Event types
+const (
+	// TypeFileUploaded is published when a file is uploaded and its processing job queued
+	TypeFileUploaded = "file.uploaded"
+	// TypeFileProcessed is published when a file's processing job completes
+	TypeFileProcessed = "file.processed"
+	// TypeFileFailed is published when a file's processing job runs out of attempts
+	TypeFileFailed = "file.failed"
+	// TypeAlert is published when a problem needs a user's attention
+	TypeAlert = "alert"
+)
+
+// Alert kinds
+const (
+	AlertProcessingFailed = "processing_failed"
+	AlertSchemaDrift      = "schema_drift"
+)
+
+// Event is a structured event published for customers' data teams to subscribe to
+type Event struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	Time        time.Time   `json:"time"`
+	Environment string      `json:"environment"`
+	UserID      string      `json:"userId"`
+	Data        interface{} `json:"data"`
+}
+
+// FileUploaded is the data of a file.uploaded event
+type FileUploaded struct {
+	FileID   string `json:"fileId"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	JobID    string `json:"jobId"`
+	Priority string `json:"priority"`
+}
+
+// FileProcessed is the data of a file.processed event
+type FileProcessed struct {
+	FileID    string   `json:"fileId"`
+	FileName  string   `json:"fileName"`
+	JobID     string   `json:"jobId"`
+	Version   int      `json:"version,omitempty"`
+	Reprocess bool     `json:"reprocess,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// FileFailed is the data of a file.failed event
+type FileFailed struct {
+	FileID   string `json:"fileId"`
+	JobID    string `json:"jobId"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+}
+
+// Alert is the data of an alert event
+type Alert struct {
+	Kind    string      `json:"kind"`
+	FileID  string      `json:"fileId,omitempty"`
+	Title   string      `json:"title"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Publisher publishes events to a message broker
+type Publisher interface {
+	// Publish queues an event to be sent; it never blocks on the broker
+	Publish(ctx context.Context, eventType, userID string, data interface{})
+	// Flush waits until queued events have been sent or ctx is done
+	Flush(ctx context.Context) error
+}
+
+// New creates a publisher from configuration. It returns nil when no provider is configured,
+// in which case no events are published.
+func New(cfg config.EventsConfig, environment string) (Publisher, error) {
+	var (
+		s   sender
+		err error
+	)
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "kafka":
+		s, err = newKafkaSender(cfg.URL, cfg.Topic)
+	case "nats":
+		s, err = newNATSSender(cfg.URL, cfg.Topic)
+	default:
+		return nil, fmt.Errorf("unknown events provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newQueuedPublisher(s, environment), nil
+}
+
+// queueSize is how many events may wait to be sent before new ones are dropped
+const queueSize = 1000
+
+// batchSize is the most events sent to the broker at once
+const batchSize = 100
+
+// sender delivers batches of events to a broker
+type sender interface {
+	send(events []Event) error
+}
+
+// queuedPublisher sends events in the background, batching those queued while a send is in flight
+type queuedPublisher struct {
+	sender      sender
+	environment string
+	queue       chan Event
+	pending     sync.WaitGroup
+}
+
+func newQueuedPublisher(s sender, environment string) *queuedPublisher {
+	p := &queuedPublisher{
+		sender:      s,
+		environment: environment,
+		queue:       make(chan Event, queueSize),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues an event to be sent, dropping it if the queue is full
+func (p *queuedPublisher) Publish(ctx context.Context, eventType, userID string, data interface{}) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	event := Event{
+		ID:          hex.EncodeToString(id),
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+		Environment: p.environment,
+		UserID:      userID,
+		Data:        data,
+	}
+
+	p.pending.Add(1)
+	select {
+	case p.queue <- event:
+	default:
+		p.pending.Done()
+		slog.WarnContext(ctx, "Dropped event, queue is full", "type", eventType)
+	}
+}
+
+// Flush waits until queued events have been sent or ctx is done
+func (p *queuedPublisher) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends queued events, taking whatever else is waiting along with each one
+func (p *queuedPublisher) run() {
+	for event := range p.queue {
+		batch := []Event{event}
+	drain:
+		for len(batch) < batchSize {
+			select {
+			case event := <-p.queue:
+				batch = append(batch, event)
+			default:
+				break drain
+			}
+		}
+
+		if err := p.sender.send(batch); err != nil {
+			slog.Warn("Failed to publish events", "events", len(batch), "error", err)
+		}
+		p.pending.Add(-len(batch))
+	}
+}
@@ -0,0 +1,283 @@
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits keeping formulas cheap to evaluate for every row of a report
+const (
+	MaxLength = 500
+	maxDepth  = 32
+)
+
+// ErrInvalidFormula is returned for a formula that doesn't parse
+var ErrInvalidFormula = errors.New("invalid formula")
+
+// Formula is a parsed arithmetic expression over named variables, such as the custom metric
+// "spend / viewable impressions * 1000"
+type Formula struct {
+	source    string
+	root      node
+	variables []string
+}
+
+// Parse parses a formula. Formulas combine numbers and variables with +, -, * and / and
+// parentheses. Variable names are letters, digits and underscores, matched case-insensitively;
+// a name of several words, such as "viewable impressions", is read as one variable with the
+// words joined by underscores.
+func Parse(source string) (*Formula, error) {
+	if len(source) > MaxLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidFormula, MaxLength)
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, seen: make(map[string]bool)}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEnd {
+		return nil, fmt.Errorf("%w: unexpected %s at position %d", ErrInvalidFormula, describe(next), next.pos+1)
+	}
+
+	return &Formula{source: source, root: root, variables: p.variables}, nil
+}
+
+// String returns the formula as it was written
+func (f *Formula) String() string {
+	return f.source
+}
+
+// Variables lists the variables the formula refers to, in order of first use
+func (f *Formula) Variables() []string {
+	return f.variables
+}
+
+// Evaluate computes the formula with the given variable values. It reports false when a
+// variable has no value or the result is undefined, such as after dividing by zero.
+func (f *Formula) Evaluate(values map[string]float64) (float64, bool) {
+	result, ok := f.root.evaluate(values)
+	if !ok || math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, false
+	}
+	return result, true
+}
+
+// node is a parsed part of a formula
+type node interface {
+	evaluate(values map[string]float64) (float64, bool)
+}
+
+type numberNode float64
+
+func (n numberNode) evaluate(map[string]float64) (float64, bool) {
+	return float64(n), true
+}
+
+type variableNode string
+
+func (n variableNode) evaluate(values map[string]float64) (float64, bool) {
+	value, ok := values[string(n)]
+	return value, ok
+}
+
+type negateNode struct {
+	operand node
+}
+
+func (n negateNode) evaluate(values map[string]float64) (float64, bool) {
+	value, ok := n.operand.evaluate(values)
+	return -value, ok
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n binaryNode) evaluate(values map[string]float64) (float64, bool) {
+	left, ok := n.left.evaluate(values)
+	if !ok {
+		return 0, false
+	}
+	right, ok := n.right.evaluate(values)
+	if !ok {
+		return 0, false
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+// Token kinds
+const (
+	tokenEnd = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// tokenize splits a formula into numbers, names and operators, joining the words of
+// multi-word names with underscores
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/()", r):
+			tokens = append(tokens, token{kind: tokenOperator, text: string(r), pos: i})
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			word := strings.ToLower(string(runes[start:i]))
+			if n := len(tokens); n > 0 && tokens[n-1].kind == tokenName {
+				tokens[n-1].text += "_" + word
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenName, text: word, pos: start})
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrInvalidFormula, r, i+1)
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, text: "end of formula", pos: len(runes)}), nil
+}
+
+// parser is a recursive descent parser over a formula's tokens
+type parser struct {
+	tokens    []token
+	next      int
+	variables []string
+	seen      map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+// expression parses terms joined by + and -
+func (p *parser) expression(depth int) (node, error) {
+	left, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.advance()
+		right, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: t.text[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+// term parses factors joined by * and /
+func (p *parser) term(depth int) (node, error) {
+	left, err := p.factor(depth)
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "*" || t.text == "/"); t = p.peek() {
+		p.advance()
+		right, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: t.text[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+// factor parses a number, a variable, a negation or a parenthesized expression
+func (p *parser) factor(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested more than %d deep", ErrInvalidFormula, maxDepth)
+	}
+
+	t := p.advance()
+	switch {
+	case t.kind == tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q at position %d", ErrInvalidFormula, t.text, t.pos+1)
+		}
+		return numberNode(value), nil
+	case t.kind == tokenName:
+		if !p.seen[t.text] {
+			p.seen[t.text] = true
+			p.variables = append(p.variables, t.text)
+		}
+		return variableNode(t.text), nil
+	case t.text == "-":
+		operand, err := p.factor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	case t.text == "(":
+		inner, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.text != ")" {
+			return nil, fmt.Errorf("%w: expected ) at position %d", ErrInvalidFormula, closing.pos+1)
+		}
+		return inner, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s at position %d", ErrInvalidFormula, describe(t), t.pos+1)
+	}
+}
+
+// describe names a token for an error message
+func describe(t token) string {
+	if t.kind == tokenEnd {
+		return t.text
+	}
+	return strconv.Quote(t.text)
+}
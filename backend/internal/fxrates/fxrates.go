@@ -0,0 +1,147 @@
+package fxrates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency rates are quoted against, which is also the currency logs
+// report spend in
+const BaseCurrency = "USD"
+
+// ErrNoRate is returned when there is no exchange rate for a currency
+var ErrNoRate = errors.New("no exchange rate")
+
+// Snapshot is a day's exchange rates, in units of each currency per US dollar
+type Snapshot struct {
+	Date  time.Time
+	Rates map[string]float64
+}
+
+// Client fetches the latest daily exchange rates from a provider returning
+// {"base": "USD", "date": "2006-01-02", "rates": {"EUR": 0.92, ...}}, such as Frankfurter
+type Client struct {
+	client *http.Client
+	url    string
+}
+
+// NewClient creates an exchange rate client. It returns nil when url is empty.
+func NewClient(url string) *Client {
+	if url == "" {
+		return nil
+	}
+
+	return &Client{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+	}
+}
+
+// Latest fetches the most recently published rates
+func (c *Client) Latest(ctx context.Context) (*Snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rate request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("exchange rate provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var body struct {
+		Base  string             `json:"base"`
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode exchange rates: %w", err)
+	}
+	if body.Base != BaseCurrency {
+		return nil, fmt.Errorf("exchange rates are quoted against %q, expected %s", body.Base, BaseCurrency)
+	}
+	date, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange rate date %q", body.Date)
+	}
+
+	snapshot := &Snapshot{Date: date, Rates: map[string]float64{BaseCurrency: 1}}
+	for currency, rate := range body.Rates {
+		if rate > 0 {
+			snapshot.Rates[strings.ToUpper(currency)] = rate
+		}
+	}
+
+	return snapshot, nil
+}
+
+// History holds daily snapshots, so amounts are converted at the rate of the day they were
+// spent rather than today's
+type History struct {
+	snapshots []*Snapshot
+}
+
+// NewHistory creates a history from snapshots in any order
+func NewHistory(snapshots []*Snapshot) *History {
+	sorted := append([]*Snapshot(nil), snapshots...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+	return &History{snapshots: sorted}
+}
+
+// Rate returns the units of currency per US dollar on a day: the latest snapshot on or before
+// the day, or the earliest one for days before any snapshot
+func (h *History) Rate(currency string, day time.Time) (float64, error) {
+	if currency == BaseCurrency {
+		return 1, nil
+	}
+
+	// Find the first snapshot after the day, then step back to the one in effect on it
+	i := sort.Search(len(h.snapshots), func(i int) bool {
+		return h.snapshots[i].Date.After(day)
+	})
+	if i > 0 {
+		i--
+	}
+	if i >= len(h.snapshots) {
+		return 0, fmt.Errorf("%w for %s: no rates have been snapshotted", ErrNoRate, currency)
+	}
+
+	rate, ok := h.snapshots[i].Rates[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s on %s", ErrNoRate, currency, day.Format("2006-01-02"))
+	}
+	return rate, nil
+}
+
+// Convert converts an amount between currencies at the rates in effect on a day
+func (h *History) Convert(amount float64, from, to string, day time.Time) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, err := h.Rate(from, day)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := h.Rate(to, day)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount / fromRate * toRate, nil
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses reported for the service and for each dependency
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// checkTimeout bounds how long a single dependency check may take
+const checkTimeout = 3 * time.Second
+
+// Check verifies a single dependency, returning an error when it is unusable
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check
+type CheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// Report is the outcome of all dependency checks
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs a set of named dependency checks
+type Checker struct {
+	names  []string
+	checks map[string]Check
+}
+
+// NewChecker creates a new checker with no checks
+func NewChecker() *Checker {
+	return &Checker{
+		checks: make(map[string]Check),
+	}
+}
+
+// Register adds a named dependency check
+func (c *Checker) Register(name string, check Check) {
+	if _, exists := c.checks[name]; !exists {
+		c.names = append(c.names, name)
+		sort.Strings(c.names)
+	}
+	c.checks[name] = check
+}
+
+// Run executes every check concurrently; the report is ok only when every check passes
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(c.names)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			result := CheckResult{
+				Status:    StatusOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = StatusUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusUnavailable
+			}
+		}(name, c.checks[name])
+	}
+	wg.Wait()
+
+	return report
+}
@@ -0,0 +1,174 @@
+// Package i18n translates user-facing error messages and report labels into the languages
+// agencies' clients read, picked from a request's Accept-Language header.
+//
+// Catalogs are keyed by the English text, so code keeps writing English messages and anything a
+// catalog is missing stays in English. Keys may contain %s, matching any text, which the
+// translation places with %s or %[n]s; matched text is itself translated. Wrapped errors are
+// translated a part at a time, splitting at each ": ".
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Languages
+const (
+	English = "en"
+	French  = "fr"
+	German  = "de"
+)
+
+// Languages lists the supported languages, English first as the default
+var Languages = []string{English, French, German}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogs are the translations out of English, by language
+var catalogs = loadCatalogs()
+
+// catalog is one language's translations
+type catalog struct {
+	exact     map[string]string
+	templates []template // Longest literal text first, so the most specific key wins
+}
+
+// template is a catalog key containing %s
+type template struct {
+	pattern     *regexp.Regexp
+	translation string
+	literal     int
+}
+
+// loadCatalogs compiles the embedded catalogs, panicking on a malformed one since they're built in
+func loadCatalogs() map[string]*catalog {
+	catalogs := make(map[string]*catalog)
+	for _, language := range Languages[1:] {
+		data, err := locales.ReadFile(path.Join("locales", language+".json"))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: failed to read %s catalog: %v", language, err))
+		}
+		var entries map[string]string
+		if err := json.Unmarshal(data, &entries); err != nil {
+			panic(fmt.Sprintf("i18n: failed to parse %s catalog: %v", language, err))
+		}
+
+		c := &catalog{exact: make(map[string]string)}
+		for key, translation := range entries {
+			if !strings.Contains(key, "%s") {
+				c.exact[key] = translation
+				continue
+			}
+			parts := strings.Split(key, "%s")
+			literal := 0
+			for i, part := range parts {
+				literal += len(part)
+				parts[i] = regexp.QuoteMeta(part)
+			}
+			c.templates = append(c.templates, template{
+				pattern:     regexp.MustCompile("^" + strings.Join(parts, "(.+?)") + "$"),
+				translation: translation,
+				literal:     literal,
+			})
+		}
+		sort.Slice(c.templates, func(i, j int) bool {
+			if c.templates[i].literal != c.templates[j].literal {
+				return c.templates[i].literal > c.templates[j].literal
+			}
+			return c.templates[i].pattern.String() < c.templates[j].pattern.String()
+		})
+		catalogs[language] = c
+	}
+	return catalogs
+}
+
+// translate translates a message, falling back to the message itself
+func (c *catalog) translate(message string) string {
+	if translation, ok := c.exact[message]; ok {
+		return translation
+	}
+	for _, t := range c.templates {
+		match := t.pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		args := make([]any, len(match)-1)
+		for i, arg := range match[1:] {
+			args[i] = c.translate(arg)
+		}
+		return fmt.Sprintf(t.translation, args...)
+	}
+
+	// A wrapped error: translate the context and the error it wraps separately
+	if head, tail, ok := strings.Cut(message, ": "); ok {
+		if translation, ok := c.exact[head]; ok {
+			return translation + ": " + c.translate(tail)
+		}
+	}
+	return message
+}
+
+// Localizer translates messages into one language
+type Localizer struct {
+	language string
+	catalog  *catalog // nil for English
+}
+
+// New returns a localizer for a supported language, or for English when it isn't supported
+func New(language string) *Localizer {
+	c, ok := catalogs[language]
+	if !ok {
+		return &Localizer{language: English}
+	}
+	return &Localizer{language: language, catalog: c}
+}
+
+// Language returns the language the localizer translates into
+func (l *Localizer) Language() string {
+	if l == nil {
+		return English
+	}
+	return l.language
+}
+
+// T translates an English message, returning it untranslated when the catalog lacks it
+func (l *Localizer) T(message string) string {
+	if l == nil || l.catalog == nil || message == "" {
+		return message
+	}
+	return l.catalog.translate(message)
+}
+
+// Match picks the supported language an Accept-Language header prefers, such as fr for
+// "fr-CH, fr;q=0.9, en;q=0.8". Languages are matched on their primary subtag; without a
+// supported one, English is picked.
+func Match(acceptLanguage string) string {
+	best, bestQ := English, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		// Earlier languages win ties, as the header lists them by preference
+		if q > bestQ && (primary == English || catalogs[primary] != nil) {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
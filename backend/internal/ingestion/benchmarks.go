@@ -0,0 +1,232 @@
+package ingestion
+
+import (
+	"math"
+	"sort"
+)
+
+// Benchmark metric names
+const (
+	BenchmarkMetricCTR     = "ctr"
+	BenchmarkMetricCPM     = "cpm"
+	BenchmarkMetricCPA     = "cpa"
+	BenchmarkMetricROAS    = "roas"
+	BenchmarkMetricWinRate = "winRate"
+)
+
+// BenchmarkSegmentAll is the segment covering a campaign's performance across all devices
+const BenchmarkSegmentAll = "all"
+
+// Norm positions relative to the historical distribution
+const (
+	NormAbove  = "above_norm"
+	NormWithin = "within_norm"
+	NormBelow  = "below_norm"
+)
+
+// minBenchmarkSamples is the number of historical campaigns needed before a benchmark is reported
+const minBenchmarkSamples = 5
+
+// minBenchmarkImpressions keeps tiny campaigns from skewing the historical distribution
+const minBenchmarkImpressions = 100
+
+// BenchmarkDistribution summarizes the historical distribution of a metric
+type BenchmarkDistribution struct {
+	SampleSize int     `json:"sampleSize"`
+	P25        float64 `json:"p25"`
+	P50        float64 `json:"p50"`
+	P75        float64 `json:"p75"`
+	P90        float64 `json:"p90"`
+}
+
+// MetricBenchmark compares a metric's value against its historical distribution
+type MetricBenchmark struct {
+	Metric         string                `json:"metric"`
+	Value          float64               `json:"value"`
+	PercentileRank float64               `json:"percentileRank"`
+	Position       string                `json:"position"`
+	Favorable      bool                  `json:"favorable"`
+	Distribution   BenchmarkDistribution `json:"distribution"`
+}
+
+// CampaignBenchmark contains a campaign's benchmarks for a segment
+type CampaignBenchmark struct {
+	CampaignID string            `json:"campaignId"`
+	Segment    string            `json:"segment"`
+	Metrics    []MetricBenchmark `json:"metrics"`
+}
+
+// BenchmarkHistory holds historical metric values keyed by segment and then by metric
+type BenchmarkHistory map[string]map[string][]float64
+
+// benchmarkMetrics lists the benchmarked metrics and whether a higher value is better
+var benchmarkMetrics = []struct {
+	name           string
+	higherIsBetter bool
+}{
+	{BenchmarkMetricCTR, true},
+	{BenchmarkMetricCPM, false},
+	{BenchmarkMetricCPA, false},
+	{BenchmarkMetricROAS, true},
+	{BenchmarkMetricWinRate, true},
+}
+
+// BuildBenchmarkHistory collects per-campaign metric values from past analyses, overall and by device
+func BuildBenchmarkHistory(results []*LogAnalysisResult) (BenchmarkHistory, error) {
+	history := make(BenchmarkHistory)
+	for _, result := range results {
+		if result.Status != "completed" {
+			continue
+		}
+
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return nil, err
+		}
+
+		for campaignID, campaign := range summary.CampaignPerformance {
+			history.add(BenchmarkSegmentAll, campaign)
+			for device, metrics := range summary.CampaignDevices[campaignID] {
+				history.add(device, metrics)
+			}
+		}
+	}
+
+	for _, metrics := range history {
+		for _, values := range metrics {
+			sort.Float64s(values)
+		}
+	}
+
+	return history, nil
+}
+
+// add records a campaign's metric values under a segment
+func (h BenchmarkHistory) add(segment string, campaign CampaignMetrics) {
+	if campaign.Impressions < minBenchmarkImpressions {
+		return
+	}
+
+	metrics, ok := h[segment]
+	if !ok {
+		metrics = make(map[string][]float64)
+		h[segment] = metrics
+	}
+	for name, value := range campaignBenchmarkValues(campaign) {
+		metrics[name] = append(metrics[name], value)
+	}
+}
+
+// BenchmarkCampaigns benchmarks every campaign in a summary against the history, overall and by device.
+// Segments without enough history are left out.
+func BenchmarkCampaigns(history BenchmarkHistory, summary *BeeswaxLogSummary) []CampaignBenchmark {
+	benchmarks := []CampaignBenchmark{}
+	for campaignID, campaign := range summary.CampaignPerformance {
+		if benchmark, ok := history.Benchmark(campaignID, BenchmarkSegmentAll, campaign); ok {
+			benchmarks = append(benchmarks, benchmark)
+		}
+		for device, metrics := range summary.CampaignDevices[campaignID] {
+			if benchmark, ok := history.Benchmark(campaignID, device, metrics); ok {
+				benchmarks = append(benchmarks, benchmark)
+			}
+		}
+	}
+
+	sort.Slice(benchmarks, func(i, j int) bool {
+		if benchmarks[i].CampaignID != benchmarks[j].CampaignID {
+			return benchmarks[i].CampaignID < benchmarks[j].CampaignID
+		}
+		return benchmarks[i].Segment < benchmarks[j].Segment
+	})
+
+	return benchmarks
+}
+
+// Benchmark compares a campaign's metrics against the historical distribution of a segment
+func (h BenchmarkHistory) Benchmark(campaignID, segment string, campaign CampaignMetrics) (CampaignBenchmark, bool) {
+	benchmark := CampaignBenchmark{
+		CampaignID: campaignID,
+		Segment:    segment,
+		Metrics:    []MetricBenchmark{},
+	}
+
+	values := campaignBenchmarkValues(campaign)
+	for _, metric := range benchmarkMetrics {
+		value, ok := values[metric.name]
+		if !ok {
+			continue
+		}
+		historical := h[segment][metric.name]
+		if len(historical) < minBenchmarkSamples {
+			continue
+		}
+
+		rank := percentileRank(historical, value)
+		entry := MetricBenchmark{
+			Metric:         metric.name,
+			Value:          value,
+			PercentileRank: rank,
+			Position:       NormWithin,
+			Distribution: BenchmarkDistribution{
+				SampleSize: len(historical),
+				P25:        percentile(historical, 25),
+				P50:        percentile(historical, 50),
+				P75:        percentile(historical, 75),
+				P90:        percentile(historical, 90),
+			},
+		}
+		switch {
+		case rank > 75:
+			entry.Position = NormAbove
+		case rank < 25:
+			entry.Position = NormBelow
+		}
+		entry.Favorable = (entry.Position == NormAbove && metric.higherIsBetter) ||
+			(entry.Position == NormBelow && !metric.higherIsBetter)
+
+		benchmark.Metrics = append(benchmark.Metrics, entry)
+	}
+
+	return benchmark, len(benchmark.Metrics) > 0
+}
+
+// campaignBenchmarkValues returns the benchmarkable metrics of a campaign; metrics that are
+// undefined (such as CPA without conversions, or ROAS without revenue) are omitted
+func campaignBenchmarkValues(campaign CampaignMetrics) map[string]float64 {
+	values := make(map[string]float64)
+	if campaign.Impressions > 0 {
+		values[BenchmarkMetricCTR] = float64(campaign.Clicks) / float64(campaign.Impressions) * 100
+		values[BenchmarkMetricCPM] = campaign.Spend / float64(campaign.Impressions) * 1000
+	}
+	if campaign.Conversions > 0 {
+		values[BenchmarkMetricCPA] = campaign.Spend / float64(campaign.Conversions)
+	}
+	if campaign.Spend > 0 && campaign.Revenue > 0 {
+		values[BenchmarkMetricROAS] = campaign.Revenue / campaign.Spend
+	}
+	if campaign.Bids > 0 {
+		values[BenchmarkMetricWinRate] = float64(campaign.Impressions) / float64(campaign.Bids) * 100
+	}
+	return values
+}
+
+// percentile returns the p-th percentile of sorted values using linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+// percentileRank returns the percentage of sorted values below the value, counting ties as half
+func percentileRank(sorted []float64, value float64) float64 {
+	below := sort.SearchFloat64s(sorted, value)
+	equal := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value }) - below
+	return (float64(below) + float64(equal)/2) / float64(len(sorted)) * 100
+}
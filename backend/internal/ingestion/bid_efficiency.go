@@ -0,0 +1,264 @@
+package ingestion
+
+import (
+	"sort"
+	"strconv"
+)
+
+// lossReasonNames are the OpenRTB 2.5 auction loss reason codes (list 5.25)
+var lossReasonNames = map[int]string{
+	0:   "Bid won",
+	1:   "Internal error",
+	2:   "Impression opportunity expired",
+	3:   "Invalid bid response",
+	4:   "Invalid deal ID",
+	5:   "Invalid auction ID",
+	6:   "Invalid advertiser domain",
+	7:   "Missing markup",
+	8:   "Missing creative ID",
+	9:   "Missing bid price",
+	10:  "Missing minimum creative approval data",
+	100: "Bid below auction floor",
+	101: "Bid below deal floor",
+	102: "Lost to higher bid",
+	103: "Lost to a bid for a PMP deal",
+	104: "Buyer seat blocked",
+	200: "Creative filtered",
+	201: "Creative pending processing",
+	202: "Creative disapproved by exchange",
+	203: "Creative size not allowed",
+	204: "Incorrect creative format",
+	205: "Advertiser exclusions",
+	206: "App bundle exclusions",
+	207: "Creative not secure",
+	208: "Language exclusions",
+	209: "Category exclusions",
+	210: "Creative attribute exclusions",
+	211: "Ad type exclusions",
+	212: "Animation too long",
+	213: "Creative not allowed in PMP deal",
+}
+
+// noBidReasonNames are the OpenRTB 2.5 no-bid reason codes (list 5.24)
+var noBidReasonNames = map[int]string{
+	0:  "Unknown error",
+	1:  "Technical error",
+	2:  "Invalid request",
+	3:  "Known web spider",
+	4:  "Suspected non-human traffic",
+	5:  "Cloud, data center or proxy IP",
+	6:  "Unsupported device",
+	7:  "Blocked publisher or site",
+	8:  "Unmatched user",
+	9:  "Daily reader cap met",
+	10: "Daily domain cap met",
+}
+
+// unknownOutcomeReason is the loss reason key of bids the log has no win or loss notice for
+const unknownOutcomeReason = "unknown"
+
+// BidEfficiencySummary contains bid-level counts only raw OpenRTB logs carry: the opportunities
+// offered, their floors and deals, and why bids won or lost. Prices are CPMs.
+type BidEfficiencySummary struct {
+	Requests      int `json:"requests"`
+	Opportunities int `json:"opportunities"`
+	Bids          int `json:"bids"`
+	Wins          int `json:"wins"`
+	// NoBids counts requests answered without a bid
+	NoBids int `json:"noBids"`
+
+	// Floors
+	FlooredOpportunities int     `json:"flooredOpportunities"`
+	TotalFloor           float64 `json:"totalFloor"`
+	FlooredBids          int     `json:"flooredBids"`
+	BidsBelowFloor       int     `json:"bidsBelowFloor"`
+	TotalBidToFloor      float64 `json:"totalBidToFloor"`
+
+	// Prices
+	TotalBid      float64 `json:"totalBid"`
+	PricedWins    int     `json:"pricedWins"`
+	TotalClearing float64 `json:"totalClearing"`
+	// TotalOverbid sums bid minus clearing price over priced wins
+	TotalOverbid float64 `json:"totalOverbid"`
+
+	// NoBidReasons and LossReasons count requests and bids by OpenRTB reason code
+	NoBidReasons map[string]int `json:"noBidReasons,omitempty"`
+	LossReasons  map[string]int `json:"lossReasons,omitempty"`
+	// Deals holds opportunities, bids and wins by PMP deal ID
+	Deals map[string]*DealEfficiency `json:"deals,omitempty"`
+
+	// Derived rates, in percent, and average CPMs
+	BidRate              float64 `json:"bidRate"`
+	WinRate              float64 `json:"winRate"`
+	AverageFloor         float64 `json:"averageFloor"`
+	AverageBid           float64 `json:"averageBid"`
+	AverageClearingPrice float64 `json:"averageClearingPrice"`
+	AverageBidToFloor    float64 `json:"averageBidToFloor"`
+	AverageOverbid       float64 `json:"averageOverbid"`
+}
+
+// DealEfficiency contains a PMP deal's bidding activity
+type DealEfficiency struct {
+	Opportunities int     `json:"opportunities"`
+	Bids          int     `json:"bids"`
+	Wins          int     `json:"wins"`
+	TotalFloor    float64 `json:"totalFloor"`
+	TotalBid      float64 `json:"totalBid"`
+	BidRate       float64 `json:"bidRate"`
+	WinRate       float64 `json:"winRate"`
+	AverageFloor  float64 `json:"averageFloor"`
+	AverageBid    float64 `json:"averageBid"`
+}
+
+// ReasonCount is a reason code with how often it occurred, used in reports
+type ReasonCount struct {
+	Code   string  `json:"code"`
+	Reason string  `json:"reason"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"`
+}
+
+// DealEntry is a deal with its bidding activity, used in reports
+type DealEntry struct {
+	DealID string `json:"dealId"`
+	DealEfficiency
+}
+
+// BidEfficiencyReport is the bid efficiency analysis for a processed file
+type BidEfficiencyReport struct {
+	FileID       string               `json:"fileId"`
+	Overview     BidEfficiencySummary `json:"overview"`
+	NoBidReasons []ReasonCount        `json:"noBidReasons"`
+	LossReasons  []ReasonCount        `json:"lossReasons"`
+	Deals        []DealEntry          `json:"deals"`
+}
+
+func newBidEfficiencySummary() *BidEfficiencySummary {
+	return &BidEfficiencySummary{
+		NoBidReasons: make(map[string]int),
+		LossReasons:  make(map[string]int),
+		Deals:        make(map[string]*DealEfficiency),
+	}
+}
+
+// deal returns a deal's metrics, creating them on first use
+func (s *BidEfficiencySummary) deal(id string) *DealEfficiency {
+	deal, ok := s.Deals[id]
+	if !ok {
+		deal = &DealEfficiency{}
+		s.Deals[id] = deal
+	}
+	return deal
+}
+
+// merge accumulates another summary's counts; derived rates must be recalculated afterwards
+func (s *BidEfficiencySummary) merge(other *BidEfficiencySummary) {
+	s.Requests += other.Requests
+	s.Opportunities += other.Opportunities
+	s.Bids += other.Bids
+	s.Wins += other.Wins
+	s.NoBids += other.NoBids
+	s.FlooredOpportunities += other.FlooredOpportunities
+	s.TotalFloor += other.TotalFloor
+	s.FlooredBids += other.FlooredBids
+	s.BidsBelowFloor += other.BidsBelowFloor
+	s.TotalBidToFloor += other.TotalBidToFloor
+	s.TotalBid += other.TotalBid
+	s.PricedWins += other.PricedWins
+	s.TotalClearing += other.TotalClearing
+	s.TotalOverbid += other.TotalOverbid
+
+	mergeCounts(s.NoBidReasons, other.NoBidReasons)
+	mergeCounts(s.LossReasons, other.LossReasons)
+	for id, deal := range other.Deals {
+		merged := s.deal(id)
+		merged.Opportunities += deal.Opportunities
+		merged.Bids += deal.Bids
+		merged.Wins += deal.Wins
+		merged.TotalFloor += deal.TotalFloor
+		merged.TotalBid += deal.TotalBid
+	}
+}
+
+// calculateRates computes the summary's derived metrics from its counts
+func (s *BidEfficiencySummary) calculateRates() {
+	s.BidRate, s.WinRate = rate(s.Bids, s.Opportunities), rate(s.Wins, s.Bids)
+	s.AverageFloor = average(s.TotalFloor, s.FlooredOpportunities)
+	s.AverageBid = average(s.TotalBid, s.Bids)
+	s.AverageClearingPrice = average(s.TotalClearing, s.PricedWins)
+	s.AverageBidToFloor = average(s.TotalBidToFloor, s.FlooredBids)
+	s.AverageOverbid = average(s.TotalOverbid, s.PricedWins)
+
+	for _, deal := range s.Deals {
+		deal.BidRate, deal.WinRate = rate(deal.Bids, deal.Opportunities), rate(deal.Wins, deal.Bids)
+		deal.AverageFloor = average(deal.TotalFloor, deal.Opportunities)
+		deal.AverageBid = average(deal.TotalBid, deal.Bids)
+	}
+}
+
+// BuildBidEfficiencyReport builds the bid efficiency report from a file's summary
+func BuildBidEfficiencyReport(fileID string, summary *BidEfficiencySummary) *BidEfficiencyReport {
+	report := &BidEfficiencyReport{
+		FileID:       fileID,
+		Overview:     *summary,
+		NoBidReasons: reasonCounts(summary.NoBidReasons, noBidReasonNames, summary.Requests),
+		LossReasons:  reasonCounts(summary.LossReasons, lossReasonNames, summary.Bids),
+		Deals:        make([]DealEntry, 0, len(summary.Deals)),
+	}
+	report.Overview.NoBidReasons = nil
+	report.Overview.LossReasons = nil
+	report.Overview.Deals = nil
+
+	for id, deal := range summary.Deals {
+		report.Deals = append(report.Deals, DealEntry{DealID: id, DealEfficiency: *deal})
+	}
+	sort.Slice(report.Deals, func(i, j int) bool {
+		if report.Deals[i].Opportunities != report.Deals[j].Opportunities {
+			return report.Deals[i].Opportunities > report.Deals[j].Opportunities
+		}
+		return report.Deals[i].DealID < report.Deals[j].DealID
+	})
+
+	return report
+}
+
+// reasonCounts lists reason code counts with their names, most frequent first
+func reasonCounts(counts map[string]int, names map[int]string, total int) []ReasonCount {
+	entries := make([]ReasonCount, 0, len(counts))
+	for code, count := range counts {
+		entry := ReasonCount{Code: code, Reason: "Unknown outcome", Count: count}
+		if n, err := strconv.Atoi(code); err == nil {
+			entry.Reason = names[n]
+			if entry.Reason == "" {
+				entry.Reason = "Exchange-specific reason"
+			}
+		}
+		if total > 0 {
+			entry.Share = float64(count) / float64(total) * 100
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
+
+// rate is part as a percentage of whole, or 0 when whole is 0
+func rate(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+// average is total divided by count, or 0 when count is 0
+func average(total float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
@@ -0,0 +1,186 @@
+package ingestion
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Bid shading dimensions, what a bid shading recommendation is for
+const (
+	BidShadingByExchange = "exchange"
+	BidShadingByCampaign = "campaign"
+)
+
+// Bid shading thresholds
+const (
+	// firstPriceTolerance is the share of its bid a win must pay to count as paying its bid
+	firstPriceTolerance = 0.99
+	// minShadingWins is the fewest first-price wins an exchange or campaign needs for a recommendation
+	minShadingWins = 100
+	// minShadingSavingsRate is the share of first-price spend, in percent, savings must reach
+	// for shading to be recommended
+	minShadingSavingsRate = 5.0
+)
+
+// BidShadingMetrics compares what wins paid with the price their auctions cleared at. A win
+// that pays its bid was bought first-price, and the difference between its bid and the clearing
+// price is what shading the bid could have saved.
+type BidShadingMetrics struct {
+	// PricedWins are wins with a bid, a win cost and a clearing price, and FirstPriceWins those
+	// that paid their bid
+	PricedWins     int `json:"pricedWins"`
+	FirstPriceWins int `json:"firstPriceWins"`
+	// FirstPriceSpend is what first-price wins paid, and FirstPriceClearing the prices their
+	// auctions cleared at
+	FirstPriceSpend    float64 `json:"firstPriceSpend"`
+	FirstPriceClearing float64 `json:"firstPriceClearing"`
+
+	// FirstPriceShare is the share of priced wins that paid their bid, in percent
+	FirstPriceShare float64 `json:"firstPriceShare"`
+	// PotentialSavings is first-price spend above the clearing price, and SavingsRate its share
+	// of first-price spend, in percent
+	PotentialSavings float64 `json:"potentialSavings"`
+	SavingsRate      float64 `json:"savingsRate"`
+	// ShadeFactor is the share of the bid that would still have cleared first-price auctions
+	ShadeFactor float64 `json:"shadeFactor"`
+}
+
+// BidShadingRecommendation suggests shading bids on an exchange or for a campaign
+type BidShadingRecommendation struct {
+	Dimension        string  `json:"dimension"`
+	Value            string  `json:"value"`
+	FirstPriceWins   int     `json:"firstPriceWins"`
+	PotentialSavings float64 `json:"potentialSavings"`
+	SavingsRate      float64 `json:"savingsRate"`
+	ShadeFactor      float64 `json:"shadeFactor"`
+	Message          string  `json:"message"`
+}
+
+// BidShadingSummary contains bid shading metrics by exchange and by campaign, with
+// recommendations where shading would have saved the most
+type BidShadingSummary struct {
+	BidShadingMetrics
+	Exchanges map[string]*BidShadingMetrics `json:"exchanges"`
+	Campaigns map[string]*BidShadingMetrics `json:"campaigns"`
+	// Recommendations are ordered by potential savings, largest first
+	Recommendations []BidShadingRecommendation `json:"recommendations"`
+}
+
+func newBidShadingSummary() *BidShadingSummary {
+	return &BidShadingSummary{
+		Exchanges:       make(map[string]*BidShadingMetrics),
+		Campaigns:       make(map[string]*BidShadingMetrics),
+		Recommendations: []BidShadingRecommendation{},
+	}
+}
+
+// add accumulates a won record's bid against what it paid and cleared at
+func (s *BidShadingSummary) add(record *BeeswaxLogRecord) {
+	if !record.Won() || record.BidPriceMicrosUSD <= 0 || record.WinCostMicrosUSD <= 0 || record.ClearingPriceMicrosUSD <= 0 {
+		return
+	}
+
+	exchange := record.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	s.BidShadingMetrics.add(record)
+	bidShadingMetrics(s.Exchanges, exchange).add(record)
+	if record.CampaignID != "" {
+		bidShadingMetrics(s.Campaigns, record.CampaignID).add(record)
+	}
+}
+
+// calculateRates computes derived metrics and recommends shading where it would have saved enough
+func (s *BidShadingSummary) calculateRates() {
+	s.BidShadingMetrics.calculateRates()
+	s.Recommendations = []BidShadingRecommendation{}
+	for _, dimension := range []struct {
+		name    string
+		metrics map[string]*BidShadingMetrics
+	}{
+		{BidShadingByExchange, s.Exchanges},
+		{BidShadingByCampaign, s.Campaigns},
+	} {
+		for value, metrics := range dimension.metrics {
+			metrics.calculateRates()
+			if metrics.FirstPriceWins < minShadingWins || metrics.SavingsRate < minShadingSavingsRate {
+				continue
+			}
+			s.Recommendations = append(s.Recommendations, BidShadingRecommendation{
+				Dimension:        dimension.name,
+				Value:            value,
+				FirstPriceWins:   metrics.FirstPriceWins,
+				PotentialSavings: metrics.PotentialSavings,
+				SavingsRate:      metrics.SavingsRate,
+				ShadeFactor:      metrics.ShadeFactor,
+				Message: fmt.Sprintf("Shade bids on %s %s to about %.0f%% of the bid: %.1f%% of first-price spend, %.2f, was above the clearing price",
+					dimension.name, value, metrics.ShadeFactor*100, metrics.SavingsRate, metrics.PotentialSavings),
+			})
+		}
+	}
+
+	sort.Slice(s.Recommendations, func(i, j int) bool {
+		a, b := s.Recommendations[i], s.Recommendations[j]
+		if a.PotentialSavings != b.PotentialSavings {
+			return a.PotentialSavings > b.PotentialSavings
+		}
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		return a.Value < b.Value
+	})
+}
+
+// merge accumulates another bid shading summary; recommendations are rebuilt by calculateRates
+func (s *BidShadingSummary) merge(other *BidShadingSummary) {
+	s.BidShadingMetrics.merge(&other.BidShadingMetrics)
+	for exchange, metrics := range other.Exchanges {
+		bidShadingMetrics(s.Exchanges, exchange).merge(metrics)
+	}
+	for campaignID, metrics := range other.Campaigns {
+		bidShadingMetrics(s.Campaigns, campaignID).merge(metrics)
+	}
+}
+
+// add accumulates a priced win, counting it as first-price when it paid its bid
+func (m *BidShadingMetrics) add(record *BeeswaxLogRecord) {
+	m.PricedWins++
+	if float64(record.WinCostMicrosUSD) < float64(record.BidPriceMicrosUSD)*firstPriceTolerance {
+		return
+	}
+	m.FirstPriceWins++
+	m.FirstPriceSpend += float64(record.WinCostMicrosUSD) / 1000000
+	m.FirstPriceClearing += float64(min(record.ClearingPriceMicrosUSD, record.WinCostMicrosUSD)) / 1000000
+}
+
+// merge accumulates another slice's priced wins
+func (m *BidShadingMetrics) merge(other *BidShadingMetrics) {
+	m.PricedWins += other.PricedWins
+	m.FirstPriceWins += other.FirstPriceWins
+	m.FirstPriceSpend += other.FirstPriceSpend
+	m.FirstPriceClearing += other.FirstPriceClearing
+}
+
+// calculateRates computes the first-price share, potential savings and shade factor
+func (m *BidShadingMetrics) calculateRates() {
+	m.FirstPriceShare, m.PotentialSavings, m.SavingsRate, m.ShadeFactor = 0, 0, 0, 0
+	if m.PricedWins > 0 {
+		m.FirstPriceShare = float64(m.FirstPriceWins) / float64(m.PricedWins) * 100
+	}
+	if m.FirstPriceSpend > 0 {
+		m.PotentialSavings = m.FirstPriceSpend - m.FirstPriceClearing
+		m.SavingsRate = m.PotentialSavings / m.FirstPriceSpend * 100
+		m.ShadeFactor = m.FirstPriceClearing / m.FirstPriceSpend
+	}
+}
+
+// bidShadingMetrics returns the metrics for a key, creating them if needed
+func bidShadingMetrics(metrics map[string]*BidShadingMetrics, key string) *BidShadingMetrics {
+	entry, ok := metrics[key]
+	if !ok {
+		entry = &BidShadingMetrics{}
+		metrics[key] = entry
+	}
+	return entry
+}
@@ -0,0 +1,207 @@
+package ingestion
+
+import (
+	"sort"
+	"strings"
+)
+
+// Brand safety rules a violation can break
+const (
+	BrandSafetyBlockedDomain  = "blocked_domain"
+	BrandSafetyKeyword        = "keyword"
+	BrandSafetyNotAllowlisted = "not_allowlisted"
+)
+
+// BrandSafetyRules screens domains against block lists, allow lists and keyword sets. A
+// blocked domain is always a violation; an allowlisted domain is exempt from keywords; and
+// once any allow list exists, domains on none of them are violations.
+type BrandSafetyRules struct {
+	blocked  map[string]string
+	allowed  map[string]string
+	keywords []brandSafetyKeyword
+}
+
+type brandSafetyKeyword struct {
+	keyword string
+	list    string
+}
+
+// BrandSafetyViolation is spend by a campaign on a domain that breaks a brand safety rule
+type BrandSafetyViolation struct {
+	CampaignID string `json:"campaignId"`
+	Domain     string `json:"domain"`
+	Rule       string `json:"rule"`
+	// List is the name of the list the domain matched, and Match the entry or keyword it matched
+	List        string  `json:"list,omitempty"`
+	Match       string  `json:"match,omitempty"`
+	Impressions int     `json:"impressions"`
+	Spend       float64 `json:"spend"`
+}
+
+// CampaignBrandSafety is a campaign's delivery with the share of it that broke brand safety rules
+type CampaignBrandSafety struct {
+	CampaignID           string  `json:"campaignId,omitempty"`
+	Impressions          int     `json:"impressions"`
+	Spend                float64 `json:"spend"`
+	ViolationImpressions int     `json:"violationImpressions"`
+	ViolationSpend       float64 `json:"violationSpend"`
+	// ViolationRate is the share of spend that broke a rule, in percent
+	ViolationRate float64 `json:"violationRate"`
+}
+
+// BrandSafetyReport is the brand safety screening of a processed file
+type BrandSafetyReport struct {
+	FileID     string                 `json:"fileId"`
+	Totals     CampaignBrandSafety    `json:"totals"`
+	Campaigns  []CampaignBrandSafety  `json:"campaigns"`
+	Violations []BrandSafetyViolation `json:"violations"`
+}
+
+// NewBrandSafetyRules creates an empty rule set that flags nothing
+func NewBrandSafetyRules() *BrandSafetyRules {
+	return &BrandSafetyRules{
+		blocked: make(map[string]string),
+		allowed: make(map[string]string),
+	}
+}
+
+// AddBlocklist blocks a list's domains and their subdomains
+func (r *BrandSafetyRules) AddBlocklist(name string, domains []string) {
+	for _, domain := range domains {
+		r.blocked[NormalizeListDomain(domain)] = name
+	}
+}
+
+// AddAllowlist allows a list's domains and their subdomains
+func (r *BrandSafetyRules) AddAllowlist(name string, domains []string) {
+	for _, domain := range domains {
+		r.allowed[NormalizeListDomain(domain)] = name
+	}
+}
+
+// AddKeywords flags domains containing any of a list's keywords
+func (r *BrandSafetyRules) AddKeywords(name string, keywords []string) {
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			r.keywords = append(r.keywords, brandSafetyKeyword{keyword: keyword, list: name})
+		}
+	}
+}
+
+// NormalizeListDomain normalizes a block or allow list entry, accepting wildcards such as *.example.com
+func NormalizeListDomain(entry string) string {
+	return NormalizeDomain(strings.TrimPrefix(strings.TrimSpace(entry), "*."))
+}
+
+// Screen checks a domain, returning the rule it breaks with the list and entry it matched, or
+// an empty rule when the domain is safe
+func (r *BrandSafetyRules) Screen(domain string) (rule, list, match string) {
+	host := NormalizeDomain(domain)
+
+	if entry, name, ok := matchListDomain(r.blocked, host); ok {
+		return BrandSafetyBlockedDomain, name, entry
+	}
+	if _, _, ok := matchListDomain(r.allowed, host); ok {
+		return "", "", ""
+	}
+	for _, keyword := range r.keywords {
+		if strings.Contains(host, keyword.keyword) {
+			return BrandSafetyKeyword, keyword.list, keyword.keyword
+		}
+	}
+	if len(r.allowed) > 0 {
+		return BrandSafetyNotAllowlisted, "", ""
+	}
+	return "", "", ""
+}
+
+// matchListDomain finds the host or its closest parent domain in a list
+func matchListDomain(entries map[string]string, host string) (entry, list string, ok bool) {
+	for host != "" {
+		if list, ok := entries[host]; ok {
+			return host, list, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return "", "", false
+}
+
+// BuildBrandSafetyReport screens a summary's campaign domains against the rules, with
+// campaigns ordered by violation spend and violations by spend
+func BuildBrandSafetyReport(fileID string, summary *BeeswaxLogSummary, rules *BrandSafetyRules) *BrandSafetyReport {
+	report := &BrandSafetyReport{
+		FileID:     fileID,
+		Campaigns:  make([]CampaignBrandSafety, 0, len(summary.CampaignDomains)),
+		Violations: []BrandSafetyViolation{},
+	}
+
+	// Screen each domain once, however many campaigns bought it
+	type verdict struct{ rule, list, match string }
+	verdicts := make(map[string]verdict)
+
+	for campaignID, domains := range summary.CampaignDomains {
+		campaign := CampaignBrandSafety{CampaignID: campaignID}
+		for domain, metrics := range domains {
+			campaign.Impressions += metrics.Impressions
+			campaign.Spend += metrics.Spend
+
+			v, ok := verdicts[domain]
+			if !ok {
+				v.rule, v.list, v.match = rules.Screen(domain)
+				verdicts[domain] = v
+			}
+			if v.rule == "" || metrics.Impressions == 0 {
+				continue
+			}
+
+			campaign.ViolationImpressions += metrics.Impressions
+			campaign.ViolationSpend += metrics.Spend
+			report.Violations = append(report.Violations, BrandSafetyViolation{
+				CampaignID:  campaignID,
+				Domain:      domain,
+				Rule:        v.rule,
+				List:        v.list,
+				Match:       v.match,
+				Impressions: metrics.Impressions,
+				Spend:       metrics.Spend,
+			})
+		}
+		campaign.calculateRate()
+		report.Campaigns = append(report.Campaigns, campaign)
+
+		report.Totals.Impressions += campaign.Impressions
+		report.Totals.Spend += campaign.Spend
+		report.Totals.ViolationImpressions += campaign.ViolationImpressions
+		report.Totals.ViolationSpend += campaign.ViolationSpend
+	}
+	report.Totals.calculateRate()
+
+	sort.Slice(report.Campaigns, func(i, j int) bool {
+		if report.Campaigns[i].ViolationSpend != report.Campaigns[j].ViolationSpend {
+			return report.Campaigns[i].ViolationSpend > report.Campaigns[j].ViolationSpend
+		}
+		return report.Campaigns[i].CampaignID < report.Campaigns[j].CampaignID
+	})
+	sort.Slice(report.Violations, func(i, j int) bool {
+		if report.Violations[i].Spend != report.Violations[j].Spend {
+			return report.Violations[i].Spend > report.Violations[j].Spend
+		}
+		if report.Violations[i].CampaignID != report.Violations[j].CampaignID {
+			return report.Violations[i].CampaignID < report.Violations[j].CampaignID
+		}
+		return report.Violations[i].Domain < report.Violations[j].Domain
+	})
+
+	return report
+}
+
+// calculateRate computes the share of spend that broke a rule
+func (c *CampaignBrandSafety) calculateRate() {
+	if c.Spend > 0 {
+		c.ViolationRate = c.ViolationSpend / c.Spend * 100
+	}
+}
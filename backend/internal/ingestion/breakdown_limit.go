@@ -0,0 +1,173 @@
+package ingestion
+
+import (
+	"sort"
+)
+
+// BreakdownOther is the key the values beyond a truncated breakdown's top N are summed under
+const BreakdownOther = "(other)"
+
+// Breakdowns that are truncated, as reported in TruncatedBreakdowns
+const (
+	BreakdownDomains         = "domains"
+	BreakdownHours           = "hours"
+	BreakdownCampaignDomains = "campaignDomains"
+)
+
+// SetBreakdownLimit caps the domain and hourly breakdowns of processed files at their top n
+// values, summing the rest into an "(other)" entry; 0 keeps every value. The full breakdowns
+// can be read back from the file's rollups.
+func (s *LogProcessorService) SetBreakdownLimit(n int) {
+	s.breakdownLimit = n
+}
+
+// LimitBreakdowns keeps the top n domains by spend and the top n hours by bids in the summary's
+// breakdowns, and the top n domains by spend of each campaign, summing the rest into
+// BreakdownOther; price floors keep only the top domains. How many values each breakdown folded
+// is recorded in TruncatedBreakdowns.
+func (summary *BeeswaxLogSummary) LimitBreakdowns(n int) {
+	if n <= 0 {
+		return
+	}
+
+	// Domains are ranked by spend, so the bid counts and categories keep the same domains
+	if len(summary.DomainPerformance) > n {
+		kept := topPerformance(summary.DomainPerformance, n)
+		folded := 0
+		for domain := range summary.DomainPerformance {
+			if !kept[domain] && domain != BreakdownOther {
+				folded++
+			}
+		}
+		summary.DomainPerformance = foldPerformance(summary.DomainPerformance, kept)
+		summary.DomainBreakdown = foldCounts(summary.DomainBreakdown, kept)
+		for domain := range summary.DomainCategories {
+			if !kept[domain] {
+				delete(summary.DomainCategories, domain)
+			}
+		}
+		if summary.PriceFloors != nil {
+			for domain := range summary.PriceFloors.Domains {
+				if !kept[domain] {
+					delete(summary.PriceFloors.Domains, domain)
+				}
+			}
+		}
+		summary.addTruncated(BreakdownDomains, folded)
+	}
+
+	if len(summary.HourlyBreakdown) > n {
+		kept := topCounts(summary.HourlyBreakdown, n)
+		folded := len(summary.HourlyBreakdown) - len(kept)
+		if _, ok := summary.HourlyBreakdown[BreakdownOther]; ok {
+			folded--
+		}
+		summary.HourlyBreakdown = foldCounts(summary.HourlyBreakdown, kept)
+		summary.addTruncated(BreakdownHours, folded)
+	}
+
+	folded := 0
+	for campaignID, domains := range summary.CampaignDomains {
+		if len(domains) <= n {
+			continue
+		}
+		kept := topPerformance(domains, n)
+		for domain := range domains {
+			if !kept[domain] && domain != BreakdownOther {
+				folded++
+			}
+		}
+		summary.CampaignDomains[campaignID] = foldPerformance(domains, kept)
+	}
+	summary.addTruncated(BreakdownCampaignDomains, folded)
+}
+
+// addTruncated records that a breakdown folded more values into its other entry
+func (summary *BeeswaxLogSummary) addTruncated(breakdown string, folded int) {
+	if folded <= 0 {
+		return
+	}
+	if summary.TruncatedBreakdowns == nil {
+		summary.TruncatedBreakdowns = make(map[string]int)
+	}
+	summary.TruncatedBreakdowns[breakdown] += folded
+}
+
+// topPerformance returns the n values with the most spend, then the most bids, leaving out any
+// other entry from an earlier truncation
+func topPerformance(performance map[string]CampaignMetrics, n int) map[string]bool {
+	values := make([]string, 0, len(performance))
+	for value := range performance {
+		if value != BreakdownOther {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := performance[values[i]], performance[values[j]]
+		if a.Spend != b.Spend {
+			return a.Spend > b.Spend
+		}
+		if a.Bids != b.Bids {
+			return a.Bids > b.Bids
+		}
+		return values[i] < values[j]
+	})
+	return keepValues(values, n)
+}
+
+// topCounts returns the n values with the highest counts, leaving out any other entry
+func topCounts(counts map[string]int, n int) map[string]bool {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		if value != BreakdownOther {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	return keepValues(values, n)
+}
+
+// keepValues returns the set of the first n values
+func keepValues(values []string, n int) map[string]bool {
+	kept := make(map[string]bool, n)
+	for _, value := range values[:min(n, len(values))] {
+		kept[value] = true
+	}
+	return kept
+}
+
+// foldPerformance returns the kept values' metrics, with the rest summed under BreakdownOther
+func foldPerformance(performance map[string]CampaignMetrics, kept map[string]bool) map[string]CampaignMetrics {
+	folded := make(map[string]CampaignMetrics, len(kept)+1)
+	var other CampaignMetrics
+	for value, metrics := range performance {
+		if kept[value] {
+			folded[value] = metrics
+		} else {
+			other.merge(metrics)
+		}
+	}
+	if other.Bids > 0 {
+		other.calculateRates()
+		folded[BreakdownOther] = other
+	}
+	return folded
+}
+
+// foldCounts returns the kept values' counts, with the rest summed under BreakdownOther
+func foldCounts(counts map[string]int, kept map[string]bool) map[string]int {
+	folded := make(map[string]int, len(kept)+1)
+	for value, count := range counts {
+		if kept[value] {
+			folded[value] = count
+		} else {
+			folded[BreakdownOther] += count
+		}
+	}
+	return folded
+}
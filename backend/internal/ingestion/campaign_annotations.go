@@ -0,0 +1,73 @@
+package ingestion
+
+import (
+	"strings"
+	"time"
+)
+
+// Annotation types, the kinds of external event a campaign can be annotated with
+const (
+	AnnotationCreativeSwap  = "creative_swap"
+	AnnotationBudgetChange  = "budget_change"
+	AnnotationBidChange     = "bid_change"
+	AnnotationTargeting     = "targeting_change"
+	AnnotationSiteOutage    = "site_outage"
+	AnnotationTrackingIssue = "tracking_issue"
+	AnnotationOther         = "other"
+)
+
+// AnnotationTypes are the known annotation types
+var AnnotationTypes = []string{
+	AnnotationCreativeSwap,
+	AnnotationBudgetChange,
+	AnnotationBidChange,
+	AnnotationTargeting,
+	AnnotationSiteOutage,
+	AnnotationTrackingIssue,
+	AnnotationOther,
+}
+
+// CampaignAnnotation records an event outside the logs that explains a change in a campaign's
+// delivery, such as a creative swap or a landing page outage, so charts can mark when it happened
+type CampaignAnnotation struct {
+	ID         string    `json:"id"`
+	CampaignID string    `json:"campaignId"`
+	Type       string    `json:"type"`
+	Note       string    `json:"note,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Label describes the annotation in a line, as exports show it
+func (a CampaignAnnotation) Label() string {
+	if a.Note == "" {
+		return a.Type
+	}
+	return a.Type + ": " + a.Note
+}
+
+// AnnotationsIn returns the annotations, in the order given, that occurred within the bucket of a
+// grain starting at bucket
+func AnnotationsIn(annotations []CampaignAnnotation, grain string, bucket time.Time) []CampaignAnnotation {
+	end := bucket.Add(time.Hour)
+	if grain == RollupDaily {
+		end = bucket.AddDate(0, 0, 1)
+	}
+
+	var in []CampaignAnnotation
+	for _, annotation := range annotations {
+		if !annotation.OccurredAt.Before(bucket) && annotation.OccurredAt.Before(end) {
+			in = append(in, annotation)
+		}
+	}
+	return in
+}
+
+// AnnotationLabels joins the labels of annotations into one line
+func AnnotationLabels(annotations []CampaignAnnotation) string {
+	labels := make([]string, len(annotations))
+	for i, annotation := range annotations {
+		labels[i] = annotation.Label()
+	}
+	return strings.Join(labels, "; ")
+}
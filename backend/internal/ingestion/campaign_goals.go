@@ -0,0 +1,117 @@
+package ingestion
+
+import (
+	"time"
+)
+
+// Goal metrics, the names goal attainment is reported under
+const (
+	GoalCPA        = "cpa"
+	GoalCTR        = "ctr"
+	GoalROAS       = "roas"
+	GoalDailySpend = "dailySpend"
+	GoalBudget     = "budget"
+)
+
+// goalPacingTolerance is how far, in percent, average daily spend may land from its goal and
+// still count as on pace
+const goalPacingTolerance = 10
+
+// CampaignGoal holds the targets a campaign is measured against; targets left nil are unset.
+// Money targets are in the campaign's currency.
+type CampaignGoal struct {
+	CampaignID string `json:"campaignId"`
+	Currency   string `json:"currency"`
+	// TargetCPA is the highest acceptable spend per conversion
+	TargetCPA *float64 `json:"targetCpa,omitempty"`
+	// TargetCTR is the lowest acceptable click-through rate, in percent like CampaignMetrics.CTR
+	TargetCTR *float64 `json:"targetCtr,omitempty"`
+	// TargetROAS is the lowest acceptable revenue per dollar spent
+	TargetROAS *float64 `json:"targetRoas,omitempty"`
+	// DailySpend is the spend the campaign is meant to pace at each day
+	DailySpend *float64 `json:"dailySpend,omitempty"`
+	// Budget is the most the campaign may spend over its flight
+	Budget    *float64  `json:"budget,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GoalAttainment compares a metric's actual value with its goal
+type GoalAttainment struct {
+	Metric string  `json:"metric"`
+	Target float64 `json:"target"`
+	Actual float64 `json:"actual"`
+	// Attainment is how much of the goal was achieved, in percent; over 100 beats the goal,
+	// except for daily spend, where 100 is exactly on pace, and budget, where it is the share
+	// of the budget spent
+	Attainment float64 `json:"attainment"`
+	Met        bool    `json:"met"`
+}
+
+// Attainment compares metrics accumulated over the given number of days, with money in the
+// goal's currency, with the goal's targets. Targets whose metric is undefined for them, such as CPA before any paid conversion,
+// are left out.
+func (g *CampaignGoal) Attainment(metrics CampaignMetrics, days int) []GoalAttainment {
+	if g == nil {
+		return nil
+	}
+
+	var attainment []GoalAttainment
+	if g.TargetCPA != nil && metrics.Conversions > 0 && metrics.Spend > 0 {
+		// CPA is better the lower it is, so attainment is the target over the actual
+		attainment = append(attainment, newGoalAttainment(GoalCPA, *g.TargetCPA, metrics.CPA, ratio(*g.TargetCPA, metrics.CPA)*100))
+	}
+	if g.TargetCTR != nil && metrics.Impressions > 0 {
+		attainment = append(attainment, newGoalAttainment(GoalCTR, *g.TargetCTR, metrics.CTR, ratio(metrics.CTR, *g.TargetCTR)*100))
+	}
+	if g.TargetROAS != nil && metrics.Spend > 0 {
+		attainment = append(attainment, newGoalAttainment(GoalROAS, *g.TargetROAS, metrics.ROAS, ratio(metrics.ROAS, *g.TargetROAS)*100))
+	}
+	if g.DailySpend != nil && days > 0 {
+		actual := metrics.Spend / float64(days)
+		pacing := ratio(actual, *g.DailySpend) * 100
+		attainment = append(attainment, GoalAttainment{
+			Metric:     GoalDailySpend,
+			Target:     *g.DailySpend,
+			Actual:     actual,
+			Attainment: pacing,
+			Met:        pacing >= 100-goalPacingTolerance && pacing <= 100+goalPacingTolerance,
+		})
+	}
+
+	if g.Budget != nil {
+		spent := ratio(metrics.Spend, *g.Budget) * 100
+		attainment = append(attainment, GoalAttainment{
+			Metric:     GoalBudget,
+			Target:     *g.Budget,
+			Actual:     metrics.Spend,
+			Attainment: spent,
+			Met:        spent <= 100,
+		})
+	}
+
+	return attainment
+}
+
+func newGoalAttainment(metric string, target, actual, attainment float64) GoalAttainment {
+	return GoalAttainment{Metric: metric, Target: target, Actual: actual, Attainment: attainment, Met: attainment >= 100}
+}
+
+// ratio divides a by b, returning 0 when b is 0
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// ApplyGoal annotates the rollup's totals, and each of its days, with their attainment of the
+// campaign's goal. The rollup must be in the goal's currency.
+func (r *CampaignRollup) ApplyGoal(goal *CampaignGoal) {
+	r.Goals = goal.Attainment(r.Totals, len(r.Daily))
+	for i := range r.Daily {
+		// A day's spend is measured against daily pacing, not the whole budget
+		daily := *goal
+		daily.Budget = nil
+		r.Daily[i].Goals = daily.Attainment(r.Daily[i].CampaignMetrics, 1)
+	}
+}
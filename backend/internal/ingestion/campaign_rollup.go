@@ -0,0 +1,169 @@
+package ingestion
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+)
+
+// CampaignDayMetrics contains a campaign's metrics for a single day
+type CampaignDayMetrics struct {
+	Date string `json:"date"`
+	CampaignMetrics
+	// Goals is the day's attainment of the campaign's goal, when it has one
+	Goals []GoalAttainment `json:"goals,omitempty"`
+}
+
+// CampaignRollup is a continuous view of a campaign merged across multiple log files
+type CampaignRollup struct {
+	CampaignID string               `json:"campaignId"`
+	From       *time.Time           `json:"from,omitempty"`
+	To         *time.Time           `json:"to,omitempty"`
+	FileIDs    []string             `json:"fileIds"`
+	Currency   string               `json:"currency"`
+	Totals     CampaignMetrics      `json:"totals"`
+	Daily      []CampaignDayMetrics `json:"daily"`
+	// Goals is the attainment of the campaign's goal over the whole rollup, when it has one
+	Goals []GoalAttainment `json:"goals,omitempty"`
+	// Annotations are the events recorded against the campaign within the rollup's dates
+	Annotations []CampaignAnnotation `json:"annotations,omitempty"`
+}
+
+// RollupCampaign merges the daily metrics of a campaign across the given analysis results.
+// Days outside the optional from/to flight dates (inclusive) are clipped from the rollup.
+func RollupCampaign(results []*LogAnalysisResult, campaignID string, from, to *time.Time) (*CampaignRollup, error) {
+	rollup := &CampaignRollup{
+		CampaignID: campaignID,
+		From:       from,
+		To:         to,
+		FileIDs:    []string{},
+		Currency:   fxrates.BaseCurrency,
+		Daily:      []CampaignDayMetrics{},
+	}
+
+	days := make(map[string]CampaignMetrics)
+	for _, result := range results {
+		if result.Status != "completed" {
+			continue
+		}
+
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return nil, err
+		}
+
+		campaignDays, ok := summary.CampaignDaily[campaignID]
+		if !ok {
+			continue
+		}
+
+		contributed := false
+		for dayKey, metrics := range campaignDays {
+			if !withinFlight(dayKey, from, to) {
+				continue
+			}
+
+			day := days[dayKey]
+			day.merge(metrics)
+			days[dayKey] = day
+			contributed = true
+		}
+
+		if contributed {
+			rollup.FileIDs = append(rollup.FileIDs, result.FileID)
+		}
+	}
+
+	rollup.finish(days)
+	return rollup, nil
+}
+
+// CampaignRollupFromDaily builds a campaign rollup from the campaign's daily rollups, which
+// are expected to be read across files and clipped to the flight dates
+func CampaignRollupFromDaily(campaignID string, from, to *time.Time, daily []Rollup) *CampaignRollup {
+	rollup := &CampaignRollup{
+		CampaignID: campaignID,
+		From:       from,
+		To:         to,
+		FileIDs:    []string{},
+		Currency:   fxrates.BaseCurrency,
+		Daily:      []CampaignDayMetrics{},
+	}
+
+	days := make(map[string]CampaignMetrics, len(daily))
+	files := make(map[string]bool)
+	for _, bucket := range daily {
+		dayKey := bucket.Bucket.UTC().Format("2006-01-02")
+		day := days[dayKey]
+		day.merge(bucket.CampaignMetrics)
+		days[dayKey] = day
+
+		for _, fileID := range bucket.FileIDs {
+			if !files[fileID] {
+				files[fileID] = true
+				rollup.FileIDs = append(rollup.FileIDs, fileID)
+			}
+		}
+	}
+
+	rollup.finish(days)
+	return rollup
+}
+
+// finish fills the rollup's daily series and totals from its merged days
+func (rollup *CampaignRollup) finish(days map[string]CampaignMetrics) {
+	for dayKey, day := range days {
+		day.calculateRates()
+		rollup.Daily = append(rollup.Daily, CampaignDayMetrics{Date: dayKey, CampaignMetrics: day})
+		rollup.Totals.merge(day)
+	}
+	rollup.Totals.calculateRates()
+
+	// Dates are formatted as YYYY-MM-DD so lexical order is chronological
+	sort.Slice(rollup.Daily, func(i, j int) bool {
+		return rollup.Daily[i].Date < rollup.Daily[j].Date
+	})
+	sort.Strings(rollup.FileIDs)
+}
+
+// Converted returns a copy of the rollup with spend and revenue converted into another
+// currency day by day, so each day is valued at its own exchange rate. Rollups are built in
+// US dollars, the currency logs report spend in.
+func (rollup *CampaignRollup) Converted(currency string, history *fxrates.History) (*CampaignRollup, error) {
+	converted := *rollup
+	converted.Currency = currency
+	converted.Totals = CampaignMetrics{}
+	converted.Daily = make([]CampaignDayMetrics, len(rollup.Daily))
+	for i, day := range rollup.Daily {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollup date %q: %w", day.Date, err)
+		}
+		if day.Spend, err = history.Convert(day.Spend, rollup.Currency, currency, date); err != nil {
+			return nil, err
+		}
+		if day.Revenue, err = history.Convert(day.Revenue, rollup.Currency, currency, date); err != nil {
+			return nil, err
+		}
+		day.calculateRates()
+
+		converted.Daily[i] = day
+		converted.Totals.merge(day.CampaignMetrics)
+	}
+	converted.Totals.calculateRates()
+
+	return &converted, nil
+}
+
+// withinFlight checks whether a YYYY-MM-DD day key falls within the optional flight dates
+func withinFlight(dayKey string, from, to *time.Time) bool {
+	if from != nil && dayKey < from.Format("2006-01-02") {
+		return false
+	}
+	if to != nil && dayKey > to.Format("2006-01-02") {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// partialParseError is returned when parsing a CSV log fails after some of its records were
+// aggregated, with where parsing stopped and the summary of the records before that
+type partialParseError struct {
+	err     error
+	header  []string
+	offset  int64 // Input offset after the last aggregated record
+	rows    int64
+	summary *BeeswaxLogSummary
+	// pending are aggregated records not yet written to the record sink
+	pending []BeeswaxLogRecord
+}
+
+// Error returns the error parsing stopped with
+func (e *partialParseError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error parsing stopped with
+func (e *partialParseError) Unwrap() error {
+	return e.err
+}
+
+// parseCheckpoint is how far a failed parse of a CSV log got: the byte offset after the last
+// record it aggregated and the partial aggregates up to there. Parsing the file again with the
+// same options resumes from the offset and merges the rest into the partial aggregates.
+type parseCheckpoint struct {
+	Options  ParseOptions       `json:"options"`
+	FileSize int64              `json:"fileSize"`
+	Header   []string           `json:"header"`
+	Offset   int64              `json:"offset"`
+	Rows     int64              `json:"rows"`
+	Summary  *BeeswaxLogSummary `json:"summary"`
+	Rollups  []Rollup           `json:"rollups,omitempty"`
+	// Pending are records parsed before the failure that the record sink hasn't stored yet
+	Pending   []BeeswaxLogRecord `json:"pending,omitempty"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// checkpointPath returns the path of a file's parse checkpoint
+func (s *LogProcessorService) checkpointPath(fileID, userID string) string {
+	return filepath.Join(s.basePath, "reports", userID, "checkpoints", fmt.Sprintf("%s_checkpoint.json", fileID))
+}
+
+// loadCheckpoint returns the checkpoint of a file's failed parse, or nil if there is none. A
+// checkpoint taken with other options or of a file with another size can't be resumed from.
+func (s *LogProcessorService) loadCheckpoint(fileID, userID string, opts ParseOptions, fileSize int64) (*parseCheckpoint, error) {
+	data, err := os.ReadFile(s.checkpointPath(fileID, userID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint parseCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if checkpoint.Options != opts || checkpoint.FileSize != fileSize || checkpoint.Offset > fileSize || checkpoint.Summary == nil {
+		return nil, nil
+	}
+
+	return &checkpoint, nil
+}
+
+// saveCheckpoint stores a file's parse checkpoint, replacing any earlier one
+func (s *LogProcessorService) saveCheckpoint(fileID, userID string, checkpoint *parseCheckpoint) error {
+	path := s.checkpointPath(fileID, userID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to serialize checkpoint: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so a crash mid-write never leaves a truncated checkpoint
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// deleteCheckpoint removes a file's parse checkpoint once the file has been parsed in full
+func (s *LogProcessorService) deleteCheckpoint(fileID, userID string) error {
+	if err := os.Remove(s.checkpointPath(fileID, userID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
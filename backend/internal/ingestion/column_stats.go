@@ -0,0 +1,238 @@
+package ingestion
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnSampleValues is how many distinct values column statistics keep as samples
+const columnSampleValues = 5
+
+// ColumnStats profiles the values of a mapped column, to help debug exports with junk in them
+type ColumnStats struct {
+	Type string `json:"type"`
+	// NullRate is the percentage of rows without a value
+	NullRate float64 `json:"nullRate"`
+	// InvalidRate is the percentage of rows whose value doesn't parse as the column's type, so it
+	// is read as empty or zero
+	InvalidRate float64 `json:"invalidRate"`
+	// DistinctCount is the approximate number of distinct values
+	DistinctCount int64 `json:"distinctCount"`
+	// Min and Max are the smallest and largest valid values as they appear in the file, compared
+	// as numbers, timestamps or text by type; flag columns have none
+	Min string `json:"min,omitempty"`
+	Max string `json:"max,omitempty"`
+	// SampleValues are the first distinct values in the file
+	SampleValues []string `json:"sampleValues"`
+	Rows         int64    `json:"rows"`
+	Nulls        int64    `json:"nulls"`
+	Invalid      int64    `json:"invalid"`
+	// Sketch estimates the distinct count, kept so statistics can be merged across files
+	Sketch *HyperLogLog `json:"sketch,omitempty"`
+}
+
+// columnProfile accumulates one column's statistics over a run of rows. Parse workers profile
+// their batches with hashes of the values; the aggregator folds those into its sketch in order.
+type columnProfile struct {
+	column  string
+	kind    string
+	scaled  bool
+	rows    int64
+	nulls   int64
+	invalid int64
+	min     string
+	max     string
+	minKey  float64
+	maxKey  float64
+	ranged  bool
+	samples []string
+	hashes  []uint64
+	sketch  *HyperLogLog
+}
+
+// newColumnProfiles returns an empty profile for each of the layout's fields
+func (l *logLayout) newColumnProfiles() []columnProfile {
+	profiles := make([]columnProfile, len(l.fields))
+	for i, column := range l.fields {
+		_, scaled := l.format.MoneyScale[column]
+		profiles[i] = columnProfile{column: column, kind: canonicalColumnDocs[column].kind, scaled: scaled}
+	}
+	return profiles
+}
+
+// profileRow adds a row's values to the profiles of the layout's fields; fields a short row
+// lacks count as empty
+func (l *logLayout) profileRow(profiles []columnProfile, row []string) {
+	for i, idx := range l.indexes {
+		var value string
+		if idx < len(row) {
+			value = strings.TrimSpace(row[idx])
+		}
+		profiles[i].add(value)
+	}
+}
+
+// add profiles one value of the column
+func (p *columnProfile) add(value string) {
+	p.rows++
+	if value == "" {
+		p.nulls++
+		return
+	}
+
+	key, ranged, valid := parseColumnValue(p.column, p.scaled, value)
+	if !valid {
+		p.invalid++
+		return
+	}
+
+	p.hashes = append(p.hashes, hashValue(value))
+	p.addSample(value)
+	if ranged {
+		p.addRange(value, key)
+	}
+}
+
+// addSample keeps a value as a sample while there is room and it isn't one already
+func (p *columnProfile) addSample(value string) {
+	if len(p.samples) < columnSampleValues && !slices.Contains(p.samples, value) {
+		p.samples = append(p.samples, value)
+	}
+}
+
+// addRange widens the column's range to include a value. Text columns compare their values
+// directly and have a zero key.
+func (p *columnProfile) addRange(value string, key float64) {
+	if !p.ranged {
+		p.min, p.max, p.minKey, p.maxKey, p.ranged = value, value, key, key, true
+		return
+	}
+	if key < p.minKey || (p.kind == ColumnTypeString && value < p.min) {
+		p.min, p.minKey = value, key
+	}
+	if key > p.maxKey || (p.kind == ColumnTypeString && value > p.max) {
+		p.max, p.maxKey = value, key
+	}
+}
+
+// merge folds a later run of rows' profile of the same column into this one
+func (p *columnProfile) merge(other *columnProfile) {
+	p.rows += other.rows
+	p.nulls += other.nulls
+	p.invalid += other.invalid
+	for _, sample := range other.samples {
+		p.addSample(sample)
+	}
+	if other.ranged {
+		p.addRange(other.min, other.minKey)
+		p.addRange(other.max, other.maxKey)
+	}
+
+	if p.sketch == nil {
+		p.sketch = NewHyperLogLog()
+	}
+	for _, hash := range other.hashes {
+		p.sketch.addHash(hash)
+	}
+}
+
+// stats returns the column's statistics; rates are calculated with the summary's
+func (p *columnProfile) stats() *ColumnStats {
+	stats := &ColumnStats{
+		Type:         p.kind,
+		Min:          p.min,
+		Max:          p.max,
+		SampleValues: append([]string{}, p.samples...),
+		Rows:         p.rows,
+		Nulls:        p.nulls,
+		Invalid:      p.invalid,
+		Sketch:       p.sketch,
+	}
+	if stats.Sketch == nil {
+		stats.Sketch = NewHyperLogLog()
+	}
+	return stats
+}
+
+// merge folds the statistics of a column in another file into these; rates must be
+// recalculated afterwards
+func (s *ColumnStats) merge(column string, other *ColumnStats) {
+	// Rebuild the ranges' keys to compare them; money columns are compared in each file's own unit
+	profile := columnProfile{column: column, kind: s.Type, rows: s.Rows, nulls: s.Nulls, invalid: s.Invalid, samples: s.SampleValues, sketch: s.Sketch}
+	for _, value := range []string{s.Min, s.Max, other.Min, other.Max} {
+		if value == "" {
+			continue
+		}
+		if key, ranged, valid := parseColumnValue(column, true, value); ranged && valid {
+			profile.addRange(value, key)
+		}
+	}
+	for _, sample := range other.SampleValues {
+		profile.addSample(sample)
+	}
+	if profile.sketch == nil {
+		profile.sketch = NewHyperLogLog()
+	}
+	profile.sketch.Merge(other.Sketch)
+
+	s.Min, s.Max, s.SampleValues, s.Sketch = profile.min, profile.max, profile.samples, profile.sketch
+	s.Rows += other.Rows
+	s.Nulls += other.Nulls
+	s.Invalid += other.Invalid
+}
+
+// calculateRates computes the null and invalid rates and the distinct count
+func (s *ColumnStats) calculateRates() {
+	if s.Rows > 0 {
+		s.NullRate = float64(s.Nulls) / float64(s.Rows) * 100
+		s.InvalidRate = float64(s.Invalid) / float64(s.Rows) * 100
+	}
+	if s.Sketch != nil {
+		s.DistinctCount = s.Sketch.Count()
+	}
+}
+
+// parseColumnValue parses a non-empty value of a canonical column, returning whether it is valid
+// and, for types with a range, a key that orders it. Scaled money columns hold amounts in
+// currency units, which may have a currency sign and thousands separators; others hold micros.
+func parseColumnValue(column string, scaled bool, value string) (key float64, ranged, valid bool) {
+	switch canonicalColumnDocs[column].kind {
+	case ColumnTypeTimestamp:
+		for _, layout := range logTimeLayouts {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return float64(parsed.UnixNano()), true, true
+			}
+		}
+		return 0, false, false
+	case ColumnTypeMoney:
+		if scaled {
+			amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(value, "$"), ",", ""), 64)
+			return amount, true, err == nil
+		}
+		micros, err := strconv.ParseInt(value, 10, 64)
+		return float64(micros), true, err == nil
+	case ColumnTypeInteger:
+		if count, err := strconv.Atoi(value); err == nil {
+			return float64(count), true, true
+		}
+		// Video events may be flags, which count as one or none
+		if strings.HasPrefix(column, "VIDEO_") && isLogFlagValue(value) {
+			return float64(parseLogCount(value)), true, true
+		}
+		return 0, false, false
+	case ColumnTypeDecimal:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, true, err == nil
+	case ColumnTypeFlag:
+		return 0, false, isLogFlagValue(value)
+	}
+	return 0, true, true
+}
+
+// isLogFlagValue reports whether a value is one flag columns recognize as true or false
+func isLogFlagValue(value string) bool {
+	value = strings.ToLower(value)
+	return slices.Contains(logFlagValues, value) || slices.Contains(logFalseFlagValues, value)
+}
@@ -0,0 +1,284 @@
+package ingestion
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// UncategorizedContent is the IAB code of domains neither the bundled mapping nor the user's
+// overrides categorize
+const UncategorizedContent = "IAB24"
+
+// contentCategoryNames are the tier 1 categories of the IAB Content Taxonomy 1.0
+var contentCategoryNames = map[string]string{
+	"IAB1":  "Arts & Entertainment",
+	"IAB2":  "Automotive",
+	"IAB3":  "Business",
+	"IAB4":  "Careers",
+	"IAB5":  "Education",
+	"IAB6":  "Family & Parenting",
+	"IAB7":  "Health & Fitness",
+	"IAB8":  "Food & Drink",
+	"IAB9":  "Hobbies & Interests",
+	"IAB10": "Home & Garden",
+	"IAB11": "Law, Government & Politics",
+	"IAB12": "News",
+	"IAB13": "Personal Finance",
+	"IAB14": "Society",
+	"IAB15": "Science",
+	"IAB16": "Pets",
+	"IAB17": "Sports",
+	"IAB18": "Style & Fashion",
+	"IAB19": "Technology & Computing",
+	"IAB20": "Travel",
+	"IAB21": "Real Estate",
+	"IAB22": "Shopping",
+	"IAB23": "Religion & Spirituality",
+	"IAB24": "Uncategorized",
+	"IAB25": "Non-Standard Content",
+	"IAB26": "Illegal Content",
+}
+
+// contentCategoryPattern matches tier 1 codes such as IAB19 and tier 2 codes such as IAB19-18
+var contentCategoryPattern = regexp.MustCompile(`^IAB([1-9]|1[0-9]|2[0-6])(-[1-9][0-9]*)?$`)
+
+// domainCategories is the bundled domain mapping, covering widely bought sites and apps.
+// Subdomains inherit their parent domain's category.
+var domainCategories = map[string]string{
+	// Arts & Entertainment
+	"imdb.com": "IAB1", "rottentomatoes.com": "IAB1", "variety.com": "IAB1", "hollywoodreporter.com": "IAB1",
+	"billboard.com": "IAB1", "rollingstone.com": "IAB1", "eonline.com": "IAB1", "tmz.com": "IAB1",
+	"people.com": "IAB1", "ew.com": "IAB1", "pitchfork.com": "IAB1", "fandom.com": "IAB1",
+	"youtube.com": "IAB1", "twitch.tv": "IAB1", "spotify.com": "IAB1", "pandora.com": "IAB1",
+	// Automotive
+	"caranddriver.com": "IAB2", "motortrend.com": "IAB2", "edmunds.com": "IAB2", "kbb.com": "IAB2",
+	"autotrader.com": "IAB2", "cars.com": "IAB2", "jalopnik.com": "IAB2", "roadandtrack.com": "IAB2",
+	// Business
+	"forbes.com": "IAB3", "bloomberg.com": "IAB3", "wsj.com": "IAB3", "businessinsider.com": "IAB3",
+	"ft.com": "IAB3", "fortune.com": "IAB3", "inc.com": "IAB3", "fastcompany.com": "IAB3", "hbr.org": "IAB3",
+	// Careers
+	"indeed.com": "IAB4", "glassdoor.com": "IAB4", "monster.com": "IAB4", "ziprecruiter.com": "IAB4",
+	// Education
+	"khanacademy.org": "IAB5", "coursera.org": "IAB5", "chegg.com": "IAB5", "quizlet.com": "IAB5",
+	"britannica.com": "IAB5", "duolingo.com": "IAB5",
+	// Family & Parenting
+	"parents.com": "IAB6", "babycenter.com": "IAB6", "whattoexpect.com": "IAB6", "thebump.com": "IAB6",
+	// Health & Fitness
+	"webmd.com": "IAB7", "healthline.com": "IAB7", "mayoclinic.org": "IAB7", "medicalnewstoday.com": "IAB7",
+	"menshealth.com": "IAB7", "womenshealthmag.com": "IAB7", "everydayhealth.com": "IAB7", "verywellhealth.com": "IAB7",
+	// Food & Drink
+	"allrecipes.com": "IAB8", "foodnetwork.com": "IAB8", "epicurious.com": "IAB8", "bonappetit.com": "IAB8",
+	"seriouseats.com": "IAB8", "delish.com": "IAB8", "tasteofhome.com": "IAB8", "food52.com": "IAB8",
+	// Hobbies & Interests
+	"ign.com": "IAB9", "gamespot.com": "IAB9", "polygon.com": "IAB9", "kotaku.com": "IAB9", "pcgamer.com": "IAB9",
+	"instructables.com": "IAB9",
+	// Home & Garden
+	"hgtv.com": "IAB10", "bhg.com": "IAB10", "thespruce.com": "IAB10", "houzz.com": "IAB10",
+	"apartmenttherapy.com": "IAB10", "architecturaldigest.com": "IAB10",
+	// Law, Government & Politics
+	"politico.com": "IAB11", "thehill.com": "IAB11", "rollcall.com": "IAB11", "fivethirtyeight.com": "IAB11",
+	// News
+	"cnn.com": "IAB12", "nytimes.com": "IAB12", "washingtonpost.com": "IAB12", "foxnews.com": "IAB12",
+	"nbcnews.com": "IAB12", "cbsnews.com": "IAB12", "abcnews.go.com": "IAB12", "usatoday.com": "IAB12",
+	"bbc.com": "IAB12", "bbc.co.uk": "IAB12", "theguardian.com": "IAB12", "reuters.com": "IAB12", "apnews.com": "IAB12",
+	"npr.org": "IAB12", "latimes.com": "IAB12", "dailymail.co.uk": "IAB12", "huffpost.com": "IAB12", "news.yahoo.com": "IAB12",
+	// Personal Finance
+	"nerdwallet.com": "IAB13", "bankrate.com": "IAB13", "investopedia.com": "IAB13", "fool.com": "IAB13",
+	"kiplinger.com": "IAB13", "marketwatch.com": "IAB13", "finance.yahoo.com": "IAB13", "creditkarma.com": "IAB13",
+	// Society
+	"theknot.com": "IAB14", "brides.com": "IAB14", "reddit.com": "IAB14", "facebook.com": "IAB14", "instagram.com": "IAB14",
+	// Science
+	"nationalgeographic.com": "IAB15", "scientificamerican.com": "IAB15", "space.com": "IAB15", "livescience.com": "IAB15",
+	"weather.com": "IAB15", "accuweather.com": "IAB15",
+	// Pets
+	"akc.org": "IAB16", "petmd.com": "IAB16", "rover.com": "IAB16", "thesprucepets.com": "IAB16",
+	// Sports
+	"espn.com": "IAB17", "si.com": "IAB17", "bleacherreport.com": "IAB17", "cbssports.com": "IAB17",
+	"nfl.com": "IAB17", "nba.com": "IAB17", "mlb.com": "IAB17", "nhl.com": "IAB17", "theathletic.com": "IAB17",
+	"sports.yahoo.com": "IAB17", "goal.com": "IAB17",
+	// Style & Fashion
+	"vogue.com": "IAB18", "elle.com": "IAB18", "harpersbazaar.com": "IAB18", "gq.com": "IAB18",
+	"cosmopolitan.com": "IAB18", "allure.com": "IAB18", "refinery29.com": "IAB18", "whowhatwear.com": "IAB18",
+	// Technology & Computing
+	"techcrunch.com": "IAB19", "theverge.com": "IAB19", "wired.com": "IAB19", "cnet.com": "IAB19",
+	"engadget.com": "IAB19", "arstechnica.com": "IAB19", "zdnet.com": "IAB19", "tomsguide.com": "IAB19",
+	"pcmag.com": "IAB19", "stackoverflow.com": "IAB19", "github.com": "IAB19", "gizmodo.com": "IAB19",
+	// Travel
+	"tripadvisor.com": "IAB20", "expedia.com": "IAB20", "booking.com": "IAB20", "kayak.com": "IAB20",
+	"lonelyplanet.com": "IAB20", "travelandleisure.com": "IAB20", "cntraveler.com": "IAB20", "airbnb.com": "IAB20",
+	// Real Estate
+	"zillow.com": "IAB21", "realtor.com": "IAB21", "redfin.com": "IAB21", "trulia.com": "IAB21", "apartments.com": "IAB21",
+	// Shopping
+	"amazon.com": "IAB22", "ebay.com": "IAB22", "walmart.com": "IAB22", "target.com": "IAB22", "etsy.com": "IAB22",
+	"bestbuy.com": "IAB22", "wayfair.com": "IAB22", "retailmenot.com": "IAB22",
+	// Religion & Spirituality
+	"beliefnet.com": "IAB23", "biblegateway.com": "IAB23",
+}
+
+// ContentCategory is an IAB content category, listed for clients choosing overrides
+type ContentCategory struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// ContentCategoryEntry is a content category with its performance, used in reports
+type ContentCategoryEntry struct {
+	ContentCategory
+	CampaignMetrics
+	// SpendShare is the category's share of the file's spend, in percent
+	SpendShare float64 `json:"spendShare"`
+	// Domains counts the domains in the category
+	Domains int `json:"domains"`
+}
+
+// ContentCategoryReport is the performance of a processed file by content vertical
+type ContentCategoryReport struct {
+	FileID     string                 `json:"fileId"`
+	Categories []ContentCategoryEntry `json:"categories"`
+	// TopDomains lists each category's domains by spend, keyed by category code
+	TopDomains map[string][]DomainMetrics `json:"topDomains"`
+}
+
+// DomainMetrics is a domain with its performance, used in reports
+type DomainMetrics struct {
+	Domain string `json:"domain"`
+	CampaignMetrics
+}
+
+// topCategoryDomains is how many domains a content category report lists per category
+const topCategoryDomains = 10
+
+// ContentCategories lists the tier 1 IAB content categories in taxonomy order
+func ContentCategories() []ContentCategory {
+	categories := make([]ContentCategory, 0, len(contentCategoryNames))
+	for code, name := range contentCategoryNames {
+		categories = append(categories, ContentCategory{Code: code, Name: name})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categoryNumber(categories[i].Code) < categoryNumber(categories[j].Code)
+	})
+	return categories
+}
+
+// IsContentCategory reports whether code is a tier 1 or tier 2 IAB content category code
+func IsContentCategory(code string) bool {
+	return contentCategoryPattern.MatchString(code)
+}
+
+// NormalizeDomain lowercases a domain and strips any scheme, path, port and www. prefix, so
+// logged domains and override keys compare equal
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if _, rest, ok := strings.Cut(domain, "://"); ok {
+		domain = rest
+	}
+	if end := strings.IndexAny(domain, "/:?#"); end >= 0 {
+		domain = domain[:end]
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(domain, "."), "www.")
+}
+
+// CategorizeDomain returns a domain's tier 1 content category, preferring the user's
+// overrides over the bundled mapping; the closest listed parent domain decides for subdomains
+func CategorizeDomain(domain string, overrides map[string]string) string {
+	for host := NormalizeDomain(domain); host != ""; {
+		if category, ok := overrides[host]; ok {
+			return tierOneCategory(category)
+		}
+		if category, ok := domainCategories[host]; ok {
+			return category
+		}
+
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			break
+		}
+		host = parent
+	}
+	return UncategorizedContent
+}
+
+// categorize rolls the summary's domain performance up into content categories
+func (summary *BeeswaxLogSummary) categorize(overrides map[string]string) {
+	summary.ContentCategories = make(map[string]CampaignMetrics)
+	summary.DomainCategories = make(map[string]string, len(summary.DomainPerformance))
+	for domain, metrics := range summary.DomainPerformance {
+		category := CategorizeDomain(domain, overrides)
+		summary.DomainCategories[domain] = category
+
+		merged := summary.ContentCategories[category]
+		merged.merge(metrics)
+		merged.calculateRates()
+		summary.ContentCategories[category] = merged
+	}
+}
+
+// BuildContentCategoryReport builds the content category report for a summary, with
+// categories ordered by spend
+func BuildContentCategoryReport(fileID string, summary *BeeswaxLogSummary) *ContentCategoryReport {
+	report := &ContentCategoryReport{
+		FileID:     fileID,
+		Categories: make([]ContentCategoryEntry, 0, len(summary.ContentCategories)),
+		TopDomains: make(map[string][]DomainMetrics),
+	}
+
+	totalSpend := 0.0
+	for _, metrics := range summary.ContentCategories {
+		totalSpend += metrics.Spend
+	}
+
+	for domain, category := range summary.DomainCategories {
+		report.TopDomains[category] = append(report.TopDomains[category], DomainMetrics{
+			Domain:          domain,
+			CampaignMetrics: summary.DomainPerformance[domain],
+		})
+	}
+
+	for code, metrics := range summary.ContentCategories {
+		entry := ContentCategoryEntry{
+			ContentCategory: ContentCategory{Code: code, Name: contentCategoryNames[code]},
+			CampaignMetrics: metrics,
+			Domains:         len(report.TopDomains[code]),
+		}
+		if totalSpend > 0 {
+			entry.SpendShare = metrics.Spend / totalSpend * 100
+		}
+		report.Categories = append(report.Categories, entry)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Spend != report.Categories[j].Spend {
+			return report.Categories[i].Spend > report.Categories[j].Spend
+		}
+		return report.Categories[i].Bids > report.Categories[j].Bids
+	})
+
+	for code, domains := range report.TopDomains {
+		sort.Slice(domains, func(i, j int) bool {
+			if domains[i].Spend != domains[j].Spend {
+				return domains[i].Spend > domains[j].Spend
+			}
+			return domains[i].Bids > domains[j].Bids
+		})
+		if len(domains) > topCategoryDomains {
+			report.TopDomains[code] = domains[:topCategoryDomains]
+		}
+	}
+
+	return report
+}
+
+// tierOneCategory rolls a tier 2 code such as IAB19-18 up to its tier 1 parent
+func tierOneCategory(code string) string {
+	tierOne, _, _ := strings.Cut(code, "-")
+	return tierOne
+}
+
+// categoryNumber is the number of a tier 1 code, for taxonomy ordering
+func categoryNumber(code string) int {
+	n := 0
+	for _, r := range strings.TrimPrefix(code, "IAB") {
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
@@ -0,0 +1,46 @@
+package ingestion
+
+import "sort"
+
+// CreativeEntry is a creative with its performance, used in reports
+type CreativeEntry struct {
+	CreativeID string `json:"creativeId"`
+	CampaignMetrics
+	// SpendShare is the creative's share of the file's spend, in percent
+	SpendShare float64 `json:"spendShare"`
+}
+
+// CreativeReport is the performance of a processed file's creatives, with their return on spend
+type CreativeReport struct {
+	FileID    string          `json:"fileId"`
+	Creatives []CreativeEntry `json:"creatives"`
+}
+
+// BuildCreativeReport builds the creative report from a file's summary, listing creatives by
+// spend, highest first
+func BuildCreativeReport(fileID string, summary *BeeswaxLogSummary) *CreativeReport {
+	report := &CreativeReport{
+		FileID:    fileID,
+		Creatives: make([]CreativeEntry, 0, len(summary.CreativePerformance)),
+	}
+
+	for creativeID, metrics := range summary.CreativePerformance {
+		entry := CreativeEntry{CreativeID: creativeID, CampaignMetrics: metrics}
+		if summary.TotalWinCost > 0 {
+			entry.SpendShare = metrics.Spend / summary.TotalWinCost * 100
+		}
+		report.Creatives = append(report.Creatives, entry)
+	}
+	sort.Slice(report.Creatives, func(i, j int) bool {
+		a, b := report.Creatives[i], report.Creatives[j]
+		if a.Spend != b.Spend {
+			return a.Spend > b.Spend
+		}
+		if a.Bids != b.Bids {
+			return a.Bids > b.Bids
+		}
+		return a.CreativeID < b.CreativeID
+	})
+
+	return report
+}
@@ -0,0 +1,146 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// ErrDatasetNotAggregated is returned when a dataset has no aggregate yet
+var ErrDatasetNotAggregated = errors.New("dataset has not been aggregated")
+
+// DatasetAggregate is the merged summary of the files appended to a dataset
+type DatasetAggregate struct {
+	DatasetID string             `json:"datasetId"`
+	UserID    string             `json:"userId"`
+	FileIDs   []string           `json:"fileIds"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Summary   *BeeswaxLogSummary `json:"summary"`
+}
+
+// Contains reports whether a file's summary is already part of the aggregate
+func (a *DatasetAggregate) Contains(fileID string) bool {
+	return slices.Contains(a.FileIDs, fileID)
+}
+
+// AppendToDataset merges a processed file's summary into a dataset's aggregate without
+// re-reading the files already in it. Appending a file twice has no effect.
+func (s *LogProcessorService) AppendToDataset(ctx context.Context, datasetID, fileID, userID string) (*DatasetAggregate, error) {
+	aggregate, err := s.GetDatasetAggregate(ctx, datasetID, userID)
+	if errors.Is(err, ErrDatasetNotAggregated) {
+		aggregate = &DatasetAggregate{DatasetID: datasetID, UserID: userID, FileIDs: []string{}}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if aggregate.Contains(fileID) {
+		return aggregate, nil
+	}
+
+	summary, err := s.completedSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregate.Summary = MergeBeeswaxSummaries(aggregate.Summary, summary)
+	aggregate.FileIDs = append(aggregate.FileIDs, fileID)
+	aggregate.UpdatedAt = time.Now()
+
+	if err := s.storeDatasetAggregate(aggregate); err != nil {
+		return nil, err
+	}
+
+	return aggregate, nil
+}
+
+// RebuildDataset replaces a dataset's aggregate with a fresh merge of the given files' summaries
+func (s *LogProcessorService) RebuildDataset(ctx context.Context, datasetID, userID string, fileIDs []string) (*DatasetAggregate, error) {
+	summaries := make([]*BeeswaxLogSummary, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		summary, err := s.completedSummary(ctx, fileID, userID)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	aggregate := &DatasetAggregate{
+		DatasetID: datasetID,
+		UserID:    userID,
+		FileIDs:   append([]string{}, fileIDs...),
+		UpdatedAt: time.Now(),
+		Summary:   MergeBeeswaxSummaries(summaries...),
+	}
+
+	if err := s.storeDatasetAggregate(aggregate); err != nil {
+		return nil, err
+	}
+
+	return aggregate, nil
+}
+
+// GetDatasetAggregate retrieves a dataset's aggregate, returning ErrDatasetNotAggregated when
+// no file has been merged into it yet
+func (s *LogProcessorService) GetDatasetAggregate(ctx context.Context, datasetID, userID string) (*DatasetAggregate, error) {
+	data, err := os.ReadFile(s.datasetPath(datasetID, userID))
+	if os.IsNotExist(err) {
+		return nil, ErrDatasetNotAggregated
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read dataset aggregate: %w", err)
+	}
+
+	var aggregate DatasetAggregate
+	if err := json.Unmarshal(data, &aggregate); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset aggregate: %w", err)
+	}
+
+	return &aggregate, nil
+}
+
+// completedSummary returns the summary of a successfully processed file
+func (s *LogProcessorService) completedSummary(ctx context.Context, fileID, userID string) (*BeeswaxLogSummary, error) {
+	result, err := s.GetAnalysisResult(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != "completed" {
+		return nil, fmt.Errorf("file %s was not processed successfully", fileID)
+	}
+
+	return result.BeeswaxSummary()
+}
+
+// storeDatasetAggregate saves a dataset's aggregate to disk
+func (s *LogProcessorService) storeDatasetAggregate(aggregate *DatasetAggregate) error {
+	path := s.datasetPath(aggregate.DatasetID, aggregate.UserID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create datasets directory: %w", err)
+	}
+
+	data, err := json.Marshal(aggregate)
+	if err != nil {
+		return fmt.Errorf("failed to serialize dataset aggregate: %w", err)
+	}
+
+	// Write to a temporary file and rename it, like analysis results
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dataset aggregate: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write dataset aggregate: %w", err)
+	}
+
+	return nil
+}
+
+// datasetPath returns where a dataset's aggregate is stored
+func (s *LogProcessorService) datasetPath(datasetID, userID string) string {
+	return filepath.Join(s.basePath, "reports", userID, "datasets", fmt.Sprintf("%s_dataset.json", datasetID))
+}
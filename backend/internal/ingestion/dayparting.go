@@ -0,0 +1,156 @@
+package ingestion
+
+import (
+	"sort"
+	"time"
+)
+
+// daypartingMinImpressionShare is the minimum share of impressions an hour needs before it is
+// considered for a recommendation, so thin hours don't produce noisy advice
+const daypartingMinImpressionShare = 0.01
+
+// daypartingThreshold is how far (as a ratio) an hour's efficiency must deviate from the
+// file average to be recommended for adjustment
+const daypartingThreshold = 0.25
+
+// DaypartCell contains the raw counts for a single day-of-week and hour-of-day slot
+type DaypartCell struct {
+	Impressions int     `json:"impressions"`
+	Clicks      int     `json:"clicks"`
+	Conversions int     `json:"conversions"`
+	Spend       float64 `json:"spend"`
+}
+
+// DaypartingGrid holds counts indexed by day of week (Sunday = 0) and hour of day
+type DaypartingGrid [7][24]DaypartCell
+
+// DaypartRecommendation suggests increasing or reducing delivery in an hour of the day
+type DaypartRecommendation struct {
+	Hour           int     `json:"hour"`
+	Action         string  `json:"action"`
+	CTR            float64 `json:"ctr"`
+	ConversionRate float64 `json:"conversionRate"`
+	CPM            float64 `json:"cpm"`
+	// EfficiencyIndex compares the hour's cost per engagement to the file average (100 = average)
+	EfficiencyIndex float64 `json:"efficiencyIndex"`
+	Reason          string  `json:"reason"`
+}
+
+// DaypartingReport contains day-of-week × hour-of-day matrices ready for heatmap rendering
+type DaypartingReport struct {
+	FileID          string                  `json:"fileId"`
+	Days            []string                `json:"days"`
+	Impressions     [7][24]int              `json:"impressions"`
+	CTR             [7][24]float64          `json:"ctr"`
+	CPM             [7][24]float64          `json:"cpm"`
+	ConversionRate  [7][24]float64          `json:"conversionRate"`
+	Recommendations []DaypartRecommendation `json:"recommendations"`
+}
+
+// add accumulates a record into the slot of its bid time
+func (g *DaypartingGrid) add(record *BeeswaxLogRecord, impressions int) {
+	cell := &g[record.BidTime.Weekday()][record.BidTime.Hour()]
+	cell.Impressions += impressions
+	cell.Clicks += record.Clicks
+	cell.Conversions += record.Conversions
+	cell.Spend += float64(record.WinCostMicrosUSD) / 1000000
+}
+
+// rates returns the CTR, conversion rate (per impression) and CPM of a cell
+func (c DaypartCell) rates() (ctr, conversionRate, cpm float64) {
+	if c.Impressions == 0 {
+		return 0, 0, 0
+	}
+	impressions := float64(c.Impressions)
+	return float64(c.Clicks) / impressions * 100,
+		float64(c.Conversions) / impressions * 100,
+		c.Spend / impressions * 1000
+}
+
+// BuildDaypartingReport builds the heatmap matrices and flight-hour recommendations for a grid
+func BuildDaypartingReport(fileID string, grid *DaypartingGrid) *DaypartingReport {
+	report := &DaypartingReport{
+		FileID:          fileID,
+		Days:            make([]string, 7),
+		Recommendations: []DaypartRecommendation{},
+	}
+
+	var total DaypartCell
+	var hours [24]DaypartCell
+	for day := 0; day < 7; day++ {
+		report.Days[day] = time.Weekday(day).String()
+		for hour := 0; hour < 24; hour++ {
+			cell := grid[day][hour]
+			report.Impressions[day][hour] = cell.Impressions
+			report.CTR[day][hour], report.ConversionRate[day][hour], report.CPM[day][hour] = cell.rates()
+
+			hours[hour].Impressions += cell.Impressions
+			hours[hour].Clicks += cell.Clicks
+			hours[hour].Conversions += cell.Conversions
+			hours[hour].Spend += cell.Spend
+			total.Impressions += cell.Impressions
+			total.Clicks += cell.Clicks
+			total.Conversions += cell.Conversions
+			total.Spend += cell.Spend
+		}
+	}
+
+	// Judge hours on cost per conversion when the file has conversions, otherwise cost per click
+	byConversions := total.Conversions > 0
+	averageCost := costPerEngagement(total, byConversions)
+	if averageCost == 0 {
+		return report
+	}
+
+	// Recommend hours whose cost per engagement deviates materially from the average
+	for hour, cell := range hours {
+		if float64(cell.Impressions) < float64(total.Impressions)*daypartingMinImpressionShare {
+			continue
+		}
+
+		ctr, conversionRate, cpm := cell.rates()
+		recommendation := DaypartRecommendation{
+			Hour:           hour,
+			CTR:            ctr,
+			ConversionRate: conversionRate,
+			CPM:            cpm,
+		}
+
+		cost := costPerEngagement(cell, byConversions)
+		switch {
+		case cost == 0 && cell.Spend > 0:
+			recommendation.Action = "reduce"
+			recommendation.Reason = "spend with no clicks or conversions"
+		case cost > 0 && cost <= averageCost*(1-daypartingThreshold):
+			recommendation.Action = "increase"
+			recommendation.EfficiencyIndex = averageCost / cost * 100
+			recommendation.Reason = "engagement is cheaper than average"
+		case cost >= averageCost*(1+daypartingThreshold):
+			recommendation.Action = "reduce"
+			recommendation.EfficiencyIndex = averageCost / cost * 100
+			recommendation.Reason = "engagement is more expensive than average"
+		default:
+			continue
+		}
+
+		report.Recommendations = append(report.Recommendations, recommendation)
+	}
+
+	sort.Slice(report.Recommendations, func(i, j int) bool {
+		return report.Recommendations[i].Hour < report.Recommendations[j].Hour
+	})
+
+	return report
+}
+
+// costPerEngagement returns spend per conversion or per click, or zero when there were none
+func costPerEngagement(cell DaypartCell, byConversions bool) float64 {
+	engagements := cell.Clicks
+	if byConversions {
+		engagements = cell.Conversions
+	}
+	if engagements == 0 {
+		return 0
+	}
+	return cell.Spend / float64(engagements)
+}
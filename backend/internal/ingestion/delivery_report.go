@@ -0,0 +1,190 @@
+package ingestion
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDeliveryReport is returned when a file isn't an ad server delivery report
+var ErrInvalidDeliveryReport = errors.New("invalid delivery report")
+
+// DeliveryReportRow is a day of a line item's delivery as counted by the ad server, split by
+// ad unit when the report includes one
+type DeliveryReportRow struct {
+	Date          string `json:"date"` // YYYY-MM-DD
+	OrderID       string `json:"orderId"`
+	OrderName     string `json:"orderName"`
+	LineItemID    string `json:"lineItemId"`
+	LineItemName  string `json:"lineItemName"`
+	AdUnit        string `json:"adUnit"`
+	Impressions   int64  `json:"impressions"`
+	Clicks        int64  `json:"clicks"`
+	RevenueMicros int64  `json:"revenueMicros"`
+}
+
+// DeliveryReport is a parsed delivery report
+type DeliveryReport struct {
+	Rows []DeliveryReportRow `json:"rows"`
+	// SkippedRows counts rows without a readable date, such as the totals row GAM appends
+	SkippedRows int `json:"skippedRows"`
+}
+
+// deliveryReportColumns maps delivery report fields to the header names Google Ad Manager uses,
+// in order of preference: the names of reports exported from the UI, then the Dimension. and
+// Column. names of reports downloaded through the API. Names are matched after normalizeColumnName.
+var deliveryReportColumns = map[string][]string{
+	"DATE":           {"Date", "Dimension.DATE"},
+	"ORDER_ID":       {"Order ID", "Dimension.ORDER_ID"},
+	"ORDER_NAME":     {"Order", "Dimension.ORDER_NAME"},
+	"LINE_ITEM_ID":   {"Line item ID", "Dimension.LINE_ITEM_ID"},
+	"LINE_ITEM_NAME": {"Line item", "Dimension.LINE_ITEM_NAME"},
+	"AD_UNIT":        {"Ad unit", "Dimension.AD_UNIT_NAME", "Dimension.AD_UNIT_ID"},
+	"IMPRESSIONS": {
+		"Ad server impressions", "Column.AD_SERVER_IMPRESSIONS",
+		"Total impressions", "Column.TOTAL_LINE_ITEM_LEVEL_IMPRESSIONS", "Impressions",
+	},
+	"CLICKS": {
+		"Ad server clicks", "Column.AD_SERVER_CLICKS",
+		"Total clicks", "Column.TOTAL_LINE_ITEM_LEVEL_CLICKS", "Clicks",
+	},
+	// UI exports show revenue in the network's currency, API downloads in micros
+	"REVENUE":        {"Ad server CPM and CPC revenue", "Total CPM and CPC revenue", "Revenue"},
+	"REVENUE_MICROS": {"Column.AD_SERVER_CPM_AND_CPC_REVENUE", "Column.TOTAL_LINE_ITEM_LEVEL_CPM_AND_CPC_REVENUE"},
+}
+
+// deliveryDateLayouts are the date formats delivery reports are exported with
+var deliveryDateLayouts = []string{
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/06",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+}
+
+// ParseDeliveryReport parses an ad server delivery report CSV, currently Google Ad Manager's
+// delivery reports. A date and an impressions column are required; rows repeating a day, order,
+// line item and ad unit, which happens when the report has dimensions not kept here, are summed.
+func ParseDeliveryReport(reader io.Reader) (*DeliveryReport, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidDeliveryReport, err)
+	}
+
+	normalized := make(map[string]int, len(header))
+	for i, col := range header {
+		name := normalizeColumnName(col)
+		if _, exists := normalized[name]; !exists {
+			normalized[name] = i
+		}
+	}
+	columns := make(map[string]int)
+	for field, names := range deliveryReportColumns {
+		for _, name := range names {
+			if idx, exists := normalized[normalizeColumnName(name)]; exists {
+				columns[field] = idx
+				break
+			}
+		}
+	}
+	for _, required := range []string{"DATE", "IMPRESSIONS"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("%w: required column not found: %s", ErrInvalidDeliveryReport, required)
+		}
+	}
+
+	value := func(row []string, field string) string {
+		idx, exists := columns[field]
+		if !exists || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	type rowKey struct{ date, orderID, orderName, lineItemID, lineItemName, adUnit string }
+	report := &DeliveryReport{}
+	index := make(map[rowKey]int)
+
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+
+		date, ok := parseDeliveryDate(value(row, "DATE"))
+		if !ok {
+			report.SkippedRows++
+			continue
+		}
+
+		parsed := DeliveryReportRow{
+			Date:         date,
+			OrderID:      value(row, "ORDER_ID"),
+			OrderName:    value(row, "ORDER_NAME"),
+			LineItemID:   value(row, "LINE_ITEM_ID"),
+			LineItemName: value(row, "LINE_ITEM_NAME"),
+			AdUnit:       value(row, "AD_UNIT"),
+			Impressions:  parseReportCount(value(row, "IMPRESSIONS")),
+			Clicks:       parseReportCount(value(row, "CLICKS")),
+		}
+		if micros := value(row, "REVENUE_MICROS"); micros != "" {
+			parsed.RevenueMicros = parseReportCount(micros)
+		} else {
+			parsed.RevenueMicros = parseReportAmount(value(row, "REVENUE"))
+		}
+
+		key := rowKey{parsed.Date, parsed.OrderID, parsed.OrderName, parsed.LineItemID, parsed.LineItemName, parsed.AdUnit}
+		if i, exists := index[key]; exists {
+			report.Rows[i].Impressions += parsed.Impressions
+			report.Rows[i].Clicks += parsed.Clicks
+			report.Rows[i].RevenueMicros += parsed.RevenueMicros
+			continue
+		}
+		index[key] = len(report.Rows)
+		report.Rows = append(report.Rows, parsed)
+	}
+
+	if len(report.Rows) == 0 {
+		return nil, fmt.Errorf("%w: no dated rows found", ErrInvalidDeliveryReport)
+	}
+
+	return report, nil
+}
+
+// parseDeliveryDate parses a report date into YYYY-MM-DD
+func parseDeliveryDate(value string) (string, bool) {
+	for _, layout := range deliveryDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// parseReportCount parses a count that may use thousands separators, e.g. "12,345"
+func parseReportCount(value string) int64 {
+	count, _ := strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, 64)
+	return count
+}
+
+// parseReportAmount parses a currency amount such as "$1,234.56" into micros
+func parseReportAmount(value string) int64 {
+	value = strings.ReplaceAll(strings.TrimLeft(value, "$€£¥ "), ",", "")
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(math.Round(amount * dollarsToMicros))
+}
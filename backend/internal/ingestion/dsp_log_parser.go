@@ -4,12 +4,14 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// BeeswaxLogRecord represents a parsed record from a Beeswax DSP log
+// BeeswaxLogRecord represents a parsed record from a DSP log. Every registered log format is
+// parsed into this canonical record, named for Beeswax whose columns it follows.
 type BeeswaxLogRecord struct {
 	AccountID              string
 	AuctionID              string
@@ -19,10 +21,13 @@ type BeeswaxLogRecord struct {
 	ClearingPriceMicrosUSD int64
 	Clicks                 int
 	Conversions            int
+	RevenueMicrosUSD       int64
 	CreativeID             string
 	Domain                 string
 	GeoCountry             string
+	GeoRegion              string
 	GeoCity                string
+	Location               *GeoPoint
 	ImpressionTime         time.Time
 	PlatformDeviceType     string
 	PlatformBrowser        string
@@ -30,17 +35,51 @@ type BeeswaxLogRecord struct {
 	WinCostMicrosUSD       int64
 	AdPosition             string
 	UserID                 string
+
+	// Viewability flags, only populated when the log includes viewability columns
+	Measurable bool
+	Viewable   bool
+
+	// Supply path, only populated when the log includes exchange and seller columns
+	Exchange           string
+	SellerID           string
+	SellerRelationship string
+	SupplyChainHops    int
+
+	// VAST quartile event counts, only populated when the log includes video columns
+	VideoStarts         int
+	VideoFirstQuartiles int
+	VideoMidpoints      int
+	VideoThirdQuartiles int
+	VideoCompletes      int
+
+	// LossReason is why the bid lost, only populated when the log includes a loss reason column
+	LossReason string
+}
+
+// Won reports whether the bid was won and paid for, making the record an impression
+func (r *BeeswaxLogRecord) Won() bool {
+	return r.WinCostMicrosUSD > 0 || r.ClearingPriceMicrosUSD > 0
 }
 
 // BeeswaxLogSummary contains aggregated metrics from a DSP log file
 type BeeswaxLogSummary struct {
+	// Source is the DSP whose log format the file was parsed as, or "mixed" for combined summaries
+	Source string `json:"source"`
+	// FieldCoverage holds the percentage of records with a value for each canonical column
+	FieldCoverage map[string]float64 `json:"fieldCoverage"`
+	// Columns profiles the values of each mapped column: null rate, distinct count, range and samples
+	Columns             map[string]*ColumnStats    `json:"columns,omitempty"`
 	TotalRecords        int                        `json:"totalRecords"`
 	TotalImpressions    int                        `json:"totalImpressions"`
 	TotalClicks         int                        `json:"totalClicks"`
 	TotalConversions    int                        `json:"totalConversions"`
 	TotalBidAmount      float64                    `json:"totalBidAmount"`
 	TotalWinCost        float64                    `json:"totalWinCost"`
+	TotalRevenue        float64                    `json:"totalRevenue"`
 	CTR                 float64                    `json:"ctr"`
+	ROAS                float64                    `json:"roas"`
+	CPA                 float64                    `json:"cpa"`
 	AverageBidPrice     float64                    `json:"averageBidPrice"`
 	AverageWinRate      float64                    `json:"averageWinRate"`
 	TimeRange           [2]time.Time               `json:"timeRange"`
@@ -51,19 +90,115 @@ type BeeswaxLogSummary struct {
 	HourlyBreakdown     map[string]int             `json:"hourlyBreakdown"`
 	DomainBreakdown     map[string]int             `json:"domainBreakdown"`
 	CampaignPerformance map[string]CampaignMetrics `json:"campaignPerformance"`
+	// CreativePerformance holds metrics keyed by creative ID
+	CreativePerformance map[string]CampaignMetrics `json:"creativePerformance,omitempty"`
+	// CampaignDaily holds per-day campaign metrics keyed by campaign ID and then by date (YYYY-MM-DD)
+	CampaignDaily map[string]map[string]CampaignMetrics `json:"campaignDaily"`
+	// CampaignDevices holds campaign metrics keyed by campaign ID and then by device type
+	CampaignDevices map[string]map[string]CampaignMetrics `json:"campaignDevices"`
+	// CampaignDomains holds campaign metrics keyed by campaign ID and then by domain, for brand safety screening
+	CampaignDomains map[string]map[string]CampaignMetrics `json:"campaignDomains,omitempty"`
+	// Funnel holds bid → impression → click → conversion counts for the whole file
+	Funnel FunnelCounts `json:"funnel"`
+	// FunnelSegments holds funnel counts keyed by dimension (device, geo, creative) and then by value
+	FunnelSegments map[string]map[string]FunnelCounts `json:"funnelSegments"`
+	// ReachFrequency holds unique reach and frequency per campaign, present when logs include USER_ID
+	ReachFrequency map[string]*ReachFrequencyMetrics `json:"reachFrequency"`
+	// Viewability and Video hold media quality metrics, present when logs include the matching columns
+	Viewability         *ViewabilityMetrics            `json:"viewability,omitempty"`
+	Video               *VideoMetrics                  `json:"video,omitempty"`
+	CampaignViewability map[string]*ViewabilityMetrics `json:"campaignViewability,omitempty"`
+	CampaignVideo       map[string]*VideoMetrics       `json:"campaignVideo,omitempty"`
+	// Geo holds the country → region → city hierarchy with metrics and centroids
+	Geo map[string]*GeoNode `json:"geo"`
+	// Dayparting holds counts by day of week and hour of day for heatmaps
+	Dayparting *DaypartingGrid `json:"dayparting"`
+	// SupplyPath holds spend and win rate by exchange and seller path, present when logs include an exchange column
+	SupplyPath *SupplyPathSummary `json:"supplyPath,omitempty"`
+	// LossReasons holds why bids lost by campaign and exchange, present when logs include loss reasons
+	LossReasons *LossReasonSummary `json:"lossReasons,omitempty"`
+	// BidEfficiency holds requests, floors, deals and loss reasons, present for OpenRTB bid logs
+	BidEfficiency *BidEfficiencySummary `json:"bidEfficiency,omitempty"`
+	// DomainPerformance holds bids, impressions and spend by domain
+	DomainPerformance map[string]CampaignMetrics `json:"domainPerformance,omitempty"`
+	// ContentCategories holds performance by tier 1 IAB content category, and DomainCategories
+	// the category each domain was assigned when the file was processed
+	ContentCategories map[string]CampaignMetrics `json:"contentCategories,omitempty"`
+	DomainCategories  map[string]string          `json:"domainCategories,omitempty"`
+	// Prebid holds bidder adapter activity, present for Prebid Server analytics logs
+	Prebid *PrebidSummary `json:"prebid,omitempty"`
+	// Prices holds bid price, clearing price and CPM percentiles, for files parsed as bid records
+	Prices *PriceDistribution `json:"prices,omitempty"`
+	// BidShading compares first-price wins with their clearing prices by exchange and campaign,
+	// recommending where to shade bids, for files parsed as bid records
+	BidShading *BidShadingSummary `json:"bidShading,omitempty"`
+	// PriceFloors holds clearing and lost bid price histograms by domain and exchange, from which
+	// floors are inferred, for files parsed as bid records
+	PriceFloors *PriceFloorSummary `json:"priceFloors,omitempty"`
+	// TruncatedBreakdowns counts the values each breakdown capped at its top N summed into its
+	// "(other)" entry, present when the file had more values than the breakdown limit
+	TruncatedBreakdowns map[string]int `json:"truncatedBreakdowns,omitempty"`
+	// UndatedRecords counts records without a readable bid time, which are left out of the
+	// hourly, daily and dayparting breakdowns
+	UndatedRecords int `json:"undatedRecords,omitempty"`
 }
 
 // CampaignMetrics contains metrics for a specific campaign
 type CampaignMetrics struct {
+	Bids        int     `json:"bids"`
 	Impressions int     `json:"impressions"`
 	Clicks      int     `json:"clicks"`
 	Conversions int     `json:"conversions"`
 	Spend       float64 `json:"spend"`
+	Revenue     float64 `json:"revenue"`
 	CTR         float64 `json:"ctr"`
+	// MeasurableImpressions and ViewableImpressions count viewability verdicts, present when
+	// the log includes viewability columns
+	MeasurableImpressions int `json:"measurableImpressions,omitempty"`
+	ViewableImpressions   int `json:"viewableImpressions,omitempty"`
+	// ROAS is revenue per dollar spent, and CPA spend per conversion; both are 0 when undefined
+	ROAS float64 `json:"roas"`
+	CPA  float64 `json:"cpa"`
+	// Custom holds the organization's custom metrics by name, evaluated when a report is read
+	Custom map[string]float64 `json:"custom,omitempty"`
 }
 
-// ParseBeeswaxLog parses a Beeswax DSP log file and returns a summary of the data
+// beeswaxRequiredColumns are the columns needed for basic analysis
+var beeswaxRequiredColumns = []string{
+	"ACCOUNT_ID", "AUCTION_ID", "BID_PRICE_MICROS_USD", "BID_TIME",
+	"CAMPAIGN_ID", "CLEARING_PRICE_MICROS_USD", "CLICKS", "CONVERSIONS",
+	"CREATIVE_ID", "DOMAIN", "GEO_COUNTRY", "GEO_CITY",
+	"PLATFORM_DEVICE_TYPE", "PLATFORM_BROWSER", "PLATFORM_OS", "WIN_COST_MICROS_USD",
+}
+
+// beeswaxColumnAliases maps canonical optional column names to the header names they may appear as
+var beeswaxColumnAliases = map[string][]string{
+	"REVENUE_MICROS_USD":     {"REVENUE_MICROS_USD", "CONVERSION_VALUE_MICROS_USD", "CONVERSION_REVENUE_MICROS_USD", "ORDER_VALUE_MICROS_USD"},
+	"VIEWABILITY_MEASURABLE": {"VIEWABILITY_MEASURABLE", "MEASURABLE", "MEASURABLE_IMPRESSION"},
+	"VIEWABLE":               {"VIEWABLE", "IS_VIEWABLE", "VIEWABLE_IMPRESSION"},
+	"VIDEO_START":            {"VIDEO_START", "VIDEO_STARTS"},
+	"VIDEO_FIRST_QUARTILE":   {"VIDEO_FIRST_QUARTILE", "VIDEO_FIRST_QUARTILES", "VIDEO_25"},
+	"VIDEO_MIDPOINT":         {"VIDEO_MIDPOINT", "VIDEO_MIDPOINTS", "VIDEO_50"},
+	"VIDEO_THIRD_QUARTILE":   {"VIDEO_THIRD_QUARTILE", "VIDEO_THIRD_QUARTILES", "VIDEO_75"},
+	"VIDEO_COMPLETE":         {"VIDEO_COMPLETE", "VIDEO_COMPLETES", "VIDEO_100"},
+	"EXCHANGE":               {"EXCHANGE", "SSP", "INVENTORY_SOURCE", "EXCHANGE_NAME"},
+	"SELLER_ID":              {"SELLER_ID", "PUBLISHER_ID", "SSP_SELLER_ID"},
+	"SELLER_RELATIONSHIP":    {"SELLER_RELATIONSHIP", "SELLER_TYPE", "ADS_TXT_RELATIONSHIP"},
+	"SCHAIN_HOPS":            {"SCHAIN_HOPS", "SUPPLY_CHAIN_HOPS", "SCHAIN_NODES"},
+	"GEO_REGION":             {"GEO_REGION", "GEO_STATE", "REGION", "GEO_SUBDIVISION"},
+	"GEO_LATITUDE":           {"GEO_LATITUDE", "GEO_LAT", "LATITUDE", "LAT"},
+	"GEO_LONGITUDE":          {"GEO_LONGITUDE", "GEO_LON", "GEO_LNG", "LONGITUDE", "LON", "LNG"},
+	"LOSS_REASON":            {"LOSS_REASON", "LOSS_REASON_CODE", "AUCTION_LOSS_REASON", "BID_LOSS_REASON"},
+}
+
+// ParseBeeswaxLog parses a DSP log file in any registered format and returns a summary of the data
 func ParseBeeswaxLog(reader io.Reader) (*BeeswaxLogSummary, error) {
+	return ParseBeeswaxLogRecords(reader, nil)
+}
+
+// ParseBeeswaxLogRecords parses a DSP log file like ParseBeeswaxLog, also passing each
+// parsed record to onRecord when it is set. Parsing stops at the first error onRecord returns.
+func ParseBeeswaxLogRecords(reader io.Reader, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
 	csvReader := csv.NewReader(reader)
 
 	// Read the header row
@@ -72,39 +207,247 @@ func ParseBeeswaxLog(reader io.Reader) (*BeeswaxLogSummary, error) {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	// Create a map from column name to index
-	colMap := make(map[string]int)
-	for i, col := range header {
-		colMap[col] = i
+	layout, err := detectLogLayout(header, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	// Required columns for basic analysis
-	requiredCols := []string{
-		"ACCOUNT_ID", "AUCTION_ID", "BID_PRICE_MICROS_USD", "BID_TIME",
-		"CAMPAIGN_ID", "CLEARING_PRICE_MICROS_USD", "CLICKS", "CONVERSIONS",
-		"CREATIVE_ID", "DOMAIN", "GEO_COUNTRY", "GEO_CITY",
-		"PLATFORM_DEVICE_TYPE", "PLATFORM_BROWSER", "PLATFORM_OS", "WIN_COST_MICROS_USD",
-	}
+	aggregator := newBeeswaxAggregator(layout)
+	filled := make([]int, len(layout.fields))
+	columns := layout.newColumnProfiles()
 
-	// Validate that required columns exist
-	for _, col := range requiredCols {
-		if _, exists := colMap[col]; !exists {
-			// If exact column not found, try to find a similar column (case insensitive)
-			found := false
-			for headerCol := range colMap {
-				if strings.ToUpper(headerCol) == col {
-					colMap[col] = colMap[headerCol]
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, fmt.Errorf("required column not found: %s", col)
+	// Parse each record
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading record: %w", err)
+		}
+
+		record := parseBeeswaxRecord(layout, row)
+		aggregator.add(&record)
+		layout.countFilled(filled, row)
+		layout.profileRow(columns, row)
+
+		if onRecord != nil {
+			if err := onRecord(&record); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	// Initialize the summary
+	aggregator.addFilled(filled)
+	aggregator.addColumns(columns)
+
+	return aggregator.finish(), nil
+}
+
+// parseBeeswaxRecord converts a CSV row into a record. Malformed numeric values are treated as zero.
+func parseBeeswaxRecord(layout *logLayout, row []string) BeeswaxLogRecord {
+	// Safely get values from the row
+	getValueSafely := func(colName string) string {
+		idx, exists := layout.columns[colName]
+		if !exists || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	record := BeeswaxLogRecord{
+		AccountID:          getValueSafely("ACCOUNT_ID"),
+		AuctionID:          getValueSafely("AUCTION_ID"),
+		CampaignID:         getValueSafely("CAMPAIGN_ID"),
+		CreativeID:         getValueSafely("CREATIVE_ID"),
+		Domain:             getValueSafely("DOMAIN"),
+		GeoCountry:         getValueSafely("GEO_COUNTRY"),
+		GeoRegion:          getValueSafely("GEO_REGION"),
+		GeoCity:            getValueSafely("GEO_CITY"),
+		PlatformDeviceType: getValueSafely("PLATFORM_DEVICE_TYPE"),
+		PlatformBrowser:    getValueSafely("PLATFORM_BROWSER"),
+		PlatformOS:         getValueSafely("PLATFORM_OS"),
+		AdPosition:         getValueSafely("AD_POSITION"),
+		UserID:             getValueSafely("USER_ID"),
+	}
+
+	// Parse timestamps
+	record.BidTime = parseLogTime(getValueSafely("BID_TIME"), "BID_TIME", layout.location)
+	record.ImpressionTime = parseLogTime(getValueSafely("IMPRESSION_TIME"), "IMPRESSION_TIME", layout.location)
+
+	// Parse prices, converting them to micros
+	record.BidPriceMicrosUSD = layout.parseMoney("BID_PRICE_MICROS_USD", getValueSafely("BID_PRICE_MICROS_USD"))
+	record.ClearingPriceMicrosUSD = layout.parseMoney("CLEARING_PRICE_MICROS_USD", getValueSafely("CLEARING_PRICE_MICROS_USD"))
+	record.WinCostMicrosUSD = layout.parseMoney("WIN_COST_MICROS_USD", getValueSafely("WIN_COST_MICROS_USD"))
+
+	// Parse coordinates, ignoring values that are missing or out of range
+	latitude, latErr := strconv.ParseFloat(getValueSafely("GEO_LATITUDE"), 64)
+	longitude, lonErr := strconv.ParseFloat(getValueSafely("GEO_LONGITUDE"), 64)
+	if latErr == nil && lonErr == nil && latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180 {
+		record.Location = &GeoPoint{Latitude: latitude, Longitude: longitude}
+	}
+
+	// Parse engagement
+	record.Clicks, _ = strconv.Atoi(getValueSafely("CLICKS"))
+	record.Conversions, _ = strconv.Atoi(getValueSafely("CONVERSIONS"))
+	record.RevenueMicrosUSD = layout.parseMoney("REVENUE_MICROS_USD", getValueSafely("REVENUE_MICROS_USD"))
+
+	// Parse viewability flags
+	viewable := getValueSafely("VIEWABLE")
+	record.Measurable = parseLogFlag(getValueSafely("VIEWABILITY_MEASURABLE"))
+	record.Viewable = parseLogFlag(viewable)
+	if _, exists := layout.columns["VIEWABILITY_MEASURABLE"]; !exists && viewable != "" {
+		// Without a measurability column, any impression with a viewability verdict was measured
+		record.Measurable = true
+	}
+	if record.Viewable {
+		// A viewable impression was necessarily measured
+		record.Measurable = true
+	}
+
+	// Parse supply path
+	record.Exchange = getValueSafely("EXCHANGE")
+	record.SellerID = getValueSafely("SELLER_ID")
+	record.SellerRelationship = normalizeSellerRelationship(getValueSafely("SELLER_RELATIONSHIP"))
+	record.SupplyChainHops, _ = strconv.Atoi(getValueSafely("SCHAIN_HOPS"))
+	if record.SellerRelationship == "" && record.SupplyChainHops > 0 {
+		// A supply chain with more than one node passed through an intermediary
+		if record.SupplyChainHops > 1 {
+			record.SellerRelationship = SellerRelationshipReseller
+		} else {
+			record.SellerRelationship = SellerRelationshipDirect
+		}
+	}
+
+	// Parse VAST quartile events
+	record.VideoStarts = parseLogCount(getValueSafely("VIDEO_START"))
+	record.VideoFirstQuartiles = parseLogCount(getValueSafely("VIDEO_FIRST_QUARTILE"))
+	record.VideoMidpoints = parseLogCount(getValueSafely("VIDEO_MIDPOINT"))
+	record.VideoThirdQuartiles = parseLogCount(getValueSafely("VIDEO_THIRD_QUARTILE"))
+	record.VideoCompletes = parseLogCount(getValueSafely("VIDEO_COMPLETE"))
+
+	// Parse the loss reason
+	record.LossReason = getValueSafely("LOSS_REASON")
+
+	return record
+}
+
+// logTimeLayouts are the timestamp formats DSP exports use, tried in order
+var logTimeLayouts = []string{
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+}
+
+// parseLogTime parses a log timestamp, returning the zero time when it is empty or malformed.
+// Timestamps without an offset are read in location, or as UTC when it's nil.
+func parseLogTime(value, column string, location *time.Location) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	var err error
+	for _, layout := range logTimeLayouts {
+		var parsed time.Time
+		if parsed, err = time.ParseInLocation(layout, value, location); err == nil {
+			return parsed
+		}
+	}
+
+	// Rows with malformed timestamps are still counted; the timestamp is left out. A bad export
+	// can have one on every row, so they're only logged at debug level.
+	slog.Debug("Malformed log timestamp", "column", column, "value", value, "error", err)
+	return time.Time{}
+}
+
+// parseLogFlag parses a boolean column that may be encoded as 1/0, true/false or yes/no
+func parseLogFlag(value string) bool {
+	value = strings.ToLower(value)
+	for _, flag := range logFlagValues {
+		if value == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// logFlagValues are the values a flag column reads as true
+var logFlagValues = []string{"1", "true", "t", "yes", "y"}
+
+// parseLogCount parses an event count column, treating boolean flags as a single event
+func parseLogCount(value string) int {
+	if count, err := strconv.Atoi(value); err == nil {
+		return count
+	}
+	if parseLogFlag(value) {
+		return 1
+	}
+	return 0
+}
+
+// beeswaxAggregator accumulates records into a BeeswaxLogSummary
+type beeswaxAggregator struct {
+	summary        *BeeswaxLogSummary
+	reachFrequency *reachFrequencyAccumulator
+	layout         *logLayout
+	filled         []int
+	columns        []columnProfile
+	hasSupplyPath  bool
+	hasViewability bool
+	hasVideo       bool
+	hasLossReasons bool
+}
+
+func newBeeswaxAggregator(layout *logLayout) *beeswaxAggregator {
+	_, hasMeasurable := layout.columns["VIEWABILITY_MEASURABLE"]
+	_, hasViewable := layout.columns["VIEWABLE"]
+	_, hasVideoStart := layout.columns["VIDEO_START"]
+	_, hasVideoComplete := layout.columns["VIDEO_COMPLETE"]
+	_, hasExchange := layout.columns["EXCHANGE"]
+	_, hasLossReason := layout.columns["LOSS_REASON"]
+
+	summary := newBeeswaxLogSummary()
+	summary.Source = layout.format.Source
+	summary.Prices = newPriceDistribution()
+	summary.BidShading = newBidShadingSummary()
+	summary.PriceFloors = newPriceFloorSummary()
+	aggregator := &beeswaxAggregator{
+		summary:        summary,
+		reachFrequency: newReachFrequencyAccumulator(),
+		layout:         layout,
+		filled:         make([]int, len(layout.fields)),
+		columns:        layout.newColumnProfiles(),
+		hasSupplyPath:  hasExchange,
+		hasViewability: hasMeasurable || hasViewable,
+		hasVideo:       hasVideoStart || hasVideoComplete,
+		hasLossReasons: hasLossReason,
+	}
+	if aggregator.hasViewability {
+		summary.Viewability = &ViewabilityMetrics{}
+		summary.CampaignViewability = make(map[string]*ViewabilityMetrics)
+	}
+	if aggregator.hasVideo {
+		summary.Video = &VideoMetrics{}
+		summary.CampaignVideo = make(map[string]*VideoMetrics)
+	}
+	if aggregator.hasSupplyPath {
+		summary.SupplyPath = newSupplyPathSummary()
+	}
+	if aggregator.hasLossReasons {
+		summary.LossReasons = newLossReasonSummary()
+	}
+
+	return aggregator
+}
+
+// newBeeswaxLogSummary creates an empty summary ready to accumulate records
+func newBeeswaxLogSummary() *BeeswaxLogSummary {
 	summary := &BeeswaxLogSummary{
 		DeviceBreakdown:     make(map[string]int),
 		BrowserBreakdown:    make(map[string]int),
@@ -112,122 +455,210 @@ func ParseBeeswaxLog(reader io.Reader) (*BeeswaxLogSummary, error) {
 		GeoBreakdown:        make(map[string]int),
 		HourlyBreakdown:     make(map[string]int),
 		DomainBreakdown:     make(map[string]int),
+		DomainPerformance:   make(map[string]CampaignMetrics),
 		CampaignPerformance: make(map[string]CampaignMetrics),
+		CreativePerformance: make(map[string]CampaignMetrics),
+		CampaignDaily:       make(map[string]map[string]CampaignMetrics),
+		CampaignDevices:     make(map[string]map[string]CampaignMetrics),
+		CampaignDomains:     make(map[string]map[string]CampaignMetrics),
+		FunnelSegments:      make(map[string]map[string]FunnelCounts),
+		ReachFrequency:      make(map[string]*ReachFrequencyMetrics),
+		FieldCoverage:       make(map[string]float64),
+		Dayparting:          &DaypartingGrid{},
+		Geo:                 make(map[string]*GeoNode),
 	}
 
 	// Initialize time range with far future and far past to ensure it gets updated
 	summary.TimeRange[0] = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
 	summary.TimeRange[1] = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	// Parse each record
-	for {
-		record, err := csvReader.Read()
-		if err == io.EOF {
-			break
+	return summary
+}
+
+// add accumulates a single record into the summary
+func (a *beeswaxAggregator) add(record *BeeswaxLogRecord) {
+	summary := a.summary
+
+	// Each record is a bid; it is an impression only when the bid was won
+	impressions := 0
+	if record.Won() {
+		impressions = 1
+	}
+	winCost := float64(record.WinCostMicrosUSD) / 1000000 // Convert micros to actual dollars
+	revenue := float64(record.RevenueMicrosUSD) / 1000000
+
+	// Update time range
+	dayKey := ""
+	if !record.BidTime.IsZero() {
+		if record.BidTime.Before(summary.TimeRange[0]) {
+			summary.TimeRange[0] = record.BidTime
 		}
-		if err != nil {
-			return nil, fmt.Errorf("error reading record: %w", err)
+		if record.BidTime.After(summary.TimeRange[1]) {
+			summary.TimeRange[1] = record.BidTime
 		}
 
-		// Safely get values from record
-		getValueSafely := func(colName string) string {
-			idx, exists := colMap[colName]
-			if !exists || idx >= len(record) {
-				return ""
-			}
-			return record[idx]
-		}
+		// Update hourly breakdown
+		hourKey := record.BidTime.Format("2006-01-02 15")
+		summary.HourlyBreakdown[hourKey]++
 
-		// Parse bid time
-		bidTimeStr := getValueSafely("BID_TIME")
-		var bidTime time.Time
-		if bidTimeStr != "" {
-			var parseErr error
-			bidTime, parseErr = time.Parse("2006-01-02 15:04:05.000", bidTimeStr)
-			if parseErr != nil {
-				// Try alternate format
-				bidTime, parseErr = time.Parse("2006-01-02 15:04:05", bidTimeStr)
-				if parseErr != nil {
-					// Just log this error but continue processing
-					fmt.Printf("Error parsing BID_TIME: %v\n", parseErr)
-				}
-			}
-		}
+		dayKey = record.BidTime.Format("2006-01-02")
 
-		// Update time range
-		if !bidTime.IsZero() {
-			if bidTime.Before(summary.TimeRange[0]) {
-				summary.TimeRange[0] = bidTime
-			}
-			if bidTime.After(summary.TimeRange[1]) {
-				summary.TimeRange[1] = bidTime
-			}
+		// Update dayparting grid
+		summary.Dayparting.add(record, impressions)
+	} else {
+		summary.UndatedRecords++
+	}
 
-			// Update hourly breakdown
-			hourKey := bidTime.Format("2006-01-02 15")
-			summary.HourlyBreakdown[hourKey]++
-		}
+	// Update summary
+	summary.TotalRecords++
+	summary.TotalImpressions += impressions
+	summary.TotalClicks += record.Clicks
+	summary.TotalConversions += record.Conversions
+	summary.TotalBidAmount += float64(record.BidPriceMicrosUSD) / 1000000 // Convert micros to actual dollars
+	summary.TotalWinCost += winCost
+	summary.TotalRevenue += revenue
+	summary.Prices.add(record)
+	summary.BidShading.add(record)
+	summary.PriceFloors.add(record)
 
-		// Parse bid price
-		bidPriceStr := getValueSafely("BID_PRICE_MICROS_USD")
-		bidPrice, _ := strconv.ParseInt(bidPriceStr, 10, 64)
-
-		// Parse win cost
-		winCostStr := getValueSafely("WIN_COST_MICROS_USD")
-		winCost, _ := strconv.ParseInt(winCostStr, 10, 64)
-
-		// Parse clicks
-		clicksStr := getValueSafely("CLICKS")
-		clicks, _ := strconv.Atoi(clicksStr)
-
-		// Parse conversions
-		conversionsStr := getValueSafely("CONVERSIONS")
-		conversions, _ := strconv.Atoi(conversionsStr)
-
-		// Get other fields
-		campaignID := getValueSafely("CAMPAIGN_ID")
-		domain := getValueSafely("DOMAIN")
-		country := getValueSafely("GEO_COUNTRY")
-		deviceType := getValueSafely("PLATFORM_DEVICE_TYPE")
-		browser := getValueSafely("PLATFORM_BROWSER")
-		os := getValueSafely("PLATFORM_OS")
-
-		// Update summary
-		summary.TotalRecords++
-		summary.TotalImpressions++
-		summary.TotalClicks += clicks
-		summary.TotalConversions += conversions
-		summary.TotalBidAmount += float64(bidPrice) / 1000000 // Convert micros to actual dollars
-		summary.TotalWinCost += float64(winCost) / 1000000    // Convert micros to actual dollars
-
-		// Update breakdowns
-		if deviceType != "" {
-			summary.DeviceBreakdown[deviceType]++
-		}
-		if browser != "" {
-			summary.BrowserBreakdown[browser]++
-		}
-		if os != "" {
-			summary.OSBreakdown[os]++
-		}
-		if country != "" {
-			summary.GeoBreakdown[country]++
+	// Update breakdowns
+	if record.PlatformDeviceType != "" {
+		summary.DeviceBreakdown[record.PlatformDeviceType]++
+	}
+	if record.PlatformBrowser != "" {
+		summary.BrowserBreakdown[record.PlatformBrowser]++
+	}
+	if record.PlatformOS != "" {
+		summary.OSBreakdown[record.PlatformOS]++
+	}
+	if record.GeoCountry != "" {
+		summary.GeoBreakdown[record.GeoCountry]++
+	}
+	if record.Domain != "" {
+		summary.DomainBreakdown[record.Domain]++
+	}
+
+	// Update geo hierarchy
+	addGeo(summary.Geo, record, impressions)
+
+	// Update funnel
+	funnel := FunnelCounts{Bids: 1, Impressions: impressions, Clicks: record.Clicks, Conversions: record.Conversions}
+	summary.Funnel.add(funnel)
+	addFunnelSegment(summary.FunnelSegments, FunnelSegmentDevice, record.PlatformDeviceType, funnel)
+	addFunnelSegment(summary.FunnelSegments, FunnelSegmentGeo, record.GeoCountry, funnel)
+	addFunnelSegment(summary.FunnelSegments, FunnelSegmentCreative, record.CreativeID, funnel)
+
+	// Update supply path
+	if a.hasSupplyPath {
+		summary.SupplyPath.add(record)
+	}
+
+	// Update loss reasons
+	if a.hasLossReasons {
+		summary.LossReasons.add(record)
+	}
+
+	// Update media quality metrics
+	if a.hasViewability && impressions > 0 {
+		summary.Viewability.add(record)
+		if record.CampaignID != "" {
+			campaignViewability(summary, record.CampaignID).add(record)
 		}
-		if domain != "" {
-			summary.DomainBreakdown[domain]++
+	}
+	if a.hasVideo && impressions > 0 {
+		summary.Video.add(record)
+		if record.CampaignID != "" {
+			campaignVideo(summary, record.CampaignID).add(record)
 		}
+	}
 
-		// Update campaign performance
-		if campaignID != "" {
-			campaign := summary.CampaignPerformance[campaignID]
-			campaign.Impressions++
-			campaign.Clicks += clicks
-			campaign.Conversions += conversions
-			campaign.Spend += float64(winCost) / 1000000
-			summary.CampaignPerformance[campaignID] = campaign
-		}
+	metrics := CampaignMetrics{
+		Bids:        1,
+		Impressions: impressions,
+		Clicks:      record.Clicks,
+		Conversions: record.Conversions,
+		Spend:       winCost,
+		Revenue:     revenue,
+	}
+	if impressions > 0 && record.Measurable {
+		metrics.MeasurableImpressions = 1
+	}
+	if impressions > 0 && record.Viewable {
+		metrics.ViewableImpressions = 1
+	}
+
+	// Update creative performance
+	if record.CreativeID != "" {
+		creative := summary.CreativePerformance[record.CreativeID]
+		creative.merge(metrics)
+		summary.CreativePerformance[record.CreativeID] = creative
+	}
+
+	// Update domain performance, rolled up into content categories once the file is parsed
+	if record.Domain != "" {
+		domain := summary.DomainPerformance[record.Domain]
+		domain.merge(metrics)
+		summary.DomainPerformance[record.Domain] = domain
+	}
+
+	// Update campaign performance
+	if record.CampaignID == "" {
+		return
+	}
+
+	campaign := summary.CampaignPerformance[record.CampaignID]
+	campaign.merge(metrics)
+	summary.CampaignPerformance[record.CampaignID] = campaign
+
+	// Update the campaign's daily performance so it can be rolled up across files
+	if dayKey != "" {
+		addCampaignSegment(summary.CampaignDaily, record.CampaignID, dayKey, metrics)
+	}
+
+	// Update the campaign's device performance for benchmarking
+	if record.PlatformDeviceType != "" {
+		addCampaignSegment(summary.CampaignDevices, record.CampaignID, record.PlatformDeviceType, metrics)
 	}
 
+	// Update the campaign's domain performance for brand safety screening
+	if record.Domain != "" {
+		addCampaignSegment(summary.CampaignDomains, record.CampaignID, record.Domain, metrics)
+	}
+
+	// Update reach and frequency when the log identifies users
+	if record.UserID != "" && impressions > 0 {
+		a.reachFrequency.add(record.CampaignID, record.UserID, dayKey, record.Conversions, winCost)
+	}
+}
+
+// addFilled accumulates counts of rows with a value for each of the layout's fields
+func (a *beeswaxAggregator) addFilled(filled []int) {
+	for i, count := range filled {
+		a.filled[i] += count
+	}
+}
+
+// addColumns folds the column profiles of the next run of rows into the summary's
+func (a *beeswaxAggregator) addColumns(columns []columnProfile) {
+	for i := range columns {
+		a.columns[i].merge(&columns[i])
+	}
+}
+
+// finish calculates derived metrics and returns the completed summary
+func (a *beeswaxAggregator) finish() *BeeswaxLogSummary {
+	a.summary.ReachFrequency = a.reachFrequency.metrics()
+	a.summary.FieldCoverage = a.layout.fieldCoverage(a.filled, a.summary.TotalRecords)
+	a.summary.Columns = make(map[string]*ColumnStats, len(a.columns))
+	for i, column := range a.layout.fields {
+		a.summary.Columns[column] = a.columns[i].stats()
+	}
+	a.summary.calculateRates()
+	return a.summary
+}
+
+// calculateRates computes the summary's derived metrics from its counts
+func (summary *BeeswaxLogSummary) calculateRates() {
 	// Calculate derived metrics
 	if summary.TotalRecords > 0 {
 		summary.AverageBidPrice = summary.TotalBidAmount / float64(summary.TotalRecords)
@@ -235,18 +666,110 @@ func ParseBeeswaxLog(reader io.Reader) (*BeeswaxLogSummary, error) {
 	if summary.TotalImpressions > 0 {
 		summary.CTR = float64(summary.TotalClicks) / float64(summary.TotalImpressions) * 100
 	}
-	// Win rate is impressions / records (assuming each record is a bid)
+	// Win rate is impressions / records (each record is a bid)
 	if summary.TotalRecords > 0 {
 		summary.AverageWinRate = float64(summary.TotalImpressions) / float64(summary.TotalRecords) * 100
 	}
+	summary.ROAS = roas(summary.TotalRevenue, summary.TotalWinCost)
+	summary.CPA = average(summary.TotalWinCost, summary.TotalConversions)
+
+	// Calculate rates for each campaign, creative, domain and content category
+	for _, performance := range []map[string]CampaignMetrics{summary.CampaignPerformance, summary.CreativePerformance, summary.DomainPerformance, summary.ContentCategories} {
+		for key, metrics := range performance {
+			metrics.calculateRates()
+			performance[key] = metrics
+		}
+	}
+	for _, segments := range []map[string]map[string]CampaignMetrics{summary.CampaignDaily, summary.CampaignDevices, summary.CampaignDomains} {
+		for _, values := range segments {
+			for key, metrics := range values {
+				metrics.calculateRates()
+				values[key] = metrics
+			}
+		}
+	}
+
+	finishGeo(summary.Geo)
+	for _, stats := range summary.Columns {
+		stats.calculateRates()
+	}
+	if summary.SupplyPath != nil {
+		summary.SupplyPath.calculateRates()
+	}
+	if summary.LossReasons != nil {
+		summary.LossReasons.calculateRates()
+	}
+	if summary.BidEfficiency != nil {
+		summary.BidEfficiency.calculateRates()
+	}
+	if summary.Prebid != nil {
+		summary.Prebid.calculateRates()
+	}
+	if summary.Prices != nil {
+		summary.Prices.calculateRates()
+	}
+	if summary.BidShading != nil {
+		summary.BidShading.calculateRates()
+	}
 
-	// Calculate CTR for each campaign
-	for id, campaign := range summary.CampaignPerformance {
-		if campaign.Impressions > 0 {
-			campaign.CTR = float64(campaign.Clicks) / float64(campaign.Impressions) * 100
-			summary.CampaignPerformance[id] = campaign
+	// Calculate media quality rates
+	if summary.Viewability != nil {
+		summary.Viewability.calculateRates(summary.TotalImpressions)
+		for id, viewability := range summary.CampaignViewability {
+			viewability.calculateRates(summary.CampaignPerformance[id].Impressions)
+		}
+	}
+	if summary.Video != nil {
+		summary.Video.calculateRates(summary.TotalImpressions)
+		for id, video := range summary.CampaignVideo {
+			video.calculateRates(summary.CampaignPerformance[id].Impressions)
 		}
 	}
+}
 
-	return summary, nil
+// merge accumulates another set of campaign metrics
+func (m *CampaignMetrics) merge(other CampaignMetrics) {
+	m.Bids += other.Bids
+	m.Impressions += other.Impressions
+	m.Clicks += other.Clicks
+	m.Conversions += other.Conversions
+	m.Spend += other.Spend
+	m.Revenue += other.Revenue
+	m.MeasurableImpressions += other.MeasurableImpressions
+	m.ViewableImpressions += other.ViewableImpressions
+}
+
+// Add accumulates another set of campaign metrics and recomputes the rates
+func (m *CampaignMetrics) Add(other CampaignMetrics) {
+	m.merge(other)
+	m.calculateRates()
+}
+
+// calculateRates computes the campaign's CTR, ROAS and CPA
+func (m *CampaignMetrics) calculateRates() {
+	if m.Impressions > 0 {
+		m.CTR = float64(m.Clicks) / float64(m.Impressions) * 100
+	}
+	m.ROAS = roas(m.Revenue, m.Spend)
+	m.CPA = average(m.Spend, m.Conversions)
+}
+
+// roas is revenue per dollar of spend, or 0 when nothing was spent
+func roas(revenue, spend float64) float64 {
+	if spend <= 0 {
+		return 0
+	}
+	return revenue / spend
+}
+
+// addCampaignSegment accumulates a campaign's metrics under a segment key such as a date or device
+func addCampaignSegment(segments map[string]map[string]CampaignMetrics, campaignID, key string, metrics CampaignMetrics) {
+	values, ok := segments[campaignID]
+	if !ok {
+		values = make(map[string]CampaignMetrics)
+		segments[campaignID] = values
+	}
+	segment := values[key]
+	segment.merge(metrics)
+	values[key] = segment
 }
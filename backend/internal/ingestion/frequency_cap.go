@@ -0,0 +1,134 @@
+package ingestion
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Frequency cap thresholds
+const (
+	// minFrequencySample is the fewest nth impressions needed to judge what an nth impression is worth
+	minFrequencySample = 200
+	// frequencyCapFloor is the share of the first impression's conversion rate below which a
+	// further impression is no longer worth buying
+	frequencyCapFloor = 0.5
+)
+
+// FrequencyCapPoint is a campaign's delivery at one frequency with its rates. Rates are in percent.
+type FrequencyCapPoint struct {
+	FrequencyPoint
+	// UserConversionRate is the share of the users who saw the campaign this many times that converted
+	UserConversionRate float64 `json:"userConversionRate"`
+	// MarginalConversionRate is conversions per nth impression and MarginalCPA their cost per
+	// conversion: what showing a user one more impression brings in
+	MarginalConversionRate float64 `json:"marginalConversionRate"`
+	MarginalCPA            float64 `json:"marginalCpa"`
+}
+
+// FrequencyCapReport analyzes a campaign's conversions by impression frequency across a user's
+// files, recommending how many impressions per user are worth buying
+type FrequencyCapReport struct {
+	CampaignID string              `json:"campaignId"`
+	From       *time.Time          `json:"from,omitempty"`
+	To         *time.Time          `json:"to,omitempty"`
+	FileIDs    []string            `json:"fileIds"`
+	Curve      []FrequencyCapPoint `json:"curve"`
+	// RecommendedCap is the most impressions per user worth buying, or 0 when no cap is recommended
+	RecommendedCap int    `json:"recommendedCap,omitempty"`
+	Recommendation string `json:"recommendation"`
+}
+
+// ComputeFrequencyCap sums a campaign's frequency curves across the given analysis results and
+// recommends a frequency cap. Files are included when they delivered the campaign on any day in
+// the optional date range; frequencies are counted within each file, as in the frequency
+// distribution.
+func ComputeFrequencyCap(results []*LogAnalysisResult, campaignID string, from, to *time.Time) (*FrequencyCapReport, error) {
+	report := &FrequencyCapReport{
+		CampaignID: campaignID,
+		From:       from,
+		To:         to,
+		FileIDs:    []string{},
+		Curve:      []FrequencyCapPoint{},
+	}
+
+	var curve []FrequencyPoint
+	for _, result := range results {
+		if result.Status != "completed" {
+			continue
+		}
+
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return nil, err
+		}
+
+		metrics, ok := summary.ReachFrequency[campaignID]
+		if !ok || len(metrics.FrequencyCurve) == 0 {
+			continue
+		}
+		for dayKey := range metrics.DailySketches {
+			if withinFlight(dayKey, from, to) {
+				report.FileIDs = append(report.FileIDs, result.FileID)
+				curve = mergeFrequencyCurves(curve, metrics.FrequencyCurve)
+				break
+			}
+		}
+	}
+	sort.Strings(report.FileIDs)
+
+	for _, point := range curve {
+		capPoint := FrequencyCapPoint{
+			FrequencyPoint:         point,
+			UserConversionRate:     rateOf(point.ConvertingUsers, point.Users),
+			MarginalConversionRate: rateOf(point.Conversions, point.Impressions),
+		}
+		if point.Conversions > 0 {
+			capPoint.MarginalCPA = point.Spend / float64(point.Conversions)
+		}
+		report.Curve = append(report.Curve, capPoint)
+	}
+	report.RecommendedCap, report.Recommendation = recommendFrequencyCap(report.Curve)
+
+	return report, nil
+}
+
+// recommendFrequencyCap walks the curve while there are enough impressions to judge each
+// frequency, capping before the first impression that converts at less than half the rate of a
+// user's first. The last point holds every impression past it, so it's judged as a whole.
+func recommendFrequencyCap(curve []FrequencyCapPoint) (int, string) {
+	if len(curve) == 0 {
+		return 0, "No impressions were served to identified users, so there's no frequency to analyze"
+	}
+	if !slices.ContainsFunc(curve, func(point FrequencyCapPoint) bool { return point.Conversions > 0 }) {
+		return 0, "No conversions were attributed to impressions of identified users, so there's nothing to base a cap on"
+	}
+	if curve[0].Impressions < minFrequencySample {
+		return 0, fmt.Sprintf("Too few users were reached to judge a cap; at least %d are needed", minFrequencySample)
+	}
+
+	first := curve[0].MarginalConversionRate
+	for i := 1; i < len(curve); i++ {
+		point := curve[i]
+		if point.Impressions < minFrequencySample {
+			return 0, fmt.Sprintf("Impressions hold their value up to %d per user; too few users saw more to judge a cap", i)
+		}
+		if point.MarginalConversionRate < first*frequencyCapFloor {
+			share := 0.0
+			if first > 0 {
+				share = point.MarginalConversionRate / first * 100
+			}
+			return i, fmt.Sprintf("Cap at %d impressions per user: impression %s converts at %.0f%% of the first impression's rate", i, point.Frequency, share)
+		}
+	}
+	return 0, fmt.Sprintf("Impressions hold their value through %s per user; no cap is needed", curve[len(curve)-1].Frequency)
+}
+
+// rateOf returns part as a percentage of whole, or 0 when whole is 0
+func rateOf(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
@@ -0,0 +1,143 @@
+package ingestion
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Funnel segment dimensions
+const (
+	FunnelSegmentDevice   = "device"
+	FunnelSegmentGeo      = "geo"
+	FunnelSegmentCreative = "creative"
+)
+
+// FunnelCounts contains the number of events at each stage of the delivery funnel
+type FunnelCounts struct {
+	Bids        int `json:"bids"`
+	Impressions int `json:"impressions"`
+	Clicks      int `json:"clicks"`
+	Conversions int `json:"conversions"`
+}
+
+// FunnelStage is a single stage of a funnel with its rate relative to the previous stage
+type FunnelStage struct {
+	Stage          string  `json:"stage"`
+	Count          int     `json:"count"`
+	ConversionRate float64 `json:"conversionRate"`
+	DropOff        float64 `json:"dropOff"`
+}
+
+// Funnel is the bid → impression → click → conversion funnel for all records or a segment
+type Funnel struct {
+	Segment     string        `json:"segment,omitempty"`
+	Stages      []FunnelStage `json:"stages"`
+	OverallRate float64       `json:"overallRate"`
+}
+
+// FunnelReport is the funnel for a processed file, optionally broken down by a dimension
+type FunnelReport struct {
+	FileID    string   `json:"fileId"`
+	Dimension string   `json:"dimension,omitempty"`
+	Overall   Funnel   `json:"overall"`
+	Segments  []Funnel `json:"segments,omitempty"`
+}
+
+// add accumulates another set of counts
+func (c *FunnelCounts) add(other FunnelCounts) {
+	c.Bids += other.Bids
+	c.Impressions += other.Impressions
+	c.Clicks += other.Clicks
+	c.Conversions += other.Conversions
+}
+
+// Funnel computes stage-to-stage conversion and drop-off percentages
+func (c FunnelCounts) Funnel(segment string) Funnel {
+	counts := []struct {
+		stage string
+		count int
+	}{
+		{"bid", c.Bids},
+		{"impression", c.Impressions},
+		{"click", c.Clicks},
+		{"conversion", c.Conversions},
+	}
+
+	funnel := Funnel{Segment: segment, Stages: make([]FunnelStage, len(counts))}
+	for i, entry := range counts {
+		stage := FunnelStage{Stage: entry.stage, Count: entry.count}
+		if i == 0 {
+			stage.ConversionRate = 100
+		} else if previous := counts[i-1].count; previous > 0 {
+			stage.ConversionRate = float64(entry.count) / float64(previous) * 100
+			stage.DropOff = 100 - stage.ConversionRate
+		}
+		funnel.Stages[i] = stage
+	}
+	if c.Bids > 0 {
+		funnel.OverallRate = float64(c.Conversions) / float64(c.Bids) * 100
+	}
+
+	return funnel
+}
+
+// BuildFunnelReport builds the funnel report for a summary, segmented by the given dimension
+// when one is provided
+func BuildFunnelReport(fileID string, summary *BeeswaxLogSummary, dimension string) (*FunnelReport, error) {
+	report := &FunnelReport{
+		FileID:    fileID,
+		Dimension: dimension,
+		Overall:   summary.Funnel.Funnel(""),
+	}
+
+	if dimension == "" {
+		return report, nil
+	}
+
+	if !IsFunnelSegment(dimension) {
+		return nil, fmt.Errorf("unsupported funnel segment: %s", dimension)
+	}
+
+	segments := summary.FunnelSegments[dimension]
+	report.Segments = make([]Funnel, 0, len(segments))
+	for value, counts := range segments {
+		report.Segments = append(report.Segments, counts.Funnel(value))
+	}
+
+	// Order segments by volume so the largest ones come first
+	sort.Slice(report.Segments, func(i, j int) bool {
+		a, b := report.Segments[i].Stages[0].Count, report.Segments[j].Stages[0].Count
+		if a != b {
+			return a > b
+		}
+		return report.Segments[i].Segment < report.Segments[j].Segment
+	})
+
+	return report, nil
+}
+
+// IsFunnelSegment reports whether the dimension can be used to segment a funnel
+func IsFunnelSegment(dimension string) bool {
+	switch dimension {
+	case FunnelSegmentDevice, FunnelSegmentGeo, FunnelSegmentCreative:
+		return true
+	}
+	return false
+}
+
+// addFunnelSegment accumulates a record's funnel counts under a dimension value
+func addFunnelSegment(segments map[string]map[string]FunnelCounts, dimension, value string, counts FunnelCounts) {
+	if value == "" {
+		return
+	}
+
+	values, ok := segments[dimension]
+	if !ok {
+		values = make(map[string]FunnelCounts)
+		segments[dimension] = values
+	}
+
+	segment := values[value]
+	segment.add(counts)
+	values[value] = segment
+}
@@ -0,0 +1,191 @@
+package ingestion
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Geo drill-down levels
+const (
+	GeoLevelCountry = "country"
+	GeoLevelRegion  = "region"
+	GeoLevelCity    = "city"
+)
+
+// ErrLocationNotFound is returned when a drill-down country or region does not exist in the file
+var ErrLocationNotFound = errors.New("location not found")
+
+// unknownGeo is used for records missing a level of the geo hierarchy
+const unknownGeo = "unknown"
+
+// GeoPoint is a latitude/longitude coordinate
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeoNode contains metrics for a country, region or city and its children in the hierarchy
+type GeoNode struct {
+	Bids        int                 `json:"bids"`
+	Impressions int                 `json:"impressions"`
+	Clicks      int                 `json:"clicks"`
+	Conversions int                 `json:"conversions"`
+	Spend       float64             `json:"spend"`
+	Centroid    *GeoPoint           `json:"centroid,omitempty"`
+	Children    map[string]*GeoNode `json:"children,omitempty"`
+
+	// Running sums for the centroid of records that carry coordinates
+	latitudeSum  float64
+	longitudeSum float64
+	located      int
+}
+
+// GeoEntry is a single location in a geo drill-down report
+type GeoEntry struct {
+	Name           string    `json:"name"`
+	Bids           int       `json:"bids"`
+	Impressions    int       `json:"impressions"`
+	Clicks         int       `json:"clicks"`
+	Conversions    int       `json:"conversions"`
+	Spend          float64   `json:"spend"`
+	CTR            float64   `json:"ctr"`
+	CPM            float64   `json:"cpm"`
+	ConversionRate float64   `json:"conversionRate"`
+	WinRate        float64   `json:"winRate"`
+	Centroid       *GeoPoint `json:"centroid,omitempty"`
+	HasChildren    bool      `json:"hasChildren"`
+}
+
+// GeoReport is one level of the country → region → city drill-down for a processed file
+type GeoReport struct {
+	FileID    string     `json:"fileId"`
+	Level     string     `json:"level"`
+	Country   string     `json:"country,omitempty"`
+	Region    string     `json:"region,omitempty"`
+	Locations []GeoEntry `json:"locations"`
+}
+
+// addGeo accumulates a record into the country → region → city tree
+func addGeo(tree map[string]*GeoNode, record *BeeswaxLogRecord, impressions int) {
+	if record.GeoCountry == "" {
+		return
+	}
+
+	path := []string{record.GeoCountry, record.GeoRegion, record.GeoCity}
+	nodes := tree
+	for _, name := range path {
+		if name == "" {
+			name = unknownGeo
+		}
+
+		node, ok := nodes[name]
+		if !ok {
+			node = &GeoNode{}
+			nodes[name] = node
+		}
+		node.add(record, impressions)
+
+		if node.Children == nil {
+			node.Children = make(map[string]*GeoNode)
+		}
+		nodes = node.Children
+	}
+}
+
+// add accumulates a record into the node
+func (n *GeoNode) add(record *BeeswaxLogRecord, impressions int) {
+	n.Bids++
+	n.Impressions += impressions
+	n.Clicks += record.Clicks
+	n.Conversions += record.Conversions
+	n.Spend += float64(record.WinCostMicrosUSD) / 1000000
+
+	if record.Location != nil {
+		n.latitudeSum += record.Location.Latitude
+		n.longitudeSum += record.Location.Longitude
+		n.located++
+	}
+}
+
+// finishGeo computes centroids throughout the tree and drops empty leaf child maps
+func finishGeo(tree map[string]*GeoNode) {
+	for _, node := range tree {
+		if node.located > 0 {
+			node.Centroid = &GeoPoint{
+				Latitude:  node.latitudeSum / float64(node.located),
+				Longitude: node.longitudeSum / float64(node.located),
+			}
+		}
+		if len(node.Children) == 0 {
+			node.Children = nil
+		}
+		finishGeo(node.Children)
+	}
+}
+
+// BuildGeoReport returns the locations at one level of the geo tree. With no country the
+// report lists countries, with a country it lists that country's regions, and with a country
+// and region it lists the region's cities.
+func BuildGeoReport(fileID string, tree map[string]*GeoNode, country, region string) (*GeoReport, error) {
+	report := &GeoReport{
+		FileID:  fileID,
+		Level:   GeoLevelCountry,
+		Country: country,
+		Region:  region,
+	}
+
+	nodes := tree
+	if country != "" {
+		node, ok := tree[country]
+		if !ok {
+			return nil, fmt.Errorf("%w: country %s", ErrLocationNotFound, country)
+		}
+		report.Level = GeoLevelRegion
+		nodes = node.Children
+
+		if region != "" {
+			node, ok = node.Children[region]
+			if !ok {
+				return nil, fmt.Errorf("%w: region %s", ErrLocationNotFound, region)
+			}
+			report.Level = GeoLevelCity
+			nodes = node.Children
+		}
+	} else if region != "" {
+		return nil, fmt.Errorf("region requires a country")
+	}
+
+	report.Locations = make([]GeoEntry, 0, len(nodes))
+	for name, node := range nodes {
+		entry := GeoEntry{
+			Name:        name,
+			Bids:        node.Bids,
+			Impressions: node.Impressions,
+			Clicks:      node.Clicks,
+			Conversions: node.Conversions,
+			Spend:       node.Spend,
+			Centroid:    node.Centroid,
+			HasChildren: report.Level != GeoLevelCity && len(node.Children) > 0,
+		}
+		if node.Impressions > 0 {
+			entry.CTR = float64(node.Clicks) / float64(node.Impressions) * 100
+			entry.CPM = node.Spend / float64(node.Impressions) * 1000
+			entry.ConversionRate = float64(node.Conversions) / float64(node.Impressions) * 100
+		}
+		if node.Bids > 0 {
+			entry.WinRate = float64(node.Impressions) / float64(node.Bids) * 100
+		}
+		report.Locations = append(report.Locations, entry)
+	}
+
+	// Order locations by spend so the most important ones come first
+	sort.Slice(report.Locations, func(i, j int) bool {
+		if report.Locations[i].Spend != report.Locations[j].Spend {
+			return report.Locations[i].Spend > report.Locations[j].Spend
+		}
+		return report.Locations[i].Name < report.Locations[j].Name
+	})
+
+	return report, nil
+}
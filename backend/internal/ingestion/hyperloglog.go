@@ -0,0 +1,117 @@
+package ingestion
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of index bits; 2^12 registers gives ~1.6% standard error in 4KB
+const hllPrecision = 12
+
+const hllRegisters = 1 << hllPrecision
+
+// HyperLogLog is an approximate distinct counter used for unique reach at scale.
+// Sketches can be merged, so reach can be computed across days and files.
+type HyperLogLog struct {
+	registers []uint8
+}
+
+// NewHyperLogLog creates an empty sketch
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]uint8, hllRegisters)}
+}
+
+// Add adds a value to the sketch
+func (h *HyperLogLog) Add(value string) {
+	h.addHash(hashValue(value))
+}
+
+// hashValue hashes a value for a sketch, so it can be hashed apart from adding it
+func hashValue(value string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	return mix64(hasher.Sum64())
+}
+
+// addHash adds a value hashed with hashValue to the sketch
+func (h *HyperLogLog) addHash(hash uint64) {
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// Merge folds another sketch into this one
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	if other == nil {
+		return
+	}
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+// Count returns the estimated number of distinct values added
+func (h *HyperLogLog) Count() int64 {
+	m := float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum := 0.0
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+
+	// Use linear counting for small cardinalities where the raw estimate is biased
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
+
+// MarshalJSON encodes the sketch registers as base64 so it can be stored with the summary
+func (h *HyperLogLog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.StdEncoding.EncodeToString(h.registers))
+}
+
+// UnmarshalJSON decodes a sketch previously encoded with MarshalJSON
+func (h *HyperLogLog) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	registers, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid sketch encoding: %w", err)
+	}
+	if len(registers) != hllRegisters {
+		return fmt.Errorf("invalid sketch size: %d", len(registers))
+	}
+
+	h.registers = registers
+	return nil
+}
+
+// mix64 is the splitmix64 finalizer, used to spread FNV output evenly across all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,140 @@
+package ingestion
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidInvoice is returned when a file isn't a DSP invoice or billing report
+var ErrInvalidInvoice = errors.New("invalid invoice")
+
+// InvoiceRow is a day of a campaign's billed spend as invoiced by the DSP
+type InvoiceRow struct {
+	Date         string `json:"date"` // YYYY-MM-DD
+	CampaignID   string `json:"campaignId"`
+	CampaignName string `json:"campaignName"`
+	Impressions  int64  `json:"impressions"`
+	SpendMicros  int64  `json:"spendMicros"`
+}
+
+// Invoice is a parsed invoice
+type Invoice struct {
+	Rows []InvoiceRow `json:"rows"`
+	// SkippedRows counts rows without a readable date or campaign, such as subtotal and tax rows
+	SkippedRows int `json:"skippedRows"`
+}
+
+// invoiceColumns maps invoice fields to the header names DSP billing exports use, in order of
+// preference. Names are matched after normalizeColumnName.
+var invoiceColumns = map[string][]string{
+	"DATE":          {"Date", "Day", "Billing date", "Delivery date", "Report date"},
+	"CAMPAIGN_ID":   {"Campaign ID", "Campaign_ID", "Line item ID", "Insertion order ID"},
+	"CAMPAIGN_NAME": {"Campaign", "Campaign name", "Line item", "Insertion order"},
+	"IMPRESSIONS":   {"Impressions", "Billable impressions", "Billed impressions"},
+	// Most exports show amounts in the billing currency, some in micros
+	"SPEND":        {"Billable spend", "Billed spend", "Media cost", "Spend", "Amount", "Total cost", "Cost"},
+	"SPEND_MICROS": {"Spend micros", "Billable spend micros", "Media cost micros", "Amount micros"},
+}
+
+// ParseInvoice parses a DSP invoice or billing CSV. A date, a campaign ID or name, and a spend
+// column are required; rows repeating a day and campaign, which happens when the invoice is
+// split by dimensions not kept here, are summed.
+func ParseInvoice(reader io.Reader) (*Invoice, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidInvoice, err)
+	}
+
+	normalized := make(map[string]int, len(header))
+	for i, col := range header {
+		name := normalizeColumnName(col)
+		if _, exists := normalized[name]; !exists {
+			normalized[name] = i
+		}
+	}
+	columns := make(map[string]int)
+	for field, names := range invoiceColumns {
+		for _, name := range names {
+			if idx, exists := normalized[normalizeColumnName(name)]; exists {
+				columns[field] = idx
+				break
+			}
+		}
+	}
+	if _, exists := columns["DATE"]; !exists {
+		return nil, fmt.Errorf("%w: required column not found: DATE", ErrInvalidInvoice)
+	}
+	_, hasID := columns["CAMPAIGN_ID"]
+	_, hasName := columns["CAMPAIGN_NAME"]
+	if !hasID && !hasName {
+		return nil, fmt.Errorf("%w: required column not found: CAMPAIGN_ID or CAMPAIGN_NAME", ErrInvalidInvoice)
+	}
+	_, hasSpend := columns["SPEND"]
+	_, hasMicros := columns["SPEND_MICROS"]
+	if !hasSpend && !hasMicros {
+		return nil, fmt.Errorf("%w: required column not found: SPEND", ErrInvalidInvoice)
+	}
+
+	value := func(row []string, field string) string {
+		idx, exists := columns[field]
+		if !exists || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	type rowKey struct{ date, campaignID, campaignName string }
+	invoice := &Invoice{}
+	index := make(map[rowKey]int)
+
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+
+		date, ok := parseDeliveryDate(value(row, "DATE"))
+		campaignID, campaignName := value(row, "CAMPAIGN_ID"), value(row, "CAMPAIGN_NAME")
+		if !ok || (campaignID == "" && campaignName == "") {
+			invoice.SkippedRows++
+			continue
+		}
+
+		parsed := InvoiceRow{
+			Date:         date,
+			CampaignID:   campaignID,
+			CampaignName: campaignName,
+			Impressions:  parseReportCount(value(row, "IMPRESSIONS")),
+		}
+		if micros := value(row, "SPEND_MICROS"); micros != "" {
+			parsed.SpendMicros = parseReportCount(micros)
+		} else {
+			parsed.SpendMicros = parseReportAmount(value(row, "SPEND"))
+		}
+
+		key := rowKey{parsed.Date, parsed.CampaignID, parsed.CampaignName}
+		if i, exists := index[key]; exists {
+			invoice.Rows[i].Impressions += parsed.Impressions
+			invoice.Rows[i].SpendMicros += parsed.SpendMicros
+			continue
+		}
+		index[key] = len(invoice.Rows)
+		invoice.Rows = append(invoice.Rows, parsed)
+	}
+
+	if len(invoice.Rows) == 0 {
+		return nil, fmt.Errorf("%w: no dated campaign rows found", ErrInvalidInvoice)
+	}
+
+	return invoice, nil
+}
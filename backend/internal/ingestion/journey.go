@@ -0,0 +1,84 @@
+package ingestion
+
+import (
+	"time"
+)
+
+// Journey event types, in the order they happen within a record
+const (
+	JourneyImpression = "impression"
+	JourneyClick      = "click"
+	JourneyConversion = "conversion"
+)
+
+// JourneyEvent is a single touchpoint of a user journey. Logs record clicks and conversions
+// on the winning bid's row without their own time, so they carry the impression's timestamp.
+type JourneyEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Journey is an anonymized user's time-ordered events within a campaign
+type Journey struct {
+	UserID string         `json:"userId"`
+	Events []JourneyEvent `json:"events"`
+}
+
+// JourneyBuilder groups a campaign's records, read ordered by user and bid time, into journeys
+type JourneyBuilder struct {
+	anonymize func(userID string) string
+	emit      func(*Journey) error
+	userID    string
+	journey   *Journey
+}
+
+// NewJourneyBuilder creates a builder that emits each user's journey, with the user ID
+// replaced by anonymize, once the user's records have all been added
+func NewJourneyBuilder(anonymize func(userID string) string, emit func(*Journey) error) *JourneyBuilder {
+	return &JourneyBuilder{
+		anonymize: anonymize,
+		emit:      emit,
+	}
+}
+
+// Add appends a record's events to its user's journey, emitting the previous user's journey
+// when the user changes
+func (b *JourneyBuilder) Add(record *BeeswaxLogRecord) error {
+	if record.UserID == "" {
+		return nil
+	}
+	if b.journey == nil || record.UserID != b.userID {
+		if err := b.Flush(); err != nil {
+			return err
+		}
+		b.userID = record.UserID
+		b.journey = &Journey{UserID: b.anonymize(record.UserID)}
+	}
+
+	timestamp := record.ImpressionTime
+	if timestamp.IsZero() {
+		timestamp = record.BidTime
+	}
+	if !record.ImpressionTime.IsZero() {
+		b.journey.Events = append(b.journey.Events, JourneyEvent{Type: JourneyImpression, Timestamp: timestamp})
+	}
+	for i := 0; i < record.Clicks; i++ {
+		b.journey.Events = append(b.journey.Events, JourneyEvent{Type: JourneyClick, Timestamp: timestamp})
+	}
+	for i := 0; i < record.Conversions; i++ {
+		b.journey.Events = append(b.journey.Events, JourneyEvent{Type: JourneyConversion, Timestamp: timestamp})
+	}
+
+	return nil
+}
+
+// Flush emits the current user's journey, if it has any events
+func (b *JourneyBuilder) Flush() error {
+	journey := b.journey
+	b.journey = nil
+	if journey == nil || len(journey.Events) == 0 {
+		return nil
+	}
+
+	return b.emit(journey)
+}
@@ -0,0 +1,278 @@
+package ingestion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFormat describes a DSP's CSV log export and how its columns map onto the canonical record
+type LogFormat struct {
+	// Source names the DSP the export comes from, e.g. "beeswax"
+	Source string
+	// Columns maps canonical column names to the header names they appear as in this export,
+	// in order of preference. Header names are matched after normalizeColumnName.
+	Columns map[string][]string
+	// Required are the canonical columns a header must resolve for the file to be in this format
+	Required []string
+	// MoneyScale converts a money column's values to micros; columns not listed are integer micros
+	MoneyScale map[string]float64
+}
+
+// canonicalColumns are the canonical record's columns, in the order field coverage is reported
+var canonicalColumns = []string{
+	"ACCOUNT_ID", "AUCTION_ID", "CAMPAIGN_ID", "CREATIVE_ID", "USER_ID",
+	"BID_TIME", "IMPRESSION_TIME",
+	"BID_PRICE_MICROS_USD", "CLEARING_PRICE_MICROS_USD", "WIN_COST_MICROS_USD",
+	"CLICKS", "CONVERSIONS", "REVENUE_MICROS_USD",
+	"DOMAIN", "AD_POSITION",
+	"GEO_COUNTRY", "GEO_REGION", "GEO_CITY", "GEO_LATITUDE", "GEO_LONGITUDE",
+	"PLATFORM_DEVICE_TYPE", "PLATFORM_BROWSER", "PLATFORM_OS",
+	"VIEWABILITY_MEASURABLE", "VIEWABLE",
+	"EXCHANGE", "SELLER_ID", "SELLER_RELATIONSHIP", "SCHAIN_HOPS",
+	"VIDEO_START", "VIDEO_FIRST_QUARTILE", "VIDEO_MIDPOINT", "VIDEO_THIRD_QUARTILE", "VIDEO_COMPLETE",
+	"LOSS_REASON",
+}
+
+// ErrUnknownLogFormat is returned when a requested log format isn't registered
+var ErrUnknownLogFormat = errors.New("unknown log format")
+
+var (
+	logFormatsMu sync.RWMutex
+	logFormats   []*LogFormat
+)
+
+func init() {
+	// Beeswax logs use the canonical column names, with aliases for some optional columns
+	beeswax := &LogFormat{
+		Source:   "beeswax",
+		Columns:  make(map[string][]string),
+		Required: beeswaxRequiredColumns,
+	}
+	for _, column := range canonicalColumns {
+		beeswax.Columns[column] = append([]string{column}, beeswaxColumnAliases[column]...)
+	}
+	RegisterLogFormat(beeswax)
+}
+
+// RegisterLogFormat adds a log format to the formats uploads are detected against.
+// Registering a source twice replaces its earlier format.
+func RegisterLogFormat(format *LogFormat) {
+	logFormatsMu.Lock()
+	defer logFormatsMu.Unlock()
+
+	for i, existing := range logFormats {
+		if existing.Source == format.Source {
+			logFormats[i] = format
+			return
+		}
+	}
+	logFormats = append(logFormats, format)
+}
+
+// LogSources lists the sources of the registered log formats
+func LogSources() []string {
+	logFormatsMu.RLock()
+	defer logFormatsMu.RUnlock()
+
+	sources := make([]string, len(logFormats))
+	for i, format := range logFormats {
+		sources[i] = format.Source
+	}
+	return sources
+}
+
+// logLayout is a log format resolved against a file's header
+type logLayout struct {
+	format *LogFormat
+	// columns maps canonical column names to their index in the header
+	columns map[string]int
+	// fields are the canonical columns present, in canonicalColumns order, with their header indexes
+	fields  []string
+	indexes []int
+	// location is the zone timestamps without an offset are read in; nil reads them as UTC
+	location *time.Location
+}
+
+// detectLogLayout works out which registered format a header belongs to, with each format's
+// columns extended by the mapping profile for its source, if any. When several formats match,
+// the one recognizing the most header columns wins, then the first registered.
+func detectLogLayout(header []string, profiles map[string]*MappingProfile) (*logLayout, error) {
+	logFormatsMu.RLock()
+	defer logFormatsMu.RUnlock()
+
+	normalized := normalizeHeader(header)
+
+	var best *logLayout
+	var closest *LogFormat
+	var closestMissing string
+	closestScore := -1
+
+	for _, format := range logFormats {
+		layout, missing := format.resolve(normalized, profiles[format.Source])
+		score := len(layout.fields)
+
+		if missing == "" {
+			if best == nil || score > len(best.fields) {
+				best = layout
+			}
+			continue
+		}
+		if score > closestScore {
+			closest, closestMissing, closestScore = format, missing, score
+		}
+	}
+
+	if best != nil {
+		return best, nil
+	}
+	if closest == nil {
+		return nil, fmt.Errorf("no log formats registered")
+	}
+	return nil, fmt.Errorf("required column not found for %s log: %s", closest.Source, closestMissing)
+}
+
+// resolveLogLayout maps a header with the format registered for source, or detects the format
+// when source is empty. Profiles extend the formats' columns by source and may be nil.
+func resolveLogLayout(header []string, source string, profiles map[string]*MappingProfile) (*logLayout, error) {
+	if source == "" {
+		return detectLogLayout(header, profiles)
+	}
+
+	format, ok := findLogFormat(source)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLogFormat, source)
+	}
+
+	layout, missing := format.resolve(normalizeHeader(header), profiles[source])
+	if missing != "" {
+		return nil, fmt.Errorf("required column not found for %s log: %s", source, missing)
+	}
+	return layout, nil
+}
+
+// findLogFormat returns the format registered for a source
+func findLogFormat(source string) (*LogFormat, bool) {
+	logFormatsMu.RLock()
+	defer logFormatsMu.RUnlock()
+
+	for _, format := range logFormats {
+		if format.Source == source {
+			return format, true
+		}
+	}
+	return nil, false
+}
+
+// normalizeHeader maps normalized column names to their index in the header; the first of
+// duplicate columns wins
+func normalizeHeader(header []string) map[string]int {
+	normalized := make(map[string]int, len(header))
+	for i, col := range header {
+		name := normalizeColumnName(col)
+		if _, exists := normalized[name]; !exists {
+			normalized[name] = i
+		}
+	}
+	return normalized
+}
+
+// resolve maps the format's canonical columns to header indexes, returning the first
+// required column that's missing, if any. The format's own names for a column are preferred
+// over those the profile adds.
+func (f *LogFormat) resolve(header map[string]int, profile *MappingProfile) (*logLayout, string) {
+	layout := &logLayout{format: f, columns: make(map[string]int)}
+	aliases := profile.aliases()
+
+	for _, canonical := range canonicalColumns {
+		idx, exists := findColumn(header, f.Columns[canonical])
+		if !exists {
+			idx, exists = findColumn(header, aliases[canonical])
+		}
+		if exists {
+			layout.columns[canonical] = idx
+			layout.fields = append(layout.fields, canonical)
+			layout.indexes = append(layout.indexes, idx)
+		}
+	}
+
+	for _, canonical := range f.Required {
+		if _, exists := layout.columns[canonical]; !exists {
+			return layout, canonical
+		}
+	}
+
+	return layout, ""
+}
+
+// findColumn returns the header index of the first of names in the header
+func findColumn(header map[string]int, names []string) (int, bool) {
+	for _, name := range names {
+		if idx, exists := header[normalizeColumnName(name)]; exists {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// IsCanonicalColumn reports whether column is one of the canonical record's columns
+func IsCanonicalColumn(column string) bool {
+	for _, canonical := range canonicalColumns {
+		if canonical == column {
+			return true
+		}
+	}
+	return false
+}
+
+// countFilled adds one to filled[i] for each present field with a value in the row
+func (l *logLayout) countFilled(filled []int, row []string) {
+	for i, idx := range l.indexes {
+		if idx < len(row) && strings.TrimSpace(row[idx]) != "" {
+			filled[i]++
+		}
+	}
+}
+
+// fieldCoverage converts filled counts to the percentage of rows with a value for every
+// canonical column; columns the export doesn't have are reported as 0
+func (l *logLayout) fieldCoverage(filled []int, rows int) map[string]float64 {
+	coverage := make(map[string]float64, len(canonicalColumns))
+	for _, column := range canonicalColumns {
+		coverage[column] = 0
+	}
+	if rows == 0 {
+		return coverage
+	}
+	for i, column := range l.fields {
+		coverage[column] = float64(filled[i]) / float64(rows) * 100
+	}
+	return coverage
+}
+
+// parseMoney parses a money column into micros using the format's scale for the column
+func (l *logLayout) parseMoney(column, value string) int64 {
+	scale, ok := l.format.MoneyScale[column]
+	if !ok {
+		micros, _ := strconv.ParseInt(value, 10, 64)
+		return micros
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(value, "$"), ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	return int64(math.Round(amount * scale))
+}
+
+// normalizeColumnName upper-cases a header name and joins its words with underscores, so
+// "Campaign ID", "campaign-id" and "CAMPAIGN_ID" all match
+func normalizeColumnName(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	return strings.ToUpper(name)
+}
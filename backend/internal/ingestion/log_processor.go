@@ -3,10 +3,15 @@ package ingestion
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
 )
 
 // LogAnalysisResult represents the result of log analysis
@@ -18,11 +23,210 @@ type LogAnalysisResult struct {
 	Summary      interface{} `json:"summary"`
 	Status       string      `json:"status"`
 	ErrorMessage string      `json:"errorMessage,omitempty"`
+	// Benchmarks compare each campaign against the user's historical campaigns
+	Benchmarks []CampaignBenchmark `json:"benchmarks,omitempty"`
+	// Narrative is a short written summary of the insights, present when narratives are enabled
+	Narrative string `json:"narrative,omitempty"`
+	// Version counts the file's analyses; reprocessing a file keeps earlier versions
+	Version int `json:"version,omitempty"`
+	// Options are the parse overrides the file was reprocessed with, if any
+	Options *ParseOptions `json:"options,omitempty"`
+	// SchemaDrift is how the file's columns changed since the previous upload from its source
+	SchemaDrift *SchemaDrift `json:"schemaDrift,omitempty"`
+	// Warnings point out problems that didn't stop processing, such as columns gone missing
+	Warnings []string `json:"warnings,omitempty"`
+	// Incomplete is set on the partial results of a file whose parsing failed part-way. They
+	// cover the records before the failure, and processing the file again resumes from there.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Parsers that ParseOptions can name
+const (
+	ParserCSV     = "csv"
+	ParserOpenRTB = "openrtb"
+	ParserPrebid  = "prebid"
+)
+
+// Analysis errors
+var (
+	// ErrAnalysisNotFound is returned when a file has no analysis, or not the requested version
+	ErrAnalysisNotFound = errors.New("analysis result not found")
+	// ErrUnknownParser is returned when ParseOptions names a parser that doesn't exist
+	ErrUnknownParser = errors.New("unknown parser")
+)
+
+// ParseOptions override how a file is parsed, for files whose format was mis-detected
+type ParseOptions struct {
+	// Parser names the parser to use instead of choosing one from the file's extension and content
+	Parser string `json:"parser,omitempty"`
+	// Format names the registered log format a CSV log's columns are mapped with, instead of
+	// detecting it from the header
+	Format string `json:"format,omitempty"`
+	// Timezone is the IANA zone CSV log timestamps without an offset are read in, instead of UTC
+	Timezone string `json:"timezone,omitempty"`
+	// BreakdownLimit is how many top domains and hours the summary keeps, instead of the
+	// service's breakdown limit
+	BreakdownLimit int `json:"breakdownLimit,omitempty"`
+}
+
+// Validate checks that the options name a known parser, log format and time zone
+func (o ParseOptions) Validate() error {
+	switch o.Parser {
+	case "", ParserCSV, ParserOpenRTB, ParserPrebid:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownParser, o.Parser)
+	}
+
+	if o.Format != "" {
+		if o.Parser != "" && o.Parser != ParserCSV {
+			return fmt.Errorf("%w: log formats apply only to the %s parser", ErrUnknownLogFormat, ParserCSV)
+		}
+		if _, ok := findLogFormat(o.Format); !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownLogFormat, o.Format)
+		}
+	}
+
+	if _, err := o.location(); err != nil {
+		return err
+	}
+	if o.BreakdownLimit < 0 {
+		return errors.New("breakdown limit must be 0 or more")
+	}
+
+	return nil
+}
+
+// location returns the time zone named by the options, or nil when they name none
+func (o ParseOptions) location() (*time.Location, error) {
+	if o.Timezone == "" {
+		return nil, nil
+	}
+	// "Local" is the server's zone, which says nothing about the log
+	location, err := time.LoadLocation(o.Timezone)
+	if err != nil || o.Timezone == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", o.Timezone)
+	}
+	return location, nil
+}
+
+// AnalysisVersion describes one of a file's analyses
+type AnalysisVersion struct {
+	Version     int           `json:"version"`
+	ProcessedAt time.Time     `json:"processedAt"`
+	Status      string        `json:"status"`
+	Options     *ParseOptions `json:"options,omitempty"`
+	Current     bool          `json:"current"`
+}
+
+// NarrativeGenerator writes a short insights paragraph for an analysis result
+type NarrativeGenerator interface {
+	Generate(ctx context.Context, result *LogAnalysisResult) (string, error)
+}
+
+// RecordSink persists the individual records of a processed file
+type RecordSink interface {
+	// DeleteRecords removes the records previously stored for a file
+	DeleteRecords(ctx context.Context, fileID, userID string) error
+	// WriteRecords stores a batch of a file's records
+	WriteRecords(ctx context.Context, fileID, userID string, records []BeeswaxLogRecord) error
+}
+
+// CategoryOverrideSource provides a user's content category overrides by domain
+type CategoryOverrideSource interface {
+	ListOverrides(ctx context.Context, userID string) (map[string]string, error)
+}
+
+// UsageSink meters the rows users ingest against their plan
+type UsageSink interface {
+	RecordRowsIngested(ctx context.Context, userID string, rows int64)
+}
+
+// recordBatchSize is the number of records buffered before they are written to the sink
+const recordBatchSize = 5000
+
+// checkpointInterval is the number of records parsed between checks for cancellation and progress reports
+const checkpointInterval = 1000
+
+// BeeswaxSummary returns the result's summary as a BeeswaxLogSummary.
+// Results loaded from disk hold a generic JSON map, so the summary is re-decoded when needed.
+func (r *LogAnalysisResult) BeeswaxSummary() (*BeeswaxLogSummary, error) {
+	if summary, ok := r.Summary.(*BeeswaxLogSummary); ok {
+		return summary, nil
+	}
+
+	data, err := json.Marshal(r.Summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize summary: %w", err)
+	}
+
+	var summary BeeswaxLogSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode summary: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// WithoutSketches returns a copy of the result whose summary leaves out the daily reach sketches,
+// for sending to clients. The stored result keeps them to merge reach across files, but they
+// mean nothing to a client and are most of a large summary's size.
+func (r *LogAnalysisResult) WithoutSketches() *LogAnalysisResult {
+	stripped := *r
+	switch summary := r.Summary.(type) {
+	case *BeeswaxLogSummary:
+		copied := *summary
+		copied.ReachFrequency = make(map[string]*ReachFrequencyMetrics, len(summary.ReachFrequency))
+		for campaignID, metrics := range summary.ReachFrequency {
+			withoutSketches := *metrics
+			withoutSketches.DailySketches = nil
+			copied.ReachFrequency[campaignID] = &withoutSketches
+		}
+		stripped.Summary = &copied
+	case map[string]interface{}:
+		// Results loaded from disk hold the summary as a generic JSON map
+		reach, ok := summary["reachFrequency"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		copied := make(map[string]interface{}, len(summary))
+		for key, value := range summary {
+			copied[key] = value
+		}
+		copiedReach := make(map[string]interface{}, len(reach))
+		for campaignID, value := range reach {
+			metrics, ok := value.(map[string]interface{})
+			if !ok {
+				copiedReach[campaignID] = value
+				continue
+			}
+			withoutSketches := make(map[string]interface{}, len(metrics))
+			for key, value := range metrics {
+				if key != "dailySketches" {
+					withoutSketches[key] = value
+				}
+			}
+			copiedReach[campaignID] = withoutSketches
+		}
+		copied["reachFrequency"] = copiedReach
+		stripped.Summary = copied
+	}
+	return &stripped
 }
 
 // LogProcessorService handles the processing and analysis of DSP log files
 type LogProcessorService struct {
-	basePath string
+	basePath       string
+	narrative      NarrativeGenerator
+	records        RecordSink
+	rollups        RollupSink
+	categories     CategoryOverrideSource
+	profiles       MappingProfileSource
+	schemas        SchemaHistory
+	parseWorkers   int
+	breakdownLimit int
+	parserRuns     ParserRunSink
+	parserMetrics  *ParserMetrics
+	usage          UsageSink
 }
 
 // NewLogProcessorService creates a new log processor service
@@ -32,12 +236,98 @@ func NewLogProcessorService(basePath string) *LogProcessorService {
 	}
 
 	return &LogProcessorService{
-		basePath: basePath,
+		basePath:      basePath,
+		parserMetrics: NewParserMetrics(),
+	}
+}
+
+// SetNarrativeGenerator enables narrative generation for processed files
+func (s *LogProcessorService) SetNarrativeGenerator(generator NarrativeGenerator) {
+	s.narrative = generator
+}
+
+// SetParseWorkers sets how many goroutines parse CSV rows; 0 uses one per CPU
+func (s *LogProcessorService) SetParseWorkers(workers int) {
+	s.parseWorkers = workers
+}
+
+// SetCategoryOverrides applies users' content category overrides on top of the bundled
+// domain mapping when files are processed
+func (s *LogProcessorService) SetCategoryOverrides(source CategoryOverrideSource) {
+	s.categories = source
+}
+
+// SetRecordSink enables persisting the individual records of processed files
+func (s *LogProcessorService) SetRecordSink(sink RecordSink) {
+	s.records = sink
+}
+
+// SetRollupSink enables maintaining hourly and daily rollups of processed files
+func (s *LogProcessorService) SetRollupSink(sink RollupSink) {
+	s.rollups = sink
+}
+
+// SetParserRunSink enables persisting the outcome of each file's parse for parser health tracking
+func (s *LogProcessorService) SetParserRunSink(sink ParserRunSink) {
+	s.parserRuns = sink
+}
+
+// SetUsageSink enables metering the rows of each file parsed
+func (s *LogProcessorService) SetUsageSink(sink UsageSink) {
+	s.usage = sink
+}
+
+// ReportsDir returns the directory analyses and their history are saved under
+func (s *LogProcessorService) ReportsDir() string {
+	return filepath.Join(s.basePath, "reports")
+}
+
+// ParserMetrics returns the counters of the files parsed since the process started
+func (s *LogProcessorService) ParserMetrics() *ParserMetrics {
+	return s.parserMetrics
+}
+
+// ProcessLogFile processes a DSP log file and returns analysis results. The options override the
+// parser and log format otherwise detected; a file with an earlier analysis gets a new version.
+func (s *LogProcessorService) ProcessLogFile(ctx context.Context, filePath, fileID, fileName, userID string, opts ParseOptions) (*LogAnalysisResult, error) {
+	run := &ParserRun{FileID: fileID}
+	start := time.Now()
+	result, err := s.processLogFile(ctx, filePath, fileID, fileName, userID, opts, run)
+	run.Duration = time.Since(start)
+	s.recordParserRun(ctx, run, result, err)
+	if err == nil && s.usage != nil {
+		s.usage.RecordRowsIngested(ctx, userID, run.Rows)
+	}
+
+	return result, err
+}
+
+// recordParserRun counts and stores the outcome of a parse. Canceled parses, and files no
+// parser was chosen for, say nothing about a parser's health and are left out.
+func (s *LogProcessorService) recordParserRun(ctx context.Context, run *ParserRun, result *LogAnalysisResult, err error) {
+	if run.Parser == "" || ctx.Err() != nil {
+		return
+	}
+
+	run.Failed = err != nil
+	run.FinishedAt = time.Now()
+	// Failed CSV parses keep the summary of the rows before the failure
+	if summary, ok := result.Summary.(*BeeswaxLogSummary); ok && summary != nil {
+		run.Source = summary.Source
+		run.Rows = int64(summary.TotalRecords)
+		run.RowErrors = int64(summary.UndatedRecords)
+	}
+
+	s.parserMetrics.Record(run)
+	if s.parserRuns != nil {
+		if err := s.parserRuns.SaveParserRun(ctx, run); err != nil {
+			errreport.Report(errreport.WithTags(ctx, "fileID", run.FileID), "Failed to record parser run", err)
+		}
 	}
 }
 
-// ProcessLogFile processes a DSP log file and returns analysis results
-func (s *LogProcessorService) ProcessLogFile(ctx context.Context, filePath, fileID, fileName, userID string) (*LogAnalysisResult, error) {
+// processLogFile processes a log file, noting the parser and file size in run
+func (s *LogProcessorService) processLogFile(ctx context.Context, filePath, fileID, fileName, userID string, opts ParseOptions, run *ParserRun) (*LogAnalysisResult, error) {
 	// Create result structure
 	result := &LogAnalysisResult{
 		FileID:      fileID,
@@ -46,6 +336,9 @@ func (s *LogProcessorService) ProcessLogFile(ctx context.Context, filePath, file
 		ProcessedAt: time.Now(),
 		Status:      "processing",
 	}
+	if opts != (ParseOptions{}) {
+		result.Options = &opts
+	}
 
 	// Open the file
 	file, err := os.Open(filePath)
@@ -56,49 +349,334 @@ func (s *LogProcessorService) ProcessLogFile(ctx context.Context, filePath, file
 	}
 	defer file.Close()
 
-	// Determine the type of log file based on extension
-	ext := filepath.Ext(fileName)
-	if ext != ".csv" {
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	run.Bytes = size
+
+	// Determine the type of log file based on extension, unless a parser was named: CSV exports,
+	// or JSON OpenRTB bid logs and Prebid Server analytics output
+	ext := strings.ToLower(filepath.Ext(fileName))
+	parser := opts.Parser
+	if parser == "" && ext == ".csv" {
+		parser = ParserCSV
+	}
+
+	// A CSV log whose last parse failed part-way resumes from where it stopped
+	var resume *parseCheckpoint
+	if parser == ParserCSV {
+		resume, err = s.loadCheckpoint(fileID, userID, opts, size)
+		if err != nil {
+			// The file is parsed from the start instead
+			errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to load parse checkpoint", err)
+		}
+	}
+
+	var parse logParser
+	switch {
+	case parser == ParserCSV:
+		var header []string
+		if resume != nil {
+			header = resume.Header
+		}
+		parse = func(reader io.Reader, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+			return parseCSVLog(reader, s.parseWorkers, header, func(header []string) (*logLayout, error) {
+				return s.resolveFileLayout(ctx, header, opts, result)
+			}, onRecord)
+		}
+	case opts.Format != "":
 		result.Status = "error"
-		result.ErrorMessage = "Unsupported file format. Only CSV files are supported."
+		result.ErrorMessage = "Log formats apply only to CSV logs."
+		return result, fmt.Errorf("%w: %s is not a CSV log", ErrUnknownLogFormat, fileName)
+	case parser == ParserOpenRTB:
+		parse = ParseOpenRTBLog
+	case parser == ParserPrebid:
+		parse = func(reader io.Reader, _ func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+			return ParsePrebidLog(reader)
+		}
+	case ext == ".json" || ext == ".jsonl" || ext == ".ndjson":
+		parse = ParseJSONLog
+	default:
+		result.Status = "error"
+		result.ErrorMessage = "Unsupported file format. Only CSV exports, JSON OpenRTB bid logs and Prebid Server analytics logs are supported."
 		return result, fmt.Errorf("unsupported file format: %s", ext)
 	}
 
+	run.Parser = parser
+	if run.Parser == "" {
+		run.Parser = ParserJSON
+	}
+
 	// Process the file based on its content
 	var summary interface{}
 
-	// Attempt to parse as Beeswax log
-	beeswaxSummary, err := ParseBeeswaxLog(file)
+	counter := &countingReader{reader: file}
+	var resumedRows, resumedBytes int64
+	if resume != nil {
+		if _, err := file.Seek(resume.Offset, io.SeekStart); err != nil {
+			result.Status = "error"
+			result.ErrorMessage = fmt.Sprintf("Failed to resume parsing: %v", err)
+			return result, fmt.Errorf("failed to seek to checkpoint: %w", err)
+		}
+		counter.n.Store(resume.Offset)
+		resumedRows, resumedBytes = resume.Rows, resume.Offset
+	}
+
+	// Report progress as the share of the file's bytes read, when the caller asked for it
+	var onProgress func(rows int64)
+	if report := progressFromContext(ctx); report != nil {
+		onProgress = func(rows int64) {
+			report(Progress{Rows: resumedRows + rows, BytesRead: counter.n.Load(), TotalBytes: size, ResumedBytes: resumedBytes})
+		}
+	}
+
+	// Parse the log, persisting records and rolling them up when sinks are configured
+	var rollups *RollupBuilder
+	if s.rollups != nil {
+		rollups = NewRollupBuilder()
+		if resume != nil {
+			rollups.addRollups(resume.Rollups)
+		}
+	}
+	beeswaxSummary, err := s.parseAndStoreRecords(ctx, parse, &contextReader{ctx: ctx, reader: counter}, fileID, userID, rollups, onProgress, resume)
 	if err != nil {
 		result.Status = "error"
 		result.ErrorMessage = fmt.Sprintf("Failed to parse file: %v", err)
+
+		// Keep what was parsed before the failure so processing the file again resumes
+		var partial *partialParseError
+		if errors.As(err, &partial) {
+			s.keepPartialResults(ctx, result, opts, size, partial, resume, rollups)
+		}
 		return result, fmt.Errorf("failed to parse file: %w", err)
 	}
+	if resume != nil {
+		beeswaxSummary = MergeBeeswaxSummaries(resume.Summary, beeswaxSummary)
+	}
+	// A run canceled after the last record must not store its results
+	if err := ctx.Err(); err != nil {
+		result.Status = "error"
+		result.ErrorMessage = "Processing was canceled"
+		return result, err
+	}
+
+	s.categorize(ctx, beeswaxSummary, fileID, userID)
+	breakdownLimit := s.breakdownLimit
+	if opts.BreakdownLimit > 0 {
+		breakdownLimit = opts.BreakdownLimit
+	}
+	beeswaxSummary.LimitBreakdowns(breakdownLimit)
+
+	// Store the rollups; a file without them is read from its summary instead, so failures don't fail processing
+	if rollups != nil {
+		if err := s.rollups.ReplaceRollups(ctx, fileID, userID, rollups.Rollups()); err != nil {
+			errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to store rollups", err)
+		}
+	}
 
 	summary = beeswaxSummary
 	result.Status = "completed"
 	result.Summary = summary
 
+	// Benchmark campaigns against previously processed files; this is context, so failures don't fail processing
+	benchmarks, err := s.BenchmarkAgainstHistory(ctx, beeswaxSummary, fileID, userID)
+	if err != nil {
+		errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to benchmark file", err)
+	} else {
+		result.Benchmarks = benchmarks
+	}
+
+	// Generate the narrative when enabled; like benchmarks, it is optional context
+	if s.narrative != nil {
+		narrative, err := s.narrative.Generate(ctx, result)
+		if err != nil {
+			errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to generate narrative", err)
+		} else {
+			result.Narrative = narrative
+		}
+	}
+
 	// Store the analysis results
 	if err := s.storeAnalysisResult(result, userID, fileID); err != nil {
 		return result, fmt.Errorf("failed to store analysis result: %w", err)
 	}
 
+	// The file is parsed in full, so no later run resumes from a checkpoint
+	if err := s.deleteCheckpoint(fileID, userID); err != nil {
+		errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to remove parse checkpoint", err)
+	}
+
 	return result, nil
 }
 
+// categorize categorizes a summary's domains by content vertical; without the user's overrides
+// the bundled mapping still applies
+func (s *LogProcessorService) categorize(ctx context.Context, summary *BeeswaxLogSummary, fileID, userID string) {
+	var overrides map[string]string
+	if s.categories != nil {
+		var err error
+		overrides, err = s.categories.ListOverrides(ctx, userID)
+		if err != nil {
+			errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to load category overrides", err)
+		}
+	}
+	summary.categorize(overrides)
+}
+
+// keepPartialResults checkpoints a CSV parse that failed part-way, so parsing the file again
+// resumes where it stopped. Unless the parse was canceled, the results of the records before
+// the failure are stored too, flagged as incomplete.
+func (s *LogProcessorService) keepPartialResults(ctx context.Context, result *LogAnalysisResult, opts ParseOptions, fileSize int64, partial *partialParseError, resume *parseCheckpoint, rollups *RollupBuilder) {
+	checkpoint := &parseCheckpoint{
+		Options:   opts,
+		FileSize:  fileSize,
+		Header:    partial.header,
+		Offset:    partial.offset,
+		Rows:      partial.rows,
+		Summary:   partial.summary,
+		Pending:   partial.pending,
+		CreatedAt: time.Now(),
+	}
+	// The parse of a resumed run started at the earlier run's checkpoint
+	if resume != nil {
+		checkpoint.Offset += resume.Offset
+		checkpoint.Rows += resume.Rows
+		checkpoint.Summary = MergeBeeswaxSummaries(resume.Summary, partial.summary)
+	}
+	if rollups != nil {
+		checkpoint.Rollups = rollups.Rollups()
+	}
+
+	tagged := errreport.WithTags(ctx, "fileID", result.FileID)
+	if err := s.saveCheckpoint(result.FileID, result.UserID, checkpoint); err != nil {
+		errreport.Report(tagged, "Failed to save parse checkpoint", err)
+	}
+
+	// A canceled run is requeued or was stopped on purpose, so it leaves the results alone
+	if ctx.Err() != nil {
+		return
+	}
+
+	s.categorize(ctx, checkpoint.Summary, result.FileID, result.UserID)
+	result.Status = "partial"
+	result.ErrorMessage = fmt.Sprintf("Failed to parse file after %d rows: %v", checkpoint.Rows, partial.err)
+	result.Summary = checkpoint.Summary
+	result.Incomplete = true
+	if err := s.storeAnalysisResult(result, result.UserID, result.FileID); err != nil {
+		errreport.Report(tagged, "Failed to store partial analysis result", err)
+	}
+}
+
+// logParser parses a log into a summary, passing each record to onRecord when it is set
+type logParser func(reader io.Reader, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error)
+
+// parseAndStoreRecords parses a log, writing its records to the record sink in batches and
+// adding them to rollups when set. Records from an earlier run of the same file are replaced,
+// unless the parse resumes one that failed part-way. Parsing stops with the context's error
+// once it is canceled, and onProgress, when set, is called with the number of records parsed
+// as it goes.
+func (s *LogProcessorService) parseAndStoreRecords(ctx context.Context, parse logParser, reader io.Reader, fileID, userID string, rollups *RollupBuilder, onProgress func(rows int64), resume *parseCheckpoint) (*BeeswaxLogSummary, error) {
+	// Reads already stop on cancellation, but a buffered chunk can hold many records
+	var parsed int64
+	checkpoint := func() error {
+		if parsed++; parsed%checkpointInterval != 0 {
+			return nil
+		}
+		if onProgress != nil {
+			onProgress(parsed)
+		}
+		return ctx.Err()
+	}
+
+	if s.records == nil && rollups == nil {
+		return parse(reader, func(*BeeswaxLogRecord) error {
+			return checkpoint()
+		})
+	}
+
+	if s.records != nil {
+		if resume == nil {
+			if err := s.records.DeleteRecords(ctx, fileID, userID); err != nil {
+				return nil, fmt.Errorf("failed to clear stored records: %w", err)
+			}
+		} else if len(resume.Pending) > 0 {
+			// The records the failed run parsed but didn't store come first
+			if err := s.records.WriteRecords(ctx, fileID, userID, resume.Pending); err != nil {
+				return nil, fmt.Errorf("failed to store records: %w", err)
+			}
+		}
+	}
+
+	// A record is added to the rollups and the batch before anything can fail, so the
+	// checkpoint of a failed parse covers every record it aggregated
+	batch := make([]BeeswaxLogRecord, 0, recordBatchSize)
+	summary, err := parse(reader, func(record *BeeswaxLogRecord) error {
+		if rollups != nil {
+			rollups.Add(record)
+		}
+		if s.records == nil {
+			return checkpoint()
+		}
+
+		batch = append(batch, *record)
+		if len(batch) == recordBatchSize {
+			if err := s.records.WriteRecords(ctx, fileID, userID, batch); err != nil {
+				return fmt.Errorf("failed to store records: %w", err)
+			}
+			batch = batch[:0]
+		}
+		return checkpoint()
+	})
+	if err != nil {
+		// The records of the failed batch are stored when the parse resumes
+		var partial *partialParseError
+		if errors.As(err, &partial) {
+			partial.pending = batch
+		}
+		return nil, err
+	}
+
+	if len(batch) > 0 {
+		if err := s.records.WriteRecords(ctx, fileID, userID, batch); err != nil {
+			return nil, fmt.Errorf("failed to store records: %w", err)
+		}
+	}
+
+	return summary, nil
+}
+
+// contextReader stops reading once its context is canceled, so parsing a large file can be interrupted
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
 // GetAnalysisResult retrieves a previously processed analysis result
 func (s *LogProcessorService) GetAnalysisResult(ctx context.Context, fileID, userID string) (*LogAnalysisResult, error) {
-	// Get the path to the results file
-	resultsPath := filepath.Join(s.basePath, "reports", userID, fmt.Sprintf("%s_analysis.json", fileID))
+	return readAnalysisResult(s.analysisPath(fileID, userID))
+}
 
-	// Check if the file exists
-	if _, err := os.Stat(resultsPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("analysis result not found for file ID: %s", fileID)
-	}
+// SaveAnalysisResult stores an analysis produced elsewhere, such as one imported from a bundle,
+// as its file's current analysis
+func (s *LogProcessorService) SaveAnalysisResult(result *LogAnalysisResult) error {
+	return s.storeAnalysisResult(result, result.UserID, result.FileID)
+}
 
-	// Read the results file
-	data, err := os.ReadFile(resultsPath)
+// readAnalysisResult reads a stored analysis result
+func readAnalysisResult(path string) (*LogAnalysisResult, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrAnalysisNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read analysis result: %w", err)
 	}
@@ -112,40 +690,182 @@ func (s *LogProcessorService) GetAnalysisResult(ctx context.Context, fileID, use
 	return &result, nil
 }
 
-// storeAnalysisResult saves the analysis result to disk
+// ListAnalysisResults retrieves all stored analysis results for a user
+func (s *LogProcessorService) ListAnalysisResults(ctx context.Context, userID string) ([]*LogAnalysisResult, error) {
+	resultsDir := filepath.Join(s.basePath, "reports", userID)
+
+	entries, err := os.ReadDir(resultsDir)
+	if os.IsNotExist(err) {
+		return []*LogAnalysisResult{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read results directory: %w", err)
+	}
+
+	results := make([]*LogAnalysisResult, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "_analysis.json") {
+			continue
+		}
+
+		fileID := strings.TrimSuffix(entry.Name(), "_analysis.json")
+		result, err := s.GetAnalysisResult(ctx, fileID, userID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// BenchmarkAgainstHistory benchmarks a summary's campaigns against the user's other processed files
+func (s *LogProcessorService) BenchmarkAgainstHistory(ctx context.Context, summary *BeeswaxLogSummary, fileID, userID string) ([]CampaignBenchmark, error) {
+	results, err := s.ListAnalysisResults(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exclude the file itself so a campaign isn't compared against its own numbers
+	history := make([]*LogAnalysisResult, 0, len(results))
+	for _, result := range results {
+		if result.FileID != fileID {
+			history = append(history, result)
+		}
+	}
+
+	benchmarkHistory, err := BuildBenchmarkHistory(history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build benchmark history: %w", err)
+	}
+
+	return BenchmarkCampaigns(benchmarkHistory, summary), nil
+}
+
+// storeAnalysisResult saves the analysis result to disk. An earlier analysis of the file is moved
+// to the file's history and the new result becomes the next version, unless the earlier one is
+// incomplete.
 func (s *LogProcessorService) storeAnalysisResult(result *LogAnalysisResult, userID, fileID string) error {
 	// Create the results directory if it doesn't exist
 	resultsDir := filepath.Join(s.basePath, "reports", userID)
-	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Join(resultsDir, "history"), 0755); err != nil {
 		return fmt.Errorf("failed to create results directory: %w", err)
 	}
 
+	resultsPath := s.analysisPath(fileID, userID)
+	previous, err := s.GetAnalysisResult(context.Background(), fileID, userID)
+	if err != nil && !errors.Is(err, ErrAnalysisNotFound) {
+		return err
+	}
+	// Partial results are replaced by the run that resumes them rather than kept as a version
+	result.Version = 1
+	keepPrevious := previous != nil && !previous.Incomplete
+	if previous != nil {
+		result.Version = previous.version()
+		if keepPrevious {
+			result.Version++
+		}
+	}
+
 	// Serialize the result to JSON
 	data, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to serialize analysis result: %w", err)
 	}
 
-	// Write the result to disk
-	resultsPath := filepath.Join(resultsDir, fmt.Sprintf("%s_analysis.json", fileID))
-	if err := os.WriteFile(resultsPath, data, 0644); err != nil {
+	// Write to a temporary file and rename it, so a crash mid-write never leaves a truncated result
+	tempPath := resultsPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write analysis result: %w", err)
+	}
+	if keepPrevious {
+		if err := os.Rename(resultsPath, s.analysisVersionPath(fileID, userID, previous.version())); err != nil {
+			os.Remove(tempPath)
+			return fmt.Errorf("failed to keep previous analysis: %w", err)
+		}
+	}
+	if err := os.Rename(tempPath, resultsPath); err != nil {
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to write analysis result: %w", err)
 	}
 
 	return nil
 }
 
-// IsLogFileProcessed checks if a log file has been processed
-func (s *LogProcessorService) IsLogFileProcessed(ctx context.Context, fileID, userID string) (bool, error) {
-	// Get the path to the results file
-	resultsPath := filepath.Join(s.basePath, "reports", userID, fmt.Sprintf("%s_analysis.json", fileID))
+// version returns the result's version; results from before versioning are the first
+func (r *LogAnalysisResult) version() int {
+	return max(r.Version, 1)
+}
+
+// analysisPath returns the path of a file's current analysis
+func (s *LogProcessorService) analysisPath(fileID, userID string) string {
+	return filepath.Join(s.basePath, "reports", userID, fmt.Sprintf("%s_analysis.json", fileID))
+}
+
+// analysisVersionPath returns the path of an earlier version of a file's analysis
+func (s *LogProcessorService) analysisVersionPath(fileID, userID string, version int) string {
+	return filepath.Join(s.basePath, "reports", userID, "history", fmt.Sprintf("%s_v%d.json", fileID, version))
+}
 
-	// Check if the file exists
-	if _, err := os.Stat(resultsPath); os.IsNotExist(err) {
+// ListAnalysisVersions lists a file's analyses, newest first
+func (s *LogProcessorService) ListAnalysisVersions(ctx context.Context, fileID, userID string) ([]AnalysisVersion, error) {
+	current, err := s.GetAnalysisResult(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []AnalysisVersion{{
+		Version:     current.version(),
+		ProcessedAt: current.ProcessedAt,
+		Status:      current.Status,
+		Options:     current.Options,
+		Current:     true,
+	}}
+	for version := current.version() - 1; version >= 1; version-- {
+		result, err := s.GetAnalysisVersion(ctx, fileID, userID, version)
+		if errors.Is(err, ErrAnalysisNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, AnalysisVersion{
+			Version:     version,
+			ProcessedAt: result.ProcessedAt,
+			Status:      result.Status,
+			Options:     result.Options,
+		})
+	}
+
+	return versions, nil
+}
+
+// GetAnalysisVersion retrieves one version of a file's analysis, current or earlier
+func (s *LogProcessorService) GetAnalysisVersion(ctx context.Context, fileID, userID string, version int) (*LogAnalysisResult, error) {
+	current, err := s.GetAnalysisResult(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if version == current.version() {
+		return current, nil
+	}
+
+	result, err := readAnalysisResult(s.analysisVersionPath(fileID, userID, version))
+	if err != nil {
+		return nil, err
+	}
+	result.Version = version
+	return result, nil
+}
+
+// IsLogFileProcessed checks if a log file has been processed. A file with only incomplete
+// results hasn't been; processing it again resumes where it stopped.
+func (s *LogProcessorService) IsLogFileProcessed(ctx context.Context, fileID, userID string) (bool, error) {
+	result, err := s.GetAnalysisResult(ctx, fileID, userID)
+	if errors.Is(err, ErrAnalysisNotFound) {
 		return false, nil
 	} else if err != nil {
 		return false, err
 	}
 
-	return true, nil
+	return !result.Incomplete, nil
 }
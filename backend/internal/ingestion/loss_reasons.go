@@ -0,0 +1,200 @@
+package ingestion
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Loss reason categories, what bids lost for whatever codes or words the log gives
+const (
+	LossOutbid           = "outbid"
+	LossBelowFloor       = "below_floor"
+	LossCreativeRejected = "creative_rejected"
+	LossTimeout          = "timeout"
+	LossOther            = "other"
+)
+
+// LossReasonMetrics counts the bids of a campaign or exchange and why those that lost did
+type LossReasonMetrics struct {
+	Bids int `json:"bids"`
+	// Losses counts lost bids the log gives a reason for, and Reasons counts them by category
+	Losses  int            `json:"losses"`
+	Reasons map[string]int `json:"reasons"`
+	// LossRate is the share of bids lost with a reason, in percent, and TopReason the category
+	// most of them lost for
+	LossRate  float64 `json:"lossRate"`
+	TopReason string  `json:"topReason,omitempty"`
+}
+
+// LossReasonSummary contains why bids lost, by campaign and by exchange, present when logs
+// include a loss reason column or OpenRTB loss notices
+type LossReasonSummary struct {
+	LossReasonMetrics
+	Campaigns map[string]*LossReasonMetrics `json:"campaigns"`
+	Exchanges map[string]*LossReasonMetrics `json:"exchanges"`
+	// NoBids counts requests answered without a bid by exchange and then OpenRTB no-bid reason
+	// code, for OpenRTB logs
+	NoBids map[string]map[string]int `json:"noBids,omitempty"`
+}
+
+func newLossReasonSummary() *LossReasonSummary {
+	return &LossReasonSummary{
+		LossReasonMetrics: LossReasonMetrics{Reasons: make(map[string]int)},
+		Campaigns:         make(map[string]*LossReasonMetrics),
+		Exchanges:         make(map[string]*LossReasonMetrics),
+	}
+}
+
+// LossReasonCategory groups a loss reason, an OpenRTB loss reason code (list 5.25) or an
+// exchange's description, into a loss category. It returns an empty string for a reason that
+// says the bid won, or for no reason at all.
+func LossReasonCategory(reason string) string {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	if reason == "" {
+		return ""
+	}
+
+	if code, err := strconv.Atoi(reason); err == nil {
+		switch {
+		case code == 0:
+			return ""
+		case code == 2:
+			return LossTimeout
+		case code == 100 || code == 101:
+			return LossBelowFloor
+		case code == 102 || code == 103:
+			return LossOutbid
+		case code >= 200 && code < 300:
+			return LossCreativeRejected
+		}
+		return LossOther
+	}
+
+	switch {
+	case reason == "won" || reason == "win":
+		return ""
+	case strings.Contains(reason, "floor"):
+		return LossBelowFloor
+	case strings.Contains(reason, "outbid") || strings.Contains(reason, "higher bid") || strings.Contains(reason, "lost to"):
+		return LossOutbid
+	case strings.Contains(reason, "creative") || strings.Contains(reason, "disapprov") || strings.Contains(reason, "reject"):
+		return LossCreativeRejected
+	case strings.Contains(reason, "timeout") || strings.Contains(reason, "time out") || strings.Contains(reason, "timed out") || strings.Contains(reason, "expired"):
+		return LossTimeout
+	}
+	return LossOther
+}
+
+// add counts a record's bid against its campaign and exchange, with its loss reason when it lost
+func (s *LossReasonSummary) add(record *BeeswaxLogRecord) {
+	category := ""
+	if !record.Won() {
+		category = LossReasonCategory(record.LossReason)
+	}
+
+	exchange := record.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	s.LossReasonMetrics.add(category)
+	lossReasonMetrics(s.Exchanges, exchange).add(category)
+	if record.CampaignID != "" {
+		lossReasonMetrics(s.Campaigns, record.CampaignID).add(category)
+	}
+}
+
+// addNoBid counts a request an exchange sent that was answered without a bid, with the
+// response's no-bid reason code when it gave one
+func (s *LossReasonSummary) addNoBid(exchange string, reason *int) {
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	code := unknownOutcomeReason
+	if reason != nil {
+		code = strconv.Itoa(*reason)
+	}
+	if s.NoBids == nil {
+		s.NoBids = make(map[string]map[string]int)
+	}
+	if s.NoBids[exchange] == nil {
+		s.NoBids[exchange] = make(map[string]int)
+	}
+	s.NoBids[exchange][code]++
+}
+
+// calculateRates computes loss rates and top reasons
+func (s *LossReasonSummary) calculateRates() {
+	s.LossReasonMetrics.calculateRates()
+	for _, metrics := range s.Campaigns {
+		metrics.calculateRates()
+	}
+	for _, metrics := range s.Exchanges {
+		metrics.calculateRates()
+	}
+}
+
+// merge accumulates another loss reason summary
+func (s *LossReasonSummary) merge(other *LossReasonSummary) {
+	s.LossReasonMetrics.merge(&other.LossReasonMetrics)
+	for campaignID, metrics := range other.Campaigns {
+		lossReasonMetrics(s.Campaigns, campaignID).merge(metrics)
+	}
+	for exchange, metrics := range other.Exchanges {
+		lossReasonMetrics(s.Exchanges, exchange).merge(metrics)
+	}
+	for exchange, reasons := range other.NoBids {
+		if s.NoBids == nil {
+			s.NoBids = make(map[string]map[string]int)
+		}
+		if s.NoBids[exchange] == nil {
+			s.NoBids[exchange] = make(map[string]int)
+		}
+		mergeCounts(s.NoBids[exchange], reasons)
+	}
+}
+
+// add counts a bid, as a loss in category when it has one
+func (m *LossReasonMetrics) add(category string) {
+	m.Bids++
+	if category == "" {
+		return
+	}
+	m.Losses++
+	m.Reasons[category]++
+}
+
+// merge accumulates another slice's bids and losses
+func (m *LossReasonMetrics) merge(other *LossReasonMetrics) {
+	m.Bids += other.Bids
+	m.Losses += other.Losses
+	mergeCounts(m.Reasons, other.Reasons)
+}
+
+// calculateRates computes the loss rate and the most frequent reason, ties going to the first
+// category in name order
+func (m *LossReasonMetrics) calculateRates() {
+	m.LossRate = rate(m.Losses, m.Bids)
+
+	categories := make([]string, 0, len(m.Reasons))
+	for category := range m.Reasons {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	m.TopReason = ""
+	for _, category := range categories {
+		if m.TopReason == "" || m.Reasons[category] > m.Reasons[m.TopReason] {
+			m.TopReason = category
+		}
+	}
+}
+
+// lossReasonMetrics returns the metrics for a key, creating them if needed
+func lossReasonMetrics(metrics map[string]*LossReasonMetrics, key string) *LossReasonMetrics {
+	entry, ok := metrics[key]
+	if !ok {
+		entry = &LossReasonMetrics{Reasons: make(map[string]int)}
+		metrics[key] = entry
+	}
+	return entry
+}
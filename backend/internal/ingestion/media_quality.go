@@ -0,0 +1,83 @@
+package ingestion
+
+// ViewabilityMetrics contains viewability counts and rates for impressions
+type ViewabilityMetrics struct {
+	MeasurableImpressions int     `json:"measurableImpressions"`
+	ViewableImpressions   int     `json:"viewableImpressions"`
+	MeasuredRate          float64 `json:"measuredRate"`
+	ViewabilityRate       float64 `json:"viewabilityRate"`
+}
+
+// VideoMetrics contains VAST quartile event counts and completion rates for video impressions
+type VideoMetrics struct {
+	Starts         int     `json:"starts"`
+	FirstQuartiles int     `json:"firstQuartiles"`
+	Midpoints      int     `json:"midpoints"`
+	ThirdQuartiles int     `json:"thirdQuartiles"`
+	Completes      int     `json:"completes"`
+	StartRate      float64 `json:"startRate"`
+	MidpointRate   float64 `json:"midpointRate"`
+	VCR            float64 `json:"vcr"`
+}
+
+// add accumulates a record's viewability flags
+func (v *ViewabilityMetrics) add(record *BeeswaxLogRecord) {
+	if record.Measurable {
+		v.MeasurableImpressions++
+	}
+	if record.Viewable {
+		v.ViewableImpressions++
+	}
+}
+
+// calculateRates computes the measured rate against impressions and the viewability rate
+// against measurable impressions, following the MRC definition
+func (v *ViewabilityMetrics) calculateRates(impressions int) {
+	if impressions > 0 {
+		v.MeasuredRate = float64(v.MeasurableImpressions) / float64(impressions) * 100
+	}
+	if v.MeasurableImpressions > 0 {
+		v.ViewabilityRate = float64(v.ViewableImpressions) / float64(v.MeasurableImpressions) * 100
+	}
+}
+
+// add accumulates a record's VAST quartile events
+func (v *VideoMetrics) add(record *BeeswaxLogRecord) {
+	v.Starts += record.VideoStarts
+	v.FirstQuartiles += record.VideoFirstQuartiles
+	v.Midpoints += record.VideoMidpoints
+	v.ThirdQuartiles += record.VideoThirdQuartiles
+	v.Completes += record.VideoCompletes
+}
+
+// calculateRates computes the start rate against impressions and the midpoint and
+// video completion rates (VCR) against starts
+func (v *VideoMetrics) calculateRates(impressions int) {
+	if impressions > 0 {
+		v.StartRate = float64(v.Starts) / float64(impressions) * 100
+	}
+	if v.Starts > 0 {
+		v.MidpointRate = float64(v.Midpoints) / float64(v.Starts) * 100
+		v.VCR = float64(v.Completes) / float64(v.Starts) * 100
+	}
+}
+
+// campaignViewability returns the campaign's viewability metrics, creating them if needed
+func campaignViewability(summary *BeeswaxLogSummary, campaignID string) *ViewabilityMetrics {
+	metrics, ok := summary.CampaignViewability[campaignID]
+	if !ok {
+		metrics = &ViewabilityMetrics{}
+		summary.CampaignViewability[campaignID] = metrics
+	}
+	return metrics
+}
+
+// campaignVideo returns the campaign's video metrics, creating them if needed
+func campaignVideo(summary *BeeswaxLogSummary, campaignID string) *VideoMetrics {
+	metrics, ok := summary.CampaignVideo[campaignID]
+	if !ok {
+		metrics = &VideoMetrics{}
+		summary.CampaignVideo[campaignID] = metrics
+	}
+	return metrics
+}
@@ -0,0 +1,43 @@
+package ingestion
+
+// Metric variables, the names custom metric formulas refer to base metrics by
+const (
+	MetricBids                  = "bids"
+	MetricImpressions           = "impressions"
+	MetricClicks                = "clicks"
+	MetricConversions           = "conversions"
+	MetricSpend                 = "spend"
+	MetricRevenue               = "revenue"
+	MetricMeasurableImpressions = "measurable_impressions"
+	MetricViewableImpressions   = "viewable_impressions"
+)
+
+// MetricVariables lists the metric variables
+var MetricVariables = []string{
+	MetricBids, MetricImpressions, MetricClicks, MetricConversions, MetricSpend, MetricRevenue,
+	MetricMeasurableImpressions, MetricViewableImpressions,
+}
+
+// IsMetricVariable reports whether name is a metric variable
+func IsMetricVariable(name string) bool {
+	for _, variable := range MetricVariables {
+		if variable == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the metrics by variable name, for evaluating custom metric formulas
+func (m CampaignMetrics) Values() map[string]float64 {
+	return map[string]float64{
+		MetricBids:                  float64(m.Bids),
+		MetricImpressions:           float64(m.Impressions),
+		MetricClicks:                float64(m.Clicks),
+		MetricConversions:           float64(m.Conversions),
+		MetricSpend:                 m.Spend,
+		MetricRevenue:               m.Revenue,
+		MetricMeasurableImpressions: float64(m.MeasurableImpressions),
+		MetricViewableImpressions:   float64(m.ViewableImpressions),
+	}
+}
@@ -0,0 +1,523 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// OpenRTB bid logs are JSON, one auction per line (or a single JSON array of them), each pairing
+// the bid request with the response sent back and, when the exchange sent notices, the outcome
+// of each bid:
+//
+//	{"timestamp": "2024-03-01T12:00:00Z", "exchange": "openx",
+//	 "request": {...BidRequest...}, "response": {...BidResponse...},
+//	 "outcomes": [{"bidId": "b1", "won": true, "clearingPrice": 1.85}]}
+//
+// Each bid becomes a canonical record, so the usual analyses work on these logs too, and the
+// requests, floors, deals and loss reasons feed the summary's BidEfficiency. Prices are taken
+// as USD CPMs whatever the response's currency.
+
+// openRTBFormat is the format OpenRTB logs are reported as; it isn't registered since it's
+// detected by file type rather than by header
+var openRTBFormat = &LogFormat{Source: "openrtb"}
+
+// openRTBColumns are the canonical columns an OpenRTB log can fill, in canonicalColumns order
+var openRTBColumns = []string{
+	"ACCOUNT_ID", "AUCTION_ID", "CAMPAIGN_ID", "CREATIVE_ID", "USER_ID",
+	"BID_TIME",
+	"BID_PRICE_MICROS_USD", "CLEARING_PRICE_MICROS_USD", "WIN_COST_MICROS_USD",
+	"DOMAIN", "AD_POSITION",
+	"GEO_COUNTRY", "GEO_REGION", "GEO_CITY", "GEO_LATITUDE", "GEO_LONGITUDE",
+	"PLATFORM_DEVICE_TYPE", "PLATFORM_OS",
+	"EXCHANGE", "SELLER_ID", "SELLER_RELATIONSHIP", "SCHAIN_HOPS",
+	"LOSS_REASON",
+}
+
+// openRTBDeviceTypes names OpenRTB device types (list 5.21)
+var openRTBDeviceTypes = map[int]string{
+	1: "Mobile/Tablet",
+	2: "Personal Computer",
+	3: "Connected TV",
+	4: "Phone",
+	5: "Tablet",
+	6: "Connected Device",
+	7: "Set Top Box",
+}
+
+// openRTBAdPositions names OpenRTB ad positions (list 5.4)
+var openRTBAdPositions = map[int]string{
+	1: "Above the fold",
+	3: "Below the fold",
+	4: "Header",
+	5: "Footer",
+	6: "Sidebar",
+	7: "Full screen",
+}
+
+// openRTBLogEntry is one line of an OpenRTB bid log
+type openRTBLogEntry struct {
+	Timestamp   json.RawMessage  `json:"timestamp"`
+	Exchange    string           `json:"exchange"`
+	Request     *openRTBRequest  `json:"request"`
+	BidRequest  *openRTBRequest  `json:"bidRequest"`
+	Response    *openRTBResponse `json:"response"`
+	BidResponse *openRTBResponse `json:"bidResponse"`
+	Outcomes    []openRTBOutcome `json:"outcomes"`
+}
+
+type openRTBRequest struct {
+	ID     string           `json:"id"`
+	Imp    []openRTBImp     `json:"imp"`
+	Site   *openRTBProperty `json:"site"`
+	App    *openRTBProperty `json:"app"`
+	Device *struct {
+		DeviceType int         `json:"devicetype"`
+		OS         string      `json:"os"`
+		IFA        string      `json:"ifa"`
+		Geo        *openRTBGeo `json:"geo"`
+	} `json:"device"`
+	User *struct {
+		ID       string      `json:"id"`
+		BuyerUID string      `json:"buyeruid"`
+		Geo      *openRTBGeo `json:"geo"`
+	} `json:"user"`
+	Source *struct {
+		SChain *openRTBSupplyChain `json:"schain"`
+		Ext    *struct {
+			SChain *openRTBSupplyChain `json:"schain"`
+		} `json:"ext"`
+	} `json:"source"`
+}
+
+type openRTBImp struct {
+	ID       string  `json:"id"`
+	BidFloor float64 `json:"bidfloor"`
+	Banner   *struct {
+		Pos int `json:"pos"`
+	} `json:"banner"`
+	Video *struct {
+		Pos int `json:"pos"`
+	} `json:"video"`
+	PMP *struct {
+		Deals []struct {
+			ID       string  `json:"id"`
+			BidFloor float64 `json:"bidfloor"`
+		} `json:"deals"`
+	} `json:"pmp"`
+}
+
+type openRTBProperty struct {
+	Domain    string `json:"domain"`
+	Bundle    string `json:"bundle"`
+	Publisher *struct {
+		ID string `json:"id"`
+	} `json:"publisher"`
+}
+
+type openRTBGeo struct {
+	Country string   `json:"country"`
+	Region  string   `json:"region"`
+	City    string   `json:"city"`
+	Lat     *float64 `json:"lat"`
+	Lon     *float64 `json:"lon"`
+}
+
+type openRTBSupplyChain struct {
+	Nodes []json.RawMessage `json:"nodes"`
+}
+
+type openRTBResponse struct {
+	ID      string `json:"id"`
+	NBR     *int   `json:"nbr"`
+	SeatBid []struct {
+		Seat string       `json:"seat"`
+		Bid  []openRTBBid `json:"bid"`
+	} `json:"seatbid"`
+}
+
+type openRTBBid struct {
+	ID     string  `json:"id"`
+	ImpID  string  `json:"impid"`
+	Price  float64 `json:"price"`
+	CID    string  `json:"cid"`
+	CrID   string  `json:"crid"`
+	DealID string  `json:"dealid"`
+}
+
+// openRTBOutcome is what the exchange reported back about a bid, matched by bid ID or else impression ID
+type openRTBOutcome struct {
+	BidID         string   `json:"bidId"`
+	ImpID         string   `json:"impId"`
+	Won           *bool    `json:"won"`
+	ClearingPrice *float64 `json:"clearingPrice"`
+	LossReason    *int     `json:"lossReason"`
+}
+
+// ParseOpenRTBLog parses an OpenRTB bid log, passing each bid's record to onRecord when it is
+// set. Parsing stops at the first error onRecord returns.
+func ParseOpenRTBLog(reader io.Reader, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+	layout := &logLayout{format: openRTBFormat, columns: make(map[string]int)}
+	for i, column := range openRTBColumns {
+		layout.columns[column] = i
+		layout.fields = append(layout.fields, column)
+		layout.indexes = append(layout.indexes, i)
+	}
+
+	aggregator := newBeeswaxAggregator(layout)
+	efficiency := newBidEfficiencySummary()
+	filled := make([]int, len(layout.fields))
+	columns := layout.newColumnProfiles()
+
+	buffered := bufio.NewReader(reader)
+	decoder := json.NewDecoder(buffered)
+
+	// Accept a JSON array of entries as well as one entry per line
+	if first, err := peekNonSpace(buffered); err == nil && first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("failed to read log: %w", err)
+		}
+	}
+
+	for entryNum := 1; decoder.More(); entryNum++ {
+		var entry openRTBLogEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid OpenRTB log entry %d: %w", entryNum, err)
+		}
+
+		for _, record := range entry.records(efficiency, aggregator.summary.LossReasons) {
+			aggregator.add(&record)
+			row := openRTBRow(&record)
+			layout.countFilled(filled, row)
+			layout.profileRow(columns, row)
+
+			if onRecord != nil {
+				if err := onRecord(&record); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if efficiency.Requests == 0 {
+		return nil, fmt.Errorf("no OpenRTB bid requests found")
+	}
+
+	aggregator.addFilled(filled)
+	aggregator.addColumns(columns)
+	summary := aggregator.finish()
+	efficiency.calculateRates()
+	summary.BidEfficiency = efficiency
+
+	return summary, nil
+}
+
+// records converts an entry's bids into canonical records, counting the entry's requests,
+// floors, deals and outcomes into efficiency, and requests left without a bid into losses
+func (e *openRTBLogEntry) records(efficiency *BidEfficiencySummary, losses *LossReasonSummary) []BeeswaxLogRecord {
+	request, response := e.Request, e.Response
+	if request == nil {
+		request = e.BidRequest
+	}
+	if response == nil {
+		response = e.BidResponse
+	}
+	if request == nil {
+		return nil
+	}
+
+	// The opportunities offered, with their floors and deals
+	efficiency.Requests++
+	efficiency.Opportunities += len(request.Imp)
+	imps := make(map[string]*openRTBImp, len(request.Imp))
+	for i := range request.Imp {
+		imp := &request.Imp[i]
+		imps[imp.ID] = imp
+
+		if imp.BidFloor > 0 {
+			efficiency.FlooredOpportunities++
+			efficiency.TotalFloor += imp.BidFloor
+		}
+		if imp.PMP != nil {
+			for _, offered := range imp.PMP.Deals {
+				deal := efficiency.deal(offered.ID)
+				deal.Opportunities++
+				deal.TotalFloor += dealFloor(imp, offered.ID)
+			}
+		}
+	}
+
+	base := request.baseRecord(e.Exchange, parseOpenRTBTime(e.Timestamp))
+
+	var records []BeeswaxLogRecord
+	if response != nil {
+		for _, seat := range response.SeatBid {
+			for _, bid := range seat.Bid {
+				record := base
+				record.AccountID = seat.Seat
+				record.CampaignID = bid.CID
+				record.CreativeID = bid.CrID
+				record.BidPriceMicrosUSD = int64(bid.Price * cpmToMicros)
+
+				imp := imps[bid.ImpID]
+				if imp == nil && len(request.Imp) == 1 {
+					imp = &request.Imp[0]
+				}
+				if imp != nil {
+					record.AdPosition = imp.position()
+				}
+
+				e.countBid(efficiency, &record, bid, imp)
+				records = append(records, record)
+			}
+		}
+	}
+
+	if len(records) == 0 {
+		efficiency.NoBids++
+		var reason *int
+		if response != nil && response.NBR != nil {
+			reason = response.NBR
+			efficiency.NoBidReasons[strconv.Itoa(*response.NBR)]++
+		}
+		losses.addNoBid(e.Exchange, reason)
+	}
+
+	return records
+}
+
+// countBid counts a bid against its floor, deal and outcome, setting the record's prices when it won
+func (e *openRTBLogEntry) countBid(efficiency *BidEfficiencySummary, record *BeeswaxLogRecord, bid openRTBBid, imp *openRTBImp) {
+	efficiency.Bids++
+	efficiency.TotalBid += bid.Price
+
+	floor := 0.0
+	if imp != nil {
+		floor = imp.BidFloor
+		if bid.DealID != "" {
+			floor = dealFloor(imp, bid.DealID)
+		}
+	}
+	if floor > 0 {
+		efficiency.FlooredBids++
+		efficiency.TotalBidToFloor += bid.Price / floor
+		if bid.Price < floor {
+			efficiency.BidsBelowFloor++
+		}
+	}
+
+	var deal *DealEfficiency
+	if bid.DealID != "" {
+		deal = efficiency.deal(bid.DealID)
+		deal.Bids++
+		deal.TotalBid += bid.Price
+	}
+
+	outcome := e.outcome(bid)
+	switch {
+	case outcome == nil:
+		efficiency.LossReasons[unknownOutcomeReason]++
+		return
+	case outcome.LossReason != nil && *outcome.LossReason != 0:
+		record.LossReason = strconv.Itoa(*outcome.LossReason)
+		efficiency.LossReasons[record.LossReason]++
+		return
+	case outcome.Won != nil && !*outcome.Won && outcome.LossReason == nil:
+		efficiency.LossReasons[unknownOutcomeReason]++
+		return
+	}
+
+	// Won: first-price auctions clear at the bid when the exchange doesn't say otherwise
+	efficiency.Wins++
+	efficiency.LossReasons["0"]++
+	if deal != nil {
+		deal.Wins++
+	}
+
+	clearing := bid.Price
+	if outcome.ClearingPrice != nil {
+		clearing = *outcome.ClearingPrice
+		efficiency.PricedWins++
+		efficiency.TotalClearing += clearing
+		efficiency.TotalOverbid += bid.Price - clearing
+	}
+	record.ClearingPriceMicrosUSD = int64(clearing * cpmToMicros)
+	record.WinCostMicrosUSD = record.ClearingPriceMicrosUSD
+	record.ImpressionTime = record.BidTime
+}
+
+// dealFloor is the floor of a deal offered on the impression, which replaces the impression's
+// own floor when the deal sets one
+func dealFloor(imp *openRTBImp, dealID string) float64 {
+	if imp.PMP != nil {
+		for _, offered := range imp.PMP.Deals {
+			if offered.ID == dealID && offered.BidFloor > 0 {
+				return offered.BidFloor
+			}
+		}
+	}
+	return imp.BidFloor
+}
+
+// outcome finds what the exchange reported about a bid
+func (e *openRTBLogEntry) outcome(bid openRTBBid) *openRTBOutcome {
+	for i := range e.Outcomes {
+		if bid.ID != "" && e.Outcomes[i].BidID == bid.ID {
+			return &e.Outcomes[i]
+		}
+	}
+	for i := range e.Outcomes {
+		if e.Outcomes[i].BidID == "" && bid.ImpID != "" && e.Outcomes[i].ImpID == bid.ImpID {
+			return &e.Outcomes[i]
+		}
+	}
+	return nil
+}
+
+// baseRecord fills the fields every bid on the request shares
+func (r *openRTBRequest) baseRecord(exchange string, bidTime time.Time) BeeswaxLogRecord {
+	record := BeeswaxLogRecord{
+		AuctionID: r.ID,
+		BidTime:   bidTime,
+		Exchange:  exchange,
+	}
+
+	property := r.Site
+	if property == nil {
+		property = r.App
+	}
+	if property != nil {
+		record.Domain = property.Domain
+		if record.Domain == "" {
+			record.Domain = property.Bundle
+		}
+		if property.Publisher != nil {
+			record.SellerID = property.Publisher.ID
+		}
+	}
+
+	var geo *openRTBGeo
+	if r.Device != nil {
+		record.PlatformDeviceType = openRTBDeviceTypes[r.Device.DeviceType]
+		record.PlatformOS = r.Device.OS
+		record.UserID = r.Device.IFA
+		geo = r.Device.Geo
+	}
+	if r.User != nil {
+		if r.User.BuyerUID != "" {
+			record.UserID = r.User.BuyerUID
+		} else if r.User.ID != "" {
+			record.UserID = r.User.ID
+		}
+		if geo == nil {
+			geo = r.User.Geo
+		}
+	}
+	if geo != nil {
+		record.GeoCountry = geo.Country
+		record.GeoRegion = geo.Region
+		record.GeoCity = geo.City
+		if geo.Lat != nil && geo.Lon != nil && *geo.Lat >= -90 && *geo.Lat <= 90 && *geo.Lon >= -180 && *geo.Lon <= 180 {
+			record.Location = &GeoPoint{Latitude: *geo.Lat, Longitude: *geo.Lon}
+		}
+	}
+
+	// OpenRTB 2.6 carries the supply chain on the source, 2.5 in its extension
+	if r.Source != nil {
+		schain := r.Source.SChain
+		if schain == nil && r.Source.Ext != nil {
+			schain = r.Source.Ext.SChain
+		}
+		if schain != nil && len(schain.Nodes) > 0 {
+			record.SupplyChainHops = len(schain.Nodes)
+			record.SellerRelationship = SellerRelationshipDirect
+			if record.SupplyChainHops > 1 {
+				record.SellerRelationship = SellerRelationshipReseller
+			}
+		}
+	}
+
+	return record
+}
+
+// position names the impression's ad position, if the request gave one
+func (imp *openRTBImp) position() string {
+	switch {
+	case imp.Banner != nil && imp.Banner.Pos != 0:
+		return openRTBAdPositions[imp.Banner.Pos]
+	case imp.Video != nil && imp.Video.Pos != 0:
+		return openRTBAdPositions[imp.Video.Pos]
+	}
+	return ""
+}
+
+// openRTBRow lays a record out in openRTBColumns order, blank where it has no value, for field coverage
+func openRTBRow(r *BeeswaxLogRecord) []string {
+	micros := func(v int64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	bidTime, latitude, longitude, hops := "", "", "", ""
+	if !r.BidTime.IsZero() {
+		bidTime = r.BidTime.Format(time.RFC3339)
+	}
+	if r.Location != nil {
+		latitude = strconv.FormatFloat(r.Location.Latitude, 'f', -1, 64)
+		longitude = strconv.FormatFloat(r.Location.Longitude, 'f', -1, 64)
+	}
+	if r.SupplyChainHops > 0 {
+		hops = strconv.Itoa(r.SupplyChainHops)
+	}
+
+	return []string{
+		r.AccountID, r.AuctionID, r.CampaignID, r.CreativeID, r.UserID,
+		bidTime,
+		micros(r.BidPriceMicrosUSD), micros(r.ClearingPriceMicrosUSD), micros(r.WinCostMicrosUSD),
+		r.Domain, r.AdPosition,
+		r.GeoCountry, r.GeoRegion, r.GeoCity, latitude, longitude,
+		r.PlatformDeviceType, r.PlatformOS,
+		r.Exchange, r.SellerID, r.SellerRelationship, hops,
+		r.LossReason,
+	}
+}
+
+// parseOpenRTBTime parses an entry's timestamp, given either as a string in one of the log time
+// layouts or as Unix seconds or milliseconds
+func parseOpenRTBTime(raw json.RawMessage) time.Time {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return parseLogTime(text, "timestamp", nil)
+	}
+
+	var unix float64
+	if err := json.Unmarshal(raw, &unix); err != nil {
+		return time.Time{}
+	}
+	if unix > 1e12 {
+		return time.UnixMilli(int64(unix)).UTC()
+	}
+	return time.Unix(int64(unix), 0).UTC()
+}
+
+// peekNonSpace returns the first byte that isn't whitespace without consuming it
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		peeked, err := reader.Peek(n)
+		if len(peeked) < n {
+			return 0, err
+		}
+		if b := peeked[n-1]; !bytes.ContainsAny([]byte{b}, " \t\r\n") {
+			return b, nil
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package ingestion
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parseBatchSize is how many rows the reader hands to a parser worker at once
+const parseBatchSize = 1000
+
+// rowBatch is a run of consecutive CSV rows with the input offset after each row; err is set
+// on the last batch when reading failed
+type rowBatch struct {
+	seq  int
+	rows [][]string
+	ends []int64
+	err  error
+}
+
+// recordBatch is a rowBatch after parsing, with how many of its rows filled each layout field
+// and a profile of each field's values
+type recordBatch struct {
+	seq     int
+	rows    [][]string
+	ends    []int64
+	records []BeeswaxLogRecord
+	filled  []int
+	columns []columnProfile
+	err     error
+}
+
+// ParseBeeswaxLogConcurrent parses a DSP log like ParseBeeswaxLogRecords, spreading the
+// CSV row parsing over workers goroutines. One goroutine reads rows and the caller's goroutine
+// aggregates the parsed records in file order, so onRecord sees records in the same order and
+// the summary is identical to a sequential parse. Workers below 1 uses one per CPU.
+func ParseBeeswaxLogConcurrent(reader io.Reader, workers int, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+	return ParseCSVLogWithFormat(reader, workers, "", onRecord)
+}
+
+// ParseCSVLogWithFormat parses a DSP log like ParseBeeswaxLogConcurrent, mapping its columns
+// with the log format registered for source instead of detecting the format from the header.
+// An empty source detects the format.
+func ParseCSVLogWithFormat(reader io.Reader, workers int, source string, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+	return parseCSVLog(reader, workers, nil, func(header []string) (*logLayout, error) {
+		return resolveLogLayout(header, source, nil)
+	}, onRecord)
+}
+
+// parseCSVLog parses a DSP log like ParseCSVLogWithFormat, mapping its columns with the layout
+// resolve returns for the header. When header is set, the reader starts after the header row
+// instead, such as at the row a failed parse stopped at. A failure after some records were
+// aggregated is returned as a *partialParseError.
+func parseCSVLog(reader io.Reader, workers int, header []string, resolve func(header []string) (*logLayout, error), onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	csvReader := csv.NewReader(reader)
+
+	// Read the header row, unless this resumes after it
+	if header == nil {
+		var err error
+		header, err = csvReader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	} else {
+		csvReader.FieldsPerRecord = len(header)
+	}
+
+	layout, err := resolve(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Track where the last aggregated record ended, so a failure can report how far parsing got.
+	// The reader goroutine owns csvReader from here on, so the aggregator only sees the offsets
+	// batches carry.
+	offset := csvReader.InputOffset()
+
+	// Closing done stops the reader and workers when aggregation ends early
+	done := make(chan struct{})
+	defer close(done)
+
+	rows := make(chan rowBatch, workers*2)
+	parsed := make(chan recordBatch, workers*2)
+
+	// Reader: split the file into batches of rows
+	go func() {
+		defer close(rows)
+
+		for seq := 0; ; seq++ {
+			batch := rowBatch{seq: seq, rows: make([][]string, 0, parseBatchSize), ends: make([]int64, 0, parseBatchSize)}
+			for len(batch.rows) < parseBatchSize {
+				row, err := csvReader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					batch.err = fmt.Errorf("error reading record: %w", err)
+					break
+				}
+				batch.rows = append(batch.rows, row)
+				batch.ends = append(batch.ends, csvReader.InputOffset())
+			}
+
+			if len(batch.rows) == 0 && batch.err == nil {
+				return
+			}
+
+			select {
+			case rows <- batch:
+			case <-done:
+				return
+			}
+
+			if batch.err != nil || len(batch.rows) < parseBatchSize {
+				return
+			}
+		}
+	}()
+
+	// Workers: parse rows into records
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for batch := range rows {
+				records := make([]BeeswaxLogRecord, len(batch.rows))
+				filled := make([]int, len(layout.fields))
+				columns := layout.newColumnProfiles()
+				for j, row := range batch.rows {
+					records[j] = parseBeeswaxRecord(layout, row)
+					layout.countFilled(filled, row)
+					layout.profileRow(columns, row)
+				}
+
+				select {
+				case parsed <- recordBatch{seq: batch.seq, rows: batch.rows, ends: batch.ends, records: records, filled: filled, columns: columns, err: batch.err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	// Aggregator: apply batches in file order, holding back any that arrive early
+	aggregator := newBeeswaxAggregator(layout)
+	pending := make(map[int]recordBatch)
+	next := 0
+
+	var aggregated int64
+	partial := func(err error) error {
+		if aggregated == 0 {
+			return err
+		}
+		return &partialParseError{err: err, header: header, offset: offset, rows: aggregated, summary: aggregator.finish()}
+	}
+
+	for batch := range parsed {
+		pending[batch.seq] = batch
+
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			for i := range ready.records {
+				record := &ready.records[i]
+				aggregator.add(record)
+				aggregated++
+				offset = ready.ends[i]
+
+				if onRecord != nil {
+					if err := onRecord(record); err != nil {
+						// Only the rows up to this record count towards field coverage and column statistics
+						filled := make([]int, len(layout.fields))
+						columns := layout.newColumnProfiles()
+						for _, row := range ready.rows[:i+1] {
+							layout.countFilled(filled, row)
+							layout.profileRow(columns, row)
+						}
+						aggregator.addFilled(filled)
+						aggregator.addColumns(columns)
+						return nil, partial(err)
+					}
+				}
+			}
+
+			aggregator.addFilled(ready.filled)
+			aggregator.addColumns(ready.columns)
+
+			if ready.err != nil {
+				return nil, partial(ready.err)
+			}
+		}
+	}
+
+	return aggregator.finish(), nil
+}
@@ -0,0 +1,103 @@
+package ingestion
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+)
+
+// generateBeeswaxLog writes a synthetic Beeswax log with the given anomalies injected
+func generateBeeswaxLog(tb testing.TB, rows int, anomalies []string) []byte {
+	tb.Helper()
+
+	cfg := loggen.DefaultConfig()
+	cfg.Rows = rows
+	cfg.Anomalies = anomalies
+	generator, err := loggen.New(cfg)
+	if err != nil {
+		tb.Fatalf("failed to create log generator: %v", err)
+	}
+	var log bytes.Buffer
+	if _, err := generator.Write(&log); err != nil {
+		tb.Fatalf("failed to generate log: %v", err)
+	}
+	return log.Bytes()
+}
+
+func TestParseBeeswaxLogConcurrentMatchesSequential(t *testing.T) {
+	// Several batches per worker with a partial last batch, and every anomaly so the summary
+	// sections that only fill up on unusual traffic and bad rows are compared too
+	data := generateBeeswaxLog(t, 8*parseBatchSize+123, loggen.Anomalies)
+
+	sequential, err := ParseBeeswaxLog(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseBeeswaxLog: %v", err)
+	}
+
+	for _, workers := range []int{1, 2, 4, 7} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var records int
+			concurrent, err := ParseBeeswaxLogConcurrent(bytes.NewReader(data), workers, func(*BeeswaxLogRecord) error {
+				records++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseBeeswaxLogConcurrent: %v", err)
+			}
+
+			if records != sequential.TotalRecords {
+				t.Errorf("onRecord saw %d records, want %d", records, sequential.TotalRecords)
+			}
+			if !reflect.DeepEqual(concurrent, sequential) {
+				t.Errorf("summary differs from the sequential parse: %d records, %d impressions, %.2f spend; want %d, %d, %.2f",
+					concurrent.TotalRecords, concurrent.TotalImpressions, concurrent.TotalWinCost,
+					sequential.TotalRecords, sequential.TotalImpressions, sequential.TotalWinCost)
+			}
+		})
+	}
+}
+
+// benchmarkRows is the size of the benchmarks' log, large enough to keep every worker busy
+const benchmarkRows = 200000
+
+func BenchmarkParseBeeswaxLog(b *testing.B) {
+	data := generateBeeswaxLog(b, benchmarkRows, nil)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseBeeswaxLog(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseBeeswaxLogConcurrent parses with 1, 2, 4, ... workers up to the CPU count, for
+// comparison with BenchmarkParseBeeswaxLog on the same machine
+func BenchmarkParseBeeswaxLogConcurrent(b *testing.B) {
+	data := generateBeeswaxLog(b, benchmarkRows, nil)
+
+	var counts []int
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		counts = append(counts, n)
+	}
+	counts = append(counts, runtime.NumCPU())
+
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseBeeswaxLogConcurrent(bytes.NewReader(data), workers, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParserJSON names the parser of JSON logs whose format is detected from their content
+const ParserJSON = "json"
+
+// ParserRun is the outcome of parsing one file, recorded so a parser regression after a DSP
+// changes its log format shows up in failure rates, row errors and throughput
+type ParserRun struct {
+	FileID string `json:"fileId"`
+	Parser string `json:"parser"`
+	// Source is the log format the file was parsed as, such as beeswax; empty when parsing
+	// failed before it was known
+	Source string `json:"source"`
+	Failed bool   `json:"failed"`
+	Rows   int64  `json:"rows"`
+	// RowErrors counts rows without a readable bid time, the usual sign of a changed format
+	RowErrors  int64         `json:"rowErrors"`
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"duration"`
+	FinishedAt time.Time     `json:"finishedAt"`
+}
+
+// ParserRunSink persists parser runs
+type ParserRunSink interface {
+	SaveParserRun(ctx context.Context, run *ParserRun) error
+}
+
+// parserKey identifies the counters of a parser and source
+type parserKey struct {
+	parser, source string
+}
+
+// parserCounters accumulate a parser's runs since the process started
+type parserCounters struct {
+	files, failed, rows, rowErrors, bytes int64
+	seconds                               float64
+}
+
+// ParserMetrics counts parser runs by parser and source for Prometheus scrapes
+type ParserMetrics struct {
+	mu       sync.Mutex
+	counters map[parserKey]*parserCounters
+}
+
+// NewParserMetrics creates parser metrics with no runs
+func NewParserMetrics() *ParserMetrics {
+	return &ParserMetrics{
+		counters: make(map[parserKey]*parserCounters),
+	}
+}
+
+// Record adds a run to the counters
+func (m *ParserMetrics) Record(run *ParserRun) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := parserKey{run.Parser, run.Source}
+	counters, ok := m.counters[key]
+	if !ok {
+		counters = &parserCounters{}
+		m.counters[key] = counters
+	}
+	counters.files++
+	if run.Failed {
+		counters.failed++
+	}
+	counters.rows += run.Rows
+	counters.rowErrors += run.RowErrors
+	counters.bytes += run.Bytes
+	counters.seconds += run.Duration.Seconds()
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition format
+func (m *ParserMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]parserKey, 0, len(m.counters))
+	for key := range m.counters {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].parser != keys[j].parser {
+			return keys[i].parser < keys[j].parser
+		}
+		return keys[i].source < keys[j].source
+	})
+
+	metrics := []struct {
+		name, help string
+		value      func(*parserCounters) string
+	}{
+		{"advantage_parser_files_total", "Files parsed.", func(c *parserCounters) string { return fmt.Sprint(c.files) }},
+		{"advantage_parser_failed_files_total", "Files whose parsing failed.", func(c *parserCounters) string { return fmt.Sprint(c.failed) }},
+		{"advantage_parser_rows_total", "Rows parsed.", func(c *parserCounters) string { return fmt.Sprint(c.rows) }},
+		{"advantage_parser_row_errors_total", "Rows parsed without a readable bid time.", func(c *parserCounters) string { return fmt.Sprint(c.rowErrors) }},
+		{"advantage_parser_bytes_total", "Bytes of files parsed.", func(c *parserCounters) string { return fmt.Sprint(c.bytes) }},
+		{"advantage_parser_duration_seconds_total", "Time spent parsing files.", func(c *parserCounters) string { return fmt.Sprint(c.seconds) }},
+	}
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s{parser=%q,source=%q} %s\n", metric.name, key.parser, key.source, metric.value(m.counters[key]))
+		}
+	}
+}
+
+// ParserStats aggregates the runs of a parser and source over a period
+type ParserStats struct {
+	Parser      string  `json:"parser"`
+	Source      string  `json:"source"`
+	Files       int64   `json:"files"`
+	FailedFiles int64   `json:"failedFiles"`
+	Rows        int64   `json:"rows"`
+	RowErrors   int64   `json:"rowErrors"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	// FailureRate and RowErrorRate are percentages of files and rows
+	FailureRate   float64 `json:"failureRate"`
+	RowErrorRate  float64 `json:"rowErrorRate"`
+	RowsPerSecond float64 `json:"rowsPerSecond"`
+}
+
+// CalculateRates derives the rates from the totals
+func (s *ParserStats) CalculateRates() {
+	s.FailureRate = ratio(float64(s.FailedFiles), float64(s.Files)) * 100
+	s.RowErrorRate = ratio(float64(s.RowErrors), float64(s.Rows)) * 100
+	s.RowsPerSecond = ratio(float64(s.Rows), s.Seconds)
+}
@@ -0,0 +1,384 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Prebid Server analytics modules write one auction object per line: the OpenRTB request PBS
+// received, the response it returned, and in the response extension each adapter's response
+// time and errors. Field names are matched case-insensitively, so both the file logger's
+// {"Status", "Request", "Response"} and lower-case variants parse:
+//
+//	{"Status": 200, "StartTime": "2024-03-01T12:00:00Z",
+//	 "Request": {"id": "a1", "imp": [{"id": "top", "ext": {"prebid": {"bidder": {"appnexus": {}}}}}]},
+//	 "Response": {"seatbid": [...], "ext": {"responsetimemillis": {"appnexus": 120},
+//	              "errors": {"rubicon": [{"code": 1, "message": "Timeout"}]}}}}
+//
+// Wins are wins within the Prebid auction: the bid PBS targeted with hb_bidder, or the highest
+// bid when targeting wasn't requested. Whether that bid then won in the ad server isn't logged.
+
+// prebidTimeoutErrorCode is the code Prebid Server reports adapter timeouts with
+const prebidTimeoutErrorCode = 1
+
+// prebidLogMarker only appears in Prebid Server responses, so finding it near the start of a
+// JSON log tells Prebid analytics output apart from DSP bid logs
+var prebidLogMarker = []byte(`"responsetimemillis"`)
+
+// prebidSniffSize is how much of a JSON log is searched for prebidLogMarker
+const prebidSniffSize = 1 << 20
+
+// prebidCPMBucketEdges are the upper bounds of the bid CPM distribution's buckets; the last
+// bucket holds everything above the final edge
+var prebidCPMBucketEdges = []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 20}
+
+// prebidReservedImpExt are imp.ext keys that aren't bidders in requests that list bidders
+// directly in the extension rather than under ext.prebid.bidder
+var prebidReservedImpExt = map[string]bool{
+	"prebid": true, "data": true, "context": true, "gpid": true, "tid": true, "skadn": true, "ae": true,
+}
+
+// PrebidSummary contains header bidding metrics from Prebid Server analytics output
+type PrebidSummary struct {
+	Auctions int `json:"auctions"`
+	AdUnits  int `json:"adUnits"`
+	// Adapters holds each bidder adapter's activity, keyed by bidder code
+	Adapters map[string]*PrebidAdapterMetrics `json:"adapters"`
+}
+
+// PrebidAdapterMetrics contains a bidder adapter's activity. Prices are CPMs.
+type PrebidAdapterMetrics struct {
+	// Auctions counts auctions the adapter was called in, AdUnitRequests the ad units it was asked to bid on
+	Auctions            int     `json:"auctions"`
+	AdUnitRequests      int     `json:"adUnitRequests"`
+	Bids                int     `json:"bids"`
+	Wins                int     `json:"wins"`
+	Timeouts            int     `json:"timeouts"`
+	Errors              int     `json:"errors"`
+	TotalCPM            float64 `json:"totalCpm"`
+	WinningCPM          float64 `json:"winningCpm"`
+	Responses           int     `json:"responses"`
+	TotalResponseMillis int64   `json:"totalResponseMillis"`
+	// CPMBuckets counts bids by CPM, one count per prebidCPMBucketEdges entry plus one above the last
+	CPMBuckets []int `json:"cpmBuckets,omitempty"`
+
+	// Derived rates, in percent, and averages
+	BidRate               float64 `json:"bidRate"`
+	WinRate               float64 `json:"winRate"`
+	TimeoutRate           float64 `json:"timeoutRate"`
+	ErrorRate             float64 `json:"errorRate"`
+	AverageCPM            float64 `json:"averageCpm"`
+	AverageWinningCPM     float64 `json:"averageWinningCpm"`
+	AverageResponseMillis float64 `json:"averageResponseMillis"`
+}
+
+// CPMBucket is a range of bid CPMs with how many bids fell in it; Max is nil for the open-ended last bucket
+type CPMBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+	Share float64  `json:"share"`
+}
+
+// PrebidAdapterEntry is an adapter with its metrics and bid CPM distribution, used in reports
+type PrebidAdapterEntry struct {
+	Bidder string `json:"bidder"`
+	PrebidAdapterMetrics
+	CPMDistribution []CPMBucket `json:"cpmDistribution"`
+}
+
+// PrebidReport is the header bidding analysis of a processed Prebid Server analytics log
+type PrebidReport struct {
+	FileID   string               `json:"fileId"`
+	Auctions int                  `json:"auctions"`
+	AdUnits  int                  `json:"adUnits"`
+	Adapters []PrebidAdapterEntry `json:"adapters"`
+}
+
+// prebidAuction is one Prebid Server analytics auction object
+type prebidAuction struct {
+	Request *struct {
+		Imp []struct {
+			ID  string                     `json:"id"`
+			Ext map[string]json.RawMessage `json:"ext"`
+		} `json:"imp"`
+	} `json:"request"`
+	Response *struct {
+		SeatBid []struct {
+			Seat string `json:"seat"`
+			Bid  []struct {
+				ImpID string  `json:"impid"`
+				Price float64 `json:"price"`
+				Ext   struct {
+					Prebid struct {
+						Targeting map[string]string `json:"targeting"`
+					} `json:"prebid"`
+				} `json:"ext"`
+			} `json:"bid"`
+		} `json:"seatbid"`
+		Ext struct {
+			ResponseTimeMillis map[string]int64 `json:"responsetimemillis"`
+			Errors             map[string][]struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"ext"`
+	} `json:"response"`
+}
+
+// ParseJSONLog parses a JSON log, telling Prebid Server analytics output apart from OpenRTB
+// bid logs by its content
+func ParseJSONLog(reader io.Reader, onRecord func(*BeeswaxLogRecord) error) (*BeeswaxLogSummary, error) {
+	buffered := bufio.NewReaderSize(reader, prebidSniffSize)
+	start, _ := buffered.Peek(prebidSniffSize)
+	if bytes.Contains(bytes.ToLower(start), prebidLogMarker) {
+		return ParsePrebidLog(buffered)
+	}
+	return ParseOpenRTBLog(buffered, onRecord)
+}
+
+// ParsePrebidLog parses Prebid Server analytics output. The log has no DSP records, so the
+// summary only carries its Prebid metrics.
+func ParsePrebidLog(reader io.Reader) (*BeeswaxLogSummary, error) {
+	prebid := newPrebidSummary()
+
+	buffered := bufio.NewReader(reader)
+	decoder := json.NewDecoder(buffered)
+
+	// Accept a JSON array of auctions as well as one auction per line
+	if first, err := peekNonSpace(buffered); err == nil && first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("failed to read log: %w", err)
+		}
+	}
+
+	for auctionNum := 1; decoder.More(); auctionNum++ {
+		var auction prebidAuction
+		if err := decoder.Decode(&auction); err != nil {
+			return nil, fmt.Errorf("invalid Prebid auction %d: %w", auctionNum, err)
+		}
+		prebid.add(&auction)
+	}
+
+	if prebid.Auctions == 0 {
+		return nil, fmt.Errorf("no Prebid auctions found")
+	}
+	prebid.calculateRates()
+
+	summary := newBeeswaxLogSummary()
+	summary.Source = "prebid"
+	summary.Prebid = prebid
+
+	return summary, nil
+}
+
+func newPrebidSummary() *PrebidSummary {
+	return &PrebidSummary{
+		Adapters: make(map[string]*PrebidAdapterMetrics),
+	}
+}
+
+// adapter returns a bidder's metrics, creating them on first use
+func (s *PrebidSummary) adapter(bidder string) *PrebidAdapterMetrics {
+	metrics, ok := s.Adapters[bidder]
+	if !ok {
+		metrics = &PrebidAdapterMetrics{CPMBuckets: make([]int, len(prebidCPMBucketEdges)+1)}
+		s.Adapters[bidder] = metrics
+	}
+	return metrics
+}
+
+// add accumulates one auction
+func (s *PrebidSummary) add(auction *prebidAuction) {
+	if auction.Request == nil {
+		return
+	}
+	s.Auctions++
+	s.AdUnits += len(auction.Request.Imp)
+
+	// The adapters each ad unit asked for
+	called := make(map[string]int)
+	for _, imp := range auction.Request.Imp {
+		for _, bidder := range prebidImpBidders(imp.Ext) {
+			called[bidder]++
+		}
+	}
+
+	if response := auction.Response; response != nil {
+		// Adapters configured through stored requests only show up in the response
+		for bidder := range response.Ext.ResponseTimeMillis {
+			if _, ok := called[bidder]; !ok {
+				called[bidder] = len(auction.Request.Imp)
+			}
+		}
+		for bidder, millis := range response.Ext.ResponseTimeMillis {
+			metrics := s.adapter(bidder)
+			metrics.Responses++
+			metrics.TotalResponseMillis += millis
+		}
+		for bidder, errs := range response.Ext.Errors {
+			if _, ok := called[bidder]; !ok {
+				continue
+			}
+			timedOut := false
+			for _, e := range errs {
+				if e.Code == prebidTimeoutErrorCode || strings.Contains(strings.ToLower(e.Message), "timeout") {
+					timedOut = true
+				}
+			}
+			if timedOut {
+				s.adapter(bidder).Timeouts++
+			} else if len(errs) > 0 {
+				s.adapter(bidder).Errors++
+			}
+		}
+
+		// Bids, and the winning bid per ad unit
+		type winner struct {
+			bidder   string
+			price    float64
+			targeted bool
+		}
+		winners := make(map[string]winner)
+		for _, seat := range response.SeatBid {
+			for _, bid := range seat.Bid {
+				metrics := s.adapter(seat.Seat)
+				metrics.Bids++
+				metrics.TotalCPM += bid.Price
+				metrics.CPMBuckets[prebidCPMBucket(bid.Price)]++
+
+				_, targeted := bid.Ext.Prebid.Targeting["hb_bidder"]
+				current, ok := winners[bid.ImpID]
+				if !ok || (targeted && !current.targeted) || (targeted == current.targeted && bid.Price > current.price) {
+					winners[bid.ImpID] = winner{bidder: seat.Seat, price: bid.Price, targeted: targeted}
+				}
+				if _, ok := called[seat.Seat]; !ok {
+					called[seat.Seat] = len(auction.Request.Imp)
+				}
+			}
+		}
+		for _, w := range winners {
+			metrics := s.adapter(w.bidder)
+			metrics.Wins++
+			metrics.WinningCPM += w.price
+		}
+	}
+
+	for bidder, adUnits := range called {
+		metrics := s.adapter(bidder)
+		metrics.Auctions++
+		metrics.AdUnitRequests += adUnits
+	}
+}
+
+// prebidImpBidders lists the bidders an ad unit asked for, from ext.prebid.bidder or, in older
+// requests, from the extension's own keys
+func prebidImpBidders(ext map[string]json.RawMessage) []string {
+	var bidders []string
+	if raw, ok := ext["prebid"]; ok {
+		var prebid struct {
+			Bidder map[string]json.RawMessage `json:"bidder"`
+		}
+		if json.Unmarshal(raw, &prebid) == nil {
+			for bidder := range prebid.Bidder {
+				bidders = append(bidders, bidder)
+			}
+		}
+	}
+	if len(bidders) > 0 {
+		return bidders
+	}
+
+	for key := range ext {
+		if !prebidReservedImpExt[key] {
+			bidders = append(bidders, key)
+		}
+	}
+	return bidders
+}
+
+// prebidCPMBucket returns the index of the bucket a CPM falls in
+func prebidCPMBucket(cpm float64) int {
+	return sort.Search(len(prebidCPMBucketEdges), func(i int) bool {
+		return cpm < prebidCPMBucketEdges[i]
+	})
+}
+
+// merge accumulates another summary's counts; derived rates must be recalculated afterwards
+func (s *PrebidSummary) merge(other *PrebidSummary) {
+	s.Auctions += other.Auctions
+	s.AdUnits += other.AdUnits
+	for bidder, metrics := range other.Adapters {
+		merged := s.adapter(bidder)
+		merged.Auctions += metrics.Auctions
+		merged.AdUnitRequests += metrics.AdUnitRequests
+		merged.Bids += metrics.Bids
+		merged.Wins += metrics.Wins
+		merged.Timeouts += metrics.Timeouts
+		merged.Errors += metrics.Errors
+		merged.TotalCPM += metrics.TotalCPM
+		merged.WinningCPM += metrics.WinningCPM
+		merged.Responses += metrics.Responses
+		merged.TotalResponseMillis += metrics.TotalResponseMillis
+		for i := 0; i < len(merged.CPMBuckets) && i < len(metrics.CPMBuckets); i++ {
+			merged.CPMBuckets[i] += metrics.CPMBuckets[i]
+		}
+	}
+}
+
+// calculateRates computes each adapter's derived metrics from its counts
+func (s *PrebidSummary) calculateRates() {
+	for _, m := range s.Adapters {
+		m.BidRate = rate(m.Bids, m.AdUnitRequests)
+		m.WinRate = rate(m.Wins, m.Bids)
+		m.TimeoutRate = rate(m.Timeouts, m.Auctions)
+		m.ErrorRate = rate(m.Errors, m.Auctions)
+		m.AverageCPM = average(m.TotalCPM, m.Bids)
+		m.AverageWinningCPM = average(m.WinningCPM, m.Wins)
+		m.AverageResponseMillis = average(float64(m.TotalResponseMillis), m.Responses)
+	}
+}
+
+// BuildPrebidReport builds the header bidding report from a file's Prebid summary
+func BuildPrebidReport(fileID string, summary *PrebidSummary) *PrebidReport {
+	report := &PrebidReport{
+		FileID:   fileID,
+		Auctions: summary.Auctions,
+		AdUnits:  summary.AdUnits,
+		Adapters: make([]PrebidAdapterEntry, 0, len(summary.Adapters)),
+	}
+
+	for bidder, metrics := range summary.Adapters {
+		entry := PrebidAdapterEntry{Bidder: bidder, PrebidAdapterMetrics: *metrics}
+		entry.CPMDistribution = make([]CPMBucket, len(metrics.CPMBuckets))
+		for i, count := range metrics.CPMBuckets {
+			bucket := CPMBucket{Count: count}
+			if i > 0 {
+				bucket.Min = prebidCPMBucketEdges[i-1]
+			}
+			if i < len(prebidCPMBucketEdges) {
+				bucket.Max = &prebidCPMBucketEdges[i]
+			}
+			if metrics.Bids > 0 {
+				bucket.Share = float64(count) / float64(metrics.Bids) * 100
+			}
+			entry.CPMDistribution[i] = bucket
+		}
+		entry.CPMBuckets = nil
+		report.Adapters = append(report.Adapters, entry)
+	}
+
+	sort.Slice(report.Adapters, func(i, j int) bool {
+		if report.Adapters[i].Bids != report.Adapters[j].Bids {
+			return report.Adapters[i].Bids > report.Adapters[j].Bids
+		}
+		return report.Adapters[i].Bidder < report.Adapters[j].Bidder
+	})
+
+	return report
+}
@@ -0,0 +1,246 @@
+package ingestion
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Price floor dimensions, what a price floor entry is for
+const (
+	PriceFloorByDomain   = "domain"
+	PriceFloorByExchange = "exchange"
+)
+
+// Price floor histogram and inference settings. Prices are bucketed on a log scale, each bucket
+// 5% wider than the last from a cent CPM, so histograms stay small and merge exactly.
+const (
+	priceBucketBase   = 0.01
+	priceBucketGrowth = 1.05
+	// floorQuantile is the share of clearing prices below the inferred floor, leaving out the few
+	// that clear under it, such as deals with their own floors
+	floorQuantile = 0.02
+	// minFloorWins is the fewest priced wins a domain or exchange needs for its floor to be inferred
+	minFloorWins = 30
+	// nearFloorBuckets is how many buckets under the floor a lost bid counts as just under it, about 10%
+	nearFloorBuckets = 2
+	// minNearFloorBids and minNearFloorShare are how many lost bids, and what share of them in
+	// percent, must sit just under the floor for a bid adjustment to be suggested
+	minNearFloorBids  = 10
+	minNearFloorShare = 20.0
+)
+
+// PriceFloorMetrics holds histograms of a domain's or exchange's prices: the clearing CPMs of
+// its wins and the bid CPMs of its lost bids, by price bucket
+type PriceFloorMetrics struct {
+	ClearingPrices map[int]int `json:"clearingPrices"`
+	LostBids       map[int]int `json:"lostBids"`
+}
+
+// PriceFloorSummary holds price histograms by domain and exchange, from which a report infers
+// the floors they sell above
+type PriceFloorSummary struct {
+	Domains   map[string]*PriceFloorMetrics `json:"domains"`
+	Exchanges map[string]*PriceFloorMetrics `json:"exchanges"`
+}
+
+// PriceFloorEntry is the inferred floor of a domain or exchange and how close lost bids came to it.
+// Prices are CPMs.
+type PriceFloorEntry struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	Wins      int    `json:"wins"`
+	LostBids  int    `json:"lostBids"`
+	// InferredFloor is where clearing prices start, their 2nd percentile
+	InferredFloor float64 `json:"inferredFloor"`
+	// BidsJustUnder counts lost bids within about 10% under the floor, and ShareJustUnder their
+	// share of lost bids, in percent
+	BidsJustUnder  int     `json:"bidsJustUnder"`
+	ShareJustUnder float64 `json:"shareJustUnder"`
+	// SuggestedBid is the CPM to bid to clear the floor, with a suggestion to raise bids to it,
+	// when enough lost bids sat just under the floor
+	SuggestedBid float64 `json:"suggestedBid,omitempty"`
+	Suggestion   string  `json:"suggestion,omitempty"`
+}
+
+// PriceFloorReport is the price floor analysis for a processed file. Domains and exchanges
+// without enough priced wins to infer a floor are left out.
+type PriceFloorReport struct {
+	FileID    string            `json:"fileId"`
+	Exchanges []PriceFloorEntry `json:"exchanges"`
+	Domains   []PriceFloorEntry `json:"domains"`
+	// Adjustments are the entries with a suggested bid, most bids just under the floor first
+	Adjustments []PriceFloorEntry `json:"adjustments"`
+}
+
+func newPriceFloorSummary() *PriceFloorSummary {
+	return &PriceFloorSummary{
+		Domains:   make(map[string]*PriceFloorMetrics),
+		Exchanges: make(map[string]*PriceFloorMetrics),
+	}
+}
+
+// add accumulates a record's clearing price when it won, or its bid when it lost
+func (s *PriceFloorSummary) add(record *BeeswaxLogRecord) {
+	won := record.Won()
+	micros := record.BidPriceMicrosUSD
+	if won {
+		micros = record.ClearingPriceMicrosUSD
+	}
+	if micros <= 0 {
+		return
+	}
+
+	bucket := priceBucket(float64(micros) / cpmToMicros)
+	exchange := record.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	priceFloorMetrics(s.Exchanges, exchange).add(won, bucket)
+	if record.Domain != "" {
+		priceFloorMetrics(s.Domains, record.Domain).add(won, bucket)
+	}
+}
+
+// merge accumulates another file's histograms
+func (s *PriceFloorSummary) merge(other *PriceFloorSummary) {
+	for domain, metrics := range other.Domains {
+		priceFloorMetrics(s.Domains, domain).merge(metrics)
+	}
+	for exchange, metrics := range other.Exchanges {
+		priceFloorMetrics(s.Exchanges, exchange).merge(metrics)
+	}
+}
+
+// add counts a price in the clearing price histogram when it won, or else the lost bid histogram
+func (m *PriceFloorMetrics) add(won bool, bucket int) {
+	if won {
+		m.ClearingPrices[bucket]++
+	} else {
+		m.LostBids[bucket]++
+	}
+}
+
+// merge accumulates another slice's histograms
+func (m *PriceFloorMetrics) merge(other *PriceFloorMetrics) {
+	for bucket, count := range other.ClearingPrices {
+		m.ClearingPrices[bucket] += count
+	}
+	for bucket, count := range other.LostBids {
+		m.LostBids[bucket] += count
+	}
+}
+
+// priceFloorMetrics returns the metrics for a key, creating them if needed
+func priceFloorMetrics(metrics map[string]*PriceFloorMetrics, key string) *PriceFloorMetrics {
+	entry, ok := metrics[key]
+	if !ok {
+		entry = &PriceFloorMetrics{ClearingPrices: make(map[int]int), LostBids: make(map[int]int)}
+		metrics[key] = entry
+	}
+	return entry
+}
+
+// priceBucket returns the histogram bucket of a CPM, with prices under a cent in the first
+func priceBucket(cpm float64) int {
+	if cpm <= priceBucketBase {
+		return 0
+	}
+	return int(math.Log(cpm/priceBucketBase) / math.Log(priceBucketGrowth))
+}
+
+// priceBucketFloor returns the lowest CPM in a bucket
+func priceBucketFloor(bucket int) float64 {
+	return priceBucketBase * math.Pow(priceBucketGrowth, float64(bucket))
+}
+
+// BuildPriceFloorReport infers the floors of a file's domains and exchanges from where their
+// clearing prices start, and suggests raising bids where lost bids cluster just under them
+func BuildPriceFloorReport(fileID string, summary *PriceFloorSummary) *PriceFloorReport {
+	report := &PriceFloorReport{
+		FileID:      fileID,
+		Exchanges:   []PriceFloorEntry{},
+		Domains:     []PriceFloorEntry{},
+		Adjustments: []PriceFloorEntry{},
+	}
+
+	for _, dimension := range []struct {
+		name    string
+		metrics map[string]*PriceFloorMetrics
+		entries *[]PriceFloorEntry
+	}{
+		{PriceFloorByExchange, summary.Exchanges, &report.Exchanges},
+		{PriceFloorByDomain, summary.Domains, &report.Domains},
+	} {
+		for value, metrics := range dimension.metrics {
+			entry, ok := inferPriceFloor(dimension.name, value, metrics)
+			if !ok {
+				continue
+			}
+			*dimension.entries = append(*dimension.entries, entry)
+			if entry.Suggestion != "" {
+				report.Adjustments = append(report.Adjustments, entry)
+			}
+		}
+	}
+
+	for _, entries := range [][]PriceFloorEntry{report.Exchanges, report.Domains, report.Adjustments} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].BidsJustUnder != entries[j].BidsJustUnder {
+				return entries[i].BidsJustUnder > entries[j].BidsJustUnder
+			}
+			if entries[i].Wins != entries[j].Wins {
+				return entries[i].Wins > entries[j].Wins
+			}
+			if entries[i].Dimension != entries[j].Dimension {
+				return entries[i].Dimension < entries[j].Dimension
+			}
+			return entries[i].Value < entries[j].Value
+		})
+	}
+
+	return report
+}
+
+// inferPriceFloor infers a slice's floor and counts the lost bids just under it, reporting false
+// when it has too few priced wins
+func inferPriceFloor(dimension, value string, metrics *PriceFloorMetrics) (PriceFloorEntry, bool) {
+	entry := PriceFloorEntry{Dimension: dimension, Value: value}
+	for _, count := range metrics.ClearingPrices {
+		entry.Wins += count
+	}
+	for _, count := range metrics.LostBids {
+		entry.LostBids += count
+	}
+	if entry.Wins < minFloorWins {
+		return entry, false
+	}
+
+	buckets := make([]int, 0, len(metrics.ClearingPrices))
+	for bucket := range metrics.ClearingPrices {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	floorBucket, seen := buckets[len(buckets)-1], 0
+	for _, bucket := range buckets {
+		seen += metrics.ClearingPrices[bucket]
+		if float64(seen) > floorQuantile*float64(entry.Wins) {
+			floorBucket = bucket
+			break
+		}
+	}
+	entry.InferredFloor = math.Round(priceBucketFloor(floorBucket)*100) / 100
+
+	for bucket := floorBucket - nearFloorBuckets; bucket < floorBucket; bucket++ {
+		entry.BidsJustUnder += metrics.LostBids[bucket]
+	}
+	entry.ShareJustUnder = rate(entry.BidsJustUnder, entry.LostBids)
+
+	if entry.BidsJustUnder >= minNearFloorBids && entry.ShareJustUnder >= minNearFloorShare {
+		entry.SuggestedBid = math.Ceil(priceBucketFloor(floorBucket+1)*100) / 100
+		entry.Suggestion = fmt.Sprintf("Raise bids on %s %s to %.2f CPM: %d lost bids (%.0f%% of losses) were within 10%% under its inferred %.2f floor",
+			dimension, value, entry.SuggestedBid, entry.BidsJustUnder, entry.ShareJustUnder, entry.InferredFloor)
+	}
+
+	return entry, true
+}
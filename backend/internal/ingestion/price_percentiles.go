@@ -0,0 +1,106 @@
+package ingestion
+
+// PriceDistribution holds the distributions of the prices in a file: the bid price of every
+// bid, the clearing price of won bids that carry one, and the CPM paid for each impression
+type PriceDistribution struct {
+	BidPrice      *PriceQuantiles `json:"bidPrice"`
+	ClearingPrice *PriceQuantiles `json:"clearingPrice"`
+	CPM           *PriceQuantiles `json:"cpm"`
+}
+
+// PricePercentiles summarizes a price distribution, in dollars
+type PricePercentiles struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	P25   float64 `json:"p25"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+// PriceQuantiles is a price distribution's percentiles with the digest they are estimated
+// from, kept so distributions can be merged across files
+type PriceQuantiles struct {
+	PricePercentiles
+	Digest *TDigest `json:"digest"`
+}
+
+// PriceReport is the price percentiles analysis for a processed file
+type PriceReport struct {
+	FileID        string           `json:"fileId"`
+	BidPrice      PricePercentiles `json:"bidPrice"`
+	ClearingPrice PricePercentiles `json:"clearingPrice"`
+	CPM           PricePercentiles `json:"cpm"`
+}
+
+func newPriceDistribution() *PriceDistribution {
+	return &PriceDistribution{
+		BidPrice:      newPriceQuantiles(),
+		ClearingPrice: newPriceQuantiles(),
+		CPM:           newPriceQuantiles(),
+	}
+}
+
+func newPriceQuantiles() *PriceQuantiles {
+	return &PriceQuantiles{Digest: NewTDigest()}
+}
+
+// add accumulates a record's prices; records without a price leave that distribution alone
+func (d *PriceDistribution) add(record *BeeswaxLogRecord) {
+	if record.BidPriceMicrosUSD > 0 {
+		d.BidPrice.Digest.Add(float64(record.BidPriceMicrosUSD) / 1000000)
+	}
+	if record.ClearingPriceMicrosUSD > 0 {
+		d.ClearingPrice.Digest.Add(float64(record.ClearingPriceMicrosUSD) / 1000000)
+	}
+	// Win cost is per impression, so the CPM is a thousand times it
+	if record.WinCostMicrosUSD > 0 {
+		d.CPM.Digest.Add(float64(record.WinCostMicrosUSD) / 1000)
+	}
+}
+
+// merge accumulates another file's distributions; percentiles must be recalculated afterwards
+func (d *PriceDistribution) merge(other *PriceDistribution) {
+	d.BidPrice.merge(other.BidPrice)
+	d.ClearingPrice.merge(other.ClearingPrice)
+	d.CPM.merge(other.CPM)
+}
+
+// calculateRates estimates the percentiles of each distribution from its digest
+func (d *PriceDistribution) calculateRates() {
+	d.BidPrice.calculateRates()
+	d.ClearingPrice.calculateRates()
+	d.CPM.calculateRates()
+}
+
+// merge folds another distribution's digest into this one's
+func (q *PriceQuantiles) merge(other *PriceQuantiles) {
+	if other == nil || other.Digest == nil {
+		return
+	}
+	q.Digest.Merge(other.Digest)
+}
+
+// calculateRates estimates the distribution's percentiles from its digest
+func (q *PriceQuantiles) calculateRates() {
+	q.PricePercentiles = PricePercentiles{
+		Count: q.Digest.Count(),
+		Min:   q.Digest.Min(),
+		P25:   q.Digest.Quantile(0.25),
+		P50:   q.Digest.Quantile(0.50),
+		P75:   q.Digest.Quantile(0.75),
+		P95:   q.Digest.Quantile(0.95),
+		Max:   q.Digest.Max(),
+	}
+}
+
+// BuildPriceReport builds the price percentiles report from a file's summary
+func BuildPriceReport(fileID string, prices *PriceDistribution) *PriceReport {
+	return &PriceReport{
+		FileID:        fileID,
+		BidPrice:      prices.BidPrice.PricePercentiles,
+		ClearingPrice: prices.ClearingPrice.PricePercentiles,
+		CPM:           prices.CPM.PricePercentiles,
+	}
+}
@@ -0,0 +1,63 @@
+package ingestion
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// Progress is how far parsing a file has got. The total number of rows isn't known until the
+// end, so it is estimated from the share of the file's bytes read so far.
+type Progress struct {
+	Rows       int64
+	BytesRead  int64
+	TotalBytes int64
+	// ResumedBytes are the bytes parsed by an earlier run that this one resumed from
+	ResumedBytes int64
+}
+
+// Fraction estimates the share of the file parsed, between 0 and 1
+func (p Progress) Fraction() float64 {
+	if p.TotalBytes <= 0 {
+		return 0
+	}
+	return min(float64(p.BytesRead)/float64(p.TotalBytes), 1)
+}
+
+// EstimatedRows extrapolates the file's total rows from the rows parsed per byte read so far
+func (p Progress) EstimatedRows() int64 {
+	fraction := p.Fraction()
+	if fraction == 0 {
+		return 0
+	}
+	return max(int64(float64(p.Rows)/fraction), p.Rows)
+}
+
+// progressKey is the context key of a progress callback
+type progressKey struct{}
+
+// WithProgress returns a context whose file processing reports its progress to fn every few
+// thousand rows. fn is called on the parsing goroutine, so it must return quickly.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFromContext returns the progress callback set by WithProgress, if any
+func progressFromContext(ctx context.Context) func(Progress) {
+	fn, _ := ctx.Value(progressKey{}).(func(Progress))
+	return fn
+}
+
+// countingReader counts the bytes read through it; parse pipelines read on another goroutine,
+// so the count is atomic
+type countingReader struct {
+	reader io.Reader
+	n      atomic.Int64
+}
+
+// Read reads from the underlying reader, counting the bytes read
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
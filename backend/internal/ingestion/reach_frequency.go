@@ -0,0 +1,266 @@
+package ingestion
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxFrequencyBucket is the frequency at which the distribution histogram is capped ("10+")
+const maxFrequencyBucket = 10
+
+// ReachFrequencyMetrics contains unique reach and frequency metrics for a campaign, counting
+// only impressions that identify their user
+type ReachFrequencyMetrics struct {
+	Impressions           int            `json:"impressions"`
+	Reach                 int64          `json:"reach"`
+	AverageFrequency      float64        `json:"averageFrequency"`
+	FrequencyDistribution map[string]int `json:"frequencyDistribution"`
+	// DailySketches estimate each day's users so reach can be merged across files and dates.
+	// They are stored with the summary but left out of what clients are sent.
+	DailySketches map[string]*HyperLogLog `json:"dailySketches,omitempty"`
+	// DailyImpressions are the day's impressions that identify their user; files processed
+	// before it was kept don't have it
+	DailyImpressions map[string]int `json:"dailyImpressions,omitempty"`
+	// FrequencyCurve is delivery by frequency, from 1 to the capped bucket; files processed
+	// before it was kept don't have it
+	FrequencyCurve []FrequencyPoint `json:"frequencyCurve,omitempty"`
+}
+
+// FrequencyPoint is a campaign's delivery at one impression frequency: the users who saw the
+// campaign that many times, and the impressions that were users' nth with what they converted
+type FrequencyPoint struct {
+	Frequency string `json:"frequency"`
+	// Users saw the campaign this many times, and ConvertingUsers of them converted
+	Users           int `json:"users"`
+	ConvertingUsers int `json:"convertingUsers"`
+	// Impressions were users' nth impression of the campaign, costing Spend and bringing in
+	// Conversions
+	Impressions int     `json:"impressions"`
+	Conversions int     `json:"conversions"`
+	Spend       float64 `json:"spend"`
+}
+
+// ReachFrequencyReport is a campaign's reach and frequency across files for a date range.
+// Impressions count those that identify their user, the same ones reach is estimated from.
+type ReachFrequencyReport struct {
+	CampaignID       string     `json:"campaignId"`
+	From             *time.Time `json:"from,omitempty"`
+	To               *time.Time `json:"to,omitempty"`
+	FileIDs          []string   `json:"fileIds"`
+	Impressions      int        `json:"impressions"`
+	Reach            int64      `json:"reach"`
+	AverageFrequency float64    `json:"averageFrequency"`
+	// PerFileFrequencyDistribution counts users by how often they saw the campaign within each
+	// file, summed over the files; a user seen in several files counts once in each. Files that
+	// also delivered outside the range can't be split by day, so they're left out of it and
+	// listed in PartialFileIDs.
+	PerFileFrequencyDistribution map[string]int `json:"perFileFrequencyDistribution"`
+	PartialFileIDs               []string       `json:"partialFileIds"`
+}
+
+// reachFrequencyAccumulator tracks per-user impression counts and daily reach sketches while parsing
+type reachFrequencyAccumulator struct {
+	userCounts map[string]map[string]int
+	sketches   map[string]map[string]*HyperLogLog
+	daily      map[string]map[string]int
+	// converters are the users with conversions, by campaign, and ranks the delivery of users'
+	// nth impressions, with the last rank holding every impression past it
+	converters map[string]map[string]bool
+	ranks      map[string]*[maxFrequencyBucket]FrequencyPoint
+}
+
+func newReachFrequencyAccumulator() *reachFrequencyAccumulator {
+	return &reachFrequencyAccumulator{
+		userCounts: make(map[string]map[string]int),
+		sketches:   make(map[string]map[string]*HyperLogLog),
+		daily:      make(map[string]map[string]int),
+		converters: make(map[string]map[string]bool),
+		ranks:      make(map[string]*[maxFrequencyBucket]FrequencyPoint),
+	}
+}
+
+// add records an impression for a user within a campaign on the given day (YYYY-MM-DD, may be
+// empty), with what it cost and converted. Impressions are ranked in the order they're read,
+// which is the order they were served in a log sorted by time.
+func (a *reachFrequencyAccumulator) add(campaignID, userID, dayKey string, conversions int, spend float64) {
+	counts, ok := a.userCounts[campaignID]
+	if !ok {
+		counts = make(map[string]int)
+		a.userCounts[campaignID] = counts
+	}
+	counts[userID]++
+
+	ranks, ok := a.ranks[campaignID]
+	if !ok {
+		ranks = &[maxFrequencyBucket]FrequencyPoint{}
+		a.ranks[campaignID] = ranks
+	}
+	rank := &ranks[min(counts[userID], maxFrequencyBucket)-1]
+	rank.Impressions++
+	rank.Conversions += conversions
+	rank.Spend += spend
+	if conversions > 0 {
+		if a.converters[campaignID] == nil {
+			a.converters[campaignID] = make(map[string]bool)
+		}
+		a.converters[campaignID][userID] = true
+	}
+
+	if dayKey == "" {
+		return
+	}
+	days, ok := a.sketches[campaignID]
+	if !ok {
+		days = make(map[string]*HyperLogLog)
+		a.sketches[campaignID] = days
+	}
+	sketch, ok := days[dayKey]
+	if !ok {
+		sketch = NewHyperLogLog()
+		days[dayKey] = sketch
+	}
+	sketch.Add(userID)
+
+	if a.daily[campaignID] == nil {
+		a.daily[campaignID] = make(map[string]int)
+	}
+	a.daily[campaignID][dayKey]++
+}
+
+// metrics builds the per-campaign reach and frequency section of the summary
+func (a *reachFrequencyAccumulator) metrics() map[string]*ReachFrequencyMetrics {
+	result := make(map[string]*ReachFrequencyMetrics, len(a.userCounts))
+	for campaignID, counts := range a.userCounts {
+		metrics := &ReachFrequencyMetrics{
+			Reach:                 int64(len(counts)),
+			FrequencyDistribution: make(map[string]int),
+			DailySketches:         a.sketches[campaignID],
+			DailyImpressions:      a.daily[campaignID],
+		}
+		if metrics.DailySketches == nil {
+			metrics.DailySketches = make(map[string]*HyperLogLog)
+			metrics.DailyImpressions = make(map[string]int)
+		}
+
+		curve := *a.ranks[campaignID]
+		for userID, count := range counts {
+			metrics.Impressions += count
+			metrics.FrequencyDistribution[frequencyBucket(count)]++
+
+			point := &curve[min(count, maxFrequencyBucket)-1]
+			point.Users++
+			if a.converters[campaignID][userID] {
+				point.ConvertingUsers++
+			}
+		}
+		for i := range curve {
+			curve[i].Frequency = frequencyBucket(i + 1)
+		}
+		metrics.FrequencyCurve = curve[:]
+		if metrics.Reach > 0 {
+			metrics.AverageFrequency = float64(metrics.Impressions) / float64(metrics.Reach)
+		}
+
+		result[campaignID] = metrics
+	}
+	return result
+}
+
+// frequencyBucket returns the histogram bucket label for an impression count
+func frequencyBucket(count int) string {
+	if count >= maxFrequencyBucket {
+		return strconv.Itoa(maxFrequencyBucket) + "+"
+	}
+	return strconv.Itoa(count)
+}
+
+// ComputeReachFrequency merges a campaign's daily reach sketches and user-identified
+// impressions across the given analysis results for the optional date range. The per-file
+// frequency distribution is exact within each file and summed across the files that delivered
+// only within the range.
+func ComputeReachFrequency(results []*LogAnalysisResult, campaignID string, from, to *time.Time) (*ReachFrequencyReport, error) {
+	report := &ReachFrequencyReport{
+		CampaignID:                   campaignID,
+		From:                         from,
+		To:                           to,
+		FileIDs:                      []string{},
+		PerFileFrequencyDistribution: make(map[string]int),
+		PartialFileIDs:               []string{},
+	}
+
+	merged := NewHyperLogLog()
+	for _, result := range results {
+		if result.Status != "completed" {
+			continue
+		}
+
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return nil, err
+		}
+
+		metrics, ok := summary.ReachFrequency[campaignID]
+		if !ok {
+			continue
+		}
+
+		contributed, partial := false, false
+		for dayKey, sketch := range metrics.DailySketches {
+			if !withinFlight(dayKey, from, to) {
+				partial = true
+				continue
+			}
+			merged.Merge(sketch)
+			if metrics.DailyImpressions != nil {
+				report.Impressions += metrics.DailyImpressions[dayKey]
+			} else {
+				// Files processed before user-identified impressions were kept by day fall
+				// back to all of the day's impressions
+				report.Impressions += summary.CampaignDaily[campaignID][dayKey].Impressions
+			}
+			contributed = true
+		}
+
+		if !contributed {
+			continue
+		}
+		report.FileIDs = append(report.FileIDs, result.FileID)
+		if partial {
+			report.PartialFileIDs = append(report.PartialFileIDs, result.FileID)
+			continue
+		}
+		for bucket, users := range metrics.FrequencyDistribution {
+			report.PerFileFrequencyDistribution[bucket] += users
+		}
+	}
+
+	report.Reach = merged.Count()
+	if report.Reach > 0 {
+		report.AverageFrequency = float64(report.Impressions) / float64(report.Reach)
+	}
+	sort.Strings(report.FileIDs)
+	sort.Strings(report.PartialFileIDs)
+
+	return report, nil
+}
+
+// mergeFrequencyCurves sums two frequency curves point by point. Users are counted per file, so
+// a user seen in both files counts at their frequency in each.
+func mergeFrequencyCurves(dst, src []FrequencyPoint) []FrequencyPoint {
+	if len(src) == 0 {
+		return dst
+	}
+	if len(dst) == 0 {
+		return slices.Clone(src)
+	}
+	for i := range min(len(dst), len(src)) {
+		dst[i].Users += src[i].Users
+		dst[i].ConvertingUsers += src[i].ConvertingUsers
+		dst[i].Impressions += src[i].Impressions
+		dst[i].Conversions += src[i].Conversions
+		dst[i].Spend += src[i].Spend
+	}
+	return dst
+}
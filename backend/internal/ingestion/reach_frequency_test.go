@@ -0,0 +1,160 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reachTestResult parses impressions of campaign 1, given as "user day" pairs with an empty
+// user for impressions that don't identify one, into a completed analysis result
+func reachTestResult(t *testing.T, fileID string, impressions ...string) *LogAnalysisResult {
+	t.Helper()
+
+	log := []string{"ACCOUNT_ID,AUCTION_ID,CAMPAIGN_ID,CREATIVE_ID,USER_ID,BID_TIME,IMPRESSION_TIME,BID_PRICE_MICROS_USD," +
+		"CLEARING_PRICE_MICROS_USD,WIN_COST_MICROS_USD,CLICKS,CONVERSIONS,DOMAIN,GEO_COUNTRY,GEO_CITY," +
+		"PLATFORM_DEVICE_TYPE,PLATFORM_BROWSER,PLATFORM_OS"}
+	for i, impression := range impressions {
+		user, day, _ := strings.Cut(impression, " ")
+		log = append(log, fmt.Sprintf("1,%s-%d,1,1,%s,%s 10:00:00.000,%s 10:00:01.000,2000,900,1000,0,0,example.com,US,Boston,desktop,Chrome,macOS",
+			fileID, i, user, day, day))
+	}
+	summary, err := ParseBeeswaxLog(strings.NewReader(strings.Join(log, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("ParseBeeswaxLog: %v", err)
+	}
+
+	// Round-trip the summary as stored results are
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatalf("failed to encode summary: %v", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+	return &LogAnalysisResult{FileID: fileID, Status: "completed", Summary: stored}
+}
+
+func reachTestDate(day string) *time.Time {
+	date, _ := time.Parse("2006-01-02", day)
+	return &date
+}
+
+func TestComputeReachFrequency(t *testing.T) {
+	results := []*LogAnalysisResult{
+		// u1 twice on the 1st and once on the 2nd, u2 once, and two impressions without a user
+		reachTestResult(t, "file-a", "u1 2026-03-01", "u1 2026-03-01", "u2 2026-03-01", " 2026-03-01", "u1 2026-03-02", " 2026-03-02"),
+		// u1 again, in another file
+		reachTestResult(t, "file-b", "u1 2026-03-03", "u3 2026-03-03"),
+	}
+
+	tests := []struct {
+		name             string
+		from, to         *time.Time
+		fileIDs          []string
+		partialFileIDs   []string
+		impressions      int
+		reach            int64
+		perFileFrequency map[string]int
+	}{
+		{
+			name:             "all dates",
+			fileIDs:          []string{"file-a", "file-b"},
+			partialFileIDs:   []string{},
+			impressions:      6,
+			reach:            3,
+			perFileFrequency: map[string]int{"1": 3, "3": 1},
+		},
+		{
+			name:             "first file's dates",
+			from:             reachTestDate("2026-03-01"),
+			to:               reachTestDate("2026-03-02"),
+			fileIDs:          []string{"file-a"},
+			partialFileIDs:   []string{},
+			impressions:      4,
+			reach:            2,
+			perFileFrequency: map[string]int{"1": 1, "3": 1},
+		},
+		{
+			name:             "part of the first file",
+			from:             reachTestDate("2026-03-02"),
+			to:               reachTestDate("2026-03-03"),
+			fileIDs:          []string{"file-a", "file-b"},
+			partialFileIDs:   []string{"file-a"},
+			impressions:      3,
+			reach:            2,
+			perFileFrequency: map[string]int{"1": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ComputeReachFrequency(results, "1", tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("ComputeReachFrequency: %v", err)
+			}
+
+			if !slices.Equal(report.FileIDs, tt.fileIDs) {
+				t.Errorf("file IDs = %v, want %v", report.FileIDs, tt.fileIDs)
+			}
+			if !slices.Equal(report.PartialFileIDs, tt.partialFileIDs) {
+				t.Errorf("partial file IDs = %v, want %v", report.PartialFileIDs, tt.partialFileIDs)
+			}
+			if report.Impressions != tt.impressions {
+				t.Errorf("impressions = %d, want %d", report.Impressions, tt.impressions)
+			}
+			if report.Reach != tt.reach {
+				t.Errorf("reach = %d, want %d", report.Reach, tt.reach)
+			}
+			if len(report.PerFileFrequencyDistribution) != len(tt.perFileFrequency) {
+				t.Errorf("per-file frequency distribution = %v, want %v", report.PerFileFrequencyDistribution, tt.perFileFrequency)
+			}
+			for bucket, users := range tt.perFileFrequency {
+				if report.PerFileFrequencyDistribution[bucket] != users {
+					t.Errorf("per-file frequency distribution = %v, want %v", report.PerFileFrequencyDistribution, tt.perFileFrequency)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWithoutSketches(t *testing.T) {
+	result := reachTestResult(t, "file-a", "u1 2026-03-01", "u2 2026-03-02")
+
+	data, err := json.Marshal(result.WithoutSketches())
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	if strings.Contains(string(data), "dailySketches") {
+		t.Error("result sent to clients has daily sketches")
+	}
+	if !strings.Contains(string(data), `"reach":2`) {
+		t.Errorf("result sent to clients lost its reach: %s", data)
+	}
+
+	// The stored result keeps its sketches
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		t.Fatalf("BeeswaxSummary: %v", err)
+	}
+	if len(summary.ReachFrequency["1"].DailySketches) != 2 {
+		t.Errorf("stored result has %d daily sketches, want 2", len(summary.ReachFrequency["1"].DailySketches))
+	}
+
+	typed := &LogAnalysisResult{Summary: summary}
+	data, err = json.Marshal(typed.WithoutSketches())
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	if strings.Contains(string(data), "dailySketches") {
+		t.Error("parsed result sent to clients has daily sketches")
+	}
+	if len(summary.ReachFrequency["1"].DailySketches) != 2 {
+		t.Error("stripping a parsed result's sketches changed the result")
+	}
+}
@@ -0,0 +1,121 @@
+package ingestion
+
+import (
+	"sort"
+	"time"
+)
+
+// RealtimeWindows are the trailing windows, in minutes, live metrics are reported over
+var RealtimeWindows = []int{1, 5, 60}
+
+// RealtimeRetention is how long per-minute metrics are kept; it covers the longest window
+const RealtimeRetention = 60 * time.Minute
+
+// RealtimeMinute is a campaign's streamed delivery in one minute of bid time, or every campaign's in a
+// snapshot's series
+type RealtimeMinute struct {
+	CampaignID string    `json:"campaignId,omitempty"`
+	Minute     time.Time `json:"minute"`
+	CampaignMetrics
+}
+
+// RealtimeWindow is delivery over the trailing minutes, counting the current partial minute
+type RealtimeWindow struct {
+	Minutes int `json:"minutes"`
+	CampaignMetrics
+}
+
+// RealtimeCampaign is one campaign's delivery over each window
+type RealtimeCampaign struct {
+	CampaignID string           `json:"campaignId"`
+	Windows    []RealtimeWindow `json:"windows"`
+}
+
+// RealtimeSnapshot is a user's live delivery: totals and per-campaign metrics over each window,
+// and the total of every minute of the longest one for charting
+type RealtimeSnapshot struct {
+	AsOf      time.Time          `json:"asOf"`
+	Windows   []RealtimeWindow   `json:"windows"`
+	Campaigns []RealtimeCampaign `json:"campaigns"`
+	Series    []RealtimeMinute   `json:"series"`
+}
+
+// NewRealtimeSnapshot sums per-minute metrics into windows ending at now. Campaigns are ordered by
+// spend over the longest window and limited to the first limit when it is positive.
+func NewRealtimeSnapshot(minutes []RealtimeMinute, now time.Time, limit int) *RealtimeSnapshot {
+	current := now.UTC().Truncate(time.Minute)
+	longest := RealtimeWindows[len(RealtimeWindows)-1]
+
+	snapshot := &RealtimeSnapshot{
+		AsOf:      now.UTC(),
+		Windows:   newRealtimeWindows(),
+		Campaigns: []RealtimeCampaign{},
+		Series:    []RealtimeMinute{},
+	}
+	campaigns := make(map[string][]RealtimeWindow)
+	series := make(map[time.Time]*CampaignMetrics)
+	for _, minute := range minutes {
+		age := int(current.Sub(minute.Minute.UTC()) / time.Minute)
+		if age < 0 || age >= longest {
+			continue
+		}
+
+		windows, ok := campaigns[minute.CampaignID]
+		if !ok {
+			windows = newRealtimeWindows()
+			campaigns[minute.CampaignID] = windows
+		}
+		for i, size := range RealtimeWindows {
+			if age < size {
+				snapshot.Windows[i].merge(minute.CampaignMetrics)
+				windows[i].merge(minute.CampaignMetrics)
+			}
+		}
+
+		point, ok := series[minute.Minute.UTC()]
+		if !ok {
+			point = &CampaignMetrics{}
+			series[minute.Minute.UTC()] = point
+		}
+		point.merge(minute.CampaignMetrics)
+	}
+
+	for i := range snapshot.Windows {
+		snapshot.Windows[i].calculateRates()
+	}
+	for id, windows := range campaigns {
+		for i := range windows {
+			windows[i].calculateRates()
+		}
+		snapshot.Campaigns = append(snapshot.Campaigns, RealtimeCampaign{CampaignID: id, Windows: windows})
+	}
+	sort.Slice(snapshot.Campaigns, func(i, j int) bool {
+		a, b := snapshot.Campaigns[i].Windows[len(RealtimeWindows)-1], snapshot.Campaigns[j].Windows[len(RealtimeWindows)-1]
+		if a.Spend != b.Spend {
+			return a.Spend > b.Spend
+		}
+		return snapshot.Campaigns[i].CampaignID < snapshot.Campaigns[j].CampaignID
+	})
+	if limit > 0 && len(snapshot.Campaigns) > limit {
+		snapshot.Campaigns = snapshot.Campaigns[:limit]
+	}
+
+	for minute, metrics := range series {
+		metrics.calculateRates()
+		snapshot.Series = append(snapshot.Series, RealtimeMinute{Minute: minute, CampaignMetrics: *metrics})
+	}
+	sort.Slice(snapshot.Series, func(i, j int) bool {
+		return snapshot.Series[i].Minute.Before(snapshot.Series[j].Minute)
+	})
+
+	return snapshot
+}
+
+// newRealtimeWindows returns an empty window of each size
+func newRealtimeWindows() []RealtimeWindow {
+	windows := make([]RealtimeWindow, len(RealtimeWindows))
+	for i, size := range RealtimeWindows {
+		windows[i].Minutes = size
+	}
+	return windows
+}
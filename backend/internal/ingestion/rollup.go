@@ -0,0 +1,211 @@
+package ingestion
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Rollup grains
+const (
+	RollupHourly = "hour"
+	RollupDaily  = "day"
+)
+
+// Rollup dimensions
+const (
+	RollupByCampaign = "campaign"
+	RollupByCreative = "creative"
+	RollupByDomain   = "domain"
+	RollupByGeo      = "geo"
+	RollupByDevice   = "device"
+	// RollupTotal has a single value, RollupTotalValue, covering every record
+	RollupTotal = "total"
+)
+
+// RollupTotalValue is the value of the total dimension's rollups
+const RollupTotalValue = "all"
+
+// RollupDimensions lists the dimensions rollups are kept for
+var RollupDimensions = []string{RollupByCampaign, RollupByCreative, RollupByDomain, RollupByGeo, RollupByDevice, RollupTotal}
+
+// Rollup is the pre-aggregated delivery of one dimension value over an hour or a UTC day
+type Rollup struct {
+	Grain     string    `json:"grain"`
+	Dimension string    `json:"dimension"`
+	Value     string    `json:"value"`
+	Bucket    time.Time `json:"bucket"`
+	CampaignMetrics
+	// FileIDs are the files that contributed to the bucket, when rollups are read across files
+	FileIDs []string `json:"-"`
+}
+
+// RollupQuery selects a user's rollups of a dimension at a grain, summed across files
+type RollupQuery struct {
+	UserID    string
+	Dimension string
+	Grain     string
+	// Value selects a single value; empty includes every value
+	Value string
+	// From and To bound the buckets, from inclusive and to exclusive; nil leaves the range open
+	From *time.Time
+	To   *time.Time
+	// AfterBucket and AfterValue continue after the last rollup of a previous page when AfterBucket is set
+	AfterBucket *time.Time
+	AfterValue  string
+	Limit       int
+}
+
+// BreakdownQuery selects a page of one file's full breakdown by a rollup dimension, summed over
+// the file's daily rollups and ordered by spend, or of its hourly totals ordered by hour
+type BreakdownQuery struct {
+	FileID    string
+	UserID    string
+	Dimension string
+	// AfterSpend and AfterValue continue after the last value of a previous page when AfterSpend is set
+	AfterSpend *float64
+	AfterValue string
+	// AfterBucket continues after the last hour of a previous page when it is set
+	AfterBucket *time.Time
+	Limit       int
+}
+
+// RollupSink stores the rollups of a processed file
+type RollupSink interface {
+	// ReplaceRollups replaces the rollups previously stored for a file
+	ReplaceRollups(ctx context.Context, fileID, userID string, rollups []Rollup) error
+}
+
+// rollupKey identifies a rollup bucket while accumulating
+type rollupKey struct {
+	grain     string
+	dimension string
+	value     string
+	bucket    time.Time
+}
+
+// RollupBuilder accumulates records into hourly and daily rollups by campaign, creative, domain,
+// country and device, and in total
+type RollupBuilder struct {
+	buckets map[rollupKey]*CampaignMetrics
+}
+
+// NewRollupBuilder creates an empty rollup builder
+func NewRollupBuilder() *RollupBuilder {
+	return &RollupBuilder{
+		buckets: make(map[rollupKey]*CampaignMetrics),
+	}
+}
+
+// Add accumulates a record into its hour and day for each dimension it has a value for.
+// Records without a bid time can't be placed in a bucket and are skipped.
+func (b *RollupBuilder) Add(record *BeeswaxLogRecord) {
+	if record.BidTime.IsZero() {
+		return
+	}
+
+	metrics := RecordMetrics(record)
+	bidTime := record.BidTime.UTC()
+	hour := bidTime.Truncate(time.Hour)
+	day := time.Date(bidTime.Year(), bidTime.Month(), bidTime.Day(), 0, 0, 0, 0, time.UTC)
+
+	values := [...]struct{ dimension, value string }{
+		{RollupByCampaign, record.CampaignID},
+		{RollupByCreative, record.CreativeID},
+		{RollupByDomain, record.Domain},
+		{RollupByGeo, record.GeoCountry},
+		{RollupByDevice, record.PlatformDeviceType},
+		{RollupTotal, RollupTotalValue},
+	}
+	for _, v := range values {
+		if v.value == "" {
+			continue
+		}
+		b.add(rollupKey{RollupHourly, v.dimension, v.value, hour}, metrics)
+		b.add(rollupKey{RollupDaily, v.dimension, v.value, day}, metrics)
+	}
+}
+
+// RecordMetrics returns a single record's contribution to delivery metrics, without rates
+func RecordMetrics(record *BeeswaxLogRecord) CampaignMetrics {
+	metrics := CampaignMetrics{
+		Bids:        1,
+		Clicks:      record.Clicks,
+		Conversions: record.Conversions,
+		Spend:       float64(record.WinCostMicrosUSD) / 1000000,
+		Revenue:     float64(record.RevenueMicrosUSD) / 1000000,
+	}
+	if record.Won() {
+		metrics.Impressions = 1
+		if record.Measurable {
+			metrics.MeasurableImpressions = 1
+		}
+		if record.Viewable {
+			metrics.ViewableImpressions = 1
+		}
+	}
+	return metrics
+}
+
+// add merges metrics into a bucket
+func (b *RollupBuilder) add(key rollupKey, metrics CampaignMetrics) {
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &CampaignMetrics{}
+		b.buckets[key] = bucket
+	}
+	bucket.merge(metrics)
+}
+
+// addRollups merges rollups built earlier into the builder, such as those of a parse being resumed
+func (b *RollupBuilder) addRollups(rollups []Rollup) {
+	for _, rollup := range rollups {
+		b.add(rollupKey{rollup.Grain, rollup.Dimension, rollup.Value, rollup.Bucket.UTC()}, rollup.CampaignMetrics)
+	}
+}
+
+// Rollups returns the accumulated rollups, ordered by grain, dimension, value and bucket
+func (b *RollupBuilder) Rollups() []Rollup {
+	rollups := make([]Rollup, 0, len(b.buckets))
+	for key, metrics := range b.buckets {
+		metrics.calculateRates()
+		rollups = append(rollups, Rollup{
+			Grain:           key.grain,
+			Dimension:       key.dimension,
+			Value:           key.value,
+			Bucket:          key.bucket,
+			CampaignMetrics: *metrics,
+		})
+	}
+
+	sort.Slice(rollups, func(i, j int) bool {
+		a, b := rollups[i], rollups[j]
+		if a.Grain != b.Grain {
+			return a.Grain < b.Grain
+		}
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		return a.Bucket.Before(b.Bucket)
+	})
+
+	return rollups
+}
+
+// IsRollupGrain reports whether grain is a grain rollups are kept at
+func IsRollupGrain(grain string) bool {
+	return grain == RollupHourly || grain == RollupDaily
+}
+
+// IsRollupDimension reports whether dimension is a dimension rollups are kept for
+func IsRollupDimension(dimension string) bool {
+	for _, d := range RollupDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
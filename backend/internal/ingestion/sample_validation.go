@@ -0,0 +1,270 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+)
+
+// Sample validation limits
+const (
+	// DefaultSampleRows is how many rows a sample is checked against when the caller doesn't say
+	DefaultSampleRows = 100
+	// MaxSampleRows is the most rows a sample is checked against
+	MaxSampleRows = 1000
+	// samplePreviewRows is how many of the checked rows the preview shows
+	samplePreviewRows = 20
+)
+
+// ErrSampleNotCSV is returned when validating a sample of a log that isn't a CSV export; JSON
+// logs have no columns to map
+var ErrSampleNotCSV = errors.New("only CSV logs can be validated")
+
+// logFalseFlagValues are the values a flag column reads as false, besides an empty value
+var logFalseFlagValues = []string{"0", "false", "f", "no", "n"}
+
+// SampleValidation is how the first rows of a CSV log would be parsed, without storing anything
+type SampleValidation struct {
+	FileName string `json:"fileName"`
+	// Source is the log source the header was detected as, or was mapped with when one was named
+	Source string `json:"source,omitempty"`
+	// Valid is whether the file would be processed; Error says why not
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Mapping maps the canonical columns found to the header names they are read from
+	Mapping map[string]string `json:"mapping"`
+	// Unmapped are header columns no canonical column is read from
+	Unmapped []string `json:"unmapped"`
+	// Missing are canonical columns the header doesn't have, so the metrics built on them are empty
+	Missing []string `json:"missing"`
+	// RowsChecked is how many data rows were read; fewer than asked for means the sample ended
+	RowsChecked int             `json:"rowsChecked"`
+	Warnings    []SampleWarning `json:"warnings"`
+	// SchemaDrift is how the columns differ from the previous upload from the source, if they do
+	SchemaDrift *SchemaDrift  `json:"schemaDrift,omitempty"`
+	Preview     SamplePreview `json:"preview"`
+}
+
+// SampleWarning is a problem found in the sample, counted over the rows it occurred on
+type SampleWarning struct {
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+	// Row is the first data row the problem was found on, counting from 1
+	Row int `json:"row,omitempty"`
+	// Value is the offending value on that row
+	Value string `json:"value,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+// SamplePreview is the first rows of the sample as a table. Mapped samples show the canonical
+// columns found; samples whose header didn't map show the header as uploaded.
+type SamplePreview struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// sampleWarnings collects warnings, counting repeats of the same problem in a column
+type sampleWarnings struct {
+	warnings []SampleWarning
+	index    map[string]int
+}
+
+// add records a problem found on a row
+func (w *sampleWarnings) add(column, message string, row int, value string) {
+	if w.index == nil {
+		w.index = make(map[string]int)
+	}
+
+	key := column + "\x00" + message
+	if i, ok := w.index[key]; ok {
+		w.warnings[i].Count++
+		return
+	}
+	w.index[key] = len(w.warnings)
+	w.warnings = append(w.warnings, SampleWarning{Column: column, Message: message, Row: row, Value: value, Count: 1})
+}
+
+// ValidateSample reads the header and up to maxRows rows of a CSV log and reports how they
+// would be mapped and parsed: the detected source, the column mapping with the user's mapping
+// profiles applied, values that wouldn't parse, drift from the previous upload and a preview.
+// Nothing is stored and the rest of the reader isn't read, so callers can send just the head of
+// a large file; a final row cut short is reported like any other short row.
+func (s *LogProcessorService) ValidateSample(ctx context.Context, reader io.Reader, fileName, userID string, opts ParseOptions, maxRows int) (*SampleValidation, error) {
+	if (opts.Parser == "" && strings.ToLower(filepath.Ext(fileName)) != ".csv") || (opts.Parser != "" && opts.Parser != ParserCSV) {
+		return nil, ErrSampleNotCSV
+	}
+	if maxRows <= 0 {
+		maxRows = DefaultSampleRows
+	}
+	maxRows = min(maxRows, MaxSampleRows)
+
+	validation := &SampleValidation{
+		FileName: fileName,
+		Mapping:  make(map[string]string),
+		Unmapped: []string{},
+		Missing:  []string{},
+		Warnings: []SampleWarning{},
+		Preview:  SamplePreview{Rows: [][]string{}},
+	}
+
+	csvReader := csv.NewReader(reader)
+	// Short and long rows are reported rather than failing the sample
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		if err == io.EOF {
+			err = errors.New("file is empty")
+		}
+		validation.Error = fmt.Sprintf("Failed to read header: %v", err)
+		return validation, nil
+	}
+
+	var profiles map[string]*MappingProfile
+	if s.profiles != nil {
+		profiles, err = s.profiles.ListProfiles(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mapping profiles: %w", err)
+		}
+	}
+
+	layout, err := resolveLogLayout(header, opts.Format, profiles)
+	if err != nil {
+		validation.Error = err.Error()
+		validation.Preview.Columns = header
+	} else {
+		validation.Valid = true
+		s.describeSampleLayout(ctx, validation, header, layout, userID)
+	}
+
+	var warnings sampleWarnings
+	for validation.RowsChecked < maxRows {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// The reader carries on with the next line
+			validation.RowsChecked++
+			warnings.add("", fmt.Sprintf("Malformed CSV: %v", parseErr.Err), validation.RowsChecked, "")
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sample: %w", err)
+		}
+		validation.RowsChecked++
+
+		if len(row) != len(header) {
+			warnings.add("", fmt.Sprintf("Row has a different number of fields than the header's %d", len(header)), validation.RowsChecked, strconv.Itoa(len(row)))
+		}
+		if layout == nil {
+			if len(validation.Preview.Rows) < samplePreviewRows {
+				validation.Preview.Rows = append(validation.Preview.Rows, row)
+			}
+			continue
+		}
+
+		values := make([]string, len(layout.fields))
+		for i, column := range layout.fields {
+			// Short rows already have a warning; the fields they lack aren't reported again
+			idx := layout.indexes[i]
+			if idx >= len(row) {
+				continue
+			}
+			values[i] = strings.TrimSpace(row[idx])
+			if message := checkSampleValue(layout, column, values[i]); message != "" {
+				warnings.add(column, message, validation.RowsChecked, values[i])
+			}
+		}
+		if len(validation.Preview.Rows) < samplePreviewRows {
+			validation.Preview.Rows = append(validation.Preview.Rows, values)
+		}
+	}
+	validation.Warnings = append(validation.Warnings, warnings.warnings...)
+
+	if validation.RowsChecked == 0 && validation.Valid {
+		validation.Warnings = append(validation.Warnings, SampleWarning{Message: "File has a header but no rows"})
+	}
+
+	return validation, nil
+}
+
+// describeSampleLayout records how a sample's header mapped and how it drifted from the previous
+// upload from its source. Schema history is context, so failing to load it isn't an error.
+func (s *LogProcessorService) describeSampleLayout(ctx context.Context, validation *SampleValidation, header []string, layout *logLayout, userID string) {
+	validation.Source = layout.format.Source
+	validation.Preview.Columns = layout.fields
+
+	mapped := make(map[int]bool, len(layout.indexes))
+	for i, column := range layout.fields {
+		validation.Mapping[column] = header[layout.indexes[i]]
+		mapped[layout.indexes[i]] = true
+	}
+	for i, name := range header {
+		if !mapped[i] {
+			validation.Unmapped = append(validation.Unmapped, name)
+		}
+	}
+	for _, column := range canonicalColumns {
+		if _, ok := layout.columns[column]; !ok {
+			validation.Missing = append(validation.Missing, column)
+		}
+	}
+
+	if s.schemas == nil {
+		return
+	}
+	previous, err := s.schemas.PreviousSchema(ctx, userID, layout.format.Source, "")
+	if err != nil {
+		errreport.Report(errreport.WithTags(ctx, "userID", userID), "Failed to load previous schema", err)
+		return
+	}
+	if drift := detectSchemaDrift(previous, newFileSchema("", userID, header, layout)); drift != nil {
+		validation.SchemaDrift = drift
+		for _, warning := range drift.Warnings() {
+			validation.Warnings = append(validation.Warnings, SampleWarning{Message: warning})
+		}
+	}
+}
+
+// checkSampleValue returns why a value of a canonical column wouldn't parse as its type, or ""
+// when it would. Empty values are only a problem in required columns.
+func checkSampleValue(layout *logLayout, column, value string) string {
+	if value == "" {
+		if slices.Contains(layout.format.Required, column) {
+			return "Required column is empty"
+		}
+		return ""
+	}
+
+	_, scaled := layout.format.MoneyScale[column]
+	if _, _, valid := parseColumnValue(column, scaled, value); valid {
+		return ""
+	}
+
+	switch canonicalColumnDocs[column].kind {
+	case ColumnTypeTimestamp:
+		return "Timestamp isn't in a supported format, so it is read as empty"
+	case ColumnTypeMoney:
+		if scaled {
+			return "Amount isn't a number, so it is read as 0"
+		}
+		return "Amount isn't a whole number of micros, so it is read as 0"
+	case ColumnTypeInteger:
+		return "Value isn't a whole number, so it is read as 0"
+	case ColumnTypeDecimal:
+		return "Value isn't a number, so it is ignored"
+	case ColumnTypeFlag:
+		return "Flag value isn't recognized, so it is read as false"
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+)
+
+// MappingProfile is a user's column mapping for a log source: header names the source's exports
+// use that its log format doesn't list
+type MappingProfile struct {
+	Source string `json:"source"`
+	// Columns maps header names to the canonical columns they hold
+	Columns map[string]string `json:"columns"`
+	// AutoExtend adds the probable renames schema drift detects to Columns as files are processed
+	AutoExtend bool      `json:"autoExtend"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// aliases returns the profile's header names by canonical column, sorted for a stable choice
+func (p *MappingProfile) aliases() map[string][]string {
+	if p == nil {
+		return nil
+	}
+
+	aliases := make(map[string][]string)
+	for name, canonical := range p.Columns {
+		aliases[canonical] = append(aliases[canonical], name)
+	}
+	for _, names := range aliases {
+		sort.Strings(names)
+	}
+	return aliases
+}
+
+// MappingProfileSource provides users' column mapping profiles
+type MappingProfileSource interface {
+	// ListProfiles returns a user's mapping profiles by source
+	ListProfiles(ctx context.Context, userID string) (map[string]*MappingProfile, error)
+	// ExtendProfile adds header names for canonical columns to a user's profile for a source,
+	// keeping the names already there
+	ExtendProfile(ctx context.Context, userID, source string, columns map[string]string) error
+}
+
+// SchemaHistory records the column sets of users' uploads, so a file can be compared with the
+// previous upload from its source
+type SchemaHistory interface {
+	// PreviousSchema returns the latest schema recorded for the user and source other than the
+	// file's own, or nil if there is none
+	PreviousSchema(ctx context.Context, userID, source, fileID string) (*FileSchema, error)
+	// SaveSchema records a file's schema, replacing the one recorded when it was last processed
+	SaveSchema(ctx context.Context, schema *FileSchema) error
+}
+
+// FileSchema is the header of an uploaded CSV log and how it mapped onto canonical columns
+type FileSchema struct {
+	FileID string `json:"fileId"`
+	UserID string `json:"-"`
+	Source string `json:"source"`
+	// Columns are the header's column names as uploaded
+	Columns []string `json:"columns"`
+	// Mapped maps the canonical columns found to the header names they were read from
+	Mapped map[string]string `json:"mapped"`
+	// Drift is how the columns changed since the previous upload from the source, if they did
+	Drift     *SchemaDrift `json:"drift,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// newFileSchema describes a header resolved into a layout
+func newFileSchema(fileID, userID string, header []string, layout *logLayout) *FileSchema {
+	schema := &FileSchema{
+		FileID:    fileID,
+		UserID:    userID,
+		Source:    layout.format.Source,
+		Columns:   header,
+		Mapped:    make(map[string]string, len(layout.columns)),
+		CreatedAt: time.Now(),
+	}
+	for canonical, idx := range layout.columns {
+		schema.Mapped[canonical] = header[idx]
+	}
+	return schema
+}
+
+// ColumnRename is a canonical column read from a different header name than before
+type ColumnRename struct {
+	Column string `json:"column"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// SchemaDrift is how a file's columns differ from those of the previous upload from its source
+type SchemaDrift struct {
+	Source         string `json:"source"`
+	PreviousFileID string `json:"previousFileId"`
+	// Added and Removed are header columns that appeared or disappeared
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Renamed are canonical columns still found, under another header name
+	Renamed []ColumnRename `json:"renamed,omitempty"`
+	// Missing are canonical columns the previous upload had and this file doesn't, so the
+	// metrics built on them are empty
+	Missing []string `json:"missing,omitempty"`
+	// Suggested are probable new names of missing columns: new columns that took their place
+	Suggested []ColumnRename `json:"suggested,omitempty"`
+	// Extended are suggestions added to the user's mapping profile and applied to this file
+	Extended []ColumnRename `json:"extended,omitempty"`
+}
+
+// detectSchemaDrift compares a file's schema with the previous upload's, returning nil when
+// there is no previous upload or the columns didn't change
+func detectSchemaDrift(previous, current *FileSchema) *SchemaDrift {
+	if previous == nil {
+		return nil
+	}
+
+	drift := &SchemaDrift{Source: current.Source, PreviousFileID: previous.FileID}
+
+	previousColumns := normalizeHeader(previous.Columns)
+	currentColumns := normalizeHeader(current.Columns)
+	added := make(map[string]bool)
+	for _, name := range current.Columns {
+		if _, ok := previousColumns[normalizeColumnName(name)]; !ok {
+			drift.Added = append(drift.Added, name)
+			added[normalizeColumnName(name)] = true
+		}
+	}
+	for _, name := range previous.Columns {
+		if _, ok := currentColumns[normalizeColumnName(name)]; !ok {
+			drift.Removed = append(drift.Removed, name)
+		}
+	}
+
+	mappedNames := make(map[string]bool, len(current.Mapped))
+	for _, name := range current.Mapped {
+		mappedNames[normalizeColumnName(name)] = true
+	}
+
+	for _, canonical := range canonicalColumns {
+		before, hadBefore := previous.Mapped[canonical]
+		now, hasNow := current.Mapped[canonical]
+		switch {
+		case !hadBefore:
+		case hasNow && normalizeColumnName(now) != normalizeColumnName(before):
+			drift.Renamed = append(drift.Renamed, ColumnRename{Column: canonical, From: before, To: now})
+		case !hasNow:
+			drift.Missing = append(drift.Missing, canonical)
+
+			// A new column nothing reads, where the missing one used to be, is probably its new name
+			idx, ok := previousColumns[normalizeColumnName(before)]
+			if !ok || idx >= len(current.Columns) {
+				continue
+			}
+			candidate := current.Columns[idx]
+			if name := normalizeColumnName(candidate); added[name] && !mappedNames[name] {
+				drift.Suggested = append(drift.Suggested, ColumnRename{Column: canonical, From: before, To: candidate})
+			}
+		}
+	}
+
+	if len(drift.Added) == 0 && len(drift.Removed) == 0 && len(drift.Renamed) == 0 && len(drift.Missing) == 0 {
+		return nil
+	}
+	return drift
+}
+
+// Warnings describes the drift for the analysis result, starting with the columns whose metrics are now empty
+func (d *SchemaDrift) Warnings() []string {
+	suggested := make(map[string]string, len(d.Suggested))
+	for _, rename := range d.Suggested {
+		suggested[rename.Column] = rename.To
+	}
+
+	var warnings []string
+	for _, column := range d.Missing {
+		warning := fmt.Sprintf("%s is missing since the previous %s upload, so metrics using it are empty for this file", column, d.Source)
+		if name, ok := suggested[column]; ok {
+			warning += fmt.Sprintf("; it may have been renamed to %q", name)
+		}
+		warnings = append(warnings, warning)
+	}
+	extended := make(map[string]bool, len(d.Extended))
+	for _, rename := range d.Extended {
+		extended[rename.Column] = true
+		warnings = append(warnings, fmt.Sprintf("%q was added to the %s column mapping as %s", rename.To, d.Source, rename.Column))
+	}
+	for _, rename := range d.Renamed {
+		if extended[rename.Column] {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s is now read from %q instead of %q", rename.Column, rename.To, rename.From))
+	}
+	if len(d.Added) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Columns added since the previous %s upload: %s", d.Source, strings.Join(d.Added, ", ")))
+	}
+	if len(d.Removed) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Columns removed since the previous %s upload: %s", d.Source, strings.Join(d.Removed, ", ")))
+	}
+	return warnings
+}
+
+// SetSchemaTracking enables mapping CSV logs with users' mapping profiles and detecting schema
+// drift between uploads from the same source
+func (s *LogProcessorService) SetSchemaTracking(profiles MappingProfileSource, history SchemaHistory) {
+	s.profiles = profiles
+	s.schemas = history
+}
+
+// resolveFileLayout maps a CSV log's header with the user's mapping profiles, then records the
+// file's schema and how it drifted from the previous upload from its source on the result.
+// When the source's profile auto-extends, probable renames are added to it and applied first.
+// Tracking schemas is context, so failures don't fail processing.
+func (s *LogProcessorService) resolveFileLayout(ctx context.Context, header []string, opts ParseOptions, result *LogAnalysisResult) (*logLayout, error) {
+	ctx = errreport.WithTags(ctx, "fileID", result.FileID)
+
+	var profiles map[string]*MappingProfile
+	if s.profiles != nil {
+		var err error
+		profiles, err = s.profiles.ListProfiles(ctx, result.UserID)
+		if err != nil {
+			errreport.Report(ctx, "Failed to load mapping profiles", err)
+		}
+	}
+
+	layout, err := resolveLogLayout(header, opts.Format, profiles)
+	if err != nil {
+		return nil, err
+	}
+	if layout.location, err = opts.location(); err != nil || s.schemas == nil {
+		return layout, err
+	}
+
+	schema := newFileSchema(result.FileID, result.UserID, header, layout)
+	previous, err := s.schemas.PreviousSchema(ctx, result.UserID, schema.Source, result.FileID)
+	if err != nil {
+		errreport.Report(ctx, "Failed to load previous schema", err)
+		return layout, nil
+	}
+	drift := detectSchemaDrift(previous, schema)
+
+	// Map the probable renames before parsing, so this file's metrics don't have the gap
+	if profile := profiles[schema.Source]; drift != nil && len(drift.Suggested) > 0 && profile != nil && profile.AutoExtend {
+		columns := make(map[string]string, len(drift.Suggested))
+		for _, rename := range drift.Suggested {
+			columns[rename.To] = rename.Column
+		}
+		if err := s.profiles.ExtendProfile(ctx, result.UserID, schema.Source, columns); err != nil {
+			errreport.Report(ctx, "Failed to extend mapping profile", err)
+		} else {
+			extended := &MappingProfile{Source: profile.Source, Columns: make(map[string]string, len(profile.Columns)+len(columns)), AutoExtend: true}
+			for name, canonical := range profile.Columns {
+				extended.Columns[name] = canonical
+			}
+			for name, canonical := range columns {
+				if _, exists := extended.Columns[name]; !exists {
+					extended.Columns[name] = canonical
+				}
+			}
+			profiles[schema.Source] = extended
+
+			if extendedLayout, err := resolveLogLayout(header, schema.Source, profiles); err == nil {
+				suggested := drift.Suggested
+				layout = extendedLayout
+				schema = newFileSchema(result.FileID, result.UserID, header, layout)
+				drift = detectSchemaDrift(previous, schema)
+				if drift != nil {
+					drift.Extended = suggested
+				}
+			}
+		}
+	}
+
+	schema.Drift = drift
+	if err := s.schemas.SaveSchema(ctx, schema); err != nil {
+		errreport.Report(ctx, "Failed to record file schema", err)
+	}
+	if drift != nil {
+		result.SchemaDrift = drift
+		result.Warnings = append(result.Warnings, drift.Warnings()...)
+	}
+
+	return layout, nil
+}
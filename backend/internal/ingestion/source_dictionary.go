@@ -0,0 +1,159 @@
+package ingestion
+
+import (
+	"strings"
+	"time"
+)
+
+// Column value types, as the data dictionary reports them
+const (
+	ColumnTypeString    = "string"
+	ColumnTypeTimestamp = "timestamp"
+	ColumnTypeMoney     = "money"
+	ColumnTypeInteger   = "integer"
+	ColumnTypeFlag      = "flag"
+	ColumnTypeDecimal   = "decimal"
+)
+
+// columnDoc documents a canonical column for the data dictionary
+type columnDoc struct {
+	kind        string
+	description string
+}
+
+// canonicalColumnDocs documents every canonical column
+var canonicalColumnDocs = map[string]columnDoc{
+	"ACCOUNT_ID":                {ColumnTypeString, "Advertiser or account the bid was made for"},
+	"AUCTION_ID":                {ColumnTypeString, "Unique ID of the auction or impression"},
+	"CAMPAIGN_ID":               {ColumnTypeString, "Campaign the bid belongs to"},
+	"CREATIVE_ID":               {ColumnTypeString, "Creative that was bid with"},
+	"USER_ID":                   {ColumnTypeString, "User or device ID, used for reach, frequency and journeys"},
+	"BID_TIME":                  {ColumnTypeTimestamp, "When the bid was made, in UTC"},
+	"IMPRESSION_TIME":           {ColumnTypeTimestamp, "When the impression was served, in UTC"},
+	"BID_PRICE_MICROS_USD":      {ColumnTypeMoney, "Bid price"},
+	"CLEARING_PRICE_MICROS_USD": {ColumnTypeMoney, "Price the auction cleared at; a bid with a clearing price was won"},
+	"WIN_COST_MICROS_USD":       {ColumnTypeMoney, "Cost of the won impression, used as spend"},
+	"CLICKS":                    {ColumnTypeInteger, "Clicks on the impression"},
+	"CONVERSIONS":               {ColumnTypeInteger, "Conversions attributed to the impression"},
+	"REVENUE_MICROS_USD":        {ColumnTypeMoney, "Revenue or order value of the conversions, used for ROAS"},
+	"DOMAIN":                    {ColumnTypeString, "Site domain or app bundle the ad ran on"},
+	"AD_POSITION":               {ColumnTypeString, "Position of the ad on the page, such as above the fold"},
+	"GEO_COUNTRY":               {ColumnTypeString, "Country code of the user"},
+	"GEO_REGION":                {ColumnTypeString, "Region or state of the user"},
+	"GEO_CITY":                  {ColumnTypeString, "City of the user"},
+	"GEO_LATITUDE":              {ColumnTypeDecimal, "Latitude of the user"},
+	"GEO_LONGITUDE":             {ColumnTypeDecimal, "Longitude of the user"},
+	"PLATFORM_DEVICE_TYPE":      {ColumnTypeString, "Device type, such as desktop, mobile or CTV"},
+	"PLATFORM_BROWSER":          {ColumnTypeString, "Browser of the user"},
+	"PLATFORM_OS":               {ColumnTypeString, "Operating system of the user"},
+	"VIEWABILITY_MEASURABLE":    {ColumnTypeFlag, "Whether viewability could be measured for the impression"},
+	"VIEWABLE":                  {ColumnTypeFlag, "Whether the impression was viewable"},
+	"EXCHANGE":                  {ColumnTypeString, "Exchange or SSP the bid request came from"},
+	"SELLER_ID":                 {ColumnTypeString, "Seller ID from the exchange's sellers.json"},
+	"SELLER_RELATIONSHIP":       {ColumnTypeString, "DIRECT or RESELLER"},
+	"SCHAIN_HOPS":               {ColumnTypeInteger, "Number of nodes in the supply chain object"},
+	"VIDEO_START":               {ColumnTypeInteger, "Video starts, as a count or a flag"},
+	"VIDEO_FIRST_QUARTILE":      {ColumnTypeInteger, "Videos played to 25%, as a count or a flag"},
+	"VIDEO_MIDPOINT":            {ColumnTypeInteger, "Videos played to 50%, as a count or a flag"},
+	"VIDEO_THIRD_QUARTILE":      {ColumnTypeInteger, "Videos played to 75%, as a count or a flag"},
+	"VIDEO_COMPLETE":            {ColumnTypeInteger, "Videos played to completion, as a count or a flag"},
+	"LOSS_REASON":               {ColumnTypeString, "Why the bid lost, as an OpenRTB loss reason code or the exchange's description"},
+}
+
+// SourceDictionary describes what a log source's CSV export needs to contain
+type SourceDictionary struct {
+	Source  string       `json:"source"`
+	Columns []ColumnSpec `json:"columns"`
+	// TimestampFormats are the formats timestamp columns are accepted in, tried in order
+	TimestampFormats []TimestampFormat `json:"timestampFormats"`
+	// FlagValues are the values flag columns read as true; anything else is false
+	FlagValues []string `json:"flagValues"`
+}
+
+// ColumnSpec describes one canonical column of a source's export
+type ColumnSpec struct {
+	Column string `json:"column"`
+	// HeaderNames are the header names the column is accepted under, in order of preference;
+	// case, spaces and dashes don't matter
+	HeaderNames []string `json:"headerNames"`
+	Required    bool     `json:"required"`
+	Type        string   `json:"type"`
+	// Unit is how money values are expressed in this export
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description"`
+}
+
+// TimestampFormat is an accepted timestamp format, with an example value
+type TimestampFormat struct {
+	Pattern string `json:"pattern"`
+	Example string `json:"example"`
+}
+
+// timestampPattern spells out a Go time layout the way exports document their formats
+var timestampPattern = strings.NewReplacer(
+	"2006", "YYYY", "01", "MM", "02", "DD", "15", "HH", "04", "mm", "05", "ss", ".000", ".SSS", "Z07:00", "Z",
+)
+
+// LogSourceDictionaries describes the registered log sources: the columns each export is read
+// from, which are required, and the timestamp formats accepted. Sources are in registration
+// order and columns in canonical order.
+func LogSourceDictionaries() []SourceDictionary {
+	logFormatsMu.RLock()
+	defer logFormatsMu.RUnlock()
+
+	example := time.Date(2024, 3, 15, 14, 30, 5, 0, time.UTC)
+	timestamps := make([]TimestampFormat, len(logTimeLayouts))
+	for i, layout := range logTimeLayouts {
+		timestamps[i] = TimestampFormat{Pattern: timestampPattern.Replace(layout), Example: example.Format(layout)}
+	}
+
+	dictionaries := make([]SourceDictionary, 0, len(logFormats))
+	for _, format := range logFormats {
+		required := make(map[string]bool, len(format.Required))
+		for _, column := range format.Required {
+			required[column] = true
+		}
+
+		dictionary := SourceDictionary{
+			Source:           format.Source,
+			Columns:          []ColumnSpec{},
+			TimestampFormats: timestamps,
+			FlagValues:       logFlagValues,
+		}
+		for _, column := range canonicalColumns {
+			names := format.Columns[column]
+			if len(names) == 0 {
+				continue
+			}
+			doc := canonicalColumnDocs[column]
+			spec := ColumnSpec{
+				Column:      column,
+				HeaderNames: names,
+				Required:    required[column],
+				Type:        doc.kind,
+				Description: doc.description,
+			}
+			if doc.kind == ColumnTypeMoney {
+				spec.Unit = moneyUnit(format.MoneyScale[column])
+			}
+			dictionary.Columns = append(dictionary.Columns, spec)
+		}
+		dictionaries = append(dictionaries, dictionary)
+	}
+
+	return dictionaries
+}
+
+// moneyUnit describes the unit of a money column with the given scale to micros
+func moneyUnit(scale float64) string {
+	switch scale {
+	case 0:
+		return "integer USD micros per impression"
+	case cpmToMicros:
+		return "USD per thousand impressions (CPM)"
+	case dollarsToMicros:
+		return "USD per impression"
+	default:
+		return "USD, scaled to micros"
+	}
+}
@@ -0,0 +1,218 @@
+package ingestion
+
+// Standard log-level exports from other DSPs. These are impression feeds with one row per won
+// impression, so bid-side columns are often missing and win rates read as 100%; the summary's
+// field coverage shows which canonical columns each export actually filled in.
+
+// cpmToMicros converts a per-thousand price in dollars to the per-impression micros the
+// canonical record uses
+const cpmToMicros = 1000
+
+// dollarsToMicros converts a per-impression amount in dollars to micros
+const dollarsToMicros = 1000000
+
+func init() {
+	RegisterLogFormat(mediaMathFormat)
+	RegisterLogFormat(criteoFormat)
+	RegisterLogFormat(stackAdaptFormat)
+	RegisterLogFormat(tradeDeskFormat)
+	RegisterLogFormat(dv360Format)
+}
+
+// mediaMathFormat is MediaMath's log-level data impression feed, priced in CPM
+var mediaMathFormat = &LogFormat{
+	Source: "mediamath",
+	Columns: map[string][]string{
+		"ACCOUNT_ID":                {"advertiser_id", "organization_id"},
+		"AUCTION_ID":                {"auction_id"},
+		"CAMPAIGN_ID":               {"campaign_id"},
+		"CREATIVE_ID":               {"creative_id", "concept_id"},
+		"USER_ID":                   {"mm_uuid"},
+		"BID_TIME":                  {"timestamp_gmt", "report_timestamp"},
+		"IMPRESSION_TIME":           {"timestamp_gmt", "report_timestamp"},
+		"BID_PRICE_MICROS_USD":      {"bid_price_cpm"},
+		"CLEARING_PRICE_MICROS_USD": {"price_paid_cpm", "media_cost_cpm"},
+		"WIN_COST_MICROS_USD":       {"total_spend_cpm"},
+		"CLICKS":                    {"clicks"},
+		"CONVERSIONS":               {"pv_pc_conversions", "conversions"},
+		"REVENUE_MICROS_USD":        {"pv_pc_revenue", "revenue"},
+		"DOMAIN":                    {"site_url", "domain"},
+		"AD_POSITION":               {"fold_position"},
+		"GEO_COUNTRY":               {"country_code", "country"},
+		"GEO_REGION":                {"region_code", "region"},
+		"GEO_CITY":                  {"city"},
+		"GEO_LATITUDE":              {"latitude"},
+		"GEO_LONGITUDE":             {"longitude"},
+		"PLATFORM_DEVICE_TYPE":      {"device_type", "connection_type"},
+		"PLATFORM_BROWSER":          {"browser"},
+		"PLATFORM_OS":               {"os"},
+		"VIEWABLE":                  {"viewable"},
+		"EXCHANGE":                  {"exchange_name", "exchange_id"},
+		"SELLER_ID":                 {"publisher_id"},
+	},
+	Required: []string{"AUCTION_ID", "CAMPAIGN_ID", "BID_TIME", "WIN_COST_MICROS_USD"},
+	MoneyScale: map[string]float64{
+		"BID_PRICE_MICROS_USD":      cpmToMicros,
+		"CLEARING_PRICE_MICROS_USD": cpmToMicros,
+		"WIN_COST_MICROS_USD":       cpmToMicros,
+		// Revenue is the conversions' total, not a CPM
+		"REVENUE_MICROS_USD": dollarsToMicros,
+	},
+}
+
+// criteoFormat is Criteo's impression-level export, priced per impression in dollars
+var criteoFormat = &LogFormat{
+	Source: "criteo",
+	Columns: map[string][]string{
+		"ACCOUNT_ID":                {"AdvertiserId"},
+		"AUCTION_ID":                {"ImpressionId", "AuctionId"},
+		"CAMPAIGN_ID":               {"CampaignId"},
+		"CREATIVE_ID":               {"AdId", "CreativeId"},
+		"USER_ID":                   {"UserId"},
+		"BID_TIME":                  {"Timestamp", "EventTime"},
+		"IMPRESSION_TIME":           {"Timestamp", "EventTime"},
+		"BID_PRICE_MICROS_USD":      {"Bid", "BidPrice"},
+		"CLEARING_PRICE_MICROS_USD": {"WinningPrice"},
+		"WIN_COST_MICROS_USD":       {"Cost"},
+		"CLICKS":                    {"Clicks"},
+		"CONVERSIONS":               {"Sales", "Conversions"},
+		"REVENUE_MICROS_USD":        {"SalesAmount", "OrderValue", "Revenue"},
+		"DOMAIN":                    {"Domain", "Publisher"},
+		"GEO_COUNTRY":               {"Country", "CountryCode"},
+		"GEO_REGION":                {"Region"},
+		"GEO_CITY":                  {"City"},
+		"PLATFORM_DEVICE_TYPE":      {"Device", "DeviceType"},
+		"PLATFORM_BROWSER":          {"Browser"},
+		"PLATFORM_OS":               {"Os", "OperatingSystem"},
+		"VIEWABILITY_MEASURABLE":    {"Measurable"},
+		"VIEWABLE":                  {"Viewable"},
+		"EXCHANGE":                  {"Exchange", "Ssp"},
+	},
+	Required: []string{"AUCTION_ID", "CAMPAIGN_ID", "BID_TIME", "WIN_COST_MICROS_USD"},
+	MoneyScale: map[string]float64{
+		"BID_PRICE_MICROS_USD":      dollarsToMicros,
+		"CLEARING_PRICE_MICROS_USD": dollarsToMicros,
+		"WIN_COST_MICROS_USD":       dollarsToMicros,
+		"REVENUE_MICROS_USD":        dollarsToMicros,
+	},
+}
+
+// stackAdaptFormat is StackAdapt's impression log export, with spaced headers and dollar costs
+var stackAdaptFormat = &LogFormat{
+	Source: "stackadapt",
+	Columns: map[string][]string{
+		"ACCOUNT_ID":                {"Advertiser ID"},
+		"AUCTION_ID":                {"Impression ID", "Auction ID"},
+		"CAMPAIGN_ID":               {"Campaign ID"},
+		"CREATIVE_ID":               {"Creative ID", "Ad ID"},
+		"USER_ID":                   {"Device ID", "User ID"},
+		"BID_TIME":                  {"Impression Time", "Timestamp"},
+		"IMPRESSION_TIME":           {"Impression Time", "Timestamp"},
+		"BID_PRICE_MICROS_USD":      {"Bid Price"},
+		"CLEARING_PRICE_MICROS_USD": {"Media Cost"},
+		"WIN_COST_MICROS_USD":       {"Cost", "Spend"},
+		"CLICKS":                    {"Clicks"},
+		"CONVERSIONS":               {"Conversions"},
+		"REVENUE_MICROS_USD":        {"Conversion Revenue", "Revenue"},
+		"DOMAIN":                    {"Domain", "Site", "App Bundle"},
+		"GEO_COUNTRY":               {"Country"},
+		"GEO_REGION":                {"Region", "State"},
+		"GEO_CITY":                  {"City"},
+		"GEO_LATITUDE":              {"Latitude"},
+		"GEO_LONGITUDE":             {"Longitude"},
+		"PLATFORM_DEVICE_TYPE":      {"Device Type"},
+		"PLATFORM_BROWSER":          {"Browser"},
+		"PLATFORM_OS":               {"OS", "Operating System"},
+		"VIEWABLE":                  {"Viewable"},
+		"EXCHANGE":                  {"Supply Source", "Exchange"},
+		"VIDEO_START":               {"Video Starts"},
+		"VIDEO_COMPLETE":            {"Video Completes"},
+	},
+	Required: []string{"AUCTION_ID", "CAMPAIGN_ID", "BID_TIME", "WIN_COST_MICROS_USD"},
+	MoneyScale: map[string]float64{
+		"BID_PRICE_MICROS_USD":      dollarsToMicros,
+		"CLEARING_PRICE_MICROS_USD": dollarsToMicros,
+		"WIN_COST_MICROS_USD":       dollarsToMicros,
+		"REVENUE_MICROS_USD":        dollarsToMicros,
+	},
+}
+
+// tradeDeskFormat is The Trade Desk's raw event data impression feed, with CPM bid and media
+// prices and per-impression partner cost in dollars
+var tradeDeskFormat = &LogFormat{
+	Source: "ttd",
+	Columns: map[string][]string{
+		"ACCOUNT_ID":                {"AdvertiserId"},
+		"AUCTION_ID":                {"ImpressionId", "BidRequestId"},
+		"CAMPAIGN_ID":               {"CampaignId", "AdGroupId"},
+		"CREATIVE_ID":               {"CreativeId"},
+		"USER_ID":                   {"TDID"},
+		"BID_TIME":                  {"LogEntryTime"},
+		"IMPRESSION_TIME":           {"LogEntryTime"},
+		"BID_PRICE_MICROS_USD":      {"BidPriceCPMInUSD"},
+		"CLEARING_PRICE_MICROS_USD": {"MediaCostCPMInUSD"},
+		"WIN_COST_MICROS_USD":       {"PartnerCostInUSD"},
+		"CLICKS":                    {"Clicks"},
+		"CONVERSIONS":               {"Conversions"},
+		"REVENUE_MICROS_USD":        {"ConversionRevenueInUSD"},
+		"DOMAIN":                    {"Site"},
+		"AD_POSITION":               {"FoldPosition"},
+		"GEO_COUNTRY":               {"Country"},
+		"GEO_REGION":                {"Region"},
+		"GEO_CITY":                  {"City"},
+		"GEO_LATITUDE":              {"Latitude"},
+		"GEO_LONGITUDE":             {"Longitude"},
+		"PLATFORM_DEVICE_TYPE":      {"DeviceType"},
+		"PLATFORM_BROWSER":          {"Browser"},
+		"PLATFORM_OS":               {"OSFamily", "OS"},
+		"EXCHANGE":                  {"SupplyVendor"},
+		"SELLER_ID":                 {"SupplyVendorPublisherId"},
+	},
+	Required: []string{"AUCTION_ID", "CAMPAIGN_ID", "BID_TIME", "WIN_COST_MICROS_USD"},
+	MoneyScale: map[string]float64{
+		"BID_PRICE_MICROS_USD":      cpmToMicros,
+		"CLEARING_PRICE_MICROS_USD": cpmToMicros,
+		"WIN_COST_MICROS_USD":       dollarsToMicros,
+		"REVENUE_MICROS_USD":        dollarsToMicros,
+	},
+}
+
+// dv360Format is Display & Video 360's Data Transfer impression file, with spaced headers, CPM
+// bid and media prices and per-impression total media cost in dollars
+var dv360Format = &LogFormat{
+	Source: "dv360",
+	Columns: map[string][]string{
+		"ACCOUNT_ID":                {"Advertiser ID"},
+		"AUCTION_ID":                {"Auction ID"},
+		"CAMPAIGN_ID":               {"Insertion Order ID", "Line Item ID"},
+		"CREATIVE_ID":               {"Creative ID"},
+		"USER_ID":                   {"User ID", "Device ID"},
+		"BID_TIME":                  {"Event Time"},
+		"IMPRESSION_TIME":           {"Event Time"},
+		"BID_PRICE_MICROS_USD":      {"Bid Price CPM (USD)"},
+		"CLEARING_PRICE_MICROS_USD": {"Media Cost CPM (USD)"},
+		"WIN_COST_MICROS_USD":       {"Total Media Cost (USD)"},
+		"CLICKS":                    {"Clicks"},
+		"CONVERSIONS":               {"Total Conversions"},
+		"REVENUE_MICROS_USD":        {"Conversion Revenue (USD)"},
+		"DOMAIN":                    {"App/URL", "Domain"},
+		"AD_POSITION":               {"Ad Position"},
+		"GEO_COUNTRY":               {"Country"},
+		"GEO_REGION":                {"Region"},
+		"GEO_CITY":                  {"City"},
+		"PLATFORM_DEVICE_TYPE":      {"Device Type"},
+		"PLATFORM_BROWSER":          {"Browser"},
+		"PLATFORM_OS":               {"Operating System"},
+		"VIEWABILITY_MEASURABLE":    {"Active View: Measurable"},
+		"VIEWABLE":                  {"Active View: Viewable"},
+		"EXCHANGE":                  {"Exchange"},
+		"SELLER_ID":                 {"Publisher ID"},
+	},
+	Required: []string{"AUCTION_ID", "CAMPAIGN_ID", "BID_TIME", "WIN_COST_MICROS_USD"},
+	MoneyScale: map[string]float64{
+		"BID_PRICE_MICROS_USD":      cpmToMicros,
+		"CLEARING_PRICE_MICROS_USD": cpmToMicros,
+		"WIN_COST_MICROS_USD":       dollarsToMicros,
+		"REVENUE_MICROS_USD":        dollarsToMicros,
+	},
+}
@@ -0,0 +1,154 @@
+package ingestion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamDecoder maps JSON bid and impression events consumed from a stream onto canonical
+// records. Each event's fields are resolved like a CSV header, against the log format for the
+// stream's source extended by the user's mapping profile for it. Nested objects are flattened
+// with underscores, so {"geo": {"country": "US"}} reads as a GEO_COUNTRY column. A decoder
+// isn't safe for concurrent use.
+type StreamDecoder struct {
+	format  *LogFormat
+	profile *MappingProfile
+	// layouts caches resolved layouts by the event's sorted field names, since a stream's events
+	// nearly always share a handful of shapes
+	layouts map[string]*logLayout
+}
+
+// streamLayoutCacheSize caps the layouts a decoder keeps, for streams whose events vary widely
+const streamLayoutCacheSize = 64
+
+// NewStreamDecoder creates a decoder for events in the log format registered for source. The
+// profile may be nil.
+func NewStreamDecoder(source string, profile *MappingProfile) (*StreamDecoder, error) {
+	format, ok := findLogFormat(source)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLogFormat, source)
+	}
+	return &StreamDecoder{
+		format:  format,
+		profile: profile,
+		layouts: make(map[string]*logLayout),
+	}, nil
+}
+
+// Decode converts one JSON event into a record. Events commonly leave out fields that are
+// empty or zero, so only a bid time is required; missing and malformed values are treated as
+// empty, as they are in uploaded logs.
+func (d *StreamDecoder) Decode(message []byte) (BeeswaxLogRecord, error) {
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	var event map[string]interface{}
+	if err := decoder.Decode(&event); err != nil {
+		return BeeswaxLogRecord{}, fmt.Errorf("invalid event: %w", err)
+	}
+
+	fields := make(map[string]interface{}, len(event))
+	flattenStreamEvent("", event, fields)
+	header := make([]string, 0, len(fields))
+	for name := range fields {
+		header = append(header, name)
+	}
+	sort.Strings(header)
+
+	layout, err := d.layout(header)
+	if err != nil {
+		return BeeswaxLogRecord{}, err
+	}
+
+	row := make([]string, len(header))
+	for i, name := range header {
+		row[i] = streamFieldString(fields[name])
+	}
+	// Streams commonly carry epoch timestamps, which CSV exports don't
+	for _, column := range []string{"BID_TIME", "IMPRESSION_TIME"} {
+		if idx, ok := layout.columns[column]; ok {
+			if number, isNumber := fields[header[idx]].(json.Number); isNumber {
+				row[idx] = epochToRFC3339(number)
+			}
+		}
+	}
+
+	return parseBeeswaxRecord(layout, row), nil
+}
+
+// layout resolves the format against an event's fields, reusing the layout of earlier events
+// with the same fields
+func (d *StreamDecoder) layout(header []string) (*logLayout, error) {
+	key := strings.Join(header, "\x00")
+	if layout, ok := d.layouts[key]; ok {
+		return layout, nil
+	}
+
+	// Unlike a file's header, an event's fields don't decide its format, so the format's
+	// required columns don't apply
+	layout, _ := d.format.resolve(normalizeHeader(header), d.profile)
+	if _, ok := layout.columns["BID_TIME"]; !ok {
+		return nil, fmt.Errorf("bid time not found in %s event", d.format.Source)
+	}
+	if len(d.layouts) >= streamLayoutCacheSize {
+		clear(d.layouts)
+	}
+	d.layouts[key] = layout
+	return layout, nil
+}
+
+// flattenStreamEvent copies an event's fields into fields, joining nested names with underscores
+func flattenStreamEvent(prefix string, event map[string]interface{}, fields map[string]interface{}) {
+	for name, value := range event {
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenStreamEvent(name, nested, fields)
+			continue
+		}
+		fields[name] = value
+	}
+}
+
+// streamFieldString renders a field's value the way a CSV export would write it
+func streamFieldString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		// Arrays have no column equivalent; keep them as JSON rather than dropping them
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// epochToRFC3339 converts an epoch timestamp in seconds, milliseconds or microseconds,
+// told apart by magnitude, to RFC 3339
+func epochToRFC3339(number json.Number) string {
+	value, err := number.Float64()
+	if err != nil || value <= 0 {
+		return ""
+	}
+
+	var t time.Time
+	switch {
+	case value < 1e11:
+		t = time.Unix(0, int64(value*1e9))
+	case value < 1e14:
+		t = time.UnixMilli(int64(value))
+	default:
+		t = time.UnixMicro(int64(value))
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,376 @@
+package ingestion
+
+// mixedSource is the source of a merged summary whose files came from different DSPs
+const mixedSource = "mixed"
+
+// MergeBeeswaxSummaries combines the summaries of several log files into one, as if their
+// records had been parsed together. Counts and spend are exact. Unique reach is estimated from
+// the merged daily sketches, frequency distributions are summed per file, and geo centroids are
+// averaged weighted by bids.
+func MergeBeeswaxSummaries(summaries ...*BeeswaxLogSummary) *BeeswaxLogSummary {
+	merged := newBeeswaxLogSummary()
+	for _, summary := range summaries {
+		if summary != nil {
+			merged.merge(summary)
+		}
+	}
+	merged.calculateRates()
+
+	return merged
+}
+
+// merge accumulates another summary's counts; derived rates must be recalculated afterwards
+func (summary *BeeswaxLogSummary) merge(other *BeeswaxLogSummary) {
+	// Widen the time range to cover both files
+	if other.TotalRecords > 0 {
+		if other.TimeRange[0].Before(summary.TimeRange[0]) {
+			summary.TimeRange[0] = other.TimeRange[0]
+		}
+		if other.TimeRange[1].After(summary.TimeRange[1]) {
+			summary.TimeRange[1] = other.TimeRange[1]
+		}
+	}
+
+	// Source, field coverage and column statistics, weighting each file's coverage by its records
+	switch {
+	case summary.Source == "":
+		summary.Source = other.Source
+	case other.Source != "" && other.Source != summary.Source:
+		summary.Source = mixedSource
+	}
+	if total := summary.TotalRecords + other.TotalRecords; total > 0 {
+		for _, column := range canonicalColumns {
+			covered := summary.FieldCoverage[column]*float64(summary.TotalRecords) + other.FieldCoverage[column]*float64(other.TotalRecords)
+			summary.FieldCoverage[column] = covered / float64(total)
+		}
+	}
+	for breakdown, folded := range other.TruncatedBreakdowns {
+		if summary.TruncatedBreakdowns == nil {
+			summary.TruncatedBreakdowns = make(map[string]int)
+		}
+		summary.TruncatedBreakdowns[breakdown] += folded
+	}
+	for column, stats := range other.Columns {
+		if summary.Columns == nil {
+			summary.Columns = make(map[string]*ColumnStats)
+		}
+		merged, ok := summary.Columns[column]
+		if !ok {
+			merged = &ColumnStats{Type: stats.Type}
+			summary.Columns[column] = merged
+		}
+		merged.merge(column, stats)
+	}
+
+	// Totals
+	summary.TotalRecords += other.TotalRecords
+	summary.UndatedRecords += other.UndatedRecords
+	summary.TotalImpressions += other.TotalImpressions
+	summary.TotalClicks += other.TotalClicks
+	summary.TotalConversions += other.TotalConversions
+	summary.TotalBidAmount += other.TotalBidAmount
+	summary.TotalWinCost += other.TotalWinCost
+	summary.TotalRevenue += other.TotalRevenue
+
+	// Breakdowns
+	mergeCounts(summary.DeviceBreakdown, other.DeviceBreakdown)
+	mergeCounts(summary.BrowserBreakdown, other.BrowserBreakdown)
+	mergeCounts(summary.OSBreakdown, other.OSBreakdown)
+	mergeCounts(summary.GeoBreakdown, other.GeoBreakdown)
+	mergeCounts(summary.HourlyBreakdown, other.HourlyBreakdown)
+	mergeCounts(summary.DomainBreakdown, other.DomainBreakdown)
+
+	// Creatives, domains and content categories
+	summary.CreativePerformance = mergePerformance(summary.CreativePerformance, other.CreativePerformance)
+	summary.DomainPerformance = mergePerformance(summary.DomainPerformance, other.DomainPerformance)
+	summary.ContentCategories = mergePerformance(summary.ContentCategories, other.ContentCategories)
+	for domain, category := range other.DomainCategories {
+		if summary.DomainCategories == nil {
+			summary.DomainCategories = make(map[string]string)
+		}
+		summary.DomainCategories[domain] = category
+	}
+
+	// Campaigns
+	for id, metrics := range other.CampaignPerformance {
+		campaign := summary.CampaignPerformance[id]
+		campaign.merge(metrics)
+		summary.CampaignPerformance[id] = campaign
+	}
+	for campaignID, days := range other.CampaignDaily {
+		for day, metrics := range days {
+			addCampaignSegment(summary.CampaignDaily, campaignID, day, metrics)
+		}
+	}
+	for campaignID, devices := range other.CampaignDevices {
+		for device, metrics := range devices {
+			addCampaignSegment(summary.CampaignDevices, campaignID, device, metrics)
+		}
+	}
+	if summary.CampaignDomains == nil && len(other.CampaignDomains) > 0 {
+		summary.CampaignDomains = make(map[string]map[string]CampaignMetrics)
+	}
+	for campaignID, domains := range other.CampaignDomains {
+		for domain, metrics := range domains {
+			addCampaignSegment(summary.CampaignDomains, campaignID, domain, metrics)
+		}
+	}
+
+	// Funnel
+	summary.Funnel.add(other.Funnel)
+	for dimension, values := range other.FunnelSegments {
+		for value, counts := range values {
+			addFunnelSegment(summary.FunnelSegments, dimension, value, counts)
+		}
+	}
+
+	// Reach and frequency
+	for campaignID, metrics := range other.ReachFrequency {
+		mergeReachFrequency(summary.ReachFrequency, campaignID, metrics)
+	}
+
+	// Media quality
+	if other.Viewability != nil {
+		if summary.Viewability == nil {
+			summary.Viewability = &ViewabilityMetrics{}
+			summary.CampaignViewability = make(map[string]*ViewabilityMetrics)
+		}
+		summary.Viewability.merge(other.Viewability)
+		for id, viewability := range other.CampaignViewability {
+			campaignViewability(summary, id).merge(viewability)
+		}
+	}
+	if other.Video != nil {
+		if summary.Video == nil {
+			summary.Video = &VideoMetrics{}
+			summary.CampaignVideo = make(map[string]*VideoMetrics)
+		}
+		summary.Video.merge(other.Video)
+		for id, video := range other.CampaignVideo {
+			campaignVideo(summary, id).merge(video)
+		}
+	}
+
+	// Geo, dayparting, supply path, loss reasons and bid efficiency
+	mergeGeo(summary.Geo, other.Geo)
+	if other.Dayparting != nil {
+		summary.Dayparting.merge(other.Dayparting)
+	}
+	if other.SupplyPath != nil {
+		if summary.SupplyPath == nil {
+			summary.SupplyPath = newSupplyPathSummary()
+		}
+		summary.SupplyPath.merge(other.SupplyPath)
+	}
+	if other.LossReasons != nil {
+		if summary.LossReasons == nil {
+			summary.LossReasons = newLossReasonSummary()
+		}
+		summary.LossReasons.merge(other.LossReasons)
+	}
+	if other.BidEfficiency != nil {
+		if summary.BidEfficiency == nil {
+			summary.BidEfficiency = newBidEfficiencySummary()
+		}
+		summary.BidEfficiency.merge(other.BidEfficiency)
+	}
+	if other.Prebid != nil {
+		if summary.Prebid == nil {
+			summary.Prebid = newPrebidSummary()
+		}
+		summary.Prebid.merge(other.Prebid)
+	}
+
+	// Price distributions, bid shading and price floors
+	if other.Prices != nil {
+		if summary.Prices == nil {
+			summary.Prices = newPriceDistribution()
+		}
+		summary.Prices.merge(other.Prices)
+	}
+	if other.BidShading != nil {
+		if summary.BidShading == nil {
+			summary.BidShading = newBidShadingSummary()
+		}
+		summary.BidShading.merge(other.BidShading)
+	}
+	if other.PriceFloors != nil {
+		if summary.PriceFloors == nil {
+			summary.PriceFloors = newPriceFloorSummary()
+		}
+		summary.PriceFloors.merge(other.PriceFloors)
+	}
+}
+
+// mergePerformance adds the metrics of src to dst, creating dst when needed
+func mergePerformance(dst, src map[string]CampaignMetrics) map[string]CampaignMetrics {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]CampaignMetrics, len(src))
+	}
+	for key, metrics := range src {
+		merged := dst[key]
+		merged.merge(metrics)
+		dst[key] = merged
+	}
+	return dst
+}
+
+// mergeCounts adds the counts of src to dst
+func mergeCounts(dst, src map[string]int) {
+	for key, count := range src {
+		dst[key] += count
+	}
+}
+
+// mergeReachFrequency folds a file's reach and frequency for a campaign into the merged metrics
+func mergeReachFrequency(dst map[string]*ReachFrequencyMetrics, campaignID string, src *ReachFrequencyMetrics) {
+	metrics, existed := dst[campaignID]
+	if !existed {
+		metrics = &ReachFrequencyMetrics{
+			FrequencyDistribution: make(map[string]int),
+			DailySketches:         make(map[string]*HyperLogLog),
+			DailyImpressions:      make(map[string]int),
+		}
+		dst[campaignID] = metrics
+	}
+
+	metrics.Impressions += src.Impressions
+	for bucket, users := range src.FrequencyDistribution {
+		metrics.FrequencyDistribution[bucket] += users
+	}
+	metrics.FrequencyCurve = mergeFrequencyCurves(metrics.FrequencyCurve, src.FrequencyCurve)
+	for day, sketch := range src.DailySketches {
+		merged, ok := metrics.DailySketches[day]
+		if !ok {
+			merged = NewHyperLogLog()
+			metrics.DailySketches[day] = merged
+		}
+		merged.Merge(sketch)
+	}
+	for day, impressions := range src.DailyImpressions {
+		metrics.DailyImpressions[day] += impressions
+	}
+
+	// The first file's reach is exact; once files are combined it is estimated from the sketches
+	if !existed {
+		metrics.Reach = src.Reach
+	} else {
+		union := NewHyperLogLog()
+		for _, sketch := range metrics.DailySketches {
+			union.Merge(sketch)
+		}
+		metrics.Reach = union.Count()
+	}
+	if metrics.Reach > 0 {
+		metrics.AverageFrequency = float64(metrics.Impressions) / float64(metrics.Reach)
+	}
+}
+
+// merge accumulates another set of viewability counts
+func (v *ViewabilityMetrics) merge(other *ViewabilityMetrics) {
+	v.MeasurableImpressions += other.MeasurableImpressions
+	v.ViewableImpressions += other.ViewableImpressions
+}
+
+// merge accumulates another set of VAST quartile counts
+func (v *VideoMetrics) merge(other *VideoMetrics) {
+	v.Starts += other.Starts
+	v.FirstQuartiles += other.FirstQuartiles
+	v.Midpoints += other.Midpoints
+	v.ThirdQuartiles += other.ThirdQuartiles
+	v.Completes += other.Completes
+}
+
+// mergeGeo folds the src geo tree into dst, averaging centroids weighted by bids
+func mergeGeo(dst, src map[string]*GeoNode) {
+	for name, other := range src {
+		node, ok := dst[name]
+		if !ok {
+			node = &GeoNode{}
+			dst[name] = node
+		}
+
+		switch {
+		case other.Centroid == nil:
+		case node.Centroid == nil || node.Bids+other.Bids == 0:
+			centroid := *other.Centroid
+			node.Centroid = &centroid
+		default:
+			weight := float64(other.Bids) / float64(node.Bids+other.Bids)
+			node.Centroid = &GeoPoint{
+				Latitude:  node.Centroid.Latitude + (other.Centroid.Latitude-node.Centroid.Latitude)*weight,
+				Longitude: node.Centroid.Longitude + (other.Centroid.Longitude-node.Centroid.Longitude)*weight,
+			}
+		}
+
+		node.Bids += other.Bids
+		node.Impressions += other.Impressions
+		node.Clicks += other.Clicks
+		node.Conversions += other.Conversions
+		node.Spend += other.Spend
+
+		if len(other.Children) > 0 {
+			if node.Children == nil {
+				node.Children = make(map[string]*GeoNode)
+			}
+			mergeGeo(node.Children, other.Children)
+		}
+	}
+}
+
+// merge accumulates another grid's cells
+func (g *DaypartingGrid) merge(other *DaypartingGrid) {
+	for day := range other {
+		for hour := range other[day] {
+			cell := &g[day][hour]
+			cell.Impressions += other[day][hour].Impressions
+			cell.Clicks += other[day][hour].Clicks
+			cell.Conversions += other[day][hour].Conversions
+			cell.Spend += other[day][hour].Spend
+		}
+	}
+}
+
+// merge accumulates another supply path summary
+func (s *SupplyPathSummary) merge(other *SupplyPathSummary) {
+	for exchange, metrics := range other.Exchanges {
+		supplyPathMetrics(s.Exchanges, exchange).merge(metrics)
+	}
+	for relationship, metrics := range other.ByRelationship {
+		supplyPathMetrics(s.ByRelationship, relationship).merge(metrics)
+	}
+	for exchange, paths := range other.Paths {
+		dst, ok := s.Paths[exchange]
+		if !ok {
+			dst = make(map[string]*SupplyPathMetrics)
+			s.Paths[exchange] = dst
+		}
+		for relationship, metrics := range paths {
+			supplyPathMetrics(dst, relationship).merge(metrics)
+		}
+	}
+	if s.Sellers == nil && len(other.Sellers) > 0 {
+		s.Sellers = make(map[string]map[string]*SupplyPathMetrics)
+	}
+	for domain, sellers := range other.Sellers {
+		dst, ok := s.Sellers[domain]
+		if !ok {
+			dst = make(map[string]*SupplyPathMetrics)
+			s.Sellers[domain] = dst
+		}
+		for key, metrics := range sellers {
+			supplyPathMetrics(dst, key).merge(metrics)
+		}
+	}
+}
+
+// merge accumulates another slice of supply's bids, wins and cost
+func (m *SupplyPathMetrics) merge(other *SupplyPathMetrics) {
+	m.Bids += other.Bids
+	m.Impressions += other.Impressions
+	m.Spend += other.Spend
+	m.ClearingSpend += other.ClearingSpend
+}
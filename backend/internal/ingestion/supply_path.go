@@ -0,0 +1,261 @@
+package ingestion
+
+import (
+	"sort"
+	"strings"
+)
+
+// Seller relationships as declared in ads.txt / sellers.json
+const (
+	SellerRelationshipDirect   = "direct"
+	SellerRelationshipReseller = "reseller"
+	SellerRelationshipUnknown  = "unknown"
+)
+
+// expensiveResoldFeeMargin is how many fee percentage points a resold path must exceed the
+// direct path on the same exchange by before it is flagged
+const expensiveResoldFeeMargin = 5.0
+
+// SupplyPathMetrics contains spend and efficiency metrics for a slice of supply
+type SupplyPathMetrics struct {
+	Bids          int     `json:"bids"`
+	Impressions   int     `json:"impressions"`
+	Spend         float64 `json:"spend"`
+	ClearingSpend float64 `json:"clearingSpend"`
+	WinRate       float64 `json:"winRate"`
+	CPM           float64 `json:"cpm"`
+	// InferredFeeRate is the share of spend above the auction clearing price, in percent
+	InferredFeeRate float64 `json:"inferredFeeRate"`
+}
+
+// SupplyPathSummary contains supply path metrics by exchange, by exchange and seller relationship,
+// and by seller relationship alone
+type SupplyPathSummary struct {
+	Exchanges      map[string]*SupplyPathMetrics            `json:"exchanges"`
+	Paths          map[string]map[string]*SupplyPathMetrics `json:"paths"`
+	ByRelationship map[string]*SupplyPathMetrics            `json:"byRelationship"`
+	// Sellers holds metrics by domain and SellerPath key, for records with a domain and seller ID,
+	// so each domain's paths can be checked against its ads.txt
+	Sellers map[string]map[string]*SupplyPathMetrics `json:"sellers,omitempty"`
+}
+
+// SellerPath is a seller account on an exchange selling a domain's inventory
+type SellerPath struct {
+	Exchange     string `json:"exchange"`
+	SellerID     string `json:"sellerId"`
+	Relationship string `json:"relationship"`
+}
+
+// Key encodes the path as a map key
+func (p SellerPath) Key() string {
+	return p.Exchange + "|" + p.SellerID + "|" + p.Relationship
+}
+
+// ParseSellerPath decodes a key produced by SellerPath.Key
+func ParseSellerPath(key string) SellerPath {
+	exchange, rest, _ := strings.Cut(key, "|")
+	separator := strings.LastIndex(rest, "|")
+	if separator < 0 {
+		return SellerPath{Exchange: exchange, SellerID: rest}
+	}
+	return SellerPath{Exchange: exchange, SellerID: rest[:separator], Relationship: rest[separator+1:]}
+}
+
+// SupplyPathEntry is a supply path with its metrics, used in reports
+type SupplyPathEntry struct {
+	Exchange     string `json:"exchange"`
+	Relationship string `json:"relationship,omitempty"`
+	SupplyPathMetrics
+}
+
+// ExpensiveResoldPath flags a resold path that costs noticeably more than buying direct
+type ExpensiveResoldPath struct {
+	Exchange           string  `json:"exchange"`
+	ResellerFeeRate    float64 `json:"resellerFeeRate"`
+	DirectFeeRate      float64 `json:"directFeeRate"`
+	ResellerSpend      float64 `json:"resellerSpend"`
+	EstimatedOverspend float64 `json:"estimatedOverspend"`
+}
+
+// SupplyPathReport is the supply path analysis for a processed file
+type SupplyPathReport struct {
+	FileID          string                `json:"fileId"`
+	Exchanges       []SupplyPathEntry     `json:"exchanges"`
+	Paths           []SupplyPathEntry     `json:"paths"`
+	ByRelationship  []SupplyPathEntry     `json:"byRelationship"`
+	ExpensiveResold []ExpensiveResoldPath `json:"expensiveResold"`
+}
+
+func newSupplyPathSummary() *SupplyPathSummary {
+	return &SupplyPathSummary{
+		Exchanges:      make(map[string]*SupplyPathMetrics),
+		Paths:          make(map[string]map[string]*SupplyPathMetrics),
+		ByRelationship: make(map[string]*SupplyPathMetrics),
+		Sellers:        make(map[string]map[string]*SupplyPathMetrics),
+	}
+}
+
+// normalizeSellerRelationship maps the relationship values used by different exports to
+// direct or reseller, returning an empty string when the value is not recognized
+func normalizeSellerRelationship(value string) string {
+	switch strings.ToLower(value) {
+	case "direct", "publisher", "owned", "o&o":
+		return SellerRelationshipDirect
+	case "reseller", "intermediary", "indirect", "both":
+		return SellerRelationshipReseller
+	}
+	return ""
+}
+
+// add accumulates a record's supply path
+func (s *SupplyPathSummary) add(record *BeeswaxLogRecord) {
+	exchange := record.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	relationship := record.SellerRelationship
+	if relationship == "" {
+		relationship = SellerRelationshipUnknown
+	}
+
+	paths, ok := s.Paths[exchange]
+	if !ok {
+		paths = make(map[string]*SupplyPathMetrics)
+		s.Paths[exchange] = paths
+	}
+
+	for _, metrics := range []*SupplyPathMetrics{
+		supplyPathMetrics(s.Exchanges, exchange),
+		supplyPathMetrics(paths, relationship),
+		supplyPathMetrics(s.ByRelationship, relationship),
+	} {
+		metrics.add(record)
+	}
+
+	if record.Domain != "" && record.SellerID != "" {
+		sellers, ok := s.Sellers[record.Domain]
+		if !ok {
+			sellers = make(map[string]*SupplyPathMetrics)
+			s.Sellers[record.Domain] = sellers
+		}
+		path := SellerPath{Exchange: exchange, SellerID: record.SellerID, Relationship: relationship}
+		supplyPathMetrics(sellers, path.Key()).add(record)
+	}
+}
+
+// calculateRates computes derived metrics for every slice of supply
+func (s *SupplyPathSummary) calculateRates() {
+	for _, metrics := range s.Exchanges {
+		metrics.calculateRates()
+	}
+	for _, paths := range s.Paths {
+		for _, metrics := range paths {
+			metrics.calculateRates()
+		}
+	}
+	for _, metrics := range s.ByRelationship {
+		metrics.calculateRates()
+	}
+	for _, sellers := range s.Sellers {
+		for _, metrics := range sellers {
+			metrics.calculateRates()
+		}
+	}
+}
+
+// add accumulates a record's bid, win and cost
+func (m *SupplyPathMetrics) add(record *BeeswaxLogRecord) {
+	m.Bids++
+	if !record.Won() {
+		return
+	}
+	m.Impressions++
+	m.Spend += float64(record.WinCostMicrosUSD) / 1000000
+	m.ClearingSpend += float64(record.ClearingPriceMicrosUSD) / 1000000
+}
+
+// calculateRates computes win rate, CPM and the inferred fee rate
+func (m *SupplyPathMetrics) calculateRates() {
+	if m.Bids > 0 {
+		m.WinRate = float64(m.Impressions) / float64(m.Bids) * 100
+	}
+	if m.Impressions > 0 {
+		m.CPM = m.Spend / float64(m.Impressions) * 1000
+	}
+	if m.Spend > 0 && m.ClearingSpend > 0 && m.Spend > m.ClearingSpend {
+		m.InferredFeeRate = (m.Spend - m.ClearingSpend) / m.Spend * 100
+	} else {
+		m.InferredFeeRate = 0
+	}
+}
+
+// supplyPathMetrics returns the metrics for a key, creating them if needed
+func supplyPathMetrics(metrics map[string]*SupplyPathMetrics, key string) *SupplyPathMetrics {
+	entry, ok := metrics[key]
+	if !ok {
+		entry = &SupplyPathMetrics{}
+		metrics[key] = entry
+	}
+	return entry
+}
+
+// BuildSupplyPathReport builds the supply path report for a summary, with entries ordered by
+// spend and resold paths flagged when they cost noticeably more than the direct path
+func BuildSupplyPathReport(fileID string, summary *SupplyPathSummary) *SupplyPathReport {
+	report := &SupplyPathReport{
+		FileID:          fileID,
+		Exchanges:       []SupplyPathEntry{},
+		Paths:           []SupplyPathEntry{},
+		ByRelationship:  []SupplyPathEntry{},
+		ExpensiveResold: []ExpensiveResoldPath{},
+	}
+
+	for exchange, metrics := range summary.Exchanges {
+		report.Exchanges = append(report.Exchanges, SupplyPathEntry{Exchange: exchange, SupplyPathMetrics: *metrics})
+	}
+	for relationship, metrics := range summary.ByRelationship {
+		report.ByRelationship = append(report.ByRelationship, SupplyPathEntry{Relationship: relationship, SupplyPathMetrics: *metrics})
+	}
+	for exchange, paths := range summary.Paths {
+		for relationship, metrics := range paths {
+			report.Paths = append(report.Paths, SupplyPathEntry{Exchange: exchange, Relationship: relationship, SupplyPathMetrics: *metrics})
+		}
+
+		reseller, hasReseller := paths[SellerRelationshipReseller]
+		direct, hasDirect := paths[SellerRelationshipDirect]
+		if !hasReseller || !hasDirect || reseller.Spend == 0 {
+			continue
+		}
+		if reseller.InferredFeeRate-direct.InferredFeeRate >= expensiveResoldFeeMargin {
+			report.ExpensiveResold = append(report.ExpensiveResold, ExpensiveResoldPath{
+				Exchange:           exchange,
+				ResellerFeeRate:    reseller.InferredFeeRate,
+				DirectFeeRate:      direct.InferredFeeRate,
+				ResellerSpend:      reseller.Spend,
+				EstimatedOverspend: reseller.Spend * (reseller.InferredFeeRate - direct.InferredFeeRate) / 100,
+			})
+		}
+	}
+
+	for _, entries := range [][]SupplyPathEntry{report.Exchanges, report.Paths, report.ByRelationship} {
+		sortSupplyPathEntries(entries)
+	}
+	sort.Slice(report.ExpensiveResold, func(i, j int) bool {
+		return report.ExpensiveResold[i].EstimatedOverspend > report.ExpensiveResold[j].EstimatedOverspend
+	})
+
+	return report
+}
+
+// sortSupplyPathEntries orders entries by spend, largest first
+func sortSupplyPathEntries(entries []SupplyPathEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Spend != entries[j].Spend {
+			return entries[i].Spend > entries[j].Spend
+		}
+		if entries[i].Exchange != entries[j].Exchange {
+			return entries[i].Exchange < entries[j].Exchange
+		}
+		return entries[i].Relationship < entries[j].Relationship
+	})
+}
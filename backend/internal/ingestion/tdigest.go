@@ -0,0 +1,222 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// tDigestCompression bounds the digest to around a hundred centroids, keeping quantile error
+// around 1% in the tails while the digest stays a few KB in the summary
+const tDigestCompression = 200
+
+// tDigestBuffer is how many values are buffered before they are merged into the centroids
+const tDigestBuffer = 500
+
+// TDigest is an approximate quantile sketch used for price distributions at scale. It keeps
+// the tails precise, where overspend shows up, and digests can be merged across files.
+type TDigest struct {
+	centroids []centroid
+	buffer    []centroid
+	count     float64
+	min       float64
+	max       float64
+}
+
+// centroid is a cluster of values summarized by their mean and how many there were
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// NewTDigest creates an empty digest
+func NewTDigest() *TDigest {
+	return &TDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add adds a value to the digest
+func (t *TDigest) Add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	t.add(centroid{mean: value, weight: 1}, value, value)
+}
+
+// add buffers a centroid covering values from low to high
+func (t *TDigest) add(c centroid, low, high float64) {
+	t.buffer = append(t.buffer, c)
+	t.count += c.weight
+	t.min = math.Min(t.min, low)
+	t.max = math.Max(t.max, high)
+	if len(t.buffer) >= tDigestBuffer {
+		t.compress()
+	}
+}
+
+// Merge folds another digest into this one
+func (t *TDigest) Merge(other *TDigest) {
+	if other == nil || other.count == 0 {
+		return
+	}
+	for _, c := range other.centroids {
+		t.add(c, other.min, other.max)
+	}
+	for _, c := range other.buffer {
+		t.add(c, other.min, other.max)
+	}
+}
+
+// Count returns the number of values added
+func (t *TDigest) Count() int64 {
+	return int64(t.count)
+}
+
+// Min returns the smallest value added, or 0 when the digest is empty
+func (t *TDigest) Min() float64 {
+	if t.count == 0 {
+		return 0
+	}
+	return t.min
+}
+
+// Max returns the largest value added, or 0 when the digest is empty
+func (t *TDigest) Max() float64 {
+	if t.count == 0 {
+		return 0
+	}
+	return t.max
+}
+
+// Quantile returns the estimated value below which the fraction q of values fall, or 0 when
+// the digest is empty
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	if len(t.centroids) == 1 {
+		return t.centroids[0].mean
+	}
+
+	// Interpolate between the centroids' midpoints, and between the outer midpoints and the
+	// extremes at either end
+	index := q * t.count
+	first := t.centroids[0]
+	if index < first.weight/2 {
+		return t.min + (first.mean-t.min)*index/(first.weight/2)
+	}
+	cumulative := first.weight / 2
+	for i := 0; i < len(t.centroids)-1; i++ {
+		a, b := t.centroids[i], t.centroids[i+1]
+		gap := (a.weight + b.weight) / 2
+		if index < cumulative+gap {
+			return a.mean + (b.mean-a.mean)*(index-cumulative)/gap
+		}
+		cumulative += gap
+	}
+	last := t.centroids[len(t.centroids)-1]
+	return last.mean + (t.max-last.mean)*(index-cumulative)/(last.weight/2)
+}
+
+// compress merges the buffered values into the centroids, keeping centroids small near the
+// tails and large near the median
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	all = append(all, t.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	current := all[0]
+	seen := 0.0
+	limit := tDigestWeightLimit(seen, t.count)
+	for _, c := range all[1:] {
+		if seen+current.weight+c.weight <= limit {
+			current.weight += c.weight
+			current.mean += (c.mean - current.mean) * c.weight / current.weight
+			continue
+		}
+		seen += current.weight
+		merged = append(merged, current)
+		limit = tDigestWeightLimit(seen, t.count)
+		current = c
+	}
+	merged = append(merged, current)
+
+	t.centroids = merged
+	t.buffer = t.buffer[:0]
+}
+
+// tDigestWeightLimit is the cumulative weight a centroid starting after seen of total may grow
+// to, using the arcsine scale function so each centroid spans one unit of k
+func tDigestWeightLimit(seen, total float64) float64 {
+	k := tDigestCompression / (2 * math.Pi) * math.Asin(2*seen/total-1)
+	angle := (k + 1) * 2 * math.Pi / tDigestCompression
+	if angle >= math.Pi/2 {
+		return total
+	}
+	return (math.Sin(angle) + 1) / 2 * total
+}
+
+// tDigestJSON is the stored form of a digest
+type tDigestJSON struct {
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Means   []float64 `json:"means"`
+	Weights []float64 `json:"weights"`
+}
+
+// MarshalJSON encodes the digest's centroids so it can be stored with the summary
+func (t *TDigest) MarshalJSON() ([]byte, error) {
+	// Compress a copy, so encoding a digest never modifies it
+	digest := *t
+	digest.centroids = append([]centroid(nil), t.centroids...)
+	digest.buffer = append([]centroid(nil), t.buffer...)
+	digest.compress()
+
+	encoded := tDigestJSON{
+		Min:     digest.Min(),
+		Max:     digest.Max(),
+		Means:   make([]float64, len(digest.centroids)),
+		Weights: make([]float64, len(digest.centroids)),
+	}
+	for i, c := range digest.centroids {
+		encoded.Means[i] = c.mean
+		encoded.Weights[i] = c.weight
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a digest previously encoded with MarshalJSON
+func (t *TDigest) UnmarshalJSON(data []byte) error {
+	var encoded tDigestJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if len(encoded.Means) != len(encoded.Weights) {
+		return fmt.Errorf("invalid digest: %d means for %d weights", len(encoded.Means), len(encoded.Weights))
+	}
+
+	*t = *NewTDigest()
+	t.centroids = make([]centroid, len(encoded.Means))
+	for i, mean := range encoded.Means {
+		t.centroids[i] = centroid{mean: mean, weight: encoded.Weights[i]}
+		t.count += encoded.Weights[i]
+	}
+	if t.count > 0 {
+		t.min = encoded.Min
+		t.max = encoded.Max
+	}
+	return nil
+}
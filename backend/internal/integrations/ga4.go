@@ -0,0 +1,166 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// ga4Scope grants read access to Google Analytics properties
+const ga4Scope = "https://www.googleapis.com/auth/analytics.readonly"
+
+// ga4BaseURL is the Google Analytics Data API
+const ga4BaseURL = "https://analyticsdata.googleapis.com/v1beta"
+
+// ga4PageSize is how many report rows are requested per page
+const ga4PageSize = 10000
+
+// GA4 imports sessions and key events from Google Analytics 4 properties
+type GA4 struct {
+	oauth   *GoogleOAuth
+	client  *http.Client
+	baseURL string
+}
+
+// NewGA4 creates a Google Analytics 4 client. It returns nil when Google OAuth is disabled.
+func NewGA4(oauth *GoogleOAuth) *GA4 {
+	if oauth == nil {
+		return nil
+	}
+
+	return &GA4{
+		oauth:   oauth,
+		client:  &http.Client{Timeout: 2 * time.Minute},
+		baseURL: ga4BaseURL,
+	}
+}
+
+// AuthURL returns the consent screen URL for connecting a GA4 property
+func (g *GA4) AuthURL(state string) string {
+	return g.oauth.AuthURL(ga4Scope, state)
+}
+
+// NormalizePropertyID accepts a GA4 property ID with or without its "properties/" prefix
+func NormalizePropertyID(propertyID string) (string, error) {
+	normalized := strings.TrimPrefix(strings.TrimSpace(propertyID), "properties/")
+	if _, err := strconv.ParseUint(normalized, 10, 64); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPropertyID, propertyID)
+	}
+	return normalized, nil
+}
+
+// FetchSiteOutcomes pulls daily sessions, key events and revenue by UTM source, medium and
+// campaign between from and to (inclusive)
+func (g *GA4) FetchSiteOutcomes(ctx context.Context, refreshToken, propertyID string, from, to time.Time) ([]models.SiteOutcome, error) {
+	accessToken, err := g.oauth.AccessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := []models.SiteOutcome{}
+	for offset := 0; ; offset += ga4PageSize {
+		body := map[string]interface{}{
+			"dateRanges": []map[string]string{{
+				"startDate": from.Format("2006-01-02"),
+				"endDate":   to.Format("2006-01-02"),
+			}},
+			"dimensions": []map[string]string{
+				{"name": "date"},
+				{"name": "sessionSource"},
+				{"name": "sessionMedium"},
+				{"name": "sessionCampaignName"},
+			},
+			"metrics": []map[string]string{
+				{"name": "sessions"},
+				{"name": "keyEvents"},
+				{"name": "totalRevenue"},
+			},
+			"limit":  ga4PageSize,
+			"offset": offset,
+		}
+
+		var page struct {
+			Rows []struct {
+				DimensionValues []struct {
+					Value string `json:"value"`
+				} `json:"dimensionValues"`
+				MetricValues []struct {
+					Value string `json:"value"`
+				} `json:"metricValues"`
+			} `json:"rows"`
+			RowCount int `json:"rowCount"`
+		}
+		if err := g.runReport(ctx, accessToken, propertyID, body, &page); err != nil {
+			return nil, err
+		}
+
+		for _, row := range page.Rows {
+			if len(row.DimensionValues) != 4 || len(row.MetricValues) != 3 {
+				return nil, fmt.Errorf("unexpected GA4 report row shape")
+			}
+
+			date, err := time.Parse("20060102", row.DimensionValues[0].Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid report date %q: %w", row.DimensionValues[0].Value, err)
+			}
+			sessions, _ := strconv.ParseInt(row.MetricValues[0].Value, 10, 64)
+			keyEvents, _ := strconv.ParseFloat(row.MetricValues[1].Value, 64)
+			revenue, _ := strconv.ParseFloat(row.MetricValues[2].Value, 64)
+
+			outcomes = append(outcomes, models.SiteOutcome{
+				Date:        date,
+				Source:      row.DimensionValues[1].Value,
+				Medium:      row.DimensionValues[2].Value,
+				Campaign:    row.DimensionValues[3].Value,
+				Sessions:    sessions,
+				Conversions: keyEvents,
+				Revenue:     revenue,
+			})
+		}
+
+		if len(page.Rows) == 0 || offset+len(page.Rows) >= page.RowCount {
+			return outcomes, nil
+		}
+	}
+}
+
+// runReport runs a report against a property
+func (g *GA4) runReport(ctx context.Context, accessToken, propertyID string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize report request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/properties/%s:runReport", g.baseURL, propertyID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GA4 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GA4 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GA4 response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// Google OAuth endpoints and scopes
+const (
+	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	googleAdsScope = "https://www.googleapis.com/auth/adwords"
+)
+
+// googleAdsBaseURL is the Google Ads REST API, pinned to a version
+const googleAdsBaseURL = "https://googleads.googleapis.com/v18"
+
+// googleAdsCampaignQuery selects daily campaign performance; the date range is appended
+const googleAdsCampaignQuery = `SELECT campaign.id, campaign.name, segments.date, metrics.impressions, ` +
+	`metrics.clicks, metrics.conversions, metrics.cost_micros FROM campaign`
+
+// GoogleOAuth runs the OAuth authorization code flow for Google APIs
+type GoogleOAuth struct {
+	client       *http.Client
+	clientID     string
+	clientSecret string
+	redirectURL  string
+}
+
+// NewGoogleOAuth creates a Google OAuth client from configuration.
+// It returns nil when Google integrations are disabled.
+func NewGoogleOAuth(cfg config.GoogleConfig) (*GoogleOAuth, error) {
+	if cfg.ClientID == "" {
+		return nil, nil
+	}
+	if cfg.ClientSecret == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("Google integrations require GOOGLE_OAUTH_CLIENT_SECRET and GOOGLE_OAUTH_REDIRECT_URL")
+	}
+
+	return &GoogleOAuth{
+		client:       &http.Client{Timeout: 30 * time.Second},
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		redirectURL:  cfg.RedirectURL,
+	}, nil
+}
+
+// AuthURL returns the consent screen URL for a scope. Offline access with a forced consent
+// prompt makes Google return a refresh token even when the user has connected before.
+func (o *GoogleOAuth) AuthURL(scope, state string) string {
+	params := url.Values{
+		"client_id":     {o.clientID},
+		"redirect_uri":  {o.redirectURL},
+		"response_type": {"code"},
+		"scope":         {scope},
+		"state":         {state},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"},
+	}
+	return googleAuthURL + "?" + params.Encode()
+}
+
+// Exchange trades an authorization code for a refresh token
+func (o *GoogleOAuth) Exchange(ctx context.Context, code string) (string, error) {
+	token, err := o.tokenRequest(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.redirectURL},
+	})
+	if err != nil {
+		return "", err
+	}
+	if token.RefreshToken == "" {
+		return "", fmt.Errorf("Google did not return a refresh token")
+	}
+
+	return token.RefreshToken, nil
+}
+
+// AccessToken gets a short-lived access token using a refresh token
+func (o *GoogleOAuth) AccessToken(ctx context.Context, refreshToken string) (string, error) {
+	token, err := o.tokenRequest(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
+
+// googleToken is the token endpoint's response
+type googleToken struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// tokenRequest posts a grant to the token endpoint
+func (o *GoogleOAuth) tokenRequest(ctx context.Context, form url.Values) (*googleToken, error) {
+	form.Set("client_id", o.clientID)
+	form.Set("client_secret", o.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token googleToken
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+
+	return &token, nil
+}
+
+// GoogleAds pulls campaign performance reports from the Google Ads API
+type GoogleAds struct {
+	oauth           *GoogleOAuth
+	client          *http.Client
+	baseURL         string
+	developerToken  string
+	loginCustomerID string
+}
+
+// NewGoogleAds creates a Google Ads client. It returns nil when Google OAuth is disabled
+// or no developer token is configured.
+func NewGoogleAds(cfg config.GoogleConfig, oauth *GoogleOAuth) *GoogleAds {
+	if oauth == nil || cfg.AdsDeveloperToken == "" {
+		return nil
+	}
+
+	return &GoogleAds{
+		oauth:           oauth,
+		client:          &http.Client{Timeout: 2 * time.Minute},
+		baseURL:         googleAdsBaseURL,
+		developerToken:  cfg.AdsDeveloperToken,
+		loginCustomerID: strings.ReplaceAll(cfg.AdsLoginCustomerID, "-", ""),
+	}
+}
+
+// AuthURL returns the consent screen URL for connecting a Google Ads account
+func (g *GoogleAds) AuthURL(state string) string {
+	return g.oauth.AuthURL(googleAdsScope, state)
+}
+
+// NormalizeCustomerID strips the dashes from a Google Ads customer ID like 123-456-7890
+func NormalizeCustomerID(customerID string) (string, error) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(customerID), "-", "")
+	if len(normalized) != 10 {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCustomerID, customerID)
+	}
+	for _, r := range normalized {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%w: %s", ErrInvalidCustomerID, customerID)
+		}
+	}
+	return normalized, nil
+}
+
+// FetchCampaignPerformance pulls each campaign's daily performance between from and to (inclusive)
+func (g *GoogleAds) FetchCampaignPerformance(ctx context.Context, refreshToken, customerID string, from, to time.Time) ([]models.CampaignPerformance, error) {
+	accessToken, err := g.oauth.AccessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("%s WHERE segments.date BETWEEN '%s' AND '%s'",
+		googleAdsCampaignQuery, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	rows := []models.CampaignPerformance{}
+	pageToken := ""
+	for {
+		var page struct {
+			Results []struct {
+				Campaign struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"campaign"`
+				Segments struct {
+					Date string `json:"date"`
+				} `json:"segments"`
+				Metrics struct {
+					Impressions int64   `json:"impressions,string"`
+					Clicks      int64   `json:"clicks,string"`
+					Conversions float64 `json:"conversions"`
+					CostMicros  int64   `json:"costMicros,string"`
+				} `json:"metrics"`
+			} `json:"results"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+
+		body := map[string]string{"query": query}
+		if pageToken != "" {
+			body["pageToken"] = pageToken
+		}
+		if err := g.search(ctx, accessToken, customerID, body, &page); err != nil {
+			return nil, err
+		}
+
+		for _, result := range page.Results {
+			date, err := time.Parse("2006-01-02", result.Segments.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid report date %q: %w", result.Segments.Date, err)
+			}
+
+			rows = append(rows, models.CampaignPerformance{
+				Provider:     models.IntegrationProviderGoogleAds,
+				CampaignID:   result.Campaign.ID,
+				CampaignName: result.Campaign.Name,
+				Date:         date,
+				Impressions:  result.Metrics.Impressions,
+				Clicks:       result.Metrics.Clicks,
+				Conversions:  result.Metrics.Conversions,
+				CostMicros:   result.Metrics.CostMicros,
+			})
+		}
+
+		if page.NextPageToken == "" {
+			return rows, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// search runs a GAQL query against a customer account
+func (g *GoogleAds) search(ctx context.Context, accessToken, customerID string, body map[string]string, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize query: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/customers/%s/googleAds:search", g.baseURL, customerID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("developer-token", g.developerToken)
+	if g.loginCustomerID != "" {
+		req.Header.Set("login-customer-id", g.loginCustomerID)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Google Ads request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Google Ads returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Google Ads response: %w", err)
+	}
+
+	return nil
+}
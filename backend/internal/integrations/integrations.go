@@ -0,0 +1,121 @@
+package integrations
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// stateTTL is how long a user has to finish an OAuth consent screen
+const stateTTL = 15 * time.Minute
+
+// Integration errors
+var (
+	ErrInvalidState       = errors.New("invalid or expired OAuth state")
+	ErrInvalidCustomerID  = errors.New("invalid Google Ads customer ID")
+	ErrInvalidPropertyID  = errors.New("invalid GA4 property ID")
+	ErrInvalidSpreadsheet = errors.New("invalid Google Sheets spreadsheet")
+)
+
+// TokenCipher encrypts OAuth tokens at rest and seals OAuth state so it can't be forged
+type TokenCipher struct {
+	aead cipher.AEAD
+}
+
+// NewTokenCipher creates a cipher from a base64-encoded 32-byte AES key
+func NewTokenCipher(key string) (*TokenCipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: expected 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return &TokenCipher{aead: aead}, nil
+}
+
+// Encrypt encrypts a value, returning it base64-encoded with its nonce
+func (c *TokenCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func (c *TokenCipher) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// State is carried through an OAuth consent screen to tie the callback to the user who started it
+type State struct {
+	UserID    string `json:"userId"`
+	Provider  string `json:"provider"`
+	AccountID string `json:"accountId"`
+	// Settings are the push integration settings to store once the connection completes
+	Settings  map[string]string `json:"settings,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// SealState encrypts a state for the OAuth state parameter, valid for stateTTL
+func (c *TokenCipher) SealState(state State) (string, error) {
+	state.ExpiresAt = time.Now().Add(stateTTL)
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize state: %w", err)
+	}
+
+	return c.Encrypt(string(payload))
+}
+
+// OpenState decrypts and validates a state returned to the OAuth callback
+func (c *TokenCipher) OpenState(sealed string) (*State, error) {
+	payload, err := c.Decrypt(sealed)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	var state State
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		return nil, ErrInvalidState
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,170 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sheetsScope grants access to the user's spreadsheets
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// sheetsBaseURL is the Google Sheets API
+const sheetsBaseURL = "https://sheets.googleapis.com/v4"
+
+// maxSheetTitle is the longest tab name Google Sheets allows
+const maxSheetTitle = 100
+
+// spreadsheetIDPattern matches a spreadsheet ID, and spreadsheetURLPattern one in a spreadsheet's URL
+var (
+	spreadsheetIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+	spreadsheetURLPattern = regexp.MustCompile(`/spreadsheets/d/([A-Za-z0-9_-]{20,})`)
+)
+
+// GoogleSheets writes reports to tabs of Google Sheets spreadsheets
+type GoogleSheets struct {
+	oauth   *GoogleOAuth
+	client  *http.Client
+	baseURL string
+}
+
+// NewGoogleSheets creates a Google Sheets client. It returns nil when Google OAuth is disabled.
+func NewGoogleSheets(oauth *GoogleOAuth) *GoogleSheets {
+	if oauth == nil {
+		return nil
+	}
+
+	return &GoogleSheets{
+		oauth:   oauth,
+		client:  &http.Client{Timeout: 2 * time.Minute},
+		baseURL: sheetsBaseURL,
+	}
+}
+
+// AuthURL returns the consent screen URL for connecting a spreadsheet
+func (g *GoogleSheets) AuthURL(state string) string {
+	return g.oauth.AuthURL(sheetsScope, state)
+}
+
+// NormalizeSpreadsheetID accepts a spreadsheet ID or the URL of the spreadsheet
+func NormalizeSpreadsheetID(spreadsheet string) (string, error) {
+	spreadsheet = strings.TrimSpace(spreadsheet)
+	if match := spreadsheetURLPattern.FindStringSubmatch(spreadsheet); match != nil {
+		return match[1], nil
+	}
+	if !spreadsheetIDPattern.MatchString(spreadsheet) {
+		return "", fmt.Errorf("%w: %s is not a spreadsheet ID or URL", ErrInvalidSpreadsheet, spreadsheet)
+	}
+	return spreadsheet, nil
+}
+
+// ValidateSheetTitle checks that a tab name is one Google Sheets accepts
+func ValidateSheetTitle(title string) error {
+	if strings.TrimSpace(title) == "" || len([]rune(title)) > maxSheetTitle {
+		return fmt.Errorf("%w: sheet must be a tab name of 1 to %d characters", ErrInvalidSpreadsheet, maxSheetTitle)
+	}
+	return nil
+}
+
+// WriteSheet replaces the contents of a spreadsheet's tab with rows, adding the tab if it
+// doesn't exist. Values are written as they are, so text is never interpreted as a formula.
+func (g *GoogleSheets) WriteSheet(ctx context.Context, refreshToken, spreadsheetID, title string, rows [][]interface{}) error {
+	accessToken, err := g.oauth.AccessToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	var spreadsheet struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := g.do(ctx, accessToken, http.MethodGet, "/spreadsheets/"+spreadsheetID+"?fields=sheets.properties.title", nil, &spreadsheet); err != nil {
+		return err
+	}
+
+	exists := false
+	for _, sheet := range spreadsheet.Sheets {
+		exists = exists || sheet.Properties.Title == title
+	}
+	if !exists {
+		add := map[string]interface{}{
+			"requests": []interface{}{
+				map[string]interface{}{"addSheet": map[string]interface{}{"properties": map[string]string{"title": title}}},
+			},
+		}
+		if err := g.do(ctx, accessToken, http.MethodPost, "/spreadsheets/"+spreadsheetID+":batchUpdate", add, nil); err != nil {
+			return err
+		}
+	}
+
+	// Clear the whole tab first, so rows from a longer earlier report don't linger below this one
+	sheetRange := url.PathEscape(quoteSheetTitle(title))
+	if err := g.do(ctx, accessToken, http.MethodPost, "/spreadsheets/"+spreadsheetID+"/values/"+sheetRange+":clear", map[string]string{}, nil); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	values := map[string]interface{}{
+		"majorDimension": "ROWS",
+		"values":         rows,
+	}
+	return g.do(ctx, accessToken, http.MethodPut, "/spreadsheets/"+spreadsheetID+"/values/"+sheetRange+"?valueInputOption=RAW", values, nil)
+}
+
+// quoteSheetTitle quotes a tab name for A1 notation
+func quoteSheetTitle(title string) string {
+	return "'" + strings.ReplaceAll(title, "'", "''") + "'"
+}
+
+// do sends a Sheets API request, decoding the response into out unless it's nil
+func (g *GoogleSheets) do(ctx context.Context, accessToken, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to serialize request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Google Sheets request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Google Sheets returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Google Sheets response: %w", err)
+	}
+
+	return nil
+}
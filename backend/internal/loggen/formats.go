@@ -0,0 +1,188 @@
+package loggen
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Roles of the columns a bad row can corrupt
+const (
+	colBidTime  = "bid_time"
+	colWinCost  = "win_cost"
+	colCampaign = "campaign"
+)
+
+// column is a header and how to write an event's value under it
+type column struct {
+	name  string
+	role  string
+	value func(e *event) string
+}
+
+// format is a DSP's log layout
+type format struct {
+	columns []column
+	// logsLosses is set for bid logs, which include bids that lost the auction
+	logsLosses bool
+}
+
+// formats are the layouts a generator writes, by name. Each matches a log format the
+// ingestion package detects.
+var formats = map[string]*format{
+	"beeswax": beeswaxFormat,
+	"ttd":     tradeDeskFormat,
+	"dv360":   dv360Format,
+}
+
+// FormatNames lists the formats a generator can write
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// header returns the format's header row
+func (f *format) header() []string {
+	header := make([]string, len(f.columns))
+	for i, c := range f.columns {
+		header[i] = c.name
+	}
+	return header
+}
+
+// fill writes an event's values into row
+func (f *format) fill(row []string, e *event) {
+	for i, c := range f.columns {
+		row[i] = c.value(e)
+	}
+}
+
+// index returns the position of the column with a role
+func (f *format) index(role string) int {
+	return slices.IndexFunc(f.columns, func(c column) bool { return c.role == role })
+}
+
+// beeswaxFormat is a Beeswax bid log, with losing bids and prices in micros per impression
+var beeswaxFormat = &format{
+	logsLosses: true,
+	columns: []column{
+		{"ACCOUNT_ID", "", func(e *event) string { return e.campaign.accountID }},
+		{"AUCTION_ID", "", func(e *event) string { return e.auctionID }},
+		{"CAMPAIGN_ID", colCampaign, func(e *event) string { return e.campaign.id }},
+		{"CREATIVE_ID", "", func(e *event) string { return e.creativeID }},
+		{"USER_ID", "", func(e *event) string { return e.userID }},
+		{"BID_TIME", colBidTime, func(e *event) string { return formatTime(e.bidTime, "2006-01-02 15:04:05.000") }},
+		{"IMPRESSION_TIME", "", func(e *event) string { return formatTime(e.impressionTime, "2006-01-02 15:04:05.000") }},
+		{"BID_PRICE_MICROS_USD", "", func(e *event) string { return cpmMicros(e.bidCPM) }},
+		{"CLEARING_PRICE_MICROS_USD", "", func(e *event) string { return cpmMicros(e.clearingCPM) }},
+		{"WIN_COST_MICROS_USD", colWinCost, func(e *event) string { return cpmMicros(e.winCostCPM) }},
+		{"CLICKS", "", func(e *event) string { return strconv.Itoa(e.clicks) }},
+		{"CONVERSIONS", "", func(e *event) string { return strconv.Itoa(e.conversions) }},
+		{"REVENUE_MICROS_USD", "", func(e *event) string { return strconv.FormatInt(int64(e.revenue*1e6), 10) }},
+		{"DOMAIN", "", func(e *event) string { return e.domain.name }},
+		{"AD_POSITION", "", func(e *event) string { return e.position }},
+		{"GEO_COUNTRY", "", func(e *event) string { return e.geo.country }},
+		{"GEO_REGION", "", func(e *event) string { return e.geo.region }},
+		{"GEO_CITY", "", func(e *event) string { return e.geo.city }},
+		{"GEO_LATITUDE", "", func(e *event) string { return strconv.FormatFloat(e.geo.latitude, 'f', 4, 64) }},
+		{"GEO_LONGITUDE", "", func(e *event) string { return strconv.FormatFloat(e.geo.longitude, 'f', 4, 64) }},
+		{"PLATFORM_DEVICE_TYPE", "", func(e *event) string { return e.device.deviceType }},
+		{"PLATFORM_BROWSER", "", func(e *event) string { return e.browser }},
+		{"PLATFORM_OS", "", func(e *event) string { return e.os }},
+		{"VIEWABILITY_MEASURABLE", "", func(e *event) string { return flag(e.won && e.measurable) }},
+		{"VIEWABLE", "", func(e *event) string { return flag(e.won && e.viewable) }},
+		{"EXCHANGE", "", func(e *event) string { return e.exchange }},
+		{"SELLER_ID", "", func(e *event) string { return e.sellerID }},
+		{"SCHAIN_HOPS", "", func(e *event) string { return strconv.Itoa(e.hops) }},
+	},
+}
+
+// tradeDeskFormat is a Trade Desk impression feed, with CPM prices and partner cost in dollars
+var tradeDeskFormat = &format{
+	columns: []column{
+		{"LogEntryTime", colBidTime, func(e *event) string { return formatTime(e.impressionTime, "2006-01-02T15:04:05") }},
+		{"ImpressionId", "", func(e *event) string { return e.auctionID }},
+		{"AdvertiserId", "", func(e *event) string { return "adv" + e.campaign.accountID }},
+		{"CampaignId", colCampaign, func(e *event) string { return "cmp" + e.campaign.id }},
+		{"CreativeId", "", func(e *event) string { return "cr" + e.creativeID }},
+		{"TDID", "", func(e *event) string { return e.userID }},
+		{"SupplyVendor", "", func(e *event) string { return e.exchange }},
+		{"SupplyVendorPublisherId", "", func(e *event) string { return e.sellerID }},
+		{"Site", "", func(e *event) string { return e.domain.name }},
+		{"FoldPosition", "", func(e *event) string { return e.position }},
+		{"Country", "", func(e *event) string { return e.geo.country }},
+		{"Region", "", func(e *event) string { return e.geo.region }},
+		{"City", "", func(e *event) string { return e.geo.city }},
+		{"Latitude", "", func(e *event) string { return strconv.FormatFloat(e.geo.latitude, 'f', 4, 64) }},
+		{"Longitude", "", func(e *event) string { return strconv.FormatFloat(e.geo.longitude, 'f', 4, 64) }},
+		{"DeviceType", "", func(e *event) string { return e.device.deviceType }},
+		{"OSFamily", "", func(e *event) string { return e.os }},
+		{"Browser", "", func(e *event) string { return e.browser }},
+		{"BidPriceCPMInUSD", "", func(e *event) string { return dollars(e.bidCPM, 4) }},
+		{"MediaCostCPMInUSD", "", func(e *event) string { return dollars(e.clearingCPM, 4) }},
+		{"PartnerCostInUSD", colWinCost, func(e *event) string { return dollars(e.winCostCPM/1000, 6) }},
+		{"Clicks", "", func(e *event) string { return strconv.Itoa(e.clicks) }},
+		{"Conversions", "", func(e *event) string { return strconv.Itoa(e.conversions) }},
+		{"ConversionRevenueInUSD", "", func(e *event) string { return dollars(e.revenue, 2) }},
+	},
+}
+
+// dv360Format is a DV360 Data Transfer impression file, with spaced headers and CPM prices
+var dv360Format = &format{
+	columns: []column{
+		{"Event Time", colBidTime, func(e *event) string { return formatTime(e.impressionTime, "01/02/2006 15:04:05") }},
+		{"Auction ID", "", func(e *event) string { return e.auctionID }},
+		{"Advertiser ID", "", func(e *event) string { return e.campaign.accountID }},
+		{"Insertion Order ID", colCampaign, func(e *event) string { return e.campaign.id }},
+		{"Creative ID", "", func(e *event) string { return e.creativeID }},
+		{"User ID", "", func(e *event) string { return e.userID }},
+		{"Exchange", "", func(e *event) string { return e.exchange }},
+		{"Publisher ID", "", func(e *event) string { return e.sellerID }},
+		{"App/URL", "", func(e *event) string { return e.domain.name }},
+		{"Ad Position", "", func(e *event) string { return e.position }},
+		{"Country", "", func(e *event) string { return e.geo.country }},
+		{"Region", "", func(e *event) string { return e.geo.region }},
+		{"City", "", func(e *event) string { return e.geo.city }},
+		{"Device Type", "", func(e *event) string { return e.device.deviceType }},
+		{"Browser", "", func(e *event) string { return e.browser }},
+		{"Operating System", "", func(e *event) string { return e.os }},
+		{"Bid Price CPM (USD)", "", func(e *event) string { return dollars(e.bidCPM, 4) }},
+		{"Media Cost CPM (USD)", "", func(e *event) string { return dollars(e.clearingCPM, 4) }},
+		{"Total Media Cost (USD)", colWinCost, func(e *event) string { return dollars(e.winCostCPM/1000, 6) }},
+		{"Clicks", "", func(e *event) string { return strconv.Itoa(e.clicks) }},
+		{"Total Conversions", "", func(e *event) string { return strconv.Itoa(e.conversions) }},
+		{"Conversion Revenue (USD)", "", func(e *event) string { return dollars(e.revenue, 2) }},
+		{"Active View: Measurable", "", func(e *event) string { return flag(e.measurable) }},
+		{"Active View: Viewable", "", func(e *event) string { return flag(e.viewable) }},
+	},
+}
+
+// formatTime formats a time, or returns an empty string for the zero time
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// cpmMicros converts a dollar CPM to micros per impression
+func cpmMicros(cpm float64) string {
+	return strconv.FormatInt(int64(cpm*1000), 10)
+}
+
+// dollars formats a dollar amount to a number of decimal places
+func dollars(amount float64, places int) string {
+	return strconv.FormatFloat(amount, 'f', places, 64)
+}
+
+// flag formats a boolean as 1 or 0
+func flag(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
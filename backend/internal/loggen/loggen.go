@@ -0,0 +1,376 @@
+package loggen
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Anomalies that can be injected into a generated log
+const (
+	// AnomalySpendSpike multiplies one campaign's prices five-fold for two hours
+	AnomalySpendSpike = "spend-spike"
+	// AnomalyClickFraud gives a few domains a ~30% click-through rate with no conversions
+	AnomalyClickFraud = "click-fraud"
+	// AnomalyBotTraffic sends a slice of traffic from a handful of users on one device
+	AnomalyBotTraffic = "bot-traffic"
+	// AnomalyBadRows malforms about one row in two hundred
+	AnomalyBadRows = "bad-rows"
+	// AnomalyOutage drops all traffic for three hours
+	AnomalyOutage = "outage"
+)
+
+// Anomalies lists the anomalies a generator can inject
+var Anomalies = []string{AnomalySpendSpike, AnomalyClickFraud, AnomalyBotTraffic, AnomalyBadRows, AnomalyOutage}
+
+// Config controls the size, shape and anomalies of a generated log
+type Config struct {
+	Format    string    // beeswax, ttd or dv360
+	Rows      int       // Rows to write
+	Seed      int64     // The same seed and config write the same log
+	Start     time.Time // First day of traffic
+	Days      int       // Days of traffic
+	Accounts  int       // Advertiser accounts
+	Campaigns int       // Campaigns, spread across the accounts
+	Creatives int       // Creatives per campaign
+	Domains   int       // Distinct domains
+	Users     int       // Distinct users
+	Anomalies []string  // Anomalies to inject
+}
+
+// DefaultConfig returns a week of Beeswax traffic for a small advertiser
+func DefaultConfig() Config {
+	return Config{
+		Format:    "beeswax",
+		Rows:      10000,
+		Seed:      1,
+		Start:     time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -7),
+		Days:      7,
+		Accounts:  3,
+		Campaigns: 20,
+		Creatives: 4,
+		Domains:   500,
+		Users:     50000,
+	}
+}
+
+// Injection describes where an anomaly was injected, so it can be found in the analysis
+type Injection struct {
+	Anomaly string
+	Detail  string
+}
+
+// Generator writes synthetic DSP logs. Traffic follows a daily curve, domain and campaign
+// popularity is long-tailed, and prices, click-through and conversion rates vary by campaign.
+type Generator struct {
+	cfg    Config
+	format *format
+	rng    *rand.Rand
+	world  *world
+
+	// cumulative is the running total of each hour's relative traffic from Start, for sampling
+	cumulative []float64
+
+	domainPicker   *rand.Zipf
+	campaignPicker *rand.Zipf
+	userPicker     *rand.Zipf
+
+	spikeCampaign  int
+	spikeFrom      time.Time
+	spikeTo        time.Time
+	fraudDomains   map[int]bool
+	botUsers       []string
+	outageFrom     time.Time
+	outageTo       time.Time
+	injections     []Injection
+	anomalyEnabled map[string]bool
+}
+
+// New creates a generator, checking the config
+func New(cfg Config) (*Generator, error) {
+	f, ok := formats[cfg.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format: %s (use %s)", cfg.Format, strings.Join(FormatNames(), ", "))
+	}
+	if cfg.Days < 1 || cfg.Accounts < 1 || cfg.Campaigns < 1 || cfg.Creatives < 1 || cfg.Domains < 2 || cfg.Users < 2 {
+		return nil, fmt.Errorf("days, accounts, campaigns and creatives must be at least 1, and domains and users at least 2")
+	}
+	enabled := make(map[string]bool, len(cfg.Anomalies))
+	for _, anomaly := range cfg.Anomalies {
+		if !slices.Contains(Anomalies, anomaly) {
+			return nil, fmt.Errorf("unknown anomaly: %s (use %s)", anomaly, strings.Join(Anomalies, ", "))
+		}
+		enabled[anomaly] = true
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	g := &Generator{
+		cfg:            cfg,
+		format:         f,
+		rng:            rng,
+		world:          newWorld(rng, cfg),
+		domainPicker:   rand.NewZipf(rng, 1.1, 1, uint64(cfg.Domains-1)),
+		userPicker:     rand.NewZipf(rng, 1.05, 10, uint64(cfg.Users-1)),
+		anomalyEnabled: enabled,
+	}
+	if cfg.Campaigns > 1 {
+		g.campaignPicker = rand.NewZipf(rng, 1.2, 2, uint64(cfg.Campaigns-1))
+	}
+	g.planAnomalies()
+	g.buildTrafficCurve()
+	return g, nil
+}
+
+// Injections describes the anomalies the generator injects
+func (g *Generator) Injections() []Injection {
+	return g.injections
+}
+
+// Write writes the log as CSV, returning the number of bytes written
+func (g *Generator) Write(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriterSize(counter, 1<<16)
+	csvWriter := csv.NewWriter(buffered)
+
+	if err := csvWriter.Write(g.format.header()); err != nil {
+		return counter.n, err
+	}
+	row := make([]string, len(g.format.columns))
+	for i := 0; i < g.cfg.Rows; i++ {
+		e := g.event(g.timeAt(i))
+		g.format.fill(row, e)
+		if e.malformed {
+			g.malform(row)
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return counter.n, err
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return counter.n, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return counter.n, err
+	}
+	return counter.n, nil
+}
+
+// RowsForSize estimates how many rows make a log of about size bytes, by generating a sample
+// with the same config
+func RowsForSize(cfg Config, size int64) (int, error) {
+	const sampleRows = 2000
+
+	sample := cfg
+	sample.Rows = sampleRows
+	g, err := New(sample)
+	if err != nil {
+		return 0, err
+	}
+	written, err := g.Write(io.Discard)
+	if err != nil {
+		return 0, err
+	}
+	perRow := float64(written) / sampleRows
+	return max(1, int(float64(size)/perRow)), nil
+}
+
+// planAnomalies picks where each enabled anomaly goes, away from the first and last day so
+// the detectors have a baseline on either side
+func (g *Generator) planAnomalies() {
+	middle := g.cfg.Start.AddDate(0, 0, g.cfg.Days/2)
+
+	if g.anomalyEnabled[AnomalySpendSpike] {
+		g.spikeCampaign = g.rng.Intn(min(g.cfg.Campaigns, 3))
+		g.spikeFrom = middle.Add(14 * time.Hour)
+		g.spikeTo = g.spikeFrom.Add(2 * time.Hour)
+		g.inject(AnomalySpendSpike, "campaign %s prices x5 from %s to %s",
+			g.world.campaigns[g.spikeCampaign].id, formatHour(g.spikeFrom), formatHour(g.spikeTo))
+	}
+	if g.anomalyEnabled[AnomalyClickFraud] {
+		// Mid-popularity domains, so the fraud has volume without dominating the log
+		g.fraudDomains = make(map[int]bool)
+		var names []string
+		for _, idx := range []int{5, 8, 13} {
+			if idx < g.cfg.Domains {
+				g.fraudDomains[idx] = true
+				names = append(names, g.world.domains[idx].name)
+			}
+		}
+		g.inject(AnomalyClickFraud, "domains %s at ~30%% CTR with no conversions", strings.Join(names, ", "))
+	}
+	if g.anomalyEnabled[AnomalyBotTraffic] {
+		for i := 0; i < 5; i++ {
+			g.botUsers = append(g.botUsers, fmt.Sprintf("bot-%08x", g.rng.Uint32()))
+		}
+		g.inject(AnomalyBotTraffic, "3%% of impressions from users %s", strings.Join(g.botUsers, ", "))
+	}
+	if g.anomalyEnabled[AnomalyBadRows] {
+		g.inject(AnomalyBadRows, "about 0.5%% of rows with malformed timestamps, prices or IDs")
+	}
+	if g.anomalyEnabled[AnomalyOutage] {
+		day := g.cfg.Start.AddDate(0, 0, max(0, g.cfg.Days-2))
+		g.outageFrom = day.Add(9 * time.Hour)
+		g.outageTo = g.outageFrom.Add(3 * time.Hour)
+		g.inject(AnomalyOutage, "no traffic from %s to %s", formatHour(g.outageFrom), formatHour(g.outageTo))
+	}
+}
+
+// inject records where an anomaly was injected
+func (g *Generator) inject(anomaly, detail string, args ...any) {
+	g.injections = append(g.injections, Injection{Anomaly: anomaly, Detail: fmt.Sprintf(detail, args...)})
+}
+
+// hourlyTraffic is the share of a day's traffic in each UTC hour, peaking in the evening
+var hourlyTraffic = [24]float64{
+	0.45, 0.30, 0.20, 0.15, 0.15, 0.20, 0.35, 0.55, 0.75, 0.85, 0.90, 0.95,
+	1.00, 0.95, 0.90, 0.90, 0.95, 1.05, 1.20, 1.35, 1.45, 1.40, 1.10, 0.75,
+}
+
+// buildTrafficCurve weights each hour of the range by the daily curve, with quieter weekends
+// and no traffic during an outage
+func (g *Generator) buildTrafficCurve() {
+	hours := g.cfg.Days * 24
+	g.cumulative = make([]float64, hours)
+	total := 0.0
+	for h := 0; h < hours; h++ {
+		at := g.cfg.Start.Add(time.Duration(h) * time.Hour)
+		weight := hourlyTraffic[at.Hour()]
+		if day := at.Weekday(); day == time.Saturday || day == time.Sunday {
+			weight *= 0.8
+		}
+		if !at.Before(g.outageFrom) && at.Before(g.outageTo) {
+			weight = 0
+		}
+		total += weight
+		g.cumulative[h] = total
+	}
+}
+
+// timeAt returns the time of row i, so rows are in time order and spread along the curve
+func (g *Generator) timeAt(i int) time.Time {
+	total := g.cumulative[len(g.cumulative)-1]
+	target := (float64(i) + g.rng.Float64()) / float64(g.cfg.Rows) * total
+	hour := sort.SearchFloat64s(g.cumulative, target)
+	hour = min(hour, len(g.cumulative)-1)
+
+	start := 0.0
+	if hour > 0 {
+		start = g.cumulative[hour-1]
+	}
+	fraction := 0.0
+	if width := g.cumulative[hour] - start; width > 0 {
+		fraction = (target - start) / width
+	}
+	offset := time.Duration((float64(hour) + fraction) * float64(time.Hour))
+	return g.cfg.Start.Add(offset).Truncate(time.Millisecond)
+}
+
+// event generates one auction at the given time. Formats that only log won impressions get
+// only wins.
+func (g *Generator) event(at time.Time) *event {
+	w := g.world
+	e := &event{
+		auctionID: fmt.Sprintf("%016x%016x", g.rng.Uint64(), g.rng.Uint64()),
+		bidTime:   at,
+	}
+
+	campaignIdx := 0
+	if g.campaignPicker != nil {
+		campaignIdx = int(g.campaignPicker.Uint64())
+	}
+	c := w.campaigns[campaignIdx]
+	e.campaign = c
+	e.creativeID = c.creatives[g.rng.Intn(len(c.creatives))]
+
+	domainIdx := int(g.domainPicker.Uint64())
+	d := w.domains[domainIdx]
+	e.domain = d
+	e.position = pick(g.rng, adPositions)
+	e.geo = pickWeighted(g.rng, geos, func(x geo) float64 { return x.weight })
+	e.device = pickWeighted(g.rng, devices, func(x device) float64 { return x.weight })
+	e.browser = pick(g.rng, e.device.browsers)
+	e.os = pick(g.rng, e.device.oses)
+	e.userID = w.userID(int(g.userPicker.Uint64()))
+
+	bot := len(g.botUsers) > 0 && g.rng.Float64() < 0.03
+	if bot {
+		e.userID = g.botUsers[g.rng.Intn(len(g.botUsers))]
+		e.device = devices[0]
+		e.browser = "Chrome"
+		e.os = "Linux"
+	}
+
+	// Bids are lognormal around the campaign's CPM; the clearing price is a second price below it
+	e.bidCPM = c.cpm * math.Exp(g.rng.NormFloat64()*0.25)
+	if !at.Before(g.spikeFrom) && at.Before(g.spikeTo) && campaignIdx == g.spikeCampaign {
+		e.bidCPM *= 5
+	}
+	e.won = !g.format.logsLosses || g.rng.Float64() < 0.35
+	if e.won {
+		e.impressionTime = at.Add(time.Duration(50+g.rng.Intn(400)) * time.Millisecond)
+		e.clearingCPM = e.bidCPM * (0.55 + 0.4*g.rng.Float64())
+		e.winCostCPM = e.clearingCPM * 1.12
+
+		e.measurable = g.rng.Float64() < 0.85
+		e.viewable = e.measurable && !bot && g.rng.Float64() < d.viewability
+
+		ctr := c.ctr * e.device.ctrFactor
+		if g.fraudDomains[domainIdx] {
+			ctr = 0.3
+		}
+		if bot {
+			ctr = 0.002
+		}
+		if g.rng.Float64() < ctr {
+			e.clicks = 1
+			if !g.fraudDomains[domainIdx] && !bot && g.rng.Float64() < c.cvr {
+				e.conversions = 1
+				e.revenue = c.orderValue * math.Exp(g.rng.NormFloat64()*0.4)
+			}
+		}
+	}
+
+	e.exchange = d.exchange
+	e.sellerID = d.sellerID
+	e.hops = d.hops
+	e.malformed = g.anomalyEnabled[AnomalyBadRows] && g.rng.Float64() < 0.005
+	return e
+}
+
+// malform corrupts one field of a row the way broken exports do
+func (g *Generator) malform(row []string) {
+	f := g.format
+	switch g.rng.Intn(3) {
+	case 0:
+		row[f.index(colBidTime)] = "not-a-timestamp"
+	case 1:
+		row[f.index(colWinCost)] = "N/A"
+	default:
+		row[f.index(colCampaign)] = ""
+	}
+}
+
+// formatHour formats a time for the injection summary
+func formatHour(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
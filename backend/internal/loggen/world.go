@@ -0,0 +1,188 @@
+package loggen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// world is the advertisers, campaigns and inventory a generated log's auctions draw from
+type world struct {
+	campaigns []campaign
+	domains   []domain
+	userSalt  uint32
+}
+
+// campaign is a campaign with its own price level and performance
+type campaign struct {
+	id         string
+	accountID  string
+	creatives  []string
+	cpm        float64 // Typical bid, in dollars CPM
+	ctr        float64 // Click-through rate on desktop
+	cvr        float64 // Conversions per click
+	orderValue float64 // Typical conversion revenue, in dollars
+}
+
+// domain is a site with the supply path it's bought through
+type domain struct {
+	name        string
+	exchange    string
+	sellerID    string
+	hops        int
+	viewability float64
+}
+
+// geo is a city traffic comes from, weighted by its share
+type geo struct {
+	country, region, city string
+	latitude, longitude   float64
+	weight                float64
+}
+
+// device is a device type with the browsers and operating systems seen on it
+type device struct {
+	deviceType string
+	browsers   []string
+	oses       []string
+	ctrFactor  float64
+	weight     float64
+}
+
+// event is one generated auction
+type event struct {
+	auctionID      string
+	campaign       campaign
+	creativeID     string
+	userID         string
+	bidTime        time.Time
+	impressionTime time.Time // Zero when the bid lost
+	won            bool
+	bidCPM         float64
+	clearingCPM    float64
+	winCostCPM     float64
+	clicks         int
+	conversions    int
+	revenue        float64
+	domain         domain
+	position       string
+	geo            geo
+	device         device
+	browser        string
+	os             string
+	measurable     bool
+	viewable       bool
+	exchange       string
+	sellerID       string
+	hops           int
+	malformed      bool
+}
+
+var geos = []geo{
+	{"US", "NY", "New York", 40.7128, -74.0060, 14},
+	{"US", "CA", "Los Angeles", 34.0522, -118.2437, 10},
+	{"US", "IL", "Chicago", 41.8781, -87.6298, 7},
+	{"US", "TX", "Houston", 29.7604, -95.3698, 6},
+	{"US", "WA", "Seattle", 47.6062, -122.3321, 4},
+	{"US", "FL", "Miami", 25.7617, -80.1918, 4},
+	{"US", "GA", "Atlanta", 33.7490, -84.3880, 4},
+	{"CA", "ON", "Toronto", 43.6532, -79.3832, 5},
+	{"GB", "ENG", "London", 51.5074, -0.1278, 6},
+	{"DE", "BE", "Berlin", 52.5200, 13.4050, 3},
+	{"FR", "IDF", "Paris", 48.8566, 2.3522, 3},
+	{"AU", "NSW", "Sydney", -33.8688, 151.2093, 2},
+}
+
+var devices = []device{
+	{"Desktop", []string{"Chrome", "Safari", "Edge", "Firefox"}, []string{"Windows", "macOS", "Linux"}, 1.0, 38},
+	{"Mobile", []string{"Chrome", "Safari", "Samsung Internet"}, []string{"iOS", "Android"}, 0.7, 48},
+	{"Tablet", []string{"Safari", "Chrome"}, []string{"iOS", "Android"}, 0.9, 9},
+	{"Connected TV", []string{"Roku", "Fire TV", "Tizen"}, []string{"Roku OS", "Fire OS", "Tizen"}, 0.1, 5},
+}
+
+var adPositions = []string{"ABOVE_THE_FOLD", "ABOVE_THE_FOLD", "BELOW_THE_FOLD", "UNKNOWN"}
+
+var exchanges = []string{"Google AdX", "Magnite", "PubMatic", "Index Exchange", "OpenX", "Xandr", "TripleLift"}
+
+// Domain names are built from these parts
+var (
+	domainWords = []string{
+		"daily", "metro", "tech", "sports", "food", "travel", "home", "style", "money", "health",
+		"auto", "game", "movie", "music", "weather", "news", "garden", "parent", "outdoor", "science",
+	}
+	domainNouns = []string{"times", "hub", "wire", "digest", "post", "central", "world", "guide", "insider", "report"}
+	domainTLDs  = []string{".com", ".com", ".com", ".net", ".org", ".co.uk", ".io"}
+)
+
+// newWorld builds the campaigns and domains for a config
+func newWorld(rng *rand.Rand, cfg Config) *world {
+	w := &world{userSalt: rng.Uint32()}
+
+	for i := 0; i < cfg.Campaigns; i++ {
+		c := campaign{
+			id:         fmt.Sprintf("%d", 20000+i),
+			accountID:  fmt.Sprintf("%d", 1000+i%cfg.Accounts),
+			cpm:        1.5 * math.Exp(rng.NormFloat64()*0.5),
+			ctr:        0.002 + rng.Float64()*0.004,
+			cvr:        0.05 + rng.Float64()*0.1,
+			orderValue: 20 + rng.Float64()*80,
+		}
+		for j := 0; j < cfg.Creatives; j++ {
+			c.creatives = append(c.creatives, fmt.Sprintf("%d", 300000+i*100+j))
+		}
+		w.campaigns = append(w.campaigns, c)
+	}
+
+	seen := make(map[string]bool, cfg.Domains)
+	for len(w.domains) < cfg.Domains {
+		name := pick(rng, domainWords) + pick(rng, domainNouns) + pick(rng, domainTLDs)
+		if seen[name] {
+			// The word combinations run out before large domain counts do
+			name = fmt.Sprintf("%s%d%s", pick(rng, domainWords), len(w.domains), pick(rng, domainTLDs))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		hops := 1
+		if rng.Float64() < 0.4 {
+			hops = 2 + rng.Intn(2)
+		}
+		w.domains = append(w.domains, domain{
+			name:        name,
+			exchange:    pick(rng, exchanges),
+			sellerID:    fmt.Sprintf("pub-%06d", rng.Intn(1000000)),
+			hops:        hops,
+			viewability: 0.4 + rng.Float64()*0.4,
+		})
+	}
+	return w
+}
+
+// userID returns a stable, opaque ID for the user at an index
+func (w *world) userID(idx int) string {
+	return fmt.Sprintf("%08x-%04x", w.userSalt^uint32(idx*2654435761), idx%0xffff)
+}
+
+// pick returns a random element of values
+func pick[T any](rng *rand.Rand, values []T) T {
+	return values[rng.Intn(len(values))]
+}
+
+// pickWeighted returns a random element of values, in proportion to its weight
+func pickWeighted[T any](rng *rand.Rand, values []T, weight func(T) float64) T {
+	total := 0.0
+	for _, v := range values {
+		total += weight(v)
+	}
+	target := rng.Float64() * total
+	for _, v := range values {
+		target -= weight(v)
+		if target < 0 {
+			return v
+		}
+	}
+	return values[len(values)-1]
+}
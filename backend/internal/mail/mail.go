@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+)
+
+// Message is an HTML email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Sender delivers email
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPSender delivers email through an SMTP relay, upgrading to TLS when the relay supports it
+type SMTPSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender creates an SMTP sender from configuration.
+// It returns nil when email is disabled.
+func NewSMTPSender(cfg config.EmailConfig) (*SMTPSender, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("sending email requires EMAIL_FROM")
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return &SMTPSender{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host: cfg.SMTPHost,
+		auth: auth,
+		from: cfg.From,
+	}, nil
+}
+
+// Send delivers a message. net/smtp takes no context, so a canceled context only stops
+// messages that haven't started sending.
+func (s *SMTPSender) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(message.To, "\r\n") {
+		return fmt.Errorf("invalid recipient address %q", message.To)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", message.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	body.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(message.HTML, "\n", "\r\n"))
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{message.To}, body.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
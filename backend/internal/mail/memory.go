@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemorySender keeps messages in memory instead of delivering them, so what would have been
+// emailed can be inspected, such as in service-level tests
+type MemorySender struct {
+	mu   sync.Mutex
+	sent []Message
+	// Err, when set, fails every send
+	Err error
+}
+
+// NewMemorySender creates a sender that has sent nothing
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Send records a message
+func (s *MemorySender) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return s.Err
+	}
+	s.sent = append(s.sent, message)
+	return nil
+}
+
+// Sent returns the messages sent so far, in order
+func (s *MemorySender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.sent)
+}
+
+// Reset forgets the messages sent so far
+func (s *MemorySender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey is a long-lived key BI tools read a user's metrics feed with, where a signed-in session
+// isn't available. Only a hash of the key is stored.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, shown so users can tell their keys apart
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"` // Only set when the key is created
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// Upload batch statuses, derived from the statuses of the batch's files
+const (
+	BatchStatusProcessing = "processing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusPartial    = "partial"
+	BatchStatusFailed     = "failed"
+)
+
+// UploadBatch is a set of files uploaded in one request, such as a day's hourly log drops
+type UploadBatch struct {
+	ID             string         `json:"id"`
+	UserID         string         `json:"userId"`
+	IdempotencyKey string         `json:"-"`
+	Status         string         `json:"status"`
+	Files          []BatchFile    `json:"files"`
+	Rejected       []RejectedFile `json:"rejected"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	// Replayed is set when the batch was returned for a repeated idempotency key
+	Replayed bool `json:"-"`
+}
+
+// BatchFile is a file accepted into a batch, with its processing job
+type BatchFile struct {
+	FileID   string `json:"fileId"`
+	JobID    string `json:"jobId"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	Status   string `json:"status"`
+	Priority string `json:"priority"`
+}
+
+// RejectedFile is a file of a batch that wasn't accepted
+type RejectedFile struct {
+	FileName string `json:"fileName"`
+	Error    string `json:"error"`
+}
+
+// SetStatus derives the batch's status: processing until every file has finished, then
+// completed, failed, or partial when only some files failed
+func (b *UploadBatch) SetStatus() {
+	processed, failed := 0, 0
+	for _, file := range b.Files {
+		switch file.Status {
+		case FileStatusProcessed:
+			processed++
+		case FileStatusFailed, FileStatusCanceled:
+			failed++
+		default:
+			b.Status = BatchStatusProcessing
+			return
+		}
+	}
+
+	switch {
+	case len(b.Files) == 0 || processed == 0:
+		b.Status = BatchStatusFailed
+	case failed == 0 && len(b.Rejected) == 0:
+		b.Status = BatchStatusCompleted
+	default:
+		b.Status = BatchStatusPartial
+	}
+}
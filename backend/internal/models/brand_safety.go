@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Brand safety list types
+const (
+	BrandSafetyListBlock   = "block"
+	BrandSafetyListAllow   = "allow"
+	BrandSafetyListKeyword = "keyword"
+)
+
+// BrandSafetyList is a user's block list, allow list or keyword set that processed files are screened against
+type BrandSafetyList struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Entries   []string  `json:"entries"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// CustomMetric is a derived metric an organization defines with a formula over base metrics,
+// such as an effective CPM of "spend / viewable impressions * 1000"
+type CustomMetric struct {
+	OrgID       string    `json:"-"`
+	Name        string    `json:"name"`    // Identifier the metric is reported under, such as effective_cpm
+	Formula     string    `json:"formula"` // Expression over the base metrics
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
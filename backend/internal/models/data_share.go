@@ -0,0 +1,36 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// DataShare is a token an org gives a third party, such as a measurement partner, to read the
+// delivery of some of its campaigns over a date range, without access to anything else. Only a
+// hash of the token is stored.
+type DataShare struct {
+	ID    string `json:"id"`
+	OrgID string `json:"-"`
+	// UserID is the member who minted the share; the share reads their delivery
+	UserID      string   `json:"userId"`
+	Name        string   `json:"name"`
+	CampaignIDs []string `json:"campaignIds"`
+	// From and To bound the days the share exposes, inclusive; either may be open
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Token     string     `json:"token,omitempty"` // Only set when the share is created
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the share's token can still be used
+func (s *DataShare) Active(now time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// Includes reports whether the share exposes a campaign
+func (s *DataShare) Includes(campaignID string) bool {
+	return slices.Contains(s.CampaignIDs, campaignID)
+}
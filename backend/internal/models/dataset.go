@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Dataset groups log files from the same campaign or source so they can be analyzed together.
+// Files are appended as they arrive, typically one per day.
+type Dataset struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	Name       string    `json:"name"`
+	CampaignID string    `json:"campaignId,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
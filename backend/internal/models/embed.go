@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// Embed widgets, the charts an embed can show
+const (
+	EmbedWidgetDaily     = "daily"
+	EmbedWidgetCampaigns = "campaigns"
+	EmbedWidgetDomains   = "domains"
+	EmbedWidgetDevices   = "devices"
+	EmbedWidgetGeo       = "geo"
+)
+
+// Embed is a tokenized link to one chart of a processed file, for showing in dashboards and
+// client portals without signing in. Only a hash of the token is stored.
+type Embed struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"-"`
+	Name      string     `json:"name"`
+	Widget    string     `json:"widget"`
+	FileID    string     `json:"fileId"`
+	Metric    string     `json:"metric"`
+	Limit     int        `json:"limit,omitempty"` // Top values of a bar chart
+	Token     string     `json:"token,omitempty"` // Only set when the embed is created
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the embed's token can still be used
+func (e *Embed) Active(now time.Time) bool {
+	return e.RevokedAt == nil && (e.ExpiresAt == nil || now.Before(*e.ExpiresAt))
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// Experiment dimensions, what an experiment's groups are made of
+const (
+	ExperimentByCreative = "creative"
+	ExperimentByCampaign = "campaign" // Campaigns standing for strategies, such as line items
+)
+
+// Experiment metrics, the rates an experiment compares between its groups
+const (
+	ExperimentMetricCTR = "ctr"
+	ExperimentMetricCVR = "cvr"
+)
+
+// Experiment is an A/B test a user defines over their delivery: two groups of creatives or
+// campaigns compared over a date range, with the result of its latest analysis
+type Experiment struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"-"`
+	Name      string          `json:"name"`
+	Dimension string          `json:"dimension"`
+	Control   ExperimentGroup `json:"control"`
+	Variant   ExperimentGroup `json:"variant"`
+	// From and To are the first and last days of the experiment, inclusive
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Confidence is the level of the lift's confidence intervals, such as 0.95
+	Confidence float64           `json:"confidence"`
+	Result     *ExperimentResult `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// ExperimentGroup is one side of an experiment, the creative or campaign IDs delivered to it
+type ExperimentGroup struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ExperimentResult is an analysis of an experiment: each group's delivery over its dates and
+// the variant's lift over the control
+type ExperimentResult struct {
+	Control ExperimentArm `json:"control"`
+	Variant ExperimentArm `json:"variant"`
+	Lifts   []MetricLift  `json:"lifts"`
+	// PendingFiles counts processed files without rollups, whose delivery is left out
+	PendingFiles int       `json:"pendingFiles"`
+	AnalyzedAt   time.Time `json:"analyzedAt"`
+}
+
+// ExperimentArm is a group's delivery over an experiment's dates. Rates are in percent; CVR is
+// conversions per impression.
+type ExperimentArm struct {
+	Impressions int     `json:"impressions"`
+	Clicks      int     `json:"clicks"`
+	Conversions int     `json:"conversions"`
+	Spend       float64 `json:"spend"`
+	CTR         float64 `json:"ctr"`
+	CVR         float64 `json:"cvr"`
+}
+
+// MetricLift is the variant's lift over the control in a rate, in percent. The lift and its
+// interval are left out when a group has no clicks or conversions to compare.
+type MetricLift struct {
+	Metric  string   `json:"metric"`
+	Control float64  `json:"control"`
+	Variant float64  `json:"variant"`
+	Lift    *float64 `json:"lift,omitempty"`
+	Lower   *float64 `json:"lower,omitempty"`
+	Upper   *float64 `json:"upper,omitempty"`
+	PValue  *float64 `json:"pValue,omitempty"`
+	// Significant is set when the interval excludes no lift
+	Significant bool `json:"significant"`
+}
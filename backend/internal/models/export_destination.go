@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// Export destination statuses
+const (
+	ExportStatusActive = "active"
+	ExportStatusError  = "error"
+)
+
+// Export table sync statuses
+const (
+	ExportTablePending   = "pending"
+	ExportTableSyncing   = "syncing"
+	ExportTableSucceeded = "succeeded"
+	ExportTableFailed    = "failed"
+)
+
+// ExportDestination is a data warehouse a user's rollups and files are exported to on a schedule
+type ExportDestination struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	// Settings are the provider's connection settings, such as the account and schema
+	Settings map[string]string `json:"settings"`
+	// Credentials are the provider's encrypted secrets, stored as a JSON object; they never
+	// leave the server
+	Credentials  string     `json:"-"`
+	Status       string     `json:"status"`
+	LastError    string     `json:"lastError,omitempty"`
+	LastSyncedAt *time.Time `json:"lastSyncedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// ExportTableSync is the state of one table's export to a destination
+type ExportTableSync struct {
+	DestinationID string `json:"destinationId"`
+	Table         string `json:"table"`
+	Status        string `json:"status"`
+	// Rows is how many rows the last successful sync loaded
+	Rows      int64  `json:"rows"`
+	LastError string `json:"lastError,omitempty"`
+	// Cursor is where the next incremental sync starts from; full refreshes don't use one
+	Cursor          *time.Time `json:"cursor,omitempty"`
+	LastSyncedAt    *time.Time `json:"lastSyncedAt,omitempty"`
+	LastSucceededAt *time.Time `json:"lastSucceededAt,omitempty"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// File statuses
+const (
+	FileStatusUploaded   = "uploaded"
+	FileStatusProcessing = "processing"
+	FileStatusProcessed  = "processed"
+	FileStatusFailed     = "failed"
+	FileStatusCanceled   = "canceled"
+)
+
+// File represents the metadata of an uploaded file
+type File struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	FileName   string    `json:"fileName"`
+	FileSize   int64     `json:"fileSize"`
+	FileType   string    `json:"fileType"`
+	FilePath   string    `json:"-"` // Internal use only
+	Status     string    `json:"status"`
+	UploadedAt time.Time `json:"uploadedAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// Tags label the file for finding it later, such as by campaign or client
+	Tags []string `json:"tags"`
+	// Folder is the slash-separated folder the file is filed under; empty for the top level
+	Folder string `json:"folder,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey records the file and job created by a request carrying an Idempotency-Key header
+type IdempotencyKey struct {
+	UserID    string    `json:"userId"`
+	Key       string    `json:"key"`
+	FileID    string    `json:"fileId"`
+	JobID     string    `json:"jobId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
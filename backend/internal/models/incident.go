@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Incident severities
+const (
+	IncidentMinor       = "minor"
+	IncidentMajor       = "major"
+	IncidentMaintenance = "maintenance"
+)
+
+// Incident is an admin-posted notice shown on the public status page, such as slow processing
+// or planned maintenance
+type Incident struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Message    string     `json:"message"`
+	Severity   string     `json:"severity"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// ValidIncidentSeverity reports whether s is an incident severity
+func ValidIncidentSeverity(s string) bool {
+	return s == IncidentMinor || s == IncidentMajor || s == IncidentMaintenance
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// Integration providers
+const (
+	IntegrationProviderGoogleAds = "google_ads"
+	IntegrationProviderGA4       = "ga4"
+	// IntegrationProviderGoogleSheets pushes a report to a spreadsheet tab instead of pulling one
+	IntegrationProviderGoogleSheets = "google_sheets"
+)
+
+// Integration statuses
+const (
+	IntegrationStatusActive = "active"
+	IntegrationStatusError  = "error"
+)
+
+// Integration is a user's connection to an ad platform account whose reports are pulled on a
+// schedule, or to a spreadsheet a report is pushed to
+type Integration struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Provider  string `json:"provider"`
+	AccountID string `json:"accountId"`
+	// Settings configure what a push integration sends, such as a Google Sheets report's tab and query
+	Settings map[string]string `json:"settings,omitempty"`
+	// RefreshToken is the encrypted OAuth refresh token; it never leaves the server
+	RefreshToken string `json:"-"`
+	Status       string `json:"status"`
+	LastError    string `json:"lastError,omitempty"`
+	// LastSyncedAt is when the last sync ran; Status and LastError tell whether it succeeded
+	LastSyncedAt *time.Time `json:"lastSyncedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// CampaignPerformance is one day of a campaign's delivery pulled from an ad platform
+type CampaignPerformance struct {
+	IntegrationID string    `json:"integrationId"`
+	UserID        string    `json:"userId"`
+	Provider      string    `json:"provider"`
+	CampaignID    string    `json:"campaignId"`
+	CampaignName  string    `json:"campaignName"`
+	Date          time.Time `json:"date"`
+	Impressions   int64     `json:"impressions"`
+	Clicks        int64     `json:"clicks"`
+	Conversions   float64   `json:"conversions"`
+	CostMicros    int64     `json:"costMicros"`
+}
+
+// SiteOutcome is one day of on-site sessions and conversions for a UTM source, medium and
+// campaign, pulled from a web analytics property
+type SiteOutcome struct {
+	IntegrationID string    `json:"integrationId"`
+	UserID        string    `json:"userId"`
+	Date          time.Time `json:"date"`
+	Source        string    `json:"source"`
+	Medium        string    `json:"medium"`
+	Campaign      string    `json:"campaign"`
+	Sessions      int64     `json:"sessions"`
+	Conversions   float64   `json:"conversions"`
+	Revenue       float64   `json:"revenue"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+)
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCanceled  = "canceled"
+)
+
+// Job priorities; the queue runs higher priority jobs first
+const (
+	JobPriorityLow    = "low"
+	JobPriorityNormal = "normal"
+	JobPriorityHigh   = "high"
+)
+
+// jobPriorityRanks orders the job priorities
+var jobPriorityRanks = map[string]int{
+	JobPriorityLow:    0,
+	JobPriorityNormal: 1,
+	JobPriorityHigh:   2,
+}
+
+// ValidJobPriority reports whether p is a job priority
+func ValidJobPriority(p string) bool {
+	_, ok := jobPriorityRanks[p]
+	return ok
+}
+
+// JobPriorityRank returns a priority's place in the queue order, higher first; unknown priorities rank as normal
+func JobPriorityRank(p string) int {
+	if rank, ok := jobPriorityRanks[p]; ok {
+		return rank
+	}
+	return jobPriorityRanks[JobPriorityNormal]
+}
+
+// ProcessingJob represents a queued request to process an uploaded file
+type ProcessingJob struct {
+	ID          string     `json:"id"`
+	FileID      string     `json:"fileId"`
+	UserID      string     `json:"userId"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	Reprocess   bool       `json:"reprocess,omitempty"` // Parses the file again even if it has an analysis
+	Parser      string     `json:"parser,omitempty"`    // Parser override for reprocessing
+	LogFormat   string     `json:"format,omitempty"`    // Log format override for reprocessing
+	Error       string     `json:"error,omitempty"`
+	Attempts    int        `json:"attempts"` // Times the job has started
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Progress is set while the job runs on this server
+	Progress *JobProgress `json:"progress,omitempty"`
+}
+
+// Finished reports whether the job has stopped for good: completed, failed or canceled
+func (j *ProcessingJob) Finished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
+
+// JobProgress is how far a running job has got. The file's total rows aren't known until it has
+// been parsed, so they and the percentage are estimated from the share of the file read.
+type JobProgress struct {
+	RowsProcessed int64     `json:"rowsProcessed"`
+	EstimatedRows int64     `json:"estimatedRows"`
+	Percent       float64   `json:"percent"`
+	ETASeconds    *float64  `json:"etaSeconds,omitempty"` // Unset until there is enough progress to extrapolate from
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// DeadLetterJob is a processing job that failed on every attempt, kept with what is needed to
+// diagnose it and, once fixed, requeue it
+type DeadLetterJob struct {
+	JobID     string `json:"jobId"`
+	FileID    string `json:"fileId"`
+	UserID    string `json:"userId"`
+	Priority  string `json:"priority"`
+	Reprocess bool   `json:"reprocess"`
+	Parser    string `json:"parser,omitempty"`
+	LogFormat string `json:"format,omitempty"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error"`
+	// Stack is the stack of a job that panicked; returned errors carry their context in Error
+	Stack    string    `json:"stack,omitempty"`
+	FailedAt time.Time `json:"failedAt"`
+}
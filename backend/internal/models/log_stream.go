@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Log stream statuses
+const (
+	LogStreamStatusActive = "active"
+	LogStreamStatusPaused = "paused"
+	LogStreamStatusError  = "error"
+)
+
+// LogStream is a customer Kafka topic of bid and impression events consumed through a Kafka
+// REST Proxy and aggregated into the user's rollups as it arrives
+type LogStream struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Name     string `json:"name"`
+	ProxyURL string `json:"proxyUrl"`
+	Username string `json:"username,omitempty"`
+	// Password is the encrypted REST Proxy password; it never leaves the server
+	Password string `json:"-"`
+	Topic    string `json:"topic"`
+	// GroupID is the consumer group the stream's offsets are committed under
+	GroupID string `json:"groupId"`
+	// Source is the log format events are mapped with, extended by the user's mapping profile for it
+	Source    string `json:"source"`
+	Status    string `json:"status"`
+	LastError string `json:"lastError,omitempty"`
+	// RecordsConsumed and RecordsRejected count the events aggregated and those that couldn't be mapped
+	RecordsConsumed int64      `json:"recordsConsumed"`
+	RecordsRejected int64      `json:"recordsRejected"`
+	LastConsumedAt  *time.Time `json:"lastConsumedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Organization groups users whose data is shared, such as an agency's team
+type Organization struct {
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	JobPriority       string         `json:"jobPriority"`       // Priority of the org's uploads that don't ask for one
+	ReportingCurrency string         `json:"reportingCurrency"` // ISO 4217 currency campaign spend is reported in
+	Plan              string         `json:"plan"`
+	TrialEndsAt       *time.Time     `json:"trialEndsAt,omitempty"` // Overrides the default trial length when set
+	UploadDefaults    UploadDefaults `json:"uploadDefaults"`
+	CreatedAt         time.Time      `json:"createdAt"`
+}
+
+// UploadDefaults are how an org's uploads are parsed when they don't say, so analysts don't
+// repeat the same settings for every file
+type UploadDefaults struct {
+	// Timezone is the IANA zone CSV log timestamps without an offset are read in; empty reads them as UTC
+	Timezone string `json:"timezone,omitempty"`
+	// BreakdownLimit is how many top domains and hours summaries keep; 0 keeps the server's default
+	BreakdownLimit int `json:"breakdownLimit,omitempty"`
+	// Parsers are the parser and log format to use for each kind of file, by extension such as ".json"
+	Parsers map[string]ParserDefault `json:"parsers,omitempty"`
+}
+
+// ParserDefault is the parser and log format an org prefers for a kind of file
+type ParserDefault struct {
+	Parser string `json:"parser,omitempty"`
+	Format string `json:"format,omitempty"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+// Plans an organization can be on
+const (
+	PlanFree       = "free"
+	PlanTrial      = "trial"
+	PlanPro        = "pro"
+	PlanEnterprise = "enterprise"
+)
+
+// TrialDays is how long a trial lasts when the org has no trial end set
+const TrialDays = 14
+
+// Features gated by plan
+const (
+	// FeatureAdvancedAnalytics covers the analytics modules beyond the standard breakdowns:
+	// supply path, bid efficiency, prebid, brand safety, journeys, benchmarks and realtime
+	FeatureAdvancedAnalytics = "advanced_analytics"
+	// FeatureConnectors covers connecting ad platforms, log streams and warehouse exports
+	FeatureConnectors = "connectors"
+)
+
+// Features lists every gated feature
+var Features = []string{FeatureAdvancedAnalytics, FeatureConnectors}
+
+// PlanLimits are what a plan includes. A limit of 0 is unlimited.
+type PlanLimits struct {
+	MaxUploadsPerDay     int
+	APICallsPerMonth     int64
+	RowsIngestedPerMonth int64
+	StorageBytes         int64           // total size of an org's stored files
+	Features             map[string]bool // gated features the plan includes
+}
+
+// planLimits are each plan's limits; trials get the pro plan's until they expire, then the free plan's
+var planLimits = map[string]PlanLimits{
+	PlanFree: {
+		MaxUploadsPerDay:     5,
+		APICallsPerMonth:     10000,
+		RowsIngestedPerMonth: 5000000,
+		StorageBytes:         1 << 30,
+		Features:             map[string]bool{},
+	},
+	PlanPro: {
+		MaxUploadsPerDay:     100,
+		APICallsPerMonth:     1000000,
+		RowsIngestedPerMonth: 500000000,
+		StorageBytes:         100 << 30,
+		Features:             map[string]bool{FeatureAdvancedAnalytics: true, FeatureConnectors: true},
+	},
+	PlanEnterprise: {
+		Features: map[string]bool{FeatureAdvancedAnalytics: true, FeatureConnectors: true},
+	},
+}
+
+// ValidPlan reports whether p is a plan
+func ValidPlan(p string) bool {
+	_, ok := planLimits[p]
+	return ok || p == PlanTrial
+}
+
+// TrialEnd returns when an org's trial ends: its trial end if set, or TrialDays after it was created
+func (o *Organization) TrialEnd() time.Time {
+	if o.TrialEndsAt != nil {
+		return *o.TrialEndsAt
+	}
+	return o.CreatedAt.AddDate(0, 0, TrialDays)
+}
+
+// Limits returns what the org's plan includes at a time. Active trials get the pro plan's
+// limits and expired trials the free plan's; unknown plans are treated as free.
+func (o *Organization) Limits(now time.Time) PlanLimits {
+	plan := o.Plan
+	if plan == PlanTrial {
+		plan = PlanFree
+		if now.Before(o.TrialEnd()) {
+			plan = PlanPro
+		}
+	}
+
+	limits, ok := planLimits[plan]
+	if !ok {
+		return planLimits[PlanFree]
+	}
+	return limits
+}
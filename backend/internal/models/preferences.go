@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Preferences are a user's defaults for reports and dashboards
+type Preferences struct {
+	UserID           string    `json:"-"`
+	Currency         string    `json:"currency"`   // ISO 4217 code spend is labelled with
+	Timezone         string    `json:"timezone"`   // IANA zone report timestamps are shown in; empty uses the profile's
+	DateFormat       string    `json:"dateFormat"` // One of the DateFormats keys
+	DefaultDashboard string    `json:"defaultDashboard"`
+	EmailDigest      bool      `json:"emailDigest"` // Whether the user opted in to the email digest
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// DateFormats maps the date formats users can choose to their Go layouts
+var DateFormats = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD/MM/YYYY": "02/01/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+// DefaultPreferences returns the preferences of a user who hasn't set any
+func DefaultPreferences(userID string) *Preferences {
+	return &Preferences{
+		UserID:           userID,
+		Currency:         "USD",
+		DateFormat:       "YYYY-MM-DD",
+		DefaultDashboard: "overview",
+	}
+}
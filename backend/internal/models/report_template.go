@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Report template section types
+const (
+	ReportSectionSummary   = "summary"
+	ReportSectionBreakdown = "breakdown"
+	ReportSectionTrend     = "trend"
+)
+
+// ReportTemplate defines the sections of an organization's generated PDF and Excel reports
+type ReportTemplate struct {
+	ID          string          `json:"id"`
+	OrgID       string          `json:"-"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Sections    []ReportSection `json:"sections"`
+	CreatedBy   string          `json:"createdBy"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// ReportSection is one section of a report: totals over the period, a breakdown by a rollup
+// dimension, or a daily trend
+type ReportSection struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Dimension string   `json:"dimension,omitempty"` // Breakdown dimension, such as domain
+	Metrics   []string `json:"metrics"`             // Base or custom metrics, in column order
+	Limit     int      `json:"limit,omitempty"`     // Top values of a breakdown, by spend
+}
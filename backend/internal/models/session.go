@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a signed-in device of a user. Access tokens carry the session's ID, so revoking
+// the session signs the device out. The device gets new access tokens with the session's refresh
+// token, which is replaced every time it's used.
+type Session struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+	// RefreshToken is only set when it's issued; only its hash is stored, along with the hash of
+	// the token it replaced so a replaced token being used again can be recognized
+	RefreshToken             string     `json:"-"`
+	RefreshTokenHash         string     `json:"-"`
+	PreviousRefreshTokenHash string     `json:"-"`
+	Device                   string     `json:"device"`
+	UserAgent                string     `json:"userAgent"`
+	IPAddress                string     `json:"ipAddress"`
+	CreatedAt                time.Time  `json:"createdAt"`
+	LastSeenAt               time.Time  `json:"lastSeenAt"`
+	ExpiresAt                time.Time  `json:"expiresAt"`
+	RevokedAt                *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the session can still authenticate requests
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// OrgUsage is an org's metered usage over a calendar month, the groundwork for plan limits
+// and billing
+type OrgUsage struct {
+	OrgID        string    `json:"-"`
+	Period       time.Time `json:"period"` // First day of the month, in UTC
+	APICalls     int64     `json:"apiCalls"`
+	RowsIngested int64     `json:"rowsIngested"`
+}
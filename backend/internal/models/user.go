@@ -9,10 +9,16 @@ import (
 // User represents a user in the system
 type User struct {
 	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"` // Never expose the password
 	FirstName string    `json:"firstName"`
 	LastName  string    `json:"lastName"`
+	Company   string    `json:"company"`
+	Role      string    `json:"role"`     // The user's job at their company
+	Timezone  string    `json:"timezone"` // IANA time zone name, such as Europe/Berlin
+	Locale    string    `json:"locale"`   // BCP 47 language tag, such as en-US
+	AvatarID  string    `json:"-"`        // Served publicly under its own ID, never the user's
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
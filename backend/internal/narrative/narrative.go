@@ -0,0 +1,169 @@
+package narrative
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// systemPrompt instructs the model how to write the narrative
+const systemPrompt = "You are an analyst for programmatic advertising campaigns. " +
+	"Write one short paragraph (at most 5 sentences) of plain-English insights from the facts provided. " +
+	"Only use the numbers given, call out notable differences between segments, and do not invent data."
+
+// Provider generates text from a prompt using a language model
+type Provider interface {
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// Generator turns analysis results into short written insights
+type Generator struct {
+	provider Provider
+	timeout  time.Duration
+}
+
+// NewGenerator creates a narrative generator from configuration.
+// It returns nil when narratives are disabled.
+func NewGenerator(cfg config.NarrativeConfig) (*Generator, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	client := &http.Client{Timeout: timeout}
+
+	var provider Provider
+	switch cfg.Provider {
+	case "openai":
+		provider = NewOpenAIProvider(client, cfg.BaseURL, cfg.APIKey, cfg.Model)
+	case "anthropic":
+		provider = NewAnthropicProvider(client, cfg.BaseURL, cfg.APIKey, cfg.Model)
+	default:
+		return nil, fmt.Errorf("unknown narrative provider: %s", cfg.Provider)
+	}
+
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("narrative provider %s requires NARRATIVE_API_KEY", cfg.Provider)
+	}
+
+	return &Generator{provider: provider, timeout: timeout}, nil
+}
+
+// NewGeneratorWithProvider creates a narrative generator backed by the given provider
+func NewGeneratorWithProvider(provider Provider, timeout time.Duration) *Generator {
+	return &Generator{provider: provider, timeout: timeout}
+}
+
+// Generate writes a narrative for a completed analysis result
+func (g *Generator) Generate(ctx context.Context, result *ingestion.LogAnalysisResult) (string, error) {
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	text, err := g.provider.Complete(ctx, systemPrompt, BuildPrompt(result.FileName, summary))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate narrative: %w", err)
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// BuildPrompt lists the key facts of a summary for the model to write about
+func BuildPrompt(fileName string, summary *ingestion.BeeswaxLogSummary) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "File: %s\n", fileName)
+	if summary.TotalRecords > 0 {
+		fmt.Fprintf(&b, "Period: %s to %s\n",
+			summary.TimeRange[0].Format("2006-01-02"), summary.TimeRange[1].Format("2006-01-02"))
+	}
+	fmt.Fprintf(&b, "Bids: %d, impressions: %d, clicks: %d, conversions: %d\n",
+		summary.TotalRecords, summary.TotalImpressions, summary.TotalClicks, summary.TotalConversions)
+	fmt.Fprintf(&b, "Spend: $%.2f, CTR: %.2f%%, win rate: %.2f%%\n",
+		summary.TotalWinCost, summary.CTR, summary.AverageWinRate)
+
+	// Device performance from the funnel segments
+	devices := summary.FunnelSegments[ingestion.FunnelSegmentDevice]
+	if len(devices) > 0 {
+		b.WriteString("CTR by device:\n")
+		for _, name := range sortedFunnelKeys(devices) {
+			counts := devices[name]
+			if counts.Impressions == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "- %s: %.2f%% over %d impressions\n",
+				name, float64(counts.Clicks)/float64(counts.Impressions)*100, counts.Impressions)
+		}
+	}
+
+	// Weekday versus weekend performance from the dayparting grid
+	if summary.Dayparting != nil {
+		var weekday, weekend ingestion.DaypartCell
+		for day, hours := range summary.Dayparting {
+			for _, cell := range hours {
+				target := &weekday
+				if time.Weekday(day) == time.Saturday || time.Weekday(day) == time.Sunday {
+					target = &weekend
+				}
+				target.Impressions += cell.Impressions
+				target.Clicks += cell.Clicks
+			}
+		}
+		if weekday.Impressions > 0 && weekend.Impressions > 0 {
+			fmt.Fprintf(&b, "CTR weekdays: %.2f%%, weekends: %.2f%%\n",
+				float64(weekday.Clicks)/float64(weekday.Impressions)*100,
+				float64(weekend.Clicks)/float64(weekend.Impressions)*100)
+		}
+	}
+
+	// Largest campaigns by spend
+	campaigns := make([]string, 0, len(summary.CampaignPerformance))
+	for id := range summary.CampaignPerformance {
+		campaigns = append(campaigns, id)
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		return summary.CampaignPerformance[campaigns[i]].Spend > summary.CampaignPerformance[campaigns[j]].Spend
+	})
+	if len(campaigns) > 5 {
+		campaigns = campaigns[:5]
+	}
+	if len(campaigns) > 0 {
+		b.WriteString("Top campaigns by spend:\n")
+		for _, id := range campaigns {
+			campaign := summary.CampaignPerformance[id]
+			fmt.Fprintf(&b, "- %s: $%.2f spend, %.2f%% CTR, %d conversions\n",
+				id, campaign.Spend, campaign.CTR, campaign.Conversions)
+		}
+	}
+
+	if summary.Viewability != nil {
+		fmt.Fprintf(&b, "Viewability rate: %.2f%%\n", summary.Viewability.ViewabilityRate)
+	}
+	if summary.Video != nil {
+		fmt.Fprintf(&b, "Video completion rate: %.2f%%\n", summary.Video.VCR)
+	}
+
+	return b.String()
+}
+
+// sortedFunnelKeys returns segment names ordered by impressions, largest first
+func sortedFunnelKeys(segments map[string]ingestion.FunnelCounts) []string {
+	keys := make([]string, 0, len(segments))
+	for key := range segments {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return segments[keys[i]].Impressions > segments[keys[j]].Impressions
+	})
+	return keys
+}
@@ -0,0 +1,162 @@
+package narrative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider generates text using an OpenAI-compatible chat completions API
+type OpenAIProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewOpenAIProvider creates a provider for an OpenAI-compatible API
+func NewOpenAIProvider(client *http.Client, baseURL, apiKey, model string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+
+	return &OpenAIProvider{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+// Complete sends the prompt to the chat completions endpoint
+func (p *OpenAIProvider) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := map[string]interface{}{
+		"model": p.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	if err := postJSON(ctx, p.client, p.baseURL+"/chat/completions", headers, body, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("empty response from provider")
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// AnthropicProvider generates text using the Anthropic Messages API
+type AnthropicProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewAnthropicProvider creates a provider for the Anthropic Messages API
+func NewAnthropicProvider(client *http.Client, baseURL, apiKey, model string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+	if model == "" {
+		model = "claude-3-5-haiku-latest"
+	}
+
+	return &AnthropicProvider{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+// Complete sends the prompt to the messages endpoint
+func (p *AnthropicProvider) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": 400,
+		"system":     system,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	var response struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	if err := postJSON(ctx, p.client, p.baseURL+"/messages", headers, body, &response); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("empty response from provider")
+	}
+
+	return text.String(), nil
+}
+
+// postJSON sends a JSON request and decodes the JSON response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
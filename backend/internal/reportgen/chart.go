@@ -0,0 +1,128 @@
+package reportgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chart types
+const (
+	ChartLine = "line"
+	ChartBar  = "bar"
+)
+
+// SVG chart layout, in pixels
+const (
+	svgWidth       = 640
+	svgHeight      = 360
+	svgMarginLeft  = 64
+	svgMarginRight = 16
+	svgMarginTop   = 40
+	svgMarginBase  = 72
+	svgGridLines   = 4
+)
+
+// Chart is a single-series chart: a line over time or bars by category
+type Chart struct {
+	Title  string    `json:"title"`
+	Type   string    `json:"type"`
+	Metric string    `json:"metric"`
+	Labels []string  `json:"labels"`
+	Values []float64 `json:"values"`
+}
+
+// WriteSVG renders a chart as a standalone SVG image with axis gridlines and labels
+func WriteSVG(w io.Writer, chart *Chart) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, svgWidth, svgHeight, svgWidth, svgHeight)
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>`)
+	fmt.Fprintf(&b, `<text x="%d" y="24" font-size="14" font-weight="bold">%s</text>`, svgMarginLeft, escapeXML(chart.Title))
+
+	plotWidth := float64(svgWidth - svgMarginLeft - svgMarginRight)
+	plotHeight := float64(svgHeight - svgMarginTop - svgMarginBase)
+	baseline := float64(svgHeight - svgMarginBase)
+
+	// Scale to a round maximum so gridlines fall on readable values
+	top := niceCeiling(maxValue(chart.Values))
+	y := func(value float64) float64 {
+		return baseline - value/top*plotHeight
+	}
+	for i := 0; i <= svgGridLines; i++ {
+		value := top * float64(i) / svgGridLines
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e7eb"/>`, svgMarginLeft, y(value), svgWidth-svgMarginRight, y(value))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" fill="#6b7280">%s</text>`, svgMarginLeft-6, y(value)+4, axisLabel(value))
+	}
+
+	n := len(chart.Values)
+	if n == 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="middle" fill="#6b7280">No data</text>`, svgWidth/2, baseline-plotHeight/2)
+	}
+	step := plotWidth / float64(max(n, 1))
+	x := func(i int) float64 {
+		return float64(svgMarginLeft) + step*(float64(i)+0.5)
+	}
+
+	switch chart.Type {
+	case ChartLine:
+		points := make([]string, n)
+		for i, value := range chart.Values {
+			points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(value))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#2563eb" stroke-width="2"/>`, strings.Join(points, " "))
+	default:
+		width := step * 0.7
+		for i, value := range chart.Values {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#2563eb"><title>%s: %s</title></rect>`,
+				x(i)-width/2, y(value), width, baseline-y(value), escapeXML(chart.Labels[i]), strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+
+	// Label every category, or about a dozen evenly spaced ones when there are more
+	every := max(1, (n+11)/12)
+	for i := 0; i < n; i += every {
+		fmt.Fprintf(&b, `<text transform="translate(%.1f %.1f) rotate(-40)" text-anchor="end" fill="#374151">%s</text>`,
+			x(i), baseline+14, escapeXML(truncate(chart.Labels[i], 18)))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#9ca3af"/>`, svgMarginLeft, baseline, svgWidth-svgMarginRight, baseline)
+	b.WriteString(`</svg>`)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func maxValue(values []float64) float64 {
+	top := 0.0
+	for _, value := range values {
+		top = math.Max(top, value)
+	}
+	return top
+}
+
+// niceCeiling rounds a maximum up to 1, 2, 2.5 or 5 times a power of ten, or 1 when it is zero
+func niceCeiling(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if value <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// axisLabel formats an axis value compactly, such as 2.5k or 1.2M
+func axisLabel(value float64) string {
+	switch {
+	case value >= 1e6:
+		return strconv.FormatFloat(value/1e6, 'f', -1, 64) + "M"
+	case value >= 1e3:
+		return strconv.FormatFloat(value/1e3, 'f', -1, 64) + "k"
+	default:
+		return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,255 @@
+package reportgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// PDF page layout: landscape A4 in points, set in Courier so tables align without font metrics
+const (
+	pdfPageWidth  = 842
+	pdfPageHeight = 595
+	pdfMargin     = 40
+	pdfFontSize   = 8
+	pdfLineHeight = 11
+	// pdfLineChars is how many Courier characters fit between the margins; each is 0.6em wide
+	pdfLineChars = (pdfPageWidth - 2*pdfMargin) * 10 / (pdfFontSize * 6)
+	// pdfMaxColumn truncates long cells, such as domains, so one column can't crowd out the rest
+	pdfMaxColumn = 40
+)
+
+// pdfLine is a line of text on a page
+type pdfLine struct {
+	text string
+	bold bool
+	size int
+}
+
+// WritePDF renders a report as a PDF document, one table after another, breaking pages as needed
+func WritePDF(w io.Writer, report *Report) error {
+	lines := []pdfLine{
+		{text: report.Title, bold: true, size: 14},
+		{text: report.Period},
+	}
+	for _, note := range report.Notes {
+		lines = append(lines, pdfLine{text: note})
+	}
+	for _, section := range report.Sections {
+		lines = append(lines, pdfLine{}, pdfLine{text: section.Title, bold: true, size: 11})
+		lines = append(lines, tableLines(section, report.label("No data for this period"))...)
+	}
+	if len(report.Insights) > 0 {
+		lines = append(lines, pdfLine{}, pdfLine{text: report.label("Insights"), bold: true, size: 11})
+		for _, insight := range report.Insights {
+			lines = append(lines, pdfLine{text: truncate(insight.FileName, pdfLineChars), bold: true})
+			for _, text := range wrapText(insight.Text, pdfLineChars) {
+				lines = append(lines, pdfLine{text: text})
+			}
+			lines = append(lines, pdfLine{})
+		}
+	}
+
+	// Break the lines into pages, counting larger lines as two
+	linesPerPage := (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+	var pages [][]pdfLine
+	var page []pdfLine
+	used := 0
+	for _, line := range lines {
+		height := 1
+		if line.size > pdfFontSize {
+			height = 2
+		}
+		if used+height > linesPerPage {
+			pages = append(pages, page)
+			page, used = nil, 0
+		}
+		page = append(page, line)
+		used += height
+	}
+	pages = append(pages, page)
+
+	return writePDFPages(w, pages)
+}
+
+// tableLines lays a section out as fixed-width text: a bold header, a rule, then the rows with
+// numbers right-aligned, or the empty label when it has no rows
+func tableLines(section Section, empty string) []pdfLine {
+	widths := make([]int, len(section.Columns))
+	cells := make([][]string, len(section.Rows))
+	for i, column := range section.Columns {
+		widths[i] = min(utf8.RuneCountInString(column), pdfMaxColumn)
+	}
+	for r, row := range section.Rows {
+		cells[r] = make([]string, len(section.Columns))
+		for c := range section.Columns {
+			if c >= len(row) {
+				continue
+			}
+			text := truncate(formatCell(row[c]), pdfMaxColumn)
+			cells[r][c] = text
+			widths[c] = max(widths[c], utf8.RuneCountInString(text))
+		}
+	}
+
+	format := func(values []string, numeric func(int) bool) string {
+		var b strings.Builder
+		for c, value := range values {
+			if c > 0 {
+				b.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[c]-utf8.RuneCountInString(value))
+			if numeric(c) {
+				b.WriteString(pad + value)
+			} else {
+				b.WriteString(value + pad)
+			}
+		}
+		return truncate(strings.TrimRight(b.String(), " "), pdfLineChars)
+	}
+
+	header := make([]string, len(section.Columns))
+	for i, column := range section.Columns {
+		header[i] = truncate(column, pdfMaxColumn)
+	}
+	lines := []pdfLine{{text: format(header, func(int) bool { return false }), bold: true}}
+	rule := 0
+	for _, width := range widths {
+		rule += width + 2
+	}
+	lines = append(lines, pdfLine{text: strings.Repeat("-", min(max(rule-2, 0), pdfLineChars))})
+
+	for r, row := range cells {
+		lines = append(lines, pdfLine{text: format(row, func(c int) bool {
+			if c >= len(section.Rows[r]) {
+				return false
+			}
+			_, isText := section.Rows[r][c].(string)
+			return !isText
+		})})
+	}
+	if len(section.Rows) == 0 {
+		lines = append(lines, pdfLine{text: empty})
+	}
+
+	return lines
+}
+
+// wrapText breaks text into lines of at most width characters, between words where it can
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// writePDFPages writes the document: catalog, page tree, fonts, then each page and its content
+func writePDFPages(w io.Writer, pages [][]pdfLine) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page is followed by its content
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		var content strings.Builder
+		y := pdfPageHeight - pdfMargin
+		for _, line := range page {
+			size := line.size
+			if size == 0 {
+				size = pdfFontSize
+			}
+			font := "F1"
+			if line.bold {
+				font = "F2"
+			}
+			y -= pdfLineHeight
+			if size > pdfFontSize {
+				y -= pdfLineHeight
+			}
+			if line.text != "" {
+				fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, pdfMargin, y, escapePDF(line.text))
+			}
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// escapePDF escapes a PDF string literal, replacing characters outside Latin-1 that the
+// standard fonts can't show
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		case r > 0x7e:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncate shortens s to at most n characters, marking the cut with ~
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "~"
+}
@@ -0,0 +1,82 @@
+package reportgen
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is a generated report laid out as titled tables, ready to render as PDF or Excel
+type Report struct {
+	Title       string    `json:"title"`
+	Period      string    `json:"period"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Notes are caveats printed under the title, such as files left out of the figures
+	Notes    []string  `json:"notes,omitempty"`
+	Sections []Section `json:"sections"`
+	// Insights are the written summaries of the files the report covers, printed after the tables
+	Insights []Insight `json:"insights,omitempty"`
+
+	// translate translates the labels the renderers add; nil leaves them in English
+	translate func(string) string
+}
+
+// Section is one table of a report. Cells are strings, ints or float64s; nil leaves a cell
+// empty, such as a custom metric that is undefined for a row.
+type Section struct {
+	Title   string   `json:"title"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// Insight is the written summary of one of the files a report covers
+type Insight struct {
+	FileName string `json:"fileName"`
+	Text     string `json:"text"`
+}
+
+// Localize translates the report's section titles, column labels, period and notes, and the
+// labels the renderers add, with translate. The title, which names the template, and cells are
+// left as they are.
+func (r *Report) Localize(translate func(string) string) {
+	r.translate = translate
+	if from, to, ok := strings.Cut(r.Period, " to "); ok {
+		r.Period = from + " " + translate("to") + " " + to
+	}
+	for i, note := range r.Notes {
+		r.Notes[i] = translate(note)
+	}
+	for i := range r.Sections {
+		section := &r.Sections[i]
+		section.Title = translate(section.Title)
+		for j, column := range section.Columns {
+			section.Columns[j] = translate(column)
+		}
+	}
+}
+
+// label translates a label a renderer adds
+func (r *Report) label(text string) string {
+	if r.translate == nil {
+		return text
+	}
+	return r.translate(text)
+}
+
+// formatCell formats a cell as text, with two decimals for fractional numbers
+func formatCell(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	default:
+		return ""
+	}
+}
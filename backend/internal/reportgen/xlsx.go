@@ -0,0 +1,165 @@
+package reportgen
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxSheetName is the longest sheet name Excel accepts
+const maxSheetName = 31
+
+// WriteXLSX renders a report as an Excel workbook with an overview sheet followed by a sheet
+// per section, and one of insights when the report has any
+func WriteXLSX(w io.Writer, report *Report) error {
+	overview := Section{Title: report.label("Overview"), Columns: []string{report.Title}, Rows: [][]any{{report.Period}}}
+	for _, note := range report.Notes {
+		overview.Rows = append(overview.Rows, []any{note})
+	}
+	sheets := append([]Section{overview}, report.Sections...)
+	if len(report.Insights) > 0 {
+		insights := Section{Title: report.label("Insights"), Columns: []string{report.label("File"), report.label("Insight")}}
+		for _, insight := range report.Insights {
+			insights.Rows = append(insights.Rows, []any{insight.FileName, insight.Text})
+		}
+		sheets = append(sheets, insights)
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+	}
+	for i, sheet := range sheets {
+		files = append(files, struct {
+			name string
+			body string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet)})
+	}
+
+	for _, file := range files {
+		part, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, file.body); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func contentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func workbook(sheets []Section) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	used := make(map[string]bool)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeXML(sheetName(sheet.Title, i+1, used)), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func workbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// worksheet renders a section as a sheet with its columns as the first row. Numbers are
+// written as numbers so they can be summed and charted.
+func worksheet(section Section) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]any, len(section.Columns))
+	for i, column := range section.Columns {
+		header[i] = column
+	}
+	for r, row := range append([][]any{header}, section.Rows...) {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := columnName(c) + strconv.Itoa(r+1)
+			switch v := cell.(type) {
+			case nil:
+			case int, int64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, escapeXML(formatCell(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// sheetName makes a section title a valid, unique sheet name, numbering the sheet when the
+// title is empty or taken
+func sheetName(title string, n int, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if runes := []rune(name); len(runes) > maxSheetName {
+		name = string(runes[:maxSheetName])
+	}
+	if name == "" || used[strings.ToLower(name)] {
+		suffix := fmt.Sprintf(" (%d)", n)
+		if runes := []rune(name); len(runes)+len(suffix) > maxSheetName {
+			name = string(runes[:maxSheetName-len(suffix)])
+		}
+		name += suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// columnName converts a zero-based column index to its letters, such as AA for 26
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresAPIKeyRepository stores users' API keys in PostgreSQL
+type PostgresAPIKeyRepository struct {
+	db DBTX
+}
+
+// NewPostgresAPIKeyRepository creates a new PostgreSQL API key repository
+func NewPostgresAPIKeyRepository(db DBTX) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{
+		db: db,
+	}
+}
+
+// apiKeyColumns lists the columns selected for an API key, in scan order
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, created_at, last_used_at, revoked_at`
+
+// Create inserts a new API key
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (` + apiKeyColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.CreatedAt,
+		key.LastUsedAt,
+		key.RevokedAt,
+	)
+
+	return err
+}
+
+// ListByUser lists a user's API keys, newest first
+func (r *PostgresAPIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// FindByHash finds the API key with a key hash
+func (r *PostgresAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return key, err
+}
+
+// Touch records that an API key was used
+func (r *PostgresAPIKeyRepository) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	return err
+}
+
+// Revoke revokes a user's active API key
+func (r *PostgresAPIKeyRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+
+	return key, err
+}
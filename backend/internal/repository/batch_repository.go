@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresBatchRepository stores upload batches in PostgreSQL
+type PostgresBatchRepository struct {
+	db DBTX
+}
+
+// NewPostgresBatchRepository creates a new PostgreSQL batch repository
+func NewPostgresBatchRepository(db DBTX) *PostgresBatchRepository {
+	return &PostgresBatchRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new batch, returning ErrDuplicate when the user has already used its idempotency key
+func (r *PostgresBatchRepository) Create(ctx context.Context, batch *models.UploadBatch) error {
+	query := `
+		INSERT INTO upload_batches (id, user_id, idempotency_key, rejected, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query, batch.ID, batch.UserID, batch.IdempotencyKey, batch.Rejected, batch.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// AddFile adds an uploaded file to a batch at the given position
+func (r *PostgresBatchRepository) AddFile(ctx context.Context, batchID string, position int, file models.BatchFile) error {
+	query := `
+		INSERT INTO upload_batch_files (batch_id, file_id, job_id, position)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(ctx, query, batchID, file.FileID, file.JobID, position)
+	return err
+}
+
+// SetRejected records the files of a batch that weren't accepted
+func (r *PostgresBatchRepository) SetRejected(ctx context.Context, batchID string, rejected []models.RejectedFile) error {
+	query := `
+		UPDATE upload_batches
+		SET rejected = $2
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(ctx, query, batchID, rejected)
+	return err
+}
+
+// FindByID finds a user's batch with its files and their current statuses, in upload order
+func (r *PostgresBatchRepository) FindByID(ctx context.Context, id, userID string) (*models.UploadBatch, error) {
+	return r.find(ctx, `id = $1 AND user_id = $2`, id, userID)
+}
+
+// FindByIdempotencyKey finds the batch a user created with an idempotency key
+func (r *PostgresBatchRepository) FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.UploadBatch, error) {
+	return r.find(ctx, `user_id = $1 AND idempotency_key = $2`, userID, key)
+}
+
+// find finds the batch matching a condition and loads its files
+func (r *PostgresBatchRepository) find(ctx context.Context, condition string, args ...interface{}) (*models.UploadBatch, error) {
+	query := `
+		SELECT id, user_id, COALESCE(idempotency_key, ''), rejected, created_at
+		FROM upload_batches
+		WHERE ` + condition
+
+	batch := &models.UploadBatch{}
+	err := r.db.QueryRow(ctx, query, args...).Scan(
+		&batch.ID,
+		&batch.UserID,
+		&batch.IdempotencyKey,
+		&batch.Rejected,
+		&batch.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT f.id, bf.job_id, f.file_name, f.file_size, f.status, COALESCE(j.priority, 'normal')
+		FROM upload_batch_files bf
+		JOIN files f ON f.id = bf.file_id
+		LEFT JOIN processing_jobs j ON j.id = bf.job_id
+		WHERE bf.batch_id = $1
+		ORDER BY bf.position
+	`, batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch.Files = []models.BatchFile{}
+	for rows.Next() {
+		var file models.BatchFile
+		if err := rows.Scan(&file.FileID, &file.JobID, &file.FileName, &file.FileSize, &file.Status, &file.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan batch file: %w", err)
+		}
+		batch.Files = append(batch.Files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if batch.Rejected == nil {
+		batch.Rejected = []models.RejectedFile{}
+	}
+	batch.SetStatus()
+
+	return batch, nil
+}
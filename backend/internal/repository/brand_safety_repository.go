@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresBrandSafetyRepository stores users' brand safety lists in PostgreSQL
+type PostgresBrandSafetyRepository struct {
+	db DBTX
+}
+
+// NewPostgresBrandSafetyRepository creates a new PostgreSQL brand safety repository
+func NewPostgresBrandSafetyRepository(db DBTX) *PostgresBrandSafetyRepository {
+	return &PostgresBrandSafetyRepository{
+		db: db,
+	}
+}
+
+// brandSafetyListColumns lists the columns selected for a brand safety list, in scan order
+const brandSafetyListColumns = `id, user_id, name, type, entries, created_at`
+
+// Create inserts a new brand safety list, returning ErrDuplicate when the user already has a list with its name
+func (r *PostgresBrandSafetyRepository) Create(ctx context.Context, list *models.BrandSafetyList) error {
+	query := `
+		INSERT INTO brand_safety_lists (` + brandSafetyListColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		list.ID,
+		list.UserID,
+		list.Name,
+		list.Type,
+		list.Entries,
+		list.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// ListByUser lists a user's brand safety lists, ordered by name
+func (r *PostgresBrandSafetyRepository) ListByUser(ctx context.Context, userID string) ([]*models.BrandSafetyList, error) {
+	query := `
+		SELECT ` + brandSafetyListColumns + `
+		FROM brand_safety_lists
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*models.BrandSafetyList{}
+	for rows.Next() {
+		list, err := scanBrandSafetyList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan brand safety list: %w", err)
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+// Delete removes a user's brand safety list
+func (r *PostgresBrandSafetyRepository) Delete(ctx context.Context, id, userID string) error {
+	query := `
+		DELETE FROM brand_safety_lists
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanBrandSafetyList scans a row selected with brandSafetyListColumns
+func scanBrandSafetyList(row pgx.Row) (*models.BrandSafetyList, error) {
+	list := &models.BrandSafetyList{}
+	err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		&list.Type,
+		&list.Entries,
+		&list.CreatedAt,
+	)
+
+	return list, err
+}
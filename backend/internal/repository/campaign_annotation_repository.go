@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresCampaignAnnotationRepository stores the events users record against their campaigns
+type PostgresCampaignAnnotationRepository struct {
+	db DBTX
+}
+
+// NewPostgresCampaignAnnotationRepository creates a new PostgreSQL campaign annotation repository
+func NewPostgresCampaignAnnotationRepository(db DBTX) *PostgresCampaignAnnotationRepository {
+	return &PostgresCampaignAnnotationRepository{
+		db: db,
+	}
+}
+
+// ListAnnotations returns a user's annotations of a campaign, or of every campaign when
+// campaignID is empty, that occurred from from up to but excluding to, ordered by when they
+// occurred
+func (r *PostgresCampaignAnnotationRepository) ListAnnotations(ctx context.Context, userID, campaignID string, from, to *time.Time) ([]ingestion.CampaignAnnotation, error) {
+	query := `
+		SELECT id, campaign_id, type, note, occurred_at, created_at
+		FROM campaign_annotations
+		WHERE user_id = $1
+			AND ($2 = '' OR campaign_id = $2)
+			AND ($3::timestamptz IS NULL OR occurred_at >= $3)
+			AND ($4::timestamptz IS NULL OR occurred_at < $4)
+		ORDER BY occurred_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, campaignID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	annotations := []ingestion.CampaignAnnotation{}
+	for rows.Next() {
+		var annotation ingestion.CampaignAnnotation
+		if err := rows.Scan(&annotation.ID, &annotation.CampaignID, &annotation.Type, &annotation.Note, &annotation.OccurredAt, &annotation.CreatedAt); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, rows.Err()
+}
+
+// CreateAnnotation records an annotation of one of a user's campaigns
+func (r *PostgresCampaignAnnotationRepository) CreateAnnotation(ctx context.Context, userID string, annotation *ingestion.CampaignAnnotation) error {
+	query := `
+		INSERT INTO campaign_annotations (id, user_id, campaign_id, type, note, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query, annotation.ID, userID, annotation.CampaignID, annotation.Type, annotation.Note, annotation.OccurredAt, annotation.CreatedAt)
+	return err
+}
+
+// DeleteAnnotation removes an annotation of one of a user's campaigns
+func (r *PostgresCampaignAnnotationRepository) DeleteAnnotation(ctx context.Context, userID, campaignID, id string) error {
+	query := `
+		DELETE FROM campaign_annotations
+		WHERE id = $1 AND user_id = $2 AND campaign_id = $3
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, campaignID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
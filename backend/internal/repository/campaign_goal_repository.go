@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresCampaignGoalRepository stores the goals users set for their campaigns
+type PostgresCampaignGoalRepository struct {
+	db DBTX
+}
+
+// NewPostgresCampaignGoalRepository creates a new PostgreSQL campaign goal repository
+func NewPostgresCampaignGoalRepository(db DBTX) *PostgresCampaignGoalRepository {
+	return &PostgresCampaignGoalRepository{
+		db: db,
+	}
+}
+
+// campaignGoalColumns are the columns scanCampaignGoal reads
+const campaignGoalColumns = `campaign_id, currency, target_cpa, target_ctr, target_roas, daily_spend, budget, updated_at`
+
+// scanCampaignGoal scans a row of campaignGoalColumns
+func scanCampaignGoal(row pgx.Row) (*ingestion.CampaignGoal, error) {
+	goal := &ingestion.CampaignGoal{}
+	err := row.Scan(&goal.CampaignID, &goal.Currency, &goal.TargetCPA, &goal.TargetCTR, &goal.TargetROAS, &goal.DailySpend, &goal.Budget, &goal.UpdatedAt)
+	return goal, err
+}
+
+// ListGoals returns a user's campaign goals by campaign ID
+func (r *PostgresCampaignGoalRepository) ListGoals(ctx context.Context, userID string) (map[string]*ingestion.CampaignGoal, error) {
+	query := `
+		SELECT ` + campaignGoalColumns + `
+		FROM campaign_goals
+		WHERE user_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := make(map[string]*ingestion.CampaignGoal)
+	for rows.Next() {
+		goal, err := scanCampaignGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals[goal.CampaignID] = goal
+	}
+
+	return goals, rows.Err()
+}
+
+// GetGoal returns a user's goal for a campaign
+func (r *PostgresCampaignGoalRepository) GetGoal(ctx context.Context, userID, campaignID string) (*ingestion.CampaignGoal, error) {
+	query := `
+		SELECT ` + campaignGoalColumns + `
+		FROM campaign_goals
+		WHERE user_id = $1 AND campaign_id = $2
+	`
+
+	goal, err := scanCampaignGoal(r.db.QueryRow(ctx, query, userID, campaignID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+// SetGoal creates or replaces a user's goal for a campaign
+func (r *PostgresCampaignGoalRepository) SetGoal(ctx context.Context, userID string, goal *ingestion.CampaignGoal) error {
+	query := `
+		INSERT INTO campaign_goals (user_id, campaign_id, currency, target_cpa, target_ctr, target_roas, daily_spend, budget, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, campaign_id) DO UPDATE
+		SET currency = EXCLUDED.currency,
+			target_cpa = EXCLUDED.target_cpa,
+			target_ctr = EXCLUDED.target_ctr,
+			target_roas = EXCLUDED.target_roas,
+			daily_spend = EXCLUDED.daily_spend,
+			budget = EXCLUDED.budget,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, goal.CampaignID, goal.Currency, goal.TargetCPA, goal.TargetCTR, goal.TargetROAS, goal.DailySpend, goal.Budget, goal.UpdatedAt)
+	return err
+}
+
+// DeleteGoal removes a user's goal for a campaign
+func (r *PostgresCampaignGoalRepository) DeleteGoal(ctx context.Context, userID, campaignID string) error {
+	query := `
+		DELETE FROM campaign_goals
+		WHERE user_id = $1 AND campaign_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, campaignID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
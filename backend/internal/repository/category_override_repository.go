@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// PostgresCategoryOverrideRepository stores users' content category overrides
+type PostgresCategoryOverrideRepository struct {
+	db DBTX
+}
+
+// NewPostgresCategoryOverrideRepository creates a new PostgreSQL category override repository
+func NewPostgresCategoryOverrideRepository(db DBTX) *PostgresCategoryOverrideRepository {
+	return &PostgresCategoryOverrideRepository{
+		db: db,
+	}
+}
+
+// ListOverrides returns a user's category overrides by domain
+func (r *PostgresCategoryOverrideRepository) ListOverrides(ctx context.Context, userID string) (map[string]string, error) {
+	query := `
+		SELECT domain, category
+		FROM category_overrides
+		WHERE user_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]string)
+	for rows.Next() {
+		var domain, category string
+		if err := rows.Scan(&domain, &category); err != nil {
+			return nil, err
+		}
+		overrides[domain] = category
+	}
+
+	return overrides, rows.Err()
+}
+
+// SetOverride creates or replaces a user's category for a domain
+func (r *PostgresCategoryOverrideRepository) SetOverride(ctx context.Context, userID, domain, category string) error {
+	query := `
+		INSERT INTO category_overrides (user_id, domain, category, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, domain) DO UPDATE
+		SET category = EXCLUDED.category,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, domain, category, time.Now())
+	return err
+}
+
+// DeleteOverride removes a user's category for a domain
+func (r *PostgresCategoryOverrideRepository) DeleteOverride(ctx context.Context, userID, domain string) error {
+	query := `
+		DELETE FROM category_overrides
+		WHERE user_id = $1 AND domain = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, domain)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// PostgresCustomMetricRepository stores organizations' custom metrics
+type PostgresCustomMetricRepository struct {
+	db DBTX
+}
+
+// NewPostgresCustomMetricRepository creates a new PostgreSQL custom metric repository
+func NewPostgresCustomMetricRepository(db DBTX) *PostgresCustomMetricRepository {
+	return &PostgresCustomMetricRepository{
+		db: db,
+	}
+}
+
+// ListMetrics returns an organization's custom metrics, ordered by name
+func (r *PostgresCustomMetricRepository) ListMetrics(ctx context.Context, orgID string) ([]*models.CustomMetric, error) {
+	query := `
+		SELECT org_id, name, formula, description, updated_at
+		FROM custom_metrics
+		WHERE org_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []*models.CustomMetric{}
+	for rows.Next() {
+		metric := &models.CustomMetric{}
+		if err := rows.Scan(&metric.OrgID, &metric.Name, &metric.Formula, &metric.Description, &metric.UpdatedAt); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, rows.Err()
+}
+
+// SetMetric creates or replaces an organization's custom metric
+func (r *PostgresCustomMetricRepository) SetMetric(ctx context.Context, metric *models.CustomMetric) error {
+	query := `
+		INSERT INTO custom_metrics (org_id, name, formula, description, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (org_id, name) DO UPDATE
+		SET formula = EXCLUDED.formula,
+			description = EXCLUDED.description,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query, metric.OrgID, metric.Name, metric.Formula, metric.Description, metric.UpdatedAt)
+	return err
+}
+
+// DeleteMetric removes an organization's custom metric
+func (r *PostgresCustomMetricRepository) DeleteMetric(ctx context.Context, orgID, name string) error {
+	query := `
+		DELETE FROM custom_metrics
+		WHERE org_id = $1 AND name = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, orgID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresDataShareRepository stores orgs' data shares in PostgreSQL
+type PostgresDataShareRepository struct {
+	db DBTX
+}
+
+// NewPostgresDataShareRepository creates a new PostgreSQL data share repository
+func NewPostgresDataShareRepository(db DBTX) *PostgresDataShareRepository {
+	return &PostgresDataShareRepository{
+		db: db,
+	}
+}
+
+// dataShareColumns lists the columns selected for a data share, in scan order
+const dataShareColumns = `id, org_id, user_id, name, campaign_ids, date_from, date_to, token_hash, created_at, expires_at, revoked_at`
+
+// Create inserts a new data share
+func (r *PostgresDataShareRepository) Create(ctx context.Context, share *models.DataShare) error {
+	query := `
+		INSERT INTO data_shares (` + dataShareColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		share.ID,
+		share.OrgID,
+		share.UserID,
+		share.Name,
+		share.CampaignIDs,
+		share.From,
+		share.To,
+		share.TokenHash,
+		share.CreatedAt,
+		share.ExpiresAt,
+		share.RevokedAt,
+	)
+
+	return err
+}
+
+// ListByOrg lists an org's data shares, newest first
+func (r *PostgresDataShareRepository) ListByOrg(ctx context.Context, orgID string) ([]*models.DataShare, error) {
+	query := `
+		SELECT ` + dataShareColumns + `
+		FROM data_shares
+		WHERE org_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*models.DataShare{}
+	for rows.Next() {
+		share, err := scanDataShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan data share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// FindByTokenHash finds the data share with a token hash
+func (r *PostgresDataShareRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.DataShare, error) {
+	query := `
+		SELECT ` + dataShareColumns + `
+		FROM data_shares
+		WHERE token_hash = $1
+	`
+
+	share, err := scanDataShare(r.db.QueryRow(ctx, query, tokenHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return share, err
+}
+
+// Revoke revokes an org's active data share
+func (r *PostgresDataShareRepository) Revoke(ctx context.Context, id, orgID string, revokedAt time.Time) error {
+	query := `
+		UPDATE data_shares
+		SET revoked_at = $3
+		WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, orgID, revokedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanDataShare scans a row selected with dataShareColumns
+func scanDataShare(row pgx.Row) (*models.DataShare, error) {
+	share := &models.DataShare{}
+	err := row.Scan(
+		&share.ID,
+		&share.OrgID,
+		&share.UserID,
+		&share.Name,
+		&share.CampaignIDs,
+		&share.From,
+		&share.To,
+		&share.TokenHash,
+		&share.CreatedAt,
+		&share.ExpiresAt,
+		&share.RevokedAt,
+	)
+
+	return share, err
+}
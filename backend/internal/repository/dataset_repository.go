@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresDatasetRepository stores datasets and their files in PostgreSQL
+type PostgresDatasetRepository struct {
+	db DBTX
+}
+
+// NewPostgresDatasetRepository creates a new PostgreSQL dataset repository
+func NewPostgresDatasetRepository(db DBTX) *PostgresDatasetRepository {
+	return &PostgresDatasetRepository{
+		db: db,
+	}
+}
+
+// datasetColumns lists the columns selected for a dataset, in scan order
+const datasetColumns = `id, user_id, name, campaign_id, source, created_at, updated_at`
+
+// Create inserts a new dataset
+func (r *PostgresDatasetRepository) Create(ctx context.Context, dataset *models.Dataset) error {
+	query := `
+		INSERT INTO datasets (` + datasetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		dataset.ID,
+		dataset.UserID,
+		dataset.Name,
+		dataset.CampaignID,
+		dataset.Source,
+		dataset.CreatedAt,
+		dataset.UpdatedAt,
+	)
+
+	return err
+}
+
+// FindByID finds a user's dataset by ID
+func (r *PostgresDatasetRepository) FindByID(ctx context.Context, id, userID string) (*models.Dataset, error) {
+	query := `
+		SELECT ` + datasetColumns + `
+		FROM datasets
+		WHERE id = $1 AND user_id = $2
+	`
+
+	dataset, err := scanDataset(r.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return dataset, nil
+}
+
+// ListByUser lists a user's datasets, most recently updated first
+func (r *PostgresDatasetRepository) ListByUser(ctx context.Context, userID string) ([]*models.Dataset, error) {
+	query := `
+		SELECT ` + datasetColumns + `
+		FROM datasets
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := []*models.Dataset{}
+	for rows.Next() {
+		dataset, err := scanDataset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, rows.Err()
+}
+
+// AddFile appends a file to a dataset, returning ErrDuplicate when it is already a member
+func (r *PostgresDatasetRepository) AddFile(ctx context.Context, datasetID, fileID string, addedAt time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO dataset_files (dataset_id, file_id, added_at)
+		VALUES ($1, $2, $3)
+	`, datasetID, fileID, addedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `UPDATE datasets SET updated_at = $2 WHERE id = $1`, datasetID, addedAt)
+	return err
+}
+
+// ListFileIDs lists the files of a dataset in the order they were appended
+func (r *PostgresDatasetRepository) ListFileIDs(ctx context.Context, datasetID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT file_id
+		FROM dataset_files
+		WHERE dataset_id = $1
+		ORDER BY added_at, file_id
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fileIDs := []string{}
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan dataset file: %w", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	return fileIDs, rows.Err()
+}
+
+// scanDataset scans a single dataset row
+func scanDataset(row pgx.Row) (*models.Dataset, error) {
+	dataset := &models.Dataset{}
+	err := row.Scan(
+		&dataset.ID,
+		&dataset.UserID,
+		&dataset.Name,
+		&dataset.CampaignID,
+		&dataset.Source,
+		&dataset.CreatedAt,
+		&dataset.UpdatedAt,
+	)
+
+	return dataset, err
+}
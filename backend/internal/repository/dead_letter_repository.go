@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresDeadLetterRepository stores dead-lettered processing jobs in PostgreSQL
+type PostgresDeadLetterRepository struct {
+	db DBTX
+}
+
+// NewPostgresDeadLetterRepository creates a new PostgreSQL dead letter repository
+func NewPostgresDeadLetterRepository(db DBTX) *PostgresDeadLetterRepository {
+	return &PostgresDeadLetterRepository{
+		db: db,
+	}
+}
+
+// deadLetterColumns lists the columns selected for a dead-lettered job, in scan order
+const deadLetterColumns = `job_id, file_id, user_id, priority, reprocess, parser, log_format, attempts, error, stack, failed_at`
+
+// Create inserts a dead-lettered job, replacing an earlier entry for the same job
+func (r *PostgresDeadLetterRepository) Create(ctx context.Context, job *models.DeadLetterJob) error {
+	query := `
+		INSERT INTO dead_letter_jobs (` + deadLetterColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (job_id) DO UPDATE
+		SET priority = EXCLUDED.priority,
+			reprocess = EXCLUDED.reprocess,
+			parser = EXCLUDED.parser,
+			log_format = EXCLUDED.log_format,
+			attempts = EXCLUDED.attempts,
+			error = EXCLUDED.error,
+			stack = EXCLUDED.stack,
+			failed_at = EXCLUDED.failed_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		job.JobID,
+		job.FileID,
+		job.UserID,
+		job.Priority,
+		job.Reprocess,
+		job.Parser,
+		job.LogFormat,
+		job.Attempts,
+		job.Error,
+		job.Stack,
+		job.FailedAt,
+	)
+
+	return err
+}
+
+// List lists dead-lettered jobs, most recently failed first
+func (r *PostgresDeadLetterRepository) List(ctx context.Context) ([]*models.DeadLetterJob, error) {
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letter_jobs
+		ORDER BY failed_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.DeadLetterJob{}
+	for rows.Next() {
+		job, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead-lettered job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FindByJobID finds a dead-lettered job
+func (r *PostgresDeadLetterRepository) FindByJobID(ctx context.Context, jobID string) (*models.DeadLetterJob, error) {
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letter_jobs
+		WHERE job_id = $1
+	`
+
+	job, err := scanDeadLetter(r.db.QueryRow(ctx, query, jobID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return job, err
+}
+
+// UpdateParameters saves the priority and parse options a dead-lettered job is requeued with
+func (r *PostgresDeadLetterRepository) UpdateParameters(ctx context.Context, job *models.DeadLetterJob) error {
+	query := `
+		UPDATE dead_letter_jobs
+		SET priority = $2, reprocess = $3, parser = $4, log_format = $5
+		WHERE job_id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, job.JobID, job.Priority, job.Reprocess, job.Parser, job.LogFormat)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a dead-lettered job
+func (r *PostgresDeadLetterRepository) Delete(ctx context.Context, jobID string) error {
+	query := `
+		DELETE FROM dead_letter_jobs
+		WHERE job_id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, jobID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanDeadLetter scans a row selected with deadLetterColumns
+func scanDeadLetter(row pgx.Row) (*models.DeadLetterJob, error) {
+	job := &models.DeadLetterJob{}
+	err := row.Scan(
+		&job.JobID,
+		&job.FileID,
+		&job.UserID,
+		&job.Priority,
+		&job.Reprocess,
+		&job.Parser,
+		&job.LogFormat,
+		&job.Attempts,
+		&job.Error,
+		&job.Stack,
+		&job.FailedAt,
+	)
+
+	return job, err
+}
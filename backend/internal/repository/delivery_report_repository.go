@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresDeliveryReportRepository stores the rows of imported ad server delivery reports
+type PostgresDeliveryReportRepository struct {
+	db DBTX
+}
+
+// NewPostgresDeliveryReportRepository creates a new PostgreSQL delivery report repository
+func NewPostgresDeliveryReportRepository(db DBTX) *PostgresDeliveryReportRepository {
+	return &PostgresDeliveryReportRepository{
+		db: db,
+	}
+}
+
+// DeleteRows removes the rows imported from a file
+func (r *PostgresDeliveryReportRepository) DeleteRows(ctx context.Context, fileID, userID string) error {
+	query := `
+		DELETE FROM delivery_report_rows
+		WHERE file_id = $1 AND user_id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, fileID, userID)
+	return err
+}
+
+// InsertRows stores the rows of a file's delivery report
+func (r *PostgresDeliveryReportRepository) InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.DeliveryReportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Send the rows as parallel arrays so the insert is a single statement
+	var (
+		dates         = make([]time.Time, len(rows))
+		orderIDs      = make([]string, len(rows))
+		orderNames    = make([]string, len(rows))
+		lineItemIDs   = make([]string, len(rows))
+		lineItemNames = make([]string, len(rows))
+		adUnits       = make([]string, len(rows))
+		impressions   = make([]int64, len(rows))
+		clicks        = make([]int64, len(rows))
+		revenue       = make([]int64, len(rows))
+	)
+	for i, row := range rows {
+		date, err := time.Parse("2006-01-02", row.Date)
+		if err != nil {
+			return fmt.Errorf("invalid delivery date %q: %w", row.Date, err)
+		}
+		dates[i] = date
+		orderIDs[i] = row.OrderID
+		orderNames[i] = row.OrderName
+		lineItemIDs[i] = row.LineItemID
+		lineItemNames[i] = row.LineItemName
+		adUnits[i] = row.AdUnit
+		impressions[i] = row.Impressions
+		clicks[i] = row.Clicks
+		revenue[i] = row.RevenueMicros
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO delivery_report_rows (
+			file_id, user_id, imported_at, date, order_id, order_name, line_item_id, line_item_name,
+			ad_unit, impressions, clicks, revenue_micros
+		)
+		SELECT $1, $2, $3, * FROM unnest(
+			$4::date[], $5::text[], $6::text[], $7::text[], $8::text[],
+			$9::text[], $10::bigint[], $11::bigint[], $12::bigint[]
+		)
+	`, fileID, userID, importedAt, dates, orderIDs, orderNames, lineItemIDs, lineItemNames, adUnits, impressions, clicks, revenue)
+
+	return err
+}
+
+// ListRows lists a user's delivery between from and to (inclusive). When several reports cover
+// the same day, order, line item and ad unit, the most recently imported one is used.
+func (r *PostgresDeliveryReportRepository) ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.DeliveryReportRow, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT ON (date, order_id, order_name, line_item_id, line_item_name, ad_unit)
+			date, order_id, order_name, line_item_id, line_item_name, ad_unit, impressions, clicks, revenue_micros
+		FROM delivery_report_rows
+		WHERE user_id = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date, order_id, order_name, line_item_id, line_item_name, ad_unit, imported_at DESC
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delivery := []ingestion.DeliveryReportRow{}
+	for rows.Next() {
+		var row ingestion.DeliveryReportRow
+		var date time.Time
+		if err := rows.Scan(
+			&date,
+			&row.OrderID,
+			&row.OrderName,
+			&row.LineItemID,
+			&row.LineItemName,
+			&row.AdUnit,
+			&row.Impressions,
+			&row.Clicks,
+			&row.RevenueMicros,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery report row: %w", err)
+		}
+		row.Date = date.Format("2006-01-02")
+		delivery = append(delivery, row)
+	}
+
+	return delivery, rows.Err()
+}
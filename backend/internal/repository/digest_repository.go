@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// PostgresDigestRepository tracks weekly digest deliveries in PostgreSQL
+type PostgresDigestRepository struct {
+	db DBTX
+}
+
+// NewPostgresDigestRepository creates a new PostgreSQL digest repository
+func NewPostgresDigestRepository(db DBTX) *PostgresDigestRepository {
+	return &PostgresDigestRepository{
+		db: db,
+	}
+}
+
+// ListRecipients lists the users who opted in to the email digest
+func (r *PostgresDigestRepository) ListRecipients(ctx context.Context) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id IN (SELECT user_id FROM user_preferences WHERE email_digest)
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// ClaimDelivery records that a user's digest for the period ending at periodEnd is being sent,
+// returning false when it was already claimed, so each digest goes out once across instances
+func (r *PostgresDigestRepository) ClaimDelivery(ctx context.Context, userID string, periodEnd, claimedAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO digest_deliveries (user_id, period_end, sent_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, period_end) DO NOTHING
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, periodEnd, claimedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseDelivery removes a claim whose digest failed to send, so it's retried
+func (r *PostgresDigestRepository) ReleaseDelivery(ctx context.Context, userID string, periodEnd time.Time) error {
+	query := `
+		DELETE FROM digest_deliveries
+		WHERE user_id = $1 AND period_end = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, periodEnd)
+	return err
+}
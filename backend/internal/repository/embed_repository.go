@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresEmbedRepository stores users' chart embeds in PostgreSQL
+type PostgresEmbedRepository struct {
+	db DBTX
+}
+
+// NewPostgresEmbedRepository creates a new PostgreSQL embed repository
+func NewPostgresEmbedRepository(db DBTX) *PostgresEmbedRepository {
+	return &PostgresEmbedRepository{
+		db: db,
+	}
+}
+
+// embedColumns lists the columns selected for an embed, in scan order
+const embedColumns = `id, user_id, name, widget, file_id, metric, item_limit, token_hash, created_at, expires_at, revoked_at`
+
+// Create inserts a new embed
+func (r *PostgresEmbedRepository) Create(ctx context.Context, embed *models.Embed) error {
+	query := `
+		INSERT INTO embeds (` + embedColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		embed.ID,
+		embed.UserID,
+		embed.Name,
+		embed.Widget,
+		embed.FileID,
+		embed.Metric,
+		embed.Limit,
+		embed.TokenHash,
+		embed.CreatedAt,
+		embed.ExpiresAt,
+		embed.RevokedAt,
+	)
+
+	return err
+}
+
+// ListByUser lists a user's embeds, newest first
+func (r *PostgresEmbedRepository) ListByUser(ctx context.Context, userID string) ([]*models.Embed, error) {
+	query := `
+		SELECT ` + embedColumns + `
+		FROM embeds
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	embeds := []*models.Embed{}
+	for rows.Next() {
+		embed, err := scanEmbed(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan embed: %w", err)
+		}
+		embeds = append(embeds, embed)
+	}
+
+	return embeds, rows.Err()
+}
+
+// FindByTokenHash finds the embed with a token hash
+func (r *PostgresEmbedRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Embed, error) {
+	query := `
+		SELECT ` + embedColumns + `
+		FROM embeds
+		WHERE token_hash = $1
+	`
+
+	embed, err := scanEmbed(r.db.QueryRow(ctx, query, tokenHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return embed, err
+}
+
+// Revoke revokes a user's active embed
+func (r *PostgresEmbedRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE embeds
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanEmbed scans a row selected with embedColumns
+func scanEmbed(row pgx.Row) (*models.Embed, error) {
+	embed := &models.Embed{}
+	err := row.Scan(
+		&embed.ID,
+		&embed.UserID,
+		&embed.Name,
+		&embed.Widget,
+		&embed.FileID,
+		&embed.Metric,
+		&embed.Limit,
+		&embed.TokenHash,
+		&embed.CreatedAt,
+		&embed.ExpiresAt,
+		&embed.RevokedAt,
+	)
+
+	return embed, err
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+)
+
+// PostgresExchangeRateRepository stores daily exchange rate snapshots in PostgreSQL
+type PostgresExchangeRateRepository struct {
+	db DBTX
+}
+
+// NewPostgresExchangeRateRepository creates a new PostgreSQL exchange rate repository
+func NewPostgresExchangeRateRepository(db DBTX) *PostgresExchangeRateRepository {
+	return &PostgresExchangeRateRepository{
+		db: db,
+	}
+}
+
+// SaveSnapshot stores a day's rates, replacing any stored for the same day
+func (r *PostgresExchangeRateRepository) SaveSnapshot(ctx context.Context, snapshot *fxrates.Snapshot) error {
+	currencies := make([]string, 0, len(snapshot.Rates))
+	rates := make([]float64, 0, len(snapshot.Rates))
+	for currency, rate := range snapshot.Rates {
+		currencies = append(currencies, currency)
+		rates = append(rates, rate)
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO exchange_rates (rate_date, currency, per_usd)
+		SELECT $1, * FROM unnest($2::text[], $3::float8[])
+		ON CONFLICT (rate_date, currency) DO UPDATE
+		SET per_usd = EXCLUDED.per_usd
+	`, snapshot.Date, currencies, rates)
+
+	return err
+}
+
+// ListSnapshots lists the snapshots taken between from and to (inclusive), along with the
+// latest one before from, which is still in effect at from
+func (r *PostgresExchangeRateRepository) ListSnapshots(ctx context.Context, from, to time.Time) ([]*fxrates.Snapshot, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT rate_date, currency, per_usd
+		FROM exchange_rates
+		WHERE rate_date <= $2
+			AND rate_date >= COALESCE((SELECT MAX(rate_date) FROM exchange_rates WHERE rate_date <= $1), $1)
+		ORDER BY rate_date
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []*fxrates.Snapshot{}
+	for rows.Next() {
+		var date time.Time
+		var currency string
+		var rate float64
+		if err := rows.Scan(&date, &currency, &rate); err != nil {
+			return nil, err
+		}
+
+		if n := len(snapshots); n == 0 || !snapshots[n-1].Date.Equal(date) {
+			snapshots = append(snapshots, &fxrates.Snapshot{Date: date, Rates: make(map[string]float64)})
+		}
+		snapshots[len(snapshots)-1].Rates[currency] = rate
+	}
+
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresExperimentRepository stores users' experiments in PostgreSQL
+type PostgresExperimentRepository struct {
+	db DBTX
+}
+
+// NewPostgresExperimentRepository creates a new PostgreSQL experiment repository
+func NewPostgresExperimentRepository(db DBTX) *PostgresExperimentRepository {
+	return &PostgresExperimentRepository{
+		db: db,
+	}
+}
+
+// experimentColumns lists the columns selected for an experiment, in scan order
+const experimentColumns = `id, user_id, name, dimension, control, variant, from_date, to_date, confidence, result, created_at, updated_at`
+
+// Create inserts a new experiment
+func (r *PostgresExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	query := `
+		INSERT INTO experiments (` + experimentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		experiment.ID,
+		experiment.UserID,
+		experiment.Name,
+		experiment.Dimension,
+		experiment.Control,
+		experiment.Variant,
+		experiment.From,
+		experiment.To,
+		experiment.Confidence,
+		experiment.Result,
+		experiment.CreatedAt,
+		experiment.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// ListByUser lists a user's experiments, newest first
+func (r *PostgresExperimentRepository) ListByUser(ctx context.Context, userID string) ([]*models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []*models.Experiment{}
+	for rows.Next() {
+		experiment, err := scanExperiment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan experiment: %w", err)
+		}
+		experiments = append(experiments, experiment)
+	}
+
+	return experiments, rows.Err()
+}
+
+// FindByID finds one of a user's experiments
+func (r *PostgresExperimentRepository) FindByID(ctx context.Context, id, userID string) (*models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE id = $1 AND user_id = $2
+	`
+
+	experiment, err := scanExperiment(r.db.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+// SaveResult replaces the result of an experiment's latest analysis
+func (r *PostgresExperimentRepository) SaveResult(ctx context.Context, id, userID string, result *models.ExperimentResult) error {
+	query := `
+		UPDATE experiments
+		SET result = $3, updated_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, result, result.AnalyzedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes one of a user's experiments
+func (r *PostgresExperimentRepository) Delete(ctx context.Context, id, userID string) error {
+	query := `
+		DELETE FROM experiments
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanExperiment scans a row selected with experimentColumns
+func scanExperiment(row pgx.Row) (*models.Experiment, error) {
+	experiment := &models.Experiment{}
+	err := row.Scan(
+		&experiment.ID,
+		&experiment.UserID,
+		&experiment.Name,
+		&experiment.Dimension,
+		&experiment.Control,
+		&experiment.Variant,
+		&experiment.From,
+		&experiment.To,
+		&experiment.Confidence,
+		&experiment.Result,
+		&experiment.CreatedAt,
+		&experiment.UpdatedAt,
+	)
+
+	return experiment, err
+}
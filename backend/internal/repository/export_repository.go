@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresExportRepository stores export destinations and the sync state of their tables
+type PostgresExportRepository struct {
+	db DBTX
+}
+
+// NewPostgresExportRepository creates a new PostgreSQL export repository
+func NewPostgresExportRepository(db DBTX) *PostgresExportRepository {
+	return &PostgresExportRepository{
+		db: db,
+	}
+}
+
+// exportDestinationColumns lists the columns selected for an export destination, in scan order
+const exportDestinationColumns = `id, user_id, name, provider, settings, credentials, status, last_error, last_synced_at, created_at, updated_at`
+
+// exportTableSyncColumns lists the columns selected for a table sync, in scan order
+const exportTableSyncColumns = `destination_id, table_name, status, rows_loaded, last_error, sync_cursor, last_synced_at, last_succeeded_at, updated_at`
+
+// Create stores a new export destination
+func (r *PostgresExportRepository) Create(ctx context.Context, destination *models.ExportDestination) error {
+	query := `
+		INSERT INTO export_destinations (` + exportDestinationColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		destination.ID,
+		destination.UserID,
+		destination.Name,
+		destination.Provider,
+		destination.Settings,
+		destination.Credentials,
+		destination.Status,
+		destination.LastError,
+		destination.LastSyncedAt,
+		destination.CreatedAt,
+		destination.UpdatedAt,
+	)
+	return err
+}
+
+// FindByID finds a user's export destination by ID
+func (r *PostgresExportRepository) FindByID(ctx context.Context, id, userID string) (*models.ExportDestination, error) {
+	query := `
+		SELECT ` + exportDestinationColumns + `
+		FROM export_destinations
+		WHERE id = $1 AND user_id = $2
+	`
+
+	destination, err := scanExportDestination(r.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return destination, nil
+}
+
+// ListByUser lists a user's export destinations, oldest first
+func (r *PostgresExportRepository) ListByUser(ctx context.Context, userID string) ([]*models.ExportDestination, error) {
+	query := `
+		SELECT ` + exportDestinationColumns + `
+		FROM export_destinations
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	return r.list(ctx, query, userID)
+}
+
+// ListDue lists export destinations of every user not synced since the cutoff, least recently synced first
+func (r *PostgresExportRepository) ListDue(ctx context.Context, cutoff time.Time) ([]*models.ExportDestination, error) {
+	query := `
+		SELECT ` + exportDestinationColumns + `
+		FROM export_destinations
+		WHERE last_synced_at IS NULL OR last_synced_at < $1
+		ORDER BY last_synced_at NULLS FIRST
+	`
+
+	return r.list(ctx, query, cutoff)
+}
+
+// list runs a query selecting exportDestinationColumns
+func (r *PostgresExportRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.ExportDestination, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	destinations := []*models.ExportDestination{}
+	for rows.Next() {
+		destination, err := scanExportDestination(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export destination: %w", err)
+		}
+		destinations = append(destinations, destination)
+	}
+
+	return destinations, rows.Err()
+}
+
+// UpdateSyncStatus records the outcome of a destination's sync
+func (r *PostgresExportRepository) UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error {
+	query := `
+		UPDATE export_destinations
+		SET status = $2, last_error = $3, last_synced_at = $4, updated_at = $4
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, status, lastError, syncedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a user's export destination along with its table sync state. The data already
+// exported stays in the warehouse.
+func (r *PostgresExportRepository) Delete(ctx context.Context, id, userID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM export_destinations WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListTableSyncs lists the sync state of a destination's tables, by table name
+func (r *PostgresExportRepository) ListTableSyncs(ctx context.Context, destinationID string) ([]*models.ExportTableSync, error) {
+	query := `
+		SELECT ` + exportTableSyncColumns + `
+		FROM export_table_syncs
+		WHERE destination_id = $1
+		ORDER BY table_name
+	`
+
+	rows, err := r.db.Query(ctx, query, destinationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	syncs := []*models.ExportTableSync{}
+	for rows.Next() {
+		var sync models.ExportTableSync
+		if err := rows.Scan(
+			&sync.DestinationID,
+			&sync.Table,
+			&sync.Status,
+			&sync.Rows,
+			&sync.LastError,
+			&sync.Cursor,
+			&sync.LastSyncedAt,
+			&sync.LastSucceededAt,
+			&sync.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan export table sync: %w", err)
+		}
+		syncs = append(syncs, &sync)
+	}
+
+	return syncs, rows.Err()
+}
+
+// SaveTableSync creates or replaces a table's sync state
+func (r *PostgresExportRepository) SaveTableSync(ctx context.Context, sync *models.ExportTableSync) error {
+	query := `
+		INSERT INTO export_table_syncs (` + exportTableSyncColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (destination_id, table_name) DO UPDATE
+		SET status = EXCLUDED.status,
+			rows_loaded = EXCLUDED.rows_loaded,
+			last_error = EXCLUDED.last_error,
+			sync_cursor = EXCLUDED.sync_cursor,
+			last_synced_at = EXCLUDED.last_synced_at,
+			last_succeeded_at = EXCLUDED.last_succeeded_at,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		sync.DestinationID,
+		sync.Table,
+		sync.Status,
+		sync.Rows,
+		sync.LastError,
+		sync.Cursor,
+		sync.LastSyncedAt,
+		sync.LastSucceededAt,
+		sync.UpdatedAt,
+	)
+	return err
+}
+
+// scanExportDestination scans a row selected with exportDestinationColumns
+func scanExportDestination(row pgx.Row) (*models.ExportDestination, error) {
+	var destination models.ExportDestination
+	err := row.Scan(
+		&destination.ID,
+		&destination.UserID,
+		&destination.Name,
+		&destination.Provider,
+		&destination.Settings,
+		&destination.Credentials,
+		&destination.Status,
+		&destination.LastError,
+		&destination.LastSyncedAt,
+		&destination.CreatedAt,
+		&destination.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &destination, nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresFileRepository stores file metadata in PostgreSQL
+type PostgresFileRepository struct {
+	db DBTX
+}
+
+// NewPostgresFileRepository creates a new PostgreSQL file repository
+func NewPostgresFileRepository(db DBTX) *PostgresFileRepository {
+	return &PostgresFileRepository{
+		db: db,
+	}
+}
+
+// fileColumns lists the columns selected for a file, in scan order
+const fileColumns = `id, user_id, file_name, file_size, file_type, file_path, status, uploaded_at, updated_at, tags, folder`
+
+// Create inserts the metadata of a new file
+func (r *PostgresFileRepository) Create(ctx context.Context, file *models.File) error {
+	query := `
+		INSERT INTO files (` + fileColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	// A nil slice would be stored as NULL rather than as no tags
+	tags := file.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		file.ID,
+		file.UserID,
+		file.FileName,
+		file.FileSize,
+		file.FileType,
+		file.FilePath,
+		file.Status,
+		file.UploadedAt,
+		file.UpdatedAt,
+		tags,
+		file.Folder,
+	)
+
+	return err
+}
+
+// FindByID finds a user's file by ID
+func (r *PostgresFileRepository) FindByID(ctx context.Context, id, userID string) (*models.File, error) {
+	query := `
+		SELECT ` + fileColumns + `
+		FROM files
+		WHERE id = $1 AND user_id = $2
+	`
+
+	file, err := scanFile(r.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// ListByUser lists a user's files, newest first
+func (r *PostgresFileRepository) ListByUser(ctx context.Context, userID string) ([]*models.File, error) {
+	query := `
+		SELECT ` + fileColumns + `
+		FROM files
+		WHERE user_id = $1
+		ORDER BY uploaded_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// ListAll lists every user's files, for storage maintenance
+func (r *PostgresFileRepository) ListAll(ctx context.Context) ([]*models.File, error) {
+	query := `
+		SELECT ` + fileColumns + `
+		FROM files
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// UpdateStatus sets the status of a user's file
+func (r *PostgresFileRepository) UpdateStatus(ctx context.Context, id, userID, status string) error {
+	query := `
+		UPDATE files
+		SET status = $3, updated_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, status, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddTags adds tags to a user's file, keeping its tags sorted and each once
+func (r *PostgresFileRepository) AddTags(ctx context.Context, id, userID string, tags []string) error {
+	query := `
+		UPDATE files
+		SET tags = ARRAY(SELECT DISTINCT tag FROM unnest(tags || $3::TEXT[]) AS tag ORDER BY tag), updated_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, tags, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetFolder files a user's file under a folder
+func (r *PostgresFileRepository) SetFolder(ctx context.Context, id, userID, folder string) error {
+	query := `
+		UPDATE files
+		SET folder = $3, updated_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, folder, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the metadata of a user's file
+func (r *PostgresFileRepository) Delete(ctx context.Context, id, userID string) error {
+	query := `
+		DELETE FROM files
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanFile scans a single file row
+func scanFile(row pgx.Row) (*models.File, error) {
+	file := &models.File{}
+	err := row.Scan(
+		&file.ID,
+		&file.UserID,
+		&file.FileName,
+		&file.FileSize,
+		&file.FileType,
+		&file.FilePath,
+		&file.Status,
+		&file.UploadedAt,
+		&file.UpdatedAt,
+		&file.Tags,
+		&file.Folder,
+	)
+
+	return file, err
+}
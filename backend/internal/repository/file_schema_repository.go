@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresFileSchemaRepository stores the column sets of uploaded logs and their drift
+type PostgresFileSchemaRepository struct {
+	db DBTX
+}
+
+// NewPostgresFileSchemaRepository creates a new PostgreSQL file schema repository
+func NewPostgresFileSchemaRepository(db DBTX) *PostgresFileSchemaRepository {
+	return &PostgresFileSchemaRepository{
+		db: db,
+	}
+}
+
+// SaveSchema records a file's schema, replacing the one recorded when it was last processed
+func (r *PostgresFileSchemaRepository) SaveSchema(ctx context.Context, schema *ingestion.FileSchema) error {
+	query := `
+		INSERT INTO file_schemas (file_id, user_id, source, columns, mapped, drift, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (file_id) DO UPDATE
+		SET source = EXCLUDED.source,
+			columns = EXCLUDED.columns,
+			mapped = EXCLUDED.mapped,
+			drift = EXCLUDED.drift,
+			created_at = EXCLUDED.created_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		schema.FileID,
+		schema.UserID,
+		schema.Source,
+		schema.Columns,
+		schema.Mapped,
+		schema.Drift,
+		schema.CreatedAt,
+	)
+
+	return err
+}
+
+// PreviousSchema returns the latest schema of a user's uploads from a source other than the
+// file's own, or nil if there is none
+func (r *PostgresFileSchemaRepository) PreviousSchema(ctx context.Context, userID, source, fileID string) (*ingestion.FileSchema, error) {
+	query := `
+		SELECT file_id, user_id, source, columns, mapped, drift, created_at
+		FROM file_schemas
+		WHERE user_id = $1 AND source = $2 AND file_id <> $3
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	schema, err := scanFileSchema(r.db.QueryRow(ctx, query, userID, source, fileID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// FindByFileID finds the schema recorded for a user's file
+func (r *PostgresFileSchemaRepository) FindByFileID(ctx context.Context, fileID, userID string) (*ingestion.FileSchema, error) {
+	query := `
+		SELECT file_id, user_id, source, columns, mapped, drift, created_at
+		FROM file_schemas
+		WHERE file_id = $1 AND user_id = $2
+	`
+
+	schema, err := scanFileSchema(r.db.QueryRow(ctx, query, fileID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// scanFileSchema scans a single file schema row
+func scanFileSchema(row pgx.Row) (*ingestion.FileSchema, error) {
+	schema := &ingestion.FileSchema{}
+	err := row.Scan(
+		&schema.FileID,
+		&schema.UserID,
+		&schema.Source,
+		&schema.Columns,
+		&schema.Mapped,
+		&schema.Drift,
+		&schema.CreatedAt,
+	)
+
+	return schema, err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgresIdempotencyRepository stores idempotency keys in PostgreSQL
+type PostgresIdempotencyRepository struct {
+	db DBTX
+}
+
+// NewPostgresIdempotencyRepository creates a new PostgreSQL idempotency key repository
+func NewPostgresIdempotencyRepository(db DBTX) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{
+		db: db,
+	}
+}
+
+// Create records a key, returning ErrDuplicate when the user has already used it
+func (r *PostgresIdempotencyRepository) Create(ctx context.Context, key *models.IdempotencyKey) error {
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, file_id, job_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query, key.UserID, key.Key, key.FileID, key.JobID, key.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// Find finds a user's key
+func (r *PostgresIdempotencyRepository) Find(ctx context.Context, userID, key string) (*models.IdempotencyKey, error) {
+	query := `
+		SELECT user_id, key, file_id, job_id, created_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+
+	record := &models.IdempotencyKey{}
+	err := r.db.QueryRow(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.FileID,
+		&record.JobID,
+		&record.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// DeleteBefore removes keys created before the cutoff, returning how many were removed
+func (r *PostgresIdempotencyRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// isUniqueViolation reports whether an error is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
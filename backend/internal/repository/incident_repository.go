@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresIncidentRepository stores status page incidents in PostgreSQL
+type PostgresIncidentRepository struct {
+	db DBTX
+}
+
+// NewPostgresIncidentRepository creates a new PostgreSQL incident repository
+func NewPostgresIncidentRepository(db DBTX) *PostgresIncidentRepository {
+	return &PostgresIncidentRepository{
+		db: db,
+	}
+}
+
+// incidentColumns lists the columns selected for an incident, in scan order
+const incidentColumns = `id, title, message, severity, started_at, updated_at, resolved_at`
+
+// Create inserts a new incident
+func (r *PostgresIncidentRepository) Create(ctx context.Context, incident *models.Incident) error {
+	query := `
+		INSERT INTO incidents (` + incidentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		incident.ID,
+		incident.Title,
+		incident.Message,
+		incident.Severity,
+		incident.StartedAt,
+		incident.UpdatedAt,
+		incident.ResolvedAt,
+	)
+
+	return err
+}
+
+// FindByID finds an incident
+func (r *PostgresIncidentRepository) FindByID(ctx context.Context, id string) (*models.Incident, error) {
+	query := `
+		SELECT ` + incidentColumns + `
+		FROM incidents
+		WHERE id = $1
+	`
+
+	incident, err := scanIncident(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return incident, err
+}
+
+// ListSince lists incidents that are unresolved or were resolved after a time, newest first
+func (r *PostgresIncidentRepository) ListSince(ctx context.Context, since time.Time) ([]*models.Incident, error) {
+	query := `
+		SELECT ` + incidentColumns + `
+		FROM incidents
+		WHERE resolved_at IS NULL OR resolved_at > $1
+		ORDER BY started_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []*models.Incident{}
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		incidents = append(incidents, incident)
+	}
+
+	return incidents, rows.Err()
+}
+
+// Update saves an incident's title, message, severity and resolution
+func (r *PostgresIncidentRepository) Update(ctx context.Context, incident *models.Incident) error {
+	query := `
+		UPDATE incidents
+		SET title = $2, message = $3, severity = $4, updated_at = $5, resolved_at = $6
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query,
+		incident.ID,
+		incident.Title,
+		incident.Message,
+		incident.Severity,
+		incident.UpdatedAt,
+		incident.ResolvedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanIncident scans a row selected with incidentColumns
+func scanIncident(row pgx.Row) (*models.Incident, error) {
+	incident := &models.Incident{}
+	err := row.Scan(
+		&incident.ID,
+		&incident.Title,
+		&incident.Message,
+		&incident.Severity,
+		&incident.StartedAt,
+		&incident.UpdatedAt,
+		&incident.ResolvedAt,
+	)
+
+	return incident, err
+}
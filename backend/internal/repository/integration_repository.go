@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresIntegrationRepository stores integrations and the performance and outcomes pulled from them
+type PostgresIntegrationRepository struct {
+	db DBTX
+}
+
+// NewPostgresIntegrationRepository creates a new PostgreSQL integration repository
+func NewPostgresIntegrationRepository(db DBTX) *PostgresIntegrationRepository {
+	return &PostgresIntegrationRepository{
+		db: db,
+	}
+}
+
+// integrationColumns lists the columns selected for an integration, in scan order
+const integrationColumns = `id, user_id, provider, account_id, settings, refresh_token, status, last_error, last_synced_at, created_at, updated_at`
+
+// Upsert creates an integration, or replaces the token and settings of the user's existing
+// connection to the same account and reactivates it. The integration's ID is set to the stored row's.
+func (r *PostgresIntegrationRepository) Upsert(ctx context.Context, integration *models.Integration) error {
+	query := `
+		INSERT INTO integrations (` + integrationColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_id, provider, account_id) DO UPDATE
+		SET settings = EXCLUDED.settings,
+			refresh_token = EXCLUDED.refresh_token,
+			status = EXCLUDED.status,
+			last_error = EXCLUDED.last_error,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		integration.ID,
+		integration.UserID,
+		integration.Provider,
+		integration.AccountID,
+		integration.Settings,
+		integration.RefreshToken,
+		integration.Status,
+		integration.LastError,
+		integration.LastSyncedAt,
+		integration.CreatedAt,
+		integration.UpdatedAt,
+	).Scan(&integration.ID, &integration.CreatedAt)
+}
+
+// FindByID finds a user's integration by ID
+func (r *PostgresIntegrationRepository) FindByID(ctx context.Context, id, userID string) (*models.Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations
+		WHERE id = $1 AND user_id = $2
+	`
+
+	integration, err := scanIntegration(r.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return integration, nil
+}
+
+// ListByUser lists a user's integrations, oldest first
+func (r *PostgresIntegrationRepository) ListByUser(ctx context.Context, userID string) ([]*models.Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	return r.list(ctx, query, userID)
+}
+
+// ListDue lists integrations of every user not synced since the cutoff, least recently synced first
+func (r *PostgresIntegrationRepository) ListDue(ctx context.Context, cutoff time.Time) ([]*models.Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations
+		WHERE last_synced_at IS NULL OR last_synced_at < $1
+		ORDER BY last_synced_at NULLS FIRST
+	`
+
+	return r.list(ctx, query, cutoff)
+}
+
+// list runs a query selecting integrationColumns
+func (r *PostgresIntegrationRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.Integration, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations := []*models.Integration{}
+	for rows.Next() {
+		integration, err := scanIntegration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan integration: %w", err)
+		}
+		integrations = append(integrations, integration)
+	}
+
+	return integrations, rows.Err()
+}
+
+// UpdateSyncStatus records the outcome of a sync attempt
+func (r *PostgresIntegrationRepository) UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error {
+	query := `
+		UPDATE integrations
+		SET status = $2, last_error = $3, last_synced_at = $4, updated_at = $4
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, status, lastError, syncedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a user's integration along with the performance pulled from it
+func (r *PostgresIntegrationRepository) Delete(ctx context.Context, id, userID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM integrations WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UpsertPerformance writes daily campaign performance, replacing rows already pulled for the
+// same integration, campaign and day since platforms restate recent numbers
+func (r *PostgresIntegrationRepository) UpsertPerformance(ctx context.Context, rows []models.CampaignPerformance) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Send the rows as parallel arrays so the upsert is a single statement
+	var (
+		integrationIDs = make([]string, len(rows))
+		userIDs        = make([]string, len(rows))
+		providers      = make([]string, len(rows))
+		campaignIDs    = make([]string, len(rows))
+		campaignNames  = make([]string, len(rows))
+		dates          = make([]time.Time, len(rows))
+		impressions    = make([]int64, len(rows))
+		clicks         = make([]int64, len(rows))
+		conversions    = make([]float64, len(rows))
+		costs          = make([]int64, len(rows))
+	)
+	for i, row := range rows {
+		integrationIDs[i] = row.IntegrationID
+		userIDs[i] = row.UserID
+		providers[i] = row.Provider
+		campaignIDs[i] = row.CampaignID
+		campaignNames[i] = row.CampaignName
+		dates[i] = row.Date
+		impressions[i] = row.Impressions
+		clicks[i] = row.Clicks
+		conversions[i] = row.Conversions
+		costs[i] = row.CostMicros
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO campaign_performance (
+			integration_id, user_id, provider, campaign_id, campaign_name, date,
+			impressions, clicks, conversions, cost_micros
+		)
+		SELECT * FROM unnest(
+			$1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::date[],
+			$7::bigint[], $8::bigint[], $9::double precision[], $10::bigint[]
+		)
+		ON CONFLICT (integration_id, campaign_id, date) DO UPDATE
+		SET campaign_name = EXCLUDED.campaign_name,
+			impressions = EXCLUDED.impressions,
+			clicks = EXCLUDED.clicks,
+			conversions = EXCLUDED.conversions,
+			cost_micros = EXCLUDED.cost_micros
+	`, integrationIDs, userIDs, providers, campaignIDs, campaignNames, dates, impressions, clicks, conversions, costs)
+
+	return err
+}
+
+// ListPerformance lists a user's daily campaign performance between from and to (inclusive)
+func (r *PostgresIntegrationRepository) ListPerformance(ctx context.Context, userID string, from, to time.Time) ([]models.CampaignPerformance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT integration_id, user_id, provider, campaign_id, campaign_name, date,
+			impressions, clicks, conversions, cost_micros
+		FROM campaign_performance
+		WHERE user_id = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date, provider, campaign_id
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	performance := []models.CampaignPerformance{}
+	for rows.Next() {
+		var row models.CampaignPerformance
+		if err := rows.Scan(
+			&row.IntegrationID,
+			&row.UserID,
+			&row.Provider,
+			&row.CampaignID,
+			&row.CampaignName,
+			&row.Date,
+			&row.Impressions,
+			&row.Clicks,
+			&row.Conversions,
+			&row.CostMicros,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign performance: %w", err)
+		}
+		performance = append(performance, row)
+	}
+
+	return performance, rows.Err()
+}
+
+// UpsertSiteOutcomes writes daily on-site outcomes, replacing rows already pulled for the same
+// integration, day and UTM source, medium and campaign
+func (r *PostgresIntegrationRepository) UpsertSiteOutcomes(ctx context.Context, rows []models.SiteOutcome) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Send the rows as parallel arrays so the upsert is a single statement
+	var (
+		integrationIDs = make([]string, len(rows))
+		userIDs        = make([]string, len(rows))
+		dates          = make([]time.Time, len(rows))
+		sources        = make([]string, len(rows))
+		mediums        = make([]string, len(rows))
+		campaigns      = make([]string, len(rows))
+		sessions       = make([]int64, len(rows))
+		conversions    = make([]float64, len(rows))
+		revenue        = make([]float64, len(rows))
+	)
+	for i, row := range rows {
+		integrationIDs[i] = row.IntegrationID
+		userIDs[i] = row.UserID
+		dates[i] = row.Date
+		sources[i] = row.Source
+		mediums[i] = row.Medium
+		campaigns[i] = row.Campaign
+		sessions[i] = row.Sessions
+		conversions[i] = row.Conversions
+		revenue[i] = row.Revenue
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO site_outcomes (
+			integration_id, user_id, date, source, medium, campaign, sessions, conversions, revenue
+		)
+		SELECT * FROM unnest(
+			$1::text[], $2::text[], $3::date[], $4::text[], $5::text[], $6::text[],
+			$7::bigint[], $8::double precision[], $9::double precision[]
+		)
+		ON CONFLICT (integration_id, date, source, medium, campaign) DO UPDATE
+		SET sessions = EXCLUDED.sessions,
+			conversions = EXCLUDED.conversions,
+			revenue = EXCLUDED.revenue
+	`, integrationIDs, userIDs, dates, sources, mediums, campaigns, sessions, conversions, revenue)
+
+	return err
+}
+
+// ListSiteOutcomes lists a user's daily on-site outcomes between from and to (inclusive)
+func (r *PostgresIntegrationRepository) ListSiteOutcomes(ctx context.Context, userID string, from, to time.Time) ([]models.SiteOutcome, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT integration_id, user_id, date, source, medium, campaign, sessions, conversions, revenue
+		FROM site_outcomes
+		WHERE user_id = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date, campaign
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outcomes := []models.SiteOutcome{}
+	for rows.Next() {
+		var row models.SiteOutcome
+		if err := rows.Scan(
+			&row.IntegrationID,
+			&row.UserID,
+			&row.Date,
+			&row.Source,
+			&row.Medium,
+			&row.Campaign,
+			&row.Sessions,
+			&row.Conversions,
+			&row.Revenue,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan site outcome: %w", err)
+		}
+		outcomes = append(outcomes, row)
+	}
+
+	return outcomes, rows.Err()
+}
+
+// scanIntegration scans a single integration row
+func scanIntegration(row pgx.Row) (*models.Integration, error) {
+	integration := &models.Integration{}
+	err := row.Scan(
+		&integration.ID,
+		&integration.UserID,
+		&integration.Provider,
+		&integration.AccountID,
+		&integration.Settings,
+		&integration.RefreshToken,
+		&integration.Status,
+		&integration.LastError,
+		&integration.LastSyncedAt,
+		&integration.CreatedAt,
+		&integration.UpdatedAt,
+	)
+
+	return integration, err
+}
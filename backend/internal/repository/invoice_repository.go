@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresInvoiceRepository stores the rows of imported DSP invoices
+type PostgresInvoiceRepository struct {
+	db DBTX
+}
+
+// NewPostgresInvoiceRepository creates a new PostgreSQL invoice repository
+func NewPostgresInvoiceRepository(db DBTX) *PostgresInvoiceRepository {
+	return &PostgresInvoiceRepository{
+		db: db,
+	}
+}
+
+// DeleteRows removes the rows imported from a file
+func (r *PostgresInvoiceRepository) DeleteRows(ctx context.Context, fileID, userID string) error {
+	query := `
+		DELETE FROM invoice_rows
+		WHERE file_id = $1 AND user_id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, fileID, userID)
+	return err
+}
+
+// InsertRows stores the rows of a file's invoice
+func (r *PostgresInvoiceRepository) InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.InvoiceRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Send the rows as parallel arrays so the insert is a single statement
+	var (
+		dates         = make([]time.Time, len(rows))
+		campaignIDs   = make([]string, len(rows))
+		campaignNames = make([]string, len(rows))
+		impressions   = make([]int64, len(rows))
+		spend         = make([]int64, len(rows))
+	)
+	for i, row := range rows {
+		date, err := time.Parse("2006-01-02", row.Date)
+		if err != nil {
+			return fmt.Errorf("invalid invoice date %q: %w", row.Date, err)
+		}
+		dates[i] = date
+		campaignIDs[i] = row.CampaignID
+		campaignNames[i] = row.CampaignName
+		impressions[i] = row.Impressions
+		spend[i] = row.SpendMicros
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO invoice_rows (file_id, user_id, imported_at, date, campaign_id, campaign_name, impressions, spend_micros)
+		SELECT $1, $2, $3, * FROM unnest($4::date[], $5::text[], $6::text[], $7::bigint[], $8::bigint[])
+	`, fileID, userID, importedAt, dates, campaignIDs, campaignNames, impressions, spend)
+
+	return err
+}
+
+// ListRows lists a user's invoiced spend between from and to (inclusive). When several invoices
+// cover the same day and campaign, the most recently imported one is used.
+func (r *PostgresInvoiceRepository) ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.InvoiceRow, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT ON (date, campaign_id, campaign_name)
+			date, campaign_id, campaign_name, impressions, spend_micros
+		FROM invoice_rows
+		WHERE user_id = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date, campaign_id, campaign_name, imported_at DESC
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoiced := []ingestion.InvoiceRow{}
+	for rows.Next() {
+		var row ingestion.InvoiceRow
+		var date time.Time
+		if err := rows.Scan(&date, &row.CampaignID, &row.CampaignName, &row.Impressions, &row.SpendMicros); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice row: %w", err)
+		}
+		row.Date = date.Format("2006-01-02")
+		invoiced = append(invoiced, row)
+	}
+
+	return invoiced, rows.Err()
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresJobRepository stores processing jobs in PostgreSQL
+type PostgresJobRepository struct {
+	db DBTX
+}
+
+// NewPostgresJobRepository creates a new PostgreSQL job repository
+func NewPostgresJobRepository(db DBTX) *PostgresJobRepository {
+	return &PostgresJobRepository{
+		db: db,
+	}
+}
+
+// Enqueue inserts a new processing job
+func (r *PostgresJobRepository) Enqueue(ctx context.Context, job *models.ProcessingJob) error {
+	query := `
+		INSERT INTO processing_jobs (id, file_id, user_id, status, priority, reprocess, parser, log_format, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		job.ID,
+		job.FileID,
+		job.UserID,
+		job.Status,
+		job.Priority,
+		job.Reprocess,
+		job.Parser,
+		job.LogFormat,
+		job.Error,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+// FindByID finds a processing job by ID
+func (r *PostgresJobRepository) FindByID(ctx context.Context, id string) (*models.ProcessingJob, error) {
+	query := `
+		SELECT id, file_id, user_id, status, priority, reprocess, parser, log_format, error, attempts, created_at, updated_at, started_at, completed_at
+		FROM processing_jobs
+		WHERE id = $1
+	`
+
+	job, err := scanJob(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// UpdateStatus moves a job to a new status, recording when it started or finished. Each start
+// counts as an attempt.
+func (r *PostgresJobRepository) UpdateStatus(ctx context.Context, id, status, errorMessage string) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = $2,
+			error = $3,
+			updated_at = $4,
+			attempts = CASE WHEN $2 = 'running' THEN attempts + 1 ELSE attempts END,
+			started_at = CASE WHEN $2 = 'running' THEN $4 ELSE started_at END,
+			completed_at = CASE WHEN $2 IN ('completed', 'failed', 'canceled') THEN $4 ELSE completed_at END
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, status, errorMessage, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Requeue puts a finished job back in the queue with its attempts reset, saving its priority
+// and parse options
+func (r *PostgresJobRepository) Requeue(ctx context.Context, job *models.ProcessingJob) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = 'queued',
+			priority = $2,
+			reprocess = $3,
+			parser = $4,
+			log_format = $5,
+			error = '',
+			attempts = 0,
+			updated_at = $6,
+			started_at = NULL,
+			completed_at = NULL
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, job.ID, job.Priority, job.Reprocess, job.Parser, job.LogFormat, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CancelActive cancels a file's queued and running jobs, returning their IDs
+func (r *PostgresJobRepository) CancelActive(ctx context.Context, fileID string) ([]string, error) {
+	query := `
+		UPDATE processing_jobs
+		SET status = 'canceled',
+			error = 'canceled by user',
+			updated_at = $2,
+			completed_at = $2
+		WHERE file_id = $1 AND status IN ('queued', 'running')
+		RETURNING id
+	`
+
+	rows, err := r.db.Query(ctx, query, fileID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan job ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// HasActive reports whether a file has a queued or running job
+func (r *PostgresJobRepository) HasActive(ctx context.Context, fileID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM processing_jobs WHERE file_id = $1 AND status IN ('queued', 'running')
+		)
+	`
+
+	var active bool
+	if err := r.db.QueryRow(ctx, query, fileID).Scan(&active); err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
+
+// CountByStatus counts the jobs with a status
+func (r *PostgresJobRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM processing_jobs WHERE status = $1
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, status).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// OldestCreatedAt returns when the oldest job with a status was created, or nil when there is none
+func (r *PostgresJobRepository) OldestCreatedAt(ctx context.Context, status string) (*time.Time, error) {
+	query := `
+		SELECT MIN(created_at) FROM processing_jobs WHERE status = $1
+	`
+
+	var oldest *time.Time
+	if err := r.db.QueryRow(ctx, query, status).Scan(&oldest); err != nil {
+		return nil, err
+	}
+
+	return oldest, nil
+}
+
+// ListByStatus lists the jobs with a status, highest priority first, then oldest first
+func (r *PostgresJobRepository) ListByStatus(ctx context.Context, status string) ([]*models.ProcessingJob, error) {
+	query := `
+		SELECT id, file_id, user_id, status, priority, reprocess, parser, log_format, error, attempts, created_at, updated_at, started_at, completed_at
+		FROM processing_jobs
+		WHERE status = $1
+		ORDER BY CASE priority WHEN 'high' THEN 0 WHEN 'normal' THEN 1 ELSE 2 END, created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.ProcessingJob{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// scanJob scans a single job row
+func scanJob(row pgx.Row) (*models.ProcessingJob, error) {
+	job := &models.ProcessingJob{}
+	err := row.Scan(
+		&job.ID,
+		&job.FileID,
+		&job.UserID,
+		&job.Status,
+		&job.Priority,
+		&job.Reprocess,
+		&job.Parser,
+		&job.LogFormat,
+		&job.Error,
+		&job.Attempts,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.StartedAt,
+		&job.CompletedAt,
+	)
+
+	return job, err
+}
+
+// CountUploadsSince counts the uploads an org's users have made since a time, by their first
+// processing job; reprocessing a file doesn't count as an upload
+func (r *PostgresJobRepository) CountUploadsSince(ctx context.Context, orgID string, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM processing_jobs j
+		JOIN users u ON u.id = j.user_id
+		WHERE u.org_id = $1 AND NOT j.reprocess AND j.created_at >= $2
+	`
+
+	var count int
+	err := r.db.QueryRow(ctx, query, orgID, since).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/jackc/pgx/v5"
+)
+
+// logRecordPartitionPrefix prefixes the names of the monthly log_records partitions, e.g. log_records_y2024m03
+const logRecordPartitionPrefix = "log_records_y"
+
+// knownPartitions remembers partitions that exist so inserts don't re-issue DDL for every batch
+var knownPartitions sync.Map
+
+// logRecordColumns lists the columns written for each record, in CopyFrom order
+var logRecordColumns = []string{
+	"file_id", "user_id", "auction_id", "account_id", "campaign_id", "creative_id",
+	"bid_time", "impression_time", "bid_price_micros", "clearing_price_micros", "win_cost_micros",
+	"clicks", "conversions", "domain", "geo_country", "geo_region", "geo_city",
+	"device_type", "browser", "os", "ad_position", "viewer_id", "exchange", "seller_id",
+	"revenue_micros",
+}
+
+// PostgresLogRecordRepository stores parsed log records in a table partitioned by month of bid time
+type PostgresLogRecordRepository struct {
+	db DBTX
+}
+
+// NewPostgresLogRecordRepository creates a new PostgreSQL log record repository
+func NewPostgresLogRecordRepository(db DBTX) *PostgresLogRecordRepository {
+	return &PostgresLogRecordRepository{
+		db: db,
+	}
+}
+
+// DeleteRecords removes every stored record of a file
+func (r *PostgresLogRecordRepository) DeleteRecords(ctx context.Context, fileID, userID string) error {
+	query := `
+		DELETE FROM log_records
+		WHERE file_id = $1 AND user_id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, fileID, userID)
+	return err
+}
+
+// WriteRecords bulk-loads a batch of a file's records, creating any missing monthly partitions first.
+// Records without a bid time cannot be placed in a partition and are skipped.
+func (r *PostgresLogRecordRepository) WriteRecords(ctx context.Context, fileID, userID string, records []ingestion.BeeswaxLogRecord) error {
+	rows := make([][]interface{}, 0, len(records))
+	months := make(map[time.Time]bool)
+	for i := range records {
+		record := &records[i]
+		if record.BidTime.IsZero() {
+			continue
+		}
+
+		months[monthStart(record.BidTime)] = true
+		rows = append(rows, []interface{}{
+			fileID, userID, record.AuctionID, record.AccountID, record.CampaignID, record.CreativeID,
+			record.BidTime, nullableTime(record.ImpressionTime), record.BidPriceMicrosUSD,
+			record.ClearingPriceMicrosUSD, record.WinCostMicrosUSD, record.Clicks, record.Conversions,
+			record.Domain, record.GeoCountry, record.GeoRegion, record.GeoCity, record.PlatformDeviceType,
+			record.PlatformBrowser, record.PlatformOS, record.AdPosition, record.UserID, record.Exchange,
+			record.SellerID, record.RevenueMicrosUSD,
+		})
+	}
+
+	for month := range months {
+		if err := r.ensurePartition(ctx, month); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"log_records"}, logRecordColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy log records: %w", err)
+	}
+
+	return nil
+}
+
+// ScanJourneyRecords calls fn with a campaign's delivered records that have a viewer, ordered by
+// viewer and bid time, filling only the fields a user journey needs
+func (r *PostgresLogRecordRepository) ScanJourneyRecords(ctx context.Context, fileID, userID, campaignID string, fn func(*ingestion.BeeswaxLogRecord) error) error {
+	query := `
+		SELECT viewer_id, bid_time, impression_time, clicks, conversions
+		FROM log_records
+		WHERE file_id = $1 AND user_id = $2 AND campaign_id = $3 AND viewer_id <> ''
+			AND (impression_time IS NOT NULL OR clicks > 0 OR conversions > 0)
+		ORDER BY viewer_id, bid_time
+	`
+
+	rows, err := r.db.Query(ctx, query, fileID, userID, campaignID)
+	if err != nil {
+		return fmt.Errorf("failed to query journey records: %w", err)
+	}
+	defer rows.Close()
+
+	record := ingestion.BeeswaxLogRecord{CampaignID: campaignID}
+	for rows.Next() {
+		var impressionTime *time.Time
+		if err := rows.Scan(&record.UserID, &record.BidTime, &impressionTime, &record.Clicks, &record.Conversions); err != nil {
+			return fmt.Errorf("failed to scan journey record: %w", err)
+		}
+		record.ImpressionTime = time.Time{}
+		if impressionTime != nil {
+			record.ImpressionTime = *impressionTime
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// EnsurePartitions creates the monthly partitions covering from through to
+func (r *PostgresLogRecordRepository) EnsurePartitions(ctx context.Context, from, to time.Time) error {
+	for month := monthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		if err := r.ensurePartition(ctx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropPartitionsBefore drops the partitions whose whole month is before the cutoff and returns their names.
+// Dropping a partition is how records expire, which is far cheaper than deleting rows.
+func (r *PostgresLogRecordRepository) DropPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	query := `
+		SELECT child.relname
+		FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE parent.relname = 'log_records'
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var expired []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+
+		month, ok := parsePartitionMonth(name)
+		if ok && !month.AddDate(0, 1, 0).After(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	for _, name := range expired {
+		if _, err := r.db.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			return nil, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		knownPartitions.Delete(name)
+	}
+
+	return expired, nil
+}
+
+// ensurePartition creates the partition for a month if it doesn't exist
+func (r *PostgresLogRecordRepository) ensurePartition(ctx context.Context, month time.Time) error {
+	name := partitionName(month)
+	if _, ok := knownPartitions.Load(name); ok {
+		return nil
+	}
+
+	statement := fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF log_records FOR VALUES FROM ('%s') TO ('%s')",
+		pgx.Identifier{name}.Sanitize(),
+		month.Format(time.RFC3339),
+		month.AddDate(0, 1, 0).Format(time.RFC3339),
+	)
+	if _, err := r.db.Exec(ctx, statement); err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+
+	knownPartitions.Store(name, true)
+	return nil
+}
+
+// monthStart returns the first instant of the UTC month containing t
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName returns the name of the partition holding a month
+func partitionName(month time.Time) string {
+	return fmt.Sprintf("%s%04dm%02d", logRecordPartitionPrefix, month.Year(), int(month.Month()))
+}
+
+// parsePartitionMonth extracts the month from a partition name
+func parsePartitionMonth(name string) (time.Time, bool) {
+	var year, month int
+	if _, err := fmt.Sscanf(name, logRecordPartitionPrefix+"%04dm%02d", &year, &month); err != nil {
+		return time.Time{}, false
+	}
+	if month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// nullableTime maps the zero time to NULL
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresLogStreamRepository stores users' log streams
+type PostgresLogStreamRepository struct {
+	db DBTX
+}
+
+// NewPostgresLogStreamRepository creates a new PostgreSQL log stream repository
+func NewPostgresLogStreamRepository(db DBTX) *PostgresLogStreamRepository {
+	return &PostgresLogStreamRepository{
+		db: db,
+	}
+}
+
+// logStreamColumns lists the columns selected for a log stream, in scan order
+const logStreamColumns = `id, user_id, name, proxy_url, username, password, topic, group_id, source, status, last_error,
+	records_consumed, records_rejected, last_consumed_at, created_at, updated_at`
+
+// Create stores a new log stream, returning ErrDuplicate when the user already streams the
+// topic under the same consumer group
+func (r *PostgresLogStreamRepository) Create(ctx context.Context, stream *models.LogStream) error {
+	query := `
+		INSERT INTO log_streams (` + logStreamColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		stream.ID,
+		stream.UserID,
+		stream.Name,
+		stream.ProxyURL,
+		stream.Username,
+		stream.Password,
+		stream.Topic,
+		stream.GroupID,
+		stream.Source,
+		stream.Status,
+		stream.LastError,
+		stream.RecordsConsumed,
+		stream.RecordsRejected,
+		stream.LastConsumedAt,
+		stream.CreatedAt,
+		stream.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// FindByID finds a user's log stream by ID
+func (r *PostgresLogStreamRepository) FindByID(ctx context.Context, id, userID string) (*models.LogStream, error) {
+	query := `
+		SELECT ` + logStreamColumns + `
+		FROM log_streams
+		WHERE id = $1 AND user_id = $2
+	`
+
+	stream, err := scanLogStream(r.db.QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// ListByUser lists a user's log streams, oldest first
+func (r *PostgresLogStreamRepository) ListByUser(ctx context.Context, userID string) ([]*models.LogStream, error) {
+	query := `
+		SELECT ` + logStreamColumns + `
+		FROM log_streams
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	return r.list(ctx, query, userID)
+}
+
+// ListActive lists the log streams of every user that aren't paused, oldest first. Streams in
+// error are included so they are retried.
+func (r *PostgresLogStreamRepository) ListActive(ctx context.Context) ([]*models.LogStream, error) {
+	query := `
+		SELECT ` + logStreamColumns + `
+		FROM log_streams
+		WHERE status <> $1
+		ORDER BY created_at
+	`
+
+	return r.list(ctx, query, models.LogStreamStatusPaused)
+}
+
+// list runs a query selecting logStreamColumns
+func (r *PostgresLogStreamRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.LogStream, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	streams := []*models.LogStream{}
+	for rows.Next() {
+		stream, err := scanLogStream(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan log stream: %w", err)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, rows.Err()
+}
+
+// SetStatus pauses or resumes a user's log stream, clearing its last error
+func (r *PostgresLogStreamRepository) SetStatus(ctx context.Context, id, userID, status string) error {
+	query := `
+		UPDATE log_streams
+		SET status = $3, last_error = '', updated_at = $4
+		WHERE id = $1 AND user_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, status, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordProgress adds to a log stream's record counts and marks it active, unless it was paused
+// while the records were consumed
+func (r *PostgresLogStreamRepository) RecordProgress(ctx context.Context, id string, consumed, rejected int64, consumedAt time.Time) error {
+	query := `
+		UPDATE log_streams
+		SET records_consumed = records_consumed + $2,
+			records_rejected = records_rejected + $3,
+			last_consumed_at = $4,
+			status = CASE WHEN status = $5 THEN status ELSE $6 END,
+			last_error = '',
+			updated_at = $4
+		WHERE id = $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, consumed, rejected, consumedAt, models.LogStreamStatusPaused, models.LogStreamStatusActive)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordError marks a log stream as failing, unless it was paused in the meantime
+func (r *PostgresLogStreamRepository) RecordError(ctx context.Context, id, lastError string, failedAt time.Time) error {
+	query := `
+		UPDATE log_streams
+		SET status = $3, last_error = $2, updated_at = $4
+		WHERE id = $1 AND status <> $5
+	`
+
+	_, err := r.db.Exec(ctx, query, id, lastError, models.LogStreamStatusError, failedAt, models.LogStreamStatusPaused)
+	return err
+}
+
+// Delete removes a user's log stream. The rollups it already streamed stay, with their segment files.
+func (r *PostgresLogStreamRepository) Delete(ctx context.Context, id, userID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM log_streams WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanLogStream scans a single log stream row
+func scanLogStream(row pgx.Row) (*models.LogStream, error) {
+	stream := &models.LogStream{}
+	err := row.Scan(
+		&stream.ID,
+		&stream.UserID,
+		&stream.Name,
+		&stream.ProxyURL,
+		&stream.Username,
+		&stream.Password,
+		&stream.Topic,
+		&stream.GroupID,
+		&stream.Source,
+		&stream.Status,
+		&stream.LastError,
+		&stream.RecordsConsumed,
+		&stream.RecordsRejected,
+		&stream.LastConsumedAt,
+		&stream.CreatedAt,
+		&stream.UpdatedAt,
+	)
+
+	return stream, err
+}
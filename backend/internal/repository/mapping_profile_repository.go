@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresMappingProfileRepository stores users' column mapping profiles
+type PostgresMappingProfileRepository struct {
+	db DBTX
+}
+
+// NewPostgresMappingProfileRepository creates a new PostgreSQL mapping profile repository
+func NewPostgresMappingProfileRepository(db DBTX) *PostgresMappingProfileRepository {
+	return &PostgresMappingProfileRepository{
+		db: db,
+	}
+}
+
+// ListProfiles returns a user's mapping profiles by source
+func (r *PostgresMappingProfileRepository) ListProfiles(ctx context.Context, userID string) (map[string]*ingestion.MappingProfile, error) {
+	query := `
+		SELECT source, columns, auto_extend, updated_at
+		FROM mapping_profiles
+		WHERE user_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]*ingestion.MappingProfile)
+	for rows.Next() {
+		profile := &ingestion.MappingProfile{}
+		if err := rows.Scan(&profile.Source, &profile.Columns, &profile.AutoExtend, &profile.UpdatedAt); err != nil {
+			return nil, err
+		}
+		profiles[profile.Source] = profile
+	}
+
+	return profiles, rows.Err()
+}
+
+// SetProfile creates or replaces a user's mapping profile for a source
+func (r *PostgresMappingProfileRepository) SetProfile(ctx context.Context, userID string, profile *ingestion.MappingProfile) error {
+	query := `
+		INSERT INTO mapping_profiles (user_id, source, columns, auto_extend, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, source) DO UPDATE
+		SET columns = EXCLUDED.columns,
+			auto_extend = EXCLUDED.auto_extend,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, profile.Source, profile.Columns, profile.AutoExtend, profile.UpdatedAt)
+	return err
+}
+
+// ExtendProfile adds header names to a user's existing profile for a source, keeping the
+// canonical column of names already in it
+func (r *PostgresMappingProfileRepository) ExtendProfile(ctx context.Context, userID, source string, columns map[string]string) error {
+	query := `
+		UPDATE mapping_profiles
+		SET columns = $3::jsonb || columns,
+			updated_at = $4
+		WHERE user_id = $1 AND source = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, source, columns, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteProfile removes a user's mapping profile for a source
+func (r *PostgresMappingProfileRepository) DeleteProfile(ctx context.Context, userID, source string) error {
+	query := `
+		DELETE FROM mapping_profiles
+		WHERE user_id = $1 AND source = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, source)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// MemoryStore holds the records of the in-memory repositories, which stand in for PostgreSQL
+// when service-level behavior is exercised without a database, such as in integration tests
+// of upload, processing and analysis. The repositories follow the PostgreSQL ones' semantics:
+// the same orderings, ErrNotFound and ErrDuplicate cases, upserts and cascading deletes.
+//
+// Records are stored by value and copied in and out, so callers can't change them behind the
+// store's back.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data memoryData
+
+	// txMu runs units of work one at a time
+	txMu sync.Mutex
+}
+
+// pairKey identifies a record by two IDs, such as a user and a domain
+type pairKey struct {
+	first, second string
+}
+
+// memoryRecords are a file's log records
+type memoryRecords struct {
+	userID  string
+	records []ingestion.BeeswaxLogRecord
+}
+
+// memoryRollups are a file's rollups and when it was rolled up
+type memoryRollups struct {
+	userID     string
+	rolledUpAt time.Time
+	rollups    []ingestion.Rollup
+}
+
+// memoryBatch is an upload batch with the files accepted into it
+type memoryBatch struct {
+	batch models.UploadBatch
+	files map[int]models.BatchFile
+}
+
+// memoryAnnotation is a campaign annotation and the user who recorded it
+type memoryAnnotation struct {
+	userID     string
+	annotation ingestion.CampaignAnnotation
+}
+
+// memoryImportedRow is a row of an imported delivery report or invoice
+type memoryImportedRow[T any] struct {
+	fileID     string
+	userID     string
+	importedAt time.Time
+	date       time.Time
+	row        T
+}
+
+// performanceKey identifies a day of a campaign's performance pulled from an integration
+type performanceKey struct {
+	integrationID, campaignID string
+	date                      time.Time
+}
+
+// siteOutcomeKey identifies a day of a UTM source, medium and campaign's outcomes pulled from an integration
+type siteOutcomeKey struct {
+	integrationID            string
+	date                     time.Time
+	source, medium, campaign string
+}
+
+// rateKey identifies a currency's rate on a day
+type rateKey struct {
+	date     time.Time
+	currency string
+}
+
+// realtimeKey identifies a minute of a user's campaign in streamed logs
+type realtimeKey struct {
+	userID, campaignID string
+	minute             time.Time
+}
+
+// usageKey identifies an org's month of usage
+type usageKey struct {
+	orgID  string
+	period time.Time
+}
+
+// memoryData is every table of a memory store
+type memoryData struct {
+	users        map[string]models.User
+	orgs         map[string]models.Organization
+	files        map[string]models.File
+	jobs         map[string]models.ProcessingJob
+	deadLetters  map[string]models.DeadLetterJob
+	idempotency  map[pairKey]models.IdempotencyKey
+	datasets     map[string]models.Dataset
+	datasetFiles map[pairKey]time.Time
+	integrations map[string]models.Integration
+	streams      map[string]models.LogStream
+	exports      map[string]models.ExportDestination
+	tableSyncs   map[pairKey]models.ExportTableSync
+	performance  map[performanceKey]models.CampaignPerformance
+	siteOutcomes map[siteOutcomeKey]models.SiteOutcome
+	delivery     []memoryImportedRow[ingestion.DeliveryReportRow]
+	invoices     []memoryImportedRow[ingestion.InvoiceRow]
+	categories   map[pairKey]string
+	mappings     map[pairKey]ingestion.MappingProfile
+	schemas      map[string]ingestion.FileSchema
+	brandSafety  map[string]models.BrandSafetyList
+	templates    map[string]models.ReportTemplate
+	embeds       map[string]models.Embed
+	apiKeys      map[string]models.APIKey
+	shares       map[string]models.DataShare
+	usage        map[usageKey]models.OrgUsage
+	incidents    map[string]models.Incident
+	parserRuns   []ingestion.ParserRun
+	logRecords   map[string]memoryRecords
+	rollups      map[string]memoryRollups
+	realtime     map[realtimeKey]ingestion.CampaignMetrics
+	sessions     map[string]models.Session
+	preferences  map[string]models.Preferences
+	digests      map[pairKey]time.Time
+	batches      map[string]memoryBatch
+	metrics      map[pairKey]models.CustomMetric
+	goals        map[pairKey]ingestion.CampaignGoal
+	annotations  map[string]memoryAnnotation
+	experiments  map[string]models.Experiment
+	rates        map[rateKey]float64
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: memoryData{
+			users:        make(map[string]models.User),
+			orgs:         make(map[string]models.Organization),
+			files:        make(map[string]models.File),
+			jobs:         make(map[string]models.ProcessingJob),
+			deadLetters:  make(map[string]models.DeadLetterJob),
+			idempotency:  make(map[pairKey]models.IdempotencyKey),
+			datasets:     make(map[string]models.Dataset),
+			datasetFiles: make(map[pairKey]time.Time),
+			integrations: make(map[string]models.Integration),
+			streams:      make(map[string]models.LogStream),
+			exports:      make(map[string]models.ExportDestination),
+			tableSyncs:   make(map[pairKey]models.ExportTableSync),
+			performance:  make(map[performanceKey]models.CampaignPerformance),
+			siteOutcomes: make(map[siteOutcomeKey]models.SiteOutcome),
+			categories:   make(map[pairKey]string),
+			mappings:     make(map[pairKey]ingestion.MappingProfile),
+			schemas:      make(map[string]ingestion.FileSchema),
+			brandSafety:  make(map[string]models.BrandSafetyList),
+			templates:    make(map[string]models.ReportTemplate),
+			embeds:       make(map[string]models.Embed),
+			apiKeys:      make(map[string]models.APIKey),
+			shares:       make(map[string]models.DataShare),
+			usage:        make(map[usageKey]models.OrgUsage),
+			incidents:    make(map[string]models.Incident),
+			logRecords:   make(map[string]memoryRecords),
+			rollups:      make(map[string]memoryRollups),
+			realtime:     make(map[realtimeKey]ingestion.CampaignMetrics),
+			sessions:     make(map[string]models.Session),
+			preferences:  make(map[string]models.Preferences),
+			digests:      make(map[pairKey]time.Time),
+			batches:      make(map[string]memoryBatch),
+			metrics:      make(map[pairKey]models.CustomMetric),
+			goals:        make(map[pairKey]ingestion.CampaignGoal),
+			annotations:  make(map[string]memoryAnnotation),
+			experiments:  make(map[string]models.Experiment),
+			rates:        make(map[rateKey]float64),
+		},
+	}
+}
+
+// clone copies the tables. Records are never changed in place, so sharing the slices and maps
+// inside them is safe.
+func (d *memoryData) clone() memoryData {
+	batches := make(map[string]memoryBatch, len(d.batches))
+	for id, batch := range d.batches {
+		batches[id] = memoryBatch{batch: batch.batch, files: maps.Clone(batch.files)}
+	}
+
+	return memoryData{
+		users:        maps.Clone(d.users),
+		orgs:         maps.Clone(d.orgs),
+		files:        maps.Clone(d.files),
+		jobs:         maps.Clone(d.jobs),
+		deadLetters:  maps.Clone(d.deadLetters),
+		idempotency:  maps.Clone(d.idempotency),
+		datasets:     maps.Clone(d.datasets),
+		datasetFiles: maps.Clone(d.datasetFiles),
+		integrations: maps.Clone(d.integrations),
+		streams:      maps.Clone(d.streams),
+		exports:      maps.Clone(d.exports),
+		tableSyncs:   maps.Clone(d.tableSyncs),
+		performance:  maps.Clone(d.performance),
+		siteOutcomes: maps.Clone(d.siteOutcomes),
+		delivery:     slices.Clone(d.delivery),
+		invoices:     slices.Clone(d.invoices),
+		categories:   maps.Clone(d.categories),
+		mappings:     maps.Clone(d.mappings),
+		schemas:      maps.Clone(d.schemas),
+		brandSafety:  maps.Clone(d.brandSafety),
+		templates:    maps.Clone(d.templates),
+		embeds:       maps.Clone(d.embeds),
+		apiKeys:      maps.Clone(d.apiKeys),
+		shares:       maps.Clone(d.shares),
+		usage:        maps.Clone(d.usage),
+		incidents:    maps.Clone(d.incidents),
+		parserRuns:   slices.Clone(d.parserRuns),
+		logRecords:   maps.Clone(d.logRecords),
+		rollups:      maps.Clone(d.rollups),
+		realtime:     maps.Clone(d.realtime),
+		sessions:     maps.Clone(d.sessions),
+		preferences:  maps.Clone(d.preferences),
+		digests:      maps.Clone(d.digests),
+		batches:      batches,
+		metrics:      maps.Clone(d.metrics),
+		goals:        maps.Clone(d.goals),
+		annotations:  maps.Clone(d.annotations),
+		experiments:  maps.Clone(d.experiments),
+		rates:        maps.Clone(d.rates),
+	}
+}
+
+// deleteFile removes a file along with the rows that reference it, as the foreign keys'
+// ON DELETE CASCADE does in PostgreSQL. Log records aren't constrained and stay.
+func (d *memoryData) deleteFile(fileID string) {
+	delete(d.files, fileID)
+	for id, job := range d.jobs {
+		if job.FileID == fileID {
+			delete(d.jobs, id)
+			delete(d.deadLetters, id)
+		}
+	}
+	for key := range d.datasetFiles {
+		if key.second == fileID {
+			delete(d.datasetFiles, key)
+		}
+	}
+	for id, batch := range d.batches {
+		for position, file := range batch.files {
+			if file.FileID == fileID {
+				delete(d.batches[id].files, position)
+			}
+		}
+	}
+	d.delivery = slices.DeleteFunc(d.delivery, func(row memoryImportedRow[ingestion.DeliveryReportRow]) bool {
+		return row.fileID == fileID
+	})
+	d.invoices = slices.DeleteFunc(d.invoices, func(row memoryImportedRow[ingestion.InvoiceRow]) bool {
+		return row.fileID == fileID
+	})
+	delete(d.schemas, fileID)
+	delete(d.rollups, fileID)
+	for id, embed := range d.embeds {
+		if embed.FileID == fileID {
+			delete(d.embeds, id)
+		}
+	}
+}
+
+// NewMemoryRepositories creates in-memory repositories backed by the given store
+func NewMemoryRepositories(store *MemoryStore) Repositories {
+	return Repositories{
+		Users:        &MemoryUserRepository{store: store},
+		Orgs:         &MemoryOrganizationRepository{store: store},
+		Files:        &MemoryFileRepository{store: store},
+		Jobs:         &MemoryJobRepository{store: store},
+		DeadLetters:  &MemoryDeadLetterRepository{store: store},
+		LogRecords:   &MemoryLogRecordRepository{store: store},
+		Idempotency:  &MemoryIdempotencyRepository{store: store},
+		Datasets:     &MemoryDatasetRepository{store: store},
+		Integrations: &MemoryIntegrationRepository{store: store},
+		Streams:      &MemoryLogStreamRepository{store: store},
+		Delivery:     &MemoryDeliveryReportRepository{store: store},
+		Categories:   &MemoryCategoryOverrideRepository{store: store},
+		Mappings:     &MemoryMappingProfileRepository{store: store},
+		Schemas:      &MemoryFileSchemaRepository{store: store},
+		BrandSafety:  &MemoryBrandSafetyRepository{store: store},
+		Rollups:      &MemoryRollupRepository{store: store},
+		Realtime:     &MemoryRealtimeRepository{store: store},
+		Exports:      &MemoryExportRepository{store: store},
+		Sessions:     &MemorySessionRepository{store: store},
+		Preferences:  &MemoryPreferencesRepository{store: store},
+		Digests:      &MemoryDigestRepository{store: store},
+		Batches:      &MemoryBatchRepository{store: store},
+		Metrics:      &MemoryCustomMetricRepository{store: store},
+		Goals:        &MemoryCampaignGoalRepository{store: store},
+		Annotations:  &MemoryCampaignAnnotationRepository{store: store},
+		Experiments:  &MemoryExperimentRepository{store: store},
+		Rates:        &MemoryExchangeRateRepository{store: store},
+		Invoices:     &MemoryInvoiceRepository{store: store},
+		Templates:    &MemoryReportTemplateRepository{store: store},
+		Embeds:       &MemoryEmbedRepository{store: store},
+		APIKeys:      &MemoryAPIKeyRepository{store: store},
+		Shares:       &MemoryDataShareRepository{store: store},
+		Usage:        &MemoryUsageRepository{store: store},
+		Incidents:    &MemoryIncidentRepository{store: store},
+		ParserRuns:   &MemoryParserRunRepository{store: store},
+	}
+}
+
+// MemoryUnitOfWork runs units of work against a memory store
+type MemoryUnitOfWork struct {
+	store *MemoryStore
+}
+
+// NewMemoryUnitOfWork creates a unit of work backed by a memory store
+func NewMemoryUnitOfWork(store *MemoryStore) *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{
+		store: store,
+	}
+}
+
+// WithinTx runs fn, one unit of work at a time, restoring the store as it was before fn on
+// error or panic. Writes made outside the unit of work while it runs are rolled back with it.
+func (u *MemoryUnitOfWork) WithinTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) (err error) {
+	u.store.txMu.Lock()
+	defer u.store.txMu.Unlock()
+
+	u.store.mu.Lock()
+	snapshot := u.store.data.clone()
+	u.store.mu.Unlock()
+
+	rollback := func() {
+		u.store.mu.Lock()
+		u.store.data = snapshot
+		u.store.mu.Unlock()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+		if err != nil {
+			rollback()
+		}
+	}()
+
+	return fn(ctx, NewMemoryRepositories(u.store))
+}
+
+// sortedValues returns the values of a map that keep accepts, ordered by less
+func sortedValues[K comparable, V any](m map[K]V, keep func(V) bool, less func(a, b V) int) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		if keep(v) {
+			values = append(values, v)
+		}
+	}
+	slices.SortFunc(values, less)
+	return values
+}
+
+// pointers returns pointers to copies of values
+func pointers[V any](values []V) []*V {
+	out := make([]*V, len(values))
+	for i := range values {
+		v := values[i]
+		out[i] = &v
+	}
+	return out
+}
+
+var _ UnitOfWork = (*MemoryUnitOfWork)(nil)
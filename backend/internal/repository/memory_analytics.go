@@ -0,0 +1,954 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// MemoryRollupRepository stores file rollups in a memory store
+type MemoryRollupRepository struct {
+	store *MemoryStore
+}
+
+// DeleteRollups removes a file's rollups and the record that it was rolled up
+func (r *MemoryRollupRepository) DeleteRollups(ctx context.Context, fileID, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if stored, ok := r.store.data.rollups[fileID]; ok && stored.userID == userID {
+		delete(r.store.data.rollups, fileID)
+	}
+	return nil
+}
+
+// InsertRollups stores a file's rollups and records that the file was rolled up
+func (r *MemoryRollupRepository) InsertRollups(ctx context.Context, fileID, userID string, rolledUpAt time.Time, rollups []ingestion.Rollup) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.files[fileID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := r.store.data.rollups[fileID]; ok {
+		return ErrDuplicate
+	}
+
+	stored := make([]ingestion.Rollup, len(rollups))
+	for i, rollup := range rollups {
+		stored[i] = ingestion.Rollup{
+			Grain:     rollup.Grain,
+			Dimension: rollup.Dimension,
+			Value:     rollup.Value,
+			Bucket:    rollup.Bucket,
+			CampaignMetrics: ingestion.CampaignMetrics{
+				Bids:                  rollup.Bids,
+				Impressions:           rollup.Impressions,
+				Clicks:                rollup.Clicks,
+				Conversions:           rollup.Conversions,
+				Spend:                 rollup.Spend,
+				Revenue:               rollup.Revenue,
+				MeasurableImpressions: rollup.MeasurableImpressions,
+				ViewableImpressions:   rollup.ViewableImpressions,
+			},
+		}
+	}
+	r.store.data.rollups[fileID] = memoryRollups{userID: userID, rolledUpAt: rolledUpAt, rollups: stored}
+	return nil
+}
+
+// CountPendingFiles counts a user's processed files that haven't been rolled up
+func (r *MemoryRollupRepository) CountPendingFiles(ctx context.Context, userID string) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	count := 0
+	for id, file := range r.store.data.files {
+		if _, ok := r.store.data.rollups[id]; !ok && file.UserID == userID && file.Status == models.FileStatusProcessed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ScanRollups calls fn with a user's rollups summed across processed files by value and
+// bucket, ordered by bucket and value and capped at the query's limit
+func (r *MemoryRollupRepository) ScanRollups(ctx context.Context, query ingestion.RollupQuery, fn func(ingestion.Rollup) error) error {
+	type bucketKey struct {
+		bucket time.Time
+		value  string
+	}
+
+	r.store.mu.Lock()
+	sums := make(map[bucketKey]*ingestion.Rollup)
+	for fileID, stored := range r.store.data.rollups {
+		if stored.userID != query.UserID || r.store.data.files[fileID].Status != models.FileStatusProcessed {
+			continue
+		}
+		for _, rollup := range stored.rollups {
+			if rollup.Dimension != query.Dimension || rollup.Grain != query.Grain ||
+				(query.Value != "" && rollup.Value != query.Value) ||
+				(query.From != nil && rollup.Bucket.Before(*query.From)) ||
+				(query.To != nil && !rollup.Bucket.Before(*query.To)) {
+				continue
+			}
+			if query.AfterBucket != nil {
+				if c := cmp.Or(rollup.Bucket.Compare(*query.AfterBucket), cmp.Compare(rollup.Value, query.AfterValue)); c <= 0 {
+					continue
+				}
+			}
+
+			key := bucketKey{rollup.Bucket.UTC(), rollup.Value}
+			sum, ok := sums[key]
+			if !ok {
+				sum = &ingestion.Rollup{Grain: query.Grain, Dimension: query.Dimension, Value: rollup.Value, Bucket: rollup.Bucket}
+				sums[key] = sum
+			}
+			addRollup(sum, rollup)
+			if !slices.Contains(sum.FileIDs, fileID) {
+				sum.FileIDs = append(sum.FileIDs, fileID)
+			}
+		}
+	}
+	r.store.mu.Unlock()
+
+	keys := slices.SortedFunc(maps.Keys(sums), func(a, b bucketKey) int {
+		return cmp.Or(a.bucket.Compare(b.bucket), cmp.Compare(a.value, b.value))
+	})
+	for i, key := range keys {
+		if i == query.Limit {
+			break
+		}
+		rollup := *sums[key]
+		slices.Sort(rollup.FileIDs)
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanFileRollups calls fn with every rollup of a user's file, ordered by grain, dimension,
+// bucket and value
+func (r *MemoryRollupRepository) ScanFileRollups(ctx context.Context, fileID, userID string, fn func(ingestion.Rollup) error) error {
+	rollups := r.fileRollups(fileID, userID, func(ingestion.Rollup) bool { return true })
+	slices.SortFunc(rollups, func(a, b ingestion.Rollup) int {
+		return cmp.Or(
+			cmp.Compare(a.Grain, b.Grain),
+			cmp.Compare(a.Dimension, b.Dimension),
+			a.Bucket.Compare(b.Bucket),
+			cmp.Compare(a.Value, b.Value),
+		)
+	})
+
+	for _, rollup := range rollups {
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasRollups reports whether a user's file has been rolled up
+func (r *MemoryRollupRepository) HasRollups(ctx context.Context, fileID, userID string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.rollups[fileID]
+	return ok && stored.userID == userID, nil
+}
+
+// ListRolledUpSince lists the IDs of a user's processed files rolled up at or after since
+func (r *MemoryRollupRepository) ListRolledUpSince(ctx context.Context, userID string, since time.Time) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	type rolledUp struct {
+		fileID string
+		at     time.Time
+	}
+	var files []rolledUp
+	for fileID, stored := range r.store.data.rollups {
+		file, ok := r.store.data.files[fileID]
+		if stored.userID == userID && !stored.rolledUpAt.Before(since) && ok && file.Status == models.FileStatusProcessed {
+			files = append(files, rolledUp{fileID, stored.rolledUpAt})
+		}
+	}
+	slices.SortFunc(files, func(a, b rolledUp) int {
+		return cmp.Or(a.at.Compare(b.at), cmp.Compare(a.fileID, b.fileID))
+	})
+
+	fileIDs := make([]string, len(files))
+	for i, file := range files {
+		fileIDs[i] = file.fileID
+	}
+	return fileIDs, nil
+}
+
+// ScanFileBreakdown calls fn with a file's daily rollups of a dimension summed by value, ordered
+// by spend, highest first, then by value and capped at the query's limit. Spend is rounded to
+// micros, so the order pages resume from is stable.
+func (r *MemoryRollupRepository) ScanFileBreakdown(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error {
+	sums := make(map[string]*ingestion.Rollup)
+	for _, rollup := range r.fileRollups(query.FileID, query.UserID, func(rollup ingestion.Rollup) bool {
+		return rollup.Dimension == query.Dimension && rollup.Grain == ingestion.RollupDaily
+	}) {
+		sum, ok := sums[rollup.Value]
+		if !ok {
+			sum = &ingestion.Rollup{Grain: ingestion.RollupDaily, Dimension: query.Dimension, Value: rollup.Value}
+			sums[rollup.Value] = sum
+		}
+		addRollup(sum, rollup)
+	}
+
+	breakdown := make([]ingestion.Rollup, 0, len(sums))
+	for _, sum := range sums {
+		sum.Spend = math.Round(sum.Spend*1e6) / 1e6
+		if query.AfterSpend != nil && (sum.Spend > *query.AfterSpend || (sum.Spend == *query.AfterSpend && sum.Value <= query.AfterValue)) {
+			continue
+		}
+		breakdown = append(breakdown, *sum)
+	}
+	slices.SortFunc(breakdown, func(a, b ingestion.Rollup) int {
+		return cmp.Or(cmp.Compare(b.Spend, a.Spend), cmp.Compare(a.Value, b.Value))
+	})
+
+	for i, rollup := range breakdown {
+		if i == query.Limit {
+			break
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanFileHours calls fn with a file's hourly total rollups in hour order, capped at the query's limit
+func (r *MemoryRollupRepository) ScanFileHours(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error {
+	hours := r.fileRollups(query.FileID, query.UserID, func(rollup ingestion.Rollup) bool {
+		return rollup.Dimension == ingestion.RollupTotal && rollup.Grain == ingestion.RollupHourly &&
+			rollup.Value == ingestion.RollupTotalValue &&
+			(query.AfterBucket == nil || rollup.Bucket.After(*query.AfterBucket))
+	})
+	slices.SortFunc(hours, func(a, b ingestion.Rollup) int { return a.Bucket.Compare(b.Bucket) })
+
+	for i, rollup := range hours {
+		if i == query.Limit {
+			break
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileRollups copies the rollups of a user's file that keep accepts
+func (r *MemoryRollupRepository) fileRollups(fileID, userID string, keep func(ingestion.Rollup) bool) []ingestion.Rollup {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.rollups[fileID]
+	if !ok || stored.userID != userID {
+		return nil
+	}
+	return slices.DeleteFunc(slices.Clone(stored.rollups), func(rollup ingestion.Rollup) bool { return !keep(rollup) })
+}
+
+// addRollup adds a rollup's counts to a sum
+func addRollup(sum *ingestion.Rollup, rollup ingestion.Rollup) {
+	sum.Bids += rollup.Bids
+	sum.Impressions += rollup.Impressions
+	sum.Clicks += rollup.Clicks
+	sum.Conversions += rollup.Conversions
+	sum.Spend += rollup.Spend
+	sum.Revenue += rollup.Revenue
+	sum.MeasurableImpressions += rollup.MeasurableImpressions
+	sum.ViewableImpressions += rollup.ViewableImpressions
+}
+
+// MemoryDeliveryReportRepository stores delivery report rows in a memory store
+type MemoryDeliveryReportRepository struct {
+	store *MemoryStore
+}
+
+// DeleteRows removes the rows imported from a file
+func (r *MemoryDeliveryReportRepository) DeleteRows(ctx context.Context, fileID, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.delivery = deleteImportedRows(r.store.data.delivery, fileID, userID)
+	return nil
+}
+
+// InsertRows stores the rows of a file's delivery report
+func (r *MemoryDeliveryReportRepository) InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.DeliveryReportRow) error {
+	imported, err := importRows(fileID, userID, importedAt, rows, "delivery", func(row ingestion.DeliveryReportRow) string { return row.Date })
+	if err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.delivery = append(slices.Clip(r.store.data.delivery), imported...)
+	return nil
+}
+
+// ListRows lists a user's delivery between from and to (inclusive). When several reports cover
+// the same day, order, line item and ad unit, the most recently imported one is used.
+func (r *MemoryDeliveryReportRepository) ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.DeliveryReportRow, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return latestImportedRows(r.store.data.delivery, userID, from, to, func(a, b ingestion.DeliveryReportRow) int {
+		return cmp.Or(
+			cmp.Compare(a.Date, b.Date),
+			cmp.Compare(a.OrderID, b.OrderID),
+			cmp.Compare(a.OrderName, b.OrderName),
+			cmp.Compare(a.LineItemID, b.LineItemID),
+			cmp.Compare(a.LineItemName, b.LineItemName),
+			cmp.Compare(a.AdUnit, b.AdUnit),
+		)
+	}), nil
+}
+
+// MemoryInvoiceRepository stores invoice rows in a memory store
+type MemoryInvoiceRepository struct {
+	store *MemoryStore
+}
+
+// DeleteRows removes the rows imported from a file
+func (r *MemoryInvoiceRepository) DeleteRows(ctx context.Context, fileID, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.invoices = deleteImportedRows(r.store.data.invoices, fileID, userID)
+	return nil
+}
+
+// InsertRows stores the rows of a file's invoice
+func (r *MemoryInvoiceRepository) InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.InvoiceRow) error {
+	imported, err := importRows(fileID, userID, importedAt, rows, "invoice", func(row ingestion.InvoiceRow) string { return row.Date })
+	if err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.invoices = append(slices.Clip(r.store.data.invoices), imported...)
+	return nil
+}
+
+// ListRows lists a user's invoiced spend between from and to (inclusive). When several invoices
+// cover the same day and campaign, the most recently imported one is used.
+func (r *MemoryInvoiceRepository) ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.InvoiceRow, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return latestImportedRows(r.store.data.invoices, userID, from, to, func(a, b ingestion.InvoiceRow) int {
+		return cmp.Or(
+			cmp.Compare(a.Date, b.Date),
+			cmp.Compare(a.CampaignID, b.CampaignID),
+			cmp.Compare(a.CampaignName, b.CampaignName),
+		)
+	}), nil
+}
+
+// importRows parses the dates of a file's imported rows, rejecting the rows as PostgreSQL does when one is invalid
+func importRows[T any](fileID, userID string, importedAt time.Time, rows []T, kind string, date func(T) string) ([]memoryImportedRow[T], error) {
+	imported := make([]memoryImportedRow[T], len(rows))
+	for i, row := range rows {
+		day, err := time.Parse("2006-01-02", date(row))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date %q: %w", kind, date(row), err)
+		}
+		imported[i] = memoryImportedRow[T]{fileID: fileID, userID: userID, importedAt: importedAt, date: day, row: row}
+	}
+	return imported, nil
+}
+
+// deleteImportedRows returns the rows that weren't imported from a user's file, without changing rows
+func deleteImportedRows[T any](rows []memoryImportedRow[T], fileID, userID string) []memoryImportedRow[T] {
+	kept := make([]memoryImportedRow[T], 0, len(rows))
+	for _, row := range rows {
+		if row.fileID != fileID || row.userID != userID {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// latestImportedRows lists a user's rows dated between from and to (inclusive), keeping the most
+// recently imported of the rows compare finds equal, in compare's order
+func latestImportedRows[T any](rows []memoryImportedRow[T], userID string, from, to time.Time, compare func(a, b T) int) []T {
+	matching := []memoryImportedRow[T]{}
+	for _, row := range rows {
+		if row.userID == userID && !row.date.Before(from) && !row.date.After(to) {
+			matching = append(matching, row)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b memoryImportedRow[T]) int {
+		return cmp.Or(compare(a.row, b.row), b.importedAt.Compare(a.importedAt))
+	})
+
+	latest := []T{}
+	for i, row := range matching {
+		if i == 0 || compare(matching[i-1].row, row.row) != 0 {
+			latest = append(latest, row.row)
+		}
+	}
+	return latest
+}
+
+// MemoryIntegrationRepository stores integrations and what was pulled from them in a memory store
+type MemoryIntegrationRepository struct {
+	store *MemoryStore
+}
+
+// Upsert creates an integration, or replaces the token and settings of the user's existing
+// connection to the same account and reactivates it. The integration's ID is set to the stored one's.
+func (r *MemoryIntegrationRepository) Upsert(ctx context.Context, integration *models.Integration) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, existing := range r.store.data.integrations {
+		if existing.UserID != integration.UserID || existing.Provider != integration.Provider || existing.AccountID != integration.AccountID {
+			continue
+		}
+		existing.Settings = integration.Settings
+		existing.RefreshToken = integration.RefreshToken
+		existing.Status = integration.Status
+		existing.LastError = integration.LastError
+		existing.UpdatedAt = integration.UpdatedAt
+		r.store.data.integrations[id] = existing
+
+		integration.ID = existing.ID
+		integration.CreatedAt = existing.CreatedAt
+		return nil
+	}
+
+	r.store.data.integrations[integration.ID] = *integration
+	return nil
+}
+
+// FindByID finds a user's integration by ID
+func (r *MemoryIntegrationRepository) FindByID(ctx context.Context, id, userID string) (*models.Integration, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integration, ok := r.store.data.integrations[id]
+	if !ok || integration.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &integration, nil
+}
+
+// ListByUser lists a user's integrations, oldest first
+func (r *MemoryIntegrationRepository) ListByUser(ctx context.Context, userID string) ([]*models.Integration, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integrations := sortedValues(r.store.data.integrations,
+		func(i models.Integration) bool { return i.UserID == userID },
+		func(a, b models.Integration) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	)
+	return pointers(integrations), nil
+}
+
+// ListDue lists integrations of every user not synced since the cutoff, least recently synced first
+func (r *MemoryIntegrationRepository) ListDue(ctx context.Context, cutoff time.Time) ([]*models.Integration, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integrations := sortedValues(r.store.data.integrations,
+		func(i models.Integration) bool { return i.LastSyncedAt == nil || i.LastSyncedAt.Before(cutoff) },
+		func(a, b models.Integration) int {
+			switch {
+			case a.LastSyncedAt == nil && b.LastSyncedAt == nil:
+				return 0
+			case a.LastSyncedAt == nil:
+				return -1
+			case b.LastSyncedAt == nil:
+				return 1
+			}
+			return a.LastSyncedAt.Compare(*b.LastSyncedAt)
+		},
+	)
+	return pointers(integrations), nil
+}
+
+// UpdateSyncStatus records the outcome of a sync attempt
+func (r *MemoryIntegrationRepository) UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integration, ok := r.store.data.integrations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	integration.Status = status
+	integration.LastError = lastError
+	integration.LastSyncedAt = &syncedAt
+	integration.UpdatedAt = syncedAt
+	r.store.data.integrations[id] = integration
+	return nil
+}
+
+// Delete removes a user's integration along with the performance and outcomes pulled from it
+func (r *MemoryIntegrationRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	integration, ok := r.store.data.integrations[id]
+	if !ok || integration.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.store.data.integrations, id)
+	maps.DeleteFunc(r.store.data.performance, func(key performanceKey, _ models.CampaignPerformance) bool {
+		return key.integrationID == id
+	})
+	maps.DeleteFunc(r.store.data.siteOutcomes, func(key siteOutcomeKey, _ models.SiteOutcome) bool {
+		return key.integrationID == id
+	})
+	return nil
+}
+
+// UpsertPerformance writes daily campaign performance, replacing rows already pulled for the
+// same integration, campaign and day
+func (r *MemoryIntegrationRepository) UpsertPerformance(ctx context.Context, rows []models.CampaignPerformance) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, row := range rows {
+		if _, ok := r.store.data.integrations[row.IntegrationID]; !ok {
+			return ErrNotFound
+		}
+	}
+	for _, row := range rows {
+		row.Date = memoryDate(row.Date)
+		r.store.data.performance[performanceKey{row.IntegrationID, row.CampaignID, row.Date}] = row
+	}
+	return nil
+}
+
+// ListPerformance lists a user's daily campaign performance between from and to (inclusive)
+func (r *MemoryIntegrationRepository) ListPerformance(ctx context.Context, userID string, from, to time.Time) ([]models.CampaignPerformance, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	from, to = memoryDate(from), memoryDate(to)
+	return sortedValues(r.store.data.performance,
+		func(p models.CampaignPerformance) bool {
+			return p.UserID == userID && !p.Date.Before(from) && !p.Date.After(to)
+		},
+		func(a, b models.CampaignPerformance) int {
+			return cmp.Or(a.Date.Compare(b.Date), cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.CampaignID, b.CampaignID))
+		},
+	), nil
+}
+
+// UpsertSiteOutcomes writes daily on-site outcomes, replacing rows already pulled for the same
+// integration, day and UTM source, medium and campaign
+func (r *MemoryIntegrationRepository) UpsertSiteOutcomes(ctx context.Context, rows []models.SiteOutcome) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, row := range rows {
+		if _, ok := r.store.data.integrations[row.IntegrationID]; !ok {
+			return ErrNotFound
+		}
+	}
+	for _, row := range rows {
+		row.Date = memoryDate(row.Date)
+		r.store.data.siteOutcomes[siteOutcomeKey{row.IntegrationID, row.Date, row.Source, row.Medium, row.Campaign}] = row
+	}
+	return nil
+}
+
+// ListSiteOutcomes lists a user's daily on-site outcomes between from and to (inclusive)
+func (r *MemoryIntegrationRepository) ListSiteOutcomes(ctx context.Context, userID string, from, to time.Time) ([]models.SiteOutcome, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	from, to = memoryDate(from), memoryDate(to)
+	return sortedValues(r.store.data.siteOutcomes,
+		func(o models.SiteOutcome) bool {
+			return o.UserID == userID && !o.Date.Before(from) && !o.Date.After(to)
+		},
+		func(a, b models.SiteOutcome) int {
+			return cmp.Or(a.Date.Compare(b.Date), cmp.Compare(a.Campaign, b.Campaign))
+		},
+	), nil
+}
+
+// MemoryLogStreamRepository stores log streams in a memory store
+type MemoryLogStreamRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a new log stream, returning ErrDuplicate when the user already streams the
+// topic under the same consumer group
+func (r *MemoryLogStreamRepository) Create(ctx context.Context, stream *models.LogStream) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.data.streams {
+		if existing.ID == stream.ID || (existing.UserID == stream.UserID && existing.Topic == stream.Topic && existing.GroupID == stream.GroupID) {
+			return ErrDuplicate
+		}
+	}
+	r.store.data.streams[stream.ID] = *stream
+	return nil
+}
+
+// FindByID finds a user's log stream by ID
+func (r *MemoryLogStreamRepository) FindByID(ctx context.Context, id, userID string) (*models.LogStream, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stream, ok := r.store.data.streams[id]
+	if !ok || stream.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &stream, nil
+}
+
+// ListByUser lists a user's log streams, oldest first
+func (r *MemoryLogStreamRepository) ListByUser(ctx context.Context, userID string) ([]*models.LogStream, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	streams := sortedValues(r.store.data.streams,
+		func(s models.LogStream) bool { return s.UserID == userID },
+		func(a, b models.LogStream) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	)
+	return pointers(streams), nil
+}
+
+// ListActive lists the log streams of every user that aren't paused, oldest first. Streams in
+// error are included so they are retried.
+func (r *MemoryLogStreamRepository) ListActive(ctx context.Context) ([]*models.LogStream, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	streams := sortedValues(r.store.data.streams,
+		func(s models.LogStream) bool { return s.Status != models.LogStreamStatusPaused },
+		func(a, b models.LogStream) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	)
+	return pointers(streams), nil
+}
+
+// SetStatus pauses or resumes a user's log stream, clearing its last error
+func (r *MemoryLogStreamRepository) SetStatus(ctx context.Context, id, userID, status string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stream, ok := r.store.data.streams[id]
+	if !ok || stream.UserID != userID {
+		return ErrNotFound
+	}
+	stream.Status = status
+	stream.LastError = ""
+	stream.UpdatedAt = time.Now()
+	r.store.data.streams[id] = stream
+	return nil
+}
+
+// RecordProgress adds to a log stream's record counts and marks it active, unless it was paused
+// while the records were consumed
+func (r *MemoryLogStreamRepository) RecordProgress(ctx context.Context, id string, consumed, rejected int64, consumedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stream, ok := r.store.data.streams[id]
+	if !ok {
+		return ErrNotFound
+	}
+	stream.RecordsConsumed += consumed
+	stream.RecordsRejected += rejected
+	stream.LastConsumedAt = &consumedAt
+	if stream.Status != models.LogStreamStatusPaused {
+		stream.Status = models.LogStreamStatusActive
+	}
+	stream.LastError = ""
+	stream.UpdatedAt = consumedAt
+	r.store.data.streams[id] = stream
+	return nil
+}
+
+// RecordError marks a log stream as failing, unless it was paused in the meantime
+func (r *MemoryLogStreamRepository) RecordError(ctx context.Context, id, lastError string, failedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stream, ok := r.store.data.streams[id]
+	if !ok || stream.Status == models.LogStreamStatusPaused {
+		return nil
+	}
+	stream.Status = models.LogStreamStatusError
+	stream.LastError = lastError
+	stream.UpdatedAt = failedAt
+	r.store.data.streams[id] = stream
+	return nil
+}
+
+// Delete removes a user's log stream. The rollups it already streamed stay, with their segment files.
+func (r *MemoryLogStreamRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stream, ok := r.store.data.streams[id]
+	if !ok || stream.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.store.data.streams, id)
+	return nil
+}
+
+// MemoryExportRepository stores export destinations and their table sync state in a memory store
+type MemoryExportRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a new export destination
+func (r *MemoryExportRepository) Create(ctx context.Context, destination *models.ExportDestination) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.exports[destination.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.exports[destination.ID] = *destination
+	return nil
+}
+
+// FindByID finds a user's export destination by ID
+func (r *MemoryExportRepository) FindByID(ctx context.Context, id, userID string) (*models.ExportDestination, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	destination, ok := r.store.data.exports[id]
+	if !ok || destination.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &destination, nil
+}
+
+// ListByUser lists a user's export destinations, oldest first
+func (r *MemoryExportRepository) ListByUser(ctx context.Context, userID string) ([]*models.ExportDestination, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	destinations := sortedValues(r.store.data.exports,
+		func(d models.ExportDestination) bool { return d.UserID == userID },
+		func(a, b models.ExportDestination) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	)
+	return pointers(destinations), nil
+}
+
+// ListDue lists export destinations of every user not synced since the cutoff, least recently synced first
+func (r *MemoryExportRepository) ListDue(ctx context.Context, cutoff time.Time) ([]*models.ExportDestination, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	destinations := sortedValues(r.store.data.exports,
+		func(d models.ExportDestination) bool { return d.LastSyncedAt == nil || d.LastSyncedAt.Before(cutoff) },
+		func(a, b models.ExportDestination) int {
+			switch {
+			case a.LastSyncedAt == nil && b.LastSyncedAt == nil:
+				return 0
+			case a.LastSyncedAt == nil:
+				return -1
+			case b.LastSyncedAt == nil:
+				return 1
+			}
+			return a.LastSyncedAt.Compare(*b.LastSyncedAt)
+		},
+	)
+	return pointers(destinations), nil
+}
+
+// UpdateSyncStatus records the outcome of a destination's sync
+func (r *MemoryExportRepository) UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	destination, ok := r.store.data.exports[id]
+	if !ok {
+		return ErrNotFound
+	}
+	destination.Status = status
+	destination.LastError = lastError
+	destination.LastSyncedAt = &syncedAt
+	destination.UpdatedAt = syncedAt
+	r.store.data.exports[id] = destination
+	return nil
+}
+
+// Delete removes a user's export destination along with its table sync state
+func (r *MemoryExportRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	destination, ok := r.store.data.exports[id]
+	if !ok || destination.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.store.data.exports, id)
+	for key := range r.store.data.tableSyncs {
+		if key.first == id {
+			delete(r.store.data.tableSyncs, key)
+		}
+	}
+	return nil
+}
+
+// ListTableSyncs lists the sync state of a destination's tables, by table name
+func (r *MemoryExportRepository) ListTableSyncs(ctx context.Context, destinationID string) ([]*models.ExportTableSync, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	syncs := sortedValues(r.store.data.tableSyncs,
+		func(s models.ExportTableSync) bool { return s.DestinationID == destinationID },
+		func(a, b models.ExportTableSync) int { return cmp.Compare(a.Table, b.Table) },
+	)
+	return pointers(syncs), nil
+}
+
+// SaveTableSync creates or replaces a table's sync state
+func (r *MemoryExportRepository) SaveTableSync(ctx context.Context, sync *models.ExportTableSync) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.tableSyncs[pairKey{sync.DestinationID, sync.Table}] = *sync
+	return nil
+}
+
+// MemoryRealtimeRepository stores the per-minute metrics of streamed logs in a memory store
+type MemoryRealtimeRepository struct {
+	store *MemoryStore
+}
+
+// AddMinutes adds metrics to a user's minutes
+func (r *MemoryRealtimeRepository) AddMinutes(ctx context.Context, userID string, minutes []ingestion.RealtimeMinute) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, minute := range minutes {
+		key := realtimeKey{userID: userID, campaignID: minute.CampaignID, minute: minute.Minute.UTC()}
+		metrics := r.store.data.realtime[key]
+		metrics.Bids += minute.Bids
+		metrics.Impressions += minute.Impressions
+		metrics.Clicks += minute.Clicks
+		metrics.Conversions += minute.Conversions
+		metrics.Spend += minute.Spend
+		metrics.Revenue += minute.Revenue
+		r.store.data.realtime[key] = metrics
+	}
+	return nil
+}
+
+// ListMinutes lists a user's minutes from since onwards, oldest first
+func (r *MemoryRealtimeRepository) ListMinutes(ctx context.Context, userID string, since time.Time) ([]ingestion.RealtimeMinute, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	minutes := []ingestion.RealtimeMinute{}
+	for key, metrics := range r.store.data.realtime {
+		if key.userID == userID && !key.minute.Before(since) {
+			minutes = append(minutes, ingestion.RealtimeMinute{CampaignID: key.campaignID, Minute: key.minute, CampaignMetrics: metrics})
+		}
+	}
+	slices.SortFunc(minutes, func(a, b ingestion.RealtimeMinute) int {
+		return cmp.Or(a.Minute.Compare(b.Minute), cmp.Compare(a.CampaignID, b.CampaignID))
+	})
+	return minutes, nil
+}
+
+// DeleteBefore removes minutes before the cutoff, returning how many were removed
+func (r *MemoryRealtimeRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for key := range r.store.data.realtime {
+		if key.minute.Before(cutoff) {
+			delete(r.store.data.realtime, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// MemoryExchangeRateRepository stores exchange rate snapshots in a memory store
+type MemoryExchangeRateRepository struct {
+	store *MemoryStore
+}
+
+// SaveSnapshot stores a day's rates, replacing any stored for the same day
+func (r *MemoryExchangeRateRepository) SaveSnapshot(ctx context.Context, snapshot *fxrates.Snapshot) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	date := memoryDate(snapshot.Date)
+	for currency, rate := range snapshot.Rates {
+		r.store.data.rates[rateKey{date, currency}] = rate
+	}
+	return nil
+}
+
+// ListSnapshots lists the snapshots taken between from and to (inclusive), along with the
+// latest one before from, which is still in effect at from
+func (r *MemoryExchangeRateRepository) ListSnapshots(ctx context.Context, from, to time.Time) ([]*fxrates.Snapshot, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	from, to = memoryDate(from), memoryDate(to)
+	// The latest day at or before from is in effect at from
+	start, found := from, false
+	for key := range r.store.data.rates {
+		if !key.date.After(from) && (!found || key.date.After(start)) {
+			start, found = key.date, true
+		}
+	}
+
+	byDate := make(map[time.Time]*fxrates.Snapshot)
+	for key, rate := range r.store.data.rates {
+		if key.date.Before(start) || key.date.After(to) {
+			continue
+		}
+		snapshot, ok := byDate[key.date]
+		if !ok {
+			snapshot = &fxrates.Snapshot{Date: key.date, Rates: make(map[string]float64)}
+			byDate[key.date] = snapshot
+		}
+		snapshot.Rates[key.currency] = rate
+	}
+
+	snapshots := []*fxrates.Snapshot{}
+	for _, date := range slices.SortedFunc(maps.Keys(byDate), time.Time.Compare) {
+		snapshots = append(snapshots, byDate[date])
+	}
+	return snapshots, nil
+}
+
+// memoryDate truncates a time to its UTC day, as storing it in a date column does
+func memoryDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,795 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// MemoryFileRepository stores file metadata in a memory store
+type MemoryFileRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts the metadata of a new file
+func (r *MemoryFileRepository) Create(ctx context.Context, file *models.File) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.files[file.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.files[file.ID] = *file
+	return nil
+}
+
+// FindByID finds a user's file by ID
+func (r *MemoryFileRepository) FindByID(ctx context.Context, id, userID string) (*models.File, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file, ok := r.store.data.files[id]
+	if !ok || file.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &file, nil
+}
+
+// ListByUser lists a user's files, newest first
+func (r *MemoryFileRepository) ListByUser(ctx context.Context, userID string) ([]*models.File, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	files := sortedValues(r.store.data.files,
+		func(f models.File) bool { return f.UserID == userID },
+		func(a, b models.File) int { return b.UploadedAt.Compare(a.UploadedAt) },
+	)
+	return pointers(files), nil
+}
+
+// ListAll lists every user's files, for storage maintenance
+func (r *MemoryFileRepository) ListAll(ctx context.Context) ([]*models.File, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	files := sortedValues(r.store.data.files,
+		func(f models.File) bool { return true },
+		func(a, b models.File) int { return cmp.Compare(a.ID, b.ID) },
+	)
+	return pointers(files), nil
+}
+
+// UpdateStatus sets the status of a user's file
+func (r *MemoryFileRepository) UpdateStatus(ctx context.Context, id, userID, status string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file, ok := r.store.data.files[id]
+	if !ok || file.UserID != userID {
+		return ErrNotFound
+	}
+	file.Status = status
+	file.UpdatedAt = time.Now()
+	r.store.data.files[id] = file
+	return nil
+}
+
+// AddTags adds tags to a user's file, keeping its tags sorted and each once
+func (r *MemoryFileRepository) AddTags(ctx context.Context, id, userID string, tags []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file, ok := r.store.data.files[id]
+	if !ok || file.UserID != userID {
+		return ErrNotFound
+	}
+	// Build a new slice, since snapshots of the store share the old one
+	merged := append(slices.Clone(file.Tags), tags...)
+	slices.Sort(merged)
+	file.Tags = slices.Compact(merged)
+	file.UpdatedAt = time.Now()
+	r.store.data.files[id] = file
+	return nil
+}
+
+// SetFolder files a user's file under a folder
+func (r *MemoryFileRepository) SetFolder(ctx context.Context, id, userID, folder string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file, ok := r.store.data.files[id]
+	if !ok || file.UserID != userID {
+		return ErrNotFound
+	}
+	file.Folder = folder
+	file.UpdatedAt = time.Now()
+	r.store.data.files[id] = file
+	return nil
+}
+
+// Delete removes the metadata of a user's file along with the rows that reference it
+func (r *MemoryFileRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file, ok := r.store.data.files[id]
+	if !ok || file.UserID != userID {
+		return ErrNotFound
+	}
+	r.store.data.deleteFile(id)
+	return nil
+}
+
+// MemoryJobRepository queues processing jobs in a memory store
+type MemoryJobRepository struct {
+	store *MemoryStore
+}
+
+// Enqueue inserts a new processing job
+func (r *MemoryJobRepository) Enqueue(ctx context.Context, job *models.ProcessingJob) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.jobs[job.ID]; ok {
+		return ErrDuplicate
+	}
+
+	stored := *job
+	stored.Attempts = 0
+	stored.StartedAt = nil
+	stored.CompletedAt = nil
+	stored.Progress = nil
+	r.store.data.jobs[job.ID] = stored
+	return nil
+}
+
+// FindByID finds a processing job by ID
+func (r *MemoryJobRepository) FindByID(ctx context.Context, id string) (*models.ProcessingJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	job, ok := r.store.data.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+// UpdateStatus moves a job to a new status, recording when it started or finished. Each start
+// counts as an attempt.
+func (r *MemoryJobRepository) UpdateStatus(ctx context.Context, id, status, errorMessage string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	job, ok := r.store.data.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	job.Status = status
+	job.Error = errorMessage
+	job.UpdatedAt = now
+	if status == models.JobStatusRunning {
+		job.Attempts++
+		job.StartedAt = &now
+	}
+	if job.Finished() {
+		job.CompletedAt = &now
+	}
+	r.store.data.jobs[id] = job
+	return nil
+}
+
+// Requeue puts a finished job back in the queue with its attempts reset, saving its priority
+// and parse options
+func (r *MemoryJobRepository) Requeue(ctx context.Context, job *models.ProcessingJob) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.jobs[job.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	stored.Status = models.JobStatusQueued
+	stored.Priority = job.Priority
+	stored.Reprocess = job.Reprocess
+	stored.Parser = job.Parser
+	stored.LogFormat = job.LogFormat
+	stored.Error = ""
+	stored.Attempts = 0
+	stored.UpdatedAt = time.Now()
+	stored.StartedAt = nil
+	stored.CompletedAt = nil
+	r.store.data.jobs[job.ID] = stored
+	return nil
+}
+
+// CancelActive cancels a file's queued and running jobs, returning their IDs
+func (r *MemoryJobRepository) CancelActive(ctx context.Context, fileID string) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	ids := []string{}
+	for id, job := range r.store.data.jobs {
+		if job.FileID != fileID || !jobActive(job) {
+			continue
+		}
+		job.Status = models.JobStatusCanceled
+		job.Error = "canceled by user"
+		job.UpdatedAt = now
+		job.CompletedAt = &now
+		r.store.data.jobs[id] = job
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// HasActive reports whether a file has a queued or running job
+func (r *MemoryJobRepository) HasActive(ctx context.Context, fileID string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, job := range r.store.data.jobs {
+		if job.FileID == fileID && jobActive(job) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CountByStatus counts the jobs with a status
+func (r *MemoryJobRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	count := 0
+	for _, job := range r.store.data.jobs {
+		if job.Status == status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// OldestCreatedAt returns when the oldest job with a status was created, or nil when there is none
+func (r *MemoryJobRepository) OldestCreatedAt(ctx context.Context, status string) (*time.Time, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var oldest *time.Time
+	for _, job := range r.store.data.jobs {
+		if job.Status == status && (oldest == nil || job.CreatedAt.Before(*oldest)) {
+			createdAt := job.CreatedAt
+			oldest = &createdAt
+		}
+	}
+	return oldest, nil
+}
+
+// ListByStatus lists the jobs with a status, highest priority first, then oldest first
+func (r *MemoryJobRepository) ListByStatus(ctx context.Context, status string) ([]*models.ProcessingJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	jobs := sortedValues(r.store.data.jobs,
+		func(j models.ProcessingJob) bool { return j.Status == status },
+		func(a, b models.ProcessingJob) int {
+			return cmp.Or(
+				cmp.Compare(models.JobPriorityRank(b.Priority), models.JobPriorityRank(a.Priority)),
+				a.CreatedAt.Compare(b.CreatedAt),
+			)
+		},
+	)
+	return pointers(jobs), nil
+}
+
+// CountUploadsSince counts the uploads an org's users have made since a time, by their first
+// processing job; reprocessing a file doesn't count as an upload
+func (r *MemoryJobRepository) CountUploadsSince(ctx context.Context, orgID string, since time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	count := 0
+	for _, job := range r.store.data.jobs {
+		if user, ok := r.store.data.users[job.UserID]; ok && user.OrgID == orgID && !job.Reprocess && !job.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// jobActive reports whether a job is queued or running
+func jobActive(job models.ProcessingJob) bool {
+	return job.Status == models.JobStatusQueued || job.Status == models.JobStatusRunning
+}
+
+// MemoryDeadLetterRepository stores dead-lettered jobs in a memory store
+type MemoryDeadLetterRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a dead-lettered job, replacing an earlier entry for the same job
+func (r *MemoryDeadLetterRepository) Create(ctx context.Context, job *models.DeadLetterJob) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.deadLetters[job.JobID] = *job
+	return nil
+}
+
+// List lists dead-lettered jobs, most recently failed first
+func (r *MemoryDeadLetterRepository) List(ctx context.Context) ([]*models.DeadLetterJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	jobs := sortedValues(r.store.data.deadLetters,
+		func(models.DeadLetterJob) bool { return true },
+		func(a, b models.DeadLetterJob) int { return b.FailedAt.Compare(a.FailedAt) },
+	)
+	return pointers(jobs), nil
+}
+
+// FindByJobID finds a dead-lettered job
+func (r *MemoryDeadLetterRepository) FindByJobID(ctx context.Context, jobID string) (*models.DeadLetterJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	job, ok := r.store.data.deadLetters[jobID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+// UpdateParameters saves the priority and parse options a dead-lettered job is requeued with
+func (r *MemoryDeadLetterRepository) UpdateParameters(ctx context.Context, job *models.DeadLetterJob) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.deadLetters[job.JobID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Priority = job.Priority
+	stored.Reprocess = job.Reprocess
+	stored.Parser = job.Parser
+	stored.LogFormat = job.LogFormat
+	r.store.data.deadLetters[job.JobID] = stored
+	return nil
+}
+
+// Delete removes a dead-lettered job
+func (r *MemoryDeadLetterRepository) Delete(ctx context.Context, jobID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.deadLetters[jobID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.deadLetters, jobID)
+	return nil
+}
+
+// MemoryIdempotencyRepository stores idempotency keys in a memory store
+type MemoryIdempotencyRepository struct {
+	store *MemoryStore
+}
+
+// Create records a key, returning ErrDuplicate when the user has already used it
+func (r *MemoryIdempotencyRepository) Create(ctx context.Context, key *models.IdempotencyKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	id := pairKey{key.UserID, key.Key}
+	if _, ok := r.store.data.idempotency[id]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.idempotency[id] = *key
+	return nil
+}
+
+// Find finds a user's key
+func (r *MemoryIdempotencyRepository) Find(ctx context.Context, userID, key string) (*models.IdempotencyKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	record, ok := r.store.data.idempotency[pairKey{userID, key}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// DeleteBefore removes keys created before the cutoff, returning how many were removed
+func (r *MemoryIdempotencyRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for id, key := range r.store.data.idempotency {
+		if key.CreatedAt.Before(cutoff) {
+			delete(r.store.data.idempotency, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// MemoryBatchRepository stores upload batches in a memory store
+type MemoryBatchRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new batch, returning ErrDuplicate when the user has already used its idempotency key
+func (r *MemoryBatchRepository) Create(ctx context.Context, batch *models.UploadBatch) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.batches[batch.ID]; ok {
+		return ErrDuplicate
+	}
+	if batch.IdempotencyKey != "" {
+		for _, existing := range r.store.data.batches {
+			if existing.batch.UserID == batch.UserID && existing.batch.IdempotencyKey == batch.IdempotencyKey {
+				return ErrDuplicate
+			}
+		}
+	}
+
+	stored := *batch
+	stored.Files = nil
+	stored.Rejected = slices.Clone(batch.Rejected)
+	r.store.data.batches[batch.ID] = memoryBatch{batch: stored, files: make(map[int]models.BatchFile)}
+	return nil
+}
+
+// AddFile adds an uploaded file to a batch at the given position
+func (r *MemoryBatchRepository) AddFile(ctx context.Context, batchID string, position int, file models.BatchFile) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	batch, ok := r.store.data.batches[batchID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := r.store.data.files[file.FileID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := batch.files[position]; ok {
+		return ErrDuplicate
+	}
+	batch.files[position] = file
+	return nil
+}
+
+// SetRejected records the files of a batch that weren't accepted
+func (r *MemoryBatchRepository) SetRejected(ctx context.Context, batchID string, rejected []models.RejectedFile) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if batch, ok := r.store.data.batches[batchID]; ok {
+		batch.batch.Rejected = slices.Clone(rejected)
+		r.store.data.batches[batchID] = batch
+	}
+	return nil
+}
+
+// FindByID finds a user's batch with its files and their current statuses, in upload order
+func (r *MemoryBatchRepository) FindByID(ctx context.Context, id, userID string) (*models.UploadBatch, error) {
+	return r.find(func(b *models.UploadBatch) bool { return b.ID == id && b.UserID == userID })
+}
+
+// FindByIdempotencyKey finds the batch a user created with an idempotency key
+func (r *MemoryBatchRepository) FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.UploadBatch, error) {
+	return r.find(func(b *models.UploadBatch) bool { return b.UserID == userID && b.IdempotencyKey == key && key != "" })
+}
+
+// find finds the batch matching a condition and loads its files
+func (r *MemoryBatchRepository) find(match func(*models.UploadBatch) bool) (*models.UploadBatch, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, stored := range r.store.data.batches {
+		if !match(&stored.batch) {
+			continue
+		}
+
+		batch := stored.batch
+		batch.Files = []models.BatchFile{}
+		for _, position := range slices.Sorted(maps.Keys(stored.files)) {
+			file := stored.files[position]
+			if f, ok := r.store.data.files[file.FileID]; ok {
+				file.FileName = f.FileName
+				file.FileSize = f.FileSize
+				file.Status = f.Status
+			}
+			file.Priority = models.JobPriorityNormal
+			if job, ok := r.store.data.jobs[file.JobID]; ok {
+				file.Priority = job.Priority
+			}
+			batch.Files = append(batch.Files, file)
+		}
+		batch.Rejected = slices.Clone(batch.Rejected)
+		if batch.Rejected == nil {
+			batch.Rejected = []models.RejectedFile{}
+		}
+		batch.SetStatus()
+		return &batch, nil
+	}
+	return nil, ErrNotFound
+}
+
+// MemoryDatasetRepository stores datasets in a memory store
+type MemoryDatasetRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new dataset
+func (r *MemoryDatasetRepository) Create(ctx context.Context, dataset *models.Dataset) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.datasets[dataset.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.datasets[dataset.ID] = *dataset
+	return nil
+}
+
+// FindByID finds a user's dataset by ID
+func (r *MemoryDatasetRepository) FindByID(ctx context.Context, id, userID string) (*models.Dataset, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	dataset, ok := r.store.data.datasets[id]
+	if !ok || dataset.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &dataset, nil
+}
+
+// ListByUser lists a user's datasets, most recently updated first
+func (r *MemoryDatasetRepository) ListByUser(ctx context.Context, userID string) ([]*models.Dataset, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	datasets := sortedValues(r.store.data.datasets,
+		func(d models.Dataset) bool { return d.UserID == userID },
+		func(a, b models.Dataset) int { return b.UpdatedAt.Compare(a.UpdatedAt) },
+	)
+	return pointers(datasets), nil
+}
+
+// AddFile appends a file to a dataset, returning ErrDuplicate when it is already a member
+func (r *MemoryDatasetRepository) AddFile(ctx context.Context, datasetID, fileID string, addedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	dataset, ok := r.store.data.datasets[datasetID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := r.store.data.files[fileID]; !ok {
+		return ErrNotFound
+	}
+
+	key := pairKey{datasetID, fileID}
+	if _, ok := r.store.data.datasetFiles[key]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.datasetFiles[key] = addedAt
+
+	dataset.UpdatedAt = addedAt
+	r.store.data.datasets[datasetID] = dataset
+	return nil
+}
+
+// ListFileIDs lists the files of a dataset in the order they were appended
+func (r *MemoryDatasetRepository) ListFileIDs(ctx context.Context, datasetID string) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	keys := []pairKey{}
+	for key := range r.store.data.datasetFiles {
+		if key.first == datasetID {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b pairKey) int {
+		return cmp.Or(
+			r.store.data.datasetFiles[a].Compare(r.store.data.datasetFiles[b]),
+			cmp.Compare(a.second, b.second),
+		)
+	})
+
+	fileIDs := make([]string, len(keys))
+	for i, key := range keys {
+		fileIDs[i] = key.second
+	}
+	return fileIDs, nil
+}
+
+// MemoryLogRecordRepository stores log records in a memory store. There are no partitions, so
+// maintaining them does nothing.
+type MemoryLogRecordRepository struct {
+	store *MemoryStore
+}
+
+// DeleteRecords removes every stored record of a file
+func (r *MemoryLogRecordRepository) DeleteRecords(ctx context.Context, fileID, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if records, ok := r.store.data.logRecords[fileID]; ok && records.userID == userID {
+		delete(r.store.data.logRecords, fileID)
+	}
+	return nil
+}
+
+// WriteRecords stores a batch of a file's records. Records without a bid time are skipped, as
+// they are when writing to PostgreSQL.
+func (r *MemoryLogRecordRepository) WriteRecords(ctx context.Context, fileID, userID string, records []ingestion.BeeswaxLogRecord) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored := r.store.data.logRecords[fileID]
+	written := slices.Clone(stored.records)
+	for _, record := range records {
+		if !record.BidTime.IsZero() {
+			written = append(written, record)
+		}
+	}
+	r.store.data.logRecords[fileID] = memoryRecords{userID: userID, records: written}
+	return nil
+}
+
+// ScanJourneyRecords calls fn with a campaign's delivered records that have a viewer, ordered by
+// viewer and bid time, filling only the fields a user journey needs
+func (r *MemoryLogRecordRepository) ScanJourneyRecords(ctx context.Context, fileID, userID, campaignID string, fn func(*ingestion.BeeswaxLogRecord) error) error {
+	r.store.mu.Lock()
+	stored := r.store.data.logRecords[fileID]
+	r.store.mu.Unlock()
+	if stored.userID != userID {
+		return nil
+	}
+
+	journey := []ingestion.BeeswaxLogRecord{}
+	for _, record := range stored.records {
+		delivered := !record.ImpressionTime.IsZero() || record.Clicks > 0 || record.Conversions > 0
+		if record.CampaignID == campaignID && record.UserID != "" && delivered {
+			journey = append(journey, ingestion.BeeswaxLogRecord{
+				CampaignID:     campaignID,
+				UserID:         record.UserID,
+				BidTime:        record.BidTime,
+				ImpressionTime: record.ImpressionTime,
+				Clicks:         record.Clicks,
+				Conversions:    record.Conversions,
+			})
+		}
+	}
+	slices.SortStableFunc(journey, func(a, b ingestion.BeeswaxLogRecord) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), a.BidTime.Compare(b.BidTime))
+	})
+
+	for i := range journey {
+		if err := fn(&journey[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsurePartitions does nothing
+func (r *MemoryLogRecordRepository) EnsurePartitions(ctx context.Context, from, to time.Time) error {
+	return nil
+}
+
+// DropPartitionsBefore drops nothing
+func (r *MemoryLogRecordRepository) DropPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	return nil, nil
+}
+
+// MemoryFileSchemaRepository stores file schemas in a memory store
+type MemoryFileSchemaRepository struct {
+	store *MemoryStore
+}
+
+// SaveSchema records a file's schema, replacing the one recorded when it was last processed
+func (r *MemoryFileSchemaRepository) SaveSchema(ctx context.Context, schema *ingestion.FileSchema) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.schemas[schema.FileID] = *schema
+	return nil
+}
+
+// PreviousSchema returns the latest schema of a user's uploads from a source other than the
+// file's own, or nil if there is none
+func (r *MemoryFileSchemaRepository) PreviousSchema(ctx context.Context, userID, source, fileID string) (*ingestion.FileSchema, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var previous *ingestion.FileSchema
+	for _, schema := range r.store.data.schemas {
+		if schema.UserID != userID || schema.Source != source || schema.FileID == fileID {
+			continue
+		}
+		if previous == nil || schema.CreatedAt.After(previous.CreatedAt) {
+			schema := schema
+			previous = &schema
+		}
+	}
+	return previous, nil
+}
+
+// FindByFileID finds the schema recorded for a user's file
+func (r *MemoryFileSchemaRepository) FindByFileID(ctx context.Context, fileID, userID string) (*ingestion.FileSchema, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	schema, ok := r.store.data.schemas[fileID]
+	if !ok || schema.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &schema, nil
+}
+
+// MemoryParserRunRepository stores parser runs in a memory store
+type MemoryParserRunRepository struct {
+	store *MemoryStore
+}
+
+// SaveParserRun inserts a parser run
+func (r *MemoryParserRunRepository) SaveParserRun(ctx context.Context, run *ingestion.ParserRun) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.parserRuns = append(r.store.data.parserRuns, *run)
+	return nil
+}
+
+// ListStats totals the runs that finished in [from, to) by parser and source. Rates are left
+// for the caller to calculate.
+func (r *MemoryParserRunRepository) ListStats(ctx context.Context, from, to time.Time) ([]*ingestion.ParserStats, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	totals := make(map[pairKey]*ingestion.ParserStats)
+	for _, run := range r.store.data.parserRuns {
+		if run.FinishedAt.Before(from) || !run.FinishedAt.Before(to) {
+			continue
+		}
+
+		key := pairKey{run.Parser, run.Source}
+		stat, ok := totals[key]
+		if !ok {
+			stat = &ingestion.ParserStats{Parser: run.Parser, Source: run.Source}
+			totals[key] = stat
+		}
+		stat.Files++
+		if run.Failed {
+			stat.FailedFiles++
+		}
+		stat.Rows += run.Rows
+		stat.RowErrors += run.RowErrors
+		stat.Bytes += run.Bytes
+		stat.Seconds += float64(run.Duration.Milliseconds()) / 1000
+	}
+
+	stats := slices.Collect(maps.Values(totals))
+	slices.SortFunc(stats, func(a, b *ingestion.ParserStats) int {
+		return cmp.Or(cmp.Compare(a.Parser, b.Parser), cmp.Compare(a.Source, b.Source))
+	})
+	if stats == nil {
+		stats = []*ingestion.ParserStats{}
+	}
+	return stats, nil
+}
@@ -0,0 +1,826 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// MemoryCategoryOverrideRepository stores category overrides in a memory store
+type MemoryCategoryOverrideRepository struct {
+	store *MemoryStore
+}
+
+// ListOverrides returns a user's category overrides by domain
+func (r *MemoryCategoryOverrideRepository) ListOverrides(ctx context.Context, userID string) (map[string]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	overrides := make(map[string]string)
+	for key, category := range r.store.data.categories {
+		if key.first == userID {
+			overrides[key.second] = category
+		}
+	}
+	return overrides, nil
+}
+
+// SetOverride creates or replaces a user's category for a domain
+func (r *MemoryCategoryOverrideRepository) SetOverride(ctx context.Context, userID, domain, category string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.categories[pairKey{userID, domain}] = category
+	return nil
+}
+
+// DeleteOverride removes a user's category for a domain
+func (r *MemoryCategoryOverrideRepository) DeleteOverride(ctx context.Context, userID, domain string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := pairKey{userID, domain}
+	if _, ok := r.store.data.categories[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.categories, key)
+	return nil
+}
+
+// MemoryMappingProfileRepository stores mapping profiles in a memory store
+type MemoryMappingProfileRepository struct {
+	store *MemoryStore
+}
+
+// ListProfiles returns a user's mapping profiles by source
+func (r *MemoryMappingProfileRepository) ListProfiles(ctx context.Context, userID string) (map[string]*ingestion.MappingProfile, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	profiles := make(map[string]*ingestion.MappingProfile)
+	for key, profile := range r.store.data.mappings {
+		if key.first == userID {
+			profile.Columns = maps.Clone(profile.Columns)
+			profiles[key.second] = &profile
+		}
+	}
+	return profiles, nil
+}
+
+// SetProfile creates or replaces a user's mapping profile for a source
+func (r *MemoryMappingProfileRepository) SetProfile(ctx context.Context, userID string, profile *ingestion.MappingProfile) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored := *profile
+	stored.Columns = maps.Clone(profile.Columns)
+	r.store.data.mappings[pairKey{userID, profile.Source}] = stored
+	return nil
+}
+
+// ExtendProfile adds header names to a user's existing profile for a source, keeping the
+// canonical column of names already in it
+func (r *MemoryMappingProfileRepository) ExtendProfile(ctx context.Context, userID, source string, columns map[string]string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := pairKey{userID, source}
+	profile, ok := r.store.data.mappings[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	extended := maps.Clone(columns)
+	if extended == nil {
+		extended = make(map[string]string)
+	}
+	maps.Copy(extended, profile.Columns)
+	profile.Columns = extended
+	profile.UpdatedAt = time.Now()
+	r.store.data.mappings[key] = profile
+	return nil
+}
+
+// DeleteProfile removes a user's mapping profile for a source
+func (r *MemoryMappingProfileRepository) DeleteProfile(ctx context.Context, userID, source string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := pairKey{userID, source}
+	if _, ok := r.store.data.mappings[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.mappings, key)
+	return nil
+}
+
+// MemoryBrandSafetyRepository stores brand safety lists in a memory store
+type MemoryBrandSafetyRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new brand safety list, returning ErrDuplicate when the user already has a list with its name
+func (r *MemoryBrandSafetyRepository) Create(ctx context.Context, list *models.BrandSafetyList) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.data.brandSafety {
+		if existing.ID == list.ID || (existing.UserID == list.UserID && existing.Name == list.Name) {
+			return ErrDuplicate
+		}
+	}
+
+	stored := *list
+	stored.Entries = slices.Clone(list.Entries)
+	r.store.data.brandSafety[list.ID] = stored
+	return nil
+}
+
+// ListByUser lists a user's brand safety lists, ordered by name
+func (r *MemoryBrandSafetyRepository) ListByUser(ctx context.Context, userID string) ([]*models.BrandSafetyList, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	lists := sortedValues(r.store.data.brandSafety,
+		func(l models.BrandSafetyList) bool { return l.UserID == userID },
+		func(a, b models.BrandSafetyList) int { return cmp.Compare(a.Name, b.Name) },
+	)
+	for i := range lists {
+		lists[i].Entries = slices.Clone(lists[i].Entries)
+	}
+	return pointers(lists), nil
+}
+
+// Delete removes a user's brand safety list
+func (r *MemoryBrandSafetyRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	list, ok := r.store.data.brandSafety[id]
+	if !ok || list.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.store.data.brandSafety, id)
+	return nil
+}
+
+// MemoryReportTemplateRepository stores report templates in a memory store
+type MemoryReportTemplateRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new report template, returning ErrDuplicate when the organization already has a template with its name
+func (r *MemoryReportTemplateRepository) Create(ctx context.Context, template *models.ReportTemplate) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.templates[template.ID]; ok || r.nameTaken(template) {
+		return ErrDuplicate
+	}
+	r.store.data.templates[template.ID] = copyReportTemplate(*template)
+	return nil
+}
+
+// ListByOrg lists an organization's report templates, ordered by name
+func (r *MemoryReportTemplateRepository) ListByOrg(ctx context.Context, orgID string) ([]*models.ReportTemplate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	templates := sortedValues(r.store.data.templates,
+		func(t models.ReportTemplate) bool { return t.OrgID == orgID },
+		func(a, b models.ReportTemplate) int { return cmp.Compare(a.Name, b.Name) },
+	)
+	for i := range templates {
+		templates[i] = copyReportTemplate(templates[i])
+	}
+	return pointers(templates), nil
+}
+
+// FindByID finds one of an organization's report templates
+func (r *MemoryReportTemplateRepository) FindByID(ctx context.Context, id, orgID string) (*models.ReportTemplate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	template, ok := r.store.data.templates[id]
+	if !ok || template.OrgID != orgID {
+		return nil, ErrNotFound
+	}
+	template = copyReportTemplate(template)
+	return &template, nil
+}
+
+// Update replaces a report template's name, description and sections, returning ErrDuplicate
+// when another of the organization's templates has its name
+func (r *MemoryReportTemplateRepository) Update(ctx context.Context, template *models.ReportTemplate) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.templates[template.ID]
+	if !ok || stored.OrgID != template.OrgID {
+		return ErrNotFound
+	}
+	if r.nameTaken(template) {
+		return ErrDuplicate
+	}
+
+	stored.Name = template.Name
+	stored.Description = template.Description
+	stored.Sections = template.Sections
+	stored.UpdatedAt = template.UpdatedAt
+	r.store.data.templates[template.ID] = copyReportTemplate(stored)
+	return nil
+}
+
+// Delete removes one of an organization's report templates
+func (r *MemoryReportTemplateRepository) Delete(ctx context.Context, id, orgID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	template, ok := r.store.data.templates[id]
+	if !ok || template.OrgID != orgID {
+		return ErrNotFound
+	}
+	delete(r.store.data.templates, id)
+	return nil
+}
+
+// nameTaken reports whether another of the organization's templates has the template's name
+func (r *MemoryReportTemplateRepository) nameTaken(template *models.ReportTemplate) bool {
+	for _, existing := range r.store.data.templates {
+		if existing.ID != template.ID && existing.OrgID == template.OrgID && existing.Name == template.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// copyReportTemplate copies a template's sections so the copy can be changed independently
+func copyReportTemplate(template models.ReportTemplate) models.ReportTemplate {
+	template.Sections = slices.Clone(template.Sections)
+	for i := range template.Sections {
+		template.Sections[i].Metrics = slices.Clone(template.Sections[i].Metrics)
+	}
+	return template
+}
+
+// MemoryEmbedRepository stores embeds in a memory store
+type MemoryEmbedRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new embed. Only the token's hash is stored.
+func (r *MemoryEmbedRepository) Create(ctx context.Context, embed *models.Embed) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.files[embed.FileID]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range r.store.data.embeds {
+		if existing.ID == embed.ID || existing.TokenHash == embed.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	stored := *embed
+	stored.Token = ""
+	r.store.data.embeds[embed.ID] = stored
+	return nil
+}
+
+// ListByUser lists a user's embeds, newest first
+func (r *MemoryEmbedRepository) ListByUser(ctx context.Context, userID string) ([]*models.Embed, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	embeds := sortedValues(r.store.data.embeds,
+		func(e models.Embed) bool { return e.UserID == userID },
+		func(a, b models.Embed) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	)
+	return pointers(embeds), nil
+}
+
+// FindByTokenHash finds the embed with a token hash
+func (r *MemoryEmbedRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Embed, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, embed := range r.store.data.embeds {
+		if embed.TokenHash == tokenHash {
+			return &embed, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Revoke revokes a user's active embed
+func (r *MemoryEmbedRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	embed, ok := r.store.data.embeds[id]
+	if !ok || embed.UserID != userID || embed.RevokedAt != nil {
+		return ErrNotFound
+	}
+	embed.RevokedAt = &revokedAt
+	r.store.data.embeds[id] = embed
+	return nil
+}
+
+// MemoryAPIKeyRepository stores API keys in a memory store
+type MemoryAPIKeyRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new API key. Only the key's hash is stored.
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.users[key.UserID]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range r.store.data.apiKeys {
+		if existing.ID == key.ID || existing.KeyHash == key.KeyHash {
+			return ErrDuplicate
+		}
+	}
+
+	stored := *key
+	stored.Key = ""
+	r.store.data.apiKeys[key.ID] = stored
+	return nil
+}
+
+// ListByUser lists a user's API keys, newest first
+func (r *MemoryAPIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	keys := sortedValues(r.store.data.apiKeys,
+		func(k models.APIKey) bool { return k.UserID == userID },
+		func(a, b models.APIKey) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	)
+	return pointers(keys), nil
+}
+
+// FindByHash finds the API key with a key hash
+func (r *MemoryAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, key := range r.store.data.apiKeys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Touch records that an API key was used
+func (r *MemoryAPIKeyRepository) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if key, ok := r.store.data.apiKeys[id]; ok {
+		key.LastUsedAt = &usedAt
+		r.store.data.apiKeys[id] = key
+	}
+	return nil
+}
+
+// Revoke revokes a user's active API key
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key, ok := r.store.data.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return ErrNotFound
+	}
+	key.RevokedAt = &revokedAt
+	r.store.data.apiKeys[id] = key
+	return nil
+}
+
+// MemoryDataShareRepository stores data shares in a memory store
+type MemoryDataShareRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new data share. Only the token's hash is stored.
+func (r *MemoryDataShareRepository) Create(ctx context.Context, share *models.DataShare) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.users[share.UserID]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range r.store.data.shares {
+		if existing.ID == share.ID || existing.TokenHash == share.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	stored := *share
+	stored.Token = ""
+	stored.CampaignIDs = slices.Clone(share.CampaignIDs)
+	r.store.data.shares[share.ID] = stored
+	return nil
+}
+
+// ListByOrg lists an org's data shares, newest first
+func (r *MemoryDataShareRepository) ListByOrg(ctx context.Context, orgID string) ([]*models.DataShare, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	shares := sortedValues(r.store.data.shares,
+		func(s models.DataShare) bool { return s.OrgID == orgID },
+		func(a, b models.DataShare) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	)
+	return pointers(shares), nil
+}
+
+// FindByTokenHash finds the data share with a token hash
+func (r *MemoryDataShareRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.DataShare, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, share := range r.store.data.shares {
+		if share.TokenHash == tokenHash {
+			return &share, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Revoke revokes an org's active data share
+func (r *MemoryDataShareRepository) Revoke(ctx context.Context, id, orgID string, revokedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	share, ok := r.store.data.shares[id]
+	if !ok || share.OrgID != orgID || share.RevokedAt != nil {
+		return ErrNotFound
+	}
+	share.RevokedAt = &revokedAt
+	r.store.data.shares[id] = share
+	return nil
+}
+
+// MemoryUsageRepository stores orgs' monthly usage in a memory store
+type MemoryUsageRepository struct {
+	store *MemoryStore
+}
+
+// Add adds API calls and ingested rows to an org's month
+func (r *MemoryUsageRepository) Add(ctx context.Context, orgID string, period time.Time, apiCalls, rowsIngested int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.orgs[orgID]; !ok {
+		return ErrNotFound
+	}
+
+	key := usageKey{orgID, period}
+	usage, ok := r.store.data.usage[key]
+	if !ok {
+		usage = models.OrgUsage{OrgID: orgID, Period: period}
+	}
+	usage.APICalls += apiCalls
+	usage.RowsIngested += rowsIngested
+	r.store.data.usage[key] = usage
+	return nil
+}
+
+// Get returns an org's usage in a month, which is zero when nothing has been metered
+func (r *MemoryUsageRepository) Get(ctx context.Context, orgID string, period time.Time) (*models.OrgUsage, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	usage, ok := r.store.data.usage[usageKey{orgID, period}]
+	if !ok {
+		usage = models.OrgUsage{OrgID: orgID, Period: period}
+	}
+	return &usage, nil
+}
+
+// ListSince lists an org's metered months from since onwards, newest first
+func (r *MemoryUsageRepository) ListSince(ctx context.Context, orgID string, since time.Time) ([]*models.OrgUsage, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	usage := sortedValues(r.store.data.usage,
+		func(u models.OrgUsage) bool { return u.OrgID == orgID && !u.Period.Before(since) },
+		func(a, b models.OrgUsage) int { return b.Period.Compare(a.Period) },
+	)
+	return pointers(usage), nil
+}
+
+// StorageBytes returns the total size of the files an org's users have stored
+func (r *MemoryUsageRepository) StorageBytes(ctx context.Context, orgID string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var total int64
+	for _, file := range r.store.data.files {
+		if user, ok := r.store.data.users[file.UserID]; ok && user.OrgID == orgID {
+			total += file.FileSize
+		}
+	}
+	return total, nil
+}
+
+// MemoryIncidentRepository stores status page incidents in a memory store
+type MemoryIncidentRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new incident
+func (r *MemoryIncidentRepository) Create(ctx context.Context, incident *models.Incident) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.incidents[incident.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.incidents[incident.ID] = *incident
+	return nil
+}
+
+// FindByID finds an incident
+func (r *MemoryIncidentRepository) FindByID(ctx context.Context, id string) (*models.Incident, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	incident, ok := r.store.data.incidents[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &incident, nil
+}
+
+// ListSince lists incidents that are unresolved or were resolved after a time, newest first
+func (r *MemoryIncidentRepository) ListSince(ctx context.Context, since time.Time) ([]*models.Incident, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	incidents := sortedValues(r.store.data.incidents,
+		func(i models.Incident) bool { return i.ResolvedAt == nil || i.ResolvedAt.After(since) },
+		func(a, b models.Incident) int { return b.StartedAt.Compare(a.StartedAt) },
+	)
+	return pointers(incidents), nil
+}
+
+// Update saves an incident's title, message, severity and resolution
+func (r *MemoryIncidentRepository) Update(ctx context.Context, incident *models.Incident) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.incidents[incident.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Title = incident.Title
+	stored.Message = incident.Message
+	stored.Severity = incident.Severity
+	stored.UpdatedAt = incident.UpdatedAt
+	stored.ResolvedAt = incident.ResolvedAt
+	r.store.data.incidents[incident.ID] = stored
+	return nil
+}
+
+// MemoryCustomMetricRepository stores custom metrics in a memory store
+type MemoryCustomMetricRepository struct {
+	store *MemoryStore
+}
+
+// ListMetrics returns an organization's custom metrics, ordered by name
+func (r *MemoryCustomMetricRepository) ListMetrics(ctx context.Context, orgID string) ([]*models.CustomMetric, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	metrics := sortedValues(r.store.data.metrics,
+		func(m models.CustomMetric) bool { return m.OrgID == orgID },
+		func(a, b models.CustomMetric) int { return cmp.Compare(a.Name, b.Name) },
+	)
+	return pointers(metrics), nil
+}
+
+// SetMetric creates or replaces an organization's custom metric
+func (r *MemoryCustomMetricRepository) SetMetric(ctx context.Context, metric *models.CustomMetric) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.metrics[pairKey{metric.OrgID, metric.Name}] = *metric
+	return nil
+}
+
+// DeleteMetric removes an organization's custom metric
+func (r *MemoryCustomMetricRepository) DeleteMetric(ctx context.Context, orgID, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := pairKey{orgID, name}
+	if _, ok := r.store.data.metrics[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.metrics, key)
+	return nil
+}
+
+// MemoryCampaignGoalRepository stores campaign goals in a memory store
+type MemoryCampaignGoalRepository struct {
+	store *MemoryStore
+}
+
+// ListGoals returns a user's campaign goals by campaign ID
+func (r *MemoryCampaignGoalRepository) ListGoals(ctx context.Context, userID string) (map[string]*ingestion.CampaignGoal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	goals := make(map[string]*ingestion.CampaignGoal)
+	for key, goal := range r.store.data.goals {
+		if key.first == userID {
+			goals[key.second] = &goal
+		}
+	}
+	return goals, nil
+}
+
+// GetGoal returns a user's goal for a campaign
+func (r *MemoryCampaignGoalRepository) GetGoal(ctx context.Context, userID, campaignID string) (*ingestion.CampaignGoal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	goal, ok := r.store.data.goals[pairKey{userID, campaignID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &goal, nil
+}
+
+// SetGoal creates or replaces a user's goal for a campaign
+func (r *MemoryCampaignGoalRepository) SetGoal(ctx context.Context, userID string, goal *ingestion.CampaignGoal) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.goals[pairKey{userID, goal.CampaignID}] = *goal
+	return nil
+}
+
+// DeleteGoal removes a user's goal for a campaign
+func (r *MemoryCampaignGoalRepository) DeleteGoal(ctx context.Context, userID, campaignID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := pairKey{userID, campaignID}
+	if _, ok := r.store.data.goals[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.goals, key)
+	return nil
+}
+
+// MemoryCampaignAnnotationRepository stores campaign annotations in a memory store
+type MemoryCampaignAnnotationRepository struct {
+	store *MemoryStore
+}
+
+// ListAnnotations returns a user's annotations of a campaign, or of every campaign when
+// campaignID is empty, that occurred from from up to but excluding to, ordered by when they
+// occurred
+func (r *MemoryCampaignAnnotationRepository) ListAnnotations(ctx context.Context, userID, campaignID string, from, to *time.Time) ([]ingestion.CampaignAnnotation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	annotations := []ingestion.CampaignAnnotation{}
+	for _, stored := range r.store.data.annotations {
+		annotation := stored.annotation
+		if stored.userID != userID || (campaignID != "" && annotation.CampaignID != campaignID) {
+			continue
+		}
+		if (from != nil && annotation.OccurredAt.Before(*from)) || (to != nil && !annotation.OccurredAt.Before(*to)) {
+			continue
+		}
+		annotations = append(annotations, annotation)
+	}
+	slices.SortFunc(annotations, func(a, b ingestion.CampaignAnnotation) int {
+		return cmp.Or(a.OccurredAt.Compare(b.OccurredAt), cmp.Compare(a.ID, b.ID))
+	})
+	return annotations, nil
+}
+
+// CreateAnnotation records an annotation of one of a user's campaigns
+func (r *MemoryCampaignAnnotationRepository) CreateAnnotation(ctx context.Context, userID string, annotation *ingestion.CampaignAnnotation) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.annotations[annotation.ID] = memoryAnnotation{userID: userID, annotation: *annotation}
+	return nil
+}
+
+// DeleteAnnotation removes an annotation of one of a user's campaigns
+func (r *MemoryCampaignAnnotationRepository) DeleteAnnotation(ctx context.Context, userID, campaignID, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.data.annotations[id]
+	if !ok || stored.userID != userID || stored.annotation.CampaignID != campaignID {
+		return ErrNotFound
+	}
+	delete(r.store.data.annotations, id)
+	return nil
+}
+
+// MemoryExperimentRepository stores experiments in a memory store
+type MemoryExperimentRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new experiment
+func (r *MemoryExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.experiments[experiment.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.experiments[experiment.ID] = copyExperiment(*experiment)
+	return nil
+}
+
+// ListByUser lists a user's experiments, newest first
+func (r *MemoryExperimentRepository) ListByUser(ctx context.Context, userID string) ([]*models.Experiment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	experiments := sortedValues(r.store.data.experiments,
+		func(e models.Experiment) bool { return e.UserID == userID },
+		func(a, b models.Experiment) int {
+			return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.ID, b.ID))
+		},
+	)
+	for i := range experiments {
+		experiments[i] = copyExperiment(experiments[i])
+	}
+	return pointers(experiments), nil
+}
+
+// FindByID finds one of a user's experiments
+func (r *MemoryExperimentRepository) FindByID(ctx context.Context, id, userID string) (*models.Experiment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	experiment, ok := r.store.data.experiments[id]
+	if !ok || experiment.UserID != userID {
+		return nil, ErrNotFound
+	}
+	experiment = copyExperiment(experiment)
+	return &experiment, nil
+}
+
+// SaveResult replaces the result of an experiment's latest analysis
+func (r *MemoryExperimentRepository) SaveResult(ctx context.Context, id, userID string, result *models.ExperimentResult) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	experiment, ok := r.store.data.experiments[id]
+	if !ok || experiment.UserID != userID {
+		return ErrNotFound
+	}
+	experiment.Result = result
+	experiment.UpdatedAt = result.AnalyzedAt
+	r.store.data.experiments[id] = copyExperiment(experiment)
+	return nil
+}
+
+// Delete removes one of a user's experiments
+func (r *MemoryExperimentRepository) Delete(ctx context.Context, id, userID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	experiment, ok := r.store.data.experiments[id]
+	if !ok || experiment.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.store.data.experiments, id)
+	return nil
+}
+
+// copyExperiment copies an experiment so the stored one isn't shared with callers
+func copyExperiment(experiment models.Experiment) models.Experiment {
+	experiment.Control.Values = slices.Clone(experiment.Control.Values)
+	experiment.Variant.Values = slices.Clone(experiment.Variant.Values)
+	if experiment.Result != nil {
+		result := *experiment.Result
+		result.Lifts = slices.Clone(result.Lifts)
+		experiment.Result = &result
+	}
+	return experiment
+}
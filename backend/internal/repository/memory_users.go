@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// MemoryUserRepository stores users in a memory store
+type MemoryUserRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new user, creating the user's organization, named after the user, if it doesn't exist
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	d := &r.store.data
+	if _, ok := d.users[user.ID]; ok {
+		return ErrDuplicate
+	}
+	for _, existing := range d.users {
+		if existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+
+	if _, ok := d.orgs[user.OrgID]; !ok {
+		d.orgs[user.OrgID] = models.Organization{
+			ID:                user.OrgID,
+			Name:              user.FirstName + " " + user.LastName,
+			JobPriority:       models.JobPriorityNormal,
+			ReportingCurrency: "USD",
+			Plan:              models.PlanTrial,
+			CreatedAt:         user.CreatedAt,
+		}
+	}
+	d.users[user.ID] = *user
+	return nil
+}
+
+// FindByID finds a user by ID
+func (r *MemoryUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// FindByEmail finds a user by email
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range r.store.data.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListIDsByOrg lists the IDs of an org's users, for reports across all of their files
+func (r *MemoryUserRepository) ListIDsByOrg(ctx context.Context, orgID string) ([]string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	userIDs := []string{}
+	for _, user := range r.store.data.users {
+		if user.OrgID == orgID {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	slices.Sort(userIDs)
+	return userIDs, nil
+}
+
+// ExistsByEmail checks if a user with the given email exists
+func (r *MemoryUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, err := r.FindByEmail(ctx, email)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Update updates an existing user
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.data.users[user.ID]
+	if !ok {
+		return nil
+	}
+
+	updated := *user
+	updated.OrgID = existing.OrgID
+	updated.CreatedAt = existing.CreatedAt
+	r.store.data.users[user.ID] = updated
+	return nil
+}
+
+// MemoryOrganizationRepository stores organizations in a memory store
+type MemoryOrganizationRepository struct {
+	store *MemoryStore
+}
+
+// FindByID finds an organization by ID
+func (r *MemoryOrganizationRepository) FindByID(ctx context.Context, id string) (*models.Organization, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	org, ok := r.store.data.orgs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	org.UploadDefaults.Parsers = maps.Clone(org.UploadDefaults.Parsers)
+	return &org, nil
+}
+
+// Upsert inserts an organization or updates its name, job priority, reporting currency and plan,
+// keeping its creation time and upload defaults
+func (r *MemoryOrganizationRepository) Upsert(ctx context.Context, org *models.Organization) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	upserted := *org
+	upserted.UploadDefaults = models.UploadDefaults{}
+	if existing, ok := r.store.data.orgs[org.ID]; ok {
+		upserted.CreatedAt = existing.CreatedAt
+		upserted.UploadDefaults = existing.UploadDefaults
+	}
+	r.store.data.orgs[org.ID] = upserted
+	return nil
+}
+
+// JobPriorityForUser returns the job priority of a user's organization
+func (r *MemoryOrganizationRepository) JobPriorityForUser(ctx context.Context, userID string) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.data.users[userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	org, ok := r.store.data.orgs[user.OrgID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return org.JobPriority, nil
+}
+
+// UploadDefaultsForUser returns the upload defaults of a user's organization
+func (r *MemoryOrganizationRepository) UploadDefaultsForUser(ctx context.Context, userID string) (*models.UploadDefaults, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.data.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	org, ok := r.store.data.orgs[user.OrgID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	defaults := org.UploadDefaults
+	defaults.Parsers = maps.Clone(defaults.Parsers)
+	return &defaults, nil
+}
+
+// SetJobPriority sets the job priority of an organization
+func (r *MemoryOrganizationRepository) SetJobPriority(ctx context.Context, id, priority string) error {
+	return r.update(id, func(org *models.Organization) { org.JobPriority = priority })
+}
+
+// SetReportingCurrency sets the currency an organization's spend is reported in
+func (r *MemoryOrganizationRepository) SetReportingCurrency(ctx context.Context, id, currency string) error {
+	return r.update(id, func(org *models.Organization) { org.ReportingCurrency = currency })
+}
+
+// SetUploadDefaults sets how an organization's uploads are parsed when they don't say
+func (r *MemoryOrganizationRepository) SetUploadDefaults(ctx context.Context, id string, defaults models.UploadDefaults) error {
+	defaults.Parsers = maps.Clone(defaults.Parsers)
+	return r.update(id, func(org *models.Organization) { org.UploadDefaults = defaults })
+}
+
+// SetPlan sets an organization's plan and, for trials, when the trial ends; nil uses the default length
+func (r *MemoryOrganizationRepository) SetPlan(ctx context.Context, id, plan string, trialEndsAt *time.Time) error {
+	return r.update(id, func(org *models.Organization) { org.Plan, org.TrialEndsAt = plan, trialEndsAt })
+}
+
+// update changes an organization, returning ErrNotFound when it doesn't exist
+func (r *MemoryOrganizationRepository) update(id string, change func(*models.Organization)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	org, ok := r.store.data.orgs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&org)
+	r.store.data.orgs[id] = org
+	return nil
+}
+
+// MemorySessionRepository stores sessions in a memory store
+type MemorySessionRepository struct {
+	store *MemoryStore
+}
+
+// Create inserts a new session
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.data.sessions[session.ID]; ok {
+		return ErrDuplicate
+	}
+	r.store.data.sessions[session.ID] = *session
+	return nil
+}
+
+// FindByID finds a user's session
+func (r *MemorySessionRepository) FindByID(ctx context.Context, id, userID string) (*models.Session, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.data.sessions[id]
+	if !ok || session.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// FindByRefreshTokenHash finds the session whose refresh token, or the token it replaced, has a hash
+func (r *MemorySessionRepository) FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, session := range r.store.data.sessions {
+		if session.RefreshTokenHash == hash || session.PreviousRefreshTokenHash == hash {
+			return &session, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListActive lists a user's sessions that are neither revoked nor expired, most recently seen first
+func (r *MemorySessionRepository) ListActive(ctx context.Context, userID string, now time.Time) ([]*models.Session, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	sessions := sortedValues(r.store.data.sessions,
+		func(s models.Session) bool { return s.UserID == userID && s.Active(now) },
+		func(a, b models.Session) int { return b.LastSeenAt.Compare(a.LastSeenAt) },
+	)
+	return pointers(sessions), nil
+}
+
+// Touch records that a session was used
+func (r *MemorySessionRepository) Touch(ctx context.Context, id string, seenAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if session, ok := r.store.data.sessions[id]; ok {
+		session.LastSeenAt = seenAt
+		r.store.data.sessions[id] = session
+	}
+	return nil
+}
+
+// RotateRefreshToken replaces an active session's refresh token, returning ErrNotFound when the
+// token was already replaced
+func (r *MemorySessionRepository) RotateRefreshToken(ctx context.Context, id, hash, newHash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.data.sessions[id]
+	if !ok || session.RefreshTokenHash != hash || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	session.PreviousRefreshTokenHash, session.RefreshTokenHash = hash, newHash
+	r.store.data.sessions[id] = session
+	return nil
+}
+
+// Revoke revokes a user's active session
+func (r *MemorySessionRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.data.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	session.RevokedAt = &revokedAt
+	r.store.data.sessions[id] = session
+	return nil
+}
+
+// DeleteExpired removes sessions that expired before the cutoff
+func (r *MemorySessionRepository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for id, session := range r.store.data.sessions {
+		if session.ExpiresAt.Before(cutoff) {
+			delete(r.store.data.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// MemoryPreferencesRepository stores user preferences in a memory store
+type MemoryPreferencesRepository struct {
+	store *MemoryStore
+}
+
+// Get finds a user's preferences, returning ErrNotFound when the user hasn't saved any
+func (r *MemoryPreferencesRepository) Get(ctx context.Context, userID string) (*models.Preferences, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	preferences, ok := r.store.data.preferences[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &preferences, nil
+}
+
+// Upsert saves a user's preferences
+func (r *MemoryPreferencesRepository) Upsert(ctx context.Context, preferences *models.Preferences) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.preferences[preferences.UserID] = *preferences
+	return nil
+}
+
+// MemoryDigestRepository tracks email digest deliveries in a memory store
+type MemoryDigestRepository struct {
+	store *MemoryStore
+}
+
+// ListRecipients lists the users who opted in to the email digest
+func (r *MemoryDigestRepository) ListRecipients(ctx context.Context) ([]*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	users := sortedValues(r.store.data.users,
+		func(u models.User) bool { return r.store.data.preferences[u.ID].EmailDigest },
+		func(a, b models.User) int { return cmp.Compare(a.ID, b.ID) },
+	)
+	return pointers(users), nil
+}
+
+// ClaimDelivery records that a user's digest for the period ending at periodEnd is being sent,
+// returning false when it was already claimed
+func (r *MemoryDigestRepository) ClaimDelivery(ctx context.Context, userID string, periodEnd, claimedAt time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := digestKey(userID, periodEnd)
+	if _, ok := r.store.data.digests[key]; ok {
+		return false, nil
+	}
+	r.store.data.digests[key] = claimedAt
+	return true, nil
+}
+
+// ReleaseDelivery removes a claim whose digest failed to send, so it's retried
+func (r *MemoryDigestRepository) ReleaseDelivery(ctx context.Context, userID string, periodEnd time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.digests, digestKey(userID, periodEnd))
+	return nil
+}
+
+// digestKey identifies a user's digest for a period
+func digestKey(userID string, periodEnd time.Time) pairKey {
+	return pairKey{userID, periodEnd.UTC().Format(time.RFC3339Nano)}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresOrganizationRepository stores organizations in PostgreSQL
+type PostgresOrganizationRepository struct {
+	db DBTX
+}
+
+// NewPostgresOrganizationRepository creates a new PostgreSQL organization repository
+func NewPostgresOrganizationRepository(db DBTX) *PostgresOrganizationRepository {
+	return &PostgresOrganizationRepository{
+		db: db,
+	}
+}
+
+// FindByID finds an organization by ID
+func (r *PostgresOrganizationRepository) FindByID(ctx context.Context, id string) (*models.Organization, error) {
+	query := `
+		SELECT id, name, job_priority, reporting_currency, plan, trial_ends_at, upload_defaults, created_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	org := &models.Organization{}
+	err := r.db.QueryRow(ctx, query, id).Scan(&org.ID, &org.Name, &org.JobPriority, &org.ReportingCurrency, &org.Plan, &org.TrialEndsAt, &org.UploadDefaults, &org.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// Upsert inserts an organization or updates its name, job priority, reporting currency and plan,
+// keeping its creation time and upload defaults
+func (r *PostgresOrganizationRepository) Upsert(ctx context.Context, org *models.Organization) error {
+	query := `
+		INSERT INTO organizations (id, name, job_priority, reporting_currency, plan, trial_ends_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE
+		SET name = EXCLUDED.name,
+			job_priority = EXCLUDED.job_priority,
+			reporting_currency = EXCLUDED.reporting_currency,
+			plan = EXCLUDED.plan,
+			trial_ends_at = EXCLUDED.trial_ends_at
+	`
+
+	_, err := r.db.Exec(ctx, query, org.ID, org.Name, org.JobPriority, org.ReportingCurrency, org.Plan, org.TrialEndsAt, org.CreatedAt)
+	return err
+}
+
+// JobPriorityForUser returns the job priority of a user's organization
+func (r *PostgresOrganizationRepository) JobPriorityForUser(ctx context.Context, userID string) (string, error) {
+	query := `
+		SELECT o.job_priority
+		FROM users u
+		JOIN organizations o ON o.id = u.org_id
+		WHERE u.id = $1
+	`
+
+	var priority string
+	err := r.db.QueryRow(ctx, query, userID).Scan(&priority)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return priority, nil
+}
+
+// UploadDefaultsForUser returns the upload defaults of a user's organization
+func (r *PostgresOrganizationRepository) UploadDefaultsForUser(ctx context.Context, userID string) (*models.UploadDefaults, error) {
+	query := `
+		SELECT o.upload_defaults
+		FROM users u
+		JOIN organizations o ON o.id = u.org_id
+		WHERE u.id = $1
+	`
+
+	defaults := &models.UploadDefaults{}
+	err := r.db.QueryRow(ctx, query, userID).Scan(defaults)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return defaults, nil
+}
+
+// SetJobPriority sets the job priority of an organization
+func (r *PostgresOrganizationRepository) SetJobPriority(ctx context.Context, id, priority string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE organizations SET job_priority = $2 WHERE id = $1`, id, priority)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetReportingCurrency sets the currency an organization's spend is reported in
+func (r *PostgresOrganizationRepository) SetReportingCurrency(ctx context.Context, id, currency string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE organizations SET reporting_currency = $2 WHERE id = $1`, id, currency)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetUploadDefaults sets how an organization's uploads are parsed when they don't say
+func (r *PostgresOrganizationRepository) SetUploadDefaults(ctx context.Context, id string, defaults models.UploadDefaults) error {
+	tag, err := r.db.Exec(ctx, `UPDATE organizations SET upload_defaults = $2 WHERE id = $1`, id, defaults)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetPlan sets an organization's plan and, for trials, when the trial ends; nil uses the default length
+func (r *PostgresOrganizationRepository) SetPlan(ctx context.Context, id, plan string, trialEndsAt *time.Time) error {
+	tag, err := r.db.Exec(ctx, `UPDATE organizations SET plan = $2, trial_ends_at = $3 WHERE id = $1`, id, plan, trialEndsAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
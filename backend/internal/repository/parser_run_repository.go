@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresParserRunRepository stores the outcome of each file's parse in PostgreSQL
+type PostgresParserRunRepository struct {
+	db DBTX
+}
+
+// NewPostgresParserRunRepository creates a new PostgreSQL parser run repository
+func NewPostgresParserRunRepository(db DBTX) *PostgresParserRunRepository {
+	return &PostgresParserRunRepository{
+		db: db,
+	}
+}
+
+// SaveParserRun inserts a parser run
+func (r *PostgresParserRunRepository) SaveParserRun(ctx context.Context, run *ingestion.ParserRun) error {
+	query := `
+		INSERT INTO parser_runs (file_id, parser, source, failed, row_count, row_errors, bytes, duration_ms, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		run.FileID,
+		run.Parser,
+		run.Source,
+		run.Failed,
+		run.Rows,
+		run.RowErrors,
+		run.Bytes,
+		run.Duration.Milliseconds(),
+		run.FinishedAt,
+	)
+
+	return err
+}
+
+// ListStats totals the runs that finished in [from, to) by parser and source. Rates are left
+// for the caller to calculate.
+func (r *PostgresParserRunRepository) ListStats(ctx context.Context, from, to time.Time) ([]*ingestion.ParserStats, error) {
+	query := `
+		SELECT parser, source, COUNT(*), COUNT(*) FILTER (WHERE failed),
+			COALESCE(SUM(row_count), 0), COALESCE(SUM(row_errors), 0), COALESCE(SUM(bytes), 0), COALESCE(SUM(duration_ms), 0)
+		FROM parser_runs
+		WHERE finished_at >= $1 AND finished_at < $2
+		GROUP BY parser, source
+		ORDER BY parser, source
+	`
+
+	rows, err := r.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*ingestion.ParserStats{}
+	for rows.Next() {
+		stat := &ingestion.ParserStats{}
+		var durationMs int64
+		if err := rows.Scan(&stat.Parser, &stat.Source, &stat.Files, &stat.FailedFiles, &stat.Rows, &stat.RowErrors, &stat.Bytes, &durationMs); err != nil {
+			return nil, fmt.Errorf("failed to scan parser stats: %w", err)
+		}
+		stat.Seconds = float64(durationMs) / 1000
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
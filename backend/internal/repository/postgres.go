@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBTX is implemented by both a connection pool and a transaction, so repositories
+// can run inside or outside a unit of work
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// NewPostgresRepositories creates PostgreSQL repositories backed by the given connection
+func NewPostgresRepositories(db DBTX) Repositories {
+	return Repositories{
+		Users:        NewPostgresUserRepository(db),
+		Orgs:         NewPostgresOrganizationRepository(db),
+		Files:        NewPostgresFileRepository(db),
+		Jobs:         NewPostgresJobRepository(db),
+		DeadLetters:  NewPostgresDeadLetterRepository(db),
+		LogRecords:   NewPostgresLogRecordRepository(db),
+		Idempotency:  NewPostgresIdempotencyRepository(db),
+		Datasets:     NewPostgresDatasetRepository(db),
+		Integrations: NewPostgresIntegrationRepository(db),
+		Streams:      NewPostgresLogStreamRepository(db),
+		Delivery:     NewPostgresDeliveryReportRepository(db),
+		Categories:   NewPostgresCategoryOverrideRepository(db),
+		Mappings:     NewPostgresMappingProfileRepository(db),
+		Schemas:      NewPostgresFileSchemaRepository(db),
+		BrandSafety:  NewPostgresBrandSafetyRepository(db),
+		Rollups:      NewPostgresRollupRepository(db),
+		Realtime:     NewPostgresRealtimeRepository(db),
+		Exports:      NewPostgresExportRepository(db),
+		Sessions:     NewPostgresSessionRepository(db),
+		Preferences:  NewPostgresPreferencesRepository(db),
+		Digests:      NewPostgresDigestRepository(db),
+		Batches:      NewPostgresBatchRepository(db),
+		Metrics:      NewPostgresCustomMetricRepository(db),
+		Goals:        NewPostgresCampaignGoalRepository(db),
+		Annotations:  NewPostgresCampaignAnnotationRepository(db),
+		Experiments:  NewPostgresExperimentRepository(db),
+		Rates:        NewPostgresExchangeRateRepository(db),
+		Invoices:     NewPostgresInvoiceRepository(db),
+		Templates:    NewPostgresReportTemplateRepository(db),
+		Embeds:       NewPostgresEmbedRepository(db),
+		APIKeys:      NewPostgresAPIKeyRepository(db),
+		Shares:       NewPostgresDataShareRepository(db),
+		Usage:        NewPostgresUsageRepository(db),
+		Incidents:    NewPostgresIncidentRepository(db),
+		ParserRuns:   NewPostgresParserRunRepository(db),
+	}
+}
+
+// PostgresUnitOfWork runs units of work in PostgreSQL transactions
+type PostgresUnitOfWork struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresUnitOfWork creates a unit of work backed by a connection pool
+func NewPostgresUnitOfWork(pool *pgxpool.Pool) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{
+		pool: pool,
+	}
+}
+
+// WithinTx runs fn in a transaction, committing on success and rolling back on error or panic
+func (u *PostgresUnitOfWork) WithinTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) (err error) {
+	tx, err := u.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(ctx, NewPostgresRepositories(tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
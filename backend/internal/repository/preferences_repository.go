@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresPreferencesRepository stores users' preferences in PostgreSQL
+type PostgresPreferencesRepository struct {
+	db DBTX
+}
+
+// NewPostgresPreferencesRepository creates a new PostgreSQL preferences repository
+func NewPostgresPreferencesRepository(db DBTX) *PostgresPreferencesRepository {
+	return &PostgresPreferencesRepository{
+		db: db,
+	}
+}
+
+// Get finds a user's preferences, returning ErrNotFound when the user hasn't saved any
+func (r *PostgresPreferencesRepository) Get(ctx context.Context, userID string) (*models.Preferences, error) {
+	query := `
+		SELECT user_id, currency, timezone, date_format, default_dashboard, email_digest, updated_at
+		FROM user_preferences
+		WHERE user_id = $1
+	`
+
+	preferences := &models.Preferences{}
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&preferences.UserID,
+		&preferences.Currency,
+		&preferences.Timezone,
+		&preferences.DateFormat,
+		&preferences.DefaultDashboard,
+		&preferences.EmailDigest,
+		&preferences.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// Upsert saves a user's preferences
+func (r *PostgresPreferencesRepository) Upsert(ctx context.Context, preferences *models.Preferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, currency, timezone, date_format, default_dashboard, email_digest, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE
+		SET currency = EXCLUDED.currency,
+			timezone = EXCLUDED.timezone,
+			date_format = EXCLUDED.date_format,
+			default_dashboard = EXCLUDED.default_dashboard,
+			email_digest = EXCLUDED.email_digest,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		preferences.UserID,
+		preferences.Currency,
+		preferences.Timezone,
+		preferences.DateFormat,
+		preferences.DefaultDashboard,
+		preferences.EmailDigest,
+		preferences.UpdatedAt,
+	)
+
+	return err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// PostgresRealtimeRepository stores the per-minute metrics of streamed logs for live monitoring
+type PostgresRealtimeRepository struct {
+	db DBTX
+}
+
+// NewPostgresRealtimeRepository creates a new PostgreSQL realtime metrics repository
+func NewPostgresRealtimeRepository(db DBTX) *PostgresRealtimeRepository {
+	return &PostgresRealtimeRepository{
+		db: db,
+	}
+}
+
+// AddMinutes adds metrics to a user's minutes, so every server consuming a stream's partitions
+// contributes to the same rows
+func (r *PostgresRealtimeRepository) AddMinutes(ctx context.Context, userID string, minutes []ingestion.RealtimeMinute) error {
+	if len(minutes) == 0 {
+		return nil
+	}
+
+	// Send the minutes as parallel arrays so the upsert is a single statement
+	var (
+		campaignIDs = make([]string, len(minutes))
+		buckets     = make([]time.Time, len(minutes))
+		bids        = make([]int64, len(minutes))
+		impressions = make([]int64, len(minutes))
+		clicks      = make([]int64, len(minutes))
+		conversions = make([]int64, len(minutes))
+		spend       = make([]float64, len(minutes))
+		revenue     = make([]float64, len(minutes))
+	)
+	for i, minute := range minutes {
+		campaignIDs[i] = minute.CampaignID
+		buckets[i] = minute.Minute
+		bids[i] = int64(minute.Bids)
+		impressions[i] = int64(minute.Impressions)
+		clicks[i] = int64(minute.Clicks)
+		conversions[i] = int64(minute.Conversions)
+		spend[i] = minute.Spend
+		revenue[i] = minute.Revenue
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO realtime_metrics (user_id, campaign_id, minute, bids, impressions, clicks, conversions, spend, revenue)
+		SELECT $1, * FROM unnest(
+			$2::text[], $3::timestamptz[], $4::bigint[], $5::bigint[], $6::bigint[], $7::bigint[],
+			$8::double precision[], $9::double precision[]
+		)
+		ON CONFLICT (user_id, campaign_id, minute) DO UPDATE
+		SET bids = realtime_metrics.bids + EXCLUDED.bids,
+			impressions = realtime_metrics.impressions + EXCLUDED.impressions,
+			clicks = realtime_metrics.clicks + EXCLUDED.clicks,
+			conversions = realtime_metrics.conversions + EXCLUDED.conversions,
+			spend = realtime_metrics.spend + EXCLUDED.spend,
+			revenue = realtime_metrics.revenue + EXCLUDED.revenue
+	`, userID, campaignIDs, buckets, bids, impressions, clicks, conversions, spend, revenue)
+	return err
+}
+
+// ListMinutes lists a user's minutes from since onwards, oldest first
+func (r *PostgresRealtimeRepository) ListMinutes(ctx context.Context, userID string, since time.Time) ([]ingestion.RealtimeMinute, error) {
+	query := `
+		SELECT campaign_id, minute, bids, impressions, clicks, conversions, spend, revenue
+		FROM realtime_metrics
+		WHERE user_id = $1 AND minute >= $2
+		ORDER BY minute, campaign_id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	minutes := []ingestion.RealtimeMinute{}
+	for rows.Next() {
+		var minute ingestion.RealtimeMinute
+		if err := rows.Scan(
+			&minute.CampaignID,
+			&minute.Minute,
+			&minute.Bids,
+			&minute.Impressions,
+			&minute.Clicks,
+			&minute.Conversions,
+			&minute.Spend,
+			&minute.Revenue,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan realtime minute: %w", err)
+		}
+		minutes = append(minutes, minute)
+	}
+
+	return minutes, rows.Err()
+}
+
+// DeleteBefore removes minutes before the cutoff, returning how many were removed
+func (r *PostgresRealtimeRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM realtime_metrics WHERE minute < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresReportTemplateRepository stores organizations' report templates in PostgreSQL
+type PostgresReportTemplateRepository struct {
+	db DBTX
+}
+
+// NewPostgresReportTemplateRepository creates a new PostgreSQL report template repository
+func NewPostgresReportTemplateRepository(db DBTX) *PostgresReportTemplateRepository {
+	return &PostgresReportTemplateRepository{
+		db: db,
+	}
+}
+
+// reportTemplateColumns lists the columns selected for a report template, in scan order
+const reportTemplateColumns = `id, org_id, name, description, sections, created_by, created_at, updated_at`
+
+// Create inserts a new report template, returning ErrDuplicate when the organization already has a template with its name
+func (r *PostgresReportTemplateRepository) Create(ctx context.Context, template *models.ReportTemplate) error {
+	query := `
+		INSERT INTO report_templates (` + reportTemplateColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		template.ID,
+		template.OrgID,
+		template.Name,
+		template.Description,
+		template.Sections,
+		template.CreatedBy,
+		template.CreatedAt,
+		template.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// ListByOrg lists an organization's report templates, ordered by name
+func (r *PostgresReportTemplateRepository) ListByOrg(ctx context.Context, orgID string) ([]*models.ReportTemplate, error) {
+	query := `
+		SELECT ` + reportTemplateColumns + `
+		FROM report_templates
+		WHERE org_id = $1
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*models.ReportTemplate{}
+	for rows.Next() {
+		template, err := scanReportTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report template: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+// FindByID finds one of an organization's report templates
+func (r *PostgresReportTemplateRepository) FindByID(ctx context.Context, id, orgID string) (*models.ReportTemplate, error) {
+	query := `
+		SELECT ` + reportTemplateColumns + `
+		FROM report_templates
+		WHERE id = $1 AND org_id = $2
+	`
+
+	template, err := scanReportTemplate(r.db.QueryRow(ctx, query, id, orgID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Update replaces a report template's name, description and sections, returning ErrDuplicate
+// when another of the organization's templates has its name
+func (r *PostgresReportTemplateRepository) Update(ctx context.Context, template *models.ReportTemplate) error {
+	query := `
+		UPDATE report_templates
+		SET name = $3, description = $4, sections = $5, updated_at = $6
+		WHERE id = $1 AND org_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query,
+		template.ID,
+		template.OrgID,
+		template.Name,
+		template.Description,
+		template.Sections,
+		template.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes one of an organization's report templates
+func (r *PostgresReportTemplateRepository) Delete(ctx context.Context, id, orgID string) error {
+	query := `
+		DELETE FROM report_templates
+		WHERE id = $1 AND org_id = $2
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanReportTemplate scans a row selected with reportTemplateColumns
+func scanReportTemplate(row pgx.Row) (*models.ReportTemplate, error) {
+	template := &models.ReportTemplate{}
+	err := row.Scan(
+		&template.ID,
+		&template.OrgID,
+		&template.Name,
+		&template.Description,
+		&template.Sections,
+		&template.CreatedBy,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+
+	return template, err
+}
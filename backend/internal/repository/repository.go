@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// Common errors
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// UserRepository persists users
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	ListIDsByOrg(ctx context.Context, orgID string) ([]string, error)
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	Update(ctx context.Context, user *models.User) error
+}
+
+// OrganizationRepository persists organizations
+type OrganizationRepository interface {
+	FindByID(ctx context.Context, id string) (*models.Organization, error)
+	Upsert(ctx context.Context, org *models.Organization) error
+	JobPriorityForUser(ctx context.Context, userID string) (string, error)
+	SetJobPriority(ctx context.Context, id, priority string) error
+	SetReportingCurrency(ctx context.Context, id, currency string) error
+	UploadDefaultsForUser(ctx context.Context, userID string) (*models.UploadDefaults, error)
+	SetUploadDefaults(ctx context.Context, id string, defaults models.UploadDefaults) error
+	SetPlan(ctx context.Context, id, plan string, trialEndsAt *time.Time) error
+}
+
+// FileRepository persists uploaded file metadata
+type FileRepository interface {
+	Create(ctx context.Context, file *models.File) error
+	FindByID(ctx context.Context, id, userID string) (*models.File, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.File, error)
+	ListAll(ctx context.Context) ([]*models.File, error)
+	UpdateStatus(ctx context.Context, id, userID, status string) error
+	AddTags(ctx context.Context, id, userID string, tags []string) error
+	SetFolder(ctx context.Context, id, userID, folder string) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+// JobRepository persists file processing jobs
+type JobRepository interface {
+	Enqueue(ctx context.Context, job *models.ProcessingJob) error
+	FindByID(ctx context.Context, id string) (*models.ProcessingJob, error)
+	UpdateStatus(ctx context.Context, id, status, errorMessage string) error
+	CancelActive(ctx context.Context, fileID string) ([]string, error)
+	HasActive(ctx context.Context, fileID string) (bool, error)
+	CountByStatus(ctx context.Context, status string) (int, error)
+	OldestCreatedAt(ctx context.Context, status string) (*time.Time, error)
+	Requeue(ctx context.Context, job *models.ProcessingJob) error
+	ListByStatus(ctx context.Context, status string) ([]*models.ProcessingJob, error)
+	CountUploadsSince(ctx context.Context, orgID string, since time.Time) (int, error)
+}
+
+// DeadLetterRepository persists processing jobs that failed on every attempt
+type DeadLetterRepository interface {
+	Create(ctx context.Context, job *models.DeadLetterJob) error
+	List(ctx context.Context) ([]*models.DeadLetterJob, error)
+	FindByJobID(ctx context.Context, jobID string) (*models.DeadLetterJob, error)
+	UpdateParameters(ctx context.Context, job *models.DeadLetterJob) error
+	Delete(ctx context.Context, jobID string) error
+}
+
+// IdempotencyRepository persists idempotency keys of requests that create files
+type IdempotencyRepository interface {
+	Create(ctx context.Context, key *models.IdempotencyKey) error
+	Find(ctx context.Context, userID, key string) (*models.IdempotencyKey, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// DatasetRepository persists datasets and the files appended to them
+type DatasetRepository interface {
+	Create(ctx context.Context, dataset *models.Dataset) error
+	FindByID(ctx context.Context, id, userID string) (*models.Dataset, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.Dataset, error)
+	AddFile(ctx context.Context, datasetID, fileID string, addedAt time.Time) error
+	ListFileIDs(ctx context.Context, datasetID string) ([]string, error)
+}
+
+// IntegrationRepository persists integrations and the campaign performance and site outcomes pulled from them
+type IntegrationRepository interface {
+	Upsert(ctx context.Context, integration *models.Integration) error
+	FindByID(ctx context.Context, id, userID string) (*models.Integration, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.Integration, error)
+	ListDue(ctx context.Context, cutoff time.Time) ([]*models.Integration, error)
+	UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error
+	Delete(ctx context.Context, id, userID string) error
+	UpsertPerformance(ctx context.Context, rows []models.CampaignPerformance) error
+	ListPerformance(ctx context.Context, userID string, from, to time.Time) ([]models.CampaignPerformance, error)
+	UpsertSiteOutcomes(ctx context.Context, rows []models.SiteOutcome) error
+	ListSiteOutcomes(ctx context.Context, userID string, from, to time.Time) ([]models.SiteOutcome, error)
+}
+
+// LogStreamRepository persists the Kafka topics streamed into users' rollups
+type LogStreamRepository interface {
+	Create(ctx context.Context, stream *models.LogStream) error
+	FindByID(ctx context.Context, id, userID string) (*models.LogStream, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.LogStream, error)
+	ListActive(ctx context.Context) ([]*models.LogStream, error)
+	SetStatus(ctx context.Context, id, userID, status string) error
+	RecordProgress(ctx context.Context, id string, consumed, rejected int64, consumedAt time.Time) error
+	RecordError(ctx context.Context, id, lastError string, failedAt time.Time) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+// DeliveryReportRepository persists the rows of imported ad server delivery reports
+type DeliveryReportRepository interface {
+	DeleteRows(ctx context.Context, fileID, userID string) error
+	InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.DeliveryReportRow) error
+	ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.DeliveryReportRow, error)
+}
+
+// InvoiceRepository persists the rows of DSP invoices imported from uploaded files
+type InvoiceRepository interface {
+	DeleteRows(ctx context.Context, fileID, userID string) error
+	InsertRows(ctx context.Context, fileID, userID string, importedAt time.Time, rows []ingestion.InvoiceRow) error
+	ListRows(ctx context.Context, userID string, from, to time.Time) ([]ingestion.InvoiceRow, error)
+}
+
+// CategoryOverrideRepository persists the content categories users assign to domains
+type CategoryOverrideRepository interface {
+	ListOverrides(ctx context.Context, userID string) (map[string]string, error)
+	SetOverride(ctx context.Context, userID, domain, category string) error
+	DeleteOverride(ctx context.Context, userID, domain string) error
+}
+
+// MappingProfileRepository persists the column mappings users extend log formats with
+type MappingProfileRepository interface {
+	ListProfiles(ctx context.Context, userID string) (map[string]*ingestion.MappingProfile, error)
+	SetProfile(ctx context.Context, userID string, profile *ingestion.MappingProfile) error
+	ExtendProfile(ctx context.Context, userID, source string, columns map[string]string) error
+	DeleteProfile(ctx context.Context, userID, source string) error
+}
+
+// FileSchemaRepository persists the column sets of uploaded logs and how they drifted
+type FileSchemaRepository interface {
+	SaveSchema(ctx context.Context, schema *ingestion.FileSchema) error
+	PreviousSchema(ctx context.Context, userID, source, fileID string) (*ingestion.FileSchema, error)
+	FindByFileID(ctx context.Context, fileID, userID string) (*ingestion.FileSchema, error)
+}
+
+// BrandSafetyRepository persists users' brand safety block lists, allow lists and keyword sets
+type BrandSafetyRepository interface {
+	Create(ctx context.Context, list *models.BrandSafetyList) error
+	ListByUser(ctx context.Context, userID string) ([]*models.BrandSafetyList, error)
+	Delete(ctx context.Context, id, userID string) error
+}
+
+// ReportTemplateRepository persists organizations' report templates
+type ReportTemplateRepository interface {
+	Create(ctx context.Context, template *models.ReportTemplate) error
+	ListByOrg(ctx context.Context, orgID string) ([]*models.ReportTemplate, error)
+	FindByID(ctx context.Context, id, orgID string) (*models.ReportTemplate, error)
+	Update(ctx context.Context, template *models.ReportTemplate) error
+	Delete(ctx context.Context, id, orgID string) error
+}
+
+// EmbedRepository persists users' tokenized chart embeds
+type EmbedRepository interface {
+	Create(ctx context.Context, embed *models.Embed) error
+	ListByUser(ctx context.Context, userID string) ([]*models.Embed, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.Embed, error)
+	Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error
+}
+
+// APIKeyRepository persists the API keys users read their metrics feed with
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error)
+	FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+	Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error
+}
+
+// DataShareRepository persists the scoped tokens orgs share campaigns' delivery with
+type DataShareRepository interface {
+	Create(ctx context.Context, share *models.DataShare) error
+	ListByOrg(ctx context.Context, orgID string) ([]*models.DataShare, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.DataShare, error)
+	Revoke(ctx context.Context, id, orgID string, revokedAt time.Time) error
+}
+
+// IncidentRepository persists the incidents posted to the status page
+type IncidentRepository interface {
+	Create(ctx context.Context, incident *models.Incident) error
+	FindByID(ctx context.Context, id string) (*models.Incident, error)
+	ListSince(ctx context.Context, since time.Time) ([]*models.Incident, error)
+	Update(ctx context.Context, incident *models.Incident) error
+}
+
+// ParserRunRepository persists the outcome of each file's parse for parser health tracking
+type ParserRunRepository interface {
+	SaveParserRun(ctx context.Context, run *ingestion.ParserRun) error
+	ListStats(ctx context.Context, from, to time.Time) ([]*ingestion.ParserStats, error)
+}
+
+// LogRecordRepository persists the individual records of processed log files
+type LogRecordRepository interface {
+	DeleteRecords(ctx context.Context, fileID, userID string) error
+	WriteRecords(ctx context.Context, fileID, userID string, records []ingestion.BeeswaxLogRecord) error
+	ScanJourneyRecords(ctx context.Context, fileID, userID, campaignID string, fn func(*ingestion.BeeswaxLogRecord) error) error
+	EnsurePartitions(ctx context.Context, from, to time.Time) error
+	DropPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error)
+}
+
+// ExportRepository persists users' export destinations and the sync state of each table exported to them
+type ExportRepository interface {
+	Create(ctx context.Context, destination *models.ExportDestination) error
+	FindByID(ctx context.Context, id, userID string) (*models.ExportDestination, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.ExportDestination, error)
+	ListDue(ctx context.Context, cutoff time.Time) ([]*models.ExportDestination, error)
+	UpdateSyncStatus(ctx context.Context, id, status, lastError string, syncedAt time.Time) error
+	Delete(ctx context.Context, id, userID string) error
+	ListTableSyncs(ctx context.Context, destinationID string) ([]*models.ExportTableSync, error)
+	SaveTableSync(ctx context.Context, sync *models.ExportTableSync) error
+}
+
+// RealtimeRepository persists the per-minute metrics of streamed logs, summed across the servers
+// consuming them
+type RealtimeRepository interface {
+	AddMinutes(ctx context.Context, userID string, minutes []ingestion.RealtimeMinute) error
+	ListMinutes(ctx context.Context, userID string, since time.Time) ([]ingestion.RealtimeMinute, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// RollupRepository persists the hourly and daily rollups of processed files
+type RollupRepository interface {
+	DeleteRollups(ctx context.Context, fileID, userID string) error
+	InsertRollups(ctx context.Context, fileID, userID string, rolledUpAt time.Time, rollups []ingestion.Rollup) error
+	CountPendingFiles(ctx context.Context, userID string) (int, error)
+	ScanRollups(ctx context.Context, query ingestion.RollupQuery, fn func(ingestion.Rollup) error) error
+	HasRollups(ctx context.Context, fileID, userID string) (bool, error)
+	ListRolledUpSince(ctx context.Context, userID string, since time.Time) ([]string, error)
+	ScanFileRollups(ctx context.Context, fileID, userID string, fn func(ingestion.Rollup) error) error
+	ScanFileBreakdown(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error
+	ScanFileHours(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error
+}
+
+// SessionRepository persists users' signed-in sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByID(ctx context.Context, id, userID string) (*models.Session, error)
+	FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	ListActive(ctx context.Context, userID string, now time.Time) ([]*models.Session, error)
+	Touch(ctx context.Context, id string, seenAt time.Time) error
+	RotateRefreshToken(ctx context.Context, id, hash, newHash string) error
+	Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PreferencesRepository persists users' report and dashboard defaults
+type PreferencesRepository interface {
+	Get(ctx context.Context, userID string) (*models.Preferences, error)
+	Upsert(ctx context.Context, preferences *models.Preferences) error
+}
+
+// DigestRepository finds the recipients of the weekly email digest and tracks its deliveries
+type DigestRepository interface {
+	ListRecipients(ctx context.Context) ([]*models.User, error)
+	ClaimDelivery(ctx context.Context, userID string, periodEnd, claimedAt time.Time) (bool, error)
+	ReleaseDelivery(ctx context.Context, userID string, periodEnd time.Time) error
+}
+
+// BatchRepository persists upload batches and the files accepted into them
+type BatchRepository interface {
+	Create(ctx context.Context, batch *models.UploadBatch) error
+	AddFile(ctx context.Context, batchID string, position int, file models.BatchFile) error
+	SetRejected(ctx context.Context, batchID string, rejected []models.RejectedFile) error
+	FindByID(ctx context.Context, id, userID string) (*models.UploadBatch, error)
+	FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.UploadBatch, error)
+}
+
+// CustomMetricRepository persists organizations' custom metrics
+type CustomMetricRepository interface {
+	ListMetrics(ctx context.Context, orgID string) ([]*models.CustomMetric, error)
+	SetMetric(ctx context.Context, metric *models.CustomMetric) error
+	DeleteMetric(ctx context.Context, orgID, name string) error
+}
+
+// CampaignGoalRepository persists the goals users set for their campaigns
+type CampaignGoalRepository interface {
+	ListGoals(ctx context.Context, userID string) (map[string]*ingestion.CampaignGoal, error)
+	GetGoal(ctx context.Context, userID, campaignID string) (*ingestion.CampaignGoal, error)
+	SetGoal(ctx context.Context, userID string, goal *ingestion.CampaignGoal) error
+	DeleteGoal(ctx context.Context, userID, campaignID string) error
+}
+
+// CampaignAnnotationRepository persists the events users record against their campaigns
+type CampaignAnnotationRepository interface {
+	ListAnnotations(ctx context.Context, userID, campaignID string, from, to *time.Time) ([]ingestion.CampaignAnnotation, error)
+	CreateAnnotation(ctx context.Context, userID string, annotation *ingestion.CampaignAnnotation) error
+	DeleteAnnotation(ctx context.Context, userID, campaignID, id string) error
+}
+
+// ExperimentRepository persists users' experiments and the result of their latest analysis
+type ExperimentRepository interface {
+	Create(ctx context.Context, experiment *models.Experiment) error
+	ListByUser(ctx context.Context, userID string) ([]*models.Experiment, error)
+	FindByID(ctx context.Context, id, userID string) (*models.Experiment, error)
+	SaveResult(ctx context.Context, id, userID string, result *models.ExperimentResult) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+// ExchangeRateRepository persists the daily exchange rate snapshots spend is converted with
+type ExchangeRateRepository interface {
+	SaveSnapshot(ctx context.Context, snapshot *fxrates.Snapshot) error
+	ListSnapshots(ctx context.Context, from, to time.Time) ([]*fxrates.Snapshot, error)
+}
+
+// UsageRepository meters orgs' API calls and ingested rows by calendar month
+type UsageRepository interface {
+	Add(ctx context.Context, orgID string, period time.Time, apiCalls, rowsIngested int64) error
+	Get(ctx context.Context, orgID string, period time.Time) (*models.OrgUsage, error)
+	ListSince(ctx context.Context, orgID string, since time.Time) ([]*models.OrgUsage, error)
+	StorageBytes(ctx context.Context, orgID string) (int64, error)
+}
+
+// Repositories groups the repositories that share a connection or transaction
+type Repositories struct {
+	Users        UserRepository
+	Orgs         OrganizationRepository
+	Files        FileRepository
+	Jobs         JobRepository
+	DeadLetters  DeadLetterRepository
+	LogRecords   LogRecordRepository
+	Idempotency  IdempotencyRepository
+	Datasets     DatasetRepository
+	Integrations IntegrationRepository
+	Streams      LogStreamRepository
+	Delivery     DeliveryReportRepository
+	Categories   CategoryOverrideRepository
+	Mappings     MappingProfileRepository
+	Schemas      FileSchemaRepository
+	BrandSafety  BrandSafetyRepository
+	Rollups      RollupRepository
+	Realtime     RealtimeRepository
+	Exports      ExportRepository
+	Sessions     SessionRepository
+	Preferences  PreferencesRepository
+	Digests      DigestRepository
+	Batches      BatchRepository
+	Metrics      CustomMetricRepository
+	Goals        CampaignGoalRepository
+	Annotations  CampaignAnnotationRepository
+	Experiments  ExperimentRepository
+	Rates        ExchangeRateRepository
+	Invoices     InvoiceRepository
+	Templates    ReportTemplateRepository
+	Embeds       EmbedRepository
+	APIKeys      APIKeyRepository
+	Shares       DataShareRepository
+	Usage        UsageRepository
+	Incidents    IncidentRepository
+	ParserRuns   ParserRunRepository
+}
+
+// UnitOfWork runs a function against repositories bound to a single transaction.
+// The transaction is committed when the function returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
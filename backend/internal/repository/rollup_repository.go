@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// rollupColumns lists the columns written for each rollup, in CopyFrom order
+var rollupColumns = []string{
+	"file_id", "user_id", "grain", "dimension", "value", "bucket",
+	"bids", "impressions", "clicks", "conversions", "spend", "revenue",
+	"measurable_impressions", "viewable_impressions",
+}
+
+// PostgresRollupRepository stores the hourly and daily rollups of processed files
+type PostgresRollupRepository struct {
+	db DBTX
+}
+
+// NewPostgresRollupRepository creates a new PostgreSQL rollup repository
+func NewPostgresRollupRepository(db DBTX) *PostgresRollupRepository {
+	return &PostgresRollupRepository{
+		db: db,
+	}
+}
+
+// DeleteRollups removes a file's rollups and the record that it was rolled up
+func (r *PostgresRollupRepository) DeleteRollups(ctx context.Context, fileID, userID string) error {
+	if _, err := r.db.Exec(ctx, `
+		DELETE FROM metric_rollups
+		WHERE file_id = $1 AND user_id = $2
+	`, fileID, userID); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM rollup_files
+		WHERE file_id = $1 AND user_id = $2
+	`, fileID, userID)
+	return err
+}
+
+// InsertRollups bulk-loads a file's rollups and records that the file was rolled up
+func (r *PostgresRollupRepository) InsertRollups(ctx context.Context, fileID, userID string, rolledUpAt time.Time, rollups []ingestion.Rollup) error {
+	rows := make([][]interface{}, len(rollups))
+	for i, rollup := range rollups {
+		rows[i] = []interface{}{
+			fileID, userID, rollup.Grain, rollup.Dimension, rollup.Value, rollup.Bucket,
+			rollup.Bids, rollup.Impressions, rollup.Clicks, rollup.Conversions, rollup.Spend,
+			rollup.Revenue, rollup.MeasurableImpressions, rollup.ViewableImpressions,
+		}
+	}
+
+	if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"metric_rollups"}, rollupColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy rollups: %w", err)
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO rollup_files (file_id, user_id, rolled_up_at)
+		VALUES ($1, $2, $3)
+	`, fileID, userID, rolledUpAt)
+	return err
+}
+
+// CountPendingFiles counts a user's processed files that haven't been rolled up
+func (r *PostgresRollupRepository) CountPendingFiles(ctx context.Context, userID string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM files
+		WHERE user_id = $1 AND status = $2
+			AND NOT EXISTS (SELECT 1 FROM rollup_files WHERE rollup_files.file_id = files.id)
+	`
+
+	var count int
+	err := r.db.QueryRow(ctx, query, userID, models.FileStatusProcessed).Scan(&count)
+	return count, err
+}
+
+// ScanRollups calls fn with a user's rollups summed across processed files by value and
+// bucket, ordered by bucket and value and capped at the query's limit
+func (r *PostgresRollupRepository) ScanRollups(ctx context.Context, query ingestion.RollupQuery, fn func(ingestion.Rollup) error) error {
+	sql := `
+		-- name: rollup_series
+		SELECT r.value, r.bucket, SUM(r.bids)::bigint, SUM(r.impressions)::bigint, SUM(r.clicks)::bigint,
+			SUM(r.conversions)::bigint, SUM(r.spend), SUM(r.revenue), SUM(r.measurable_impressions)::bigint,
+			SUM(r.viewable_impressions)::bigint, array_agg(DISTINCT r.file_id)
+		FROM metric_rollups r
+		JOIN files f ON f.id = r.file_id
+		WHERE r.user_id = $1 AND r.dimension = $2 AND r.grain = $3
+			AND ($4 = '' OR r.value = $4)
+			AND ($5::timestamptz IS NULL OR r.bucket >= $5)
+			AND ($6::timestamptz IS NULL OR r.bucket < $6)
+			AND ($7::timestamptz IS NULL OR (r.bucket, r.value) > ($7, $8))
+			AND f.status = $9
+		GROUP BY r.bucket, r.value
+		ORDER BY r.bucket, r.value
+		LIMIT $10
+	`
+
+	rows, err := r.db.Query(ctx, sql,
+		query.UserID, query.Dimension, query.Grain, query.Value, query.From, query.To,
+		query.AfterBucket, query.AfterValue, models.FileStatusProcessed, query.Limit,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rollup := ingestion.Rollup{Grain: query.Grain, Dimension: query.Dimension}
+		if err := rows.Scan(
+			&rollup.Value,
+			&rollup.Bucket,
+			&rollup.Bids,
+			&rollup.Impressions,
+			&rollup.Clicks,
+			&rollup.Conversions,
+			&rollup.Spend,
+			&rollup.Revenue,
+			&rollup.MeasurableImpressions,
+			&rollup.ViewableImpressions,
+			&rollup.FileIDs,
+		); err != nil {
+			return fmt.Errorf("failed to scan rollup: %w", err)
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ScanFileRollups calls fn with every rollup of a user's file, ordered by grain, dimension,
+// bucket and value
+func (r *PostgresRollupRepository) ScanFileRollups(ctx context.Context, fileID, userID string, fn func(ingestion.Rollup) error) error {
+	rows, err := r.db.Query(ctx, `
+		-- name: rollup_file_scan
+		SELECT grain, dimension, value, bucket, bids, impressions, clicks, conversions, spend, revenue,
+			measurable_impressions, viewable_impressions
+		FROM metric_rollups
+		WHERE file_id = $1 AND user_id = $2
+		ORDER BY grain, dimension, bucket, value
+	`, fileID, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rollup ingestion.Rollup
+		if err := rows.Scan(
+			&rollup.Grain,
+			&rollup.Dimension,
+			&rollup.Value,
+			&rollup.Bucket,
+			&rollup.Bids,
+			&rollup.Impressions,
+			&rollup.Clicks,
+			&rollup.Conversions,
+			&rollup.Spend,
+			&rollup.Revenue,
+			&rollup.MeasurableImpressions,
+			&rollup.ViewableImpressions,
+		); err != nil {
+			return fmt.Errorf("failed to scan rollup: %w", err)
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// HasRollups reports whether a user's file has been rolled up
+func (r *PostgresRollupRepository) HasRollups(ctx context.Context, fileID, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM rollup_files WHERE file_id = $1 AND user_id = $2
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, query, fileID, userID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// ListRolledUpSince lists the IDs of a user's processed files rolled up at or after since, such
+// as streamed segments whose rollups were replaced
+func (r *PostgresRollupRepository) ListRolledUpSince(ctx context.Context, userID string, since time.Time) ([]string, error) {
+	query := `
+		SELECT rollup_files.file_id
+		FROM rollup_files
+		JOIN files ON files.id = rollup_files.file_id
+		WHERE rollup_files.user_id = $1 AND rollup_files.rolled_up_at >= $2 AND files.status = $3
+		ORDER BY rollup_files.rolled_up_at, rollup_files.file_id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, since, models.FileStatusProcessed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fileIDs := []string{}
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan rolled up file: %w", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	return fileIDs, rows.Err()
+}
+
+// ScanFileBreakdown calls fn with a file's daily rollups of a dimension summed by value, ordered
+// by spend, highest first, then by value and capped at the query's limit. Spend is summed as
+// numeric and rounded to micros, so the order pages resume from is stable.
+func (r *PostgresRollupRepository) ScanFileBreakdown(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error {
+	sql := `
+		-- name: rollup_file_breakdown
+		SELECT value, SUM(bids)::bigint, SUM(impressions)::bigint, SUM(clicks)::bigint,
+			SUM(conversions)::bigint, ROUND(SUM(spend::numeric), 6)::float8 AS total_spend, SUM(revenue),
+			SUM(measurable_impressions)::bigint, SUM(viewable_impressions)::bigint
+		FROM metric_rollups
+		WHERE file_id = $1 AND user_id = $2 AND dimension = $3 AND grain = $4
+		GROUP BY value
+		HAVING $5::float8 IS NULL
+			OR ROUND(SUM(spend::numeric), 6)::float8 < $5
+			OR (ROUND(SUM(spend::numeric), 6)::float8 = $5 AND value > $6)
+		ORDER BY total_spend DESC, value
+		LIMIT $7
+	`
+
+	rows, err := r.db.Query(ctx, sql,
+		query.FileID, query.UserID, query.Dimension, ingestion.RollupDaily, query.AfterSpend, query.AfterValue, query.Limit,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rollup := ingestion.Rollup{Grain: ingestion.RollupDaily, Dimension: query.Dimension}
+		if err := rows.Scan(
+			&rollup.Value,
+			&rollup.Bids,
+			&rollup.Impressions,
+			&rollup.Clicks,
+			&rollup.Conversions,
+			&rollup.Spend,
+			&rollup.Revenue,
+			&rollup.MeasurableImpressions,
+			&rollup.ViewableImpressions,
+		); err != nil {
+			return fmt.Errorf("failed to scan breakdown: %w", err)
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ScanFileHours calls fn with a file's hourly total rollups in hour order, capped at the query's limit
+func (r *PostgresRollupRepository) ScanFileHours(ctx context.Context, query ingestion.BreakdownQuery, fn func(ingestion.Rollup) error) error {
+	sql := `
+		-- name: rollup_file_hours
+		SELECT bucket, bids, impressions, clicks, conversions, spend, revenue, measurable_impressions,
+			viewable_impressions
+		FROM metric_rollups
+		WHERE file_id = $1 AND user_id = $2 AND dimension = $3 AND grain = $4 AND value = $5
+			AND ($6::timestamptz IS NULL OR bucket > $6)
+		ORDER BY bucket
+		LIMIT $7
+	`
+
+	rows, err := r.db.Query(ctx, sql,
+		query.FileID, query.UserID, ingestion.RollupTotal, ingestion.RollupHourly, ingestion.RollupTotalValue, query.AfterBucket, query.Limit,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rollup := ingestion.Rollup{Grain: ingestion.RollupHourly, Dimension: ingestion.RollupTotal, Value: ingestion.RollupTotalValue}
+		if err := rows.Scan(
+			&rollup.Bucket,
+			&rollup.Bids,
+			&rollup.Impressions,
+			&rollup.Clicks,
+			&rollup.Conversions,
+			&rollup.Spend,
+			&rollup.Revenue,
+			&rollup.MeasurableImpressions,
+			&rollup.ViewableImpressions,
+		); err != nil {
+			return fmt.Errorf("failed to scan hourly total: %w", err)
+		}
+		setRollupRates(&rollup)
+		if err := fn(rollup); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// setRollupRates computes a summed rollup's CTR, ROAS and CPA
+func setRollupRates(rollup *ingestion.Rollup) {
+	if rollup.Impressions > 0 {
+		rollup.CTR = float64(rollup.Clicks) / float64(rollup.Impressions) * 100
+	}
+	if rollup.Spend > 0 {
+		rollup.ROAS = rollup.Revenue / rollup.Spend
+	}
+	if rollup.Conversions > 0 {
+		rollup.CPA = rollup.Spend / float64(rollup.Conversions)
+	}
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresSessionRepository stores users' sessions in PostgreSQL
+type PostgresSessionRepository struct {
+	db DBTX
+}
+
+// NewPostgresSessionRepository creates a new PostgreSQL session repository
+func NewPostgresSessionRepository(db DBTX) *PostgresSessionRepository {
+	return &PostgresSessionRepository{
+		db: db,
+	}
+}
+
+// sessionColumns lists the columns selected for a session, in scan order
+const sessionColumns = `id, user_id, refresh_token_hash, previous_refresh_token_hash, device, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at`
+
+// Create inserts a new session
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (` + sessionColumns + `)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		session.ID,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.PreviousRefreshTokenHash,
+		session.Device,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+	)
+
+	return err
+}
+
+// FindByID finds a user's session
+func (r *PostgresSessionRepository) FindByID(ctx context.Context, id, userID string) (*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	session, err := scanSession(r.db.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return session, err
+}
+
+// FindByRefreshTokenHash finds the session whose refresh token, or the token it replaced, has a hash
+func (r *PostgresSessionRepository) FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE refresh_token_hash = $1 OR previous_refresh_token_hash = $1
+		LIMIT 1
+	`
+
+	session, err := scanSession(r.db.QueryRow(ctx, query, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return session, err
+}
+
+// ListActive lists a user's sessions that are neither revoked nor expired, most recently seen first
+func (r *PostgresSessionRepository) ListActive(ctx context.Context, userID string, now time.Time) ([]*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records that a session was used
+func (r *PostgresSessionRepository) Touch(ctx context.Context, id string, seenAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = $2
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(ctx, query, id, seenAt)
+	return err
+}
+
+// RotateRefreshToken replaces an active session's refresh token, returning ErrNotFound when the
+// token was already replaced
+func (r *PostgresSessionRepository) RotateRefreshToken(ctx context.Context, id, hash, newHash string) error {
+	query := `
+		UPDATE sessions
+		SET refresh_token_hash = $3, previous_refresh_token_hash = refresh_token_hash
+		WHERE id = $1 AND refresh_token_hash = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, hash, newHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Revoke revokes a user's active session
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, id, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := r.db.Exec(ctx, query, id, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired removes sessions that expired before the cutoff
+func (r *PostgresSessionRepository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM sessions
+		WHERE expires_at < $1
+	`
+
+	tag, err := r.db.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// scanSession scans a row selected with sessionColumns
+func scanSession(row pgx.Row) (*models.Session, error) {
+	session := &models.Session{}
+	var previousRefreshTokenHash *string
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenHash,
+		&previousRefreshTokenHash,
+		&session.Device,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if previousRefreshTokenHash != nil {
+		session.PreviousRefreshTokenHash = *previousRefreshTokenHash
+	}
+
+	return session, err
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresUsageRepository stores orgs' monthly usage in PostgreSQL
+type PostgresUsageRepository struct {
+	db DBTX
+}
+
+// NewPostgresUsageRepository creates a new PostgreSQL usage repository
+func NewPostgresUsageRepository(db DBTX) *PostgresUsageRepository {
+	return &PostgresUsageRepository{
+		db: db,
+	}
+}
+
+// Add adds API calls and ingested rows to an org's month, so every server's counts are summed.
+// It returns ErrNotFound when the org doesn't exist.
+func (r *PostgresUsageRepository) Add(ctx context.Context, orgID string, period time.Time, apiCalls, rowsIngested int64) error {
+	query := `
+		INSERT INTO org_usage (org_id, period, api_calls, rows_ingested)
+		SELECT id, $2, $3, $4 FROM organizations WHERE id = $1
+		ON CONFLICT (org_id, period) DO UPDATE
+		SET api_calls = org_usage.api_calls + EXCLUDED.api_calls,
+			rows_ingested = org_usage.rows_ingested + EXCLUDED.rows_ingested
+	`
+
+	tag, err := r.db.Exec(ctx, query, orgID, period, apiCalls, rowsIngested)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Get returns an org's usage in a month, which is zero when nothing has been metered
+func (r *PostgresUsageRepository) Get(ctx context.Context, orgID string, period time.Time) (*models.OrgUsage, error) {
+	query := `
+		SELECT org_id, period, api_calls, rows_ingested
+		FROM org_usage
+		WHERE org_id = $1 AND period = $2
+	`
+
+	usage, err := scanOrgUsage(r.db.QueryRow(ctx, query, orgID, period))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.OrgUsage{OrgID: orgID, Period: period}, nil
+	}
+
+	return usage, err
+}
+
+// ListSince lists an org's metered months from since onwards, newest first
+func (r *PostgresUsageRepository) ListSince(ctx context.Context, orgID string, since time.Time) ([]*models.OrgUsage, error) {
+	query := `
+		SELECT org_id, period, api_calls, rows_ingested
+		FROM org_usage
+		WHERE org_id = $1 AND period >= $2
+		ORDER BY period DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []*models.OrgUsage{}
+	for rows.Next() {
+		usage, err := scanOrgUsage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		months = append(months, usage)
+	}
+
+	return months, rows.Err()
+}
+
+// StorageBytes returns the total size of the files an org's users have stored
+func (r *PostgresUsageRepository) StorageBytes(ctx context.Context, orgID string) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(f.file_size), 0)
+		FROM files f
+		JOIN users u ON u.id = f.user_id
+		WHERE u.org_id = $1
+	`
+
+	var total int64
+	err := r.db.QueryRow(ctx, query, orgID).Scan(&total)
+	return total, err
+}
+
+// scanOrgUsage scans a month of usage
+func scanOrgUsage(row pgx.Row) (*models.OrgUsage, error) {
+	usage := &models.OrgUsage{}
+	err := row.Scan(
+		&usage.OrgID,
+		&usage.Period,
+		&usage.APICalls,
+		&usage.RowsIngested,
+	)
+	usage.Period = usage.Period.UTC()
+
+	return usage, err
+}
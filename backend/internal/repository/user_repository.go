@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresUserRepository stores users in PostgreSQL
+type PostgresUserRepository struct {
+	db DBTX
+}
+
+// NewPostgresUserRepository creates a new PostgreSQL user repository
+func NewPostgresUserRepository(db DBTX) *PostgresUserRepository {
+	return &PostgresUserRepository{
+		db: db,
+	}
+}
+
+// userColumns lists the columns selected for a user, in scan order
+const userColumns = `id, org_id, email, password, first_name, last_name, company, role, timezone, locale, avatar_id, created_at, updated_at`
+
+// Create inserts a new user, creating the user's organization, named after the user, if it doesn't exist
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+		WITH org AS (
+			INSERT INTO organizations (id, name, created_at)
+			VALUES ($2, $5::text || ' ' || $6::text, $7)
+			ON CONFLICT (id) DO NOTHING
+		)
+		INSERT INTO users (id, org_id, email, password, first_name, last_name, company, role, timezone, locale, avatar_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $9, $10, $11, $12, NULLIF($13, ''), $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		user.ID,
+		user.OrgID,
+		user.Email,
+		user.Password,
+		user.FirstName,
+		user.LastName,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.Company,
+		user.Role,
+		user.Timezone,
+		user.Locale,
+		user.AvatarID,
+	)
+
+	return err
+}
+
+// FindByID finds a user by ID
+func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	return scanUser(r.db.QueryRow(ctx, query, id))
+}
+
+// ListIDsByOrg lists the IDs of an org's users, for reports across all of their files
+func (r *PostgresUserRepository) ListIDsByOrg(ctx context.Context, orgID string) ([]string, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE org_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan user ID: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// FindByEmail finds a user by email
+func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+
+	return scanUser(r.db.QueryRow(ctx, query, email))
+}
+
+// ExistsByEmail checks if a user with the given email exists
+func (r *PostgresUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	query := `
+		SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)
+	`
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, query, email).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Update updates an existing user
+func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET email = $2, password = $3, first_name = $4, last_name = $5, updated_at = $6,
+			company = $7, role = $8, timezone = $9, locale = $10, avatar_id = NULLIF($11, '')
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		user.ID,
+		user.Email,
+		user.Password,
+		user.FirstName,
+		user.LastName,
+		user.UpdatedAt,
+		user.Company,
+		user.Role,
+		user.Timezone,
+		user.Locale,
+		user.AvatarID,
+	)
+
+	return err
+}
+
+// scanUser scans a single user row
+func scanUser(row pgx.Row) (*models.User, error) {
+	user := &models.User{}
+	var avatarID *string
+	err := row.Scan(
+		&user.ID,
+		&user.OrgID,
+		&user.Email,
+		&user.Password,
+		&user.FirstName,
+		&user.LastName,
+		&user.Company,
+		&user.Role,
+		&user.Timezone,
+		&user.Locale,
+		&avatarID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if avatarID != nil {
+		user.AvatarID = *avatarID
+	}
+
+	return user, nil
+}
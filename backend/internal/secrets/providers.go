@@ -0,0 +1,273 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager using credentials from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+type AWSProvider struct {
+	client *http.Client
+	region string
+}
+
+// NewAWSProvider creates a provider for AWS Secrets Manager
+func NewAWSProvider(client *http.Client, region string) *AWSProvider {
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &AWSProvider{
+		client: client,
+		region: region,
+	}
+}
+
+// Fetch reads the current version of a secret string
+func (p *AWSProvider) Fetch(ctx context.Context, name string) (map[string]string, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+
+	host := fmt.Sprintf("secretsmanager.%s.amazonaws.com", p.region)
+	payload, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, payload, host, p.region, "secretsmanager", accessKey, secretKey, time.Now().UTC())
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doJSON(p.client, req, &response); err != nil {
+		return nil, err
+	}
+
+	return decodeSecretString([]byte(response.SecretString))
+}
+
+// signAWSRequest adds an AWS Signature Version 4 Authorization header to a request
+func signAWSRequest(req *http.Request, payload []byte, host, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// Canonical headers must be lowercase and sorted
+	names := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if req.Header.Get("X-Amz-Security-Token") != "" {
+		names = append(names, "x-amz-security-token")
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes an HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// GCPProvider reads secrets from Google Cloud Secret Manager. It authenticates with the
+// GCP_ACCESS_TOKEN environment variable when set, and otherwise with the metadata server.
+type GCPProvider struct {
+	client  *http.Client
+	project string
+}
+
+// NewGCPProvider creates a provider for Google Cloud Secret Manager
+func NewGCPProvider(client *http.Client, project string) *GCPProvider {
+	return &GCPProvider{
+		client:  client,
+		project: project,
+	}
+}
+
+// Fetch reads the latest version of a secret
+func (p *GCPProvider) Fetch(ctx context.Context, name string) (map[string]string, error) {
+	if p.project == "" {
+		return nil, fmt.Errorf("GCP_PROJECT is required")
+	}
+
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("https://secretmanager.googleapis.com/v1/projects/%s/secrets/%s/versions/latest:access",
+		url.PathEscape(p.project), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var response struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(p.client, req, &response); err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret payload: %w", err)
+	}
+
+	return decodeSecretString(data)
+}
+
+// accessToken returns an OAuth access token for the Secret Manager API
+func (p *GCPProvider) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GCP_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(p.client, req, &response); err != nil {
+		return "", fmt.Errorf("failed to get access token from metadata server: %w", err)
+	}
+
+	return response.AccessToken, nil
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 engine
+type VaultProvider struct {
+	client *http.Client
+	addr   string
+	token  string
+	mount  string
+}
+
+// NewVaultProvider creates a provider for a Vault KV version 2 engine
+func NewVaultProvider(client *http.Client, addr, token, mount string) *VaultProvider {
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return &VaultProvider{
+		client: client,
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		mount:  strings.Trim(mount, "/"),
+	}
+}
+
+// Fetch reads the latest version of a secret
+func (p *VaultProvider) Fetch(ctx context.Context, name string) (map[string]string, error) {
+	if p.addr == "" || p.token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, strings.Trim(name, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	var response struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := doJSON(p.client, req, &response); err != nil {
+		return nil, err
+	}
+
+	return stringValues(response.Data.Data), nil
+}
+
+// doJSON sends a request and decodes the JSON response
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("secrets backend returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/config"
+)
+
+// Keys read from the secret; they match the environment variables they replace
+const (
+	KeyDBUser     = "DB_USER"
+	KeyDBPassword = "DB_PASSWORD"
+	KeyJWTSecret  = "JWT_SECRET"
+
+	KeyGoogleClientSecret        = "GOOGLE_OAUTH_CLIENT_SECRET"
+	KeyGoogleAdsDeveloperToken   = "GOOGLE_ADS_DEVELOPER_TOKEN"
+	KeyIntegrationsEncryptionKey = "INTEGRATIONS_ENCRYPTION_KEY"
+)
+
+// Provider fetches a secret holding a JSON object of string values
+type Provider interface {
+	Fetch(ctx context.Context, name string) (map[string]string, error)
+}
+
+// Store holds the latest values of a secret and refreshes them in the background
+type Store struct {
+	provider Provider
+	name     string
+	values   atomic.Pointer[map[string]string]
+}
+
+// NewStore creates a secret store from configuration.
+// It returns nil when no secrets backend is configured.
+func NewStore(cfg config.SecretsConfig) (*Store, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("secrets provider %s requires SECRETS_NAME", cfg.Provider)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var provider Provider
+	switch cfg.Provider {
+	case "aws":
+		provider = NewAWSProvider(client, cfg.AWSRegion)
+	case "gcp":
+		provider = NewGCPProvider(client, cfg.GCPProject)
+	case "vault":
+		provider = NewVaultProvider(client, cfg.VaultAddr, cfg.VaultToken, cfg.VaultMount)
+	default:
+		return nil, fmt.Errorf("unknown secrets provider: %s", cfg.Provider)
+	}
+
+	return NewStoreWithProvider(provider, cfg.Name), nil
+}
+
+// NewStoreWithProvider creates a secret store backed by the given provider
+func NewStoreWithProvider(provider Provider, name string) *Store {
+	return &Store{provider: provider, name: name}
+}
+
+// LoadInto creates a store from cfg.Secrets, loads the secret, and overrides the configured
+// credentials with its values. It returns nil when no secrets backend is configured.
+func LoadInto(ctx context.Context, cfg *config.Config) (*Store, error) {
+	store, err := NewStore(cfg.Secrets)
+	if err != nil || store == nil {
+		return nil, err
+	}
+
+	if err := store.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg.Database.User = store.Get(KeyDBUser, cfg.Database.User)
+	cfg.Database.Password = store.Get(KeyDBPassword, cfg.Database.Password)
+	cfg.JWT.Secret = store.Get(KeyJWTSecret, cfg.JWT.Secret)
+	cfg.Google.ClientSecret = store.Get(KeyGoogleClientSecret, cfg.Google.ClientSecret)
+	cfg.Google.AdsDeveloperToken = store.Get(KeyGoogleAdsDeveloperToken, cfg.Google.AdsDeveloperToken)
+	cfg.Integrations.EncryptionKey = store.Get(KeyIntegrationsEncryptionKey, cfg.Integrations.EncryptionKey)
+
+	return store, nil
+}
+
+// Refresh fetches the latest values of the secret
+func (s *Store) Refresh(ctx context.Context) error {
+	values, err := s.provider.Fetch(ctx, s.name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch secret %s: %w", s.name, err)
+	}
+
+	s.values.Store(&values)
+	return nil
+}
+
+// Get returns the current value of a key, or the fallback when the secret doesn't set it
+func (s *Store) Get(key, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+
+	values := s.values.Load()
+	if values == nil {
+		return fallback
+	}
+	if value, ok := (*values)[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Run refreshes the secret at every interval until the context is canceled.
+// Failed refreshes keep the previous values.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				slog.Error("Failed to refresh secrets", "error", err)
+			}
+		}
+	}
+}
+
+// decodeSecretString decodes a secret payload holding a JSON object
+func decodeSecretString(payload []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+
+	return stringValues(raw), nil
+}
+
+// stringValues converts decoded JSON values to strings
+func stringValues(raw map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+// Analysis bundle format
+const (
+	BundleFormat        = "advantage-analysis"
+	BundleFormatVersion = 1
+)
+
+// Analysis bundle entries
+const (
+	bundleManifest    = "manifest.json"
+	bundleAnalysis    = "analysis.json"
+	bundleRollups     = "rollups.json"
+	bundleCharts      = "charts.json"
+	bundleDataQuality = "data_quality.json"
+)
+
+// ErrInvalidBundle is returned when an uploaded file isn't a readable analysis bundle
+var ErrInvalidBundle = errors.New("invalid analysis bundle")
+
+// BundleManifest describes an analysis bundle and checksums its entries, so an import can tell
+// a bundle that was damaged or edited in transit
+type BundleManifest struct {
+	Format          string        `json:"format"`
+	FormatVersion   int           `json:"formatVersion"`
+	ExportedAt      time.Time     `json:"exportedAt"`
+	FileID          string        `json:"fileId"`
+	FileName        string        `json:"fileName"`
+	Source          string        `json:"source,omitempty"`
+	ProcessedAt     time.Time     `json:"processedAt"`
+	AnalysisVersion int           `json:"analysisVersion"`
+	Entries         []BundleEntry `json:"entries"`
+}
+
+// BundleEntry is one file of an analysis bundle
+type BundleEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BundleCharts holds the series the analysis charts are drawn from
+type BundleCharts struct {
+	Daily      []ChartDay                           `json:"daily"`
+	Hourly     map[string]int                       `json:"hourly"`
+	Dayparting *ingestion.DaypartingGrid            `json:"dayparting,omitempty"`
+	Devices    map[string]int                       `json:"devices"`
+	Geo        map[string]int                       `json:"geo"`
+	Domains    map[string]int                       `json:"domains"`
+	Campaigns  map[string]ingestion.CampaignMetrics `json:"campaigns"`
+}
+
+// ChartDay is a day of delivery summed across campaigns
+type ChartDay struct {
+	Date string `json:"date"`
+	ingestion.CampaignMetrics
+}
+
+// DataQualityReport collects what is known about the quality of the analyzed log
+type DataQualityReport struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	Incomplete   bool   `json:"incomplete"`
+	TotalRecords int    `json:"totalRecords"`
+	// FieldCoverage is the percentage of records with a value for each canonical column
+	FieldCoverage       map[string]float64                `json:"fieldCoverage"`
+	Columns             map[string]*ingestion.ColumnStats `json:"columns,omitempty"`
+	SchemaDrift         *ingestion.SchemaDrift            `json:"schemaDrift,omitempty"`
+	Warnings            []string                          `json:"warnings,omitempty"`
+	TruncatedBreakdowns map[string]int                    `json:"truncatedBreakdowns,omitempty"`
+}
+
+// BundleService exports processed files' analyses as portable ZIP bundles and imports them,
+// so analyses can move between environments or be archived offline
+type BundleService struct {
+	fileStorage  *storage.FileStorage
+	fileService  *FileService
+	logProcessor *ingestion.LogProcessorService
+	resultCache  *ResultCache
+	files        repository.FileRepository
+	rollups      repository.RollupRepository
+	uow          repository.UnitOfWork
+}
+
+// NewBundleService creates a new bundle service
+func NewBundleService(fileStorage *storage.FileStorage, fileService *FileService, logProcessor *ingestion.LogProcessorService, resultCache *ResultCache,
+	repos repository.Repositories, uow repository.UnitOfWork) *BundleService {
+	return &BundleService{
+		fileStorage:  fileStorage,
+		fileService:  fileService,
+		logProcessor: logProcessor,
+		resultCache:  resultCache,
+		files:        repos.Files,
+		rollups:      repos.Rollups,
+		uow:          uow,
+	}
+}
+
+// ExportBundle writes a processed file's current analysis as a ZIP bundle: the analysis with
+// its summary, the file's rollups, chart series and a data quality report, with a manifest first
+func (s *BundleService) ExportBundle(ctx context.Context, fileID, userID string, w io.Writer) error {
+	file, err := s.files.FindByID(ctx, fileID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrFileNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find file: %w", err)
+	}
+
+	result, err := s.logProcessor.GetAnalysisResult(ctx, fileID, userID)
+	if err != nil {
+		return err
+	}
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		return err
+	}
+
+	rollups := []ingestion.Rollup{}
+	err = s.rollups.ScanFileRollups(ctx, fileID, userID, func(rollup ingestion.Rollup) error {
+		rollups = append(rollups, rollup)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read rollups: %w", err)
+	}
+
+	entries := []struct {
+		name  string
+		value any
+	}{
+		{bundleAnalysis, result},
+		{bundleRollups, rollups},
+		{bundleCharts, bundleChartsFor(summary)},
+		{bundleDataQuality, dataQualityFor(result, summary)},
+	}
+
+	manifest := BundleManifest{
+		Format:          BundleFormat,
+		FormatVersion:   BundleFormatVersion,
+		ExportedAt:      time.Now().UTC(),
+		FileID:          fileID,
+		FileName:        file.FileName,
+		Source:          summary.Source,
+		ProcessedAt:     result.ProcessedAt,
+		AnalysisVersion: result.Version,
+	}
+	contents := make([][]byte, len(entries))
+	for i, entry := range entries {
+		data, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize %s: %w", entry.name, err)
+		}
+		sum := sha256.Sum256(data)
+		manifest.Entries = append(manifest.Entries, BundleEntry{Name: entry.name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		contents[i] = data
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+
+	archive := zip.NewWriter(w)
+	write := func(name string, data []byte) error {
+		part, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(data)
+		return err
+	}
+	if err := write(bundleManifest, manifestData); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if err := write(entry.name, contents[i]); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// ImportBundle imports an analysis bundle as a new processed file of the user's, with the
+// bundle's analysis and rollups. The bundle itself is kept as the file's contents, so the
+// imported file can't be reprocessed; it stands in for a log that isn't available here.
+func (s *BundleService) ImportBundle(ctx context.Context, bundle io.ReaderAt, size int64, userID string) (*FileUploadInfo, error) {
+	if size > s.fileService.MaxUploadSize() {
+		return nil, storage.ErrFileTooLarge
+	}
+
+	manifest, result, rollups, err := readBundle(bundle, size, s.fileService.DecompressionLimits().Budget(size))
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(path.Base(manifest.FileName), path.Ext(manifest.FileName)) + ".zip"
+	fileInfo, err := s.fileStorage.StoreFile(io.NewSectionReader(bundle, 0, size), name, "application/zip", userID, s.fileService.MaxUploadSize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to store bundle: %w", err)
+	}
+
+	now := time.Now()
+	result.FileID = fileInfo.ID
+	result.UserID = userID
+	result.FileName = manifest.FileName
+	result.Warnings = append(result.Warnings, fmt.Sprintf("Imported from a bundle of file %s exported %s", manifest.FileID, manifest.ExportedAt.Format(time.RFC3339)))
+
+	err = s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Files.Create(ctx, &models.File{
+			ID:         fileInfo.ID,
+			UserID:     userID,
+			FileName:   manifest.FileName,
+			FileSize:   fileInfo.FileSize,
+			FileType:   fileInfo.FileType,
+			FilePath:   fileInfo.FilePath,
+			Status:     models.FileStatusProcessed,
+			UploadedAt: fileInfo.UploadedAt,
+			UpdatedAt:  now,
+		}); err != nil {
+			return fmt.Errorf("failed to save file metadata: %w", err)
+		}
+		if len(rollups) > 0 {
+			if err := repos.Rollups.InsertRollups(ctx, fileInfo.ID, userID, now, rollups); err != nil {
+				return fmt.Errorf("failed to store rollups: %w", err)
+			}
+		}
+
+		// The analysis is written last, so a failure before it leaves nothing to clean up but the bundle
+		return s.logProcessor.SaveAnalysisResult(result)
+	})
+	if err != nil {
+		_ = s.fileStorage.DeleteFile(fileInfo.ID, userID)
+		return nil, err
+	}
+
+	// Cached cross-file results are stale once the imported analysis exists
+	s.resultCache.InvalidateFile(ctx, userID, fileInfo.ID)
+
+	return &FileUploadInfo{
+		ID:         fileInfo.ID,
+		FileName:   manifest.FileName,
+		FileSize:   fileInfo.FileSize,
+		FileType:   fileInfo.FileType,
+		UploadedAt: fileInfo.UploadedAt,
+		Status:     models.FileStatusProcessed,
+	}, nil
+}
+
+// readBundle reads and verifies a bundle's manifest, analysis and rollups, decompressing no more
+// than the budget allows
+func readBundle(bundle io.ReaderAt, size int64, budget *storage.DecompressionBudget) (*BundleManifest, *ingestion.LogAnalysisResult, []ingestion.Rollup, error) {
+	archive, err := zip.NewReader(bundle, size)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	read := func(name string, limit int64) ([]byte, error) {
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, name)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, name, err)
+		}
+		defer reader.Close()
+
+		// Entries are read at most one byte past their declared size, so a bundle can't
+		// decompress into more than it claims
+		data, err := io.ReadAll(io.LimitReader(budget.Reader(reader), limit+1))
+		if errors.Is(err, storage.ErrDecompressionLimit) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, name, err)
+		}
+		return data, nil
+	}
+
+	data, err := read(bundleManifest, 1<<20)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: malformed manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest.Format != BundleFormat {
+		return nil, nil, nil, fmt.Errorf("%w: not an analysis bundle", ErrInvalidBundle)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > BundleFormatVersion {
+		return nil, nil, nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidBundle, manifest.FormatVersion)
+	}
+
+	// Entries are read no further than the manifest says they go, so a manifest declaring more
+	// than the budget is refused before reading them
+	var declared uint64
+	for _, entry := range manifest.Entries {
+		if entry.Size < 0 {
+			return nil, nil, nil, fmt.Errorf("%w: %s has a negative size", ErrInvalidBundle, entry.Name)
+		}
+		declared += uint64(entry.Size)
+	}
+	if err := budget.Check(declared); err != nil {
+		return nil, nil, nil, err
+	}
+
+	contents := make(map[string][]byte, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		data, err := read(entry.Name, entry.Size)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return nil, nil, nil, fmt.Errorf("%w: %s doesn't match its checksum", ErrInvalidBundle, entry.Name)
+		}
+		contents[entry.Name] = data
+	}
+
+	var result ingestion.LogAnalysisResult
+	if data, ok := contents[bundleAnalysis]; !ok {
+		return nil, nil, nil, fmt.Errorf("%w: manifest doesn't list %s", ErrInvalidBundle, bundleAnalysis)
+	} else if err := json.Unmarshal(data, &result); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: malformed %s: %v", ErrInvalidBundle, bundleAnalysis, err)
+	}
+	if result.Status != "completed" {
+		return nil, nil, nil, fmt.Errorf("%w: only completed analyses can be imported", ErrInvalidBundle)
+	}
+
+	// Bundles without rollups import fine; like files processed before rollups existed, the file
+	// is then counted as pending by cross-file reports
+	rollups := []ingestion.Rollup{}
+	if data, ok := contents[bundleRollups]; ok {
+		if err := json.Unmarshal(data, &rollups); err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: malformed %s: %v", ErrInvalidBundle, bundleRollups, err)
+		}
+		for _, rollup := range rollups {
+			if !ingestion.IsRollupGrain(rollup.Grain) || !ingestion.IsRollupDimension(rollup.Dimension) {
+				return nil, nil, nil, fmt.Errorf("%w: %s has a rollup of unknown grain or dimension", ErrInvalidBundle, bundleRollups)
+			}
+		}
+	}
+
+	return &manifest, &result, rollups, nil
+}
+
+// bundleChartsFor collects the chart series of a summary
+func bundleChartsFor(summary *ingestion.BeeswaxLogSummary) BundleCharts {
+	days := make(map[string]*ChartDay)
+	for _, campaignDays := range summary.CampaignDaily {
+		for date, metrics := range campaignDays {
+			day, ok := days[date]
+			if !ok {
+				day = &ChartDay{Date: date}
+				days[date] = day
+			}
+			day.Add(metrics)
+		}
+	}
+	daily := make([]ChartDay, 0, len(days))
+	for _, day := range days {
+		daily = append(daily, *day)
+	}
+	sort.Slice(daily, func(i, j int) bool {
+		return daily[i].Date < daily[j].Date
+	})
+
+	return BundleCharts{
+		Daily:      daily,
+		Hourly:     summary.HourlyBreakdown,
+		Dayparting: summary.Dayparting,
+		Devices:    summary.DeviceBreakdown,
+		Geo:        summary.GeoBreakdown,
+		Domains:    summary.DomainBreakdown,
+		Campaigns:  summary.CampaignPerformance,
+	}
+}
+
+// dataQualityFor collects the data quality findings of an analysis
+func dataQualityFor(result *ingestion.LogAnalysisResult, summary *ingestion.BeeswaxLogSummary) DataQualityReport {
+	return DataQualityReport{
+		Status:              result.Status,
+		ErrorMessage:        result.ErrorMessage,
+		Incomplete:          result.Incomplete,
+		TotalRecords:        summary.TotalRecords,
+		FieldCoverage:       summary.FieldCoverage,
+		Columns:             summary.Columns,
+		SchemaDrift:         result.SchemaDrift,
+		Warnings:            result.Warnings,
+		TruncatedBreakdowns: summary.TruncatedBreakdowns,
+	}
+}
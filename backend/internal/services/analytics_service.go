@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/adstxt"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// ErrReportUnavailable is returned when a file's log does not contain the columns a report needs
+var ErrReportUnavailable = errors.New("report unavailable: the log file does not contain the required columns")
+
+// AnalyticsService handles analytical reports built from a processed log file
+type AnalyticsService struct {
+	logProcessor        *ingestion.LogProcessorService
+	resultCache         *ResultCache
+	supplyValidator     *adstxt.Validator
+	maxValidatedDomains int
+}
+
+// NewAnalyticsService creates a new analytics service
+func NewAnalyticsService(logProcessor *ingestion.LogProcessorService, resultCache *ResultCache) *AnalyticsService {
+	return &AnalyticsService{
+		logProcessor: logProcessor,
+		resultCache:  resultCache,
+	}
+}
+
+// GetFunnel builds the delivery funnel for a processed file, optionally segmented by a dimension
+func (s *AnalyticsService) GetFunnel(ctx context.Context, fileID, userID, dimension string) (*ingestion.FunnelReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ingestion.BuildFunnelReport(fileID, summary, dimension)
+}
+
+// getSummary loads the parsed summary of a completed analysis, from the cache when possible
+func (s *AnalyticsService) getSummary(ctx context.Context, fileID, userID string) (*ingestion.BeeswaxLogSummary, error) {
+	return cached(ctx, s.resultCache, fileKey(userID, fileID, "summary"), func() (*ingestion.BeeswaxLogSummary, error) {
+		return s.loadSummary(ctx, fileID, userID)
+	})
+}
+
+// loadSummary reads the parsed summary of a completed analysis from storage
+func (s *AnalyticsService) loadSummary(ctx context.Context, fileID, userID string) (*ingestion.BeeswaxLogSummary, error) {
+	result, err := s.logProcessor.GetAnalysisResult(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Status != "completed" {
+		return nil, fmt.Errorf("analysis for file ID %s is not completed", fileID)
+	}
+
+	return result.BeeswaxSummary()
+}
+
+// GetSupplyPath builds the supply path report for a processed file
+func (s *AnalyticsService) GetSupplyPath(ctx context.Context, fileID, userID string) (*ingestion.SupplyPathReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.SupplyPath == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildSupplyPathReport(fileID, summary.SupplyPath), nil
+}
+
+// GetBidEfficiency builds the bid efficiency report for a processed OpenRTB bid log
+func (s *AnalyticsService) GetBidEfficiency(ctx context.Context, fileID, userID string) (*ingestion.BidEfficiencyReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.BidEfficiency == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildBidEfficiencyReport(fileID, summary.BidEfficiency), nil
+}
+
+// GetPrices builds the price percentiles report for a processed file
+func (s *AnalyticsService) GetPrices(ctx context.Context, fileID, userID string) (*ingestion.PriceReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Prices == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildPriceReport(fileID, summary.Prices), nil
+}
+
+// GetPriceFloors builds the price floor report for a processed file
+func (s *AnalyticsService) GetPriceFloors(ctx context.Context, fileID, userID string) (*ingestion.PriceFloorReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.PriceFloors == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildPriceFloorReport(fileID, summary.PriceFloors), nil
+}
+
+// GetCreatives builds the creative performance report for a processed file
+func (s *AnalyticsService) GetCreatives(ctx context.Context, fileID, userID string) (*ingestion.CreativeReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(summary.CreativePerformance) == 0 {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildCreativeReport(fileID, summary), nil
+}
+
+// GetPrebid builds the bidder adapter report for a processed Prebid Server analytics log
+func (s *AnalyticsService) GetPrebid(ctx context.Context, fileID, userID string) (*ingestion.PrebidReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Prebid == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildPrebidReport(fileID, summary.Prebid), nil
+}
+
+// GetContentCategories builds the content category report for a processed file. Files
+// processed before categories were introduced need reprocessing.
+func (s *AnalyticsService) GetContentCategories(ctx context.Context, fileID, userID string) (*ingestion.ContentCategoryReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.ContentCategories == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildContentCategoryReport(fileID, summary), nil
+}
+
+// GetDayparting builds the day-of-week × hour-of-day heatmap report for a processed file
+func (s *AnalyticsService) GetDayparting(ctx context.Context, fileID, userID string) (*ingestion.DaypartingReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Dayparting == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildDaypartingReport(fileID, summary.Dayparting), nil
+}
+
+// GetGeo builds one level of the country → region → city drill-down for a processed file
+func (s *AnalyticsService) GetGeo(ctx context.Context, fileID, userID, country, region string) (*ingestion.GeoReport, error) {
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Geo == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	return ingestion.BuildGeoReport(fileID, summary.Geo, country, region)
+}
+
+// GetBenchmarks benchmarks a processed file's campaigns against the user's current history
+func (s *AnalyticsService) GetBenchmarks(ctx context.Context, fileID, userID string) ([]ingestion.CampaignBenchmark, error) {
+	// Benchmarks depend on every file of the user, so they are cached under the user
+	return cached(ctx, s.resultCache, userKey(userID, "benchmarks", fileID), func() ([]ingestion.CampaignBenchmark, error) {
+		summary, err := s.getSummary(ctx, fileID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.logProcessor.BenchmarkAgainstHistory(ctx, summary, fileID, userID)
+	})
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// maxAnnotationNoteLength is the most characters an annotation's note may have
+const maxAnnotationNoteLength = 1000
+
+// Campaign annotation errors
+var (
+	ErrInvalidAnnotation  = errors.New("invalid annotation")
+	ErrAnnotationNotFound = errors.New("annotation not found")
+)
+
+// AnnotationService manages the events users record against their campaigns, such as creative
+// swaps and budget changes, which campaign rollups and time series return alongside delivery
+type AnnotationService struct {
+	annotations repository.CampaignAnnotationRepository
+}
+
+// NewAnnotationService creates a new annotation service
+func NewAnnotationService(repos repository.Repositories) *AnnotationService {
+	return &AnnotationService{
+		annotations: repos.Annotations,
+	}
+}
+
+// ListAnnotations lists a user's annotations of a campaign, or of every campaign when campaignID
+// is empty, optionally limited to a date range, in the order they occurred
+func (s *AnnotationService) ListAnnotations(ctx context.Context, userID, campaignID string, from, to *time.Time) ([]ingestion.CampaignAnnotation, error) {
+	annotations, err := s.annotations.ListAnnotations(ctx, userID, campaignID, from, dayAfter(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// AnnotationsByCampaign returns a user's annotations within a date range by campaign ID
+func (s *AnnotationService) AnnotationsByCampaign(ctx context.Context, userID string, from, to *time.Time) (map[string][]ingestion.CampaignAnnotation, error) {
+	annotations, err := s.ListAnnotations(ctx, userID, "", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byCampaign := make(map[string][]ingestion.CampaignAnnotation)
+	for _, annotation := range annotations {
+		byCampaign[annotation.CampaignID] = append(byCampaign[annotation.CampaignID], annotation)
+	}
+	return byCampaign, nil
+}
+
+// AddAnnotation validates and records an annotation of one of a user's campaigns
+func (s *AnnotationService) AddAnnotation(ctx context.Context, userID string, annotation *ingestion.CampaignAnnotation) error {
+	annotation.CampaignID = strings.TrimSpace(annotation.CampaignID)
+	annotation.Note = strings.TrimSpace(annotation.Note)
+	if annotation.CampaignID == "" {
+		return fmt.Errorf("%w: campaign ID is required", ErrInvalidAnnotation)
+	}
+	if !slices.Contains(ingestion.AnnotationTypes, annotation.Type) {
+		return fmt.Errorf("%w: type must be one of %s", ErrInvalidAnnotation, strings.Join(ingestion.AnnotationTypes, ", "))
+	}
+	if annotation.Type == ingestion.AnnotationOther && annotation.Note == "" {
+		return fmt.Errorf("%w: a note is required for other events", ErrInvalidAnnotation)
+	}
+	if utf8.RuneCountInString(annotation.Note) > maxAnnotationNoteLength {
+		return fmt.Errorf("%w: note can't be longer than %d characters", ErrInvalidAnnotation, maxAnnotationNoteLength)
+	}
+	if annotation.OccurredAt.IsZero() {
+		return fmt.Errorf("%w: occurredAt is required", ErrInvalidAnnotation)
+	}
+
+	annotation.ID = uuid.New().String()
+	annotation.OccurredAt = annotation.OccurredAt.UTC()
+	annotation.CreatedAt = time.Now()
+	if err := s.annotations.CreateAnnotation(ctx, userID, annotation); err != nil {
+		return fmt.Errorf("failed to create annotation: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnnotation removes an annotation of one of a user's campaigns
+func (s *AnnotationService) DeleteAnnotation(ctx context.Context, userID, campaignID, id string) error {
+	err := s.annotations.DeleteAnnotation(ctx, userID, campaignID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAnnotationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete annotation: %w", err)
+	}
+
+	return nil
+}
+
+// ApplyAnnotations adds the campaign's annotations within the rollup's dates to a campaign rollup
+func (s *AnnotationService) ApplyAnnotations(ctx context.Context, userID string, rollup *ingestion.CampaignRollup) error {
+	annotations, err := s.ListAnnotations(ctx, userID, rollup.CampaignID, rollup.From, rollup.To)
+	if err != nil {
+		return err
+	}
+	rollup.Annotations = annotations
+	return nil
+}
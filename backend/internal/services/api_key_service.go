@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// API key errors
+var (
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+	// ErrInvalidAPIKey is returned for unknown and revoked keys alike
+	ErrInvalidAPIKey = errors.New("invalid or revoked API key")
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognize and scan for
+const apiKeyPrefix = "adv_"
+
+// apiKeyTouchInterval is how stale a key's last use may get before it's recorded again, so a
+// BI tool paging through a feed doesn't write on every request
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService manages the API keys users read their metrics feed with and authenticates them
+type APIKeyService struct {
+	keys repository.APIKeyRepository
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(repos repository.Repositories) *APIKeyService {
+	return &APIKeyService{
+		keys: repos.APIKeys,
+	}
+}
+
+// ListKeys lists a user's API keys, newest first
+func (s *APIKeyService) ListKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	keys, err := s.keys.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateKey creates an API key for a user, setting its key. The key is only returned here;
+// afterwards just its hash is kept.
+func (s *APIKeyService) CreateKey(ctx context.Context, key *models.APIKey) error {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key.ID = uuid.New().String()
+	key.Key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key.Prefix = key.Key[:len(apiKeyPrefix)+8]
+	key.KeyHash = hashToken(key.Key)
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+
+	if err := s.keys.Create(ctx, key); err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeKey revokes one of a user's API keys, so it stops working
+func (s *APIKeyService) RevokeKey(ctx context.Context, id, userID string) error {
+	err := s.keys.Revoke(ctx, id, userID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate returns the active API key matching a key presented by a client, recording its use
+func (s *APIKeyService) Authenticate(ctx context.Context, presented string) (*models.APIKey, error) {
+	if !strings.HasPrefix(presented, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.keys.FindByHash(ctx, hashToken(presented))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.keys.Touch(ctx, key.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/google/uuid"
+)
+
+// MaxBatchFiles caps the files accepted into one batch; a day of hourly drops is 24
+const MaxBatchFiles = 100
+
+// ErrBatchNotFound is returned when a batch doesn't exist or belongs to another user
+var ErrBatchNotFound = errors.New("batch not found")
+
+// BatchFiles produces the files of a batch, calling add once per file in order and reject for
+// files it can't supply. An error returned by add aborts the batch; files add rejects itself are
+// recorded and skipped instead.
+type BatchFiles func(add func(file io.Reader, fileName, contentType string) error, reject func(fileName, reason string)) error
+
+// UploadBatch uploads a set of files as one batch, creating a processing job per file at the
+// given priority, or the user's org's priority when it is empty. Files
+// that can't be accepted, such as unsupported types or oversized files, are rejected without
+// failing the rest. A repeated idempotency key returns the earlier batch with Replayed set.
+func (s *FileService) UploadBatch(ctx context.Context, userID, idempotencyKey, priority string, files BatchFiles) (*models.UploadBatch, error) {
+	priority, err := s.resolvePriority(ctx, userID, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.UploadBatch{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		IdempotencyKey: idempotencyKey,
+		Files:          []models.BatchFile{},
+		Rejected:       []models.RejectedFile{},
+		CreatedAt:      time.Now(),
+	}
+
+	// Claim the idempotency key before storing anything, so a retried request uploads nothing
+	if err := s.batches.Create(ctx, batch); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			existing, err := s.batches.FindByIdempotencyKey(ctx, userID, idempotencyKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find batch for idempotency key: %w", err)
+			}
+			existing.Replayed = true
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	reject := func(fileName, reason string) {
+		batch.Rejected = append(batch.Rejected, models.RejectedFile{FileName: fileName, Error: reason})
+	}
+
+	err = files(func(file io.Reader, fileName, contentType string) error {
+		if len(batch.Files) >= MaxBatchFiles {
+			reject(fileName, fmt.Sprintf("batch is limited to %d files", MaxBatchFiles))
+			return nil
+		}
+
+		info, err := s.UploadFile(ctx, file, fileName, contentType, userID, "", priority)
+		if errors.Is(err, ErrFileTypeNotAllowed) || errors.Is(err, storage.ErrFileTooLarge) {
+			reject(fileName, err.Error())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		batchFile := models.BatchFile{
+			FileID:   info.ID,
+			JobID:    info.JobID,
+			FileName: info.FileName,
+			FileSize: info.FileSize,
+			Status:   info.Status,
+			Priority: info.Priority,
+		}
+		if err := s.batches.AddFile(ctx, batch.ID, len(batch.Files), batchFile); err != nil {
+			return fmt.Errorf("failed to add file to batch: %w", err)
+		}
+		batch.Files = append(batch.Files, batchFile)
+
+		return nil
+	}, reject)
+
+	// Record rejections even when the batch was cut short, so its status explains what happened
+	if len(batch.Rejected) > 0 {
+		if err := s.batches.SetRejected(ctx, batch.ID, batch.Rejected); err != nil {
+			return nil, fmt.Errorf("failed to record rejected files: %w", err)
+		}
+	}
+	if err != nil {
+		return batch, err
+	}
+
+	batch.SetStatus()
+	return batch, nil
+}
+
+// GetBatch returns a batch with the current processing status of its files
+func (s *FileService) GetBatch(ctx context.Context, batchID, userID string) (*models.UploadBatch, error) {
+	batch, err := s.batches.FindByID(ctx, batchID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// SpoolArchive copies an uploaded archive into a temp file so it can be read out of order.
+// Archives larger than the upload limit return storage.ErrFileTooLarge. The caller closes
+// and removes the returned file.
+func (s *FileService) SpoolArchive(r io.Reader) (*os.File, int64, error) {
+	file, err := s.fileStorage.CreateTemp("batch-*.zip")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	maxSize := s.maxUpload.Load()
+	src := r
+	if maxSize > 0 {
+		src = io.LimitReader(r, maxSize+1)
+	}
+	size, err := io.Copy(file, src)
+	if err == nil && maxSize > 0 && size > maxSize {
+		err = storage.ErrFileTooLarge
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+
+	return file, size, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BlendedCPARow is one campaign's spend across DSP logs and ad platforms next to the on-site
+// outcomes its UTM campaign drove
+type BlendedCPARow struct {
+	Campaign            string   `json:"campaign"`
+	SpendSources        []string `json:"spendSources"`
+	Impressions         int64    `json:"impressions"`
+	Clicks              int64    `json:"clicks"`
+	Spend               float64  `json:"spend"`
+	PlatformConversions float64  `json:"platformConversions"`
+	Sessions            int64    `json:"sessions"`
+	SiteConversions     float64  `json:"siteConversions"`
+	Revenue             float64  `json:"revenue"`
+	// CPA is spend per on-site conversion; nil without conversions
+	CPA *float64 `json:"cpa"`
+	// ROAS is on-site revenue per unit of spend; nil without spend
+	ROAS *float64 `json:"roas"`
+}
+
+// BlendedCPAReport joins paid spend with on-site outcomes by campaign
+type BlendedCPAReport struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Campaigns []BlendedCPARow `json:"campaigns"`
+	Totals    BlendedCPARow   `json:"totals"`
+}
+
+// GetBlendedCPA reports cost per on-site conversion by campaign. DSP campaigns are matched on
+// campaign ID and ad platform campaigns on name, each against the utm_campaign of GA4 sessions,
+// ignoring case. Campaigns with spend but no sessions, and UTM campaigns without spend, are
+// kept so unmatched tagging shows up. Without dates it covers the last 30 days.
+func (s *IntegrationService) GetBlendedCPA(ctx context.Context, userID string, from, to *time.Time) (*BlendedCPAReport, error) {
+	start, end := reportRange(from, to)
+	fromKey, toKey := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	campaigns := make(map[string]*BlendedCPARow)
+	sources := make(map[string]map[string]struct{})
+	row := func(campaign string) *BlendedCPARow {
+		key := campaignKey(campaign)
+		r, ok := campaigns[key]
+		if !ok {
+			r = &BlendedCPARow{Campaign: strings.TrimSpace(campaign)}
+			campaigns[key] = r
+			sources[key] = make(map[string]struct{})
+		}
+		return r
+	}
+	addSpend := func(campaign, source string, spend ChannelSpend) {
+		r := row(campaign)
+		r.Impressions += spend.Impressions
+		r.Clicks += spend.Clicks
+		r.Spend += spend.Spend
+		r.PlatformConversions += spend.Conversions
+		sources[campaignKey(campaign)][source] = struct{}{}
+	}
+
+	// DSP spend, keyed by the campaign ID the logs carry
+	err := eachDSPCampaignDay(ctx, s.logProcessor, userID, fromKey, toKey, func(source, campaignID, _ string, metrics ChannelSpend) {
+		addSpend(campaignID, source, metrics)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ad platform spend, keyed by campaign name since that's what auto-tagging puts in utm_campaign
+	performance, err := s.integrations.ListPerformance(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign performance: %w", err)
+	}
+	for _, p := range performance {
+		campaign := p.CampaignName
+		if campaign == "" {
+			campaign = p.CampaignID
+		}
+		addSpend(campaign, p.Provider, ChannelSpend{
+			Impressions: p.Impressions,
+			Clicks:      p.Clicks,
+			Conversions: p.Conversions,
+			Spend:       float64(p.CostMicros) / 1000000,
+		})
+	}
+
+	// On-site outcomes from analytics properties
+	outcomes, err := s.integrations.ListSiteOutcomes(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list site outcomes: %w", err)
+	}
+	for _, outcome := range outcomes {
+		// GA4 reports untagged traffic as "(not set)" or "(direct)", which no campaign can match
+		if outcome.Campaign == "" || strings.HasPrefix(outcome.Campaign, "(") {
+			continue
+		}
+		r := row(outcome.Campaign)
+		r.Sessions += outcome.Sessions
+		r.SiteConversions += outcome.Conversions
+		r.Revenue += outcome.Revenue
+	}
+
+	report := &BlendedCPAReport{
+		From:      fromKey,
+		To:        toKey,
+		Campaigns: make([]BlendedCPARow, 0, len(campaigns)),
+		Totals:    BlendedCPARow{Campaign: "total", SpendSources: []string{}},
+	}
+	allSources := make(map[string]struct{})
+	for key, r := range campaigns {
+		r.SpendSources = make([]string, 0, len(sources[key]))
+		for source := range sources[key] {
+			r.SpendSources = append(r.SpendSources, source)
+			allSources[source] = struct{}{}
+		}
+		sort.Strings(r.SpendSources)
+		r.setRatios()
+		report.Campaigns = append(report.Campaigns, *r)
+
+		report.Totals.Impressions += r.Impressions
+		report.Totals.Clicks += r.Clicks
+		report.Totals.Spend += r.Spend
+		report.Totals.PlatformConversions += r.PlatformConversions
+		report.Totals.Sessions += r.Sessions
+		report.Totals.SiteConversions += r.SiteConversions
+		report.Totals.Revenue += r.Revenue
+	}
+	for source := range allSources {
+		report.Totals.SpendSources = append(report.Totals.SpendSources, source)
+	}
+	sort.Strings(report.Totals.SpendSources)
+	report.Totals.setRatios()
+
+	sort.Slice(report.Campaigns, func(i, j int) bool {
+		if report.Campaigns[i].Spend != report.Campaigns[j].Spend {
+			return report.Campaigns[i].Spend > report.Campaigns[j].Spend
+		}
+		return report.Campaigns[i].Campaign < report.Campaigns[j].Campaign
+	})
+
+	return report, nil
+}
+
+// setRatios fills in CPA and ROAS where they're defined
+func (r *BlendedCPARow) setRatios() {
+	r.CPA, r.ROAS = nil, nil
+	if r.SiteConversions > 0 {
+		cpa := r.Spend / r.SiteConversions
+		r.CPA = &cpa
+	}
+	if r.Spend > 0 {
+		roas := r.Revenue / r.Spend
+		r.ROAS = &roas
+	}
+}
+
+// campaignKey is the case-insensitive key campaigns are joined on
+func campaignKey(campaign string) string {
+	return strings.ToLower(strings.TrimSpace(campaign))
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// maxBrandSafetyEntries caps the entries of a single brand safety list
+const maxBrandSafetyEntries = 50000
+
+// Brand safety list errors
+var (
+	ErrInvalidBrandSafetyList    = errors.New("invalid brand safety list")
+	ErrBrandSafetyListExists     = errors.New("a brand safety list with this name already exists")
+	ErrBrandSafetyListNotFound   = errors.New("brand safety list not found")
+	ErrTooManyBrandSafetyEntries = fmt.Errorf("brand safety lists are limited to %d entries", maxBrandSafetyEntries)
+)
+
+// BrandSafetyService manages users' brand safety lists and screens processed files against them.
+// Lists apply to every file, including ones processed before the list was created.
+type BrandSafetyService struct {
+	lists     repository.BrandSafetyRepository
+	analytics *AnalyticsService
+}
+
+// NewBrandSafetyService creates a new brand safety service
+func NewBrandSafetyService(repos repository.Repositories, analytics *AnalyticsService) *BrandSafetyService {
+	return &BrandSafetyService{
+		lists:     repos.BrandSafety,
+		analytics: analytics,
+	}
+}
+
+// CreateList creates a block list, allow list or keyword set. Blank entries, # comments and
+// duplicates are dropped; domains are normalized and keywords lowercased.
+func (s *BrandSafetyService) CreateList(ctx context.Context, userID, name, listType string, entries []string) (*models.BrandSafetyList, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidBrandSafetyList)
+	}
+	listType = strings.ToLower(strings.TrimSpace(listType))
+	switch listType {
+	case models.BrandSafetyListBlock, models.BrandSafetyListAllow, models.BrandSafetyListKeyword:
+	default:
+		return nil, fmt.Errorf("%w: type must be block, allow or keyword", ErrInvalidBrandSafetyList)
+	}
+
+	normalized := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if comment := strings.IndexByte(entry, '#'); comment >= 0 {
+			entry = entry[:comment]
+		}
+		if listType == models.BrandSafetyListKeyword {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+		} else {
+			entry = ingestion.NormalizeListDomain(entry)
+		}
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		normalized = append(normalized, entry)
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one entry is required", ErrInvalidBrandSafetyList)
+	}
+	if len(normalized) > maxBrandSafetyEntries {
+		return nil, ErrTooManyBrandSafetyEntries
+	}
+
+	list := &models.BrandSafetyList{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Type:      listType,
+		Entries:   normalized,
+		CreatedAt: time.Now(),
+	}
+	if err := s.lists.Create(ctx, list); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrBrandSafetyListExists
+		}
+		return nil, fmt.Errorf("failed to create brand safety list: %w", err)
+	}
+
+	return list, nil
+}
+
+// ListLists lists a user's brand safety lists, ordered by name
+func (s *BrandSafetyService) ListLists(ctx context.Context, userID string) ([]*models.BrandSafetyList, error) {
+	lists, err := s.lists.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list brand safety lists: %w", err)
+	}
+
+	return lists, nil
+}
+
+// DeleteList removes a user's brand safety list
+func (s *BrandSafetyService) DeleteList(ctx context.Context, id, userID string) error {
+	err := s.lists.Delete(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrBrandSafetyListNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete brand safety list: %w", err)
+	}
+
+	return nil
+}
+
+// GetBrandSafety screens a processed file's campaign domains against the user's lists
+func (s *BrandSafetyService) GetBrandSafety(ctx context.Context, fileID, userID string) (*ingestion.BrandSafetyReport, error) {
+	summary, err := s.analytics.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files processed before campaign domains were tracked can't be screened
+	if summary.CampaignDomains == nil {
+		return nil, ErrReportUnavailable
+	}
+
+	lists, err := s.ListLists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := ingestion.NewBrandSafetyRules()
+	for _, list := range lists {
+		switch list.Type {
+		case models.BrandSafetyListBlock:
+			rules.AddBlocklist(list.Name, list.Entries)
+		case models.BrandSafetyListAllow:
+			rules.AddAllowlist(list.Name, list.Entries)
+		case models.BrandSafetyListKeyword:
+			rules.AddKeywords(list.Name, list.Entries)
+		}
+	}
+
+	return ingestion.BuildBrandSafetyReport(fileID, summary, rules), nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Bulk file actions
+const (
+	BulkActionDelete    = "delete"
+	BulkActionTag       = "tag"
+	BulkActionMove      = "move-to-folder"
+	BulkActionReprocess = "reprocess"
+)
+
+// Bulk file limits
+const (
+	// MaxBulkFiles caps the files one bulk action applies to; a month of hourly uploads is about 720
+	MaxBulkFiles = 1000
+	// MaxFileTags caps the tags added at once, and maxTagLength the length of each
+	MaxFileTags  = 20
+	maxTagLength = 64
+	// maxFolderLength caps the length of a folder path
+	maxFolderLength = 1024
+)
+
+// Bulk file errors
+var (
+	// ErrInvalidBulkAction is returned for a bulk action other than delete, tag, move-to-folder or reprocess
+	ErrInvalidBulkAction = errors.New("invalid bulk action")
+	// ErrInvalidTags is returned when tagging with no tags, or with a tag that's empty or too long
+	ErrInvalidTags = errors.New("invalid tags")
+	// ErrInvalidFolder is returned for a folder path that's too long or climbs out of the top level
+	ErrInvalidFolder = errors.New("invalid folder")
+)
+
+// BulkFileAction is an action to apply to many of a user's files at once
+type BulkFileAction struct {
+	Action  string
+	FileIDs []string
+	// Tags are added to the files by the tag action
+	Tags []string
+	// Folder is where the move-to-folder action files them; empty moves them to the top level
+	Folder string
+	// Priority is the priority of the reprocess action's jobs, or the user's org's priority when empty
+	Priority string
+}
+
+// BulkFileResult is the outcome of a bulk action for one file
+type BulkFileResult struct {
+	FileID string
+	// Job is the processing job queued for the file by the reprocess action
+	Job *models.ProcessingJob
+	// Err is why the action failed for the file, if it did
+	Err error
+}
+
+// ApplyBulkAction applies an action to each of a user's files in turn. A file the action fails
+// for doesn't stop the rest; its result says why instead. Repeated file IDs are acted on once.
+// The error returned is for the action itself being invalid.
+func (s *FileService) ApplyBulkAction(ctx context.Context, userID string, action BulkFileAction) ([]BulkFileResult, error) {
+	var apply func(ctx context.Context, fileID string) (*models.ProcessingJob, error)
+	switch action.Action {
+	case BulkActionDelete:
+		apply = func(ctx context.Context, fileID string) (*models.ProcessingJob, error) {
+			return nil, s.DeleteFile(ctx, fileID, userID)
+		}
+	case BulkActionTag:
+		tags, err := normalizeTags(action.Tags)
+		if err != nil {
+			return nil, err
+		}
+		apply = func(ctx context.Context, fileID string) (*models.ProcessingJob, error) {
+			return nil, fileNotFound(s.files.AddTags(ctx, fileID, userID, tags))
+		}
+	case BulkActionMove:
+		folder, err := normalizeFolder(action.Folder)
+		if err != nil {
+			return nil, err
+		}
+		apply = func(ctx context.Context, fileID string) (*models.ProcessingJob, error) {
+			return nil, fileNotFound(s.files.SetFolder(ctx, fileID, userID, folder))
+		}
+	case BulkActionReprocess:
+		priority, err := s.resolvePriority(ctx, userID, action.Priority)
+		if err != nil {
+			return nil, err
+		}
+		apply = func(ctx context.Context, fileID string) (*models.ProcessingJob, error) {
+			job, err := s.QueueReprocess(ctx, fileID, userID, priority, ingestion.ParseOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if err := s.SubmitProcessingJob(job.ID, job.FileID, userID, job.Priority); err != nil {
+				// If the server is shutting down the job stays queued for the next start
+				errreport.Report(errreport.WithTags(ctx, "jobID", job.ID, "fileID", job.FileID), "failed to submit processing job", err)
+			}
+			return job, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBulkAction, action.Action)
+	}
+
+	seen := make(map[string]bool, len(action.FileIDs))
+	results := make([]BulkFileResult, 0, len(action.FileIDs))
+	for _, fileID := range action.FileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+
+		job, err := apply(ctx, fileID)
+		results = append(results, BulkFileResult{FileID: fileID, Job: job, Err: err})
+	}
+
+	return results, nil
+}
+
+// fileNotFound turns a repository's not found error for a file into ErrFileNotFound
+func fileNotFound(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrFileNotFound
+	}
+	return err
+}
+
+// normalizeTags trims tags, checking there's at least one and none is empty or too long
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 || len(tags) > MaxFileTags {
+		return nil, fmt.Errorf("%w: between 1 and %d tags are required", ErrInvalidTags, MaxFileTags)
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w: tags must be 1 to %d characters", ErrInvalidTags, maxTagLength)
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// normalizeFolder cleans a slash-separated folder path, such as "2024/march/", into the form
+// it's stored in: "2024/march"
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if folder == "" {
+		return "", nil
+	}
+
+	cleaned := path.Clean(folder)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") || len(cleaned) > maxFolderLength {
+		return "", fmt.Errorf("%w: %s", ErrInvalidFolder, folder)
+	}
+	return cleaned, nil
+}
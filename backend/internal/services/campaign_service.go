@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// CampaignService handles campaign-level views built from processed log files
+type CampaignService struct {
+	users        repository.UserRepository
+	logProcessor *ingestion.LogProcessorService
+	resultCache  *ResultCache
+	rollups      *RollupService
+}
+
+// NewCampaignService creates a new campaign service
+func NewCampaignService(repos repository.Repositories, logProcessor *ingestion.LogProcessorService, resultCache *ResultCache) *CampaignService {
+	return &CampaignService{
+		users:        repos.Users,
+		logProcessor: logProcessor,
+		resultCache:  resultCache,
+	}
+}
+
+// SetRollups reads campaign rollups from precomputed daily rollups once all of an org's files have them
+func (s *CampaignService) SetRollups(rollups *RollupService) {
+	s.rollups = rollups
+}
+
+// GetCampaignRollup merges a campaign's records across the processed files of all of an org's
+// users, optionally clipped to the given flight dates
+func (s *CampaignService) GetCampaignRollup(ctx context.Context, orgID, campaignID string, from, to *time.Time) (*ingestion.CampaignRollup, error) {
+	key := orgKey(orgID, "rollup", campaignID, dateKey(from), dateKey(to))
+	return cached(ctx, s.resultCache, key, func() (*ingestion.CampaignRollup, error) {
+		return s.rollupCampaign(ctx, orgID, campaignID, from, to)
+	})
+}
+
+// rollupCampaign builds a campaign rollup from precomputed rollups when possible, otherwise
+// from the stored analysis results
+func (s *CampaignService) rollupCampaign(ctx context.Context, orgID, campaignID string, from, to *time.Time) (*ingestion.CampaignRollup, error) {
+	userIDs, err := s.users.ListIDsByOrg(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	if s.rollups != nil {
+		rollup, ok, err := s.rollups.CampaignRollup(ctx, userIDs, campaignID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return rollup, nil
+		}
+	}
+
+	var results []*ingestion.LogAnalysisResult
+	for _, userID := range userIDs {
+		userResults, err := s.logProcessor.ListAnalysisResults(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list analysis results: %w", err)
+		}
+		results = append(results, userResults...)
+	}
+
+	rollup, err := ingestion.RollupCampaign(results, campaignID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll up campaign: %w", err)
+	}
+
+	return rollup, nil
+}
+
+// GetReachFrequency computes a campaign's unique reach and frequency across all of the user's
+// processed files, optionally limited to a date range
+func (s *CampaignService) GetReachFrequency(ctx context.Context, userID, campaignID string, from, to *time.Time) (*ingestion.ReachFrequencyReport, error) {
+	key := userKey(userID, "reach", campaignID, dateKey(from), dateKey(to))
+	return cached(ctx, s.resultCache, key, func() (*ingestion.ReachFrequencyReport, error) {
+		return s.computeReachFrequency(ctx, userID, campaignID, from, to)
+	})
+}
+
+// computeReachFrequency builds a reach and frequency report from the stored analysis results
+func (s *CampaignService) computeReachFrequency(ctx context.Context, userID, campaignID string, from, to *time.Time) (*ingestion.ReachFrequencyReport, error) {
+	results, err := s.logProcessor.ListAnalysisResults(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list analysis results: %w", err)
+	}
+
+	report, err := ingestion.ComputeReachFrequency(results, campaignID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute reach and frequency: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetFrequencyCap analyzes a campaign's conversions by impression frequency across all of the
+// user's processed files, optionally limited to a date range, and recommends a frequency cap
+func (s *CampaignService) GetFrequencyCap(ctx context.Context, userID, campaignID string, from, to *time.Time) (*ingestion.FrequencyCapReport, error) {
+	key := userKey(userID, "frequency-cap", campaignID, dateKey(from), dateKey(to))
+	return cached(ctx, s.resultCache, key, func() (*ingestion.FrequencyCapReport, error) {
+		results, err := s.logProcessor.ListAnalysisResults(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list analysis results: %w", err)
+		}
+
+		report, err := ingestion.ComputeFrequencyCap(results, campaignID, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute frequency cap: %w", err)
+		}
+
+		return report, nil
+	})
+}
+
+// dateKey formats an optional date for use in a cache key
+func dateKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Category override errors
+var (
+	ErrInvalidCategory          = errors.New("invalid IAB content category")
+	ErrInvalidDomain            = errors.New("invalid domain")
+	ErrCategoryOverrideNotFound = errors.New("category override not found")
+)
+
+// CategoryOverride is a content category a user assigned to a domain
+type CategoryOverride struct {
+	Domain   string `json:"domain"`
+	Category string `json:"category"`
+}
+
+// CategoryService manages the content categories users assign to domains. Overrides apply to
+// files processed after they're set.
+type CategoryService struct {
+	overrides repository.CategoryOverrideRepository
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService(repos repository.Repositories) *CategoryService {
+	return &CategoryService{
+		overrides: repos.Categories,
+	}
+}
+
+// ListCategories lists the IAB content categories domains can be assigned
+func (s *CategoryService) ListCategories() []ingestion.ContentCategory {
+	return ingestion.ContentCategories()
+}
+
+// ListOverrides lists a user's category overrides, ordered by domain
+func (s *CategoryService) ListOverrides(ctx context.Context, userID string) ([]CategoryOverride, error) {
+	overrides, err := s.overrides.ListOverrides(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category overrides: %w", err)
+	}
+
+	list := make([]CategoryOverride, 0, len(overrides))
+	for domain, category := range overrides {
+		list = append(list, CategoryOverride{Domain: domain, Category: category})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Domain < list[j].Domain
+	})
+
+	return list, nil
+}
+
+// SetOverride assigns a content category to a domain and its subdomains, replacing the
+// bundled mapping for the user
+func (s *CategoryService) SetOverride(ctx context.Context, userID, domain, category string) (*CategoryOverride, error) {
+	domain = ingestion.NormalizeDomain(domain)
+	if domain == "" || strings.ContainsAny(domain, " \t") {
+		return nil, ErrInvalidDomain
+	}
+	category = strings.ToUpper(strings.TrimSpace(category))
+	if !ingestion.IsContentCategory(category) {
+		return nil, ErrInvalidCategory
+	}
+
+	if err := s.overrides.SetOverride(ctx, userID, domain, category); err != nil {
+		return nil, fmt.Errorf("failed to set category override: %w", err)
+	}
+
+	return &CategoryOverride{Domain: domain, Category: category}, nil
+}
+
+// DeleteOverride removes a user's category for a domain, restoring the bundled mapping
+func (s *CategoryService) DeleteOverride(ctx context.Context, userID, domain string) error {
+	err := s.overrides.DeleteOverride(ctx, userID, ingestion.NormalizeDomain(domain))
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCategoryOverrideNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete category override: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// exchangeRateInterval is how often the latest exchange rates are fetched. Providers publish
+// once a day, so fetching more often only refreshes that day's snapshot.
+const exchangeRateInterval = 6 * time.Hour
+
+// CurrencyService snapshots exchange rates daily and converts campaign spend, which logs
+// report in US dollars, into campaign and reporting currencies at historical rates
+type CurrencyService struct {
+	rates  repository.ExchangeRateRepository
+	orgs   repository.OrganizationRepository
+	client *fxrates.Client
+}
+
+// NewCurrencyService creates a currency service. Rates aren't snapshotted when client is nil.
+func NewCurrencyService(repos repository.Repositories, client *fxrates.Client) *CurrencyService {
+	return &CurrencyService{
+		rates:  repos.Rates,
+		orgs:   repos.Orgs,
+		client: client,
+	}
+}
+
+// Run snapshots the latest exchange rates each time it checks until the context is canceled
+func (s *CurrencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(exchangeRateInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Snapshot(ctx); err != nil {
+			slog.Error("Failed to snapshot exchange rates", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot fetches and stores the latest exchange rates
+func (s *CurrencyService) Snapshot(ctx context.Context) error {
+	snapshot, err := s.client.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.rates.SaveSnapshot(ctx, snapshot); err != nil {
+		return fmt.Errorf("failed to save exchange rates: %w", err)
+	}
+
+	return nil
+}
+
+// ReportingCurrency returns the currency an org's campaign spend is reported in
+func (s *CurrencyService) ReportingCurrency(ctx context.Context, orgID string) (string, error) {
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fxrates.BaseCurrency, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org.ReportingCurrency, nil
+}
+
+// ConvertRollup returns a campaign rollup with its spend and revenue in another currency,
+// converting each day at that day's rate
+func (s *CurrencyService) ConvertRollup(ctx context.Context, rollup *ingestion.CampaignRollup, currency string) (*ingestion.CampaignRollup, error) {
+	// Rollups cached before they carried a currency are in US dollars
+	if rollup.Currency == "" {
+		rollup.Currency = fxrates.BaseCurrency
+	}
+	if rollup.Currency == currency {
+		return rollup, nil
+	}
+	if len(rollup.Daily) == 0 {
+		converted := *rollup
+		converted.Currency = currency
+		return &converted, nil
+	}
+
+	from, err := time.Parse("2006-01-02", rollup.Daily[0].Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rollup date %q: %w", rollup.Daily[0].Date, err)
+	}
+	to, err := time.Parse("2006-01-02", rollup.Daily[len(rollup.Daily)-1].Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rollup date %q: %w", rollup.Daily[len(rollup.Daily)-1].Date, err)
+	}
+
+	history, err := s.history(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return rollup.Converted(currency, history)
+}
+
+// history loads the exchange rates in effect between from and to
+func (s *CurrencyService) history(ctx context.Context, from, to time.Time) (*fxrates.History, error) {
+	snapshots, err := s.rates.ListSnapshots(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exchange rates: %w", err)
+	}
+	return fxrates.NewHistory(snapshots), nil
+}
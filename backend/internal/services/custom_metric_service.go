@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/formula"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Custom metric errors
+var (
+	ErrInvalidCustomMetric  = errors.New("invalid custom metric")
+	ErrCustomMetricNotFound = errors.New("custom metric not found")
+)
+
+// maxCustomMetrics caps how many custom metrics an organization can define, since every
+// metric is evaluated for every row of a report
+const maxCustomMetrics = 50
+
+// customMetricNamePattern matches custom metric names such as effective_cpm
+var customMetricNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// CustomMetricService manages the derived metrics organizations define over the base metrics
+// and evaluates them for reports
+type CustomMetricService struct {
+	metrics repository.CustomMetricRepository
+}
+
+// NewCustomMetricService creates a new custom metric service
+func NewCustomMetricService(repos repository.Repositories) *CustomMetricService {
+	return &CustomMetricService{
+		metrics: repos.Metrics,
+	}
+}
+
+// ListMetrics lists an organization's custom metrics, ordered by name
+func (s *CustomMetricService) ListMetrics(ctx context.Context, orgID string) ([]*models.CustomMetric, error) {
+	metrics, err := s.metrics.ListMetrics(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom metrics: %w", err)
+	}
+	return metrics, nil
+}
+
+// SetMetric validates and saves an organization's custom metric, replacing an earlier one of
+// the same name
+func (s *CustomMetricService) SetMetric(ctx context.Context, metric *models.CustomMetric) error {
+	metric.Name = strings.ToLower(strings.TrimSpace(metric.Name))
+	metric.Formula = strings.TrimSpace(metric.Formula)
+	metric.Description = strings.TrimSpace(metric.Description)
+
+	if !customMetricNamePattern.MatchString(metric.Name) {
+		return fmt.Errorf("%w: name must be up to 64 lowercase letters, digits and underscores, starting with a letter", ErrInvalidCustomMetric)
+	}
+	if ingestion.IsMetricVariable(metric.Name) {
+		return fmt.Errorf("%w: %q is a base metric", ErrInvalidCustomMetric, metric.Name)
+	}
+
+	parsed, err := formula.Parse(metric.Formula)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCustomMetric, err)
+	}
+	for _, variable := range parsed.Variables() {
+		if !ingestion.IsMetricVariable(variable) {
+			return fmt.Errorf("%w: unknown metric %q", ErrInvalidCustomMetric, variable)
+		}
+	}
+
+	existing, err := s.metrics.ListMetrics(ctx, metric.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to list custom metrics: %w", err)
+	}
+	if len(existing) >= maxCustomMetrics && !hasCustomMetric(existing, metric.Name) {
+		return fmt.Errorf("%w: organizations can define up to %d custom metrics", ErrInvalidCustomMetric, maxCustomMetrics)
+	}
+
+	metric.UpdatedAt = time.Now()
+	if err := s.metrics.SetMetric(ctx, metric); err != nil {
+		return fmt.Errorf("failed to set custom metric: %w", err)
+	}
+
+	return nil
+}
+
+// hasCustomMetric reports whether metrics includes one with the given name
+func hasCustomMetric(metrics []*models.CustomMetric, name string) bool {
+	for _, metric := range metrics {
+		if metric.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteMetric removes an organization's custom metric
+func (s *CustomMetricService) DeleteMetric(ctx context.Context, orgID, name string) error {
+	err := s.metrics.DeleteMetric(ctx, orgID, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCustomMetricNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete custom metric: %w", err)
+	}
+	return nil
+}
+
+// Compile parses an organization's custom metrics so they can be evaluated for a report
+func (s *CustomMetricService) Compile(ctx context.Context, orgID string) (*CustomMetricSet, error) {
+	metrics, err := s.metrics.ListMetrics(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom metrics: %w", err)
+	}
+
+	set := &CustomMetricSet{}
+	for _, metric := range metrics {
+		// Formulas are validated when they're set, so one that no longer parses is skipped
+		// rather than failing the report
+		parsed, err := formula.Parse(metric.Formula)
+		if err != nil {
+			continue
+		}
+		set.metrics = append(set.metrics, compiledMetric{name: metric.Name, formula: parsed})
+	}
+
+	return set, nil
+}
+
+// CustomMetricSet is an organization's custom metrics, parsed and ready to evaluate
+type CustomMetricSet struct {
+	metrics []compiledMetric
+}
+
+type compiledMetric struct {
+	name    string
+	formula *formula.Formula
+}
+
+// Names lists the set's metric names, ordered by name
+func (s *CustomMetricSet) Names() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, len(s.metrics))
+	for i, metric := range s.metrics {
+		names[i] = metric.name
+	}
+	return names
+}
+
+// Apply evaluates the custom metrics for a set of base metrics, leaving out metrics that are
+// undefined for them, such as a ratio over zero impressions
+func (s *CustomMetricSet) Apply(metrics *ingestion.CampaignMetrics) {
+	if s == nil || len(s.metrics) == 0 {
+		return
+	}
+
+	values := metrics.Values()
+	metrics.Custom = make(map[string]float64, len(s.metrics))
+	for _, metric := range s.metrics {
+		if value, ok := metric.formula.Evaluate(values); ok {
+			metrics.Custom[metric.name] = value
+		}
+	}
+}
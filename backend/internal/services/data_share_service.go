@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Data share errors
+var (
+	ErrInvalidDataShare  = errors.New("invalid data share")
+	ErrDataShareNotFound = errors.New("data share not found")
+	// ErrInvalidShareToken is returned for unknown, expired and revoked tokens alike
+	ErrInvalidShareToken = errors.New("invalid, expired or revoked share token")
+	// ErrCampaignNotShared is returned for campaigns a share doesn't include, whether or not
+	// they exist, so a token reveals nothing about the rest of the account
+	ErrCampaignNotShared = errors.New("campaign not found")
+	ErrOutsideShareRange = errors.New("dates are outside the share's date range")
+)
+
+// shareTokenPrefix starts every share token, telling them apart from API keys
+const shareTokenPrefix = "shr_"
+
+// maxShareCampaigns is how many campaigns a share may include
+const maxShareCampaigns = 100
+
+// DataShareService manages the scoped tokens orgs give third parties to read some campaigns'
+// delivery over a date range, and reads that delivery for them
+type DataShareService struct {
+	shares    repository.DataShareRepository
+	campaigns *CampaignService
+	rollups   *RollupService
+}
+
+// NewDataShareService creates a new data share service, reading delivery from campaigns and rollups
+func NewDataShareService(repos repository.Repositories, campaigns *CampaignService, rollups *RollupService) *DataShareService {
+	return &DataShareService{
+		shares:    repos.Shares,
+		campaigns: campaigns,
+		rollups:   rollups,
+	}
+}
+
+// ListShares lists an org's data shares, newest first
+func (s *DataShareService) ListShares(ctx context.Context, orgID string) ([]*models.DataShare, error) {
+	shares, err := s.shares.ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data shares: %w", err)
+	}
+	return shares, nil
+}
+
+// CreateShare validates and saves a data share, setting its token. The token is only returned
+// here; afterwards just its hash is kept.
+func (s *DataShareService) CreateShare(ctx context.Context, share *models.DataShare) error {
+	share.Name = strings.TrimSpace(share.Name)
+	if share.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDataShare)
+	}
+
+	campaignIDs := make([]string, 0, len(share.CampaignIDs))
+	for _, campaignID := range share.CampaignIDs {
+		campaignID = strings.TrimSpace(campaignID)
+		if campaignID != "" && !slices.Contains(campaignIDs, campaignID) {
+			campaignIDs = append(campaignIDs, campaignID)
+		}
+	}
+	if len(campaignIDs) == 0 || len(campaignIDs) > maxShareCampaigns {
+		return fmt.Errorf("%w: a share must include between 1 and %d campaigns", ErrInvalidDataShare, maxShareCampaigns)
+	}
+	share.CampaignIDs = campaignIDs
+
+	if share.From != nil && share.To != nil && share.To.Before(*share.From) {
+		return fmt.Errorf("%w: 'to' must not be before 'from'", ErrInvalidDataShare)
+	}
+	now := time.Now()
+	if share.ExpiresAt != nil && !share.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expiry must be in the future", ErrInvalidDataShare)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate share token: %w", err)
+	}
+	share.ID = uuid.New().String()
+	share.Token = shareTokenPrefix + base64.RawURLEncoding.EncodeToString(token)
+	share.TokenHash = hashToken(share.Token)
+	share.CreatedAt = now
+	share.RevokedAt = nil
+
+	if err := s.shares.Create(ctx, share); err != nil {
+		return fmt.Errorf("failed to create data share: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeShare revokes one of an org's data shares, so its token stops working
+func (s *DataShareService) RevokeShare(ctx context.Context, id, orgID string) error {
+	err := s.shares.Revoke(ctx, id, orgID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrDataShareNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke data share: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate returns the active data share with a token
+func (s *DataShareService) Authenticate(ctx context.Context, token string) (*models.DataShare, error) {
+	if !strings.HasPrefix(token, shareTokenPrefix) {
+		return nil, ErrInvalidShareToken
+	}
+
+	share, err := s.shares.FindByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidShareToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find data share: %w", err)
+	}
+	if !share.Active(time.Now()) {
+		return nil, ErrInvalidShareToken
+	}
+
+	return share, nil
+}
+
+// CampaignRollup rolls up a shared campaign's delivery between the from and to dates, narrowed
+// to the share's date range
+func (s *DataShareService) CampaignRollup(ctx context.Context, share *models.DataShare, campaignID string, from, to *time.Time) (*ingestion.CampaignRollup, error) {
+	if !share.Includes(campaignID) {
+		return nil, ErrCampaignNotShared
+	}
+	from, to, err := shareRange(share, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.campaigns.GetCampaignRollup(ctx, share.OrgID, campaignID, from, to)
+}
+
+// StreamCampaignRollups reads a page of a shared campaign's hourly or daily rollups between the
+// from and to dates, narrowed to the share's date range, as RollupService.StreamRollups does
+func (s *DataShareService) StreamCampaignRollups(ctx context.Context, share *models.DataShare, campaignID, grain string, from, to *time.Time, cursor string, limit int, start func(*RollupSeries) error, emit func(ingestion.Rollup) error) (string, error) {
+	if !share.Includes(campaignID) {
+		return "", ErrCampaignNotShared
+	}
+	from, to, err := shareRange(share, from, to)
+	if err != nil {
+		return "", err
+	}
+
+	return s.rollups.StreamRollups(ctx, share.UserID, ingestion.RollupByCampaign, grain, campaignID, from, to, cursor, limit, start, emit)
+}
+
+// shareRange narrows a requested date range to a share's, defaulting to the share's own bounds
+func shareRange(share *models.DataShare, from, to *time.Time) (*time.Time, *time.Time, error) {
+	if share.From != nil && (from == nil || from.Before(*share.From)) {
+		from = share.From
+	}
+	if share.To != nil && (to == nil || to.After(*share.To)) {
+		to = share.To
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, ErrOutsideShareRange
+	}
+	return from, to, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+	"github.com/google/uuid"
+)
+
+// Dataset errors
+var (
+	ErrDatasetNotFound  = errors.New("dataset not found")
+	ErrFileNotFound     = errors.New("file not found")
+	ErrFileAlreadyAdded = errors.New("file is already in the dataset")
+)
+
+// DatasetInfo is a dataset with its files and merged summary
+type DatasetInfo struct {
+	*models.Dataset
+	FileIDs []string `json:"fileIds"`
+	// PendingFileIDs are appended files not yet merged into the summary
+	PendingFileIDs []string                     `json:"pendingFileIds"`
+	AggregatedAt   *time.Time                   `json:"aggregatedAt,omitempty"`
+	Summary        *ingestion.BeeswaxLogSummary `json:"summary,omitempty"`
+}
+
+// DatasetService handles datasets that accumulate daily log files incrementally
+type DatasetService struct {
+	datasets     repository.DatasetRepository
+	files        repository.FileRepository
+	fileService  *FileService
+	logProcessor *ingestion.LogProcessorService
+	workers      *worker.Manager
+	locks        sync.Map // dataset ID → *sync.Mutex serializing aggregate updates
+}
+
+// NewDatasetService creates a new dataset service
+func NewDatasetService(repos repository.Repositories, fileService *FileService, logProcessor *ingestion.LogProcessorService, workers *worker.Manager) *DatasetService {
+	return &DatasetService{
+		datasets:     repos.Datasets,
+		files:        repos.Files,
+		fileService:  fileService,
+		logProcessor: logProcessor,
+		workers:      workers,
+	}
+}
+
+// CreateDataset creates an empty dataset
+func (s *DatasetService) CreateDataset(ctx context.Context, userID, name, campaignID, source string) (*models.Dataset, error) {
+	now := time.Now()
+	dataset := &models.Dataset{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Name:       name,
+		CampaignID: campaignID,
+		Source:     source,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.datasets.Create(ctx, dataset); err != nil {
+		return nil, fmt.Errorf("failed to create dataset: %w", err)
+	}
+
+	return dataset, nil
+}
+
+// ListDatasets lists a user's datasets
+func (s *DatasetService) ListDatasets(ctx context.Context, userID string) ([]*models.Dataset, error) {
+	datasets, err := s.datasets.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list datasets: %w", err)
+	}
+
+	return datasets, nil
+}
+
+// GetDataset returns a dataset with its files and merged summary
+func (s *DatasetService) GetDataset(ctx context.Context, datasetID, userID string) (*DatasetInfo, error) {
+	dataset, fileIDs, err := s.findDataset(ctx, datasetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DatasetInfo{
+		Dataset:        dataset,
+		FileIDs:        fileIDs,
+		PendingFileIDs: []string{},
+	}
+
+	aggregate, err := s.logProcessor.GetDatasetAggregate(ctx, datasetID, userID)
+	if err != nil && !errors.Is(err, ingestion.ErrDatasetNotAggregated) {
+		return nil, err
+	}
+	if aggregate != nil {
+		info.AggregatedAt = &aggregate.UpdatedAt
+		info.Summary = aggregate.Summary
+	}
+
+	for _, fileID := range fileIDs {
+		if aggregate == nil || !aggregate.Contains(fileID) {
+			info.PendingFileIDs = append(info.PendingFileIDs, fileID)
+		}
+	}
+
+	return info, nil
+}
+
+// AppendFile adds a file to a dataset and merges it into the dataset's summary in the
+// background, processing the file first if needed. Files already in the summary aren't re-read.
+func (s *DatasetService) AppendFile(ctx context.Context, datasetID, fileID, userID string) error {
+	if _, _, err := s.findDataset(ctx, datasetID, userID); err != nil {
+		return err
+	}
+
+	if _, err := s.files.FindByID(ctx, fileID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to find file: %w", err)
+	}
+
+	if err := s.datasets.AddFile(ctx, datasetID, fileID, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrFileAlreadyAdded
+		}
+		return fmt.Errorf("failed to add file to dataset: %w", err)
+	}
+
+	return s.workers.Submit(worker.Task{
+		ID: "dataset-append-" + uuid.New().String(),
+		Run: func(ctx context.Context) error {
+			if _, err := s.fileService.ProcessLogFile(ctx, fileID, userID); err != nil {
+				return err
+			}
+
+			unlock := s.lock(datasetID)
+			defer unlock()
+
+			_, err := s.logProcessor.AppendToDataset(ctx, datasetID, fileID, userID)
+			return err
+		},
+	})
+}
+
+// RecomputeDataset re-parses every file in a dataset and rebuilds its summary from scratch in
+// the background, for when the way files are parsed has changed
+func (s *DatasetService) RecomputeDataset(ctx context.Context, datasetID, userID string) error {
+	if _, _, err := s.findDataset(ctx, datasetID, userID); err != nil {
+		return err
+	}
+
+	return s.workers.Submit(worker.Task{
+		ID: "dataset-recompute-" + uuid.New().String(),
+		Run: func(ctx context.Context) error {
+			unlock := s.lock(datasetID)
+			defer unlock()
+
+			// Read the file list under the lock so files appended meanwhile are included
+			fileIDs, err := s.datasets.ListFileIDs(ctx, datasetID)
+			if err != nil {
+				return fmt.Errorf("failed to list dataset files: %w", err)
+			}
+
+			for _, fileID := range fileIDs {
+				if _, err := s.fileService.ReprocessLogFile(ctx, fileID, userID, ingestion.ParseOptions{}); err != nil {
+					return err
+				}
+			}
+
+			_, err = s.logProcessor.RebuildDataset(ctx, datasetID, userID, fileIDs)
+			return err
+		},
+	})
+}
+
+// findDataset finds a user's dataset and its file IDs
+func (s *DatasetService) findDataset(ctx context.Context, datasetID, userID string) (*models.Dataset, []string, error) {
+	dataset, err := s.datasets.FindByID(ctx, datasetID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, ErrDatasetNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to find dataset: %w", err)
+	}
+
+	fileIDs, err := s.datasets.ListFileIDs(ctx, datasetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list dataset files: %w", err)
+	}
+
+	return dataset, fileIDs, nil
+}
+
+// lock serializes updates to a dataset's aggregate, returning the unlock function
+func (s *DatasetService) lock(datasetID string) func() {
+	value, _ := s.locks.LoadOrStore(datasetID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
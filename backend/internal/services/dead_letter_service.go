@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// ErrDeadLetterNotFound is returned when a job isn't in the dead letter table
+var ErrDeadLetterNotFound = errors.New("dead-lettered job not found")
+
+// DeadLetterParameters changes how a dead-lettered job runs when requeued; nil fields keep
+// their current values
+type DeadLetterParameters struct {
+	Priority  *string `json:"priority"`
+	Reprocess *bool   `json:"reprocess"`
+	Parser    *string `json:"parser"`
+	Format    *string `json:"format"`
+}
+
+// RequeueResult lists what a bulk requeue did with each job
+type RequeueResult struct {
+	Requeued []string `json:"requeued"`
+	// Skipped jobs' files have been queued or processed again since they were dead-lettered
+	Skipped  []string `json:"skipped"`
+	NotFound []string `json:"notFound"`
+}
+
+// DeadLetterService lets admins inspect processing jobs that failed on every attempt, fix
+// their parameters and requeue them
+type DeadLetterService struct {
+	deadLetters repository.DeadLetterRepository
+	uow         repository.UnitOfWork
+	fileService *FileService
+}
+
+// NewDeadLetterService creates a new dead letter service, submitting requeued jobs to the
+// file service's workers
+func NewDeadLetterService(repos repository.Repositories, uow repository.UnitOfWork, fileService *FileService) *DeadLetterService {
+	return &DeadLetterService{
+		deadLetters: repos.DeadLetters,
+		uow:         uow,
+		fileService: fileService,
+	}
+}
+
+// ListDeadLetters lists dead-lettered jobs, most recently failed first
+func (s *DeadLetterService) ListDeadLetters(ctx context.Context) ([]*models.DeadLetterJob, error) {
+	jobs, err := s.deadLetters.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-lettered jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetDeadLetter returns a dead-lettered job
+func (s *DeadLetterService) GetDeadLetter(ctx context.Context, jobID string) (*models.DeadLetterJob, error) {
+	job, err := s.deadLetters.FindByJobID(ctx, jobID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-lettered job: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateDeadLetter validates and saves the parameters a dead-lettered job is requeued with
+func (s *DeadLetterService) UpdateDeadLetter(ctx context.Context, jobID string, params DeadLetterParameters) (*models.DeadLetterJob, error) {
+	job, err := s.GetDeadLetter(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Priority != nil {
+		if !models.ValidJobPriority(*params.Priority) {
+			return nil, ErrInvalidPriority
+		}
+		job.Priority = *params.Priority
+	}
+	if params.Reprocess != nil {
+		job.Reprocess = *params.Reprocess
+	}
+	if params.Parser != nil {
+		job.Parser = *params.Parser
+	}
+	if params.Format != nil {
+		job.LogFormat = *params.Format
+	}
+	opts := ingestion.ParseOptions{Parser: job.Parser, Format: job.LogFormat}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParseOptions, err)
+	}
+	// Parse options only apply when the file is parsed again
+	if !job.Reprocess && (job.Parser != "" || job.LogFormat != "") {
+		return nil, fmt.Errorf("%w: parser and format overrides require reprocess", ErrInvalidParseOptions)
+	}
+
+	err = s.deadLetters.UpdateParameters(ctx, job)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update dead-lettered job: %w", err)
+	}
+
+	return job, nil
+}
+
+// RequeueDeadLetters puts dead-lettered jobs back in the queue with their current parameters
+// and a fresh set of attempts, or every dead-lettered job when jobIDs is empty
+func (s *DeadLetterService) RequeueDeadLetters(ctx context.Context, jobIDs []string) (*RequeueResult, error) {
+	if len(jobIDs) == 0 {
+		jobs, err := s.ListDeadLetters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range jobs {
+			jobIDs = append(jobIDs, job.JobID)
+		}
+	}
+
+	result := &RequeueResult{Requeued: []string{}, Skipped: []string{}, NotFound: []string{}}
+	for _, jobID := range jobIDs {
+		var requeued *models.ProcessingJob
+		err := s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			dead, err := repos.DeadLetters.FindByJobID(ctx, jobID)
+			if err != nil {
+				return err
+			}
+			active, err := repos.Jobs.HasActive(ctx, dead.FileID)
+			if err != nil {
+				return fmt.Errorf("failed to check for active jobs: %w", err)
+			}
+			if active {
+				return ErrJobActive
+			}
+
+			job := &models.ProcessingJob{
+				ID:        dead.JobID,
+				FileID:    dead.FileID,
+				UserID:    dead.UserID,
+				Priority:  dead.Priority,
+				Reprocess: dead.Reprocess,
+				Parser:    dead.Parser,
+				LogFormat: dead.LogFormat,
+			}
+			if err := repos.Jobs.Requeue(ctx, job); err != nil {
+				return fmt.Errorf("failed to requeue job: %w", err)
+			}
+			if err := repos.Files.UpdateStatus(ctx, job.FileID, job.UserID, models.FileStatusUploaded); err != nil {
+				return fmt.Errorf("failed to update file status: %w", err)
+			}
+			if err := repos.DeadLetters.Delete(ctx, jobID); err != nil {
+				return fmt.Errorf("failed to remove dead-lettered job: %w", err)
+			}
+			requeued = job
+			return nil
+		})
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			result.NotFound = append(result.NotFound, jobID)
+			continue
+		case errors.Is(err, ErrJobActive):
+			result.Skipped = append(result.Skipped, jobID)
+			continue
+		case err != nil:
+			return result, err
+		}
+
+		if err := s.fileService.SubmitProcessingJob(requeued.ID, requeued.FileID, requeued.UserID, requeued.Priority); err != nil {
+			return result, fmt.Errorf("failed to submit job %s: %w", jobID, err)
+		}
+		result.Requeued = append(result.Requeued, jobID)
+	}
+
+	return result, nil
+}
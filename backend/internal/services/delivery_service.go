@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// DefaultDiscrepancyThreshold is the impression discrepancy, in percent, above which delivery is
+// flagged; 10% is the usual contractual tolerance between buyer and seller counts
+const DefaultDiscrepancyThreshold = 10.0
+
+// DeliveryImport summarizes a delivery report imported from an uploaded file
+type DeliveryImport struct {
+	FileID      string `json:"fileId"`
+	Rows        int    `json:"rows"`
+	SkippedRows int    `json:"skippedRows"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Impressions int64  `json:"impressions"`
+}
+
+// DeliveryDiscrepancy compares DSP-reported wins with the ad server's count of the same delivery
+type DeliveryDiscrepancy struct {
+	Date         string   `json:"date,omitempty"`
+	LineItemID   string   `json:"lineItemId,omitempty"`
+	LineItemName string   `json:"lineItemName,omitempty"`
+	CampaignIDs  []string `json:"campaignIds,omitempty"`
+
+	DSPImpressions      int64 `json:"dspImpressions"`
+	AdServerImpressions int64 `json:"adServerImpressions"`
+	// ImpressionDiscrepancy is DSP impressions over or under the ad server's count, as a
+	// percentage of it; nil when the ad server counted none
+	ImpressionDiscrepancy *float64 `json:"impressionDiscrepancy"`
+
+	DSPSpend        float64 `json:"dspSpend"`
+	AdServerRevenue float64 `json:"adServerRevenue"`
+	// RevenueDiscrepancy is DSP spend over or under ad server revenue, as a percentage of it
+	RevenueDiscrepancy *float64 `json:"revenueDiscrepancy"`
+
+	// Flagged is set when the impression discrepancy is beyond the threshold or only one side delivered
+	Flagged bool `json:"flagged"`
+}
+
+// DeliveryReconciliation compares DSP wins against ad server delivery. DSP campaigns are matched
+// to line items by the line item's ID or name; totals and days cover matched line items only, and
+// whatever didn't match is listed so the mapping can be fixed.
+type DeliveryReconciliation struct {
+	From               string                `json:"from"`
+	To                 string                `json:"to"`
+	Threshold          float64               `json:"threshold"`
+	Totals             DeliveryDiscrepancy   `json:"totals"`
+	Daily              []DeliveryDiscrepancy `json:"daily"`
+	LineItems          []DeliveryDiscrepancy `json:"lineItems"`
+	UnmatchedCampaigns []string              `json:"unmatchedCampaigns"`
+	UnmatchedLineItems []string              `json:"unmatchedLineItems"`
+}
+
+// DeliveryService imports ad server delivery reports and reconciles them with DSP logs
+type DeliveryService struct {
+	delivery     repository.DeliveryReportRepository
+	files        repository.FileRepository
+	uow          repository.UnitOfWork
+	fileService  *FileService
+	logProcessor *ingestion.LogProcessorService
+}
+
+// NewDeliveryService creates a new delivery service
+func NewDeliveryService(repos repository.Repositories, uow repository.UnitOfWork, fileService *FileService, logProcessor *ingestion.LogProcessorService) *DeliveryService {
+	return &DeliveryService{
+		delivery:     repos.Delivery,
+		files:        repos.Files,
+		uow:          uow,
+		fileService:  fileService,
+		logProcessor: logProcessor,
+	}
+}
+
+// ImportDeliveryReport parses an uploaded file as an ad server delivery report and stores its
+// rows, replacing any earlier import of the same file. Reports are aggregates, so unlike logs
+// they're small enough to import while the client waits.
+func (s *DeliveryService) ImportDeliveryReport(ctx context.Context, fileID, userID string) (*DeliveryImport, error) {
+	if _, err := s.files.FindByID(ctx, fileID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+
+	file, _, err := s.fileService.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report, err := ingestion.ParseDeliveryReport(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Delivery.DeleteRows(ctx, fileID, userID); err != nil {
+			return fmt.Errorf("failed to clear delivery rows: %w", err)
+		}
+		if err := repos.Delivery.InsertRows(ctx, fileID, userID, time.Now(), report.Rows); err != nil {
+			return fmt.Errorf("failed to store delivery rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &DeliveryImport{
+		FileID:      fileID,
+		Rows:        len(report.Rows),
+		SkippedRows: report.SkippedRows,
+		From:        report.Rows[0].Date,
+		To:          report.Rows[0].Date,
+	}
+	for _, row := range report.Rows {
+		summary.From = min(summary.From, row.Date)
+		summary.To = max(summary.To, row.Date)
+		summary.Impressions += row.Impressions
+	}
+
+	return summary, nil
+}
+
+// GetDeliveryReconciliation reconciles the user's DSP wins with imported ad server delivery,
+// flagging discrepancies beyond threshold percent. Without dates it covers the last 30 days.
+func (s *DeliveryService) GetDeliveryReconciliation(ctx context.Context, userID string, from, to *time.Time, threshold float64) (*DeliveryReconciliation, error) {
+	start, end := reportRange(from, to)
+	fromKey, toKey := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	rows, err := s.delivery.ListRows(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delivery rows: %w", err)
+	}
+
+	// Index line items by both ID and name, so a DSP campaign can match either
+	lineItems := make(map[string]*DeliveryDiscrepancy)
+	byKey := make(map[string]string)
+	type dayKey struct{ date, lineItem string }
+	adServerDays := make(map[dayKey]ingestion.DeliveryReportRow)
+	for _, row := range rows {
+		id := row.LineItemID
+		if id == "" {
+			id = row.LineItemName
+		}
+		if id == "" {
+			continue
+		}
+
+		item, ok := lineItems[id]
+		if !ok {
+			item = &DeliveryDiscrepancy{LineItemID: row.LineItemID, LineItemName: row.LineItemName}
+			lineItems[id] = item
+		}
+		item.AdServerImpressions += row.Impressions
+		item.AdServerRevenue += float64(row.RevenueMicros) / 1000000
+
+		for _, name := range []string{row.LineItemID, row.LineItemName} {
+			if key := campaignKey(name); key != "" {
+				byKey[key] = id
+			}
+		}
+
+		day := adServerDays[dayKey{row.Date, id}]
+		day.Impressions += row.Impressions
+		day.RevenueMicros += row.RevenueMicros
+		adServerDays[dayKey{row.Date, id}] = day
+	}
+
+	days := make(map[string]*DeliveryDiscrepancy)
+	dayOf := func(date string) *DeliveryDiscrepancy {
+		day, ok := days[date]
+		if !ok {
+			day = &DeliveryDiscrepancy{Date: date}
+			days[date] = day
+		}
+		return day
+	}
+
+	unmatched := make(map[string]struct{})
+	matched := make(map[string]map[string]struct{})
+	err = eachDSPCampaignDay(ctx, s.logProcessor, userID, fromKey, toKey, func(_, campaignID, date string, metrics ChannelSpend) {
+		id, ok := byKey[campaignKey(campaignID)]
+		if !ok {
+			unmatched[campaignID] = struct{}{}
+			return
+		}
+		if matched[id] == nil {
+			matched[id] = make(map[string]struct{})
+		}
+		matched[id][campaignID] = struct{}{}
+
+		item := lineItems[id]
+		item.DSPImpressions += metrics.Impressions
+		item.DSPSpend += metrics.Spend
+
+		day := dayOf(date)
+		day.DSPImpressions += metrics.Impressions
+		day.DSPSpend += metrics.Spend
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeliveryReconciliation{
+		From:               fromKey,
+		To:                 toKey,
+		Threshold:          threshold,
+		Daily:              []DeliveryDiscrepancy{},
+		LineItems:          []DeliveryDiscrepancy{},
+		UnmatchedCampaigns: []string{},
+		UnmatchedLineItems: []string{},
+	}
+
+	for key, day := range adServerDays {
+		if _, ok := matched[key.lineItem]; !ok {
+			continue
+		}
+		d := dayOf(key.date)
+		d.AdServerImpressions += day.Impressions
+		d.AdServerRevenue += float64(day.RevenueMicros) / 1000000
+	}
+
+	for id, item := range lineItems {
+		campaigns, ok := matched[id]
+		if !ok {
+			report.UnmatchedLineItems = append(report.UnmatchedLineItems, id)
+			continue
+		}
+		for campaignID := range campaigns {
+			item.CampaignIDs = append(item.CampaignIDs, campaignID)
+		}
+		sort.Strings(item.CampaignIDs)
+		item.compare(threshold)
+		report.LineItems = append(report.LineItems, *item)
+
+		report.Totals.DSPImpressions += item.DSPImpressions
+		report.Totals.AdServerImpressions += item.AdServerImpressions
+		report.Totals.DSPSpend += item.DSPSpend
+		report.Totals.AdServerRevenue += item.AdServerRevenue
+	}
+	report.Totals.compare(threshold)
+
+	for _, day := range days {
+		day.compare(threshold)
+		report.Daily = append(report.Daily, *day)
+	}
+	for campaignID := range unmatched {
+		report.UnmatchedCampaigns = append(report.UnmatchedCampaigns, campaignID)
+	}
+
+	sort.Slice(report.Daily, func(i, j int) bool {
+		return report.Daily[i].Date < report.Daily[j].Date
+	})
+	sort.Slice(report.LineItems, func(i, j int) bool {
+		return report.LineItems[i].AdServerImpressions > report.LineItems[j].AdServerImpressions
+	})
+	sort.Strings(report.UnmatchedCampaigns)
+	sort.Strings(report.UnmatchedLineItems)
+
+	return report, nil
+}
+
+// compare fills in the discrepancies and flags them against threshold
+func (d *DeliveryDiscrepancy) compare(threshold float64) {
+	d.ImpressionDiscrepancy = percentDifference(float64(d.DSPImpressions), float64(d.AdServerImpressions))
+	d.RevenueDiscrepancy = percentDifference(d.DSPSpend, d.AdServerRevenue)
+
+	switch {
+	case d.ImpressionDiscrepancy != nil:
+		d.Flagged = math.Abs(*d.ImpressionDiscrepancy) > threshold
+	default:
+		d.Flagged = d.DSPImpressions > 0
+	}
+}
+
+// percentDifference is how far value is from reference as a percentage of reference, or nil
+// when reference is zero
+func percentDifference(value, reference float64) *float64 {
+	if reference == 0 {
+		return nil
+	}
+	diff := (value - reference) / reference * 100
+	return &diff
+}
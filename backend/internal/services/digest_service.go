@@ -0,0 +1,423 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/mail"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+const (
+	// digestCheckInterval is how often the scheduler checks whether a digest is due
+	digestCheckInterval = time.Hour
+	// digestTopCampaigns is how many campaigns the digest lists
+	digestTopCampaigns = 5
+	// digestMaxAlerts is how many alerts the digest lists
+	digestMaxAlerts = 10
+)
+
+//go:embed templates/weekly_digest.html
+var digestTemplates embed.FS
+
+// digestTemplate renders the weekly digest email
+var digestTemplate = template.Must(template.ParseFS(digestTemplates, "templates/weekly_digest.html"))
+
+// Digest summarizes a user's account activity over a week
+type Digest struct {
+	From           time.Time
+	To             time.Time
+	FilesProcessed int
+	Spend          float64
+	Impressions    int
+	// Campaigns are the top campaigns by spend
+	Campaigns []DigestCampaign
+	// Alerts are the files that failed processing, newest first
+	Alerts []DigestAlert
+}
+
+// DigestCampaign is a campaign's delivery ingested during the week
+type DigestCampaign struct {
+	CampaignID string
+	ingestion.CampaignMetrics
+	// Goals is the week's attainment of the campaign's goal, when it has one
+	Goals []ingestion.GoalAttainment
+}
+
+// DigestAlert is a problem raised during the week
+type DigestAlert struct {
+	Title  string
+	Detail string
+	Time   time.Time
+}
+
+// DigestService emails opted-in users a weekly summary of their account activity
+type DigestService struct {
+	logProcessor *ingestion.LogProcessorService
+	digests      repository.DigestRepository
+	preferences  *PreferencesService
+	sender       mail.Sender
+	goals        *GoalService
+	weekday      time.Weekday
+	hour         int
+}
+
+// NewDigestService creates a digest service sending every week on the weekday and hour given in UTC
+func NewDigestService(logProcessor *ingestion.LogProcessorService, digests repository.DigestRepository, preferences *PreferencesService, sender mail.Sender, weekday time.Weekday, hour int) *DigestService {
+	return &DigestService{
+		logProcessor: logProcessor,
+		digests:      digests,
+		preferences:  preferences,
+		sender:       sender,
+		weekday:      weekday,
+		hour:         hour,
+	}
+}
+
+// SetGoals compares campaigns with their goals in digests, raising an alert for each campaign
+// that missed one
+func (s *DigestService) SetGoals(goals *GoalService) {
+	s.goals = goals
+}
+
+// Run sends the digests due each time it checks until the context is canceled
+func (s *DigestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendDue(ctx, time.Now()); err != nil {
+			slog.Error("Failed to send weekly digests", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends each opted-in user the digest of the latest week ended by now that they haven't been sent
+func (s *DigestService) SendDue(ctx context.Context, now time.Time) error {
+	periodEnd := s.latestPeriodEnd(now)
+
+	recipients, err := s.digests.ListRecipients(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list digest recipients: %w", err)
+	}
+
+	for _, user := range recipients {
+		claimed, err := s.digests.ClaimDelivery(ctx, user.ID, periodEnd, now)
+		if err != nil {
+			return fmt.Errorf("failed to claim digest delivery: %w", err)
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.send(ctx, user, periodEnd); err != nil {
+			slog.Error("Failed to send weekly digest", "userID", user.ID, "error", err)
+			if err := s.digests.ReleaseDelivery(ctx, user.ID, periodEnd); err != nil {
+				slog.Error("Failed to release weekly digest delivery", "userID", user.ID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// BuildDigest summarizes the files a user's account processed in the week ending at periodEnd
+func (s *DigestService) BuildDigest(ctx context.Context, userID string, periodEnd time.Time) (*Digest, error) {
+	results, err := s.logProcessor.ListAnalysisResults(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list analysis results: %w", err)
+	}
+
+	digest := &Digest{
+		From:      periodEnd.AddDate(0, 0, -7),
+		To:        periodEnd,
+		Campaigns: []DigestCampaign{},
+		Alerts:    []DigestAlert{},
+	}
+
+	campaigns := make(map[string]ingestion.CampaignMetrics)
+	var week []*ingestion.LogAnalysisResult
+	for _, result := range results {
+		if result.ProcessedAt.Before(digest.From) || !result.ProcessedAt.Before(digest.To) {
+			continue
+		}
+
+		if result.Status != "completed" {
+			if result.ErrorMessage != "" {
+				digest.Alerts = append(digest.Alerts, DigestAlert{
+					Title:  "Processing failed for " + result.FileName,
+					Detail: result.ErrorMessage,
+					Time:   result.ProcessedAt,
+				})
+			}
+			continue
+		}
+
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return nil, err
+		}
+
+		week = append(week, result)
+		digest.FilesProcessed++
+		digest.Spend += summary.TotalWinCost
+		digest.Impressions += summary.TotalImpressions
+		for campaignID, metrics := range summary.CampaignPerformance {
+			merged := campaigns[campaignID]
+			merged.Bids += metrics.Bids
+			merged.Impressions += metrics.Impressions
+			merged.Clicks += metrics.Clicks
+			merged.Conversions += metrics.Conversions
+			merged.Spend += metrics.Spend
+			merged.Revenue += metrics.Revenue
+			campaigns[campaignID] = merged
+		}
+	}
+
+	var goals map[string]*ingestion.CampaignGoal
+	if s.goals != nil && len(campaigns) > 0 {
+		goals, err = s.goals.GoalsByCampaign(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for campaignID, metrics := range campaigns {
+		if metrics.Impressions > 0 {
+			metrics.CTR = float64(metrics.Clicks) / float64(metrics.Impressions) * 100
+		}
+		if metrics.Spend > 0 {
+			metrics.ROAS = metrics.Revenue / metrics.Spend
+		}
+		if metrics.Conversions > 0 {
+			metrics.CPA = metrics.Spend / float64(metrics.Conversions)
+		}
+
+		attainment, err := s.weekGoalAttainment(ctx, goals[campaignID], week, campaignID)
+		if err != nil {
+			return nil, err
+		}
+		if missed := missedGoals(attainment); missed != "" {
+			digest.Alerts = append(digest.Alerts, DigestAlert{
+				Title:  "Campaign " + campaignID + " missed its goals",
+				Detail: missed,
+				Time:   digest.To,
+			})
+		}
+		digest.Campaigns = append(digest.Campaigns, DigestCampaign{CampaignID: campaignID, CampaignMetrics: metrics, Goals: attainment})
+	}
+	sort.Slice(digest.Campaigns, func(i, j int) bool {
+		if digest.Campaigns[i].Spend != digest.Campaigns[j].Spend {
+			return digest.Campaigns[i].Spend > digest.Campaigns[j].Spend
+		}
+		return digest.Campaigns[i].CampaignID < digest.Campaigns[j].CampaignID
+	})
+	if len(digest.Campaigns) > digestTopCampaigns {
+		digest.Campaigns = digest.Campaigns[:digestTopCampaigns]
+	}
+
+	sort.Slice(digest.Alerts, func(i, j int) bool {
+		return digest.Alerts[i].Time.After(digest.Alerts[j].Time)
+	})
+	if len(digest.Alerts) > digestMaxAlerts {
+		digest.Alerts = digest.Alerts[:digestMaxAlerts]
+	}
+
+	return digest, nil
+}
+
+// weekGoalAttainment compares a campaign's delivery over the week's files with its goal, in
+// the goal's currency. A campaign without a goal, or whose currency has no rates yet, has none.
+func (s *DigestService) weekGoalAttainment(ctx context.Context, goal *ingestion.CampaignGoal, week []*ingestion.LogAnalysisResult, campaignID string) ([]ingestion.GoalAttainment, error) {
+	if goal == nil {
+		return nil, nil
+	}
+
+	rollup, err := ingestion.RollupCampaign(week, campaignID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll up campaign: %w", err)
+	}
+
+	// A week's spend isn't measured against the whole flight's budget
+	weekly := *goal
+	weekly.Budget = nil
+	err = s.goals.EvaluateGoal(ctx, &weekly, rollup)
+	if errors.Is(err, fxrates.ErrNoRate) {
+		slog.Warn("Skipping campaign goal in weekly digest", "campaignID", campaignID, "error", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rollup.Goals, nil
+}
+
+// goalLabels name goal metrics in digests
+var goalLabels = map[string]string{
+	ingestion.GoalCPA:        "CPA",
+	ingestion.GoalCTR:        "CTR",
+	ingestion.GoalROAS:       "ROAS",
+	ingestion.GoalDailySpend: "Daily spend",
+	ingestion.GoalBudget:     "Budget",
+}
+
+// formatAttainment lists goal attainment percentages, such as "CPA 80%, CTR 125%"
+func formatAttainment(attainment []ingestion.GoalAttainment) string {
+	parts := make([]string, len(attainment))
+	for i, goal := range attainment {
+		parts[i] = fmt.Sprintf("%s %.0f%%", goalLabels[goal.Metric], goal.Attainment)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// missedGoals lists the goals attainment fell short of, or returns "" when all were met
+func missedGoals(attainment []ingestion.GoalAttainment) string {
+	var missed []ingestion.GoalAttainment
+	for _, goal := range attainment {
+		if !goal.Met {
+			missed = append(missed, goal)
+		}
+	}
+	if len(missed) == 0 {
+		return ""
+	}
+	return formatAttainment(missed) + " of goal"
+}
+
+// send builds, renders and emails a user's digest
+func (s *DigestService) send(ctx context.Context, user *models.User, periodEnd time.Time) error {
+	digest, err := s.BuildDigest(ctx, user.ID, periodEnd)
+	if err != nil {
+		return err
+	}
+
+	defaults, err := s.preferences.ReportDefaults(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	html, err := renderDigest(user, digest, defaults)
+	if err != nil {
+		return err
+	}
+
+	return s.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Your AdVantage week: %d files, %s spend", digest.FilesProcessed, formatMoney(digest.Spend, defaults.Currency)),
+		HTML:    html,
+	})
+}
+
+// latestPeriodEnd returns the most recent scheduled send time at or before now
+func (s *DigestService) latestPeriodEnd(now time.Time) time.Time {
+	now = now.UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), s.hour, 0, 0, 0, time.UTC)
+	end = end.AddDate(0, 0, -int((now.Weekday()-s.weekday+7)%7))
+	if end.After(now) {
+		end = end.AddDate(0, 0, -7)
+	}
+	return end
+}
+
+// renderDigest renders a digest email with amounts and dates in the user's report defaults
+func renderDigest(user *models.User, digest *Digest, defaults *ReportDefaults) (string, error) {
+	type campaignView struct {
+		CampaignID  string
+		Spend       string
+		Impressions string
+		CTR         string
+		Goals       string
+	}
+	type alertView struct {
+		Title  string
+		Detail string
+		Time   string
+	}
+
+	view := struct {
+		FirstName      string
+		From           string
+		To             string
+		FilesProcessed int
+		Spend          string
+		Impressions    string
+		Campaigns      []campaignView
+		Alerts         []alertView
+	}{
+		FirstName:      user.FirstName,
+		From:           defaults.FormatDate(digest.From),
+		To:             defaults.FormatDate(digest.To.Add(-time.Second)),
+		FilesProcessed: digest.FilesProcessed,
+		Spend:          formatMoney(digest.Spend, defaults.Currency),
+		Impressions:    formatCount(digest.Impressions),
+	}
+	for _, campaign := range digest.Campaigns {
+		view.Campaigns = append(view.Campaigns, campaignView{
+			CampaignID:  campaign.CampaignID,
+			Spend:       formatMoney(campaign.Spend, defaults.Currency),
+			Impressions: formatCount(campaign.Impressions),
+			CTR:         strconv.FormatFloat(campaign.CTR, 'f', 2, 64) + "%",
+			Goals:       formatAttainment(campaign.Goals),
+		})
+	}
+	for _, alert := range digest.Alerts {
+		view.Alerts = append(view.Alerts, alertView{
+			Title:  alert.Title,
+			Detail: alert.Detail,
+			Time:   defaults.FormatTimestamp(alert.Time),
+		})
+	}
+
+	var out bytes.Buffer
+	if err := digestTemplate.Execute(&out, view); err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+// formatMoney formats an amount with its currency code, such as 1,234.50 USD
+func formatMoney(amount float64, currency string) string {
+	whole := int(amount)
+	cents := int((amount-float64(whole))*100 + 0.5)
+	if cents == 100 {
+		whole, cents = whole+1, 0
+	}
+	return fmt.Sprintf("%s.%02d %s", formatCount(whole), cents, currency)
+}
+
+// formatCount formats a count with thousands separators
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	digits := strconv.Itoa(n)
+
+	var out []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, digits[i])
+	}
+	return string(out)
+}
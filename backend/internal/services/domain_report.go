@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bolognesandwiches/AdVantage/internal/adstxt"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// Authorized supply statuses of a domain, summarizing its paths' checks
+const (
+	AuthorizedSupplyAll     = "authorized"
+	AuthorizedSupplyPartial = "partially_authorized"
+	AuthorizedSupplyNone    = "unauthorized"
+	AuthorizedSupplyNoAds   = "no_ads_txt"
+	AuthorizedSupplyUnknown = "unverified"
+)
+
+// SellerPathCheck is a seller path a domain was bought through, with its ads.txt verdict
+type SellerPathCheck struct {
+	ingestion.SellerPath
+	Bids        int     `json:"bids"`
+	Impressions int     `json:"impressions"`
+	Spend       float64 `json:"spend"`
+	adstxt.Check
+}
+
+// AuthorizedSupply summarizes how much of a domain's supply its ads.txt authorizes. Shares
+// cover the bids whose paths could be checked.
+type AuthorizedSupply struct {
+	Status          string            `json:"status"`
+	CheckedBids     int               `json:"checkedBids"`
+	AuthorizedBids  int               `json:"authorizedBids"`
+	AuthorizedShare float64           `json:"authorizedShare"`
+	Paths           []SellerPathCheck `json:"paths"`
+}
+
+// DomainEntry is a domain with its bid volume, its delivery and return on spend, and, when its
+// seller paths were checked, how much of its supply is authorized
+type DomainEntry struct {
+	Domain           string                    `json:"domain"`
+	Bids             int                       `json:"bids"`
+	Share            float64                   `json:"share"`
+	Performance      ingestion.CampaignMetrics `json:"performance"`
+	AuthorizedSupply *AuthorizedSupply         `json:"authorizedSupply"`
+}
+
+// DomainReport lists a page of a processed file's domains by bid volume
+type DomainReport struct {
+	FileID      string        `json:"fileId"`
+	TotalBids   int           `json:"totalBids"`
+	DomainCount int           `json:"domainCount"`
+	Domains     []DomainEntry `json:"domains,omitempty"`
+	// OtherBids are the bids of the domains beyond the summary's top domains, which aren't
+	// listed; the full breakdown pages through them
+	OtherBids int `json:"otherBids,omitempty"`
+	// UnauthorizedSpend is spend on paths ads.txt or sellers.json doesn't authorize
+	UnauthorizedSpend float64 `json:"unauthorizedSpend"`
+}
+
+// SetSupplyValidator enables checking the top maxDomains domains of domain reports against
+// ads.txt and sellers.json
+func (s *AnalyticsService) SetSupplyValidator(validator *adstxt.Validator, maxDomains int) {
+	s.supplyValidator = validator
+	s.maxValidatedDomains = maxDomains
+}
+
+// domainCursor is the sort key of the last domain of a page
+type domainCursor struct {
+	Bids   int    `json:"b"`
+	Domain string `json:"d"`
+}
+
+// GetDomains builds a page of up to limit domains of the domain report for a processed file,
+// starting after the cursor, and returns the cursor of the next page or "" on the last page.
+// Summaries keep only their top domains, so the rest are counted in OtherBids rather than listed.
+// Domains bought through logged seller paths get an authorized supply verdict when a supply
+// validator is set.
+func (s *AnalyticsService) GetDomains(ctx context.Context, fileID, userID, cursor string, limit int) (*DomainReport, string, error) {
+	var after domainCursor
+	paged, err := decodeCursor(cursor, &after)
+	if err != nil {
+		return nil, "", err
+	}
+
+	summary, err := s.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	report := &DomainReport{
+		FileID:  fileID,
+		Domains: make([]DomainEntry, 0, len(summary.DomainBreakdown)),
+	}
+	for domain, bids := range summary.DomainBreakdown {
+		report.TotalBids += bids
+		if domain == ingestion.BreakdownOther {
+			report.OtherBids = bids
+			continue
+		}
+		report.Domains = append(report.Domains, DomainEntry{
+			Domain:      domain,
+			Bids:        bids,
+			Performance: summary.DomainPerformance[domain],
+		})
+	}
+	listed := len(report.Domains)
+	report.DomainCount = listed + summary.TruncatedBreakdowns[ingestion.BreakdownDomains]
+	for i := range report.Domains {
+		report.Domains[i].Share = float64(report.Domains[i].Bids) / float64(report.TotalBids) * 100
+	}
+	sort.Slice(report.Domains, func(i, j int) bool {
+		if report.Domains[i].Bids != report.Domains[j].Bids {
+			return report.Domains[i].Bids > report.Domains[j].Bids
+		}
+		return report.Domains[i].Domain < report.Domains[j].Domain
+	})
+
+	if s.supplyValidator != nil && summary.SupplyPath != nil && len(summary.SupplyPath.Sellers) > 0 {
+		s.checkAuthorizedSupply(ctx, report, summary.SupplyPath)
+	}
+
+	// Keyset pagination: skip past the cursor's domain in bid order
+	start := 0
+	if paged {
+		start = sort.Search(len(report.Domains), func(i int) bool {
+			entry := report.Domains[i]
+			return entry.Bids < after.Bids || (entry.Bids == after.Bids && entry.Domain > after.Domain)
+		})
+	}
+	end := min(start+pageSize(limit), len(report.Domains))
+	report.Domains = report.Domains[start:end]
+
+	next := ""
+	if end < listed {
+		last := report.Domains[len(report.Domains)-1]
+		next = encodeCursor(domainCursor{Bids: last.Bids, Domain: last.Domain})
+	}
+
+	return report, next, nil
+}
+
+// checkAuthorizedSupply checks the seller paths of the report's largest domains against
+// ads.txt and sellers.json, adding up the spend on unauthorized paths
+func (s *AnalyticsService) checkAuthorizedSupply(ctx context.Context, report *DomainReport, supplyPath *ingestion.SupplyPathSummary) {
+	// Check the largest domains' paths
+	paths := make(map[string][]ingestion.SellerPath)
+	for _, entry := range report.Domains {
+		if len(paths) >= s.maxValidatedDomains {
+			break
+		}
+		for key := range supplyPath.Sellers[entry.Domain] {
+			paths[entry.Domain] = append(paths[entry.Domain], ingestion.ParseSellerPath(key))
+		}
+	}
+	checks := s.supplyValidator.Validate(ctx, paths)
+
+	for i := range report.Domains {
+		entry := &report.Domains[i]
+		domainChecks, ok := checks[entry.Domain]
+		if !ok {
+			continue
+		}
+
+		supply := &AuthorizedSupply{Paths: make([]SellerPathCheck, 0, len(domainChecks))}
+		for j, check := range domainChecks {
+			path := paths[entry.Domain][j]
+			metrics := supplyPath.Sellers[entry.Domain][path.Key()]
+			supply.Paths = append(supply.Paths, SellerPathCheck{
+				SellerPath:  path,
+				Bids:        metrics.Bids,
+				Impressions: metrics.Impressions,
+				Spend:       metrics.Spend,
+				Check:       check,
+			})
+
+			switch check.Status {
+			case adstxt.StatusAuthorized:
+				supply.CheckedBids += metrics.Bids
+				supply.AuthorizedBids += metrics.Bids
+			case adstxt.StatusUnauthorized, adstxt.StatusNoAdsTxt:
+				supply.CheckedBids += metrics.Bids
+				report.UnauthorizedSpend += metrics.Spend
+			}
+		}
+		supply.setStatus()
+
+		sort.Slice(supply.Paths, func(a, b int) bool {
+			return supply.Paths[a].Bids > supply.Paths[b].Bids
+		})
+		entry.AuthorizedSupply = supply
+	}
+}
+
+// setStatus summarizes the domain's path checks
+func (a *AuthorizedSupply) setStatus() {
+	if a.CheckedBids > 0 {
+		a.AuthorizedShare = float64(a.AuthorizedBids) / float64(a.CheckedBids) * 100
+	}
+
+	noAdsTxt := true
+	for _, path := range a.Paths {
+		if path.Status != adstxt.StatusNoAdsTxt {
+			noAdsTxt = false
+		}
+	}
+
+	switch {
+	case a.CheckedBids == 0:
+		a.Status = AuthorizedSupplyUnknown
+	case noAdsTxt:
+		a.Status = AuthorizedSupplyNoAds
+	case a.AuthorizedBids == a.CheckedBids:
+		a.Status = AuthorizedSupplyAll
+	case a.AuthorizedBids == 0:
+		a.Status = AuthorizedSupplyNone
+	default:
+		a.Status = AuthorizedSupplyPartial
+	}
+}
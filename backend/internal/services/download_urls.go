@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// defaultDownloadURLExpiry is how long a signed download URL works when no expiry is set
+const defaultDownloadURLExpiry = 15 * time.Minute
+
+// downloadKeyLabel is the HKDF info download keys are derived from a secret with
+const downloadKeyLabel = "download-url"
+
+// Signed download URL errors
+var (
+	// ErrInvalidDownloadToken is returned for a download token that's malformed or wasn't signed
+	// with the server's key
+	ErrInvalidDownloadToken = errors.New("invalid download token")
+	// ErrDownloadTokenExpired is returned for a download token past its expiry
+	ErrDownloadTokenExpired = errors.New("download token expired")
+)
+
+// DeriveDownloadKey derives a key to sign download tokens with from a secret used for something
+// else, such as signing access tokens, so a signature made with one key never verifies with the other
+func DeriveDownloadKey(secret string) ([]byte, error) {
+	return deriveKey(secret, downloadKeyLabel)
+}
+
+// deriveKey derives a key for one use from a secret with HKDF, the label naming the use so keys
+// derived for different uses of the same secret are unrelated
+func deriveKey(secret, label string) ([]byte, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("no secret to derive the %s key from", label)
+	}
+
+	key := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(label)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive %s key: %w", label, err)
+	}
+	return key, nil
+}
+
+// SetDownloadSigning sets the key download tokens are signed with and how long they work for,
+// or the default when expiry is 0. It must be called before tokens are signed.
+func (s *FileService) SetDownloadSigning(key []byte, expiry time.Duration) {
+	if expiry <= 0 {
+		expiry = defaultDownloadURLExpiry
+	}
+	s.downloadKey = key
+	s.downloadExpiry = expiry
+}
+
+// SignDownload returns a short-lived token that downloads one of the user's files without
+// signing in, so a browser can be handed a plain link to a large file, and when it expires.
+// The token is stateless, so it can't be revoked; deleting the file is what stops it working.
+func (s *FileService) SignDownload(ctx context.Context, fileID, userID string) (string, time.Time, error) {
+	if len(s.downloadKey) == 0 {
+		return "", time.Time{}, errors.New("download signing key isn't set")
+	}
+	if _, err := s.files.FindByID(ctx, fileID, userID); err != nil {
+		return "", time.Time{}, fileNotFound(err)
+	}
+
+	expiresAt := time.Now().Add(s.downloadExpiry).Truncate(time.Second)
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%s.%s.%d", fileID, userID, expiresAt.Unix()))
+	return payload + "." + s.downloadSignature(payload), expiresAt, nil
+}
+
+// OpenSignedDownload opens the file a download token was signed for
+func (s *FileService) OpenSignedDownload(ctx context.Context, token string) (*os.File, *FileUploadInfo, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || len(s.downloadKey) == 0 || !hmac.Equal([]byte(signature), []byte(s.downloadSignature(payload))) {
+		return nil, nil, ErrInvalidDownloadToken
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, nil, ErrInvalidDownloadToken
+	}
+	fields := strings.Split(string(decoded), ".")
+	if len(fields) != 3 {
+		return nil, nil, ErrInvalidDownloadToken
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, nil, ErrInvalidDownloadToken
+	}
+	if time.Now().Unix() >= expires {
+		return nil, nil, ErrDownloadTokenExpired
+	}
+
+	ctx, err = ownerContext(ctx, s.users, fields[1])
+	if err != nil {
+		return nil, nil, fileNotFound(err)
+	}
+
+	return s.GetFile(ctx, fields[0], fields[1])
+}
+
+// downloadSignature signs a download token's payload
+func (s *FileService) downloadSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.downloadKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
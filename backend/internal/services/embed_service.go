@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/reportgen"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Embed errors
+var (
+	ErrInvalidEmbed = errors.New("invalid embed")
+	// ErrEmbedNotFound is also returned for revoked and expired embeds, so a token reveals
+	// nothing once it stops working
+	ErrEmbedNotFound = errors.New("embed not found")
+)
+
+// Embed limits
+const (
+	defaultEmbedLimit = 10
+	maxEmbedLimit     = 50
+)
+
+// embedWidgets are the widgets an embed can show
+var embedWidgets = map[string]bool{
+	models.EmbedWidgetDaily:     true,
+	models.EmbedWidgetCampaigns: true,
+	models.EmbedWidgetDomains:   true,
+	models.EmbedWidgetDevices:   true,
+	models.EmbedWidgetGeo:       true,
+}
+
+// EmbedService manages the tokenized embeds users share charts of their processed files with,
+// and renders the charts for them
+type EmbedService struct {
+	embeds       repository.EmbedRepository
+	files        repository.FileRepository
+	users        repository.UserRepository
+	logProcessor *ingestion.LogProcessorService
+}
+
+// NewEmbedService creates a new embed service, reading charts from files' analyses
+func NewEmbedService(repos repository.Repositories, logProcessor *ingestion.LogProcessorService) *EmbedService {
+	return &EmbedService{
+		embeds:       repos.Embeds,
+		files:        repos.Files,
+		users:        repos.Users,
+		logProcessor: logProcessor,
+	}
+}
+
+// ListEmbeds lists a user's embeds, newest first
+func (s *EmbedService) ListEmbeds(ctx context.Context, userID string) ([]*models.Embed, error) {
+	embeds, err := s.embeds.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeds: %w", err)
+	}
+	return embeds, nil
+}
+
+// CreateEmbed validates and saves an embed of one of the user's files, setting its token. The
+// token is only returned here; afterwards just its hash is kept.
+func (s *EmbedService) CreateEmbed(ctx context.Context, embed *models.Embed) error {
+	embed.Name = strings.TrimSpace(embed.Name)
+	if embed.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidEmbed)
+	}
+	if !embedWidgets[embed.Widget] {
+		return fmt.Errorf("%w: widget must be one of daily, campaigns, domains, devices or geo", ErrInvalidEmbed)
+	}
+	if embed.Metric == "" {
+		embed.Metric = ingestion.MetricImpressions
+	}
+	if _, ok := reportMetricLabels[embed.Metric]; !ok {
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidEmbed, embed.Metric)
+	}
+	switch {
+	case embed.Widget == models.EmbedWidgetDaily:
+		embed.Limit = 0
+	case embed.Limit == 0:
+		embed.Limit = defaultEmbedLimit
+	case embed.Limit < 0 || embed.Limit > maxEmbedLimit:
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidEmbed, maxEmbedLimit)
+	}
+	now := time.Now()
+	if embed.ExpiresAt != nil && !embed.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expiry must be in the future", ErrInvalidEmbed)
+	}
+
+	if _, err := s.files.FindByID(ctx, embed.FileID, embed.UserID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to find file: %w", err)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate embed token: %w", err)
+	}
+	embed.ID = uuid.New().String()
+	embed.Token = base64.RawURLEncoding.EncodeToString(token)
+	embed.TokenHash = hashToken(embed.Token)
+	embed.CreatedAt = now
+	embed.RevokedAt = nil
+
+	if err := s.embeds.Create(ctx, embed); err != nil {
+		return fmt.Errorf("failed to create embed: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeEmbed revokes one of a user's embeds, so its token stops working
+func (s *EmbedService) RevokeEmbed(ctx context.Context, id, userID string) error {
+	err := s.embeds.Revoke(ctx, id, userID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrEmbedNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke embed: %w", err)
+	}
+
+	return nil
+}
+
+// GetEmbedChart returns the chart of the active embed with a token
+func (s *EmbedService) GetEmbedChart(ctx context.Context, token string) (*reportgen.Chart, error) {
+	// The embed's org isn't known until it is found, so it is looked up across orgs
+	embed, err := s.embeds.FindByTokenHash(db.AsSystem(ctx), hashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrEmbedNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find embed: %w", err)
+	}
+	if !embed.Active(time.Now()) {
+		return nil, ErrEmbedNotFound
+	}
+	ctx, err = ownerContext(ctx, s.users, embed.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrEmbedNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.logProcessor.GetAnalysisResult(ctx, embed.FileID, embed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		return nil, err
+	}
+
+	return embedChart(embed, summary), nil
+}
+
+// embedChart builds an embed's chart from a summary: a line of daily values, or bars of the
+// top values of a breakdown by spend
+func embedChart(embed *models.Embed, summary *ingestion.BeeswaxLogSummary) *reportgen.Chart {
+	chart := &reportgen.Chart{
+		Title:  embed.Name,
+		Type:   reportgen.ChartBar,
+		Metric: reportMetricLabel(embed.Metric),
+		Labels: []string{},
+		Values: []float64{},
+	}
+
+	if embed.Widget == models.EmbedWidgetDaily {
+		chart.Type = reportgen.ChartLine
+		for _, day := range bundleChartsFor(summary).Daily {
+			chart.Labels = append(chart.Labels, day.Date)
+			chart.Values = append(chart.Values, embedValue(day.CampaignMetrics, embed.Metric))
+		}
+		return chart
+	}
+
+	var breakdown map[string]ingestion.CampaignMetrics
+	switch embed.Widget {
+	case models.EmbedWidgetCampaigns:
+		breakdown = summary.CampaignPerformance
+	case models.EmbedWidgetDomains:
+		breakdown = summary.DomainPerformance
+	case models.EmbedWidgetDevices:
+		breakdown = make(map[string]ingestion.CampaignMetrics)
+		for _, devices := range summary.CampaignDevices {
+			for device, metrics := range devices {
+				total := breakdown[device]
+				total.Add(metrics)
+				breakdown[device] = total
+			}
+		}
+	case models.EmbedWidgetGeo:
+		breakdown = make(map[string]ingestion.CampaignMetrics, len(summary.Geo))
+		for country, node := range summary.Geo {
+			metrics := ingestion.CampaignMetrics{}
+			metrics.Add(ingestion.CampaignMetrics{
+				Bids:        node.Bids,
+				Impressions: node.Impressions,
+				Clicks:      node.Clicks,
+				Conversions: node.Conversions,
+				Spend:       node.Spend,
+			})
+			breakdown[country] = metrics
+		}
+	}
+
+	values := make([]string, 0, len(breakdown))
+	for value := range breakdown {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := breakdown[values[i]], breakdown[values[j]]
+		if a.Spend != b.Spend {
+			return a.Spend > b.Spend
+		}
+		return values[i] < values[j]
+	})
+	if len(values) > embed.Limit {
+		values = values[:embed.Limit]
+	}
+	for _, value := range values {
+		chart.Labels = append(chart.Labels, value)
+		chart.Values = append(chart.Values, embedValue(breakdown[value], embed.Metric))
+	}
+
+	return chart
+}
+
+// embedValue returns a base metric's value as a float
+func embedValue(metrics ingestion.CampaignMetrics, metric string) float64 {
+	switch value := reportMetricValue(metrics, metric).(type) {
+	case int:
+		return float64(value)
+	case float64:
+		return value
+	}
+	return 0
+}
+
+// hashToken hashes an embed token, API key or refresh token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
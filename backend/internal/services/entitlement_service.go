@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Entitlement errors
+var (
+	ErrFeatureNotInPlan = errors.New("feature not included in plan")
+	ErrDailyUploadLimit = errors.New("daily upload limit reached")
+	ErrInvalidPlan      = errors.New("invalid plan")
+)
+
+// featureNames are the gated features' names in messages
+var featureNames = map[string]string{
+	models.FeatureAdvancedAnalytics: "advanced analytics",
+	models.FeatureConnectors:        "connectors",
+}
+
+// Entitlements is what an org's plan allows right now, for the frontend to show and gate
+// features with
+type Entitlements struct {
+	Plan             string          `json:"plan"`
+	TrialEndsAt      *time.Time      `json:"trialEndsAt,omitempty"` // Only set for trials
+	TrialExpired     bool            `json:"trialExpired"`
+	MaxUploadsPerDay int             `json:"maxUploadsPerDay"` // 0 is unlimited
+	UploadsToday     int             `json:"uploadsToday"`     // Since midnight UTC
+	Features         map[string]bool `json:"features"`         // Every gated feature, and whether the plan includes it
+}
+
+// EntitlementService decides what an org may do from its plan and trial: how many files it may
+// upload a day, and which gated features it may use
+type EntitlementService struct {
+	orgs  repository.OrganizationRepository
+	users repository.UserRepository
+	jobs  repository.JobRepository
+}
+
+// NewEntitlementService creates a new entitlement service
+func NewEntitlementService(repos repository.Repositories) *EntitlementService {
+	return &EntitlementService{
+		orgs:  repos.Orgs,
+		users: repos.Users,
+		jobs:  repos.Jobs,
+	}
+}
+
+// Entitlements returns what an org's plan allows right now
+func (s *EntitlementService) Entitlements(ctx context.Context, orgID string) (*Entitlements, error) {
+	org, err := s.org(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	limits := org.Limits(now)
+	uploads, err := s.jobs.CountUploadsSince(ctx, orgID, now.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count uploads: %w", err)
+	}
+
+	entitlements := &Entitlements{
+		Plan:             org.Plan,
+		MaxUploadsPerDay: limits.MaxUploadsPerDay,
+		UploadsToday:     uploads,
+		Features:         make(map[string]bool, len(models.Features)),
+	}
+	if org.Plan == models.PlanTrial {
+		trialEnd := org.TrialEnd()
+		entitlements.TrialEndsAt = &trialEnd
+		entitlements.TrialExpired = !now.Before(trialEnd)
+	}
+	for _, feature := range models.Features {
+		entitlements.Features[feature] = limits.Features[feature]
+	}
+
+	return entitlements, nil
+}
+
+// RequireFeature returns ErrFeatureNotInPlan, explaining why, when an org's plan doesn't
+// include a feature
+func (s *EntitlementService) RequireFeature(ctx context.Context, orgID, feature string) error {
+	org, err := s.org(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if org.Limits(now).Features[feature] {
+		return nil
+	}
+	if org.Plan == models.PlanTrial && !now.Before(org.TrialEnd()) {
+		return fmt.Errorf("%w: your trial has ended; upgrade to keep using %s", ErrFeatureNotInPlan, featureNames[feature])
+	}
+	return fmt.Errorf("%w: the %s plan doesn't include %s; upgrade to use them", ErrFeatureNotInPlan, org.Plan, featureNames[feature])
+}
+
+// RequireUserFeature is RequireFeature for the org a user belongs to, for background jobs that
+// only know whose they are, such as scheduled syncs, so they stop once the plan no longer
+// includes the feature
+func (s *EntitlementService) RequireUserFeature(ctx context.Context, userID, feature string) error {
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.RequireFeature(ctx, user.OrgID, feature)
+}
+
+// UploadsRemaining returns how many more files an org may upload today, or -1 when its plan
+// doesn't limit uploads. Once none are left it returns ErrDailyUploadLimit, saying when the
+// limit resets.
+func (s *EntitlementService) UploadsRemaining(ctx context.Context, orgID string) (int, error) {
+	org, err := s.org(ctx, orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	limit := org.Limits(now).MaxUploadsPerDay
+	if limit == 0 {
+		return -1, nil
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	uploads, err := s.jobs.CountUploadsSince(ctx, orgID, today)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count uploads: %w", err)
+	}
+	if uploads >= limit {
+		return 0, fmt.Errorf("%w: your plan allows %d uploads a day, which resets at %s", ErrDailyUploadLimit, limit, today.AddDate(0, 0, 1).Format(time.RFC3339))
+	}
+	return limit - uploads, nil
+}
+
+// SetPlan moves an org to a plan. trialEndsAt sets when a trial ends, and is only kept for
+// trials; nil ends it TrialDays after the org was created.
+func (s *EntitlementService) SetPlan(ctx context.Context, orgID, plan string, trialEndsAt *time.Time) (*models.Organization, error) {
+	if !models.ValidPlan(plan) {
+		return nil, fmt.Errorf("%w: plan must be free, trial, pro or enterprise", ErrInvalidPlan)
+	}
+	if plan != models.PlanTrial {
+		trialEndsAt = nil
+	}
+
+	if err := s.orgs.SetPlan(ctx, orgID, plan, trialEndsAt); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to set plan: %w", err)
+	}
+
+	return s.org(ctx, orgID)
+}
+
+// org returns an org
+func (s *EntitlementService) org(ctx context.Context, orgID string) (*models.Organization, error) {
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
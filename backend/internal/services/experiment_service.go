@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Experiment limits
+const (
+	defaultExperimentConfidence = 0.95
+	minExperimentConfidence     = 0.8
+	maxExperimentConfidence     = 0.999
+	maxExperimentGroupValues    = 100
+)
+
+// Experiment errors
+var (
+	ErrInvalidExperiment  = errors.New("invalid experiment")
+	ErrExperimentNotFound = errors.New("experiment not found")
+)
+
+// ExperimentService manages users' A/B tests, comparing the delivery of two groups of creatives
+// or campaigns over a date range from their daily rollups
+type ExperimentService struct {
+	experiments repository.ExperimentRepository
+	rollups     *RollupService
+}
+
+// NewExperimentService creates a new experiment service
+func NewExperimentService(repos repository.Repositories, rollups *RollupService) *ExperimentService {
+	return &ExperimentService{
+		experiments: repos.Experiments,
+		rollups:     rollups,
+	}
+}
+
+// ListExperiments lists a user's experiments, newest first
+func (s *ExperimentService) ListExperiments(ctx context.Context, userID string) ([]*models.Experiment, error) {
+	experiments, err := s.experiments.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	return experiments, nil
+}
+
+// GetExperiment returns one of a user's experiments with the result of its latest analysis
+func (s *ExperimentService) GetExperiment(ctx context.Context, id, userID string) (*models.Experiment, error) {
+	experiment, err := s.experiments.FindByID(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment: %w", err)
+	}
+	return experiment, nil
+}
+
+// CreateExperiment validates and saves an experiment, then analyzes it with the delivery
+// ingested so far
+func (s *ExperimentService) CreateExperiment(ctx context.Context, experiment *models.Experiment) error {
+	if err := validateExperiment(experiment); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	experiment.ID = uuid.New().String()
+	experiment.CreatedAt = now
+	experiment.UpdatedAt = now
+	if err := s.experiments.Create(ctx, experiment); err != nil {
+		return fmt.Errorf("failed to create experiment: %w", err)
+	}
+
+	return s.analyze(ctx, experiment)
+}
+
+// AnalyzeExperiment analyzes one of a user's experiments again, taking in delivery ingested
+// since it was last analyzed, and stores the result
+func (s *ExperimentService) AnalyzeExperiment(ctx context.Context, id, userID string) (*models.Experiment, error) {
+	experiment, err := s.GetExperiment(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.analyze(ctx, experiment); err != nil {
+		return nil, err
+	}
+	return experiment, nil
+}
+
+// DeleteExperiment removes one of a user's experiments
+func (s *ExperimentService) DeleteExperiment(ctx context.Context, id, userID string) error {
+	err := s.experiments.Delete(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrExperimentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete experiment: %w", err)
+	}
+	return nil
+}
+
+// analyze sums each group's daily rollups over the experiment's dates, compares their rates
+// and stores the result on the experiment
+func (s *ExperimentService) analyze(ctx context.Context, experiment *models.Experiment) error {
+	var control, variant ingestion.CampaignMetrics
+	pending, err := s.rollups.ScanDailyRollups(ctx, experiment.UserID, experiment.Dimension, &experiment.From, &experiment.To, func(rollup ingestion.Rollup) error {
+		switch {
+		case slices.Contains(experiment.Control.Values, rollup.Value):
+			control.Impressions += rollup.Impressions
+			control.Clicks += rollup.Clicks
+			control.Conversions += rollup.Conversions
+			control.Spend += rollup.Spend
+		case slices.Contains(experiment.Variant.Values, rollup.Value):
+			variant.Impressions += rollup.Impressions
+			variant.Clicks += rollup.Clicks
+			variant.Conversions += rollup.Conversions
+			variant.Spend += rollup.Spend
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read experiment delivery: %w", err)
+	}
+
+	result := &models.ExperimentResult{
+		Control: experimentArm(control),
+		Variant: experimentArm(variant),
+		Lifts: []models.MetricLift{
+			compareRates(models.ExperimentMetricCTR, control.Clicks, control.Impressions, variant.Clicks, variant.Impressions, experiment.Confidence),
+			compareRates(models.ExperimentMetricCVR, control.Conversions, control.Impressions, variant.Conversions, variant.Impressions, experiment.Confidence),
+		},
+		PendingFiles: pending,
+		AnalyzedAt:   time.Now(),
+	}
+	if err := s.experiments.SaveResult(ctx, experiment.ID, experiment.UserID, result); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrExperimentNotFound
+		}
+		return fmt.Errorf("failed to save experiment result: %w", err)
+	}
+
+	experiment.Result = result
+	experiment.UpdatedAt = result.AnalyzedAt
+	return nil
+}
+
+// validateExperiment checks an experiment's definition, tidying its names and group values
+func validateExperiment(experiment *models.Experiment) error {
+	experiment.Name = strings.TrimSpace(experiment.Name)
+	if experiment.Name == "" || len(experiment.Name) > 255 {
+		return fmt.Errorf("%w: name is required and can't be longer than 255 characters", ErrInvalidExperiment)
+	}
+	if experiment.Dimension != models.ExperimentByCreative && experiment.Dimension != models.ExperimentByCampaign {
+		return fmt.Errorf("%w: dimension must be %s or %s", ErrInvalidExperiment, models.ExperimentByCreative, models.ExperimentByCampaign)
+	}
+
+	groups := []struct {
+		group *models.ExperimentGroup
+		name  string
+	}{
+		{&experiment.Control, "Control"},
+		{&experiment.Variant, "Variant"},
+	}
+	for _, g := range groups {
+		g.group.Name = strings.TrimSpace(g.group.Name)
+		if g.group.Name == "" {
+			g.group.Name = g.name
+		}
+
+		values := make([]string, 0, len(g.group.Values))
+		for _, value := range g.group.Values {
+			value = strings.TrimSpace(value)
+			if value != "" && !slices.Contains(values, value) {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 || len(values) > maxExperimentGroupValues {
+			return fmt.Errorf("%w: %s group needs between 1 and %d %s IDs", ErrInvalidExperiment, strings.ToLower(g.name), maxExperimentGroupValues, experiment.Dimension)
+		}
+		g.group.Values = values
+	}
+	for _, value := range experiment.Variant.Values {
+		if slices.Contains(experiment.Control.Values, value) {
+			return fmt.Errorf("%w: %s %s is in both groups", ErrInvalidExperiment, experiment.Dimension, value)
+		}
+	}
+
+	if experiment.From.IsZero() || experiment.To.IsZero() {
+		return fmt.Errorf("%w: from and to dates are required", ErrInvalidExperiment)
+	}
+	if experiment.To.Before(experiment.From) {
+		return fmt.Errorf("%w: 'to' must not be before 'from'", ErrInvalidExperiment)
+	}
+
+	if experiment.Confidence == 0 {
+		experiment.Confidence = defaultExperimentConfidence
+	}
+	if experiment.Confidence < minExperimentConfidence || experiment.Confidence > maxExperimentConfidence {
+		return fmt.Errorf("%w: confidence must be between %g and %g", ErrInvalidExperiment, minExperimentConfidence, maxExperimentConfidence)
+	}
+
+	return nil
+}
+
+// experimentArm summarizes a group's delivery with its rates
+func experimentArm(metrics ingestion.CampaignMetrics) models.ExperimentArm {
+	return models.ExperimentArm{
+		Impressions: metrics.Impressions,
+		Clicks:      metrics.Clicks,
+		Conversions: metrics.Conversions,
+		Spend:       metrics.Spend,
+		CTR:         percentOf(metrics.Clicks, metrics.Impressions),
+		CVR:         percentOf(metrics.Conversions, metrics.Impressions),
+	}
+}
+
+// compareRates measures the variant's lift over the control in a rate of hits per trial, with a
+// confidence interval on the ratio of the rates. The interval is taken on the log of the ratio,
+// the Katz method, which keeps it skewed the way lift is. The lift is left out when either group
+// has no hits, or more hits than trials, as with view-through conversions.
+func compareRates(metric string, controlHits, controlTrials, variantHits, variantTrials int, confidence float64) models.MetricLift {
+	lift := models.MetricLift{
+		Metric:  metric,
+		Control: percentOf(controlHits, controlTrials),
+		Variant: percentOf(variantHits, variantTrials),
+	}
+	if controlHits == 0 || variantHits == 0 || controlHits > controlTrials || variantHits > variantTrials {
+		return lift
+	}
+
+	ratio := lift.Variant / lift.Control
+	stdErr := math.Sqrt(1/float64(variantHits) - 1/float64(variantTrials) + 1/float64(controlHits) - 1/float64(controlTrials))
+	z := math.Sqrt2 * math.Erfinv(confidence)
+
+	value := (ratio - 1) * 100
+	lower := (ratio*math.Exp(-z*stdErr) - 1) * 100
+	upper := (ratio*math.Exp(z*stdErr) - 1) * 100
+	pValue := 1.0
+	if stdErr > 0 {
+		pValue = math.Erfc(math.Abs(math.Log(ratio)) / stdErr / math.Sqrt2)
+	}
+
+	lift.Lift, lift.Lower, lift.Upper, lift.PValue = &value, &lower, &upper, &pValue
+	lift.Significant = lower > 0 || upper < 0
+	return lift
+}
+
+// percentOf returns part as a percentage of whole, or 0 when whole is 0
+func percentOf(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
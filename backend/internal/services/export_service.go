@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/integrations"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/warehouse"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+	"github.com/google/uuid"
+)
+
+// exportCheckInterval is how often the scheduler looks for destinations due a sync
+const exportCheckInterval = 5 * time.Minute
+
+// Export errors
+var (
+	ErrExportUnavailable         = errors.New("exports are not configured on this server")
+	ErrExportDestinationNotFound = errors.New("export destination not found")
+	ErrExportTableNotFound       = errors.New("export table not found")
+	ErrInvalidExportDestination  = warehouse.ErrInvalidDestination
+)
+
+// exportFilesTable lists a user's processed files. It is replaced on every sync, so files
+// deleted since are removed.
+var exportFilesTable = warehouse.Table{
+	Name: "ADVANTAGE_FILES",
+	Columns: []warehouse.Column{
+		{Name: "ID", Type: warehouse.TypeString},
+		{Name: "FILE_NAME", Type: warehouse.TypeString},
+		{Name: "FILE_TYPE", Type: warehouse.TypeString},
+		{Name: "FILE_SIZE", Type: warehouse.TypeInteger},
+		{Name: "UPLOADED_AT", Type: warehouse.TypeTimestamp},
+	},
+}
+
+// exportRollupsTable holds the hourly and daily rollups of a user's processed files. Only files
+// rolled up since the last sync are loaded, replacing their rows, and rows of files no longer in
+// the files table are removed.
+var exportRollupsTable = warehouse.Table{
+	Name: "ADVANTAGE_ROLLUPS",
+	Columns: []warehouse.Column{
+		{Name: "FILE_ID", Type: warehouse.TypeString},
+		{Name: "GRAIN", Type: warehouse.TypeString},
+		{Name: "DIMENSION", Type: warehouse.TypeString},
+		{Name: "VALUE", Type: warehouse.TypeString},
+		{Name: "BUCKET", Type: warehouse.TypeTimestamp},
+		{Name: "BIDS", Type: warehouse.TypeInteger},
+		{Name: "IMPRESSIONS", Type: warehouse.TypeInteger},
+		{Name: "CLICKS", Type: warehouse.TypeInteger},
+		{Name: "CONVERSIONS", Type: warehouse.TypeInteger},
+		{Name: "SPEND", Type: warehouse.TypeFloat},
+		{Name: "REVENUE", Type: warehouse.TypeFloat},
+		{Name: "MEASURABLE_IMPRESSIONS", Type: warehouse.TypeInteger},
+		{Name: "VIEWABLE_IMPRESSIONS", Type: warehouse.TypeInteger},
+	},
+	Key:    "FILE_ID",
+	Parent: &warehouse.Reference{Table: "ADVANTAGE_FILES", Column: "ID"},
+}
+
+// exportTable is a table synced to every destination
+type exportTable struct {
+	name  string // as its sync status is reported
+	table warehouse.Table
+	// incremental tables are loaded from the previous sync's cursor; others are replaced
+	incremental bool
+	write       func(s *ExportService, ctx context.Context, userID string, since *time.Time, w *warehouse.LoadWriter) error
+}
+
+// exportTables are synced in order; files come first since rollups are pruned against them
+var exportTables = []exportTable{
+	{name: "files", table: exportFilesTable, write: (*ExportService).writeFiles},
+	{name: "rollups", table: exportRollupsTable, incremental: true, write: (*ExportService).writeRollups},
+}
+
+// ExportService exports users' files and rollups to the data warehouses they connect, on a
+// schedule and on request, tracking each table's sync
+type ExportService struct {
+	exports      repository.ExportRepository
+	files        repository.FileRepository
+	rollups      repository.RollupRepository
+	workers      *worker.Manager
+	entitlements *EntitlementService
+	cipher       *integrations.TokenCipher
+	syncing      sync.Map // destination ID → struct{}, for syncs queued or running
+}
+
+// NewExportService creates an export service. The cipher encrypts destination credentials and
+// may be nil, in which case destinations can't be created.
+func NewExportService(repos repository.Repositories, workers *worker.Manager, entitlements *EntitlementService, cipher *integrations.TokenCipher) *ExportService {
+	return &ExportService{
+		exports:      repos.Exports,
+		files:        repos.Files,
+		rollups:      repos.Rollups,
+		workers:      workers,
+		entitlements: entitlements,
+		cipher:       cipher,
+	}
+}
+
+// CreateDestination validates and stores a destination with its credentials encrypted, and
+// queues its first sync
+func (s *ExportService) CreateDestination(ctx context.Context, destination *models.ExportDestination, credentials map[string]string) error {
+	if s.cipher == nil {
+		return ErrExportUnavailable
+	}
+
+	destination.Name = strings.TrimSpace(destination.Name)
+	if destination.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidExportDestination)
+	}
+	if destination.Settings == nil {
+		destination.Settings = map[string]string{}
+	}
+	if _, err := warehouse.New(destination.Provider, destination.Settings, credentials); err != nil {
+		return err
+	}
+
+	sealed, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	if destination.Credentials, err = s.cipher.Encrypt(string(sealed)); err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	now := time.Now()
+	destination.ID = uuid.New().String()
+	destination.Status = models.ExportStatusActive
+	destination.CreatedAt = now
+	destination.UpdatedAt = now
+	if err := s.exports.Create(ctx, destination); err != nil {
+		return fmt.Errorf("failed to create export destination: %w", err)
+	}
+
+	return s.submitSync(destination)
+}
+
+// ListDestinations lists a user's export destinations
+func (s *ExportService) ListDestinations(ctx context.Context, userID string) ([]*models.ExportDestination, error) {
+	destinations, err := s.exports.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export destinations: %w", err)
+	}
+
+	return destinations, nil
+}
+
+// DeleteDestination stops exporting to a destination. What was exported stays in the warehouse.
+func (s *ExportService) DeleteDestination(ctx context.Context, id, userID string) error {
+	if err := s.exports.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrExportDestinationNotFound
+		}
+		return fmt.Errorf("failed to delete export destination: %w", err)
+	}
+
+	return nil
+}
+
+// SyncDestination queues an immediate sync of a user's destination
+func (s *ExportService) SyncDestination(ctx context.Context, id, userID string) error {
+	destination, err := s.findDestination(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.submitSync(destination)
+}
+
+// ListTableSyncs reports the sync of each table exported to a user's destination; tables not
+// synced yet are pending
+func (s *ExportService) ListTableSyncs(ctx context.Context, id, userID string) ([]*models.ExportTableSync, error) {
+	destination, err := s.findDestination(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.exports.ListTableSyncs(ctx, destination.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export table syncs: %w", err)
+	}
+	byTable := make(map[string]*models.ExportTableSync, len(stored))
+	for _, tableSync := range stored {
+		byTable[tableSync.Table] = tableSync
+	}
+
+	syncs := make([]*models.ExportTableSync, 0, len(exportTables))
+	for _, table := range exportTables {
+		tableSync, ok := byTable[table.name]
+		if !ok {
+			tableSync = &models.ExportTableSync{
+				DestinationID: destination.ID,
+				Table:         table.name,
+				Status:        models.ExportTablePending,
+				UpdatedAt:     destination.CreatedAt,
+			}
+		}
+		syncs = append(syncs, tableSync)
+	}
+
+	return syncs, nil
+}
+
+// GetTableSync reports the sync of one table exported to a user's destination
+func (s *ExportService) GetTableSync(ctx context.Context, id, userID, table string) (*models.ExportTableSync, error) {
+	syncs, err := s.ListTableSyncs(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tableSync := range syncs {
+		if tableSync.Table == table {
+			return tableSync, nil
+		}
+	}
+
+	return nil, ErrExportTableNotFound
+}
+
+// findDestination finds a user's destination, returning ErrExportDestinationNotFound when it doesn't exist
+func (s *ExportService) findDestination(ctx context.Context, id, userID string) (*models.ExportDestination, error) {
+	destination, err := s.exports.FindByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrExportDestinationNotFound
+		}
+		return nil, fmt.Errorf("failed to find export destination: %w", err)
+	}
+
+	return destination, nil
+}
+
+// Run queues syncs of destinations not synced within interval until the context is canceled
+func (s *ExportService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(exportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		due, err := s.exports.ListDue(ctx, time.Now().Add(-interval))
+		if err != nil {
+			slog.Error("Failed to list export destinations due a sync", "error", err)
+		}
+		for _, destination := range due {
+			if err := s.submitSync(destination); err != nil {
+				slog.Error("Failed to queue export sync", "destinationID", destination.ID, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// submitSync queues a sync in the background unless one is already queued or running
+func (s *ExportService) submitSync(destination *models.ExportDestination) error {
+	if _, busy := s.syncing.LoadOrStore(destination.ID, struct{}{}); busy {
+		return nil
+	}
+
+	err := s.workers.Submit(worker.Task{
+		ID: "export-sync-" + destination.ID,
+		Run: func(ctx context.Context) error {
+			defer s.syncing.Delete(destination.ID)
+			return s.sync(ctx, destination)
+		},
+	})
+	if err != nil {
+		s.syncing.Delete(destination.ID)
+		return fmt.Errorf("failed to queue sync: %w", err)
+	}
+
+	return nil
+}
+
+// sync exports every table to a destination and records the outcome. A table that fails doesn't
+// stop the others; the destination reports the first failure.
+func (s *ExportService) sync(ctx context.Context, destination *models.ExportDestination) error {
+	// Exports stop once a trial ends or the plan is downgraded
+	err := s.entitlements.RequireUserFeature(ctx, destination.UserID, models.FeatureConnectors)
+	if err == nil {
+		err = s.syncTables(ctx, destination)
+	}
+
+	status, lastError := models.ExportStatusActive, ""
+	if err != nil {
+		status, lastError = models.ExportStatusError, err.Error()
+	}
+	if updateErr := s.exports.UpdateSyncStatus(ctx, destination.ID, status, lastError, time.Now()); updateErr != nil && !errors.Is(updateErr, repository.ErrNotFound) {
+		return fmt.Errorf("failed to update sync status: %w", updateErr)
+	}
+
+	return err
+}
+
+// syncTables opens the destination and loads each table into it
+func (s *ExportService) syncTables(ctx context.Context, destination *models.ExportDestination) error {
+	if s.cipher == nil {
+		return ErrExportUnavailable
+	}
+
+	decrypted, err := s.cipher.Decrypt(destination.Credentials)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+	var credentials map[string]string
+	if err := json.Unmarshal([]byte(decrypted), &credentials); err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+	dest, err := warehouse.New(destination.Provider, destination.Settings, credentials)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.exports.ListTableSyncs(ctx, destination.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list export table syncs: %w", err)
+	}
+	previous := make(map[string]*models.ExportTableSync, len(stored))
+	for _, tableSync := range stored {
+		previous[tableSync.Table] = tableSync
+	}
+
+	var firstErr error
+	for _, table := range exportTables {
+		if err := s.syncTable(ctx, destination, dest, table, previous[table.name]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", table.name, err)
+		}
+	}
+	return firstErr
+}
+
+// syncTable loads one table, recording its progress and outcome
+func (s *ExportService) syncTable(ctx context.Context, destination *models.ExportDestination, dest warehouse.Destination, table exportTable, state *models.ExportTableSync) error {
+	started := time.Now()
+	if state == nil {
+		state = &models.ExportTableSync{DestinationID: destination.ID, Table: table.name}
+	}
+	state.Status = models.ExportTableSyncing
+	state.LastSyncedAt = &started
+	state.UpdatedAt = started
+	if err := s.exports.SaveTableSync(ctx, state); err != nil {
+		return fmt.Errorf("failed to save table sync: %w", err)
+	}
+
+	rows, err := s.load(ctx, destination.UserID, dest, table, state.Cursor)
+
+	finished := time.Now()
+	state.UpdatedAt = finished
+	if err != nil {
+		state.Status = models.ExportTableFailed
+		state.LastError = err.Error()
+	} else {
+		state.Status = models.ExportTableSucceeded
+		state.LastError = ""
+		state.Rows = rows
+		state.LastSucceededAt = &finished
+		if table.incremental {
+			// Files rolled up while the load ran are loaded again next time, which is harmless
+			state.Cursor = &started
+		}
+	}
+	if saveErr := s.exports.SaveTableSync(ctx, state); saveErr != nil && err == nil {
+		return fmt.Errorf("failed to save table sync: %w", saveErr)
+	}
+
+	return err
+}
+
+// load writes a table's rows since the cursor and loads them into the destination, returning
+// how many rows were loaded
+func (s *ExportService) load(ctx context.Context, userID string, dest warehouse.Destination, table exportTable, cursor *time.Time) (int64, error) {
+	w, err := warehouse.NewLoadWriter(table.table)
+	if err != nil {
+		return 0, err
+	}
+	var since *time.Time
+	if table.incremental {
+		since = cursor
+	}
+	if err := table.write(s, ctx, userID, since, w); err != nil {
+		return 0, err
+	}
+
+	load, err := w.Load()
+	if err != nil {
+		return 0, err
+	}
+	if err := dest.Load(ctx, load); err != nil {
+		return 0, err
+	}
+	return load.Rows, nil
+}
+
+// writeFiles writes the user's processed files
+func (s *ExportService) writeFiles(ctx context.Context, userID string, _ *time.Time, w *warehouse.LoadWriter) error {
+	files, err := s.files.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, file := range files {
+		if file.Status != models.FileStatusProcessed {
+			continue
+		}
+		if err := w.Write(file.ID, file.FileName, file.FileType, file.FileSize, file.UploadedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRollups writes the rollups of the user's files rolled up since the cursor, or of every
+// file on the first sync
+func (s *ExportService) writeRollups(ctx context.Context, userID string, since *time.Time, w *warehouse.LoadWriter) error {
+	var from time.Time
+	if since != nil {
+		from = *since
+	}
+	fileIDs, err := s.rollups.ListRolledUpSince(ctx, userID, from)
+	if err != nil {
+		return fmt.Errorf("failed to list rolled up files: %w", err)
+	}
+
+	for _, fileID := range fileIDs {
+		err := s.rollups.ScanFileRollups(ctx, fileID, userID, func(rollup ingestion.Rollup) error {
+			return w.Write(fileID, rollup.Grain, rollup.Dimension, rollup.Value, rollup.Bucket,
+				rollup.Bids, rollup.Impressions, rollup.Clicks, rollup.Conversions, rollup.Spend, rollup.Revenue,
+				rollup.MeasurableImpressions, rollup.ViewableImpressions)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read rollups of file %s: %w", fileID, err)
+		}
+	}
+	return nil
+}
@@ -2,13 +2,26 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"mime/multipart"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/events"
 	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
 	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
 // FileUploadInfo contains information about an uploaded file
@@ -19,40 +32,209 @@ type FileUploadInfo struct {
 	FileType   string    `json:"fileType"`
 	UploadedAt time.Time `json:"uploadedAt"`
 	Status     string    `json:"status"`
+	JobID      string    `json:"jobId,omitempty"`
+	Priority   string    `json:"priority,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Folder     string    `json:"folder,omitempty"`
+	// Replayed is set when the upload was returned for a repeated idempotency key
+	Replayed bool `json:"-"`
 }
 
+// File service errors
+var (
+	// ErrFileTypeNotAllowed is returned when an upload's content type isn't a supported log format
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+	// ErrInvalidPriority is returned for a job priority other than low, normal or high
+	ErrInvalidPriority = errors.New("invalid priority")
+	// ErrJobNotFound is returned when a processing job doesn't exist or belongs to another user
+	ErrJobNotFound = errors.New("job not found")
+	// ErrNoActiveJob is returned when canceling processing of a file that has no queued or running job
+	ErrNoActiveJob = errors.New("file has no queued or running job")
+	// ErrJobActive is returned when reprocessing a file that is already queued or being processed
+	ErrJobActive = errors.New("file is already queued or being processed")
+	// ErrInvalidParseOptions is returned when a reprocess names an unknown parser or log format
+	ErrInvalidParseOptions = errors.New("invalid parse options")
+)
+
+// idempotencyKeyTTL is how long an idempotency key is remembered
+const idempotencyKeyTTL = 24 * time.Hour
+
+// jobRetryDelay is how long a failed job waits before its first retry; the wait doubles with
+// each further attempt
+const jobRetryDelay = 30 * time.Second
+
 // FileService handles file operations
 type FileService struct {
-	fileStorage  *storage.FileStorage
-	logProcessor *ingestion.LogProcessorService
+	fileStorage    *storage.FileStorage
+	logProcessor   *ingestion.LogProcessorService
+	resultCache    *ResultCache
+	files          repository.FileRepository
+	fileReader     repository.FileRepository
+	jobs           repository.JobRepository
+	orgs           repository.OrganizationRepository
+	users          repository.UserRepository
+	idempotency    repository.IdempotencyRepository
+	batches        repository.BatchRepository
+	uow            repository.UnitOfWork
+	workers        *worker.Manager
+	progress       *JobProgressTracker
+	processing     singleflight.Group
+	maxUpload      atomic.Int64
+	maxAttempts    int
+	decompress     storage.DecompressionLimits
+	downloadKey    []byte
+	downloadExpiry time.Duration
+	events         events.Publisher
 }
 
-// NewFileService creates a new file service
-func NewFileService(fileStorage *storage.FileStorage, logProcessor *ingestion.LogProcessorService) *FileService {
-	return &FileService{
+// NewFileService creates a new file service. Listings are served from readRepos, which may lag repos.
+func NewFileService(fileStorage *storage.FileStorage, logProcessor *ingestion.LogProcessorService, resultCache *ResultCache,
+	repos, readRepos repository.Repositories, uow repository.UnitOfWork, workers *worker.Manager) *FileService {
+	service := &FileService{
 		fileStorage:  fileStorage,
 		logProcessor: logProcessor,
+		resultCache:  resultCache,
+		files:        repos.Files,
+		fileReader:   readRepos.Files,
+		jobs:         repos.Jobs,
+		orgs:         repos.Orgs,
+		users:        repos.Users,
+		idempotency:  repos.Idempotency,
+		batches:      repos.Batches,
+		uow:          uow,
+		workers:      workers,
+		progress:     NewJobProgressTracker(),
+		maxAttempts:  1,
 	}
+	service.maxUpload.Store(50 << 20)
+
+	return service
+}
+
+// SetMaxUploadSize changes the largest accepted upload, in bytes
+func (s *FileService) SetMaxUploadSize(size int64) {
+	s.maxUpload.Store(size)
+}
+
+// SetMaxJobAttempts sets how many times a failing processing job is tried before it is
+// dead-lettered. It must be called before jobs are submitted.
+func (s *FileService) SetMaxJobAttempts(attempts int) {
+	s.maxAttempts = max(attempts, 1)
+}
+
+// SetDecompressionLimits bounds how far the archives users upload may expand. It must be called
+// before uploads are accepted.
+func (s *FileService) SetDecompressionLimits(limits storage.DecompressionLimits) {
+	s.decompress = limits
+}
+
+// DecompressionLimits returns how far the archives users upload may expand
+func (s *FileService) DecompressionLimits() storage.DecompressionLimits {
+	return s.decompress
 }
 
-// UploadFile handles the uploading of a file
-func (s *FileService) UploadFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, userID string) (*FileUploadInfo, error) {
+// SetEventPublisher publishes upload, processing and alert events to publisher; nil publishes none
+func (s *FileService) SetEventPublisher(publisher events.Publisher) {
+	s.events = publisher
+}
+
+// MaxUploadSize returns the largest accepted upload, in bytes
+func (s *FileService) MaxUploadSize() int64 {
+	return s.maxUpload.Load()
+}
+
+// UploadFile handles the uploading of a file, queuing its processing job at the given priority,
+// or the user's org's priority when it is empty. When an idempotency key is given and the user
+// has already uploaded with it, the earlier upload is returned with Replayed set instead.
+func (s *FileService) UploadFile(ctx context.Context, file io.Reader, fileName, contentType, userID, idempotencyKey, priority string) (*FileUploadInfo, error) {
+	// Return the earlier upload for a repeated request
+	if idempotencyKey != "" {
+		if info, err := s.findIdempotentUpload(ctx, userID, idempotencyKey); err == nil {
+			return info, nil
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
 	// Validate file type
-	if err := s.validateFileType(header); err != nil {
+	if err := s.validateFileType(contentType); err != nil {
 		return nil, err
 	}
 
-	// Validate file size
-	if err := s.validateFileSize(header); err != nil {
+	priority, err := s.resolvePriority(ctx, userID, priority)
+	if err != nil {
 		return nil, err
 	}
 
-	// Store the file
-	fileInfo, err := s.fileStorage.StoreFile(file, header.Filename, header.Header.Get("Content-Type"), userID, header.Size)
+	// Stream the file to storage, enforcing the size limit as it is written
+	fileInfo, err := s.fileStorage.StoreFile(file, fileName, contentType, userID, s.maxUpload.Load())
 	if err != nil {
 		return nil, fmt.Errorf("failed to store file: %w", err)
 	}
 
+	// Record the file and queue its processing job atomically
+	now := time.Now()
+	job := &models.ProcessingJob{
+		ID:        uuid.New().String(),
+		FileID:    fileInfo.ID,
+		UserID:    userID,
+		Status:    models.JobStatusQueued,
+		Priority:  priority,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Files.Create(ctx, &models.File{
+			ID:         fileInfo.ID,
+			UserID:     userID,
+			FileName:   fileInfo.FileName,
+			FileSize:   fileInfo.FileSize,
+			FileType:   fileInfo.FileType,
+			FilePath:   fileInfo.FilePath,
+			Status:     models.FileStatusUploaded,
+			UploadedAt: fileInfo.UploadedAt,
+			UpdatedAt:  now,
+		}); err != nil {
+			return fmt.Errorf("failed to save file metadata: %w", err)
+		}
+
+		if err := repos.Jobs.Enqueue(ctx, job); err != nil {
+			return fmt.Errorf("failed to enqueue processing job: %w", err)
+		}
+
+		if idempotencyKey != "" {
+			if err := repos.Idempotency.Create(ctx, &models.IdempotencyKey{
+				UserID:    userID,
+				Key:       idempotencyKey,
+				FileID:    fileInfo.ID,
+				JobID:     job.ID,
+				CreatedAt: now,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		// Don't leave an untracked file behind
+		_ = s.fileStorage.DeleteFile(fileInfo.ID, userID)
+
+		// A concurrent request with the same key won the race; return its upload
+		if errors.Is(err, repository.ErrDuplicate) {
+			return s.findIdempotentUpload(ctx, userID, idempotencyKey)
+		}
+		return nil, err
+	}
+
+	s.publish(ctx, events.TypeFileUploaded, userID, events.FileUploaded{
+		FileID:   fileInfo.ID,
+		FileName: fileInfo.FileName,
+		FileSize: fileInfo.FileSize,
+		JobID:    job.ID,
+		Priority: job.Priority,
+	})
+
 	// Return file upload info
 	return &FileUploadInfo{
 		ID:         fileInfo.ID,
@@ -60,7 +242,59 @@ func (s *FileService) UploadFile(ctx context.Context, file multipart.File, heade
 		FileSize:   fileInfo.FileSize,
 		FileType:   fileInfo.FileType,
 		UploadedAt: fileInfo.UploadedAt,
-		Status:     "uploaded", // Initial status
+		Status:     models.FileStatusUploaded,
+		JobID:      job.ID,
+		Priority:   job.Priority,
+	}, nil
+}
+
+// resolvePriority validates a requested job priority, defaulting to the user's org's priority
+func (s *FileService) resolvePriority(ctx context.Context, userID, priority string) (string, error) {
+	if priority != "" {
+		if !models.ValidJobPriority(priority) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPriority, priority)
+		}
+		return priority, nil
+	}
+
+	priority, err := s.orgs.JobPriorityForUser(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.JobPriorityNormal, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get org priority: %w", err)
+	}
+
+	return priority, nil
+}
+
+// findIdempotentUpload returns the upload created by an earlier request with the same key
+func (s *FileService) findIdempotentUpload(ctx context.Context, userID, idempotencyKey string) (*FileUploadInfo, error) {
+	key, err := s.idempotency.Find(ctx, userID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.files.FindByID(ctx, key.FileID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find file for idempotency key: %w", err)
+	}
+
+	job, err := s.jobs.FindByID(ctx, key.JobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job for idempotency key: %w", err)
+	}
+
+	return &FileUploadInfo{
+		ID:         file.ID,
+		FileName:   file.FileName,
+		FileSize:   file.FileSize,
+		FileType:   file.FileType,
+		UploadedAt: file.UploadedAt,
+		Status:     file.Status,
+		JobID:      key.JobID,
+		Priority:   job.Priority,
+		Replayed:   true,
 	}, nil
 }
 
@@ -85,61 +319,143 @@ func (s *FileService) GetFile(ctx context.Context, fileID, userID string) (*os.F
 
 // DeleteFile removes a file
 func (s *FileService) DeleteFile(ctx context.Context, fileID, userID string) error {
-	return s.fileStorage.DeleteFile(fileID, userID)
+	if err := s.fileStorage.DeleteFile(fileID, userID); err != nil {
+		if errors.Is(err, storage.ErrFileNotFound) {
+			return ErrFileNotFound
+		}
+		return err
+	}
+
+	// Files uploaded before metadata was tracked have no record to delete
+	if err := s.files.Delete(ctx, fileID, userID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+
+	// Drop cached results that included the file
+	s.resultCache.InvalidateFile(ctx, userID, fileID)
+
+	return nil
 }
 
-// ListUserFiles lists all files for a user
-// In a real implementation, this would query a database
+// ListUserFiles lists all files for a user.
+// The listing is read from a replica, so a file uploaded moments ago may not appear yet.
 func (s *FileService) ListUserFiles(ctx context.Context, userID string) ([]*FileUploadInfo, error) {
-	// This is a placeholder implementation
-	// In a real application, we would query a database for the user's files
-	return []*FileUploadInfo{}, nil
+	files, err := s.fileReader.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	infos := make([]*FileUploadInfo, len(files))
+	for i, file := range files {
+		infos[i] = &FileUploadInfo{
+			ID:         file.ID,
+			FileName:   file.FileName,
+			FileSize:   file.FileSize,
+			FileType:   file.FileType,
+			UploadedAt: file.UploadedAt,
+			Status:     file.Status,
+			Tags:       file.Tags,
+			Folder:     file.Folder,
+		}
+	}
+
+	return infos, nil
 }
 
 // validateFileType checks if the file's content type is allowed
-func (s *FileService) validateFileType(header *multipart.FileHeader) error {
-	contentType := header.Header.Get("Content-Type")
-
+func (s *FileService) validateFileType(contentType string) error {
 	allowedTypes := map[string]bool{
 		"text/csv":                 true,
 		"application/vnd.ms-excel": true,
 		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true,
-		"text/plain":       true,
-		"application/json": true,
+		"text/plain":           true,
+		"application/json":     true,
+		"application/x-ndjson": true,
 	}
 
 	if !allowedTypes[contentType] {
-		return fmt.Errorf("file type not allowed: %s", contentType)
+		return fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, contentType)
 	}
 
 	return nil
 }
 
-// validateFileSize checks if the file size is within limits
-func (s *FileService) validateFileSize(header *multipart.FileHeader) error {
-	// 50MB size limit
-	const maxSize = 50 * 1024 * 1024
+// ProcessLogFile handles the processing of an uploaded DSP log file
+// Concurrent requests for the same file share a single run, so a double-clicked process button
+// or a job racing a manual request doesn't parse the file twice.
+func (s *FileService) ProcessLogFile(ctx context.Context, fileID, userID string) (*ingestion.LogAnalysisResult, error) {
+	result, err, _ := s.processing.Do(userID+"/"+fileID, func() (interface{}, error) {
+		return s.processLogFile(ctx, fileID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*ingestion.LogAnalysisResult), nil
+}
 
-	if header.Size > maxSize {
-		return fmt.Errorf("file size exceeds the maximum allowed size of 50MB")
+// ReprocessLogFile parses a file again even when it already has an analysis, storing a new
+// version of it. The options override the parser and log format otherwise detected.
+func (s *FileService) ReprocessLogFile(ctx context.Context, fileID, userID string, opts ingestion.ParseOptions) (*ingestion.LogAnalysisResult, error) {
+	key := strings.Join([]string{"reprocess", userID, fileID, opts.Parser, opts.Format}, "/")
+	result, err, _ := s.processing.Do(key, func() (interface{}, error) {
+		return s.parseLogFile(ctx, fileID, userID, opts)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return result.(*ingestion.LogAnalysisResult), nil
 }
 
-// ProcessLogFile handles the processing of an uploaded DSP log file
-func (s *FileService) ProcessLogFile(ctx context.Context, fileID, userID string) (*ingestion.LogAnalysisResult, error) {
+// ValidateSample dry-runs parsing the first rows of a CSV log without storing it, so a mapping
+// problem shows up before a large upload rather than after it
+func (s *FileService) ValidateSample(ctx context.Context, file io.Reader, fileName, userID string, opts ingestion.ParseOptions, rows int) (*ingestion.SampleValidation, error) {
+	opts, err := s.withUploadDefaults(ctx, userID, fileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParseOptions, err)
+	}
+
+	validation, err := s.logProcessor.ValidateSample(ctx, file, fileName, userID, opts, rows)
+	if err != nil {
+		if errors.Is(err, ingestion.ErrSampleNotCSV) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParseOptions, err)
+		}
+		return nil, fmt.Errorf("failed to validate sample: %w", err)
+	}
+
+	return validation, nil
+}
+
+// processLogFile processes a file unless it already has a complete analysis
+func (s *FileService) processLogFile(ctx context.Context, fileID, userID string) (*ingestion.LogAnalysisResult, error) {
 	// Check if the file has already been processed
-	processed, err := s.logProcessor.IsLogFileProcessed(ctx, fileID, userID)
+	result, err := s.GetLogAnalysisResult(ctx, fileID, userID)
+	if errors.Is(err, ingestion.ErrAnalysisNotFound) {
+		return s.parseLogFile(ctx, fileID, userID, ingestion.ParseOptions{})
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if file is processed: %w", err)
 	}
 
-	// If already processed, return the existing results
-	if processed {
-		return s.GetLogAnalysisResult(ctx, fileID, userID)
+	// Results left incomplete by a failure resume with the options the failed run used
+	if result.Incomplete {
+		var opts ingestion.ParseOptions
+		if result.Options != nil {
+			opts = *result.Options
+		}
+		return s.parseLogFile(ctx, fileID, userID, opts)
 	}
 
+	// If already processed, return the existing results
+	return result, nil
+}
+
+// parseLogFile parses a stored file and saves its analysis
+func (s *FileService) parseLogFile(ctx context.Context, fileID, userID string, opts ingestion.ParseOptions) (*ingestion.LogAnalysisResult, error) {
 	// Get the file
 	file, fileInfo, err := s.fileStorage.GetFile(fileID, userID)
 	if err != nil {
@@ -147,15 +463,438 @@ func (s *FileService) ProcessLogFile(ctx context.Context, fileID, userID string)
 	}
 	defer file.Close()
 
+	opts, err = s.withUploadDefaults(ctx, userID, fileInfo.FileName, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process the file
-	result, err := s.logProcessor.ProcessLogFile(ctx, fileInfo.FilePath, fileID, fileInfo.FileName, userID)
+	result, err := s.logProcessor.ProcessLogFile(ctx, fileInfo.FilePath, fileID, fileInfo.FileName, userID, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process log file: %w", err)
 	}
 
+	// Cached cross-file results are stale once a new analysis exists
+	s.resultCache.InvalidateFile(ctx, userID, fileID)
+
 	return result, nil
 }
 
+// withUploadDefaults fills in what parse options leave unset from the upload defaults of the
+// user's org: the parser and log format for the file's kind, when neither is set, the time zone
+// and the breakdown limit
+func (s *FileService) withUploadDefaults(ctx context.Context, userID, fileName string, opts ingestion.ParseOptions) (ingestion.ParseOptions, error) {
+	defaults, err := s.orgs.UploadDefaultsForUser(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return opts, nil
+	}
+	if err != nil {
+		return opts, fmt.Errorf("failed to get org upload defaults: %w", err)
+	}
+
+	if opts.Parser == "" && opts.Format == "" {
+		parser := defaults.Parsers[strings.ToLower(filepath.Ext(fileName))]
+		opts.Parser, opts.Format = parser.Parser, parser.Format
+	}
+	if opts.Timezone == "" {
+		opts.Timezone = defaults.Timezone
+	}
+	if opts.BreakdownLimit == 0 {
+		opts.BreakdownLimit = defaults.BreakdownLimit
+	}
+	return opts, nil
+}
+
+// SubmitProcessingJob runs a queued job in the background, ahead of waiting jobs of lower priority.
+// If shutdown interrupts the job, it is put back in the queue to be resumed on the next start.
+func (s *FileService) SubmitProcessingJob(jobID, fileID, userID, priority string) error {
+	return s.workers.Submit(worker.Task{
+		ID:       jobID,
+		Priority: models.JobPriorityRank(priority),
+		Run: func(ctx context.Context) error {
+			return s.RunProcessingJob(ctx, jobID, fileID, userID)
+		},
+		Interrupted: func(ctx context.Context) error {
+			return s.setProcessingStatus(ctx, jobID, fileID, userID, models.JobStatusQueued, models.FileStatusUploaded, "interrupted by shutdown")
+		},
+	})
+}
+
+// ResumeQueuedJobs submits every queued job, such as jobs interrupted by the last shutdown
+func (s *FileService) ResumeQueuedJobs(ctx context.Context) (int, error) {
+	jobs, err := s.jobs.ListByStatus(ctx, models.JobStatusQueued)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queued jobs: %w", err)
+	}
+
+	for i, job := range jobs {
+		if err := s.SubmitProcessingJob(job.ID, job.FileID, job.UserID, job.Priority); err != nil {
+			return i, err
+		}
+	}
+
+	return len(jobs), nil
+}
+
+// ReconcileJobs requeues jobs left running by a crashed process and removes expired idempotency keys.
+// It must run at startup before queued jobs are resumed and assumes a single processing instance.
+func (s *FileService) ReconcileJobs(ctx context.Context) (int, error) {
+	jobs, err := s.jobs.ListByStatus(ctx, models.JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list running jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		if err := s.setProcessingStatus(ctx, job.ID, job.FileID, job.UserID, models.JobStatusQueued, models.FileStatusUploaded, "requeued after restart"); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := s.idempotency.DeleteBefore(ctx, time.Now().Add(-idempotencyKeyTTL)); err != nil {
+		return 0, fmt.Errorf("failed to remove expired idempotency keys: %w", err)
+	}
+
+	return len(jobs), nil
+}
+
+// QueueReprocess queues a job that parses a file again into a new analysis version, optionally
+// overriding the parser and log format detected the first time, so a mis-detected file doesn't
+// have to be uploaded again. The job runs at the given priority, or the org's when it is empty.
+func (s *FileService) QueueReprocess(ctx context.Context, fileID, userID, priority string, opts ingestion.ParseOptions) (*models.ProcessingJob, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParseOptions, err)
+	}
+
+	priority, err := s.resolvePriority(ctx, userID, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &models.ProcessingJob{
+		ID:        uuid.New().String(),
+		FileID:    fileID,
+		UserID:    userID,
+		Status:    models.JobStatusQueued,
+		Priority:  priority,
+		Reprocess: true,
+		Parser:    opts.Parser,
+		LogFormat: opts.Format,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if _, err := repos.Files.FindByID(ctx, fileID, userID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrFileNotFound
+			}
+			return fmt.Errorf("failed to get file: %w", err)
+		}
+
+		active, err := repos.Jobs.HasActive(ctx, fileID)
+		if err != nil {
+			return fmt.Errorf("failed to check for active jobs: %w", err)
+		}
+		if active {
+			return ErrJobActive
+		}
+
+		if err := repos.Jobs.Enqueue(ctx, job); err != nil {
+			return fmt.Errorf("failed to enqueue processing job: %w", err)
+		}
+		if err := repos.Files.UpdateStatus(ctx, fileID, userID, models.FileStatusUploaded); err != nil {
+			return fmt.Errorf("failed to update file status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ListAnalysisVersions lists the versions of a file's analysis, newest first
+func (s *FileService) ListAnalysisVersions(ctx context.Context, fileID, userID string) ([]ingestion.AnalysisVersion, error) {
+	return s.logProcessor.ListAnalysisVersions(ctx, fileID, userID)
+}
+
+// GetAnalysisVersion retrieves one version of a file's analysis
+func (s *FileService) GetAnalysisVersion(ctx context.Context, fileID, userID string, version int) (*ingestion.LogAnalysisResult, error) {
+	return s.logProcessor.GetAnalysisVersion(ctx, fileID, userID, version)
+}
+
+// CancelProcessing cancels a file's queued or running processing jobs. Jobs waiting for a worker
+// never start; a running job stops at the next record it parses and its results are discarded.
+func (s *FileService) CancelProcessing(ctx context.Context, fileID, userID string) error {
+	if _, err := s.files.FindByID(ctx, fileID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	// Record the cancellation first, so a job finishing in the meantime isn't mistaken for a canceled one
+	var jobIDs []string
+	err := s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		jobIDs, err = repos.Jobs.CancelActive(ctx, fileID)
+		if err != nil {
+			return fmt.Errorf("failed to cancel jobs: %w", err)
+		}
+		if len(jobIDs) == 0 {
+			return ErrNoActiveJob
+		}
+		if err := repos.Files.UpdateStatus(ctx, fileID, userID, models.FileStatusCanceled); err != nil {
+			return fmt.Errorf("failed to update file status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, jobID := range jobIDs {
+		s.workers.Cancel(jobID)
+	}
+
+	return nil
+}
+
+// GetJob returns one of the user's processing jobs
+func (s *FileService) GetJob(ctx context.Context, jobID, userID string) (*models.ProcessingJob, error) {
+	job, err := s.jobs.FindByID(ctx, jobID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && job.UserID != userID) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job.Status == models.JobStatusRunning {
+		job.Progress = s.progress.Get(jobID)
+	}
+
+	return job, nil
+}
+
+// jobWatchMinInterval bounds how often a watched job is sent, and jobWatchPollInterval how long a
+// watcher waits before checking a job that isn't running on this server again
+const (
+	jobWatchMinInterval  = 500 * time.Millisecond
+	jobWatchPollInterval = 5 * time.Second
+)
+
+// WatchJob calls send with one of the user's processing jobs, then again whenever its status or
+// progress changes, until the job finishes, send fails or ctx is done
+func (s *FileService) WatchJob(ctx context.Context, jobID, userID string, send func(*models.ProcessingJob) error) error {
+	changed, stop := s.progress.Watch(jobID)
+	defer stop()
+
+	poll := time.NewTicker(jobWatchPollInterval)
+	defer poll.Stop()
+
+	for {
+		job, err := s.GetJob(ctx, jobID, userID)
+		if err != nil {
+			return err
+		}
+		if err := send(job); err != nil {
+			return err
+		}
+		if job.Finished() {
+			return nil
+		}
+
+		// Rate-limit updates; progress changes every thousand rows
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jobWatchMinInterval):
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-poll.C:
+		}
+	}
+}
+
+// RunProcessingJob processes the file of a queued job, tracking the job and file status as it runs
+func (s *FileService) RunProcessingJob(ctx context.Context, jobID, fileID, userID string) error {
+	ctx = errreport.WithTags(ctx, "jobID", jobID, "fileID", fileID, "userID", userID)
+
+	job, err := s.jobs.FindByID(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	// A job canceled while it waited for a worker doesn't start
+	if job.Status == models.JobStatusCanceled {
+		return nil
+	}
+
+	if err := s.setProcessingStatus(ctx, jobID, fileID, userID, models.JobStatusRunning, models.FileStatusProcessing, ""); err != nil {
+		return err
+	}
+
+	// Track progress until the final status is recorded, so watchers never see the job end early
+	s.progress.Start(jobID)
+	defer s.progress.Finish(jobID)
+	ctx = ingestion.WithProgress(ctx, func(progress ingestion.Progress) {
+		s.progress.Update(jobID, progress)
+	})
+
+	result, stack, err := s.processJob(ctx, job)
+	if err != nil {
+		// A job canceled by its user ends canceled, which the canceled context can't record itself
+		if errors.Is(context.Cause(ctx), worker.ErrCanceled) {
+			return s.setProcessingStatus(context.WithoutCancel(ctx), jobID, fileID, userID, models.JobStatusCanceled, models.FileStatusCanceled, "canceled by user")
+		}
+		// A job canceled by shutdown was interrupted rather than failed; its status is reset when it is requeued
+		if ctx.Err() != nil {
+			return err
+		}
+		// The job was fetched before this attempt was counted
+		job.Attempts++
+		if statusErr := s.recordJobFailure(ctx, job, err, stack); statusErr != nil {
+			errreport.Report(ctx, "Failed to record job failure", statusErr)
+		}
+		return err
+	}
+
+	if err := s.setProcessingStatus(ctx, jobID, fileID, userID, models.JobStatusCompleted, models.FileStatusProcessed, ""); err != nil {
+		return err
+	}
+	s.publishProcessed(ctx, job, result)
+	return nil
+}
+
+// processJob parses a job's file, turning a panic into an error and returning its stack
+func (s *FileService) processJob(ctx context.Context, job *models.ProcessingJob) (result *ingestion.LogAnalysisResult, stack string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result, stack = nil, string(debug.Stack())
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	if job.Reprocess {
+		result, err = s.ReprocessLogFile(ctx, job.FileID, job.UserID, ingestion.ParseOptions{Parser: job.Parser, Format: job.LogFormat})
+	} else {
+		result, err = s.ProcessLogFile(ctx, job.FileID, job.UserID)
+	}
+	return result, "", err
+}
+
+// recordJobFailure queues a failed job to be retried after a backoff or, once it has run out of
+// attempts, fails it and moves it to the dead letter table
+func (s *FileService) recordJobFailure(ctx context.Context, job *models.ProcessingJob, jobErr error, stack string) error {
+	if job.Attempts < s.maxAttempts {
+		message := fmt.Sprintf("attempt %d of %d failed, retrying: %v", job.Attempts, s.maxAttempts, jobErr)
+		if err := s.setProcessingStatus(ctx, job.ID, job.FileID, job.UserID, models.JobStatusQueued, models.FileStatusUploaded, message); err != nil {
+			return err
+		}
+
+		// A retry still waiting at shutdown is resumed with the other queued jobs on the next start
+		delay := jobRetryDelay << (job.Attempts - 1)
+		time.AfterFunc(delay, func() {
+			if err := s.SubmitProcessingJob(job.ID, job.FileID, job.UserID, job.Priority); err != nil && !errors.Is(err, worker.ErrShuttingDown) {
+				errreport.Report(context.WithoutCancel(ctx), "Failed to submit job retry", err)
+			}
+		})
+		return nil
+	}
+
+	err := s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Jobs.UpdateStatus(ctx, job.ID, models.JobStatusFailed, jobErr.Error()); err != nil {
+			return fmt.Errorf("failed to update job status: %w", err)
+		}
+		if err := repos.Files.UpdateStatus(ctx, job.FileID, job.UserID, models.FileStatusFailed); err != nil {
+			return fmt.Errorf("failed to update file status: %w", err)
+		}
+		err := repos.DeadLetters.Create(ctx, &models.DeadLetterJob{
+			JobID:     job.ID,
+			FileID:    job.FileID,
+			UserID:    job.UserID,
+			Priority:  job.Priority,
+			Reprocess: job.Reprocess,
+			Parser:    job.Parser,
+			LogFormat: job.LogFormat,
+			Attempts:  job.Attempts,
+			Error:     jobErr.Error(),
+			Stack:     stack,
+			FailedAt:  time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to dead-letter job: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(ctx, events.TypeFileFailed, job.UserID, events.FileFailed{
+		FileID:   job.FileID,
+		JobID:    job.ID,
+		Attempts: job.Attempts,
+		Error:    jobErr.Error(),
+	})
+	s.publish(ctx, events.TypeAlert, job.UserID, events.Alert{
+		Kind:    events.AlertProcessingFailed,
+		FileID:  job.FileID,
+		Title:   fmt.Sprintf("Processing failed after %d attempts", job.Attempts),
+		Details: jobErr.Error(),
+	})
+	return nil
+}
+
+// publishProcessed publishes a completed job's file.processed event, and an alert when the file's
+// columns drifted from the previous upload from its source
+func (s *FileService) publishProcessed(ctx context.Context, job *models.ProcessingJob, result *ingestion.LogAnalysisResult) {
+	if s.events == nil || result == nil {
+		return
+	}
+
+	s.publish(ctx, events.TypeFileProcessed, job.UserID, events.FileProcessed{
+		FileID:    job.FileID,
+		FileName:  result.FileName,
+		JobID:     job.ID,
+		Version:   result.Version,
+		Reprocess: job.Reprocess,
+		Warnings:  result.Warnings,
+	})
+	if result.SchemaDrift != nil {
+		s.publish(ctx, events.TypeAlert, job.UserID, events.Alert{
+			Kind:    events.AlertSchemaDrift,
+			FileID:  job.FileID,
+			Title:   fmt.Sprintf("Columns changed in %s", result.FileName),
+			Details: result.SchemaDrift,
+		})
+	}
+}
+
+// publish publishes an event when a publisher is set
+func (s *FileService) publish(ctx context.Context, eventType, userID string, data interface{}) {
+	if s.events != nil {
+		s.events.Publish(ctx, eventType, userID, data)
+	}
+}
+
+// setProcessingStatus updates a job and its file together so they never disagree
+func (s *FileService) setProcessingStatus(ctx context.Context, jobID, fileID, userID, jobStatus, fileStatus, errorMessage string) error {
+	return s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Jobs.UpdateStatus(ctx, jobID, jobStatus, errorMessage); err != nil {
+			return fmt.Errorf("failed to update job status: %w", err)
+		}
+		if err := repos.Files.UpdateStatus(ctx, fileID, userID, fileStatus); err != nil {
+			return fmt.Errorf("failed to update file status: %w", err)
+		}
+		return nil
+	})
+}
+
 // GetLogAnalysisResult retrieves the analysis result for a log file
 func (s *FileService) GetLogAnalysisResult(ctx context.Context, fileID, userID string) (*ingestion.LogAnalysisResult, error) {
 	return s.logProcessor.GetAnalysisResult(ctx, fileID, userID)
@@ -166,7 +905,7 @@ func (s *FileService) AnalyzeLogFile(ctx context.Context, fileID, userID string)
 	// In a real implementation, this would run analytics on the processed data
 
 	// For now, just log that we're analyzing the file
-	fmt.Printf("Analyzing log file with ID: %s for user %s\n", fileID, userID)
+	slog.Info("Analyzing log file", "fileID", fileID, "userID", userID)
 
 	// Simulate analysis time
 	time.Sleep(2 * time.Second)
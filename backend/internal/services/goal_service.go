@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/fxrates"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Campaign goal errors
+var (
+	ErrInvalidCampaignGoal  = errors.New("invalid campaign goal")
+	ErrCampaignGoalNotFound = errors.New("campaign goal not found")
+)
+
+// GoalService manages the goals users set for their campaigns, which campaign rollups and the
+// weekly digest compare delivery against
+type GoalService struct {
+	goals      repository.CampaignGoalRepository
+	currencies *CurrencyService
+}
+
+// NewGoalService creates a new goal service, converting spend into campaigns' currencies with
+// the currency service
+func NewGoalService(repos repository.Repositories, currencies *CurrencyService) *GoalService {
+	return &GoalService{
+		goals:      repos.Goals,
+		currencies: currencies,
+	}
+}
+
+// ListGoals lists a user's campaign goals, ordered by campaign ID
+func (s *GoalService) ListGoals(ctx context.Context, userID string) ([]*ingestion.CampaignGoal, error) {
+	goals, err := s.goals.ListGoals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign goals: %w", err)
+	}
+
+	list := make([]*ingestion.CampaignGoal, 0, len(goals))
+	for _, goal := range goals {
+		list = append(list, goal)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CampaignID < list[j].CampaignID
+	})
+
+	return list, nil
+}
+
+// GoalsByCampaign returns a user's campaign goals by campaign ID
+func (s *GoalService) GoalsByCampaign(ctx context.Context, userID string) (map[string]*ingestion.CampaignGoal, error) {
+	goals, err := s.goals.ListGoals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign goals: %w", err)
+	}
+	return goals, nil
+}
+
+// GetGoal returns a user's goal for a campaign
+func (s *GoalService) GetGoal(ctx context.Context, userID, campaignID string) (*ingestion.CampaignGoal, error) {
+	goal, err := s.goals.GetGoal(ctx, userID, campaignID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCampaignGoalNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign goal: %w", err)
+	}
+
+	return goal, nil
+}
+
+// SetGoal validates and saves a user's goal for a campaign, replacing the earlier one
+func (s *GoalService) SetGoal(ctx context.Context, userID string, goal *ingestion.CampaignGoal) error {
+	goal.CampaignID = strings.TrimSpace(goal.CampaignID)
+	if goal.CampaignID == "" {
+		return fmt.Errorf("%w: campaign ID is required", ErrInvalidCampaignGoal)
+	}
+	if goal.Currency == "" {
+		goal.Currency = fxrates.BaseCurrency
+	}
+	if !currencyPattern.MatchString(goal.Currency) {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code such as USD", ErrInvalidCampaignGoal)
+	}
+
+	targets := []struct {
+		metric string
+		value  *float64
+	}{
+		{ingestion.GoalCPA, goal.TargetCPA},
+		{ingestion.GoalCTR, goal.TargetCTR},
+		{ingestion.GoalROAS, goal.TargetROAS},
+		{ingestion.GoalDailySpend, goal.DailySpend},
+		{ingestion.GoalBudget, goal.Budget},
+	}
+	set := 0
+	for _, target := range targets {
+		if target.value == nil {
+			continue
+		}
+		if *target.value <= 0 || math.IsInf(*target.value, 0) || math.IsNaN(*target.value) {
+			return fmt.Errorf("%w: %s target must be a positive number", ErrInvalidCampaignGoal, target.metric)
+		}
+		set++
+	}
+	if set == 0 {
+		return fmt.Errorf("%w: set at least one of targetCpa, targetCtr, targetRoas, dailySpend or budget", ErrInvalidCampaignGoal)
+	}
+	if goal.TargetCTR != nil && *goal.TargetCTR > 100 {
+		return fmt.Errorf("%w: CTR target is a percentage and can't exceed 100", ErrInvalidCampaignGoal)
+	}
+
+	goal.UpdatedAt = time.Now()
+	if err := s.goals.SetGoal(ctx, userID, goal); err != nil {
+		return fmt.Errorf("failed to set campaign goal: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteGoal removes a user's goal for a campaign
+func (s *GoalService) DeleteGoal(ctx context.Context, userID, campaignID string) error {
+	err := s.goals.DeleteGoal(ctx, userID, campaignID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCampaignGoalNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete campaign goal: %w", err)
+	}
+
+	return nil
+}
+
+// ApplyGoal annotates a campaign rollup in US dollars with its attainment of the campaign's
+// goal, leaving it as is when the campaign has none. Spend is converted into the goal's
+// currency day by day first, so pacing reflects the rate on each day spent.
+func (s *GoalService) ApplyGoal(ctx context.Context, userID string, rollup *ingestion.CampaignRollup) error {
+	goal, err := s.GetGoal(ctx, userID, rollup.CampaignID)
+	if errors.Is(err, ErrCampaignGoalNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.EvaluateGoal(ctx, goal, rollup)
+}
+
+// EvaluateGoal annotates a campaign rollup in US dollars with its attainment of a goal
+func (s *GoalService) EvaluateGoal(ctx context.Context, goal *ingestion.CampaignGoal, rollup *ingestion.CampaignRollup) error {
+	converted, err := s.currencies.ConvertRollup(ctx, rollup, goal.Currency)
+	if err != nil {
+		return err
+	}
+	converted.ApplyGoal(goal)
+
+	// Attainment is a percentage, so it carries over to the rollup in any currency
+	rollup.Goals = converted.Goals
+	for i := range rollup.Daily {
+		rollup.Daily[i].Goals = converted.Daily[i].Goals
+	}
+	return nil
+}
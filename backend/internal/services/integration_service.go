@@ -0,0 +1,623 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/integrations"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+	"github.com/google/uuid"
+)
+
+// integrationCheckInterval is how often the scheduler looks for integrations due a sync
+const integrationCheckInterval = 5 * time.Minute
+
+// defaultSpendDays is the range of the channel spend report when no dates are given
+const defaultSpendDays = 30
+
+// Google Sheets report limits and defaults
+const (
+	defaultSheetTitle = "AdVantage"
+	defaultSheetDays  = 30
+	maxSheetDays      = 366
+	// maxSheetRows keeps a report well inside a spreadsheet's cell limit
+	maxSheetRows = 50000
+)
+
+// sheetHeader is the header row of a Google Sheets report
+var sheetHeader = []interface{}{"bucket", "value", "bids", "impressions", "clicks", "conversions", "spend", "revenue", "ctr", "roas", "cpa", "measurable_impressions", "viewable_impressions"}
+
+// Integration errors
+var (
+	ErrIntegrationUnavailable = errors.New("integration is not configured on this server")
+	ErrIntegrationNotFound    = errors.New("integration not found")
+	ErrSheetReportTooLarge    = fmt.Errorf("report has more than %d rows; narrow it to a value, fewer days or a daily grain", maxSheetRows)
+)
+
+// SheetReport is the rollup query a Google Sheets integration writes to a spreadsheet's tab on
+// every sync, covering the trailing Days up to today
+type SheetReport struct {
+	Spreadsheet string `json:"spreadsheet" binding:"required"` // ID or URL
+	Sheet       string `json:"sheet"`
+	Dimension   string `json:"dimension"`
+	Grain       string `json:"grain"`
+	Value       string `json:"value"`
+	Days        int    `json:"days"`
+}
+
+// ChannelSpend is a day's delivery and spend from one source, a DSP log format or an ad platform
+type ChannelSpend struct {
+	Date        string  `json:"date,omitempty"`
+	Source      string  `json:"source"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	Conversions float64 `json:"conversions"`
+	Spend       float64 `json:"spend"`
+}
+
+// ChannelSpendReport puts DSP log spend and spend pulled from ad platforms side by side
+type ChannelSpendReport struct {
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+	Daily  []ChannelSpend `json:"daily"`
+	Totals []ChannelSpend `json:"totals"`
+}
+
+// IntegrationClients are the clients integrations connect and pull with. The cipher is nil when
+// integrations aren't configured and a provider's client is nil when that provider isn't, in
+// which case connecting returns ErrIntegrationUnavailable.
+type IntegrationClients struct {
+	Cipher      *integrations.TokenCipher
+	GoogleOAuth *integrations.GoogleOAuth
+	GoogleAds   *integrations.GoogleAds
+	GA4         *integrations.GA4
+	Sheets      *integrations.GoogleSheets
+}
+
+// IntegrationService connects users' ad platform and analytics accounts and pulls their reports
+// on a schedule, and pushes reports to the spreadsheets they connect
+type IntegrationService struct {
+	integrations repository.IntegrationRepository
+	logProcessor *ingestion.LogProcessorService
+	rollups      *RollupService
+	workers      *worker.Manager
+	entitlements *EntitlementService
+	clients      IntegrationClients
+	lookbackDays int
+	syncing      sync.Map // integration ID → struct{}, for syncs queued or running
+}
+
+// NewIntegrationService creates a new integration service
+func NewIntegrationService(repos repository.Repositories, logProcessor *ingestion.LogProcessorService, rollups *RollupService, workers *worker.Manager, entitlements *EntitlementService, clients IntegrationClients, lookbackDays int) *IntegrationService {
+	return &IntegrationService{
+		integrations: repos.Integrations,
+		logProcessor: logProcessor,
+		rollups:      rollups,
+		workers:      workers,
+		entitlements: entitlements,
+		clients:      clients,
+		lookbackDays: max(lookbackDays, 1),
+	}
+}
+
+// GoogleAdsAuthURL starts connecting a Google Ads account, returning the consent screen URL
+func (s *IntegrationService) GoogleAdsAuthURL(userID, customerID string) (string, error) {
+	if s.clients.Cipher == nil || s.clients.GoogleAds == nil {
+		return "", ErrIntegrationUnavailable
+	}
+
+	accountID, err := integrations.NormalizeCustomerID(customerID)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := s.sealState(userID, models.IntegrationProviderGoogleAds, accountID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return s.clients.GoogleAds.AuthURL(state), nil
+}
+
+// GA4AuthURL starts connecting a Google Analytics 4 property, returning the consent screen URL
+func (s *IntegrationService) GA4AuthURL(userID, propertyID string) (string, error) {
+	if s.clients.Cipher == nil || s.clients.GA4 == nil {
+		return "", ErrIntegrationUnavailable
+	}
+
+	accountID, err := integrations.NormalizePropertyID(propertyID)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := s.sealState(userID, models.IntegrationProviderGA4, accountID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return s.clients.GA4.AuthURL(state), nil
+}
+
+// GoogleSheetsAuthURL starts connecting a spreadsheet tab a report is written to, returning the
+// consent screen URL. Connecting the same tab again replaces its report.
+func (s *IntegrationService) GoogleSheetsAuthURL(userID string, report SheetReport) (string, error) {
+	if s.clients.Cipher == nil || s.clients.Sheets == nil {
+		return "", ErrIntegrationUnavailable
+	}
+
+	spreadsheetID, err := integrations.NormalizeSpreadsheetID(report.Spreadsheet)
+	if err != nil {
+		return "", err
+	}
+	settings, err := sheetSettings(spreadsheetID, report)
+	if err != nil {
+		return "", err
+	}
+
+	// A spreadsheet ID never contains a slash, so the tab follows the first one
+	state, err := s.sealState(userID, models.IntegrationProviderGoogleSheets, spreadsheetID+"/"+settings["sheet"], settings)
+	if err != nil {
+		return "", err
+	}
+
+	return s.clients.Sheets.AuthURL(state), nil
+}
+
+// sheetSettings validates a Google Sheets report, filling in its defaults, and returns it as
+// integration settings
+func sheetSettings(spreadsheetID string, report SheetReport) (map[string]string, error) {
+	sheet := cmp.Or(report.Sheet, defaultSheetTitle)
+	if err := integrations.ValidateSheetTitle(sheet); err != nil {
+		return nil, err
+	}
+
+	dimension := cmp.Or(report.Dimension, ingestion.RollupByCampaign)
+	grain := cmp.Or(report.Grain, ingestion.RollupDaily)
+	if !ingestion.IsRollupDimension(dimension) || !ingestion.IsRollupGrain(grain) {
+		return nil, ErrInvalidRollupQuery
+	}
+
+	days := cmp.Or(report.Days, defaultSheetDays)
+	if days < 1 || days > maxSheetDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", integrations.ErrInvalidSpreadsheet, maxSheetDays)
+	}
+
+	return map[string]string{
+		"spreadsheetId": spreadsheetID,
+		"sheet":         sheet,
+		"dimension":     dimension,
+		"grain":         grain,
+		"value":         report.Value,
+		"days":          strconv.Itoa(days),
+	}, nil
+}
+
+// sealState seals the OAuth state identifying who is connecting which account
+func (s *IntegrationService) sealState(userID, provider, accountID string, settings map[string]string) (string, error) {
+	return s.clients.Cipher.SealState(integrations.State{
+		UserID:    userID,
+		Provider:  provider,
+		AccountID: accountID,
+		Settings:  settings,
+	})
+}
+
+// CompleteGoogleConnection finishes a Google OAuth flow from the callback's code and state,
+// storing the encrypted refresh token and starting the first sync
+func (s *IntegrationService) CompleteGoogleConnection(ctx context.Context, code, sealedState string) (*models.Integration, error) {
+	if s.clients.Cipher == nil || s.clients.GoogleOAuth == nil {
+		return nil, ErrIntegrationUnavailable
+	}
+
+	state, err := s.clients.Cipher.OpenState(sealedState)
+	if err != nil {
+		return nil, err
+	}
+	switch state.Provider {
+	case models.IntegrationProviderGoogleAds, models.IntegrationProviderGA4, models.IntegrationProviderGoogleSheets:
+	default:
+		return nil, integrations.ErrInvalidState
+	}
+
+	refreshToken, err := s.clients.GoogleOAuth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	return s.connect(ctx, state, refreshToken)
+}
+
+// connect stores a connection and queues its first sync
+func (s *IntegrationService) connect(ctx context.Context, state *integrations.State, refreshToken string) (*models.Integration, error) {
+	encrypted, err := s.clients.Cipher.Encrypt(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	settings := state.Settings
+	if settings == nil {
+		settings = map[string]string{}
+	}
+
+	now := time.Now()
+	integration := &models.Integration{
+		ID:           uuid.New().String(),
+		UserID:       state.UserID,
+		Provider:     state.Provider,
+		AccountID:    state.AccountID,
+		Settings:     settings,
+		RefreshToken: encrypted,
+		Status:       models.IntegrationStatusActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.integrations.Upsert(ctx, integration); err != nil {
+		return nil, fmt.Errorf("failed to save integration: %w", err)
+	}
+
+	if err := s.submitSync(integration); err != nil {
+		return nil, err
+	}
+
+	return integration, nil
+}
+
+// ListIntegrations lists a user's integrations
+func (s *IntegrationService) ListIntegrations(ctx context.Context, userID string) ([]*models.Integration, error) {
+	list, err := s.integrations.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integrations: %w", err)
+	}
+
+	return list, nil
+}
+
+// DeleteIntegration disconnects an integration and deletes the data pulled from it
+func (s *IntegrationService) DeleteIntegration(ctx context.Context, id, userID string) error {
+	if err := s.integrations.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrIntegrationNotFound
+		}
+		return fmt.Errorf("failed to delete integration: %w", err)
+	}
+
+	return nil
+}
+
+// SyncIntegration queues an immediate sync of a user's integration
+func (s *IntegrationService) SyncIntegration(ctx context.Context, id, userID string) error {
+	integration, err := s.integrations.FindByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrIntegrationNotFound
+		}
+		return fmt.Errorf("failed to find integration: %w", err)
+	}
+
+	return s.submitSync(integration)
+}
+
+// Run queues syncs of integrations not synced within interval until the context is canceled
+func (s *IntegrationService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(integrationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		due, err := s.integrations.ListDue(ctx, time.Now().Add(-interval))
+		if err != nil {
+			slog.Error("Failed to list integrations due a sync", "error", err)
+		}
+		for _, integration := range due {
+			if err := s.submitSync(integration); err != nil {
+				slog.Error("Failed to queue integration sync", "integrationID", integration.ID, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// submitSync queues a sync in the background unless one is already queued or running
+func (s *IntegrationService) submitSync(integration *models.Integration) error {
+	if _, busy := s.syncing.LoadOrStore(integration.ID, struct{}{}); busy {
+		return nil
+	}
+
+	err := s.workers.Submit(worker.Task{
+		ID: "integration-sync-" + integration.ID,
+		Run: func(ctx context.Context) error {
+			defer s.syncing.Delete(integration.ID)
+			return s.sync(ctx, integration)
+		},
+	})
+	if err != nil {
+		s.syncing.Delete(integration.ID)
+		return fmt.Errorf("failed to queue sync: %w", err)
+	}
+
+	return nil
+}
+
+// sync pulls the lookback window of an integration's reports, or pushes its report, and records
+// the outcome
+func (s *IntegrationService) sync(ctx context.Context, integration *models.Integration) error {
+	// Connectors stop syncing once a trial ends or the plan is downgraded
+	err := s.entitlements.RequireUserFeature(ctx, integration.UserID, models.FeatureConnectors)
+	if err == nil {
+		err = s.pull(ctx, integration)
+	}
+
+	status, lastError := models.IntegrationStatusActive, ""
+	if err != nil {
+		status, lastError = models.IntegrationStatusError, err.Error()
+	}
+	if updateErr := s.integrations.UpdateSyncStatus(ctx, integration.ID, status, lastError, time.Now()); updateErr != nil && !errors.Is(updateErr, repository.ErrNotFound) {
+		return fmt.Errorf("failed to update sync status: %w", updateErr)
+	}
+
+	return err
+}
+
+// pull fetches an integration's recent reports and stores them
+func (s *IntegrationService) pull(ctx context.Context, integration *models.Integration) error {
+	if s.clients.Cipher == nil {
+		return ErrIntegrationUnavailable
+	}
+
+	refreshToken, err := s.clients.Cipher.Decrypt(integration.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt token: %w", err)
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -s.lookbackDays)
+
+	switch integration.Provider {
+	case models.IntegrationProviderGoogleAds:
+		if s.clients.GoogleAds == nil {
+			return ErrIntegrationUnavailable
+		}
+		rows, err := s.clients.GoogleAds.FetchCampaignPerformance(ctx, refreshToken, integration.AccountID, from, to)
+		if err != nil {
+			return err
+		}
+
+		for i := range rows {
+			rows[i].IntegrationID = integration.ID
+			rows[i].UserID = integration.UserID
+		}
+		if err := s.integrations.UpsertPerformance(ctx, rows); err != nil {
+			return fmt.Errorf("failed to store campaign performance: %w", err)
+		}
+
+	case models.IntegrationProviderGA4:
+		if s.clients.GA4 == nil {
+			return ErrIntegrationUnavailable
+		}
+		rows, err := s.clients.GA4.FetchSiteOutcomes(ctx, refreshToken, integration.AccountID, from, to)
+		if err != nil {
+			return err
+		}
+
+		for i := range rows {
+			rows[i].IntegrationID = integration.ID
+			rows[i].UserID = integration.UserID
+		}
+		if err := s.integrations.UpsertSiteOutcomes(ctx, rows); err != nil {
+			return fmt.Errorf("failed to store site outcomes: %w", err)
+		}
+
+	case models.IntegrationProviderGoogleSheets:
+		return s.pushSheet(ctx, integration, refreshToken)
+
+	default:
+		return fmt.Errorf("unknown integration provider: %s", integration.Provider)
+	}
+
+	return nil
+}
+
+// pushSheet runs a Google Sheets integration's report over its trailing days and writes it to
+// the spreadsheet's tab, replacing the previous report
+func (s *IntegrationService) pushSheet(ctx context.Context, integration *models.Integration, refreshToken string) error {
+	if s.clients.Sheets == nil {
+		return ErrIntegrationUnavailable
+	}
+
+	settings := integration.Settings
+	days, err := strconv.Atoi(settings["days"])
+	if err != nil || days < 1 {
+		days = defaultSheetDays
+	}
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -(days - 1))
+
+	bucketLayout := "2006-01-02"
+	if settings["grain"] == ingestion.RollupHourly {
+		bucketLayout = "2006-01-02 15:04"
+	}
+
+	rows := [][]interface{}{sheetHeader}
+	cursor := ""
+	for {
+		cursor, err = s.rollups.StreamRollups(ctx, integration.UserID, settings["dimension"], settings["grain"], settings["value"], &from, &to, cursor, MaxPageSize,
+			func(*RollupSeries) error { return nil },
+			func(rollup ingestion.Rollup) error {
+				if len(rows) > maxSheetRows {
+					return ErrSheetReportTooLarge
+				}
+				rows = append(rows, []interface{}{
+					rollup.Bucket.UTC().Format(bucketLayout),
+					rollup.Value,
+					rollup.Bids,
+					rollup.Impressions,
+					rollup.Clicks,
+					rollup.Conversions,
+					rollup.Spend,
+					rollup.Revenue,
+					rollup.CTR,
+					rollup.ROAS,
+					rollup.CPA,
+					rollup.MeasurableImpressions,
+					rollup.ViewableImpressions,
+				})
+				return nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if cursor == "" {
+			break
+		}
+	}
+
+	return s.clients.Sheets.WriteSheet(ctx, refreshToken, settings["spreadsheetId"], settings["sheet"], rows)
+}
+
+// GetChannelSpend reports daily spend per source, combining the user's processed DSP logs with
+// performance pulled from connected ad platforms. Without dates it covers the last 30 days.
+func (s *IntegrationService) GetChannelSpend(ctx context.Context, userID string, from, to *time.Time) (*ChannelSpendReport, error) {
+	start, end := reportRange(from, to)
+	fromKey, toKey := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	type spendKey struct{ date, source string }
+	days := make(map[spendKey]*ChannelSpend)
+	add := func(date, source string, spend ChannelSpend) {
+		key := spendKey{date, source}
+		day, ok := days[key]
+		if !ok {
+			day = &ChannelSpend{Date: date, Source: source}
+			days[key] = day
+		}
+		day.Impressions += spend.Impressions
+		day.Clicks += spend.Clicks
+		day.Conversions += spend.Conversions
+		day.Spend += spend.Spend
+	}
+
+	// DSP spend from the daily campaign metrics of processed logs
+	err := eachDSPCampaignDay(ctx, s.logProcessor, userID, fromKey, toKey, func(source, _, date string, metrics ChannelSpend) {
+		add(date, source, metrics)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ad platform spend pulled by integrations
+	performance, err := s.integrations.ListPerformance(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign performance: %w", err)
+	}
+	for _, row := range performance {
+		add(row.Date.Format("2006-01-02"), row.Provider, ChannelSpend{
+			Impressions: row.Impressions,
+			Clicks:      row.Clicks,
+			Conversions: row.Conversions,
+			Spend:       float64(row.CostMicros) / 1000000,
+		})
+	}
+
+	report := &ChannelSpendReport{
+		From:   fromKey,
+		To:     toKey,
+		Daily:  make([]ChannelSpend, 0, len(days)),
+		Totals: []ChannelSpend{},
+	}
+	totals := make(map[string]*ChannelSpend)
+	for _, day := range days {
+		report.Daily = append(report.Daily, *day)
+
+		total, ok := totals[day.Source]
+		if !ok {
+			total = &ChannelSpend{Source: day.Source}
+			totals[day.Source] = total
+		}
+		total.Impressions += day.Impressions
+		total.Clicks += day.Clicks
+		total.Conversions += day.Conversions
+		total.Spend += day.Spend
+	}
+	for _, total := range totals {
+		report.Totals = append(report.Totals, *total)
+	}
+
+	sort.Slice(report.Daily, func(i, j int) bool {
+		if report.Daily[i].Date != report.Daily[j].Date {
+			return report.Daily[i].Date < report.Daily[j].Date
+		}
+		return report.Daily[i].Source < report.Daily[j].Source
+	})
+	sort.Slice(report.Totals, func(i, j int) bool {
+		return report.Totals[i].Spend > report.Totals[j].Spend
+	})
+
+	return report, nil
+}
+
+// reportRange resolves a report's optional dates, defaulting to the last 30 days
+func reportRange(from, to *time.Time) (time.Time, time.Time) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if to != nil {
+		end = *to
+	}
+	start := end.AddDate(0, 0, -(defaultSpendDays - 1))
+	if from != nil {
+		start = *from
+	}
+	return start, end
+}
+
+// eachDSPCampaignDay calls fn with each campaign day between fromKey and toKey in the user's
+// completed DSP log analyses
+func eachDSPCampaignDay(ctx context.Context, logProcessor *ingestion.LogProcessorService, userID, fromKey, toKey string, fn func(source, campaignID, date string, metrics ChannelSpend)) error {
+	results, err := logProcessor.ListAnalysisResults(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list analysis results: %w", err)
+	}
+
+	for _, result := range results {
+		if result.Status != "completed" {
+			continue
+		}
+		summary, err := result.BeeswaxSummary()
+		if err != nil {
+			return err
+		}
+
+		// Logs processed before formats were detected are all Beeswax logs
+		source := summary.Source
+		if source == "" {
+			source = "beeswax"
+		}
+		for campaignID, campaignDays := range summary.CampaignDaily {
+			for date, metrics := range campaignDays {
+				if date < fromKey || date > toKey {
+					continue
+				}
+				fn(source, campaignID, date, ChannelSpend{
+					Impressions: int64(metrics.Impressions),
+					Clicks:      int64(metrics.Clicks),
+					Conversions: float64(metrics.Conversions),
+					Spend:       metrics.Spend,
+				})
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// DefaultSpendDiscrepancyThreshold is the spend discrepancy, in percent, above which a campaign's
+// day is flagged. Billed spend should match logged spend far more closely than impression
+// counts match between platforms, so it's tighter than DefaultDiscrepancyThreshold.
+const DefaultSpendDiscrepancyThreshold = 5.0
+
+// InvoiceImport summarizes a DSP invoice imported from an uploaded file
+type InvoiceImport struct {
+	FileID      string  `json:"fileId"`
+	Rows        int     `json:"rows"`
+	SkippedRows int     `json:"skippedRows"`
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Spend       float64 `json:"spend"`
+}
+
+// SpendDiscrepancy compares the spend a DSP invoiced for a campaign with the spend its logs recorded
+type SpendDiscrepancy struct {
+	Date         string `json:"date,omitempty"`
+	CampaignID   string `json:"campaignId,omitempty"`
+	CampaignName string `json:"campaignName,omitempty"`
+
+	LoggedImpressions   int64   `json:"loggedImpressions"`
+	InvoicedImpressions int64   `json:"invoicedImpressions"`
+	LoggedSpend         float64 `json:"loggedSpend"`
+	InvoicedSpend       float64 `json:"invoicedSpend"`
+	// Discrepancy is invoiced spend over or under logged spend, as a percentage of it; nil when
+	// nothing was logged
+	Discrepancy *float64 `json:"discrepancy"`
+
+	// Flagged is set when the discrepancy is beyond the threshold or spend was invoiced for a day
+	// without logged spend
+	Flagged bool `json:"flagged"`
+}
+
+// SpendReconciliation compares invoiced spend with logged spend by campaign and day. Logged
+// campaigns are matched to invoiced ones by ID or name; totals and campaigns cover matched
+// campaigns only, and whatever didn't match is listed so it can be looked into.
+type SpendReconciliation struct {
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Source    string           `json:"source,omitempty"`
+	Threshold float64          `json:"threshold"`
+	Totals    SpendDiscrepancy `json:"totals"`
+	// Campaigns are the matched campaigns' totals over the range
+	Campaigns []SpendDiscrepancy `json:"campaigns"`
+	// Discrepancies are the campaign days flagged against the threshold
+	Discrepancies []SpendDiscrepancy `json:"discrepancies"`
+	// UninvoicedCampaigns have logged spend but no invoice rows
+	UninvoicedCampaigns []string `json:"uninvoicedCampaigns"`
+	// UnloggedCampaigns are invoiced but have no logged spend
+	UnloggedCampaigns []string `json:"unloggedCampaigns"`
+}
+
+// InvoiceService imports DSP invoices and reconciles billed spend with DSP logs
+type InvoiceService struct {
+	invoices     repository.InvoiceRepository
+	files        repository.FileRepository
+	uow          repository.UnitOfWork
+	fileService  *FileService
+	logProcessor *ingestion.LogProcessorService
+}
+
+// NewInvoiceService creates a new invoice service
+func NewInvoiceService(repos repository.Repositories, uow repository.UnitOfWork, fileService *FileService, logProcessor *ingestion.LogProcessorService) *InvoiceService {
+	return &InvoiceService{
+		invoices:     repos.Invoices,
+		files:        repos.Files,
+		uow:          uow,
+		fileService:  fileService,
+		logProcessor: logProcessor,
+	}
+}
+
+// ImportInvoice parses an uploaded file as a DSP invoice or billing report and stores its rows,
+// replacing any earlier import of the same file
+func (s *InvoiceService) ImportInvoice(ctx context.Context, fileID, userID string) (*InvoiceImport, error) {
+	if _, err := s.files.FindByID(ctx, fileID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+
+	file, _, err := s.fileService.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	invoice, err := ingestion.ParseInvoice(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Invoices.DeleteRows(ctx, fileID, userID); err != nil {
+			return fmt.Errorf("failed to clear invoice rows: %w", err)
+		}
+		if err := repos.Invoices.InsertRows(ctx, fileID, userID, time.Now(), invoice.Rows); err != nil {
+			return fmt.Errorf("failed to store invoice rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &InvoiceImport{
+		FileID:      fileID,
+		Rows:        len(invoice.Rows),
+		SkippedRows: invoice.SkippedRows,
+		From:        invoice.Rows[0].Date,
+		To:          invoice.Rows[0].Date,
+	}
+	for _, row := range invoice.Rows {
+		summary.From = min(summary.From, row.Date)
+		summary.To = max(summary.To, row.Date)
+		summary.Spend += float64(row.SpendMicros) / 1000000
+	}
+
+	return summary, nil
+}
+
+// GetSpendReconciliation reconciles the user's invoiced spend with spend in their DSP logs,
+// optionally only logs from one source, flagging campaign days whose spend differs by more than
+// threshold percent. Without dates it covers the last 30 days.
+func (s *InvoiceService) GetSpendReconciliation(ctx context.Context, userID, source string, from, to *time.Time, threshold float64) (*SpendReconciliation, error) {
+	start, end := reportRange(from, to)
+	fromKey, toKey := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	rows, err := s.invoices.ListRows(ctx, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invoice rows: %w", err)
+	}
+
+	// Index invoiced campaigns by both ID and name, so a logged campaign can match either
+	campaigns := make(map[string]*SpendDiscrepancy)
+	byKey := make(map[string]string)
+	type dayKey struct{ date, campaign string }
+	days := make(map[dayKey]*SpendDiscrepancy)
+	for _, row := range rows {
+		id := row.CampaignID
+		if id == "" {
+			id = row.CampaignName
+		}
+
+		campaign, ok := campaigns[id]
+		if !ok {
+			campaign = &SpendDiscrepancy{CampaignID: row.CampaignID, CampaignName: row.CampaignName}
+			campaigns[id] = campaign
+		}
+		for _, name := range []string{row.CampaignID, row.CampaignName} {
+			if key := campaignKey(name); key != "" {
+				byKey[key] = id
+			}
+		}
+
+		day := &SpendDiscrepancy{Date: row.Date, CampaignID: row.CampaignID, CampaignName: row.CampaignName}
+		day.InvoicedImpressions = row.Impressions
+		day.InvoicedSpend = float64(row.SpendMicros) / 1000000
+		days[dayKey{row.Date, id}] = day
+	}
+
+	uninvoiced := make(map[string]struct{})
+	matched := make(map[string]struct{})
+	err = eachDSPCampaignDay(ctx, s.logProcessor, userID, fromKey, toKey, func(logSource, campaignID, date string, metrics ChannelSpend) {
+		if source != "" && logSource != source {
+			return
+		}
+		id, ok := byKey[campaignKey(campaignID)]
+		if !ok {
+			uninvoiced[campaignID] = struct{}{}
+			return
+		}
+		matched[id] = struct{}{}
+
+		day, ok := days[dayKey{date, id}]
+		if !ok {
+			campaign := campaigns[id]
+			day = &SpendDiscrepancy{Date: date, CampaignID: campaign.CampaignID, CampaignName: campaign.CampaignName}
+			days[dayKey{date, id}] = day
+		}
+		day.LoggedImpressions += metrics.Impressions
+		day.LoggedSpend += metrics.Spend
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SpendReconciliation{
+		From:                fromKey,
+		To:                  toKey,
+		Source:              source,
+		Threshold:           threshold,
+		Campaigns:           []SpendDiscrepancy{},
+		Discrepancies:       []SpendDiscrepancy{},
+		UninvoicedCampaigns: []string{},
+		UnloggedCampaigns:   []string{},
+	}
+
+	for key, day := range days {
+		if _, ok := matched[key.campaign]; !ok {
+			continue
+		}
+		day.compare(threshold)
+		if day.Flagged {
+			report.Discrepancies = append(report.Discrepancies, *day)
+		}
+
+		campaign := campaigns[key.campaign]
+		campaign.add(day)
+		report.Totals.add(day)
+	}
+	report.Totals.compare(threshold)
+
+	for id, campaign := range campaigns {
+		if _, ok := matched[id]; !ok {
+			report.UnloggedCampaigns = append(report.UnloggedCampaigns, id)
+			continue
+		}
+		campaign.compare(threshold)
+		report.Campaigns = append(report.Campaigns, *campaign)
+	}
+	for campaignID := range uninvoiced {
+		report.UninvoicedCampaigns = append(report.UninvoicedCampaigns, campaignID)
+	}
+
+	sort.Slice(report.Campaigns, func(i, j int) bool {
+		return report.Campaigns[i].InvoicedSpend > report.Campaigns[j].InvoicedSpend
+	})
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		a, b := report.Discrepancies[i], report.Discrepancies[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.CampaignID != b.CampaignID {
+			return a.CampaignID < b.CampaignID
+		}
+		return a.CampaignName < b.CampaignName
+	})
+	sort.Strings(report.UninvoicedCampaigns)
+	sort.Strings(report.UnloggedCampaigns)
+
+	return report, nil
+}
+
+// add accumulates another discrepancy's impressions and spend
+func (d *SpendDiscrepancy) add(other *SpendDiscrepancy) {
+	d.LoggedImpressions += other.LoggedImpressions
+	d.InvoicedImpressions += other.InvoicedImpressions
+	d.LoggedSpend += other.LoggedSpend
+	d.InvoicedSpend += other.InvoicedSpend
+}
+
+// compare fills in the discrepancy and flags it against threshold
+func (d *SpendDiscrepancy) compare(threshold float64) {
+	d.Discrepancy = percentDifference(d.InvoicedSpend, d.LoggedSpend)
+
+	switch {
+	case d.Discrepancy != nil:
+		d.Flagged = math.Abs(*d.Discrepancy) > threshold
+	default:
+		d.Flagged = d.InvoicedSpend > 0
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+)
+
+// maxRunningPercent caps the reported progress of a running job; storing the results and rollups
+// after the last row is parsed takes a while too
+const maxRunningPercent = 99
+
+// JobProgressTracker holds the progress of the jobs running on this server and notifies
+// watchers as it changes
+type JobProgressTracker struct {
+	mu       sync.Mutex
+	running  map[string]*trackedJob
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+// trackedJob is a running job's latest progress
+type trackedJob struct {
+	startedAt time.Time
+	progress  models.JobProgress
+}
+
+// NewJobProgressTracker creates an empty progress tracker
+func NewJobProgressTracker() *JobProgressTracker {
+	return &JobProgressTracker{
+		running:  make(map[string]*trackedJob),
+		watchers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Start begins tracking a job
+func (t *JobProgressTracker) Start(jobID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.running[jobID] = &trackedJob{startedAt: now, progress: models.JobProgress{UpdatedAt: now}}
+	t.notify(jobID)
+}
+
+// Update records a job's parsing progress, estimating its percentage and time remaining
+func (t *JobProgressTracker) Update(jobID string, progress ingestion.Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.running[jobID]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	fraction := progress.Fraction()
+	job.progress = models.JobProgress{
+		RowsProcessed: progress.Rows,
+		EstimatedRows: progress.EstimatedRows(),
+		Percent:       math.Min(math.Round(fraction*1000)/10, maxRunningPercent),
+		UpdatedAt:     now,
+	}
+	// Extrapolate from this run's pace; a run resuming a failed one skips the bytes it parsed
+	if read := progress.BytesRead - progress.ResumedBytes; fraction > 0 && fraction < 1 && read > 0 {
+		elapsed := now.Sub(job.startedAt).Seconds()
+		eta := math.Round(elapsed / float64(read) * float64(progress.TotalBytes-progress.BytesRead))
+		job.progress.ETASeconds = &eta
+	}
+	t.notify(jobID)
+}
+
+// Finish stops tracking a job once its final status has been recorded
+func (t *JobProgressTracker) Finish(jobID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.running, jobID)
+	t.notify(jobID)
+}
+
+// Get returns a copy of a running job's progress, or nil if it isn't running here
+func (t *JobProgressTracker) Get(jobID string) *models.JobProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.running[jobID]
+	if !ok {
+		return nil
+	}
+	progress := job.progress
+	return &progress
+}
+
+// Watch returns a channel that receives a value whenever a job's progress changes, and a
+// function that stops watching. Changes arriving faster than they are received are coalesced.
+func (t *JobProgressTracker) Watch(jobID string) (<-chan struct{}, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if t.watchers[jobID] == nil {
+		t.watchers[jobID] = make(map[chan struct{}]struct{})
+	}
+	t.watchers[jobID][ch] = struct{}{}
+
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.watchers[jobID], ch)
+		if len(t.watchers[jobID]) == 0 {
+			delete(t.watchers, jobID)
+		}
+	}
+}
+
+// notify wakes a job's watchers without blocking on ones that haven't caught up
+func (t *JobProgressTracker) notify(jobID string) {
+	for ch := range t.watchers[jobID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Journey export errors
+var (
+	ErrJourneysUnavailable  = errors.New("journeys unavailable: log records are not being persisted")
+	ErrCampaignNotDelivered = errors.New("campaign has no impressions with user IDs in this file")
+)
+
+// JourneyService exports per-user event sequences from persisted log records. User IDs are
+// replaced by keyed hashes, which stay stable across a user's exports so journeys can be
+// joined between files without revealing the IDs.
+type JourneyService struct {
+	analytics *AnalyticsService
+	records   repository.LogRecordRepository
+	hashKey   []byte
+}
+
+// journeyKeyLabel is the HKDF info journey hash keys are derived from a secret with
+const journeyKeyLabel = "journey-hash"
+
+// NewJourneyService creates a new journey service. records is nil when log records aren't persisted.
+func NewJourneyService(analytics *AnalyticsService, records repository.LogRecordRepository, hashKey []byte) *JourneyService {
+	return &JourneyService{
+		analytics: analytics,
+		records:   records,
+		hashKey:   hashKey,
+	}
+}
+
+// DeriveJourneyKey derives the key that hashes user IDs in journey exports from a secret used
+// for something else, such as signing access tokens, so the hashes reveal nothing about it
+func DeriveJourneyKey(secret string) ([]byte, error) {
+	return deriveKey(secret, journeyKeyLabel)
+}
+
+// ExportJourneys calls emit with each anonymized user's journey through a campaign. Errors
+// that make the file unexportable are returned before the first journey is emitted.
+func (s *JourneyService) ExportJourneys(ctx context.Context, fileID, userID, campaignID string, emit func(*ingestion.Journey) error) error {
+	if s.records == nil {
+		return ErrJourneysUnavailable
+	}
+
+	summary, err := s.analytics.getSummary(ctx, fileID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Reach is only tracked for logs with USER_ID
+	if len(summary.ReachFrequency) == 0 {
+		return ErrReportUnavailable
+	}
+	if _, ok := summary.ReachFrequency[campaignID]; !ok {
+		return ErrCampaignNotDelivered
+	}
+
+	builder := ingestion.NewJourneyBuilder(func(viewerID string) string {
+		return s.anonymize(userID, viewerID)
+	}, emit)
+	if err := s.records.ScanJourneyRecords(ctx, fileID, userID, campaignID, builder.Add); err != nil {
+		return err
+	}
+
+	return builder.Flush()
+}
+
+// anonymize hashes a viewer ID with the account it belongs to, so the same viewer can't be
+// matched across accounts
+func (s *JourneyService) anonymize(userID, viewerID string) string {
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(userID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(viewerID))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
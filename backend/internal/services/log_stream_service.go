@@ -0,0 +1,442 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/integrations"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/bolognesandwiches/AdVantage/internal/streaming"
+	"github.com/google/uuid"
+)
+
+// streamCheckInterval is how often the consumer manager starts new streams and stops paused or deleted ones
+const streamCheckInterval = 30 * time.Second
+
+// streamFlushInterval is how often a consumer writes its rollups and commits its offsets, which
+// bounds how far behind the stream reports run
+const streamFlushInterval = 10 * time.Second
+
+// streamRetryDelay is how long a failed consumer waits before reconnecting
+const streamRetryDelay = 30 * time.Second
+
+// Log stream errors
+var (
+	ErrInvalidLogStream  = errors.New("invalid log stream")
+	ErrLogStreamNotFound = errors.New("log stream not found")
+	ErrLogStreamExists   = errors.New("topic is already streamed with this consumer group")
+	// ErrLogStreamCredentials is returned for a stream with a password when the server has no
+	// key to encrypt it with
+	ErrLogStreamCredentials = errors.New("stream credentials can't be stored on this server")
+)
+
+// LogStreamService consumes users' Kafka topics of bid and impression events, aggregating them into
+// the same rollups uploaded logs produce. Each consumer writes its rollups into an hourly segment,
+// recorded as a processed file named after the stream, and commits its offsets only once the
+// segment is stored, so a restart resumes where the stored rollups end.
+type LogStreamService struct {
+	streams      repository.LogStreamRepository
+	files        repository.FileRepository
+	mappings     repository.MappingProfileRepository
+	fileStorage  *storage.FileStorage
+	rollups      *RollupService
+	resultCache  *ResultCache
+	realtime     *RealtimeService
+	usage        *UsageService
+	entitlements *EntitlementService
+	cipher       *integrations.TokenCipher
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // stream ID → stops its consumer
+	wg      sync.WaitGroup
+}
+
+// NewLogStreamService creates a log stream service, which also records streamed events into
+// realtime's live metrics and meters them with usage. The cipher encrypts REST Proxy passwords
+// and may be nil, in which case only streams without one can be created.
+func NewLogStreamService(repos repository.Repositories, fileStorage *storage.FileStorage, rollups *RollupService, resultCache *ResultCache, realtime *RealtimeService, usage *UsageService, entitlements *EntitlementService, cipher *integrations.TokenCipher) *LogStreamService {
+	return &LogStreamService{
+		streams:      repos.Streams,
+		files:        repos.Files,
+		mappings:     repos.Mappings,
+		fileStorage:  fileStorage,
+		rollups:      rollups,
+		resultCache:  resultCache,
+		realtime:     realtime,
+		usage:        usage,
+		entitlements: entitlements,
+		cipher:       cipher,
+		running:      make(map[string]context.CancelFunc),
+	}
+}
+
+// CreateStream validates and stores a new stream, which starts consuming on the manager's next check.
+// The password, if any, is encrypted before it is stored.
+func (s *LogStreamService) CreateStream(ctx context.Context, stream *models.LogStream, password string) error {
+	stream.Name = strings.TrimSpace(stream.Name)
+	if stream.Name == "" || stream.Topic == "" {
+		return fmt.Errorf("%w: name and topic are required", ErrInvalidLogStream)
+	}
+	parsed, err := url.Parse(stream.ProxyURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil {
+		return fmt.Errorf("%w: proxyUrl must be an http or https URL without credentials", ErrInvalidLogStream)
+	}
+	if !slices.Contains(ingestion.LogSources(), stream.Source) {
+		return fmt.Errorf("%w: source must be one of %s", ErrInvalidLogStream, strings.Join(ingestion.LogSources(), ", "))
+	}
+	if stream.GroupID == "" {
+		stream.GroupID = "advantage-" + stream.UserID
+	}
+
+	if password != "" {
+		if s.cipher == nil {
+			return ErrLogStreamCredentials
+		}
+		if stream.Password, err = s.cipher.Encrypt(password); err != nil {
+			return fmt.Errorf("failed to encrypt password: %w", err)
+		}
+	}
+
+	now := time.Now()
+	stream.ID = uuid.New().String()
+	stream.Status = models.LogStreamStatusActive
+	stream.CreatedAt = now
+	stream.UpdatedAt = now
+	if err := s.streams.Create(ctx, stream); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrLogStreamExists
+		}
+		return fmt.Errorf("failed to create log stream: %w", err)
+	}
+
+	return nil
+}
+
+// ListStreams lists a user's streams
+func (s *LogStreamService) ListStreams(ctx context.Context, userID string) ([]*models.LogStream, error) {
+	streams, err := s.streams.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log streams: %w", err)
+	}
+
+	return streams, nil
+}
+
+// SetPaused pauses or resumes a user's stream; the manager stops or starts its consumer on its next check
+func (s *LogStreamService) SetPaused(ctx context.Context, id, userID string, paused bool) (*models.LogStream, error) {
+	status := models.LogStreamStatusActive
+	if paused {
+		status = models.LogStreamStatusPaused
+	}
+	if err := s.streams.SetStatus(ctx, id, userID, status); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrLogStreamNotFound
+		}
+		return nil, fmt.Errorf("failed to update log stream: %w", err)
+	}
+
+	return s.streams.FindByID(ctx, id, userID)
+}
+
+// DeleteStream deletes a user's stream. The rollups it already streamed stay, with their segment files.
+func (s *LogStreamService) DeleteStream(ctx context.Context, id, userID string) error {
+	if err := s.streams.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrLogStreamNotFound
+		}
+		return fmt.Errorf("failed to delete log stream: %w", err)
+	}
+
+	return nil
+}
+
+// Run keeps a consumer running for every stream that isn't paused until the context is
+// canceled, then waits for the consumers to store what they consumed and flushes live metrics
+func (s *LogStreamService) Run(ctx context.Context) {
+	ticker := time.NewTicker(streamCheckInterval)
+	defer ticker.Stop()
+
+	go s.realtime.Run(ctx)
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := s.realtime.Flush(flushCtx); err != nil {
+			slog.Error("Failed to flush realtime metrics", "error", err)
+		}
+	}()
+
+	for {
+		if err := s.reconcile(ctx); err != nil {
+			slog.Error("Failed to list log streams", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile starts consumers for new streams and stops those of streams paused or deleted
+func (s *LogStreamService) reconcile(ctx context.Context) error {
+	streams, err := s.streams.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	streams, err = s.entitledStreams(ctx, streams)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		active[stream.ID] = true
+		if _, ok := s.running[stream.ID]; ok {
+			continue
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		s.running[stream.ID] = cancel
+		s.wg.Add(1)
+		go func(stream *models.LogStream) {
+			defer s.wg.Done()
+			s.runStream(streamCtx, stream)
+		}(stream)
+	}
+
+	for id, cancel := range s.running {
+		if !active[id] {
+			cancel()
+			delete(s.running, id)
+		}
+	}
+	return nil
+}
+
+// entitledStreams drops the streams whose org's plan no longer includes connectors, so their
+// consumers stop once a trial ends or the plan is downgraded. The streams are marked failing
+// with the reason, and start again once the plan allows them.
+func (s *LogStreamService) entitledStreams(ctx context.Context, streams []*models.LogStream) ([]*models.LogStream, error) {
+	checked := make(map[string]error) // user ID → whether their org may use connectors
+	entitled := make([]*models.LogStream, 0, len(streams))
+	for _, stream := range streams {
+		err, ok := checked[stream.UserID]
+		if !ok {
+			err = s.entitlements.RequireUserFeature(ctx, stream.UserID, models.FeatureConnectors)
+			checked[stream.UserID] = err
+		}
+
+		switch {
+		case err == nil:
+			entitled = append(entitled, stream)
+		case errors.Is(err, ErrFeatureNotInPlan):
+			// Only record the reason once, rather than on every check
+			if stream.LastError != err.Error() {
+				if recordErr := s.streams.RecordError(ctx, stream.ID, err.Error(), time.Now()); recordErr != nil {
+					errreport.Report(errreport.WithTags(ctx, "streamID", stream.ID), "Failed to record log stream error", recordErr)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("failed to check plan: %w", err)
+		}
+	}
+	return entitled, nil
+}
+
+// runStream consumes a stream until the context is canceled, reconnecting after failures
+func (s *LogStreamService) runStream(ctx context.Context, stream *models.LogStream) {
+	ctx = errreport.WithTags(ctx, "streamID", stream.ID, "userID", stream.UserID)
+	for {
+		err := s.consume(ctx, stream)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Log stream consumer failed", "streamID", stream.ID, "error", err)
+			if recordErr := s.streams.RecordError(ctx, stream.ID, err.Error(), time.Now()); recordErr != nil {
+				errreport.Report(ctx, "Failed to record log stream error", recordErr)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(streamRetryDelay):
+		}
+	}
+}
+
+// streamSegment accumulates a consumer's rollups for the hour its segment file covers
+type streamSegment struct {
+	fileID   string
+	start    time.Time
+	builder  *ingestion.RollupBuilder
+	consumed int64 // since the last flush
+	rejected int64
+}
+
+// consume joins the stream's consumer group and aggregates its events until the context is
+// canceled or the consumer fails. Whatever was consumed is flushed before it returns.
+func (s *LogStreamService) consume(ctx context.Context, stream *models.LogStream) error {
+	password := ""
+	if stream.Password != "" {
+		if s.cipher == nil {
+			return ErrLogStreamCredentials
+		}
+		var err error
+		if password, err = s.cipher.Decrypt(stream.Password); err != nil {
+			return fmt.Errorf("failed to decrypt password: %w", err)
+		}
+	}
+
+	profiles, err := s.mappings.ListProfiles(ctx, stream.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load mapping profiles: %w", err)
+	}
+	decoder, err := ingestion.NewStreamDecoder(stream.Source, profiles[stream.Source])
+	if err != nil {
+		return err
+	}
+
+	consumer, err := streaming.NewKafkaConsumer(stream.ProxyURL, stream.GroupID, stream.Username, password)
+	if err != nil {
+		return err
+	}
+	if err := consumer.Subscribe(ctx, stream.Topic); err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := consumer.Close(closeCtx); err != nil {
+			slog.Warn("Failed to close log stream consumer", "streamID", stream.ID, "error", err)
+		}
+	}()
+
+	segment := &streamSegment{builder: ingestion.NewRollupBuilder()}
+	lastFlush := time.Now()
+	for {
+		records, err := consumer.Poll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				return err
+			}
+			// Store what was consumed before shutdown so it isn't consumed again
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			return s.flush(flushCtx, stream, consumer, segment)
+		}
+
+		// Once everything in an earlier hour's segment is stored, further events start a new one
+		if segment.consumed == 0 && segment.fileID != "" && time.Now().UTC().Truncate(time.Hour).After(segment.start) {
+			*segment = streamSegment{builder: ingestion.NewRollupBuilder()}
+		}
+
+		for _, record := range records {
+			event, err := decoder.Decode(record.Value)
+			if err != nil {
+				segment.rejected++
+				continue
+			}
+			segment.builder.Add(&event)
+			s.realtime.Record(stream.UserID, &event)
+			segment.consumed++
+		}
+
+		if time.Since(lastFlush) >= streamFlushInterval {
+			if err := s.flush(ctx, stream, consumer, segment); err != nil {
+				return err
+			}
+			lastFlush = time.Now()
+		}
+	}
+}
+
+// flush stores the segment's rollups, commits the offsets consumed into them and records the
+// stream's progress
+func (s *LogStreamService) flush(ctx context.Context, stream *models.LogStream, consumer *streaming.KafkaConsumer, segment *streamSegment) error {
+	if segment.consumed == 0 && segment.rejected == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if segment.consumed > 0 {
+		if segment.fileID == "" {
+			fileID, err := s.createSegmentFile(ctx, stream, now.Truncate(time.Hour))
+			if err != nil {
+				return err
+			}
+			segment.fileID, segment.start = fileID, now.Truncate(time.Hour)
+		}
+		if err := s.rollups.ReplaceRollups(ctx, segment.fileID, stream.UserID, segment.builder.Rollups()); err != nil {
+			return fmt.Errorf("failed to store stream rollups: %w", err)
+		}
+		s.resultCache.InvalidateFile(ctx, stream.UserID, segment.fileID)
+	}
+
+	if err := consumer.Commit(ctx); err != nil {
+		return err
+	}
+	s.usage.RecordRowsIngested(ctx, stream.UserID, segment.consumed)
+	if err := s.streams.RecordProgress(ctx, stream.ID, segment.consumed, segment.rejected, now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("failed to record log stream progress: %w", err)
+	}
+	segment.consumed, segment.rejected = 0, 0
+	return nil
+}
+
+// createSegmentFile records a processed file for an hour of a stream's rollups. The stored file
+// describes the segment, so the file can be downloaded and deleted like an upload.
+func (s *LogStreamService) createSegmentFile(ctx context.Context, stream *models.LogStream, start time.Time) (string, error) {
+	descriptor, err := json.Marshal(map[string]interface{}{
+		"streamId":     stream.ID,
+		"topic":        stream.Topic,
+		"source":       stream.Source,
+		"segmentStart": start,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fileName := fmt.Sprintf("%s %s.json", stream.Name, start.Format("2006-01-02T15Z"))
+	fileInfo, err := s.fileStorage.StoreFile(bytes.NewReader(descriptor), fileName, "application/json", stream.UserID, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to store segment file: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.files.Create(ctx, &models.File{
+		ID:         fileInfo.ID,
+		UserID:     stream.UserID,
+		FileName:   fileInfo.FileName,
+		FileSize:   fileInfo.FileSize,
+		FileType:   fileInfo.FileType,
+		FilePath:   fileInfo.FilePath,
+		Status:     models.FileStatusProcessed,
+		UploadedAt: fileInfo.UploadedAt,
+		UpdatedAt:  now,
+	}); err != nil {
+		_ = s.fileStorage.DeleteFile(fileInfo.ID, stream.UserID)
+		return "", fmt.Errorf("failed to save segment file metadata: %w", err)
+	}
+
+	return fileInfo.ID, nil
+}
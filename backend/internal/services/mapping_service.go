@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Mapping profile errors
+var (
+	ErrInvalidMappingProfile  = errors.New("invalid mapping profile")
+	ErrMappingProfileNotFound = errors.New("mapping profile not found")
+	ErrFileSchemaNotFound     = errors.New("file schema not found")
+)
+
+// MappingService manages users' column mapping profiles and the schemas recorded for their
+// uploads. Profiles apply to files processed after they're set.
+type MappingService struct {
+	profiles repository.MappingProfileRepository
+	schemas  repository.FileSchemaRepository
+}
+
+// NewMappingService creates a new mapping service
+func NewMappingService(repos repository.Repositories) *MappingService {
+	return &MappingService{
+		profiles: repos.Mappings,
+		schemas:  repos.Schemas,
+	}
+}
+
+// ListProfiles lists a user's mapping profiles, ordered by source
+func (s *MappingService) ListProfiles(ctx context.Context, userID string) ([]*ingestion.MappingProfile, error) {
+	profiles, err := s.profiles.ListProfiles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mapping profiles: %w", err)
+	}
+
+	list := make([]*ingestion.MappingProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		list = append(list, profile)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Source < list[j].Source
+	})
+
+	return list, nil
+}
+
+// SetProfile validates and saves a user's mapping profile for a log source, replacing the
+// earlier one
+func (s *MappingService) SetProfile(ctx context.Context, userID string, profile *ingestion.MappingProfile) error {
+	if !isLogSource(profile.Source) {
+		return fmt.Errorf("%w: unknown log source %q", ErrInvalidMappingProfile, profile.Source)
+	}
+
+	columns := make(map[string]string, len(profile.Columns))
+	for name, canonical := range profile.Columns {
+		name = strings.TrimSpace(name)
+		canonical = strings.ToUpper(strings.TrimSpace(canonical))
+		if name == "" {
+			return fmt.Errorf("%w: header names can't be empty", ErrInvalidMappingProfile)
+		}
+		if !ingestion.IsCanonicalColumn(canonical) {
+			return fmt.Errorf("%w: %q is not a canonical column", ErrInvalidMappingProfile, canonical)
+		}
+		columns[name] = canonical
+	}
+	profile.Columns = columns
+	profile.UpdatedAt = time.Now()
+
+	if err := s.profiles.SetProfile(ctx, userID, profile); err != nil {
+		return fmt.Errorf("failed to set mapping profile: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteProfile removes a user's mapping profile for a log source
+func (s *MappingService) DeleteProfile(ctx context.Context, userID, source string) error {
+	err := s.profiles.DeleteProfile(ctx, userID, source)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMappingProfileNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete mapping profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileSchema returns the columns recorded for a processed CSV log and how they drifted
+// from the previous upload from its source
+func (s *MappingService) GetFileSchema(ctx context.Context, fileID, userID string) (*ingestion.FileSchema, error) {
+	schema, err := s.schemas.FindByFileID(ctx, fileID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrFileSchemaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// isLogSource reports whether source names a registered log format
+func isLogSource(source string) bool {
+	for _, registered := range ingestion.LogSources() {
+		if registered == source {
+			return true
+		}
+	}
+	return false
+}
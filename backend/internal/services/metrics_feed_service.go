@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+)
+
+// ErrFeedReportNotFound is returned for a metrics feed report that doesn't exist
+var ErrFeedReportNotFound = errors.New("metrics feed report not found")
+
+// Metrics feed field types, named the way BI connectors name them
+const (
+	FeedTypeString   = "string"
+	FeedTypeDate     = "date"
+	FeedTypeDateTime = "datetime"
+	FeedTypeInteger  = "integer"
+	FeedTypeNumber   = "number"
+)
+
+// FeedField is a column of a metrics feed report
+type FeedField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// FeedReport is a metrics feed report. Its fields are fixed: new fields may be appended, but
+// fields are never renamed, retyped or removed, so BI connectors built on a report keep working.
+type FeedReport struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Fields      []FeedField `json:"fields"`
+
+	dimension string
+	grain     string
+}
+
+// FeedRow is a row of a metrics feed report, keyed by field name
+type FeedRow map[string]interface{}
+
+// feedMetricFields are the metric fields every report ends with
+var feedMetricFields = []FeedField{
+	{"bids", FeedTypeInteger, "Bids placed"},
+	{"impressions", FeedTypeInteger, "Impressions won"},
+	{"clicks", FeedTypeInteger, "Clicks"},
+	{"conversions", FeedTypeInteger, "Conversions"},
+	{"spend", FeedTypeNumber, "Media spend in USD"},
+	{"revenue", FeedTypeNumber, "Conversion revenue in USD"},
+	{"ctr", FeedTypeNumber, "Click-through rate, as a percentage of impressions"},
+	{"roas", FeedTypeNumber, "Revenue per dollar spent; 0 without spend"},
+	{"cpa", FeedTypeNumber, "Spend per conversion; 0 without conversions"},
+	{"measurable_impressions", FeedTypeInteger, "Impressions viewability could be measured for"},
+	{"viewable_impressions", FeedTypeInteger, "Impressions measured as viewable"},
+}
+
+// feedReports are the metrics feed's reports, in the order they're listed
+var feedReports = []*FeedReport{
+	newFeedReport("campaign_daily", "Daily delivery by campaign", ingestion.RollupByCampaign, ingestion.RollupDaily,
+		FeedField{"campaign_id", FeedTypeString, "DSP campaign ID"}),
+	newFeedReport("campaign_hourly", "Hourly delivery by campaign", ingestion.RollupByCampaign, ingestion.RollupHourly,
+		FeedField{"campaign_id", FeedTypeString, "DSP campaign ID"}),
+	newFeedReport("domain_daily", "Daily delivery by site domain", ingestion.RollupByDomain, ingestion.RollupDaily,
+		FeedField{"domain", FeedTypeString, "Domain the impression was served on"}),
+	newFeedReport("country_daily", "Daily delivery by country", ingestion.RollupByGeo, ingestion.RollupDaily,
+		FeedField{"country", FeedTypeString, "Country code of the user"}),
+	newFeedReport("device_daily", "Daily delivery by device type", ingestion.RollupByDevice, ingestion.RollupDaily,
+		FeedField{"device_type", FeedTypeString, "Device type of the user"}),
+	newFeedReport("total_daily", "Daily delivery across all campaigns", ingestion.RollupTotal, ingestion.RollupDaily),
+	newFeedReport("total_hourly", "Hourly delivery across all campaigns", ingestion.RollupTotal, ingestion.RollupHourly),
+}
+
+// newFeedReport describes a report of a rollup dimension at a grain, with the time field first
+// and the dimension's field, if any, before the metrics
+func newFeedReport(name, description, dimension, grain string, dimensionFields ...FeedField) *FeedReport {
+	timeField := FeedField{"date", FeedTypeDate, "UTC day, as YYYY-MM-DD"}
+	if grain == ingestion.RollupHourly {
+		timeField = FeedField{"hour", FeedTypeDateTime, "Start of the UTC hour, as RFC 3339"}
+	}
+
+	fields := append([]FeedField{timeField}, dimensionFields...)
+	return &FeedReport{
+		Name:        name,
+		Description: description,
+		Fields:      append(fields, feedMetricFields...),
+		dimension:   dimension,
+		grain:       grain,
+	}
+}
+
+// MetricsFeedService serves users' delivery as fixed-schema, paginated reports for BI tools such
+// as Looker Studio community connectors and Power BI web sources. Reports read rollups, so files
+// processed before rollups existed are left out until they are reprocessed, and custom metrics
+// aren't included since they would change a report's schema.
+type MetricsFeedService struct {
+	rollups *RollupService
+}
+
+// NewMetricsFeedService creates a new metrics feed service, reading delivery from rollups
+func NewMetricsFeedService(rollups *RollupService) *MetricsFeedService {
+	return &MetricsFeedService{
+		rollups: rollups,
+	}
+}
+
+// Reports lists the feed's reports
+func (s *MetricsFeedService) Reports() []*FeedReport {
+	return feedReports
+}
+
+// Report returns one of the feed's reports by name
+func (s *MetricsFeedService) Report(name string) (*FeedReport, error) {
+	for _, report := range feedReports {
+		if report.Name == name {
+			return report, nil
+		}
+	}
+	return nil, ErrFeedReportNotFound
+}
+
+// StreamReport reads a page of up to limit rows of a user's report between the from and to
+// dates (inclusive), starting after the cursor. Rows come in time order, so a connector syncing
+// incrementally can ask for the days since its last sync. start is called with the report once
+// the query is known to be valid, then emit with each row. It returns the cursor of the next
+// page, or "" on the last page.
+func (s *MetricsFeedService) StreamReport(ctx context.Context, userID, name string, from, to *time.Time, cursor string, limit int, start func(*FeedReport) error, emit func(FeedRow) error) (string, error) {
+	report, err := s.Report(name)
+	if err != nil {
+		return "", err
+	}
+
+	return s.rollups.StreamRollups(ctx, userID, report.dimension, report.grain, "", from, to, cursor, limit,
+		func(*RollupSeries) error { return start(report) },
+		func(rollup ingestion.Rollup) error { return emit(report.row(rollup)) },
+	)
+}
+
+// row formats a rollup as a row of the report
+func (r *FeedReport) row(rollup ingestion.Rollup) FeedRow {
+	row := FeedRow{
+		"bids":                   rollup.Bids,
+		"impressions":            rollup.Impressions,
+		"clicks":                 rollup.Clicks,
+		"conversions":            rollup.Conversions,
+		"spend":                  rollup.Spend,
+		"revenue":                rollup.Revenue,
+		"ctr":                    rollup.CTR,
+		"roas":                   rollup.ROAS,
+		"cpa":                    rollup.CPA,
+		"measurable_impressions": rollup.MeasurableImpressions,
+		"viewable_impressions":   rollup.ViewableImpressions,
+	}
+
+	bucket := rollup.Bucket.UTC()
+	if r.grain == ingestion.RollupHourly {
+		row["hour"] = bucket.Format(time.RFC3339)
+	} else {
+		row["date"] = bucket.Format("2006-01-02")
+	}
+	if r.dimension != ingestion.RollupTotal {
+		row[r.Fields[1].Name] = rollup.Value
+	}
+
+	return row
+}
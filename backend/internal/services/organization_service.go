@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Organization errors
+var (
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrInvalidCurrency       = errors.New("invalid currency")
+	ErrInvalidUploadDefaults = errors.New("invalid upload defaults")
+)
+
+// parsedExtensions are the kinds of file upload defaults can pick a parser for
+var parsedExtensions = []string{".csv", ".json", ".jsonl", ".ndjson"}
+
+// OrganizationService handles org-wide settings
+type OrganizationService struct {
+	orgs repository.OrganizationRepository
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(orgs repository.OrganizationRepository) *OrganizationService {
+	return &OrganizationService{
+		orgs: orgs,
+	}
+}
+
+// GetOrganization returns an org with its settings
+func (s *OrganizationService) GetOrganization(ctx context.Context, orgID string) (*models.Organization, error) {
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// SetJobPriority sets the priority of an org's uploads that don't ask for one, so an org
+// running bulk backfills can be queued behind interactive users
+func (s *OrganizationService) SetJobPriority(ctx context.Context, orgID, priority string) (*models.Organization, error) {
+	if !models.ValidJobPriority(priority) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPriority, priority)
+	}
+
+	if err := s.orgs.SetJobPriority(ctx, orgID, priority); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to set job priority: %w", err)
+	}
+
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// SetReportingCurrency sets the currency an org's campaign spend is reported in
+func (s *OrganizationService) SetReportingCurrency(ctx context.Context, orgID, currency string) (*models.Organization, error) {
+	if !currencyPattern.MatchString(currency) {
+		return nil, fmt.Errorf("%w: currency must be an ISO 4217 code such as USD", ErrInvalidCurrency)
+	}
+
+	if err := s.orgs.SetReportingCurrency(ctx, orgID, currency); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to set reporting currency: %w", err)
+	}
+
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// SetUploadDefaults sets how an org's uploads are parsed when they don't say. Parsers are keyed
+// by file extension, written with or without the dot.
+func (s *OrganizationService) SetUploadDefaults(ctx context.Context, orgID string, defaults models.UploadDefaults) (*models.Organization, error) {
+	parsers := make(map[string]models.ParserDefault, len(defaults.Parsers))
+	for ext, parser := range defaults.Parsers {
+		ext = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if !slices.Contains(parsedExtensions, ext) {
+			return nil, fmt.Errorf("%w: %s files aren't parsed; parsers can be set for %s", ErrInvalidUploadDefaults, ext, strings.Join(parsedExtensions, ", "))
+		}
+		if parser.Format != "" && ext != ".csv" {
+			return nil, fmt.Errorf("%w: %s: log formats apply only to .csv files", ErrInvalidUploadDefaults, ext)
+		}
+		if err := (ingestion.ParseOptions{Parser: parser.Parser, Format: parser.Format}).Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidUploadDefaults, ext, err)
+		}
+		if parser != (models.ParserDefault{}) {
+			parsers[ext] = parser
+		}
+	}
+	defaults.Parsers = nil
+	if len(parsers) > 0 {
+		defaults.Parsers = parsers
+	}
+	if err := (ingestion.ParseOptions{Timezone: defaults.Timezone, BreakdownLimit: defaults.BreakdownLimit}).Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadDefaults, err)
+	}
+
+	if err := s.orgs.SetUploadDefaults(ctx, orgID, defaults); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to set upload defaults: %w", err)
+	}
+
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Page sizes for keyset-paginated results
+const (
+	DefaultPageSize = 1000
+	MaxPageSize     = 10000
+)
+
+// ErrInvalidCursor is returned for a pagination cursor that wasn't issued by a previous page
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// encodeCursor encodes the sort key of the last item of a page as an opaque cursor
+func encodeCursor(key interface{}) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor into the sort key it was encoded from. An empty cursor is the first page.
+func decodeCursor(cursor string, key interface{}) (bool, error) {
+	if cursor == "" {
+		return false, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, key); err != nil {
+		return false, ErrInvalidCursor
+	}
+
+	return true, nil
+}
+
+// pageSize clamps a requested page size, using the default when none was requested
+func pageSize(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultPageSize
+	case limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return limit
+	}
+}
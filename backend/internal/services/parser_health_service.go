@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Parser regression thresholds, comparing the last day with the baseline before it
+const (
+	parserRecentWindow = 24 * time.Hour
+	// minBaselineFiles is how many files the baseline needs before it is compared against
+	minBaselineFiles = 3
+	// Failure and row error rates are flagged when they rise by this many percentage points
+	parserFailureRateRise  = 10
+	parserRowErrorRateRise = 5
+	// Throughput is flagged when it falls below this share of the baseline
+	parserThroughputFloor = 0.5
+)
+
+// ParserHealth compares a parser's last day of runs with its baseline
+type ParserHealth struct {
+	Parser   string                 `json:"parser"`
+	Source   string                 `json:"source"`
+	Recent   *ingestion.ParserStats `json:"recent,omitempty"`
+	Baseline *ingestion.ParserStats `json:"baseline,omitempty"`
+	// Regressions describe how the recent runs are worse than the baseline
+	Regressions []string `json:"regressions"`
+}
+
+// ParserHealthService tracks parsers' failure rates, row errors and throughput, so a parser
+// regression after a DSP changes its log format is noticed quickly
+type ParserHealthService struct {
+	runs repository.ParserRunRepository
+}
+
+// NewParserHealthService creates a new parser health service
+func NewParserHealthService(repos repository.Repositories) *ParserHealthService {
+	return &ParserHealthService{
+		runs: repos.ParserRuns,
+	}
+}
+
+// GetParserHealth compares each parser's runs over the last day with its runs over the
+// baseline days before
+func (s *ParserHealthService) GetParserHealth(ctx context.Context, baselineDays int) ([]*ParserHealth, error) {
+	now := time.Now()
+	recentStart := now.Add(-parserRecentWindow)
+	baselineStart := recentStart.AddDate(0, 0, -baselineDays)
+
+	recent, err := s.runs.ListStats(ctx, recentStart, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent parser stats: %w", err)
+	}
+	baseline, err := s.runs.ListStats(ctx, baselineStart, recentStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list baseline parser stats: %w", err)
+	}
+
+	type key struct{ parser, source string }
+	health := []*ParserHealth{}
+	index := make(map[key]*ParserHealth)
+	entry := func(stats *ingestion.ParserStats) *ParserHealth {
+		stats.CalculateRates()
+		k := key{stats.Parser, stats.Source}
+		if h, ok := index[k]; ok {
+			return h
+		}
+		h := &ParserHealth{Parser: stats.Parser, Source: stats.Source, Regressions: []string{}}
+		index[k] = h
+		health = append(health, h)
+		return h
+	}
+	for _, stats := range recent {
+		entry(stats).Recent = stats
+	}
+	for _, stats := range baseline {
+		entry(stats).Baseline = stats
+	}
+
+	for _, h := range health {
+		h.Regressions = parserRegressions(h.Recent, h.Baseline)
+	}
+	return health, nil
+}
+
+// parserRegressions describes how recent runs are worse than the baseline
+func parserRegressions(recent, baseline *ingestion.ParserStats) []string {
+	regressions := []string{}
+	if recent == nil || baseline == nil || baseline.Files < minBaselineFiles {
+		return regressions
+	}
+
+	if rise := recent.FailureRate - baseline.FailureRate; rise >= parserFailureRateRise {
+		regressions = append(regressions, fmt.Sprintf("failure rate rose from %.1f%% to %.1f%%", baseline.FailureRate, recent.FailureRate))
+	}
+	if rise := recent.RowErrorRate - baseline.RowErrorRate; rise >= parserRowErrorRateRise {
+		regressions = append(regressions, fmt.Sprintf("row error rate rose from %.1f%% to %.1f%%", baseline.RowErrorRate, recent.RowErrorRate))
+	}
+	if recent.Rows > 0 && baseline.RowsPerSecond > 0 && recent.RowsPerSecond < baseline.RowsPerSecond*parserThroughputFloor {
+		regressions = append(regressions, fmt.Sprintf("throughput fell from %.0f to %.0f rows per second", baseline.RowsPerSecond, recent.RowsPerSecond))
+	}
+	return regressions
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// partitionLookaheadMonths is how many months of log record partitions are created ahead of time
+const partitionLookaheadMonths = 2
+
+// PartitionMaintenance keeps the monthly log record partitions ahead of incoming data and
+// drops partitions that have passed the retention period
+type PartitionMaintenance struct {
+	records         repository.LogRecordRepository
+	retentionMonths int
+}
+
+// NewPartitionMaintenance creates a new partition maintenance task
+func NewPartitionMaintenance(records repository.LogRecordRepository, retentionMonths int) *PartitionMaintenance {
+	return &PartitionMaintenance{
+		records:         records,
+		retentionMonths: retentionMonths,
+	}
+}
+
+// Run performs maintenance immediately and then at every interval until the context is canceled
+func (m *PartitionMaintenance) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.RunOnce(ctx, time.Now()); err != nil {
+			slog.Error("Failed to maintain log record partitions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates upcoming partitions and drops expired ones relative to now
+func (m *PartitionMaintenance) RunOnce(ctx context.Context, now time.Time) error {
+	if err := m.records.EnsurePartitions(ctx, now, now.AddDate(0, partitionLookaheadMonths, 0)); err != nil {
+		return err
+	}
+
+	if m.retentionMonths <= 0 {
+		return nil
+	}
+
+	now = now.UTC()
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -m.retentionMonths, 0)
+	dropped, err := m.records.DropPartitionsBefore(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		slog.Info("Dropped expired log record partitions", "partitions", dropped)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// ErrInvalidPreferences is returned when preferences fail validation
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+var (
+	// currencyPattern matches ISO 4217 currency codes
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	// dashboardPattern matches dashboard identifiers such as overview or brand-safety
+	dashboardPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+)
+
+// ReportDefaults are how a user's reports label spend and show dates
+type ReportDefaults struct {
+	Currency   string
+	Location   *time.Location
+	DateLayout string
+}
+
+// FormatDate formats a date in the user's time zone and date format
+func (d *ReportDefaults) FormatDate(t time.Time) string {
+	return t.In(d.Location).Format(d.DateLayout)
+}
+
+// FormatTimestamp formats a time in the user's time zone and date format, to the second
+func (d *ReportDefaults) FormatTimestamp(t time.Time) string {
+	return t.In(d.Location).Format(d.DateLayout + " 15:04:05 MST")
+}
+
+// PreferencesService manages users' report and dashboard defaults
+type PreferencesService struct {
+	preferences repository.PreferencesRepository
+	users       repository.UserRepository
+}
+
+// NewPreferencesService creates a new preferences service
+func NewPreferencesService(preferences repository.PreferencesRepository, users repository.UserRepository) *PreferencesService {
+	return &PreferencesService{
+		preferences: preferences,
+		users:       users,
+	}
+}
+
+// GetPreferences returns a user's preferences, or the defaults when the user hasn't saved any
+func (s *PreferencesService) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	preferences, err := s.preferences.Get(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultPreferences(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+// UpdatePreferences validates and saves a user's preferences
+func (s *PreferencesService) UpdatePreferences(ctx context.Context, preferences *models.Preferences) error {
+	if !currencyPattern.MatchString(preferences.Currency) {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code such as USD", ErrInvalidPreferences)
+	}
+	if preferences.Timezone != "" {
+		if _, err := time.LoadLocation(preferences.Timezone); err != nil || preferences.Timezone == "Local" {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreferences, preferences.Timezone)
+		}
+	}
+	if _, ok := models.DateFormats[preferences.DateFormat]; !ok {
+		return fmt.Errorf("%w: date format must be one of YYYY-MM-DD, MM/DD/YYYY, DD/MM/YYYY or DD.MM.YYYY", ErrInvalidPreferences)
+	}
+	if !dashboardPattern.MatchString(preferences.DefaultDashboard) {
+		return fmt.Errorf("%w: default dashboard must be a lowercase identifier such as overview", ErrInvalidPreferences)
+	}
+
+	preferences.UpdatedAt = time.Now()
+
+	if err := s.preferences.Upsert(ctx, preferences); err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+
+	return nil
+}
+
+// ReportDefaults resolves how a user's reports should label spend and show dates. Without a
+// preferred time zone, reports use the profile's, and without either, UTC.
+func (s *PreferencesService) ReportDefaults(ctx context.Context, userID string) (*ReportDefaults, error) {
+	preferences, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	timezone := preferences.Timezone
+	if timezone == "" {
+		user, err := s.users.FindByID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user: %w", err)
+		}
+		timezone = user.Timezone
+	}
+
+	// Zones were validated when saved, but the zone database may have changed since
+	location := time.UTC
+	if timezone != "" {
+		if loaded, err := time.LoadLocation(timezone); err == nil {
+			location = loaded
+		}
+	}
+
+	layout, ok := models.DateFormats[preferences.DateFormat]
+	if !ok {
+		layout = models.DateFormats["YYYY-MM-DD"]
+	}
+
+	return &ReportDefaults{
+		Currency:   preferences.Currency,
+		Location:   location,
+		DateLayout: layout,
+	}, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// realtimeFlushInterval is how often live metrics are added to the database, which bounds how far
+// behind other servers' view of this server's streams is
+const realtimeFlushInterval = 5 * time.Second
+
+// realtimePruneInterval is how often minutes past the retention are deleted
+const realtimePruneInterval = 10 * time.Minute
+
+// realtimeMaxSkew is how far ahead of the server's clock an event's bid time may be and still count
+const realtimeMaxSkew = time.Minute
+
+// realtimeBucket identifies a minute of a campaign's delivery
+type realtimeBucket struct {
+	campaignID string
+	minute     time.Time
+}
+
+// RealtimeService keeps sliding-window metrics of streamed events for monitoring campaigns live.
+// Consumers record events into per-minute buckets in memory, which are added to the database every
+// few seconds so every server's share of a stream's partitions is summed. Events redelivered
+// after a consumer fails are counted again, so live metrics can run ahead of the rollups.
+type RealtimeService struct {
+	realtime repository.RealtimeRepository
+
+	mu      sync.Mutex
+	pending map[string]map[realtimeBucket]*ingestion.CampaignMetrics // user ID → minutes not yet flushed
+
+	// flushMu is held for writing while pending minutes move to the database, so a snapshot never
+	// sees them in both or neither
+	flushMu sync.RWMutex
+}
+
+// NewRealtimeService creates a realtime metrics service
+func NewRealtimeService(repos repository.Repositories) *RealtimeService {
+	return &RealtimeService{
+		realtime: repos.Realtime,
+		pending:  make(map[string]map[realtimeBucket]*ingestion.CampaignMetrics),
+	}
+}
+
+// Record adds a streamed event to its minute. Events older than the longest window, or
+// implausibly far in the future, are left to the rollups.
+func (s *RealtimeService) Record(userID string, record *ingestion.BeeswaxLogRecord) {
+	now := time.Now().UTC()
+	bidTime := record.BidTime.UTC()
+	if bidTime.Before(now.Truncate(time.Minute).Add(-ingestion.RealtimeRetention)) || bidTime.After(now.Add(realtimeMaxSkew)) {
+		return
+	}
+
+	key := realtimeBucket{campaignID: record.CampaignID, minute: bidTime.Truncate(time.Minute)}
+	metrics := ingestion.RecordMetrics(record)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, ok := s.pending[userID]
+	if !ok {
+		buckets = make(map[realtimeBucket]*ingestion.CampaignMetrics)
+		s.pending[userID] = buckets
+	}
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = &ingestion.CampaignMetrics{}
+		buckets[key] = bucket
+	}
+	bucket.Add(metrics)
+}
+
+// Snapshot returns a user's live metrics over each window, with campaigns limited to the top
+// limit by spend when it is positive
+func (s *RealtimeService) Snapshot(ctx context.Context, userID string, limit int) (*ingestion.RealtimeSnapshot, error) {
+	s.flushMu.RLock()
+	defer s.flushMu.RUnlock()
+
+	now := time.Now().UTC()
+	minutes, err := s.realtime.ListMinutes(ctx, userID, now.Truncate(time.Minute).Add(-ingestion.RealtimeRetention))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realtime metrics: %w", err)
+	}
+
+	// This server's events since its last flush aren't in the database yet
+	s.mu.Lock()
+	for key, metrics := range s.pending[userID] {
+		minutes = append(minutes, ingestion.RealtimeMinute{CampaignID: key.campaignID, Minute: key.minute, CampaignMetrics: *metrics})
+	}
+	s.mu.Unlock()
+
+	return ingestion.NewRealtimeSnapshot(minutes, now, limit), nil
+}
+
+// Run flushes recorded minutes and prunes expired ones until the context is canceled. Minutes
+// recorded after the last flush are left for the caller to Flush once consumers have stopped.
+func (s *RealtimeService) Run(ctx context.Context) {
+	flushTicker := time.NewTicker(realtimeFlushInterval)
+	defer flushTicker.Stop()
+	pruneTicker := time.NewTicker(realtimePruneInterval)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flushTicker.C:
+			if err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to flush realtime metrics", "error", err)
+			}
+		case <-pruneTicker.C:
+			cutoff := time.Now().UTC().Truncate(time.Minute).Add(-ingestion.RealtimeRetention)
+			if _, err := s.realtime.DeleteBefore(ctx, cutoff); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to prune realtime metrics", "error", err)
+			}
+		}
+	}
+}
+
+// Flush adds the recorded minutes to the database. Minutes that fail to be written are kept for
+// the next flush.
+func (s *RealtimeService) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]map[realtimeBucket]*ingestion.CampaignMetrics)
+	s.mu.Unlock()
+
+	var firstErr error
+	for userID, buckets := range pending {
+		minutes := make([]ingestion.RealtimeMinute, 0, len(buckets))
+		for key, metrics := range buckets {
+			minutes = append(minutes, ingestion.RealtimeMinute{CampaignID: key.campaignID, Minute: key.minute, CampaignMetrics: *metrics})
+		}
+		if err := s.realtime.AddMinutes(ctx, userID, minutes); err != nil {
+			s.restore(userID, buckets)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// restore merges minutes that couldn't be flushed back into those recorded since
+func (s *RealtimeService) restore(userID string, buckets map[realtimeBucket]*ingestion.CampaignMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.pending[userID]
+	if !ok {
+		s.pending[userID] = buckets
+		return
+	}
+	for key, metrics := range buckets {
+		if bucket, ok := current[key]; ok {
+			bucket.Add(*metrics)
+		} else {
+			current[key] = metrics
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/reportgen"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Report template errors
+var (
+	ErrInvalidReportTemplate  = errors.New("invalid report template")
+	ErrReportTemplateExists   = errors.New("a report template with this name already exists")
+	ErrReportTemplateNotFound = errors.New("report template not found")
+)
+
+// Report template limits
+const (
+	maxReportSections     = 20
+	maxReportMetrics      = 30
+	defaultBreakdownLimit = 10
+	maxBreakdownLimit     = 100
+)
+
+// reportMetricLabels are the column labels of the base metrics report sections can show;
+// custom metrics are labeled with their names
+var reportMetricLabels = map[string]string{
+	ingestion.MetricBids:                  "Bids",
+	ingestion.MetricImpressions:           "Impressions",
+	ingestion.MetricClicks:                "Clicks",
+	ingestion.MetricConversions:           "Conversions",
+	ingestion.MetricSpend:                 "Spend",
+	ingestion.MetricRevenue:               "Revenue",
+	ingestion.MetricMeasurableImpressions: "Measurable impressions",
+	ingestion.MetricViewableImpressions:   "Viewable impressions",
+	"ctr":                                 "CTR (%)",
+	"roas":                                "ROAS",
+	"cpa":                                 "CPA",
+}
+
+// defaultReportMetrics are shown by sections that don't list metrics
+var defaultReportMetrics = []string{ingestion.MetricImpressions, ingestion.MetricClicks, "ctr", ingestion.MetricSpend, "cpa"}
+
+// ReportTemplateService manages the templates organizations lay out their reports with and
+// generates reports from them
+type ReportTemplateService struct {
+	templates    repository.ReportTemplateRepository
+	rollups      *RollupService
+	metrics      *CustomMetricService
+	logProcessor *ingestion.LogProcessorService
+}
+
+// NewReportTemplateService creates a new report template service, reading delivery from rollups
+// and the covered files' narratives from their analysis results
+func NewReportTemplateService(repos repository.Repositories, rollups *RollupService, metrics *CustomMetricService, logProcessor *ingestion.LogProcessorService) *ReportTemplateService {
+	return &ReportTemplateService{
+		templates:    repos.Templates,
+		rollups:      rollups,
+		metrics:      metrics,
+		logProcessor: logProcessor,
+	}
+}
+
+// ListTemplates lists an organization's report templates, ordered by name
+func (s *ReportTemplateService) ListTemplates(ctx context.Context, orgID string) ([]*models.ReportTemplate, error) {
+	templates, err := s.templates.ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report templates: %w", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns one of an organization's report templates
+func (s *ReportTemplateService) GetTemplate(ctx context.Context, id, orgID string) (*models.ReportTemplate, error) {
+	template, err := s.templates.FindByID(ctx, id, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReportTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report template: %w", err)
+	}
+	return template, nil
+}
+
+// CreateTemplate validates and saves a new report template for an organization
+func (s *ReportTemplateService) CreateTemplate(ctx context.Context, template *models.ReportTemplate) error {
+	if err := s.validate(ctx, template); err != nil {
+		return err
+	}
+
+	template.ID = uuid.New().String()
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+	if err := s.templates.Create(ctx, template); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrReportTemplateExists
+		}
+		return fmt.Errorf("failed to create report template: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTemplate validates and saves a report template's new name, description and sections
+func (s *ReportTemplateService) UpdateTemplate(ctx context.Context, template *models.ReportTemplate) error {
+	if err := s.validate(ctx, template); err != nil {
+		return err
+	}
+
+	template.UpdatedAt = time.Now()
+	err := s.templates.Update(ctx, template)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrReportTemplateNotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		return ErrReportTemplateExists
+	case err != nil:
+		return fmt.Errorf("failed to update report template: %w", err)
+	}
+
+	// Return the template as stored, with its creation details
+	updated, err := s.GetTemplate(ctx, template.ID, template.OrgID)
+	if err != nil {
+		return err
+	}
+	*template = *updated
+	return nil
+}
+
+// DeleteTemplate removes one of an organization's report templates
+func (s *ReportTemplateService) DeleteTemplate(ctx context.Context, id, orgID string) error {
+	err := s.templates.Delete(ctx, id, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrReportTemplateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete report template: %w", err)
+	}
+	return nil
+}
+
+// validate normalizes a template and checks its sections against the rollup dimensions and the
+// base and custom metrics
+func (s *ReportTemplateService) validate(ctx context.Context, template *models.ReportTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	template.Description = strings.TrimSpace(template.Description)
+	if template.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReportTemplate)
+	}
+	if len(template.Sections) == 0 || len(template.Sections) > maxReportSections {
+		return fmt.Errorf("%w: a template has between 1 and %d sections", ErrInvalidReportTemplate, maxReportSections)
+	}
+
+	custom, err := s.metrics.ListMetrics(ctx, template.OrgID)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(reportMetricLabels)+len(custom))
+	for name := range reportMetricLabels {
+		known[name] = true
+	}
+	for _, metric := range custom {
+		known[metric.Name] = true
+	}
+
+	for i := range template.Sections {
+		section := &template.Sections[i]
+		section.Type = strings.ToLower(strings.TrimSpace(section.Type))
+		section.Title = strings.TrimSpace(section.Title)
+		section.Dimension = strings.ToLower(strings.TrimSpace(section.Dimension))
+
+		switch section.Type {
+		case models.ReportSectionSummary, models.ReportSectionTrend:
+			section.Dimension = ""
+			section.Limit = 0
+		case models.ReportSectionBreakdown:
+			if !ingestion.IsRollupDimension(section.Dimension) || section.Dimension == ingestion.RollupTotal {
+				return fmt.Errorf("%w: section %d: breakdown dimension must be campaign, creative, domain, geo or device", ErrInvalidReportTemplate, i+1)
+			}
+			if section.Limit == 0 {
+				section.Limit = defaultBreakdownLimit
+			}
+			if section.Limit < 0 || section.Limit > maxBreakdownLimit {
+				return fmt.Errorf("%w: section %d: limit must be between 1 and %d", ErrInvalidReportTemplate, i+1, maxBreakdownLimit)
+			}
+		default:
+			return fmt.Errorf("%w: section %d: type must be summary, breakdown or trend", ErrInvalidReportTemplate, i+1)
+		}
+
+		if len(section.Metrics) == 0 {
+			section.Metrics = append([]string(nil), defaultReportMetrics...)
+		}
+		if len(section.Metrics) > maxReportMetrics {
+			return fmt.Errorf("%w: section %d: at most %d metrics", ErrInvalidReportTemplate, i+1, maxReportMetrics)
+		}
+		for j, metric := range section.Metrics {
+			metric = strings.ToLower(strings.TrimSpace(metric))
+			if !known[metric] {
+				return fmt.Errorf("%w: section %d: unknown metric %q", ErrInvalidReportTemplate, i+1, metric)
+			}
+			section.Metrics[j] = metric
+		}
+	}
+
+	return nil
+}
+
+// GenerateReport lays out the user's delivery between from and to by one of their
+// organization's templates. Without dates it covers the last 30 days.
+func (s *ReportTemplateService) GenerateReport(ctx context.Context, userID, orgID, templateID string, from, to *time.Time) (*reportgen.Report, error) {
+	template, err := s.GetTemplate(ctx, templateID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := s.metrics.Compile(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := reportRange(from, to)
+	report := &reportgen.Report{
+		Title:       template.Name,
+		Period:      fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
+		GeneratedAt: time.Now(),
+		Sections:    []reportgen.Section{},
+	}
+
+	// Sections of the same dimension share one read of its rollups
+	rollups := make(map[string][]ingestion.Rollup)
+	pending := 0
+	read := func(dimension string) ([]ingestion.Rollup, error) {
+		if daily, ok := rollups[dimension]; ok {
+			return daily, nil
+		}
+		daily := []ingestion.Rollup{}
+		files, err := s.rollups.ScanDailyRollups(ctx, userID, dimension, &start, &end, func(rollup ingestion.Rollup) error {
+			daily = append(daily, rollup)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		pending = max(pending, files)
+		rollups[dimension] = daily
+		return daily, nil
+	}
+
+	for _, section := range template.Sections {
+		dimension := section.Dimension
+		if dimension == "" {
+			dimension = ingestion.RollupTotal
+		}
+		daily, err := read(dimension)
+		if err != nil {
+			return nil, err
+		}
+
+		table := reportgen.Section{Title: section.Title, Rows: [][]any{}}
+		var label string
+		var entries []BreakdownEntry
+		switch section.Type {
+		case models.ReportSectionSummary:
+			var totals ingestion.CampaignMetrics
+			for _, rollup := range daily {
+				totals.Add(rollup.CampaignMetrics)
+			}
+			custom.Apply(&totals)
+
+			// Totals read down the page, a metric per row
+			table.Columns = []string{"Metric", "Value"}
+			for _, metric := range section.Metrics {
+				table.Rows = append(table.Rows, []any{reportMetricLabel(metric), reportMetricValue(totals, metric)})
+			}
+			if table.Title == "" {
+				table.Title = "Summary"
+			}
+			report.Sections = append(report.Sections, table)
+			continue
+		case models.ReportSectionTrend:
+			label = "Date"
+			for _, rollup := range daily {
+				entry := BreakdownEntry{Value: rollup.Bucket.UTC().Format("2006-01-02")}
+				entry.Add(rollup.CampaignMetrics)
+				entries = append(entries, entry)
+			}
+			if table.Title == "" {
+				table.Title = "Daily trend"
+			}
+		case models.ReportSectionBreakdown:
+			label = strings.ToUpper(dimension[:1]) + dimension[1:]
+			entries = sumByValue(daily)
+			if len(entries) > section.Limit {
+				entries = entries[:section.Limit]
+			}
+			if table.Title == "" {
+				table.Title = "Top " + dimension + "s by spend"
+			}
+		}
+
+		table.Columns = []string{label}
+		for _, metric := range section.Metrics {
+			table.Columns = append(table.Columns, reportMetricLabel(metric))
+		}
+		for _, entry := range entries {
+			custom.Apply(&entry.CampaignMetrics)
+			row := []any{entry.Value}
+			for _, metric := range section.Metrics {
+				row = append(row, reportMetricValue(entry.CampaignMetrics, metric))
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		report.Sections = append(report.Sections, table)
+	}
+
+	if pending > 0 {
+		report.Notes = append(report.Notes, fmt.Sprintf("%d processed files have no rollups and are left out until they are reprocessed", pending))
+	}
+
+	report.Insights = s.insights(ctx, userID, rollups)
+
+	return report, nil
+}
+
+// insights collects the narratives of the files behind a report's rollups, in file name order.
+// Like the narratives themselves they are optional context, so a file whose analysis can't be
+// read is left out rather than failing the report.
+func (s *ReportTemplateService) insights(ctx context.Context, userID string, rollups map[string][]ingestion.Rollup) []reportgen.Insight {
+	fileIDs := make(map[string]bool)
+	for _, daily := range rollups {
+		for _, rollup := range daily {
+			for _, fileID := range rollup.FileIDs {
+				fileIDs[fileID] = true
+			}
+		}
+	}
+
+	insights := []reportgen.Insight{}
+	for fileID := range fileIDs {
+		result, err := s.logProcessor.GetAnalysisResult(ctx, fileID, userID)
+		if err != nil {
+			errreport.Report(errreport.WithTags(ctx, "fileID", fileID), "Failed to read narrative for report", err)
+			continue
+		}
+		if result.Narrative != "" {
+			insights = append(insights, reportgen.Insight{FileName: result.FileName, Text: result.Narrative})
+		}
+	}
+	sort.Slice(insights, func(i, j int) bool {
+		if insights[i].FileName != insights[j].FileName {
+			return insights[i].FileName < insights[j].FileName
+		}
+		return insights[i].Text < insights[j].Text
+	})
+	return insights
+}
+
+// sumByValue sums daily rollups over the period by value, highest spend first
+func sumByValue(daily []ingestion.Rollup) []BreakdownEntry {
+	index := make(map[string]int)
+	var entries []BreakdownEntry
+	for _, rollup := range daily {
+		i, ok := index[rollup.Value]
+		if !ok {
+			i = len(entries)
+			index[rollup.Value] = i
+			entries = append(entries, BreakdownEntry{Value: rollup.Value})
+		}
+		entries[i].Add(rollup.CampaignMetrics)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Spend != entries[j].Spend {
+			return entries[i].Spend > entries[j].Spend
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	return entries
+}
+
+// reportMetricLabel returns a metric's column label
+func reportMetricLabel(metric string) string {
+	if label, ok := reportMetricLabels[metric]; ok {
+		return label
+	}
+	return metric
+}
+
+// reportMetricValue returns a metric's value, or nil for a custom metric that is undefined for
+// the metrics or has since been deleted
+func reportMetricValue(metrics ingestion.CampaignMetrics, metric string) any {
+	switch metric {
+	case ingestion.MetricBids:
+		return metrics.Bids
+	case ingestion.MetricImpressions:
+		return metrics.Impressions
+	case ingestion.MetricClicks:
+		return metrics.Clicks
+	case ingestion.MetricConversions:
+		return metrics.Conversions
+	case ingestion.MetricSpend:
+		return metrics.Spend
+	case ingestion.MetricRevenue:
+		return metrics.Revenue
+	case ingestion.MetricMeasurableImpressions:
+		return metrics.MeasurableImpressions
+	case ingestion.MetricViewableImpressions:
+		return metrics.ViewableImpressions
+	case "ctr":
+		return metrics.CTR
+	case "roas":
+		return metrics.ROAS
+	case "cpa":
+		return metrics.CPA
+	}
+	if value, ok := metrics.Custom[metric]; ok {
+		return value
+	}
+	return nil
+}
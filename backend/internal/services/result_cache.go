@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/cache"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// ResultCache caches analysis summaries and analytics results for dashboards that poll the
+// same aggregations. Keys are scoped so processing or deleting a file invalidates exactly the
+// entries that depend on it.
+type ResultCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+	users repository.UserRepository
+}
+
+// NewResultCache creates a result cache backed by the given cache
+func NewResultCache(c cache.Cache, ttl time.Duration) *ResultCache {
+	if c == nil {
+		c = cache.NoopCache{}
+	}
+
+	return &ResultCache{cache: c, ttl: ttl}
+}
+
+// SetUsers finds the org a file's owner belongs to, so changing the file also invalidates the
+// org's cross-file results
+func (c *ResultCache) SetUsers(users repository.UserRepository) {
+	c.users = users
+}
+
+// fileKey builds a key for a result derived from a single file
+func fileKey(userID, fileID string, parts ...string) string {
+	return fmt.Sprintf("file:%s:%s:%s", userID, fileID, strings.Join(parts, ":"))
+}
+
+// userKey builds a key for a result derived from all of a user's files
+func userKey(userID string, parts ...string) string {
+	return fmt.Sprintf("user:%s:%s", userID, strings.Join(parts, ":"))
+}
+
+// orgKey builds a key for a result derived from the files of all of an org's users
+func orgKey(orgID string, parts ...string) string {
+	return fmt.Sprintf("org:%s:%s", orgID, strings.Join(parts, ":"))
+}
+
+// InvalidateFile removes cached results for a file and every cross-file result of its owner and
+// the owner's org
+func (c *ResultCache) InvalidateFile(ctx context.Context, userID, fileID string) {
+	prefixes := []string{
+		fmt.Sprintf("file:%s:%s:", userID, fileID),
+		fmt.Sprintf("user:%s:", userID),
+	}
+	if c.users != nil {
+		owner, err := c.users.FindByID(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to find file owner to invalidate cache", "userID", userID, "error", err)
+		} else {
+			prefixes = append(prefixes, fmt.Sprintf("org:%s:", owner.OrgID))
+		}
+	}
+
+	for _, prefix := range prefixes {
+		if err := c.cache.DeletePrefix(ctx, prefix); err != nil {
+			slog.Error("Failed to invalidate cache", "prefix", prefix, "error", err)
+		}
+	}
+}
+
+// cached returns the value cached at key, loading and caching it on a miss.
+// Cache errors are logged and fall through to the loader so an unavailable cache never fails a request.
+func cached[T any](ctx context.Context, c *ResultCache, key string, load func() (T, error)) (T, error) {
+	var value T
+	found, err := c.cache.Get(ctx, key, &value)
+	if err != nil {
+		slog.Error("Failed to read cache", "key", key, "error", err)
+	}
+	if found {
+		return value, nil
+	}
+
+	value, err = load()
+	if err != nil {
+		return value, err
+	}
+
+	if err := c.cache.Set(ctx, key, value, c.ttl); err != nil {
+		slog.Error("Failed to write cache", "key", key, "error", err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+// Resumable upload errors
+var (
+	ErrInvalidUploadSize = errors.New("upload size must be greater than zero")
+	ErrUploadIncomplete  = errors.New("upload has not received all of its bytes")
+)
+
+// StartUpload begins a resumable upload of a file of the given size, which is then sent in
+// chunks that can be retried or resumed after a dropped connection
+func (s *FileService) StartUpload(ctx context.Context, fileName, contentType, userID string, size int64) (*storage.PartialUpload, error) {
+	if err := s.validateFileType(contentType); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, ErrInvalidUploadSize
+	}
+	if size > s.maxUpload.Load() {
+		return nil, storage.ErrFileTooLarge
+	}
+
+	upload, err := s.fileStorage.CreatePartialUpload(fileName, contentType, userID, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetUpload returns a resumable upload with the bytes received so far
+func (s *FileService) GetUpload(ctx context.Context, uploadID, userID string) (*storage.PartialUpload, error) {
+	return s.fileStorage.GetPartialUpload(uploadID, userID)
+}
+
+// UploadChunk appends a chunk to a resumable upload at the given offset
+func (s *FileService) UploadChunk(ctx context.Context, uploadID, userID string, offset int64, chunk io.Reader) (*storage.PartialUpload, error) {
+	return s.fileStorage.AppendPartialUpload(uploadID, userID, offset, chunk)
+}
+
+// CompleteUpload turns a fully received resumable upload into a file and queues its processing,
+// exactly as if it had been uploaded in one request
+func (s *FileService) CompleteUpload(ctx context.Context, uploadID, userID, idempotencyKey, priority string) (*FileUploadInfo, error) {
+	// A retried completion finds the file it created, since the upload itself is gone
+	if idempotencyKey != "" {
+		if info, err := s.findIdempotentUpload(ctx, userID, idempotencyKey); err == nil {
+			return info, nil
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	upload, err := s.fileStorage.GetPartialUpload(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Received != upload.Size {
+		return nil, fmt.Errorf("%w: received %d of %d bytes", ErrUploadIncomplete, upload.Received, upload.Size)
+	}
+
+	data, err := s.fileStorage.OpenPartialUpload(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	info, err := s.UploadFile(ctx, data, upload.FileName, upload.FileType, userID, idempotencyKey, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.fileStorage.DeletePartialUpload(uploadID, userID); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// AbortUpload discards a resumable upload
+func (s *FileService) AbortUpload(ctx context.Context, uploadID, userID string) error {
+	if _, err := s.fileStorage.GetPartialUpload(uploadID, userID); err != nil {
+		return err
+	}
+
+	return s.fileStorage.DeletePartialUpload(uploadID, userID)
+}
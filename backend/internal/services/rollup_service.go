@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Rollup service errors
+var (
+	// ErrInvalidRollupQuery is returned for a rollup query with an unknown dimension or grain
+	ErrInvalidRollupQuery = errors.New("invalid rollup query: dimension must be campaign, creative, domain, geo, device or total and grain hour or day")
+	// ErrInvalidBreakdown is returned for a file breakdown by an unknown dimension
+	ErrInvalidBreakdown = errors.New("invalid breakdown: dimension must be campaign, creative, domain, geo, device or hour")
+	// ErrBreakdownUnavailable is returned for the full breakdown of a file that has no rollups
+	ErrBreakdownUnavailable = errors.New("file has no rollups to break down until it is reprocessed")
+)
+
+// BreakdownByHour breaks a file down by hour, from its hourly total rollups
+const BreakdownByHour = "hour"
+
+// RollupSeries describes a page of a user's rollups across their processed files
+type RollupSeries struct {
+	Dimension string     `json:"dimension"`
+	Grain     string     `json:"grain"`
+	Value     string     `json:"value,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	// PendingFiles counts processed files without rollups, whose delivery the series leaves out
+	PendingFiles int `json:"pendingFiles"`
+	// Annotations are the events recorded against the campaign within the series' dates, when
+	// it's a campaign's series
+	Annotations []ingestion.CampaignAnnotation `json:"annotations,omitempty"`
+}
+
+// rollupCursor is the sort key of the last rollup of a page
+type rollupCursor struct {
+	Bucket time.Time `json:"b"`
+	Value  string    `json:"v"`
+}
+
+// FileBreakdown describes a page of a file's full breakdown by a dimension
+type FileBreakdown struct {
+	FileID    string `json:"fileId"`
+	Dimension string `json:"dimension"`
+}
+
+// BreakdownEntry is one value of a file's full breakdown with its delivery; hours are formatted
+// like the keys of the summary's hourly breakdown
+type BreakdownEntry struct {
+	Value string `json:"value"`
+	ingestion.CampaignMetrics
+}
+
+// breakdownCursor is the sort key of the last entry of a breakdown page: spend and value, or the hour
+type breakdownCursor struct {
+	Spend float64    `json:"s"`
+	Value string     `json:"v"`
+	Hour  *time.Time `json:"h,omitempty"`
+}
+
+// RollupService maintains the hourly and daily rollups of processed files and reads them back,
+// so reports spanning many files don't load every file's summary. Files processed before
+// rollups existed have none until they are reprocessed.
+type RollupService struct {
+	rollups repository.RollupRepository
+	uow     repository.UnitOfWork
+}
+
+// NewRollupService creates a new rollup service, reading through repos and writing through uow
+func NewRollupService(repos repository.Repositories, uow repository.UnitOfWork) *RollupService {
+	return &RollupService{
+		rollups: repos.Rollups,
+		uow:     uow,
+	}
+}
+
+// ReplaceRollups replaces a file's rollups in a single transaction, so readers never see a
+// file half rolled up
+func (s *RollupService) ReplaceRollups(ctx context.Context, fileID, userID string, rollups []ingestion.Rollup) error {
+	return s.uow.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Rollups.DeleteRollups(ctx, fileID, userID); err != nil {
+			return fmt.Errorf("failed to clear rollups: %w", err)
+		}
+		if err := repos.Rollups.InsertRollups(ctx, fileID, userID, time.Now(), rollups); err != nil {
+			return fmt.Errorf("failed to store rollups: %w", err)
+		}
+		return nil
+	})
+}
+
+// StreamRollups reads a page of up to limit of a user's rollups of a dimension at a grain,
+// optionally for a single value, between the from and to dates (inclusive), starting after the
+// cursor. start is called with the series once the query is known to be valid, then emit with
+// each rollup in bucket and value order. It returns the cursor of the next page, or "" on the
+// last page.
+func (s *RollupService) StreamRollups(ctx context.Context, userID, dimension, grain, value string, from, to *time.Time, cursor string, limit int, start func(*RollupSeries) error, emit func(ingestion.Rollup) error) (string, error) {
+	if !ingestion.IsRollupDimension(dimension) || !ingestion.IsRollupGrain(grain) {
+		return "", ErrInvalidRollupQuery
+	}
+
+	query := ingestion.RollupQuery{
+		UserID:    userID,
+		Dimension: dimension,
+		Grain:     grain,
+		Value:     value,
+		From:      from,
+		To:        dayAfter(to),
+		Limit:     pageSize(limit) + 1,
+	}
+	var after rollupCursor
+	paged, err := decodeCursor(cursor, &after)
+	if err != nil {
+		return "", err
+	}
+	if paged {
+		query.AfterBucket = &after.Bucket
+		query.AfterValue = after.Value
+	}
+
+	pending, err := s.rollups.CountPendingFiles(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to count files without rollups: %w", err)
+	}
+
+	err = start(&RollupSeries{
+		Dimension:    dimension,
+		Grain:        grain,
+		Value:        value,
+		From:         from,
+		To:           to,
+		PendingFiles: pending,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// One rollup past the page is read to tell whether there's a next page
+	var last *ingestion.Rollup
+	read := 0
+	next := ""
+	err = s.rollups.ScanRollups(ctx, query, func(rollup ingestion.Rollup) error {
+		read++
+		if read == query.Limit {
+			next = encodeCursor(rollupCursor{Bucket: last.Bucket, Value: last.Value})
+			return nil
+		}
+		last = &rollup
+		return emit(rollup)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read rollups: %w", err)
+	}
+
+	return next, nil
+}
+
+// StreamFileBreakdown reads a page of up to limit values of a processed file's full breakdown by
+// a rollup dimension, highest spend first, or by hour in time order, starting after the cursor.
+// Summaries keep only the top values of their breakdowns; this pages through all of them.
+// start is called with the breakdown once the file is known to have rollups, then emit with each
+// entry. It returns the cursor of the next page, or "" on the last page. Files rolled up before
+// hourly totals were kept have no hour breakdown until they are reprocessed.
+func (s *RollupService) StreamFileBreakdown(ctx context.Context, fileID, userID, dimension, cursor string, limit int, start func(*FileBreakdown) error, emit func(BreakdownEntry) error) (string, error) {
+	if dimension != BreakdownByHour && (!ingestion.IsRollupDimension(dimension) || dimension == ingestion.RollupTotal) {
+		return "", ErrInvalidBreakdown
+	}
+
+	query := ingestion.BreakdownQuery{
+		FileID:    fileID,
+		UserID:    userID,
+		Dimension: dimension,
+		Limit:     pageSize(limit) + 1,
+	}
+	var after breakdownCursor
+	paged, err := decodeCursor(cursor, &after)
+	if err != nil {
+		return "", err
+	}
+	if paged {
+		if dimension == BreakdownByHour {
+			if after.Hour == nil {
+				return "", ErrInvalidCursor
+			}
+			query.AfterBucket = after.Hour
+		} else {
+			query.AfterSpend = &after.Spend
+			query.AfterValue = after.Value
+		}
+	}
+
+	rolledUp, err := s.rollups.HasRollups(ctx, fileID, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for rollups: %w", err)
+	}
+	if !rolledUp {
+		return "", ErrBreakdownUnavailable
+	}
+
+	if err := start(&FileBreakdown{FileID: fileID, Dimension: dimension}); err != nil {
+		return "", err
+	}
+
+	// One entry past the page is read to tell whether there's a next page
+	var last *ingestion.Rollup
+	read := 0
+	next := ""
+	fn := func(rollup ingestion.Rollup) error {
+		read++
+		if read == query.Limit {
+			if dimension == BreakdownByHour {
+				next = encodeCursor(breakdownCursor{Hour: &last.Bucket})
+			} else {
+				next = encodeCursor(breakdownCursor{Spend: last.Spend, Value: last.Value})
+			}
+			return nil
+		}
+		last = &rollup
+
+		entry := BreakdownEntry{Value: rollup.Value, CampaignMetrics: rollup.CampaignMetrics}
+		if dimension == BreakdownByHour {
+			entry.Value = rollup.Bucket.UTC().Format("2006-01-02 15")
+		}
+		return emit(entry)
+	}
+	if dimension == BreakdownByHour {
+		err = s.rollups.ScanFileHours(ctx, query, fn)
+	} else {
+		err = s.rollups.ScanFileBreakdown(ctx, query, fn)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read breakdown: %w", err)
+	}
+
+	return next, nil
+}
+
+// CampaignRollup builds a campaign rollup from the daily rollups of the given users' files. ok
+// is false when some of their files have no rollups, and the rollup has to be built from
+// summaries instead.
+func (s *RollupService) CampaignRollup(ctx context.Context, userIDs []string, campaignID string, from, to *time.Time) (rollup *ingestion.CampaignRollup, ok bool, err error) {
+	for _, userID := range userIDs {
+		pending, err := s.rollups.CountPendingFiles(ctx, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to count files without rollups: %w", err)
+		}
+		if pending > 0 {
+			return nil, false, nil
+		}
+	}
+
+	// A campaign has a single rollup per user and day, so the whole flight is read at once and
+	// the users' days merged
+	var daily []ingestion.Rollup
+	for _, userID := range userIDs {
+		query := ingestion.RollupQuery{
+			UserID:    userID,
+			Dimension: ingestion.RollupByCampaign,
+			Grain:     ingestion.RollupDaily,
+			Value:     campaignID,
+			From:      from,
+			To:        dayAfter(to),
+			Limit:     math.MaxInt32,
+		}
+		err = s.rollups.ScanRollups(ctx, query, func(rollup ingestion.Rollup) error {
+			daily = append(daily, rollup)
+			return nil
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read rollups: %w", err)
+		}
+	}
+
+	return ingestion.CampaignRollupFromDaily(campaignID, from, to, daily), true, nil
+}
+
+// ScanDailyRollups reads all of a user's daily rollups of a dimension between the from and to
+// dates (inclusive), in day and value order, for reports that aggregate a whole period. It
+// returns the number of processed files without rollups, whose delivery is left out.
+func (s *RollupService) ScanDailyRollups(ctx context.Context, userID, dimension string, from, to *time.Time, fn func(ingestion.Rollup) error) (int, error) {
+	if !ingestion.IsRollupDimension(dimension) {
+		return 0, ErrInvalidRollupQuery
+	}
+
+	pending, err := s.rollups.CountPendingFiles(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count files without rollups: %w", err)
+	}
+
+	query := ingestion.RollupQuery{
+		UserID:    userID,
+		Dimension: dimension,
+		Grain:     ingestion.RollupDaily,
+		From:      from,
+		To:        dayAfter(to),
+		Limit:     math.MaxInt32,
+	}
+	if err := s.rollups.ScanRollups(ctx, query, fn); err != nil {
+		return 0, fmt.Errorf("failed to read rollups: %w", err)
+	}
+
+	return pending, nil
+}
+
+// dayAfter returns the start of the day after an optional date, the exclusive end of a range
+// that includes the date
+func dayAfter(date *time.Time) *time.Time {
+	if date == nil {
+		return nil
+	}
+	next := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, time.UTC)
+	return &next
+}
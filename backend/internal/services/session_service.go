@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval limits how often a session's last seen time is written, so every
+// authenticated request doesn't cost an update
+const sessionTouchInterval = 5 * time.Minute
+
+// Session errors
+var (
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionRevoked       = errors.New("session has been revoked or has expired")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRefreshTokenReplaced = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// SessionService tracks the devices users are signed in on
+type SessionService struct {
+	sessions repository.SessionRepository
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(sessions repository.SessionRepository) *SessionService {
+	return &SessionService{
+		sessions: sessions,
+	}
+}
+
+// CreateSession starts a session for a user signing in from a device, issuing its first
+// refresh token
+func (s *SessionService) CreateSession(ctx context.Context, userID, userAgent, ipAddress string, expiresAt time.Time) (*models.Session, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		RefreshToken:     refreshToken,
+		RefreshTokenHash: hashToken(refreshToken),
+		Device:           describeDevice(userAgent),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		CreatedAt:        now,
+		LastSeenAt:       now,
+		ExpiresAt:        expiresAt,
+	}
+
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ValidateSession checks that a user's session is still active and records that it was used
+func (s *SessionService) ValidateSession(ctx context.Context, id, userID string) error {
+	session, err := s.sessions.FindByID(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+
+	now := time.Now()
+	if !session.Active(now) {
+		return ErrSessionRevoked
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.sessions.Touch(ctx, id, now); err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RefreshSession exchanges a session's refresh token for a new one, returning the session with
+// the new token set. Each refresh token works once: one that was already exchanged may have been
+// stolen, so using it again revokes the session and returns ErrRefreshTokenReplaced.
+func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string) (*models.Session, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	hash := hashToken(refreshToken)
+	session, err := s.sessions.FindByRefreshTokenHash(ctx, hash)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	now := time.Now()
+	if !session.Active(now) {
+		return nil, ErrSessionRevoked
+	}
+	if session.RefreshTokenHash != hash {
+		return nil, s.revokeReplaced(ctx, session, now)
+	}
+
+	newToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.sessions.RotateRefreshToken(ctx, session.ID, hash, hashToken(newToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		// Another refresh exchanged the token first
+		return nil, s.revokeReplaced(ctx, session, now)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	session.PreviousRefreshTokenHash = hash
+	session.RefreshToken = newToken
+	session.RefreshTokenHash = hashToken(newToken)
+	return session, nil
+}
+
+// revokeReplaced revokes a session whose replaced refresh token was used, returning
+// ErrRefreshTokenReplaced
+func (s *SessionService) revokeReplaced(ctx context.Context, session *models.Session, now time.Time) error {
+	if err := s.sessions.Revoke(ctx, session.ID, session.UserID, now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return ErrRefreshTokenReplaced
+}
+
+// ListSessions lists a user's active sessions, most recently used first
+func (s *SessionService) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	sessions, err := s.sessions.ListActive(ctx, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs one of a user's devices out
+func (s *SessionService) RevokeSession(ctx context.Context, id, userID string) error {
+	err := s.sessions.Revoke(ctx, id, userID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// generateRefreshToken generates a random refresh token
+func generateRefreshToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// describeDevice names the browser and platform of a user agent, such as "Chrome on macOS"
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case ua == "":
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
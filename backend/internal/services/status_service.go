@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/health"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Incident errors
+var (
+	ErrInvalidIncident  = errors.New("invalid incident")
+	ErrIncidentNotFound = errors.New("incident not found")
+)
+
+// Statuses shown on the status page, from best to worst
+const (
+	SystemOperational = "operational"
+	SystemDegraded    = "degraded"
+	SystemOutage      = "outage"
+)
+
+const (
+	// statusCacheTTL bounds how often the public status page checks dependencies, so a crowd
+	// refreshing it during an incident doesn't add to the load
+	statusCacheTTL = 15 * time.Second
+	// slowQueueWait is how long the oldest queued file may wait before processing counts as slow
+	slowQueueWait = 15 * time.Minute
+	// recentIncidentWindow is how long resolved incidents stay on the status page
+	recentIncidentWindow = 7 * 24 * time.Hour
+)
+
+// statusRanks orders the statuses, worst highest
+var statusRanks = map[string]int{
+	SystemOperational: 0,
+	SystemDegraded:    1,
+	SystemOutage:      2,
+}
+
+// SystemStatus is the public status page: overall health, the processing backlog and recent
+// incidents. Dependency errors are left out, as they can reveal internals.
+type SystemStatus struct {
+	Status     string             `json:"status"`
+	Components map[string]string  `json:"components"`
+	Backlog    *ProcessingBacklog `json:"backlog,omitempty"`
+	Incidents  []*models.Incident `json:"incidents"`
+	CheckedAt  time.Time          `json:"checkedAt"`
+}
+
+// ProcessingBacklog is the state of the processing queue
+type ProcessingBacklog struct {
+	Queued  int `json:"queued"`
+	Running int `json:"running"`
+	// OldestWaitSeconds is how long the oldest queued file has waited to be processed
+	OldestWaitSeconds int64 `json:"oldestWaitSeconds"`
+}
+
+// StatusService reports system status for the public status page and manages the incidents
+// admins post to it
+type StatusService struct {
+	jobs      repository.JobRepository
+	incidents repository.IncidentRepository
+	checker   *health.Checker
+
+	mu        sync.Mutex
+	cached    *SystemStatus
+	expiresAt time.Time
+}
+
+// NewStatusService creates a new status service, checking dependencies with the health checker
+func NewStatusService(repos repository.Repositories, checker *health.Checker) *StatusService {
+	return &StatusService{
+		jobs:      repos.Jobs,
+		incidents: repos.Incidents,
+		checker:   checker,
+	}
+}
+
+// GetStatus returns the current system status, checked at most every statusCacheTTL
+func (s *StatusService) GetStatus(ctx context.Context) *SystemStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.cached != nil && now.Before(s.expiresAt) {
+		return s.cached
+	}
+
+	status := &SystemStatus{
+		Status:     SystemOperational,
+		Components: map[string]string{"api": SystemOperational},
+		Incidents:  []*models.Incident{},
+		CheckedAt:  now,
+	}
+	report := s.checker.Run(ctx)
+	for name, check := range report.Checks {
+		component := SystemOperational
+		if check.Status != health.StatusOK {
+			component = SystemOutage
+		}
+		status.Components[name] = component
+	}
+
+	// Without the database there is no backlog or incident list to show
+	if status.Components["database"] != SystemOutage {
+		backlog, err := s.backlog(ctx, now)
+		if err == nil {
+			status.Backlog = backlog
+			if backlog.OldestWaitSeconds > int64(slowQueueWait/time.Second) && status.Components["jobQueue"] == SystemOperational {
+				status.Components["jobQueue"] = SystemDegraded
+			}
+		}
+		if incidents, err := s.incidents.ListSince(ctx, now.Add(-recentIncidentWindow)); err == nil {
+			status.Incidents = incidents
+		}
+	}
+
+	for _, component := range status.Components {
+		status.Status = worseStatus(status.Status, component)
+	}
+	for _, incident := range status.Incidents {
+		if incident.ResolvedAt != nil {
+			continue
+		}
+		switch incident.Severity {
+		case models.IncidentMajor:
+			status.Status = worseStatus(status.Status, SystemOutage)
+		case models.IncidentMinor:
+			status.Status = worseStatus(status.Status, SystemDegraded)
+		}
+	}
+
+	s.cached = status
+	s.expiresAt = now.Add(statusCacheTTL)
+	return status
+}
+
+// backlog counts queued and running jobs
+func (s *StatusService) backlog(ctx context.Context, now time.Time) (*ProcessingBacklog, error) {
+	// The backlog is every org's, and the status page is public
+	ctx = db.AsSystem(ctx)
+	queued, err := s.jobs.CountByStatus(ctx, models.JobStatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	running, err := s.jobs.CountByStatus(ctx, models.JobStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	oldest, err := s.jobs.OldestCreatedAt(ctx, models.JobStatusQueued)
+	if err != nil {
+		return nil, err
+	}
+
+	backlog := &ProcessingBacklog{Queued: queued, Running: running}
+	if oldest != nil {
+		backlog.OldestWaitSeconds = int64(now.Sub(*oldest).Seconds())
+	}
+	return backlog, nil
+}
+
+// worseStatus returns the worse of two statuses
+func worseStatus(a, b string) string {
+	if statusRanks[b] > statusRanks[a] {
+		return b
+	}
+	return a
+}
+
+// ListIncidents lists unresolved incidents and those resolved within the status page's window
+func (s *StatusService) ListIncidents(ctx context.Context) ([]*models.Incident, error) {
+	incidents, err := s.incidents.ListSince(ctx, time.Now().Add(-recentIncidentWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// CreateIncident validates and posts an incident to the status page
+func (s *StatusService) CreateIncident(ctx context.Context, incident *models.Incident) error {
+	if err := validateIncident(incident); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	incident.ID = uuid.New().String()
+	incident.StartedAt = now
+	incident.UpdatedAt = now
+	if err := s.incidents.Create(ctx, incident); err != nil {
+		return fmt.Errorf("failed to create incident: %w", err)
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// UpdateIncident validates and saves changes to an incident, such as a progress message or
+// its resolution
+func (s *StatusService) UpdateIncident(ctx context.Context, incident *models.Incident) error {
+	if err := validateIncident(incident); err != nil {
+		return err
+	}
+
+	incident.UpdatedAt = time.Now()
+	err := s.incidents.Update(ctx, incident)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrIncidentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update incident: %w", err)
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// GetIncident returns an incident
+func (s *StatusService) GetIncident(ctx context.Context, id string) (*models.Incident, error) {
+	incident, err := s.incidents.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrIncidentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+	return incident, nil
+}
+
+// invalidate drops the cached status, so incident changes show on the next request
+func (s *StatusService) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached = nil
+}
+
+func validateIncident(incident *models.Incident) error {
+	incident.Title = strings.TrimSpace(incident.Title)
+	if incident.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidIncident)
+	}
+	if !models.ValidIncidentSeverity(incident.Severity) {
+		return fmt.Errorf("%w: severity must be minor, major or maintenance", ErrInvalidIncident)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+// maxMissingShare is the share of file records that can be missing their upload before a sweep
+// stops deleting them, taking it instead for storage that isn't mounted or was restored wrongly
+const maxMissingShare = 0.5
+
+// StorageGC finds stored uploads without a file record, left behind by uploads that failed
+// between storing the file and recording it, and file records whose upload is gone. When
+// reconciling, orphaned uploads are moved to the trash, where they're kept for a while in case
+// they're wanted back, and records without an upload are deleted.
+type StorageGC struct {
+	fileStorage    *storage.FileStorage
+	files          repository.FileRepository
+	resultCache    *ResultCache
+	grace          time.Duration
+	trashRetention time.Duration
+	reconcile      bool
+
+	sweeping sync.Mutex // Serializes sweeps
+	mu       sync.Mutex // Guards last
+	last     *StorageGCReport
+}
+
+// StorageGCReport is what a sweep found, and what it did about it when reconciling
+type StorageGCReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Reconciled bool      `json:"reconciled"`
+	// OrphanedBlobs are stored uploads without a file record
+	OrphanedBlobs []storage.Blob `json:"orphanedBlobs"`
+	OrphanedBytes int64          `json:"orphanedBytes"`
+	// MissingBlobs are file records whose upload isn't stored
+	MissingBlobs []MissingBlob `json:"missingBlobs"`
+	// TrashedBlobs and DeletedRecords count what reconciling fixed
+	TrashedBlobs   int `json:"trashedBlobs"`
+	DeletedRecords int `json:"deletedRecords"`
+	// PurgedBlobs and PurgedBytes are the trashed uploads removed for good, having been in the
+	// trash past its retention
+	PurgedBlobs int   `json:"purgedBlobs"`
+	PurgedBytes int64 `json:"purgedBytes"`
+	// Errors are the fixes that failed, which the next sweep tries again
+	Errors []string `json:"errors,omitempty"`
+}
+
+// MissingBlob is a file record whose upload isn't stored
+type MissingBlob struct {
+	FileID     string    `json:"fileId"`
+	UserID     string    `json:"userId"`
+	FileName   string    `json:"fileName"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// NewStorageGC creates a storage garbage collector. Uploads and records younger than grace are
+// left alone, since an upload in progress is stored before it's recorded. Reconciling sweeps
+// trash orphaned uploads and purge those trashed longer than trashRetention ago.
+func NewStorageGC(fileStorage *storage.FileStorage, files repository.FileRepository, resultCache *ResultCache,
+	grace, trashRetention time.Duration, reconcile bool) *StorageGC {
+	return &StorageGC{
+		fileStorage:    fileStorage,
+		files:          files,
+		resultCache:    resultCache,
+		grace:          grace,
+		trashRetention: trashRetention,
+		reconcile:      reconcile,
+	}
+}
+
+// Run sweeps immediately and then at every interval until the context is canceled,
+// reconciling if the collector was created to
+func (g *StorageGC) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := g.Sweep(ctx, g.reconcile)
+		if err != nil {
+			slog.Error("Failed to sweep storage", "error", err)
+		} else if len(report.OrphanedBlobs) > 0 || len(report.MissingBlobs) > 0 {
+			slog.Warn("Storage is out of step with file records",
+				"orphanedBlobs", len(report.OrphanedBlobs), "orphanedBytes", report.OrphanedBytes,
+				"missingBlobs", len(report.MissingBlobs), "reconciled", report.Reconciled)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep compares stored uploads with file records, fixing what's out of step when reconcile is
+// set and only reporting it otherwise
+func (g *StorageGC) Sweep(ctx context.Context, reconcile bool) (*StorageGCReport, error) {
+	g.sweeping.Lock()
+	defer g.sweeping.Unlock()
+
+	report := &StorageGCReport{
+		StartedAt:     time.Now(),
+		Reconciled:    reconcile,
+		OrphanedBlobs: []storage.Blob{},
+		MissingBlobs:  []MissingBlob{},
+	}
+	cutoff := report.StartedAt.Add(-g.grace)
+
+	// Uploads are listed before records, so an upload recorded between the two isn't taken for an
+	// orphan; one stored after the listing is too young to be missed
+	blobs, err := g.fileStorage.ListBlobs()
+	if err != nil {
+		return nil, err
+	}
+	files, err := g.files.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	recorded := make(map[string]bool, len(files))
+	for _, file := range files {
+		recorded[file.ID] = true
+	}
+	stored := make(map[string]bool, len(blobs))
+	for _, blob := range blobs {
+		stored[blob.FileID] = true
+		if !recorded[blob.FileID] && blob.ModifiedAt.Before(cutoff) {
+			report.OrphanedBlobs = append(report.OrphanedBlobs, blob)
+			report.OrphanedBytes += blob.Size
+		}
+	}
+	for _, file := range files {
+		if !stored[file.ID] && file.UploadedAt.Before(cutoff) {
+			report.MissingBlobs = append(report.MissingBlobs, MissingBlob{
+				FileID:     file.ID,
+				UserID:     file.UserID,
+				FileName:   file.FileName,
+				UploadedAt: file.UploadedAt,
+			})
+		}
+	}
+
+	if reconcile {
+		g.fix(ctx, report, len(files))
+	}
+
+	report.FinishedAt = time.Now()
+	g.mu.Lock()
+	g.last = report
+	g.mu.Unlock()
+	return report, nil
+}
+
+// fix trashes orphaned uploads, deletes records without an upload and purges the trash. records
+// is how many file records there are.
+func (g *StorageGC) fix(ctx context.Context, report *StorageGCReport, records int) {
+	for _, blob := range report.OrphanedBlobs {
+		if err := g.fileStorage.TrashBlob(blob); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.TrashedBlobs++
+	}
+
+	missingBlobs := report.MissingBlobs
+	if len(missingBlobs) > 0 && float64(len(missingBlobs)) > maxMissingShare*float64(records) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%d of %d file records have no stored upload, too many to delete without checking storage first",
+			len(missingBlobs), records))
+		missingBlobs = nil
+	}
+	for _, missing := range missingBlobs {
+		err := g.files.Delete(ctx, missing.FileID, missing.UserID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete file %s: %v", missing.FileID, err))
+			continue
+		}
+		g.resultCache.InvalidateFile(ctx, missing.UserID, missing.FileID)
+		report.DeletedRecords++
+	}
+
+	purged, freed, err := g.fileStorage.PurgeTrash(report.StartedAt.Add(-g.trashRetention))
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.PurgedBlobs, report.PurgedBytes = purged, freed
+}
+
+// LastReport returns the report of the latest sweep, or nil before the first
+func (g *StorageGC) LastReport() *StorageGCReport {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.last
+}
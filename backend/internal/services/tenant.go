@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// ownerContext scopes a context to a user's org, for requests authenticated by a token for one of
+// the user's resources rather than as the user, so they only reach that org's rows
+func ownerContext(ctx context.Context, users repository.UserRepository, userID string) (context.Context, error) {
+	owner, err := users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find owner: %w", err)
+	}
+	return db.WithOrg(ctx, owner.OrgID), nil
+}
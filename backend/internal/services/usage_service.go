@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// Usage limit errors, wrapped in a UsageLimitError saying what the limit is
+var (
+	ErrAPICallLimit = errors.New("monthly API call limit reached")
+	ErrRowLimit     = errors.New("monthly ingested row limit reached")
+	ErrStorageLimit = errors.New("storage limit reached")
+)
+
+// usageFlushInterval is how often metered usage is added to the database
+const usageFlushInterval = 15 * time.Second
+
+// usageRefreshInterval is how long an org's usage read from the database is trusted for limit
+// checks, which bounds how far other servers' usage can go unseen
+const usageRefreshInterval = 30 * time.Second
+
+// maxUsageHistoryMonths is how many months of usage history can be listed
+const maxUsageHistoryMonths = 24
+
+// UsageLimits are an org's plan's usage limits. A limit of 0 is unlimited.
+type UsageLimits struct {
+	APICalls     int64 `json:"apiCalls"`
+	RowsIngested int64 `json:"rowsIngested"`
+	StorageBytes int64 `json:"storageBytes"`
+}
+
+// UsageLimitError is returned when an org has reached one of its plan's limits
+type UsageLimitError struct {
+	Err      error
+	Limit    int64
+	ResetsAt *time.Time // When the monthly limit resets; nil for storage, which frees up as files are deleted
+}
+
+// Error explains which limit was reached and what frees it up
+func (e *UsageLimitError) Error() string {
+	if errors.Is(e.Err, ErrStorageLimit) {
+		return fmt.Sprintf("%s: your plan stores up to %d bytes of files; delete files to free up space", e.Err, e.Limit)
+	}
+
+	unit := "API calls"
+	if errors.Is(e.Err, ErrRowLimit) {
+		unit = "ingested rows"
+	}
+	return fmt.Sprintf("%s: your plan allows %d %s a month, which resets at %s", e.Err, e.Limit, unit, e.ResetsAt.Format(time.RFC3339))
+}
+
+// Unwrap returns the limit error
+func (e *UsageLimitError) Unwrap() error {
+	return e.Err
+}
+
+// UsageReport is an org's usage in the current month against its plan's limits
+type UsageReport struct {
+	Period       time.Time   `json:"period"`
+	ResetsAt     time.Time   `json:"resetsAt"`
+	APICalls     int64       `json:"apiCalls"`
+	RowsIngested int64       `json:"rowsIngested"`
+	StorageBytes int64       `json:"storageBytes"`
+	Limits       UsageLimits `json:"limits"`
+}
+
+// usageCounts are API calls and ingested rows metered but not yet flushed
+type usageCounts struct {
+	apiCalls, rowsIngested int64
+}
+
+// usagePeriodKey identifies an org's month
+type usagePeriodKey struct {
+	orgID  string
+	period time.Time
+}
+
+// cachedUsage is an org's usage as last read from the database, with its plan's limits
+type cachedUsage struct {
+	usage    models.OrgUsage
+	limits   UsageLimits
+	loadedAt time.Time
+}
+
+// UsageService meters each org's API calls, ingested rows and storage by calendar month (UTC)
+// and checks them against the limits of the org's plan. Calls and rows are counted in memory and
+// added to the database every few seconds, so every server's share is summed; limit checks read
+// the database at most every half minute, so orgs can briefly run past a limit across servers
+// and plan changes take up to half a minute to apply.
+type UsageService struct {
+	usage repository.UsageRepository
+	users repository.UserRepository
+	orgs  repository.OrganizationRepository
+
+	flushMu sync.Mutex // held by Flush, so one flush writes at a time
+
+	mu       sync.Mutex
+	pending  map[usagePeriodKey]*usageCounts
+	flushing map[usagePeriodKey]*usageCounts // taken by the running flush but not yet written
+	flushed  uint64                          // counts writes by flushes, so loads that raced one aren't cached
+	cached   map[usagePeriodKey]*cachedUsage
+	userOrgs sync.Map // user ID → org ID, since users never change orgs
+}
+
+// NewUsageService creates a usage service enforcing each org's plan limits
+func NewUsageService(repos repository.Repositories) *UsageService {
+	return &UsageService{
+		usage:    repos.Usage,
+		users:    repos.Users,
+		orgs:     repos.Orgs,
+		pending:  make(map[usagePeriodKey]*usageCounts),
+		flushing: make(map[usagePeriodKey]*usageCounts),
+		cached:   make(map[usagePeriodKey]*cachedUsage),
+	}
+}
+
+// usagePeriod returns the start of the month containing t, in UTC
+func usagePeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// MeterAPICall counts an API call by an org, unless the org has reached its monthly API call
+// limit, in which case the call isn't counted and a UsageLimitError is returned
+func (s *UsageService) MeterAPICall(ctx context.Context, orgID string) error {
+	period := usagePeriod(time.Now())
+	usage, limits, err := s.current(ctx, orgID, period)
+	if err != nil {
+		return err
+	}
+	if limits.APICalls > 0 && usage.APICalls >= limits.APICalls {
+		resetsAt := period.AddDate(0, 1, 0)
+		return &UsageLimitError{Err: ErrAPICallLimit, Limit: limits.APICalls, ResetsAt: &resetsAt}
+	}
+
+	s.add(usagePeriodKey{orgID, period}, usageCounts{apiCalls: 1})
+	return nil
+}
+
+// RecordRowsIngested counts rows a user's files or streams ingested against their org. Files
+// that are reprocessed are counted again, since they're parsed again.
+func (s *UsageService) RecordRowsIngested(ctx context.Context, userID string, rows int64) {
+	if rows <= 0 {
+		return
+	}
+
+	orgID, err := s.orgOf(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to meter ingested rows", "userID", userID, "error", err)
+		return
+	}
+	s.add(usagePeriodKey{orgID, usagePeriod(time.Now())}, usageCounts{rowsIngested: rows})
+}
+
+// CheckIngestion checks that an org may store size more bytes and ingest more rows this month,
+// returning a UsageLimitError when it has reached either limit. A size of 0 only checks that
+// storage isn't already full.
+func (s *UsageService) CheckIngestion(ctx context.Context, orgID string, size int64) error {
+	period := usagePeriod(time.Now())
+	usage, limits, err := s.current(ctx, orgID, period)
+	if err != nil {
+		return err
+	}
+	if limits.RowsIngested > 0 && usage.RowsIngested >= limits.RowsIngested {
+		resetsAt := period.AddDate(0, 1, 0)
+		return &UsageLimitError{Err: ErrRowLimit, Limit: limits.RowsIngested, ResetsAt: &resetsAt}
+	}
+
+	if limits.StorageBytes > 0 {
+		stored, err := s.usage.StorageBytes(ctx, orgID)
+		if err != nil {
+			return fmt.Errorf("failed to measure storage: %w", err)
+		}
+		if stored+max(size, 0) > limits.StorageBytes || stored >= limits.StorageBytes {
+			return &UsageLimitError{Err: ErrStorageLimit, Limit: limits.StorageBytes}
+		}
+	}
+
+	return nil
+}
+
+// Usage reports an org's usage this month against its limits
+func (s *UsageService) Usage(ctx context.Context, orgID string) (*UsageReport, error) {
+	period := usagePeriod(time.Now())
+	usage, limits, err := s.load(ctx, orgID, period)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.usage.StorageBytes(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure storage: %w", err)
+	}
+
+	usage = s.withPending(usage)
+	return &UsageReport{
+		Period:       period,
+		ResetsAt:     period.AddDate(0, 1, 0),
+		APICalls:     usage.APICalls,
+		RowsIngested: usage.RowsIngested,
+		StorageBytes: stored,
+		Limits:       limits,
+	}, nil
+}
+
+// History lists an org's usage over the last months, this month included, newest first.
+// Months nothing was metered in are left out.
+func (s *UsageService) History(ctx context.Context, orgID string, months int) ([]*models.OrgUsage, error) {
+	if months < 1 || months > maxUsageHistoryMonths {
+		months = maxUsageHistoryMonths
+	}
+
+	since := usagePeriod(time.Now()).AddDate(0, 1-months, 0)
+	history, err := s.usage.ListSince(ctx, orgID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+
+	// This server's usage since its last flush isn't in the database yet
+	for i, usage := range history {
+		merged := s.withPending(*usage)
+		history[i] = &merged
+	}
+	return history, nil
+}
+
+// Limits returns the limits of an org's plan
+func (s *UsageService) Limits(ctx context.Context, orgID string) (UsageLimits, error) {
+	org, err := s.orgs.FindByID(ctx, orgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return UsageLimits{}, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return UsageLimits{}, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	limits := org.Limits(time.Now())
+	return UsageLimits{
+		APICalls:     limits.APICallsPerMonth,
+		RowsIngested: limits.RowsIngestedPerMonth,
+		StorageBytes: limits.StorageBytes,
+	}, nil
+}
+
+// current returns an org's usage in a month for limit checks, the database's count as of the
+// last refresh plus this server's unflushed usage, and its plan's limits as of the last refresh
+func (s *UsageService) current(ctx context.Context, orgID string, period time.Time) (models.OrgUsage, UsageLimits, error) {
+	key := usagePeriodKey{orgID, period}
+
+	s.mu.Lock()
+	cached, ok := s.cached[key]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < usageRefreshInterval {
+		return s.withPending(cached.usage), cached.limits, nil
+	}
+
+	usage, limits, err := s.load(ctx, orgID, period)
+	if err != nil {
+		return models.OrgUsage{}, UsageLimits{}, err
+	}
+	return s.withPending(usage), limits, nil
+}
+
+// load reads an org's usage in a month and its plan's limits from the database, and caches them
+// for limit checks
+func (s *UsageService) load(ctx context.Context, orgID string, period time.Time) (models.OrgUsage, UsageLimits, error) {
+	s.mu.Lock()
+	flushed := s.flushed
+	s.mu.Unlock()
+
+	limits, err := s.Limits(ctx, orgID)
+	if err != nil {
+		return models.OrgUsage{}, UsageLimits{}, err
+	}
+	usage, err := s.usage.Get(ctx, orgID, period)
+	if err != nil {
+		return models.OrgUsage{}, UsageLimits{}, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	s.mu.Lock()
+	// Usage read while a flush was writing may not include what it wrote
+	if s.flushed == flushed {
+		s.cached[usagePeriodKey{orgID, period}] = &cachedUsage{usage: *usage, limits: limits, loadedAt: time.Now()}
+	}
+	s.mu.Unlock()
+
+	return *usage, limits, nil
+}
+
+// withPending adds this server's unflushed usage, including any a flush is writing, to usage read
+// from the database
+func (s *UsageService) withPending(usage models.OrgUsage) models.OrgUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := usagePeriodKey{usage.OrgID, usage.Period}
+	for _, counts := range []*usageCounts{s.pending[key], s.flushing[key]} {
+		if counts != nil {
+			usage.APICalls += counts.apiCalls
+			usage.RowsIngested += counts.rowsIngested
+		}
+	}
+	return usage
+}
+
+// add adds counts to an org's unflushed usage
+func (s *UsageService) add(key usagePeriodKey, counts usageCounts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addLocked(key, counts)
+}
+
+// addLocked adds counts to an org's unflushed usage; s.mu must be held
+func (s *UsageService) addLocked(key usagePeriodKey, counts usageCounts) {
+	pending, ok := s.pending[key]
+	if !ok {
+		pending = &usageCounts{}
+		s.pending[key] = pending
+	}
+	pending.apiCalls += counts.apiCalls
+	pending.rowsIngested += counts.rowsIngested
+}
+
+// orgOf returns a user's org
+func (s *UsageService) orgOf(ctx context.Context, userID string) (string, error) {
+	if orgID, ok := s.userOrgs.Load(userID); ok {
+		return orgID.(string), nil
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find user: %w", err)
+	}
+	s.userOrgs.Store(userID, user.OrgID)
+	return user.OrgID, nil
+}
+
+// Run flushes metered usage until the context is canceled. Usage metered after the last flush
+// is left for the caller to Flush once requests and jobs have stopped.
+func (s *UsageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to flush usage", "error", err)
+			}
+		}
+	}
+}
+
+// Flush adds metered usage to the database. Usage being written still counts toward limit
+// checks until it's in the database, and usage that fails to be written is kept for the next
+// flush.
+func (s *UsageService) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	flushing := s.pending
+	s.pending = make(map[usagePeriodKey]*usageCounts)
+	s.flushing = flushing
+	s.mu.Unlock()
+
+	var firstErr error
+	for key, counts := range flushing {
+		err := s.usage.Add(ctx, key.orgID, key.period, counts.apiCalls, counts.rowsIngested)
+
+		s.mu.Lock()
+		delete(s.flushing, key)
+		if err == nil || errors.Is(err, repository.ErrNotFound) {
+			// Cached usage doesn't include what was written, so it's read again. An org that was
+			// deleted has nothing left to meter.
+			delete(s.cached, key)
+			s.flushed++
+		} else {
+			s.addLocked(key, *counts)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to add usage: %w", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return firstErr
+}
@@ -3,27 +3,42 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
 	"time"
 
-	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
 	"github.com/bolognesandwiches/AdVantage/internal/models"
-	"github.com/jackc/pgx/v5"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
 )
 
+// MaxAvatarUploadSize caps the size of an uploaded avatar image
+const MaxAvatarUploadSize = 5 << 20
+
 // Common errors
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrNoAvatar       = errors.New("user has no avatar")
 )
 
+// localePattern matches BCP 47 language tags such as en, en-US or zh-Hant-TW
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
 // UserService handles user-related operations
 type UserService struct {
-	db *db.PostgresDB
+	users   repository.UserRepository
+	avatars *storage.FileStorage
 }
 
-// NewUserService creates a new UserService
-func NewUserService(database *db.PostgresDB) *UserService {
+// NewUserService creates a new UserService storing avatars in the given file storage
+func NewUserService(users repository.UserRepository, avatars *storage.FileStorage) *UserService {
 	return &UserService{
-		db: database,
+		users:   users,
+		avatars: avatars,
 	}
 }
 
@@ -34,123 +49,120 @@ func (s *UserService) Create(ctx context.Context, user *models.User) error {
 		user.ID = generateUUID()
 	}
 
+	// New users start in a personal org sharing their ID
+	if user.OrgID == "" {
+		user.OrgID = user.ID
+	}
+
 	// Set timestamps
 	now := time.Now()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
-	query := `
-		INSERT INTO users (id, email, password, first_name, last_name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	_, err := s.db.Pool.Exec(ctx, query,
-		user.ID,
-		user.Email,
-		user.Password,
-		user.FirstName,
-		user.LastName,
-		user.CreatedAt,
-		user.UpdatedAt,
-	)
-
-	return err
+	return s.users.Create(ctx, user)
 }
 
 // FindByID finds a user by ID
 func (s *UserService) FindByID(ctx context.Context, id string) (*models.User, error) {
-	query := `
-		SELECT id, email, password, first_name, last_name, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-
-	user := &models.User{}
-	err := s.db.Pool.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FirstName,
-		&user.LastName,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
+	user, err := s.users.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
 	}
 
-	return user, nil
+	return user, err
 }
 
 // FindByEmail finds a user by email
 func (s *UserService) FindByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `
-		SELECT id, email, password, first_name, last_name, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-
-	user := &models.User{}
-	err := s.db.Pool.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.FirstName,
-		&user.LastName,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := s.users.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrUserNotFound
+	return user, err
+}
+
+// ExistsByEmail checks if a user with the given email exists
+func (s *UserService) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	return s.users.ExistsByEmail(ctx, email)
+}
+
+// Update updates an existing user
+func (s *UserService) Update(ctx context.Context, user *models.User) error {
+	if user.Timezone != "" {
+		if _, err := time.LoadLocation(user.Timezone); err != nil || user.Timezone == "Local" {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidProfile, user.Timezone)
 		}
+	}
+	if user.Locale != "" && !localePattern.MatchString(user.Locale) {
+		return fmt.Errorf("%w: locale %q is not a language tag such as en-US", ErrInvalidProfile, user.Locale)
+	}
+
+	// Update timestamp
+	user.UpdatedAt = time.Now()
+
+	return s.users.Update(ctx, user)
+}
+
+// SetAvatar stores an uploaded image as the user's avatar, replacing any previous one
+func (s *UserService) SetAvatar(ctx context.Context, userID string, image io.Reader) (*models.User, error) {
+	user, err := s.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	avatarID, err := s.avatars.StoreAvatar(image, MaxAvatarUploadSize)
+	if err != nil {
 		return nil, err
 	}
 
+	previous := user.AvatarID
+	user.AvatarID = avatarID
+	if err := s.Update(ctx, user); err != nil {
+		_ = s.avatars.DeleteAvatar(avatarID)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	s.deleteAvatar(ctx, previous)
+
 	return user, nil
 }
 
-// ExistsByEmail checks if a user with the given email exists
-func (s *UserService) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	query := `
-		SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)
-	`
-
-	var exists bool
-	err := s.db.Pool.QueryRow(ctx, query, email).Scan(&exists)
+// RemoveAvatar removes the user's avatar
+func (s *UserService) RemoveAvatar(ctx context.Context, userID string) error {
+	user, err := s.FindByID(ctx, userID)
 	if err != nil {
-		return false, err
+		return err
+	}
+	if user.AvatarID == "" {
+		return ErrNoAvatar
+	}
+
+	previous := user.AvatarID
+	user.AvatarID = ""
+	if err := s.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
 	}
 
-	return exists, nil
+	s.deleteAvatar(ctx, previous)
+
+	return nil
 }
 
-// Update updates an existing user
-func (s *UserService) Update(ctx context.Context, user *models.User) error {
-	// Update timestamp
-	user.UpdatedAt = time.Now()
+// OpenAvatar opens a stored avatar by its ID
+func (s *UserService) OpenAvatar(avatarID string) (*os.File, error) {
+	return s.avatars.OpenAvatar(avatarID)
+}
 
-	query := `
-		UPDATE users
-		SET email = $2, password = $3, first_name = $4, last_name = $5, updated_at = $6
-		WHERE id = $1
-	`
-
-	_, err := s.db.Pool.Exec(ctx, query,
-		user.ID,
-		user.Email,
-		user.Password,
-		user.FirstName,
-		user.LastName,
-		user.UpdatedAt,
-	)
-
-	return err
+// deleteAvatar removes a replaced avatar; the user no longer references it, so a failure only
+// leaves an orphaned file behind and is reported rather than returned
+func (s *UserService) deleteAvatar(ctx context.Context, avatarID string) {
+	if avatarID == "" {
+		return
+	}
+	if err := s.avatars.DeleteAvatar(avatarID); err != nil {
+		errreport.Report(errreport.WithTags(ctx, "avatar_id", avatarID), "failed to delete replaced avatar", err)
+	}
 }
 
 // Helper function to generate a UUID
@@ -0,0 +1,150 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Settings holds the configuration that can be changed while the server is running
+type Settings struct {
+	LogLevel           string `json:"logLevel"`           // debug, info, warn or error
+	RateLimitPerMinute int    `json:"rateLimitPerMinute"` // requests per client, 0 disables limiting
+	WorkerConcurrency  int    `json:"workerConcurrency"`  // processing jobs run at once
+	MaxUploadSizeMB    int    `json:"maxUploadSizeMB"`
+}
+
+// Validate checks that the settings are usable
+func (s Settings) Validate() error {
+	switch strings.ToLower(s.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid log level: %s", s.LogLevel)
+	}
+	if s.RateLimitPerMinute < 0 {
+		return fmt.Errorf("rate limit must not be negative")
+	}
+	if s.WorkerConcurrency < 1 {
+		return fmt.Errorf("worker concurrency must be at least 1")
+	}
+	if s.MaxUploadSizeMB < 1 {
+		return fmt.Errorf("max upload size must be at least 1MB")
+	}
+	return nil
+}
+
+// MaxUploadSizeBytes returns the maximum upload size in bytes
+func (s Settings) MaxUploadSizeBytes() int64 {
+	return int64(s.MaxUploadSizeMB) << 20
+}
+
+// Load reads the settings from the environment, overridden by the values in the file at path when it exists.
+// The process environment can't change after start, so reloads pick up new values from the file.
+func Load(path string) (Settings, error) {
+	values := map[string]string{}
+	if path != "" {
+		fileValues, err := godotenv.Read(path)
+		if err != nil && !os.IsNotExist(err) {
+			return Settings{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		values = fileValues
+	}
+
+	get := func(key, defaultValue string) string {
+		if value, ok := values[key]; ok && value != "" {
+			return value
+		}
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return defaultValue
+	}
+
+	rateLimit, err := strconv.Atoi(get("RATE_LIMIT_PER_MINUTE", "600"))
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid RATE_LIMIT_PER_MINUTE: %w", err)
+	}
+	concurrency, err := strconv.Atoi(get("WORKER_CONCURRENCY", "4"))
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid WORKER_CONCURRENCY: %w", err)
+	}
+	maxUpload, err := strconv.Atoi(get("MAX_UPLOAD_SIZE_MB", "50"))
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid MAX_UPLOAD_SIZE_MB: %w", err)
+	}
+
+	settings := Settings{
+		LogLevel:           strings.ToLower(get("LOG_LEVEL", "info")),
+		RateLimitPerMinute: rateLimit,
+		WorkerConcurrency:  concurrency,
+		MaxUploadSizeMB:    maxUpload,
+	}
+
+	return settings, settings.Validate()
+}
+
+// Store holds the current settings and notifies listeners when they change
+type Store struct {
+	mu        sync.RWMutex
+	current   Settings
+	path      string
+	listeners []func(Settings)
+}
+
+// NewStore creates a store with initial settings; path is the file re-read by Reload
+func NewStore(initial Settings, path string) *Store {
+	return &Store{
+		current: initial,
+		path:    path,
+	}
+}
+
+// Get returns the current settings
+func (s *Store) Get() Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// OnChange registers a listener and calls it immediately with the current settings
+func (s *Store) OnChange(listener func(Settings)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	current := s.current
+	s.mu.Unlock()
+
+	listener(current)
+}
+
+// Update validates and applies new settings, notifying every listener
+func (s *Store) Update(settings Settings) error {
+	settings.LogLevel = strings.ToLower(settings.LogLevel)
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.current = settings
+	listeners := append([]func(Settings){}, s.listeners...)
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(settings)
+	}
+
+	return nil
+}
+
+// Reload re-reads the settings file and applies it
+func (s *Store) Reload() (Settings, error) {
+	settings, err := Load(s.path)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	return settings, s.Update(settings)
+}
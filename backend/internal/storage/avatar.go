@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	// Register the formats avatars may be uploaded in
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AvatarSize is the width and height avatars are stored at, in pixels
+	AvatarSize = 256
+	// maxAvatarPixels bounds the decoded size of an uploaded image, so a small file can't
+	// expand into an enormous bitmap
+	maxAvatarPixels = 40_000_000
+)
+
+// Avatar errors
+var (
+	ErrInvalidImage   = errors.New("file is not a PNG, JPEG or GIF image")
+	ErrImageTooLarge  = errors.New("image dimensions are too large")
+	ErrAvatarNotFound = errors.New("avatar not found")
+)
+
+// StoreAvatar validates an uploaded image, crops it to a centered square, scales it to
+// AvatarSize and stores it as a PNG under a new random ID. The ID is safe to expose
+// publicly: it says nothing about the user and can't be guessed.
+func (fs *FileStorage) StoreAvatar(file io.Reader, maxSize int64) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return "", ErrFileTooLarge
+	}
+
+	// Check the dimensions before decoding the whole image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return "", ErrInvalidImage
+	}
+	if int64(config.Width)*int64(config.Height) > maxAvatarPixels {
+		return "", ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, resizeSquare(img, AvatarSize)); err != nil {
+		return "", fmt.Errorf("failed to encode avatar: %w", err)
+	}
+
+	id := uuid.New().String()
+	if err := os.WriteFile(fs.avatarPath(id), out.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write avatar: %w", err)
+	}
+
+	return id, nil
+}
+
+// OpenAvatar opens a stored avatar
+func (fs *FileStorage) OpenAvatar(id string) (*os.File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrAvatarNotFound
+	}
+
+	file, err := os.Open(fs.avatarPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAvatarNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open avatar: %w", err)
+	}
+
+	return file, nil
+}
+
+// DeleteAvatar removes a stored avatar; removing one that doesn't exist is not an error
+func (fs *FileStorage) DeleteAvatar(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrAvatarNotFound
+	}
+
+	if err := os.Remove(fs.avatarPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete avatar: %w", err)
+	}
+
+	return nil
+}
+
+// avatarPath returns the path an avatar is stored at
+func (fs *FileStorage) avatarPath(id string) string {
+	return filepath.Join(fs.basePath, "avatars", id+".png")
+}
+
+// resizeSquare crops an image to its centered square and scales it to size x size, averaging
+// the source pixels each destination pixel covers so downscaled avatars stay smooth
+func resizeSquare(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	// Work on a copy of the crop in a single known pixel format
+	src := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := y*side/size, max((y+1)*side/size, y*side/size+1)
+		for x := 0; x < size; x++ {
+			x0, x1 := x*side/size, max((x+1)*side/size, x*side/size+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					// Weight color by alpha so transparent pixels don't darken the edges
+					alpha := uint64(p[3])
+					r += uint64(p[0]) * alpha
+					g += uint64(p[1]) * alpha
+					b += uint64(p[2]) * alpha
+					a += alpha
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+			}
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// blobDirs are the directories uploaded files are stored under, by user
+var blobDirs = []string{"dsp_logs", "reports", "temp"}
+
+// derivedSuffixes end the names of files written alongside uploads from their processing,
+// such as analysis results, which are named after the upload but aren't one
+var derivedSuffixes = []string{"_analysis.json"}
+
+// Blob is an uploaded file as stored on disk
+type Blob struct {
+	FileID     string    `json:"fileId"`
+	UserID     string    `json:"userId"`
+	Path       string    `json:"path"` // Relative to the storage directory
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+}
+
+// ListBlobs lists every stored upload. Files that aren't named after an upload ID, and the
+// files processing writes next to uploads, are left out.
+func (fs *FileStorage) ListBlobs() ([]Blob, error) {
+	var blobs []Blob
+	for _, dir := range blobDirs {
+		users, err := os.ReadDir(filepath.Join(fs.basePath, dir))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+
+		for _, user := range users {
+			if !user.IsDir() {
+				continue
+			}
+			userDir := filepath.Join(dir, user.Name())
+			entries, err := os.ReadDir(filepath.Join(fs.basePath, userDir))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", userDir, err)
+			}
+
+			for _, entry := range entries {
+				fileID, ok := blobFileID(entry.Name())
+				if !ok || !entry.Type().IsRegular() {
+					continue
+				}
+				info, err := entry.Info()
+				if errors.Is(err, os.ErrNotExist) {
+					// Deleted since the directory was read
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to stat %s: %w", entry.Name(), err)
+				}
+				blobs = append(blobs, Blob{
+					FileID:     fileID,
+					UserID:     user.Name(),
+					Path:       filepath.Join(userDir, entry.Name()),
+					Size:       info.Size(),
+					ModifiedAt: info.ModTime(),
+				})
+			}
+		}
+	}
+
+	return blobs, nil
+}
+
+// blobFileID returns the upload ID a stored file is named after, as StoreFile names them
+func blobFileID(name string) (string, bool) {
+	id, _, ok := strings.Cut(name, "_")
+	if !ok || uuid.Validate(id) != nil {
+		return "", false
+	}
+	for _, suffix := range derivedSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) == len(id)+len(suffix) {
+			return "", false
+		}
+	}
+	return id, true
+}
+
+// TrashBlob moves a stored upload into the trash, where it's kept until PurgeTrash removes it.
+// Putting it back is a matter of moving it from trash/ to the same path outside it.
+func (fs *FileStorage) TrashBlob(blob Blob) error {
+	source := filepath.Join(fs.basePath, blob.Path)
+	target := filepath.Join(fs.basePath, "trash", blob.Path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := os.Rename(source, target); err != nil {
+		return fmt.Errorf("failed to move %s to the trash: %w", blob.Path, err)
+	}
+
+	// Date the blob by when it was trashed, which is what PurgeTrash goes by
+	now := time.Now()
+	if err := os.Chtimes(target, now, now); err != nil {
+		return fmt.Errorf("failed to date %s in the trash: %w", blob.Path, err)
+	}
+	return nil
+}
+
+// PurgeTrash removes the blobs trashed before a cutoff, returning how many it removed and the
+// bytes it freed
+func (fs *FileStorage) PurgeTrash(before time.Time) (int, int64, error) {
+	root := filepath.Join(fs.basePath, "trash")
+	removed, freed := 0, int64(0)
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return removed, freed, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	return removed, freed, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrDecompressionLimit is returned when an archive expands past its decompression limits, as
+// a zip bomb does
+var ErrDecompressionLimit = errors.New("archive expands past the decompression limits")
+
+// DecompressionLimits bound how far a compressed upload may expand. A zero limit is no limit.
+type DecompressionLimits struct {
+	MaxRatio int64 // decompressed bytes allowed per compressed byte
+	MaxBytes int64 // decompressed bytes allowed in total
+}
+
+// Budget returns the bytes an archive of compressedSize bytes may decompress into
+func (l DecompressionLimits) Budget(compressedSize int64) *DecompressionBudget {
+	allowed := int64(-1)
+	if l.MaxRatio > 0 {
+		allowed = l.MaxRatio * compressedSize
+		if compressedSize > 0 && allowed/compressedSize != l.MaxRatio {
+			// Overflowed, which no disk could hold anyway
+			allowed = -1
+		}
+	}
+	if l.MaxBytes > 0 && (allowed < 0 || l.MaxBytes < allowed) {
+		allowed = l.MaxBytes
+	}
+	return &DecompressionBudget{allowed: allowed}
+}
+
+// DecompressionBudget counts the bytes decompressed from one archive, across all its entries,
+// against what its limits allow. It isn't safe for concurrent use.
+type DecompressionBudget struct {
+	allowed int64 // -1 when unlimited
+	used    int64
+}
+
+// Check fails with ErrDecompressionLimit when an archive declares it decompresses into more than
+// its budget, so it can be refused before anything is decompressed. Declared sizes can lie, so
+// entries are still counted as they're read.
+func (b *DecompressionBudget) Check(declared uint64) error {
+	if b.allowed >= 0 && declared > uint64(b.allowed) {
+		return fmt.Errorf("%w: declares %d bytes, more than the %d allowed", ErrDecompressionLimit, declared, b.allowed)
+	}
+	return nil
+}
+
+// Reader counts what's read from an archive entry against the budget, failing with
+// ErrDecompressionLimit as soon as the archive has decompressed into more than it allows
+func (b *DecompressionBudget) Reader(r io.Reader) io.Reader {
+	if b.allowed < 0 {
+		return r
+	}
+	return &budgetReader{r: r, budget: b}
+}
+
+// budgetReader reads an archive entry within a decompression budget
+type budgetReader struct {
+	r      io.Reader
+	budget *DecompressionBudget
+}
+
+// Read reads from the entry, reading at most one byte past the budget to tell it was exceeded
+func (r *budgetReader) Read(p []byte) (int, error) {
+	remaining := r.budget.allowed - r.budget.used
+	if remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrDecompressionLimit, r.budget.allowed)
+	}
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+
+	n, err := r.r.Read(p)
+	r.budget.used += int64(n)
+	if r.budget.used > r.budget.allowed {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrDecompressionLimit, r.budget.allowed)
+	}
+	return n, err
+}
@@ -1,15 +1,27 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
 
+// File storage errors
+var (
+	// ErrFileTooLarge is returned when a file exceeds the size limit it is stored with
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrFileNotFound is returned when a user has no stored file with an ID
+	ErrFileNotFound = errors.New("file not found")
+)
+
 // FileInfo represents metadata about a stored file
 type FileInfo struct {
 	ID         string    `json:"id"`
@@ -24,6 +36,8 @@ type FileInfo struct {
 // FileStorage handles storing and retrieving files
 type FileStorage struct {
 	basePath string
+	// appending serializes chunk appends per resumable upload
+	appending sync.Map
 }
 
 // NewFileStorage creates a new file storage instance
@@ -39,7 +53,7 @@ func NewFileStorage(basePath string) (*FileStorage, error) {
 	}
 
 	// Create subdirectories for organization
-	for _, dir := range []string{"dsp_logs", "reports", "temp"} {
+	for _, dir := range []string{"dsp_logs", "reports", "temp", "avatars", "partial"} {
 		if err := os.MkdirAll(filepath.Join(basePath, dir), 0755); err != nil {
 			return nil, fmt.Errorf("failed to create %s directory: %w", dir, err)
 		}
@@ -50,8 +64,27 @@ func NewFileStorage(basePath string) (*FileStorage, error) {
 	}, nil
 }
 
-// StoreFile saves a file to disk and returns metadata about the stored file
-func (fs *FileStorage) StoreFile(file io.Reader, fileName, fileType, userID string, fileSize int64) (*FileInfo, error) {
+// NewTempFileStorage creates file storage in a new temporary directory, for exercising uploads
+// and processing without touching the configured upload directory. cleanup removes the directory
+// and everything stored in it.
+func NewTempFileStorage() (fs *FileStorage, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "advantage-uploads-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary upload directory: %w", err)
+	}
+
+	fs, err = NewFileStorage(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	return fs, func() { os.RemoveAll(dir) }, nil
+}
+
+// StoreFile streams a file to disk and returns metadata about the stored file.
+// Files larger than maxSize bytes are removed and ErrFileTooLarge is returned; 0 means no limit.
+func (fs *FileStorage) StoreFile(file io.Reader, fileName, fileType, userID string, maxSize int64) (*FileInfo, error) {
 	// Generate a unique ID for the file
 	id := uuid.New().String()
 
@@ -84,15 +117,29 @@ func (fs *FileStorage) StoreFile(file io.Reader, fileName, fileType, userID stri
 	}
 	defer dst.Close()
 
-	// Copy file data to the destination
-	if _, err := io.Copy(dst, file); err != nil {
+	// Copy file data to the destination, reading one byte past the limit to detect oversized files
+	src := file
+	if maxSize > 0 {
+		src = io.LimitReader(file, maxSize+1)
+	}
+	fileSize, err := io.Copy(dst, src)
+	if err == nil && maxSize > 0 && fileSize > maxSize {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		// Don't leave a partial file behind
+		dst.Close()
+		os.Remove(filePath)
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Return file info
 	return &FileInfo{
 		ID:         id,
-		FileName:   fileName,
+		FileName:   safeFileName,
 		FileSize:   fileSize,
 		FileType:   fileType,
 		UploadedAt: time.Now(),
@@ -137,6 +184,31 @@ func (fs *FileStorage) DeleteFile(id, userID string) error {
 	return nil
 }
 
+// CheckWritable verifies that files can be written to and removed from storage
+func (fs *FileStorage) CheckWritable() error {
+	probe, err := os.CreateTemp(filepath.Join(fs.basePath, "temp"), ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("failed to create probe file: %w", err)
+	}
+	name := probe.Name()
+
+	_, writeErr := probe.Write([]byte("ok"))
+	closeErr := probe.Close()
+	removeErr := os.Remove(name)
+
+	if writeErr != nil {
+		return fmt.Errorf("failed to write probe file: %w", writeErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close probe file: %w", closeErr)
+	}
+	if removeErr != nil {
+		return fmt.Errorf("failed to remove probe file: %w", removeErr)
+	}
+
+	return nil
+}
+
 // findFileByID is a helper function to find a file by ID
 // In a real implementation, this would be replaced with a database query
 func (fs *FileStorage) findFileByID(id, userID string) (*FileInfo, error) {
@@ -190,7 +262,7 @@ func (fs *FileStorage) findFileByID(id, userID string) (*FileInfo, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("file not found")
+	return nil, ErrFileNotFound
 }
 
 // Helper functions for file type detection and sanitization
@@ -201,8 +273,9 @@ func isLogFile(fileType, fileName string) bool {
 	ext := filepath.Ext(fileName)
 	return (fileType == "text/csv" || fileType == "application/vnd.ms-excel" ||
 		fileType == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" ||
-		fileType == "text/plain" ||
-		ext == ".csv" || ext == ".xls" || ext == ".xlsx" || ext == ".log" || ext == ".txt")
+		fileType == "text/plain" || fileType == "application/x-ndjson" ||
+		ext == ".csv" || ext == ".xls" || ext == ".xlsx" || ext == ".log" || ext == ".txt" ||
+		ext == ".jsonl" || ext == ".ndjson")
 }
 
 // isReportFile determines if a file is a report file
@@ -213,11 +286,43 @@ func isReportFile(fileType, fileName string) bool {
 		ext == ".docx" || ext == ".doc")
 }
 
-// sanitizeFileName ensures the filename is safe for storage
+// maxStoredNameLength caps the bytes of a stored file's name, leaving room for its ID prefix
+// within the 255 bytes filesystems allow
+const maxStoredNameLength = 200
+
+// sanitizeFileName makes an uploaded file's name safe to store and to send back in headers: the
+// last element of a Unix or Windows path, without control or formatting characters, invalid
+// UTF-8 or leading dots, and short enough for the filesystem. A shortened name keeps its
+// extension, since file types are told by it.
 func sanitizeFileName(fileName string) string {
-	// This is a simple implementation that removes path traversal attempts
-	// A more comprehensive solution would be needed in production
-	return filepath.Base(fileName)
+	// Some browsers on Windows send the whole path
+	if i := strings.LastIndexAny(fileName, `/\`); i >= 0 {
+		fileName = fileName[i+1:]
+	}
+	// Formatting characters include the right-to-left override that disguises "gpj.exe" as
+	// "exe.jpg"
+	fileName = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(fileName, ""))
+	// Leading dots would hide the file, or make it "." or ".."
+	fileName = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(fileName), "."))
+
+	if len(fileName) > maxStoredNameLength {
+		ext := filepath.Ext(fileName)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		// Cutting through a character leaves invalid UTF-8, which is dropped
+		stem := strings.ToValidUTF8(fileName[:maxStoredNameLength-len(ext)], "")
+		fileName = strings.TrimSpace(stem) + ext
+	}
+	if fileName == "" {
+		return "file"
+	}
+	return fileName
 }
 
 // getFileTypeFromName guesses the file type based on the filename
@@ -238,7 +343,18 @@ func getFileTypeFromName(fileName string) string {
 		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
 	case ".json":
 		return "application/json"
+	case ".jsonl", ".ndjson":
+		return "application/x-ndjson"
 	default:
 		return "application/octet-stream"
 	}
 }
+
+// CreateTemp creates a scratch file in the temp directory; the caller closes and removes it
+func (fs *FileStorage) CreateTemp(pattern string) (*os.File, error) {
+	file, err := os.CreateTemp(filepath.Join(fs.basePath, "temp"), pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return file, nil
+}
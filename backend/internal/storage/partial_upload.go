@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Resumable upload errors
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch is returned when a chunk doesn't start where the received data ends
+	ErrOffsetMismatch = errors.New("chunk offset does not match the bytes received")
+)
+
+// PartialUpload is a file being uploaded in chunks. Its data is appended in order, so an
+// interrupted upload resumes from Received.
+type PartialUpload struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	FileName  string    `json:"fileName"`
+	FileType  string    `json:"fileType"`
+	Size      int64     `json:"size"`
+	Received  int64     `json:"received"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreatePartialUpload starts a resumable upload of a file of the given size
+func (fs *FileStorage) CreatePartialUpload(fileName, fileType, userID string, size int64) (*PartialUpload, error) {
+	upload := &PartialUpload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FileName:  sanitizeFileName(fileName),
+		FileType:  fileType,
+		Size:      size,
+		CreatedAt: time.Now(),
+	}
+
+	dirPath := filepath.Join(fs.basePath, "partial", userID)
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create user directory: %w", err)
+	}
+
+	meta, err := json.Marshal(upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize upload: %w", err)
+	}
+	if err := os.WriteFile(fs.partialPath(upload.ID, userID)+".json", meta, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write upload metadata: %w", err)
+	}
+	if err := os.WriteFile(fs.partialPath(upload.ID, userID), nil, 0644); err != nil {
+		os.Remove(fs.partialPath(upload.ID, userID) + ".json")
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetPartialUpload returns a user's resumable upload with the bytes received so far
+func (fs *FileStorage) GetPartialUpload(id, userID string) (*PartialUpload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	meta, err := os.ReadFile(fs.partialPath(id, userID) + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload metadata: %w", err)
+	}
+
+	upload := &PartialUpload{}
+	if err := json.Unmarshal(meta, upload); err != nil {
+		return nil, fmt.Errorf("failed to decode upload metadata: %w", err)
+	}
+
+	info, err := os.Stat(fs.partialPath(id, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat upload: %w", err)
+	}
+	upload.Received = info.Size()
+
+	return upload, nil
+}
+
+// AppendPartialUpload appends a chunk starting at offset, which must equal the bytes already
+// received. A chunk that would take the upload past its declared size is rejected with
+// ErrFileTooLarge, leaving the upload as it was.
+func (fs *FileStorage) AppendPartialUpload(id, userID string, offset int64, chunk io.Reader) (*PartialUpload, error) {
+	lock, _ := fs.appending.LoadOrStore(id, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	upload, err := fs.GetPartialUpload(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Received {
+		return upload, ErrOffsetMismatch
+	}
+
+	dst, err := os.OpenFile(fs.partialPath(id, userID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer dst.Close()
+
+	// Read one byte past the remaining size to detect oversized chunks
+	written, err := io.Copy(dst, io.LimitReader(chunk, upload.Size-upload.Received+1))
+	if err == nil && upload.Received+written > upload.Size {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		// Drop the partial chunk so the client can resend it from the same offset
+		_ = dst.Truncate(upload.Received)
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	upload.Received += written
+	return upload, nil
+}
+
+// OpenPartialUpload opens the data received for an upload
+func (fs *FileStorage) OpenPartialUpload(id, userID string) (*os.File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	file, err := os.Open(fs.partialPath(id, userID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+
+	return file, nil
+}
+
+// DeletePartialUpload removes a resumable upload's data and metadata
+func (fs *FileStorage) DeletePartialUpload(id, userID string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrUploadNotFound
+	}
+
+	defer fs.appending.Delete(id)
+
+	path := fs.partialPath(id, userID)
+	for _, name := range []string{path, path + ".json"} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete upload: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// partialPath returns the path a resumable upload's data is stored at
+func (fs *FileStorage) partialPath(id, userID string) string {
+	return filepath.Join(fs.basePath, "partial", userID, id)
+}
@@ -0,0 +1,207 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kafka REST Proxy v2 content types; records are consumed in binary format so any payload,
+// however it was produced, arrives as the bytes it was written as
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaBinaryType  = "application/vnd.kafka.binary.v2+json"
+)
+
+// kafkaPollTimeout is how long a poll waits on the proxy for records before returning none
+const kafkaPollTimeout = 2 * time.Second
+
+// kafkaMaxBytes caps the records a single poll returns
+const kafkaMaxBytes = 4 << 20
+
+// ErrConsumerExpired is returned when the proxy no longer knows the consumer instance, usually
+// because it sat idle past the proxy's instance timeout. The consumer must be created again.
+var ErrConsumerExpired = errors.New("kafka consumer instance expired")
+
+// KafkaRecord is a record consumed from a topic
+type KafkaRecord struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// KafkaConsumer consumes a topic as a member of a consumer group through a Kafka REST Proxy
+// (v2 API). Offsets are only committed on request, so records fetched but not committed are
+// delivered again to whichever member next owns their partition.
+type KafkaConsumer struct {
+	client   *http.Client
+	proxyURL string
+	group    string
+	username string
+	password string
+	// baseURI addresses the consumer instance once it is created
+	baseURI string
+}
+
+// NewKafkaConsumer creates a consumer in a group; it joins the group on Subscribe. Username
+// and password are sent as basic auth when set.
+func NewKafkaConsumer(proxyURL, group, username, password string) (*KafkaConsumer, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid Kafka REST Proxy URL %q", proxyURL)
+	}
+	if group == "" {
+		return nil, fmt.Errorf("a consumer group is required")
+	}
+
+	return &KafkaConsumer{
+		client:   &http.Client{Timeout: kafkaPollTimeout + 30*time.Second},
+		proxyURL: strings.TrimRight(proxyURL, "/"),
+		group:    group,
+		username: username,
+		password: password,
+	}, nil
+}
+
+// Subscribe creates the consumer instance, starting from the earliest offset when the group
+// has none committed, and subscribes it to a topic
+func (c *KafkaConsumer) Subscribe(ctx context.Context, topic string) error {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := c.do(ctx, http.MethodPost, c.proxyURL+"/consumers/"+url.PathEscape(c.group), map[string]string{
+		"name":               "advantage-" + hex.EncodeToString(suffix),
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, kafkaContentType, &instance)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	if instance.BaseURI == "" {
+		return fmt.Errorf("failed to create consumer: proxy returned no instance URI")
+	}
+	c.baseURI = strings.TrimRight(instance.BaseURI, "/")
+
+	if err := c.do(ctx, http.MethodPost, c.baseURI+"/subscription", map[string][]string{"topics": {topic}}, kafkaContentType, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Poll fetches the next records, waiting briefly for some to arrive
+func (c *KafkaConsumer) Poll(ctx context.Context) ([]KafkaRecord, error) {
+	endpoint := fmt.Sprintf("%s/records?timeout=%d&max_bytes=%d", c.baseURI, kafkaPollTimeout.Milliseconds(), kafkaMaxBytes)
+
+	var raw []struct {
+		Topic     string `json:"topic"`
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		Key       string `json:"key"`
+		Value     string `json:"value"`
+	}
+	if err := c.do(ctx, http.MethodGet, endpoint, nil, kafkaBinaryType, &raw); err != nil {
+		return nil, fmt.Errorf("failed to poll records: %w", err)
+	}
+
+	records := make([]KafkaRecord, len(raw))
+	for i, r := range raw {
+		key, _ := base64.StdEncoding.DecodeString(r.Key)
+		value, err := base64.StdEncoding.DecodeString(r.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record value at %s/%d@%d: %w", r.Topic, r.Partition, r.Offset, err)
+		}
+		records[i] = KafkaRecord{Topic: r.Topic, Partition: r.Partition, Offset: r.Offset, Key: key, Value: value}
+	}
+	return records, nil
+}
+
+// Commit commits the offsets of every record polled so far
+func (c *KafkaConsumer) Commit(ctx context.Context) error {
+	// An empty body commits everything the instance has fetched
+	if err := c.do(ctx, http.MethodPost, c.baseURI+"/offsets", nil, kafkaContentType, nil); err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	return nil
+}
+
+// Close deletes the consumer instance so the group rebalances its partitions right away
+// rather than after the proxy's instance timeout
+func (c *KafkaConsumer) Close(ctx context.Context) error {
+	if c.baseURI == "" {
+		return nil
+	}
+	err := c.do(ctx, http.MethodDelete, c.baseURI, nil, kafkaContentType, nil)
+	c.baseURI = ""
+	if err != nil && !errors.Is(err, ErrConsumerExpired) {
+		return fmt.Errorf("failed to delete consumer: %w", err)
+	}
+	return nil
+}
+
+// do sends a request to the proxy, decoding the response into out when it isn't nil
+func (c *KafkaConsumer) do(ctx context.Context, method, endpoint string, body interface{}, accept string, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to serialize request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", accept)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var proxyErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&proxyErr)
+		// 40403 is the proxy's code for an unknown consumer instance
+		if resp.StatusCode == http.StatusNotFound && proxyErr.ErrorCode == 40403 {
+			return ErrConsumerExpired
+		}
+		if proxyErr.Message != "" {
+			return fmt.Errorf("kafka rest proxy returned status %d: %s", resp.StatusCode, proxyErr.Message)
+		}
+		return fmt.Errorf("kafka rest proxy returned status %d", resp.StatusCode)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
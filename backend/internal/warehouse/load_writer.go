@@ -0,0 +1,80 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// LoadWriter builds a load for a table, writing its rows as gzipped CSV in memory
+type LoadWriter struct {
+	table Table
+	buf   bytes.Buffer
+	gz    *gzip.Writer
+	csv   *csv.Writer
+	rows  int64
+}
+
+// NewLoadWriter starts a load for a table, writing its header row
+func NewLoadWriter(table Table) (*LoadWriter, error) {
+	w := &LoadWriter{table: table}
+	w.gz = gzip.NewWriter(&w.buf)
+	w.csv = csv.NewWriter(w.gz)
+
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Name
+	}
+	if err := w.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write adds a row with a value per column. Strings, integers, floats and times are supported;
+// a nil time is written as NULL.
+func (w *LoadWriter) Write(values ...interface{}) error {
+	if len(values) != len(w.table.Columns) {
+		return fmt.Errorf("%s has %d columns, got %d values", w.table.Name, len(w.table.Columns), len(values))
+	}
+
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = v
+		case int:
+			record[i] = strconv.Itoa(v)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			record[i] = v.UTC().Format(time.RFC3339Nano)
+		case *time.Time:
+			if v != nil {
+				record[i] = v.UTC().Format(time.RFC3339Nano)
+			}
+		default:
+			return fmt.Errorf("unsupported value for %s.%s: %T", w.table.Name, w.table.Columns[i].Name, value)
+		}
+	}
+
+	w.rows++
+	return w.csv.Write(record)
+}
+
+// Load finishes the CSV and returns the load
+func (w *LoadWriter) Load() (Load, error) {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return Load{}, err
+	}
+	if err := w.gz.Close(); err != nil {
+		return Load{}, err
+	}
+	return Load{Table: w.table, Body: &w.buf, Rows: w.rows}, nil
+}
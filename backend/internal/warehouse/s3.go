@@ -0,0 +1,139 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// s3Bucket writes objects to an S3 bucket with AWS Signature Version 4
+type s3Bucket struct {
+	client          *http.Client
+	endpoint        string // https://{bucket}.s3.{region}.amazonaws.com
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// newS3Bucket creates a writer for a bucket in a region
+func newS3Bucket(bucket, region, accessKeyID, secretAccessKey string) *s3Bucket {
+	return &s3Bucket{
+		client:          &http.Client{Timeout: 5 * time.Minute},
+		endpoint:        fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region),
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+	}
+}
+
+// parseS3URL splits an s3://bucket/prefix URL, returning the prefix without slashes at either end
+func parseS3URL(raw string) (bucket, prefix string, err error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "s3" || parsed.Host == "" {
+		return "", "", fmt.Errorf("%w: stageUrl must be an s3://bucket/prefix URL", ErrInvalidDestination)
+	}
+	return parsed.Host, strings.Trim(parsed.Path, "/"), nil
+}
+
+// Put writes an object, replacing any at the key
+func (b *s3Bucket) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.endpoint+"/"+s3EscapePath(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	b.sign(req, body, time.Now().UTC())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write to S3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("S3 returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// sign adds a Signature Version 4 authorization header covering the host, the payload hash and
+// the request time
+func (b *s3Bucket) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.secretAccessKey), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKeyID, scope, signedHeaders, signature))
+}
+
+// s3EscapePath escapes each segment of an object key the way Signature Version 4 expects,
+// leaving only unreserved characters unescaped
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		var escaped strings.Builder
+		for _, c := range []byte(segment) {
+			if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+				escaped.WriteByte(c)
+			} else {
+				fmt.Fprintf(&escaped, "%%%02X", c)
+			}
+		}
+		segments[i] = escaped.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,297 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// snowflakeTokenTTL is how long a key-pair JWT is valid; Snowflake accepts at most an hour
+const snowflakeTokenTTL = 59 * time.Minute
+
+// snowflakeStatementTimeout is how long, in seconds, Snowflake runs a load before canceling it
+const snowflakeStatementTimeout = 600
+
+// snowflakePollInterval is how often a statement still running is checked on
+const snowflakePollInterval = time.Second
+
+// snowflakeTypes maps column types to Snowflake's
+var snowflakeTypes = map[string]string{
+	TypeString:    "VARCHAR",
+	TypeInteger:   "NUMBER(38, 0)",
+	TypeFloat:     "FLOAT",
+	TypeTimestamp: "TIMESTAMP_TZ",
+}
+
+// Snowflake loads tables into a Snowflake schema. Loads are written as gzipped CSV to the S3
+// bucket behind an external stage the customer created, copied from the stage into a temporary
+// table and swapped into the target table in a transaction. Statements run through the SQL API
+// with key-pair authentication.
+type Snowflake struct {
+	client  *http.Client
+	baseURL string
+
+	// qualifiedUser is ACCOUNT.USER, as the key-pair JWT names its subject
+	qualifiedUser string
+	fingerprint   string
+	privateKey    *rsa.PrivateKey
+
+	role      string
+	warehouse string
+	database  string
+	schema    string
+
+	stage       string
+	stagePrefix string
+	bucket      *s3Bucket
+}
+
+// NewSnowflake creates a Snowflake destination.
+//
+// Settings: account, user, warehouse, database, schema, stage (the external stage's name),
+// stageUrl (the s3://bucket/prefix it points at), stageRegion and optionally role.
+// Credentials: privateKey (an unencrypted PKCS#8 or PKCS#1 PEM RSA key registered with the
+// user), awsAccessKeyId and awsSecretAccessKey (allowed to write to the stage's bucket).
+func NewSnowflake(settings, credentials map[string]string) (*Snowflake, error) {
+	if err := required(settings, "account", "user", "warehouse", "database", "schema", "stage", "stageUrl", "stageRegion"); err != nil {
+		return nil, err
+	}
+	if err := required(credentials, "privateKey", "awsAccessKeyId", "awsSecretAccessKey"); err != nil {
+		return nil, err
+	}
+	if !identifierPattern.MatchString(settings["stage"]) {
+		return nil, fmt.Errorf("%w: stage must be a stage name, optionally qualified by database and schema", ErrInvalidDestination)
+	}
+	bucket, prefix, err := parseS3URL(settings["stageUrl"])
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parseRSAPrivateKey(credentials["privateKey"])
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDestination, err)
+	}
+	fingerprint := sha256.Sum256(publicKey)
+
+	account := strings.ToLower(strings.TrimSpace(settings["account"]))
+	return &Snowflake{
+		client:        &http.Client{Timeout: 2 * time.Minute},
+		baseURL:       "https://" + account + ".snowflakecomputing.com",
+		qualifiedUser: jwtAccount(account) + "." + strings.ToUpper(strings.TrimSpace(settings["user"])),
+		fingerprint:   "SHA256:" + base64.StdEncoding.EncodeToString(fingerprint[:]),
+		privateKey:    privateKey,
+		role:          settings["role"],
+		warehouse:     settings["warehouse"],
+		database:      settings["database"],
+		schema:        settings["schema"],
+		stage:         settings["stage"],
+		stagePrefix:   prefix,
+		bucket:        newS3Bucket(bucket, settings["stageRegion"], credentials["awsAccessKeyId"], credentials["awsSecretAccessKey"]),
+	}, nil
+}
+
+// jwtAccount returns the account as key-pair JWTs name it: upper case and without the region and
+// cloud of an account locator such as xy12345.us-east-1
+func jwtAccount(account string) string {
+	locator, _, _ := strings.Cut(account, ".")
+	return strings.ToUpper(locator)
+}
+
+// parseRSAPrivateKey parses an unencrypted PEM RSA private key
+func parseRSAPrivateKey(value string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(value)))
+	if block == nil {
+		return nil, fmt.Errorf("%w: privateKey must be a PEM-encoded RSA key", ErrInvalidDestination)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid privateKey: %v", ErrInvalidDestination, err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid privateKey: %v", ErrInvalidDestination, err)
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: privateKey must be an RSA key", ErrInvalidDestination)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: privateKey must be an unencrypted RSA key, got %s", ErrInvalidDestination, block.Type)
+	}
+}
+
+// Load stages the load's CSV and replaces the table's rows with it in one transaction
+func (s *Snowflake) Load(ctx context.Context, load Load) error {
+	table := load.Table
+	statements := []string{createTableSQL(table)}
+
+	staged := ""
+	if load.Rows > 0 {
+		body, err := io.ReadAll(load.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read load: %w", err)
+		}
+		name := path.Join(strings.ToLower(table.Name), time.Now().UTC().Format("20060102T150405Z")+"-"+uuid.New().String()+".csv.gz")
+		if err := s.bucket.Put(ctx, path.Join(s.stagePrefix, name), body, "application/gzip"); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", table.Name, err)
+		}
+
+		staged = table.Name + "_LOAD"
+		statements = append(statements,
+			fmt.Sprintf("CREATE OR REPLACE TEMPORARY TABLE %s LIKE %s", staged, table.Name),
+			fmt.Sprintf(`COPY INTO %s FROM @%s/%s FILE_FORMAT = (TYPE = CSV COMPRESSION = GZIP SKIP_HEADER = 1 FIELD_OPTIONALLY_ENCLOSED_BY = '"') PURGE = TRUE`,
+				staged, s.stage, name),
+		)
+	}
+
+	statements = append(statements, "BEGIN")
+	switch {
+	case table.Key == "":
+		statements = append(statements, "DELETE FROM "+table.Name)
+	case staged != "":
+		statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s IN (SELECT %s FROM %s)", table.Name, table.Key, table.Key, staged))
+	}
+	if staged != "" {
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", table.Name, staged))
+	}
+	if table.Key != "" && table.Parent != nil {
+		statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s NOT IN (SELECT %s FROM %s)", table.Name, table.Key, table.Parent.Column, table.Parent.Table))
+	}
+	statements = append(statements, "COMMIT")
+
+	return s.execute(ctx, statements)
+}
+
+// createTableSQL returns the statement creating a table unless it exists
+func createTableSQL(table Table) string {
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.Name + " " + snowflakeTypes[column.Type]
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table.Name, strings.Join(columns, ", "))
+}
+
+// snowflakeResponse is the part of a SQL API response a load needs
+type snowflakeResponse struct {
+	Code               string `json:"code"`
+	Message            string `json:"message"`
+	StatementHandle    string `json:"statementHandle"`
+	StatementStatusURL string `json:"statementStatusUrl"`
+}
+
+// execute runs statements as one multi-statement request, in a single session, and waits for
+// them to finish
+func (s *Snowflake) execute(ctx context.Context, statements []string) error {
+	body := map[string]interface{}{
+		"statement": strings.Join(statements, ";\n"),
+		"timeout":   snowflakeStatementTimeout,
+		"warehouse": s.warehouse,
+		"database":  s.database,
+		"schema":    s.schema,
+		"parameters": map[string]string{
+			"MULTI_STATEMENT_COUNT": fmt.Sprint(len(statements)),
+		},
+	}
+	if s.role != "" {
+		body["role"] = s.role
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	status, resp, err := s.do(ctx, http.MethodPost, "/api/v2/statements", payload)
+	for err == nil && status == http.StatusAccepted {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(snowflakePollInterval):
+		}
+		statusURL := resp.StatementStatusURL
+		if statusURL == "" {
+			statusURL = "/api/v2/statements/" + resp.StatementHandle
+		}
+		status, resp, err = s.do(ctx, http.MethodGet, statusURL, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("snowflake returned %d: %s (code %s)", status, resp.Message, resp.Code)
+	}
+	return nil
+}
+
+// do sends a SQL API request authenticated with a fresh key-pair JWT
+func (s *Snowflake) do(ctx context.Context, method, path string, payload []byte) (int, *snowflakeResponse, error) {
+	token, err := s.token(time.Now())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reach Snowflake: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded snowflakeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decoded); err != nil {
+		return 0, nil, fmt.Errorf("snowflake returned %d with an unreadable body: %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, &decoded, nil
+}
+
+// token signs a key-pair JWT identifying the user by their public key's fingerprint
+func (s *Snowflake) token(now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"iss": s.qualifiedUser + "." + s.fingerprint,
+		"sub": s.qualifiedUser,
+		"iat": now.Unix(),
+		"exp": now.Add(snowflakeTokenTTL).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(s.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign Snowflake token: %w", err)
+	}
+	return token, nil
+}
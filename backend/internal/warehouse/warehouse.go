@@ -0,0 +1,92 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Destination providers
+const (
+	ProviderSnowflake = "snowflake"
+)
+
+// ErrInvalidDestination is returned for destination settings or credentials that are missing or malformed
+var ErrInvalidDestination = errors.New("invalid export destination")
+
+// identifierPattern matches the unquoted identifiers tables and stages may be named with
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*){0,2}$`)
+
+// Column types
+const (
+	TypeString    = "string"
+	TypeInteger   = "integer"
+	TypeFloat     = "float"
+	TypeTimestamp = "timestamp"
+)
+
+// Column is a column of an exported table
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table describes an exported table and how a load replaces its rows
+type Table struct {
+	Name    string
+	Columns []Column
+	// Key is the column a load replaces rows by: rows with a key value the load contains are
+	// replaced, others are kept. An empty key replaces the whole table.
+	Key string
+	// Parent, when set with a Key, is the column listing the key values that still exist; rows
+	// with any other key are deleted on every load
+	Parent *Reference
+}
+
+// Reference is a column of another exported table
+type Reference struct {
+	Table  string
+	Column string
+}
+
+// Load is a batch of rows for a table, written as CSV with a header row
+type Load struct {
+	Table Table
+	// Body is the gzip-compressed CSV
+	Body io.Reader
+	Rows int64
+}
+
+// Destination loads tables into a warehouse
+type Destination interface {
+	// Load replaces the table's rows as its Key describes, creating the table if needed
+	Load(ctx context.Context, load Load) error
+}
+
+// New creates a destination for a provider from its settings and decrypted credentials
+func New(provider string, settings, credentials map[string]string) (Destination, error) {
+	switch provider {
+	case ProviderSnowflake:
+		return NewSnowflake(settings, credentials)
+	default:
+		return nil, fmt.Errorf("%w: provider must be one of %s", ErrInvalidDestination, strings.Join(Providers(), ", "))
+	}
+}
+
+// Providers lists the supported destination providers
+func Providers() []string {
+	return []string{ProviderSnowflake}
+}
+
+// required checks that each named value is set
+func required(values map[string]string, names ...string) error {
+	for _, name := range names {
+		if strings.TrimSpace(values[name]) == "" {
+			return fmt.Errorf("%w: %s is required", ErrInvalidDestination, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/db"
+	"github.com/bolognesandwiches/AdVantage/internal/errreport"
+)
+
+// Worker errors
+var (
+	// ErrShuttingDown is returned when a task is submitted after shutdown has begun
+	ErrShuttingDown = errors.New("worker manager is shutting down")
+	// ErrCanceled is the cause of a task context canceled with Cancel, telling it apart from shutdown
+	ErrCanceled = errors.New("task canceled")
+)
+
+// cancelGracePeriod is how long canceled tasks get to return before they are reported as interrupted
+const cancelGracePeriod = 5 * time.Second
+
+// interruptTimeout bounds each Interrupted callback
+const interruptTimeout = 5 * time.Second
+
+// Task is a unit of background work
+type Task struct {
+	ID string
+	// Priority orders tasks waiting for a slot; higher priorities start first
+	Priority int
+	// Run performs the work; it must return promptly once ctx is canceled
+	Run func(ctx context.Context) error
+	// Interrupted is called when shutdown canceled the task before it finished, so it can be retried later
+	Interrupted func(ctx context.Context) error
+}
+
+// Manager runs background tasks, at most a configurable number at once, and drains them on shutdown
+type Manager struct {
+	mu        sync.Mutex
+	slots     *sync.Cond
+	accepting bool
+	running   map[string]Task
+	cancels   map[string]context.CancelCauseFunc
+	waiting   map[int]int
+	active    int
+	limit     int
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewManager creates a manager that runs up to concurrency tasks at once. Tasks are system work,
+// whose queries row-level security doesn't restrict to one org.
+func NewManager(concurrency int) *Manager {
+	ctx, cancel := context.WithCancel(db.AsSystem(context.Background()))
+	m := &Manager{
+		accepting: true,
+		running:   make(map[string]Task),
+		cancels:   make(map[string]context.CancelCauseFunc),
+		waiting:   make(map[int]int),
+		limit:     max(concurrency, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	m.slots = sync.NewCond(&m.mu)
+	return m
+}
+
+// SetConcurrency changes how many tasks may run at once. Lowering it lets running tasks finish.
+func (m *Manager) SetConcurrency(concurrency int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limit = max(concurrency, 1)
+	m.slots.Broadcast()
+}
+
+// acquire waits for a free slot that no higher priority task is waiting for, returning an
+// error if the task's context is canceled first
+func (m *Manager) acquire(ctx context.Context, priority int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waiting[priority]++
+	defer func() {
+		if m.waiting[priority]--; m.waiting[priority] == 0 {
+			delete(m.waiting, priority)
+		}
+		// Lower priority tasks held back by this one may fit in a remaining slot
+		m.slots.Broadcast()
+	}()
+
+	for (m.active >= m.limit || m.higherWaiting(priority)) && ctx.Err() == nil {
+		m.slots.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.active++
+	return nil
+}
+
+// higherWaiting reports whether a task with a higher priority is waiting for a slot
+func (m *Manager) higherWaiting(priority int) bool {
+	for p := range m.waiting {
+		if p > priority {
+			return true
+		}
+	}
+	return false
+}
+
+// release frees a slot for a waiting task. Every waiter is woken so the highest priority one takes it.
+func (m *Manager) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.active--
+	m.slots.Broadcast()
+}
+
+// Submit starts a task in the background
+func (m *Manager) Submit(task Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.accepting {
+		return ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	m.running[task.ID] = task
+	m.cancels[task.ID] = cancel
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		// Tasks waiting for a slot at shutdown or cancellation are stopped without starting
+		err := m.acquire(ctx, task.Priority)
+		if err == nil {
+			err = task.Run(errreport.WithTags(ctx, "task", task.ID))
+			m.release()
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// Tasks that fail after shutdown began stay registered so Shutdown reports them as interrupted,
+		// unless they had been canceled anyway
+		canceled := errors.Is(context.Cause(ctx), ErrCanceled)
+		if err != nil && m.ctx.Err() != nil && !canceled {
+			return
+		}
+		delete(m.running, task.ID)
+		delete(m.cancels, task.ID)
+		cancel(nil)
+
+		// A canceled task stopping early is what was asked for, not a failure
+		if err != nil && !canceled {
+			errreport.Report(errreport.WithTags(m.ctx, "task", task.ID), "Background task failed", err)
+		}
+	}()
+
+	return nil
+}
+
+// Cancel cancels a running or waiting task, reporting whether it was found. The task's context
+// is canceled with ErrCanceled as its cause; tasks that haven't started never run.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cancel, ok := m.cancels[id]
+	if !ok {
+		return false
+	}
+
+	cancel(ErrCanceled)
+	// Wake the task if it is waiting for a slot
+	m.slots.Broadcast()
+	return true
+}
+
+// Running returns the number of tasks in progress or waiting for a slot
+func (m *Manager) Running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.running)
+}
+
+// Shutdown stops accepting tasks and waits for running ones until ctx is done. Tasks still running
+// at that point are canceled and their Interrupted callbacks are called so they can be retried.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.accepting = false
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Out of time: cancel the remaining tasks, wake those waiting for a slot, and give them a moment to stop
+	m.cancel()
+	m.mu.Lock()
+	m.slots.Broadcast()
+	m.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(cancelGracePeriod):
+	}
+
+	m.mu.Lock()
+	interrupted := make([]Task, 0, len(m.running))
+	for _, task := range m.running {
+		interrupted = append(interrupted, task)
+	}
+	m.mu.Unlock()
+
+	for _, task := range interrupted {
+		if task.Interrupted == nil {
+			continue
+		}
+
+		interruptCtx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+		if err := task.Interrupted(interruptCtx); err != nil {
+			errreport.Report(errreport.WithTags(interruptCtx, "task", task.ID), "Failed to record interrupted task", err)
+		}
+		cancel()
+	}
+
+	if len(interrupted) > 0 {
+		slog.Warn("Interrupted background tasks during shutdown", "count", len(interrupted))
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,275 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/loggen"
+	"github.com/bolognesandwiches/AdVantage/internal/mail"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/reportgen"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+)
+
+// testEnv wires the file and rollup services to in-memory repositories and temporary storage
+type testEnv struct {
+	repos        repository.Repositories
+	logProcessor *ingestion.LogProcessorService
+	files        *services.FileService
+	rollups      *services.RollupService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	dir := t.TempDir()
+	fileStorage, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+
+	store := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(store)
+	unitOfWork := repository.NewMemoryUnitOfWork(store)
+
+	logProcessor := ingestion.NewLogProcessorService(dir)
+	rollups := services.NewRollupService(repos, unitOfWork)
+	logProcessor.SetRollupSink(rollups)
+
+	workers := worker.NewManager(1)
+	t.Cleanup(func() {
+		_ = workers.Shutdown(context.Background())
+	})
+
+	return &testEnv{
+		repos:        repos,
+		logProcessor: logProcessor,
+		files:        services.NewFileService(fileStorage, logProcessor, services.NewResultCache(nil, time.Minute), repos, repos, unitOfWork, workers),
+		rollups:      rollups,
+	}
+}
+
+// createUser records a user in a personal org
+func (e *testEnv) createUser(t *testing.T, id string) {
+	t.Helper()
+
+	now := time.Now()
+	if err := e.repos.Orgs.Upsert(context.Background(), &models.Organization{ID: id, Name: id, JobPriority: models.JobPriorityNormal, CreatedAt: now}); err != nil {
+		t.Fatalf("failed to create org: %v", err)
+	}
+	if err := e.repos.Users.Create(context.Background(), &models.User{ID: id, OrgID: id, Email: id + "@example.com", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+}
+
+// generateLog writes a synthetic Beeswax log
+func generateLog(t *testing.T, rows int) []byte {
+	t.Helper()
+
+	cfg := loggen.DefaultConfig()
+	cfg.Rows = rows
+	generator, err := loggen.New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create log generator: %v", err)
+	}
+	var log bytes.Buffer
+	if _, err := generator.Write(&log); err != nil {
+		t.Fatalf("failed to generate log: %v", err)
+	}
+	return log.Bytes()
+}
+
+func TestUploadProcessAnalyze(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.createUser(t, "user-1")
+
+	upload, err := env.files.UploadFile(ctx, bytes.NewReader(generateLog(t, 2000)), "beeswax.csv", "text/csv", "user-1", "", "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if upload.Status != models.FileStatusUploaded || upload.JobID == "" {
+		t.Fatalf("upload = %+v, want an uploaded file with a queued job", upload)
+	}
+
+	if err := env.files.RunProcessingJob(ctx, upload.JobID, upload.ID, "user-1"); err != nil {
+		t.Fatalf("RunProcessingJob: %v", err)
+	}
+
+	job, err := env.files.GetJob(ctx, upload.JobID, "user-1")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.Status != models.JobStatusCompleted {
+		t.Errorf("job status = %q, want %q (%s)", job.Status, models.JobStatusCompleted, job.Error)
+	}
+	files, err := env.files.ListUserFiles(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListUserFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].Status != models.FileStatusProcessed {
+		t.Fatalf("files = %+v, want the upload processed", files)
+	}
+
+	result, err := env.files.GetLogAnalysisResult(ctx, upload.ID, "user-1")
+	if err != nil {
+		t.Fatalf("GetLogAnalysisResult: %v", err)
+	}
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		t.Fatalf("BeeswaxSummary: %v", err)
+	}
+	if summary.TotalRecords != 2000 {
+		t.Errorf("total records = %d, want 2000", summary.TotalRecords)
+	}
+	if summary.TotalImpressions == 0 || len(summary.CampaignPerformance) == 0 {
+		t.Fatalf("summary has no delivery: %d impressions, %d campaigns", summary.TotalImpressions, len(summary.CampaignPerformance))
+	}
+
+	// The rollups written while processing agree with the summary
+	for campaignID, metrics := range summary.CampaignPerformance {
+		rollup, ok, err := env.rollups.CampaignRollup(ctx, []string{"user-1"}, campaignID, nil, nil)
+		if err != nil {
+			t.Fatalf("CampaignRollup(%s): %v", campaignID, err)
+		}
+		if !ok {
+			t.Fatalf("CampaignRollup(%s) reported files without rollups", campaignID)
+		}
+		if rollup.Totals.Impressions != metrics.Impressions || rollup.Totals.Clicks != metrics.Clicks {
+			t.Errorf("campaign %s rollup = %d impressions, %d clicks, want %d, %d",
+				campaignID, rollup.Totals.Impressions, rollup.Totals.Clicks, metrics.Impressions, metrics.Clicks)
+		}
+	}
+
+	// The week's digest reports the processed file to the opted-in user
+	preferences := services.NewPreferencesService(env.repos.Preferences, env.repos.Users)
+	if err := preferences.UpdatePreferences(ctx, &models.Preferences{UserID: "user-1", Currency: "USD", DateFormat: "YYYY-MM-DD", DefaultDashboard: "overview", EmailDigest: true}); err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	sender := mail.NewMemorySender()
+	sendAt := time.Now().UTC().Add(time.Hour).Truncate(time.Hour)
+	digests := services.NewDigestService(env.logProcessor, env.repos.Digests, preferences, sender, sendAt.Weekday(), sendAt.Hour())
+	if err := digests.SendDue(ctx, sendAt); err != nil {
+		t.Fatalf("SendDue: %v", err)
+	}
+	sent := sender.Sent()
+	if len(sent) != 1 || sent[0].To != "user-1@example.com" || !strings.Contains(sent[0].Subject, "1 files") {
+		t.Fatalf("sent = %+v, want one digest of 1 file to user-1", sent)
+	}
+
+	// Another user sees neither the job nor the file
+	env.createUser(t, "user-2")
+	if _, err := env.files.GetJob(ctx, upload.JobID, "user-2"); err == nil {
+		t.Error("GetJob for another user succeeded")
+	}
+	if _, _, err := env.files.GetFile(ctx, upload.ID, "user-2"); err == nil {
+		t.Error("GetFile for another user succeeded")
+	}
+}
+
+// processLog uploads and processes a generated log for a user, returning its campaigns' delivery
+func (e *testEnv) processLog(t *testing.T, userID string, rows int) map[string]ingestion.CampaignMetrics {
+	t.Helper()
+	ctx := context.Background()
+
+	upload, err := e.files.UploadFile(ctx, bytes.NewReader(generateLog(t, rows)), "beeswax.csv", "text/csv", userID, "", "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if err := e.files.RunProcessingJob(ctx, upload.JobID, upload.ID, userID); err != nil {
+		t.Fatalf("RunProcessingJob: %v", err)
+	}
+	result, err := e.files.GetLogAnalysisResult(ctx, upload.ID, userID)
+	if err != nil {
+		t.Fatalf("GetLogAnalysisResult: %v", err)
+	}
+	summary, err := result.BeeswaxSummary()
+	if err != nil {
+		t.Fatalf("BeeswaxSummary: %v", err)
+	}
+	return summary.CampaignPerformance
+}
+
+func TestCampaignRollupMergesTheOrgsFiles(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.createUser(t, "user-1")
+	env.createUser(t, "user-3")
+	now := time.Now()
+	if err := env.repos.Users.Create(ctx, &models.User{ID: "user-2", OrgID: "user-1", Email: "user-2@example.com", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	first := env.processLog(t, "user-1", 1000)
+	second := env.processLog(t, "user-2", 500)
+	// Another org's delivery of the same campaigns stays out of the rollup
+	env.processLog(t, "user-3", 300)
+
+	fromSummaries := services.NewCampaignService(env.repos, env.logProcessor, services.NewResultCache(nil, time.Minute))
+	fromRollups := services.NewCampaignService(env.repos, env.logProcessor, services.NewResultCache(nil, time.Minute))
+	fromRollups.SetRollups(env.rollups)
+
+	for campaignID, metrics := range first {
+		impressions, clicks := metrics.Impressions, metrics.Clicks
+		if other, ok := second[campaignID]; ok {
+			impressions += other.Impressions
+			clicks += other.Clicks
+		}
+
+		for name, campaigns := range map[string]*services.CampaignService{"summaries": fromSummaries, "rollups": fromRollups} {
+			rollup, err := campaigns.GetCampaignRollup(ctx, "user-1", campaignID, nil, nil)
+			if err != nil {
+				t.Fatalf("GetCampaignRollup(%s) from %s: %v", campaignID, name, err)
+			}
+			if rollup.Totals.Impressions != impressions || rollup.Totals.Clicks != clicks {
+				t.Errorf("campaign %s rollup from %s = %d impressions, %d clicks, want both users' %d, %d",
+					campaignID, name, rollup.Totals.Impressions, rollup.Totals.Clicks, impressions, clicks)
+			}
+		}
+	}
+}
+
+// fixedNarrative writes the same narrative for every file
+type fixedNarrative string
+
+func (n fixedNarrative) Generate(ctx context.Context, result *ingestion.LogAnalysisResult) (string, error) {
+	return string(n), nil
+}
+
+func TestReportIncludesTheFilesNarratives(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.createUser(t, "user-1")
+	narrative := "Spend rose through the week while the cost per click held steady."
+	env.logProcessor.SetNarrativeGenerator(fixedNarrative(narrative))
+	env.processLog(t, "user-1", 500)
+
+	templates := services.NewReportTemplateService(env.repos, env.rollups, services.NewCustomMetricService(env.repos), env.logProcessor)
+	template := &models.ReportTemplate{OrgID: "user-1", Name: "Weekly", CreatedBy: "user-1", Sections: []models.ReportSection{{Type: models.ReportSectionSummary}}}
+	if err := templates.CreateTemplate(ctx, template); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+
+	report, err := templates.GenerateReport(ctx, "user-1", "user-1", template.ID, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+	if len(report.Insights) != 1 || report.Insights[0].FileName != "beeswax.csv" || report.Insights[0].Text != narrative {
+		t.Fatalf("insights = %+v, want the processed file's narrative", report.Insights)
+	}
+
+	var pdf bytes.Buffer
+	if err := reportgen.WritePDF(&pdf, report); err != nil {
+		t.Fatalf("WritePDF: %v", err)
+	}
+	if !bytes.Contains(pdf.Bytes(), []byte(narrative)) {
+		t.Error("the PDF doesn't include the narrative")
+	}
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bolognesandwiches/AdVantage/internal/backup"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+)
+
+// backupArchive writes a backup archive of a manifest and its analyses' contents
+func backupArchive(t *testing.T, manifest backup.Manifest, analyses map[string]string) []byte {
+	t.Helper()
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, contents := range analyses {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := entry.Write([]byte(contents)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	entry, err := writer.Create("manifest.json")
+	if err != nil {
+		t.Fatalf("failed to add manifest: %v", err)
+	}
+	if err := json.NewEncoder(entry).Encode(manifest); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	return archive.Bytes()
+}
+
+func TestRestoreRefusesArchivesPastTheDecompressionLimits(t *testing.T) {
+	limits := storage.DecompressionLimits{MaxRatio: 10, MaxBytes: 1 << 20}
+	tests := []struct {
+		name     string
+		manifest backup.Manifest
+		analyses map[string]string
+	}{
+		{
+			name: "declared sizes",
+			manifest: backup.Manifest{Format: backup.Format, FormatVersion: backup.FormatVersion, Entries: []backup.Entry{
+				{Name: "analyses/a.json", Size: 2 << 20},
+			}},
+			analyses: map[string]string{"analyses/a.json": "{}"},
+		},
+		{
+			name: "manifest",
+			manifest: backup.Manifest{Format: backup.Format, FormatVersion: backup.FormatVersion, Entries: []backup.Entry{
+				{Name: "analyses/a.json", SHA256: strings.Repeat("0", 2<<20)},
+			}},
+			analyses: map[string]string{"analyses/a.json": "{}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := backupArchive(t, tt.manifest, tt.analyses)
+
+			// The archive is refused before the database is used
+			_, err := backup.Restore(context.Background(), nil, t.TempDir(), bytes.NewReader(archive), int64(len(archive)), limits)
+			if !errors.Is(err, storage.ErrDecompressionLimit) {
+				t.Errorf("Restore error = %v, want ErrDecompressionLimit", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+)
+
+func TestDeriveDownloadKey(t *testing.T) {
+	key, err := services.DeriveDownloadKey("jwt-secret")
+	if err != nil {
+		t.Fatalf("DeriveDownloadKey: %v", err)
+	}
+	if len(key) != 32 || bytes.Contains(key, []byte("jwt-secret")) {
+		t.Errorf("derived key = %x, want 32 bytes unlike the secret", key)
+	}
+
+	again, err := services.DeriveDownloadKey("jwt-secret")
+	if err != nil {
+		t.Fatalf("DeriveDownloadKey: %v", err)
+	}
+	if !bytes.Equal(again, key) {
+		t.Error("deriving the key again gave a different key, so signed URLs wouldn't survive a restart")
+	}
+	other, err := services.DeriveDownloadKey("other-secret")
+	if err != nil {
+		t.Fatalf("DeriveDownloadKey: %v", err)
+	}
+	if bytes.Equal(other, key) {
+		t.Error("different secrets derived the same key")
+	}
+
+	if _, err := services.DeriveDownloadKey(""); err == nil {
+		t.Error("DeriveDownloadKey derived a key from an empty secret")
+	}
+}
+
+func TestDeriveJourneyKey(t *testing.T) {
+	key, err := services.DeriveJourneyKey("jwt-secret")
+	if err != nil {
+		t.Fatalf("DeriveJourneyKey: %v", err)
+	}
+	if len(key) != 32 || bytes.Contains(key, []byte("jwt-secret")) {
+		t.Errorf("derived key = %x, want 32 bytes unlike the secret", key)
+	}
+
+	// Keys derived from the same secret for other uses must differ, so a journey hash never
+	// reveals anything that signs downloads
+	downloadKey, err := services.DeriveDownloadKey("jwt-secret")
+	if err != nil {
+		t.Fatalf("DeriveDownloadKey: %v", err)
+	}
+	if bytes.Equal(key, downloadKey) {
+		t.Error("the journey and download keys derived from one secret are the same")
+	}
+
+	if _, err := services.DeriveJourneyKey(""); err == nil {
+		t.Error("DeriveJourneyKey derived a key from an empty secret")
+	}
+}
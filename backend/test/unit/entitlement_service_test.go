@@ -0,0 +1,43 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+)
+
+func TestRequireUserFeatureFollowsThePlan(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	newTestUser(t, repos, "user-1")
+	entitlements := services.NewEntitlementService(repos)
+
+	if err := entitlements.RequireUserFeature(ctx, "user-1", models.FeatureConnectors); !errors.Is(err, services.ErrFeatureNotInPlan) {
+		t.Errorf("RequireUserFeature on the free plan: err = %v, want ErrFeatureNotInPlan", err)
+	}
+
+	if _, err := entitlements.SetPlan(ctx, "user-1", models.PlanPro, nil); err != nil {
+		t.Fatalf("SetPlan: %v", err)
+	}
+	if err := entitlements.RequireUserFeature(ctx, "user-1", models.FeatureConnectors); err != nil {
+		t.Errorf("RequireUserFeature on the pro plan: %v", err)
+	}
+
+	// Scheduled syncs check again when they run, so they stop once a trial has ended
+	ended := time.Now().Add(-time.Hour)
+	if _, err := entitlements.SetPlan(ctx, "user-1", models.PlanTrial, &ended); err != nil {
+		t.Fatalf("SetPlan: %v", err)
+	}
+	if err := entitlements.RequireUserFeature(ctx, "user-1", models.FeatureConnectors); !errors.Is(err, services.ErrFeatureNotInPlan) {
+		t.Errorf("RequireUserFeature after the trial ended: err = %v, want ErrFeatureNotInPlan", err)
+	}
+
+	if err := entitlements.RequireUserFeature(ctx, "user-2", models.FeatureConnectors); !errors.Is(err, services.ErrUserNotFound) {
+		t.Errorf("RequireUserFeature for a missing user: err = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,204 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+	"github.com/bolognesandwiches/AdVantage/internal/storage"
+	"github.com/bolognesandwiches/AdVantage/internal/worker"
+)
+
+const testLog = "AUCTION_ID,CAMPAIGN_ID,BID_PRICE,WIN_PRICE\nauction-1,campaign-1,2.5,1.9\n"
+
+// failingJobs fails every enqueue
+type failingJobs struct {
+	repository.JobRepository
+	err error
+}
+
+func (j failingJobs) Enqueue(ctx context.Context, job *models.ProcessingJob) error {
+	return j.err
+}
+
+// failingUnitOfWork runs units of work against a memory store whose job enqueues fail
+type failingUnitOfWork struct {
+	*repository.MemoryUnitOfWork
+	err error
+}
+
+func (u failingUnitOfWork) WithinTx(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+	return u.MemoryUnitOfWork.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		repos.Jobs = failingJobs{JobRepository: repos.Jobs, err: u.err}
+		return fn(ctx, repos)
+	})
+}
+
+// newFileService creates a file service on in-memory repositories and temporary storage,
+// running units of work with uow when given
+func newFileService(t *testing.T, store *repository.MemoryStore, uow repository.UnitOfWork) (*services.FileService, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	fileStorage, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	if uow == nil {
+		uow = repository.NewMemoryUnitOfWork(store)
+	}
+	repos := repository.NewMemoryRepositories(store)
+
+	workers := worker.NewManager(1)
+	t.Cleanup(func() {
+		_ = workers.Shutdown(context.Background())
+	})
+
+	service := services.NewFileService(fileStorage, ingestion.NewLogProcessorService(dir), services.NewResultCache(nil, time.Minute), repos, repos, uow, workers)
+	return service, dir
+}
+
+// storedFiles counts the files under a storage directory
+func storedFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Type().IsRegular() {
+			count++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to walk storage: %v", err)
+	}
+	return count
+}
+
+func TestUploadFileRecordsFileAndJob(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	newTestUser(t, repository.NewMemoryRepositories(store), "user-1")
+	files, _ := newFileService(t, store, nil)
+
+	upload, err := files.UploadFile(ctx, strings.NewReader(testLog), "log.csv", "text/csv", "user-1", "", "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	job, err := files.GetJob(ctx, upload.JobID, "user-1")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.FileID != upload.ID || job.Status != models.JobStatusQueued || job.Priority != models.JobPriorityNormal {
+		t.Errorf("job = %+v, want a normal priority job queued for file %s", job, upload.ID)
+	}
+	listed, err := files.ListUserFiles(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListUserFiles: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != upload.ID {
+		t.Errorf("listed files = %+v, want the upload", listed)
+	}
+
+	if _, err := files.GetJob(ctx, upload.JobID, "user-2"); !errors.Is(err, services.ErrJobNotFound) {
+		t.Errorf("GetJob for another user: err = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestUploadFileIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	newTestUser(t, repository.NewMemoryRepositories(store), "user-1")
+	files, dir := newFileService(t, store, nil)
+
+	first, err := files.UploadFile(ctx, strings.NewReader(testLog), "log.csv", "text/csv", "user-1", "key-1", "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	second, err := files.UploadFile(ctx, strings.NewReader(testLog), "log.csv", "text/csv", "user-1", "key-1", "")
+	if err != nil {
+		t.Fatalf("repeated UploadFile: %v", err)
+	}
+
+	if second.ID != first.ID || second.JobID != first.JobID {
+		t.Errorf("repeated upload = %s (job %s), want %s (job %s)", second.ID, second.JobID, first.ID, first.JobID)
+	}
+	if stored := storedFiles(t, dir); stored != 1 {
+		t.Errorf("stored files = %d, want 1", stored)
+	}
+}
+
+func TestUploadFileRollsBackWhenEnqueueFails(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(store)
+	newTestUser(t, repos, "user-1")
+	failure := errors.New("queue unavailable")
+	files, dir := newFileService(t, store, failingUnitOfWork{MemoryUnitOfWork: repository.NewMemoryUnitOfWork(store), err: failure})
+
+	if _, err := files.UploadFile(ctx, strings.NewReader(testLog), "log.csv", "text/csv", "user-1", "key-1", ""); !errors.Is(err, failure) {
+		t.Fatalf("UploadFile error = %v, want %v", err, failure)
+	}
+
+	recorded, err := repos.Files.ListByUser(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(recorded) != 0 {
+		t.Errorf("files recorded after a failed upload = %d, want none", len(recorded))
+	}
+	if _, err := repos.Idempotency.Find(ctx, "user-1", "key-1"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("idempotency key after a failed upload: err = %v, want ErrNotFound", err)
+	}
+	if stored := storedFiles(t, dir); stored != 0 {
+		t.Errorf("stored files after a failed upload = %d, want none", stored)
+	}
+}
+
+func TestUploadFileRejectsUnknownTypes(t *testing.T) {
+	store := repository.NewMemoryStore()
+	newTestUser(t, repository.NewMemoryRepositories(store), "user-1")
+	files, dir := newFileService(t, store, nil)
+
+	_, err := files.UploadFile(context.Background(), strings.NewReader("%PDF"), "log.pdf", "application/pdf", "user-1", "", "")
+	if !errors.Is(err, services.ErrFileTypeNotAllowed) {
+		t.Errorf("UploadFile error = %v, want ErrFileTypeNotAllowed", err)
+	}
+	if stored := storedFiles(t, dir); stored != 0 {
+		t.Errorf("stored files = %d, want none", stored)
+	}
+}
+
+func TestDeleteFileRemovesItsJobs(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	newTestUser(t, repository.NewMemoryRepositories(store), "user-1")
+	files, dir := newFileService(t, store, nil)
+
+	upload, err := files.UploadFile(ctx, strings.NewReader(testLog), "log.csv", "text/csv", "user-1", "", "")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if err := files.DeleteFile(ctx, upload.ID, "user-1"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+
+	if _, err := files.GetJob(ctx, upload.JobID, "user-1"); !errors.Is(err, services.ErrJobNotFound) {
+		t.Errorf("GetJob after delete: err = %v, want ErrJobNotFound", err)
+	}
+	if err := files.DeleteFile(ctx, upload.ID, "user-1"); !errors.Is(err, services.ErrFileNotFound) {
+		t.Errorf("second DeleteFile: err = %v, want ErrFileNotFound", err)
+	}
+	if stored := storedFiles(t, dir); stored != 0 {
+		t.Errorf("stored files after delete = %d, want none", stored)
+	}
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+)
+
+// newSession signs user-1 in, returning the session service, the session ID and its refresh token
+func newSession(t *testing.T) (*services.SessionService, string, string) {
+	t.Helper()
+
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	newTestUser(t, repos, "user-1")
+	sessions := services.NewSessionService(repos.Sessions)
+
+	session, err := sessions.CreateSession(context.Background(), "user-1", "curl/8.0", "127.0.0.1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if session.RefreshToken == "" {
+		t.Fatal("session was created without a refresh token")
+	}
+	return sessions, session.ID, session.RefreshToken
+}
+
+func TestRefreshSessionRotatesTheRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	sessions, id, refreshToken := newSession(t)
+
+	refreshed, err := sessions.RefreshSession(ctx, refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if refreshed.ID != id || refreshed.RefreshToken == "" || refreshed.RefreshToken == refreshToken {
+		t.Fatalf("refreshed session = %s with token %q, want %s with a new token", refreshed.ID, refreshed.RefreshToken, id)
+	}
+
+	again, err := sessions.RefreshSession(ctx, refreshed.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession with the new token: %v", err)
+	}
+	if err := sessions.ValidateSession(ctx, id, "user-1"); err != nil {
+		t.Errorf("ValidateSession after refreshing: %v", err)
+	}
+
+	// Using a replaced token again means it was copied, so the session is signed out
+	if _, err := sessions.RefreshSession(ctx, refreshed.RefreshToken); !errors.Is(err, services.ErrRefreshTokenReplaced) {
+		t.Fatalf("RefreshSession with a replaced token: err = %v, want ErrRefreshTokenReplaced", err)
+	}
+	if err := sessions.ValidateSession(ctx, id, "user-1"); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Errorf("ValidateSession after a replaced token was used: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, again.RefreshToken); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Errorf("RefreshSession after a replaced token was used: err = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRefreshSessionRejectsRevokedSessions(t *testing.T) {
+	ctx := context.Background()
+	sessions, id, refreshToken := newSession(t)
+
+	if err := sessions.RevokeSession(ctx, id, "user-1"); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := sessions.RefreshSession(ctx, refreshToken); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Errorf("RefreshSession of a revoked session: err = %v, want ErrSessionRevoked", err)
+	}
+	for _, token := range []string{"", "not-a-refresh-token"} {
+		if _, err := sessions.RefreshSession(ctx, token); !errors.Is(err, services.ErrInvalidRefreshToken) {
+			t.Errorf("RefreshSession(%q): err = %v, want ErrInvalidRefreshToken", token, err)
+		}
+	}
+}
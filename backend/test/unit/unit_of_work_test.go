@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/ingestion"
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+)
+
+// newTestFile returns a file uploaded by a user
+func newTestFile(id, userID string) *models.File {
+	now := time.Now()
+	return &models.File{
+		ID:         id,
+		UserID:     userID,
+		FileName:   id + ".csv",
+		FileType:   "text/csv",
+		Status:     models.FileStatusUploaded,
+		UploadedAt: now,
+		UpdatedAt:  now,
+	}
+}
+
+// newTestUser records a user in a personal org
+func newTestUser(t *testing.T, repos repository.Repositories, id string) {
+	t.Helper()
+
+	now := time.Now()
+	if err := repos.Orgs.Upsert(context.Background(), &models.Organization{ID: id, Name: id, JobPriority: models.JobPriorityNormal, CreatedAt: now}); err != nil {
+		t.Fatalf("failed to create org: %v", err)
+	}
+	if err := repos.Users.Create(context.Background(), &models.User{ID: id, OrgID: id, Email: id + "@example.com", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+}
+
+func TestUnitOfWorkCommits(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(store)
+	newTestUser(t, repos, "user-1")
+
+	err := repository.NewMemoryUnitOfWork(store).WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		return repos.Files.Create(ctx, newTestFile("file-1", "user-1"))
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	if _, err := repos.Files.FindByID(ctx, "file-1", "user-1"); err != nil {
+		t.Errorf("file written in a committed unit of work: %v", err)
+	}
+}
+
+func TestUnitOfWorkRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(store)
+	newTestUser(t, repos, "user-1")
+	if err := repos.Files.Create(ctx, newTestFile("kept", "user-1")); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	failure := errors.New("enqueue failed")
+	err := repository.NewMemoryUnitOfWork(store).WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if err := repos.Files.Create(ctx, newTestFile("file-1", "user-1")); err != nil {
+			return err
+		}
+		if err := repos.Files.Delete(ctx, "kept", "user-1"); err != nil {
+			return err
+		}
+		if err := repos.Experiments.Create(ctx, &models.Experiment{ID: "experiment-1", UserID: "user-1", Name: "test"}); err != nil {
+			return err
+		}
+		if err := repos.Annotations.CreateAnnotation(ctx, "user-1", &ingestion.CampaignAnnotation{ID: "annotation-1", CampaignID: "campaign-1"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithinTx error = %v, want %v", err, failure)
+	}
+
+	if _, err := repos.Files.FindByID(ctx, "file-1", "user-1"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("file created in a failed unit of work: err = %v, want ErrNotFound", err)
+	}
+	if _, err := repos.Files.FindByID(ctx, "kept", "user-1"); err != nil {
+		t.Errorf("file deleted in a failed unit of work is gone: %v", err)
+	}
+	experiments, err := repos.Experiments.ListByUser(ctx, "user-1")
+	if err != nil || len(experiments) != 0 {
+		t.Errorf("experiments after a failed unit of work = %d (%v), want none", len(experiments), err)
+	}
+	annotations, err := repos.Annotations.ListAnnotations(ctx, "user-1", "campaign-1", nil, nil)
+	if err != nil || len(annotations) != 0 {
+		t.Errorf("annotations after a failed unit of work = %d (%v), want none", len(annotations), err)
+	}
+
+	// The restored store still takes writes
+	if err := repos.Experiments.Create(ctx, &models.Experiment{ID: "experiment-2", UserID: "user-1", Name: "test"}); err != nil {
+		t.Errorf("experiment created after a rollback: %v", err)
+	}
+}
+
+func TestUnitOfWorkRollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(store)
+	newTestUser(t, repos, "user-1")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithinTx swallowed the panic")
+			}
+		}()
+		_ = repository.NewMemoryUnitOfWork(store).WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+			if err := repos.Files.Create(ctx, newTestFile("file-1", "user-1")); err != nil {
+				return err
+			}
+			panic("processing failed")
+		})
+	}()
+
+	if _, err := repos.Files.FindByID(ctx, "file-1", "user-1"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("file created in a panicked unit of work: err = %v, want ErrNotFound", err)
+	}
+}
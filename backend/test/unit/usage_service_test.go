@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bolognesandwiches/AdVantage/internal/models"
+	"github.com/bolognesandwiches/AdVantage/internal/repository"
+	"github.com/bolognesandwiches/AdVantage/internal/services"
+)
+
+// blockingUsage holds every usage write until released
+type blockingUsage struct {
+	repository.UsageRepository
+	adding  chan struct{}
+	release chan struct{}
+}
+
+func (u blockingUsage) Add(ctx context.Context, orgID string, period time.Time, apiCalls, rowsIngested int64) error {
+	u.adding <- struct{}{}
+	<-u.release
+	return u.UsageRepository.Add(ctx, orgID, period, apiCalls, rowsIngested)
+}
+
+// usedAPICalls records an org's API calls this month in the database
+func usedAPICalls(t *testing.T, repos repository.Repositories, orgID string, calls int64) {
+	t.Helper()
+
+	now := time.Now().UTC()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if err := repos.Usage.Add(context.Background(), orgID, period, calls, 0); err != nil {
+		t.Fatalf("failed to record usage: %v", err)
+	}
+}
+
+func TestUsageLimitsFollowThePlan(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	newTestUser(t, repos, "user-1")
+	usage := services.NewUsageService(repos)
+
+	free, err := usage.Limits(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("Limits: %v", err)
+	}
+	if free.APICalls == 0 || free.RowsIngested == 0 || free.StorageBytes == 0 {
+		t.Fatalf("free plan limits = %+v, want every limit set", free)
+	}
+
+	usedAPICalls(t, repos, "user-1", free.APICalls)
+	if err := usage.MeterAPICall(ctx, "user-1"); !errors.Is(err, services.ErrAPICallLimit) {
+		t.Errorf("MeterAPICall on the free plan: err = %v, want ErrAPICallLimit", err)
+	}
+
+	if err := repos.Orgs.SetPlan(ctx, "user-1", models.PlanEnterprise, nil); err != nil {
+		t.Fatalf("SetPlan: %v", err)
+	}
+	// A new service doesn't have the free plan's limits cached
+	usage = services.NewUsageService(repos)
+	if err := usage.MeterAPICall(ctx, "user-1"); err != nil {
+		t.Errorf("MeterAPICall on the enterprise plan: %v", err)
+	}
+	report, err := usage.Usage(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if report.Limits != (services.UsageLimits{}) {
+		t.Errorf("enterprise plan limits = %+v, want unlimited", report.Limits)
+	}
+}
+
+func TestFlushKeepsUsageBeingWrittenCounted(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+	newTestUser(t, repos, "user-1")
+
+	limits, err := services.NewUsageService(repos).Limits(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("Limits: %v", err)
+	}
+	usedAPICalls(t, repos, "user-1", limits.APICalls-1)
+
+	blocking := blockingUsage{UsageRepository: repos.Usage, adding: make(chan struct{}), release: make(chan struct{})}
+	repos.Usage = blocking
+	usage := services.NewUsageService(repos)
+	if err := usage.MeterAPICall(ctx, "user-1"); err != nil {
+		t.Fatalf("MeterAPICall under the limit: %v", err)
+	}
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- usage.Flush(ctx)
+	}()
+	<-blocking.adding
+
+	// The last call is being written, so the org is at its limit
+	if err := usage.MeterAPICall(ctx, "user-1"); !errors.Is(err, services.ErrAPICallLimit) {
+		t.Errorf("MeterAPICall while flushing: err = %v, want ErrAPICallLimit", err)
+	}
+
+	close(blocking.release)
+	if err := <-flushed; err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if err := usage.MeterAPICall(ctx, "user-1"); !errors.Is(err, services.ErrAPICallLimit) {
+		t.Errorf("MeterAPICall after flushing: err = %v, want ErrAPICallLimit", err)
+	}
+	report, err := usage.Usage(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if report.APICalls != limits.APICalls {
+		t.Errorf("API calls = %d, want %d", report.APICalls, limits.APICalls)
+	}
+}